@@ -0,0 +1,406 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services"
+)
+
+// maxAPIAttachments is the max number of attachments per API message.
+const maxAPIAttachments = 10
+
+type APIHandler struct {
+	ws  ports.WorkspaceServicer
+	ths ports.ThreadServicer
+	aps ports.APIServicer
+}
+
+func NewAPIHandler(ws ports.WorkspaceServicer, ths ports.ThreadServicer, aps ports.APIServicer) *APIHandler {
+	return &APIHandler{ws: ws, ths: ths, aps: aps}
+}
+
+func (h *APIHandler) handleGetAPISetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.aps.GetAPISetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch api setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateAPISetting saves the workspace API channel setting with the reply webhook.
+func (h *APIHandler) handleUpdateAPISetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp APISettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.aps.GetAPISetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch api setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.IsEnabled = reqp.IsEnabled
+	setting.WebhookUrl = strings.TrimSpace(reqp.WebhookUrl)
+	if reqp.WebhookSecret != "" {
+		setting.WebhookSecret = reqp.WebhookSecret
+	}
+	if err := setting.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setting, err = h.aps.SaveAPISetting(ctx, setting)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save api setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// isAPIEnabled checks the workspace API channel is enabled, responds otherwise.
+func (h *APIHandler) isAPIEnabled(w http.ResponseWriter, ctx context.Context, workspaceId string) bool {
+	hub := sentry.GetHubFromContext(ctx)
+	setting, err := h.aps.GetAPISetting(ctx, workspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch api setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return false
+	}
+	if !setting.IsEnabled {
+		http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+		return false
+	}
+	return true
+}
+
+// validAPIMessage checks the message has the text body or the attachments.
+func validAPIMessage(textBody string, attachments []models.APIAttachment) bool {
+	if strings.TrimSpace(textBody) == "" && len(attachments) == 0 {
+		return false
+	}
+	if len(attachments) > maxAPIAttachments {
+		return false
+	}
+	for _, a := range attachments {
+		if a.Content == "" || a.ContentType == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// upsertCustomer fetches or creates the Customer by the external ID, email or phone in that order.
+func (h *APIHandler) upsertCustomer(
+	ctx context.Context, workspaceId string, reqp CreateCustomerReq) (models.Customer, error) {
+	externalId := models.NullString(reqp.ExternalId)
+	email := models.NullString(reqp.Email)
+	phone := models.NullString(reqp.Phone)
+
+	var customer models.Customer
+	var err error
+	switch {
+	case externalId.Valid:
+		customer, _, err = h.ws.CreateCustomerWithExternalId(ctx, workspaceId, externalId.String, reqp.Name)
+	case email.Valid:
+		customer, _, err = h.ws.CreateCustomerWithEmail(
+			ctx, workspaceId, email.String, reqp.IsEmailVerified, reqp.Name)
+	default:
+		customer, _, err = h.ws.CreateCustomerWithPhone(ctx, workspaceId, phone.String, reqp.Name)
+	}
+	return customer, err
+}
+
+// handleCreateAPIThread creates the Thread on behalf of the Customer from the workspace's own backend.
+// The Customer is created if not exists.
+func (h *APIHandler) handleCreateAPIThread(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp CreateAPIThreadReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	ci := reqp.Customer
+	if !models.NullString(ci.ExternalId).Valid && !models.NullString(ci.Email).Valid &&
+		!models.NullString(ci.Phone).Valid {
+		http.Error(w, "at least one of `externalId`, `email` or `phone` is required", http.StatusBadRequest)
+		return
+	}
+	if !validAPIMessage(reqp.TextBody, reqp.Attachments) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if !h.isAPIEnabled(w, ctx, member.WorkspaceId) {
+		return
+	}
+
+	customer, err := h.upsertCustomer(ctx, member.WorkspaceId, ci)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch or create customer for api thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if customer.IsBlocked {
+		http.Error(w, services.ErrCustomerBlocked.Error(), http.StatusForbidden)
+		return
+	}
+
+	thread, message, attachments, err := h.aps.CreateAPIThread(
+		ctx, member.WorkspaceId, customer.AsCustomerActor(), member.AsMemberActor(),
+		strings.TrimSpace(reqp.Title), reqp.TextBody, reqp.Attachments)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to create api thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := APIThreadResp{
+		Thread: ThreadResp{}.NewResponse(&thread),
+		Message: MessageWithAttachmentsResp{
+			MessageResp: MessageResp{}.NewResponse(&message),
+			Attachments: attachments,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleCreateAPIThreadMessage appends the Customer's message to the API Thread.
+func (h *APIHandler) handleCreateAPIThreadMessage(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	var reqp APIThreadMessageReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if !validAPIMessage(reqp.TextBody, reqp.Attachments) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if !h.isAPIEnabled(w, ctx, member.WorkspaceId) {
+		return
+	}
+
+	channel := models.ThreadChannel{}.API()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	customer, err := h.ws.GetCustomer(ctx, member.WorkspaceId, thread.Customer.CustomerId, nil)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if customer.IsBlocked {
+		http.Error(w, services.ErrCustomerBlocked.Error(), http.StatusForbidden)
+		return
+	}
+
+	message, attachments, err := h.aps.AppendAPIThreadMessage(
+		ctx, thread, customer.AsCustomerActor(), member.AsMemberActor(), reqp.TextBody, reqp.Attachments)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to append api thread message", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := MessageWithAttachmentsResp{
+		MessageResp: MessageResp{}.NewResponse(&message),
+		Attachments: attachments,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleReplyThreadAPI sends the Member's reply on the API Thread to the workspace webhook.
+func (h *APIHandler) handleReplyThreadAPI(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	var reqp ReplyThreadAPIReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(reqp.TextBody) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	channel := models.ThreadChannel{}.API()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// API setting must have the webhook before sending a reply
+	setting, err := h.aps.GetAPISetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch api setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !setting.IsEnabled || !setting.HasWebhook() {
+		http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+		return
+	}
+
+	customer, err := h.ws.GetCustomer(ctx, member.WorkspaceId, thread.Customer.CustomerId, nil)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	message, delivery, err := h.aps.SendThreadAPIReply(ctx, setting, thread, *member, customer, reqp.TextBody)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send thread api reply", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := APIReplyResp{
+		Message:  MessageResp{}.NewResponse(&message),
+		Delivery: delivery,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleGetThreadAPILogs returns the webhook deliveries of the API Thread messages.
+func (h *APIHandler) handleGetThreadAPILogs(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	channel := models.ThreadChannel{}.API()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logs, err := h.aps.ListThreadAPILogs(ctx, thread.ThreadId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread api logs", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(logs); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
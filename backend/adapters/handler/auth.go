@@ -18,6 +18,10 @@ type AuthenticatedAccountHandler func(http.ResponseWriter, *http.Request, *model
 
 type AuthenticatedMemberHandler func(http.ResponseWriter, *http.Request, *models.Member)
 
+// AuthenticatedWebhookHandler is the workspace webhook handler of the verified webhook credential,
+// nil when authenticated with the global legacy credentials.
+type AuthenticatedWebhookHandler func(http.ResponseWriter, *http.Request, *models.WebhookCredential)
+
 func CheckAuthCredentials(r *http.Request) (string, string, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
@@ -92,3 +96,24 @@ func AuthenticateMember(
 		return models.Member{}, fmt.Errorf("unsupported scheme `%s` cannot authenticate", scheme)
 	}
 }
+
+// AuthenticateAPIMember authenticates the workspace member as AuthenticateMember, also with the PAT
+// of the account's membership used by the workspace's own backend for the API channel requests.
+func AuthenticateAPIMember(
+	ctx context.Context, authz ports.AuthServicer, workspaceId string, scheme string, cred string,
+) (models.Member, error) {
+	if scheme != "token" {
+		return AuthenticateMember(ctx, authz, workspaceId, scheme, cred)
+	}
+	account, err := AuthenticateAccount(ctx, authz, scheme, cred)
+	if err != nil {
+		return models.Member{}, err
+	}
+
+	member, err := authz.AuthenticateWorkspaceMember(ctx, workspaceId, account.AccountId)
+	if err != nil {
+		return models.Member{}, fmt.Errorf("failed to authenticate workspace member: %v", err)
+	}
+
+	return member, nil
+}
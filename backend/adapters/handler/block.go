@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services"
+)
+
+type BlocklistHandler struct {
+	ws  ports.WorkspaceServicer
+	bls ports.BlocklistServicer
+}
+
+func NewBlocklistHandler(ws ports.WorkspaceServicer, bls ports.BlocklistServicer) *BlocklistHandler {
+	return &BlocklistHandler{ws: ws, bls: bls}
+}
+
+func (h *BlocklistHandler) handleGetSenderBlocks(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	blocks, err := h.bls.ListSenderBlocks(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch sender blocks", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(blocks); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleCreateSenderBlock blocks the sender email address, domain or IP range.
+func (h *BlocklistHandler) handleCreateSenderBlock(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp SenderBlockReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	block, err := models.NewSenderBlock(
+		member.WorkspaceId, reqp.Kind, reqp.Value, reqp.Action, reqp.Reason, member.AsMemberActor())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	block, err = h.bls.BlockSender(ctx, block)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to block sender", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(block); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleDeleteSenderBlock unblocks the sender, the request body with the reason is optional.
+func (h *BlocklistHandler) handleDeleteSenderBlock(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp BlockReasonReq
+	if err := json.NewDecoder(r.Body).Decode(&reqp); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	blockId := r.PathValue("blockId")
+	err := h.bls.UnblockSender(ctx, member.WorkspaceId, blockId, member.AsMemberActor(), reqp.Reason)
+	if errors.Is(err, services.ErrSenderBlockNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to unblock sender", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *BlocklistHandler) handleGetBlockAudits(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	audits, err := h.bls.ListBlockAudits(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch block audits", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(audits); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleBlockCustomer blocks the customer, refusing the widget and revoking the existing sessions.
+func (h *BlocklistHandler) handleBlockCustomer(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	h.setCustomerBlock(w, r, member, true)
+}
+
+// handleUnblockCustomer reverses the customer block and restores the widget sessions.
+func (h *BlocklistHandler) handleUnblockCustomer(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	h.setCustomerBlock(w, r, member, false)
+}
+
+func (h *BlocklistHandler) setCustomerBlock(
+	w http.ResponseWriter, r *http.Request, member *models.Member, block bool) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp BlockReasonReq
+	if err := json.NewDecoder(r.Body).Decode(&reqp); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	customerId := r.PathValue("customerId")
+	customer, err := h.ws.GetCustomer(ctx, member.WorkspaceId, customerId, nil)
+	if errors.Is(err, services.ErrCustomerNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if block {
+		customer, err = h.bls.BlockCustomer(ctx, customer, member.AsMemberActor(), reqp.Reason)
+	} else {
+		customer, err = h.bls.UnblockCustomer(ctx, customer, member.AsMemberActor(), reqp.Reason)
+	}
+	if errors.Is(err, services.ErrCustomerNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to update customer block", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := CustomerResp{
+		CustomerId:        customer.CustomerId,
+		ExternalId:        customer.ExternalId,
+		Email:             customer.Email,
+		Phone:             customer.Phone,
+		Name:              customer.Name,
+		AvatarUrl:         customer.AvatarUrl(),
+		IsEmailVerified:   customer.IsEmailVerified,
+		Role:              customer.Role,
+		IsBlocked:         customer.IsBlocked,
+		IsEmailBounced:    customer.IsEmailBounced,
+		EmailBouncedAt:    customer.EmailBouncedAt,
+		EmailBounceReason: customer.EmailBounceReason,
+		CreatedAt:         customer.CreatedAt,
+		UpdatedAt:         customer.UpdatedAt,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
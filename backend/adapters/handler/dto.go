@@ -3,9 +3,13 @@ package handler
 import (
 	"database/sql"
 	"encoding/json"
+	"log/slog"
+	"net/url"
 	"time"
 
+	"github.com/zyghq/zyg"
 	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/utils"
 )
 
 type PATReq struct {
@@ -40,20 +44,24 @@ func (w WorkspaceResp) MarshalJSON() ([]byte, error) {
 }
 
 type CustomerResp struct {
-	CustomerId      string
-	ExternalId      sql.NullString
-	Email           sql.NullString
-	Phone           sql.NullString
-	Name            string
-	AvatarUrl       string
-	IsEmailVerified bool
-	Role            string
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
+	CustomerId        string
+	ExternalId        sql.NullString
+	Email             sql.NullString
+	Phone             sql.NullString
+	Name              string
+	AvatarUrl         string
+	IsEmailVerified   bool
+	Role              string
+	IsBlocked         bool
+	IsEmailBounced    bool
+	EmailBouncedAt    sql.NullTime
+	EmailBounceReason string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
 }
 
 func (c CustomerResp) MarshalJSON() ([]byte, error) {
-	var externalId, email, phone *string
+	var externalId, email, phone, emailBouncedAt *string
 	if c.ExternalId.Valid {
 		externalId = &c.ExternalId.String
 	}
@@ -63,27 +71,39 @@ func (c CustomerResp) MarshalJSON() ([]byte, error) {
 	if c.Phone.Valid {
 		phone = &c.Phone.String
 	}
+	if c.EmailBouncedAt.Valid {
+		bouncedAt := c.EmailBouncedAt.Time.Format(time.RFC3339)
+		emailBouncedAt = &bouncedAt
+	}
 
 	aux := &struct {
-		CustomerId      string  `json:"customerId"`
-		ExternalId      *string `json:"externalId"`
-		Email           *string `json:"email"`
-		Phone           *string `json:"phone"`
-		Name            string  `json:"name"`
-		IsEmailVerified bool    `json:"isEmailVerified"`
-		Role            string  `json:"role"`
-		CreatedAt       string  `json:"createdAt"`
-		UpdatedAt       string  `json:"updatedAt"`
+		CustomerId        string  `json:"customerId"`
+		ExternalId        *string `json:"externalId"`
+		Email             *string `json:"email"`
+		Phone             *string `json:"phone"`
+		Name              string  `json:"name"`
+		IsEmailVerified   bool    `json:"isEmailVerified"`
+		Role              string  `json:"role"`
+		IsBlocked         bool    `json:"isBlocked"`
+		IsEmailBounced    bool    `json:"isEmailBounced"`
+		EmailBouncedAt    *string `json:"emailBouncedAt"`
+		EmailBounceReason string  `json:"emailBounceReason"`
+		CreatedAt         string  `json:"createdAt"`
+		UpdatedAt         string  `json:"updatedAt"`
 	}{
-		CustomerId:      c.CustomerId,
-		ExternalId:      externalId,
-		Email:           email,
-		Phone:           phone,
-		Name:            c.Name,
-		IsEmailVerified: c.IsEmailVerified,
-		Role:            c.Role,
-		CreatedAt:       c.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:       c.UpdatedAt.Format(time.RFC3339),
+		CustomerId:        c.CustomerId,
+		ExternalId:        externalId,
+		Email:             email,
+		Phone:             phone,
+		Name:              c.Name,
+		IsEmailVerified:   c.IsEmailVerified,
+		Role:              c.Role,
+		IsBlocked:         c.IsBlocked,
+		IsEmailBounced:    c.IsEmailBounced,
+		EmailBouncedAt:    emailBouncedAt,
+		EmailBounceReason: c.EmailBounceReason,
+		CreatedAt:         c.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:         c.UpdatedAt.Format(time.RFC3339),
 	}
 	return json.Marshal(aux)
 }
@@ -119,7 +139,8 @@ type NewLabelReq struct {
 }
 
 type ThChatReq struct {
-	Message string `json:"message"`
+	Message       string   `json:"message"`
+	AttachmentIds []string `json:"attachmentIds"`
 }
 
 type ThChatLabelReq struct {
@@ -377,13 +398,54 @@ type MessageResp struct {
 	TextBody     string
 	MarkdownBody string
 	HTMLBody     string
-	Customer     *CustomerActorResp
-	Member       *MemberActorResp
-	Channel      string
-	CreatedAt    time.Time
+	// Inbound mail text as received, members expand the quoted history.
+	OriginalTextBody string
+	Customer         *CustomerActorResp
+	Member           *MemberActorResp
+	Channel          string
+	Kind             string
+	IsAutomated      bool
+	Delivery         *MessageDeliveryResp
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// MessageDeliveryResp is the outbound message delivery status as reported by the channel provider.
+type MessageDeliveryResp struct {
+	Status       string
+	HasError     bool
+	ErrorMessage string
 	UpdatedAt    time.Time
 }
 
+func (d MessageDeliveryResp) MarshalJSON() ([]byte, error) {
+	aux := &struct {
+		Status       string `json:"status"`
+		HasError     bool   `json:"hasError"`
+		ErrorMessage string `json:"errorMessage"`
+		UpdatedAt    string `json:"updatedAt"`
+	}{
+		Status:       d.Status,
+		HasError:     d.HasError,
+		ErrorMessage: d.ErrorMessage,
+		UpdatedAt:    d.UpdatedAt.Format(time.RFC3339),
+	}
+	return json.Marshal(aux)
+}
+
+// NewResponse returns the delivery status response, nil if the message delivery is not tracked.
+func (d MessageDeliveryResp) NewResponse(delivery *models.MessageDelivery) *MessageDeliveryResp {
+	if delivery == nil {
+		return nil
+	}
+	return &MessageDeliveryResp{
+		Status:       delivery.Status,
+		HasError:     delivery.HasError,
+		ErrorMessage: delivery.ErrorMessage,
+		UpdatedAt:    delivery.UpdatedAt,
+	}
+}
+
 func (m MessageResp) MarshalJSON() ([]byte, error) {
 	var customer *CustomerActorResp
 	var member *MemberActorResp
@@ -396,36 +458,119 @@ func (m MessageResp) MarshalJSON() ([]byte, error) {
 	}
 
 	aux := &struct {
-		ThreadId     string             `json:"threadId"`
-		MessageId    string             `json:"messageId"`
-		TextBody     string             `json:"textBody"`
-		MarkdownBody string             `json:"markdownBody"`
-		HTMLBody     string             `json:"htmlBody"`
-		Customer     *CustomerActorResp `json:"customer,omitempty"`
-		Member       *MemberActorResp   `json:"member,omitempty"`
-		Channel      string             `json:"channel"`
-		CreatedAt    string             `json:"createdAt"`
-		UpdatedAt    string             `json:"updatedAt"`
+		ThreadId         string               `json:"threadId"`
+		MessageId        string               `json:"messageId"`
+		TextBody         string               `json:"textBody"`
+		MarkdownBody     string               `json:"markdownBody"`
+		HTMLBody         string               `json:"htmlBody"`
+		OriginalTextBody string               `json:"originalTextBody"`
+		Customer         *CustomerActorResp   `json:"customer,omitempty"`
+		Member           *MemberActorResp     `json:"member,omitempty"`
+		Channel          string               `json:"channel"`
+		Kind             string               `json:"kind"`
+		IsAutomated      bool                 `json:"isAutomated"`
+		Delivery         *MessageDeliveryResp `json:"delivery,omitempty"`
+		CreatedAt        string               `json:"createdAt"`
+		UpdatedAt        string               `json:"updatedAt"`
 	}{
-		ThreadId:     m.ThreadId,
-		MessageId:    m.MessageId,
-		TextBody:     m.TextBody,
-		MarkdownBody: m.MarkdownBody,
-		HTMLBody:     m.HTMLBody,
-		Customer:     customer,
-		Member:       member,
-		Channel:      m.Channel,
-		CreatedAt:    m.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:    m.UpdatedAt.Format(time.RFC3339),
+		ThreadId:         m.ThreadId,
+		MessageId:        m.MessageId,
+		TextBody:         m.TextBody,
+		MarkdownBody:     m.MarkdownBody,
+		HTMLBody:         m.HTMLBody,
+		OriginalTextBody: m.OriginalTextBody,
+		Customer:         customer,
+		Member:           member,
+		Channel:          m.Channel,
+		Kind:             m.Kind,
+		IsAutomated:      m.IsAutomated,
+		Delivery:         m.Delivery,
+		CreatedAt:        m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:        m.UpdatedAt.Format(time.RFC3339),
 	}
 	return json.Marshal(aux)
 }
 
+func (m MessageResp) NewResponse(message *models.Message) MessageResp {
+	var customer *CustomerActorResp
+	var member *MemberActorResp
+	if message.Customer != nil {
+		customer = &CustomerActorResp{
+			CustomerId: message.Customer.CustomerId,
+			Name:       message.Customer.Name,
+		}
+	} else if message.Member != nil {
+		member = &MemberActorResp{
+			MemberId: message.Member.MemberId,
+			Name:     message.Member.Name,
+		}
+	}
+	return MessageResp{
+		ThreadId:         message.ThreadId,
+		MessageId:        message.MessageId,
+		TextBody:         message.TextBody,
+		MarkdownBody:     message.MarkdownBody,
+		HTMLBody:         sanitizedHTMLBody(message.HTMLBody, nil),
+		OriginalTextBody: message.OriginalTextBody,
+		Customer:         customer,
+		Member:           member,
+		Channel:          message.Channel,
+		Kind:             message.Kind,
+		IsAutomated:      message.IsAutomated,
+		Delivery:         MessageDeliveryResp{}.NewResponse(message.Delivery),
+		CreatedAt:        message.CreatedAt,
+		UpdatedAt:        message.UpdatedAt,
+	}
+}
+
 type MessageWithAttachmentsResp struct {
 	MessageResp
 	Attachments []models.MessageAttachment `json:"attachments"`
 }
 
+func (m MessageWithAttachmentsResp) NewResponse(message *models.MessageWithAttachments) MessageWithAttachmentsResp {
+	resp := MessageResp{}.NewResponse(&message.Message)
+	resp.HTMLBody = sanitizedHTMLBody(message.HTMLBody, message.Attachments)
+	return MessageWithAttachmentsResp{
+		MessageResp: resp,
+		Attachments: message.Attachments,
+	}
+}
+
+// sanitizedHTMLBody returns the message HTML as served to the Member, with only the allowlisted tags
+// and attributes. Inline images are resolved to the message attachment URLs, remote images are loaded
+// through the image proxy if configured.
+func sanitizedHTMLBody(htmlBody string, attachments []models.MessageAttachment) string {
+	if htmlBody == "" {
+		return ""
+	}
+	opts := utils.HTMLSanitizeOptions{
+		InlineImageURL: func(contentId string) (string, bool) {
+			for _, a := range attachments {
+				if a.ContentId != "" && a.ContentId == contentId && !a.HasError {
+					return a.ContentUrl, true
+				}
+			}
+			return "", false
+		},
+	}
+	if proxyUrl, err := url.Parse(zyg.ImageProxyUrl()); err == nil && proxyUrl.Host != "" {
+		opts.ImageProxyURL = func(src string) string {
+			u := *proxyUrl
+			q := u.Query()
+			q.Set("url", src)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	sanitized, err := utils.SanitizeHTML(htmlBody, opts)
+	if err != nil {
+		slog.Error("failed to sanitize message html", slog.Any("err", err))
+		return ""
+	}
+	return sanitized
+}
+
 func (m MessageWithAttachmentsResp) MarshalJSON() ([]byte, error) {
 	var customer *CustomerActorResp
 	var member *MemberActorResp
@@ -448,6 +593,7 @@ func (m MessageWithAttachmentsResp) MarshalJSON() ([]byte, error) {
 		HasError     bool   `json:"hasError"`
 		Error        string `json:"error"`
 		MD5Hash      string `json:"md5Hash"`
+		ContentId    string `json:"contentId"`
 		CreatedAt    string `json:"createdAt"`
 		UpdatedAt    string `json:"updatedAt"`
 	}, len(m.Attachments))
@@ -463,6 +609,7 @@ func (m MessageWithAttachmentsResp) MarshalJSON() ([]byte, error) {
 			HasError     bool   `json:"hasError"`
 			Error        string `json:"error"`
 			MD5Hash      string `json:"md5Hash"`
+			ContentId    string `json:"contentId"`
 			CreatedAt    string `json:"createdAt"`
 			UpdatedAt    string `json:"updatedAt"`
 		}{
@@ -475,36 +622,45 @@ func (m MessageWithAttachmentsResp) MarshalJSON() ([]byte, error) {
 			HasError:     att.HasError,
 			Error:        att.Error,
 			MD5Hash:      att.MD5Hash,
+			ContentId:    att.ContentId,
 			CreatedAt:    att.CreatedAt.Format(time.RFC3339),
 			UpdatedAt:    att.UpdatedAt.Format(time.RFC3339),
 		}
 	}
 
 	aux := &struct {
-		ThreadId            string             `json:"threadId"`
-		MessageId           string             `json:"messageId"`
-		TextBody            string             `json:"textBody"`
-		MarkdownBody        string             `json:"markdownBody"`
-		HTMLBody            string             `json:"htmlBody"`
-		Customer            *CustomerActorResp `json:"customer,omitempty"`
-		Member              *MemberActorResp   `json:"member,omitempty"`
-		Channel             string             `json:"channel"`
-		CreatedAt           string             `json:"createdAt"`
-		UpdatedAt           string             `json:"updatedAt"`
-		Attachments         interface{}        `json:"attachments"`
-		AttachmentsHasError bool               `json:"attachmentsHasError"`
+		ThreadId            string               `json:"threadId"`
+		MessageId           string               `json:"messageId"`
+		TextBody            string               `json:"textBody"`
+		MarkdownBody        string               `json:"markdownBody"`
+		HTMLBody            string               `json:"htmlBody"`
+		OriginalTextBody    string               `json:"originalTextBody"`
+		Customer            *CustomerActorResp   `json:"customer,omitempty"`
+		Member              *MemberActorResp     `json:"member,omitempty"`
+		Channel             string               `json:"channel"`
+		Kind                string               `json:"kind"`
+		IsAutomated         bool                 `json:"isAutomated"`
+		Delivery            *MessageDeliveryResp `json:"delivery,omitempty"`
+		CreatedAt           string               `json:"createdAt"`
+		UpdatedAt           string               `json:"updatedAt"`
+		Attachments         interface{}          `json:"attachments"`
+		AttachmentsHasError bool                 `json:"attachmentsHasError"`
 	}{
-		ThreadId:     m.ThreadId,
-		MessageId:    m.MessageId,
-		TextBody:     m.TextBody,
-		MarkdownBody: m.MarkdownBody,
-		HTMLBody:     m.HTMLBody,
-		Customer:     customer,
-		Member:       member,
-		Channel:      m.Channel,
-		CreatedAt:    m.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:    m.UpdatedAt.Format(time.RFC3339),
-		Attachments:  formattedAttachments,
+		ThreadId:         m.ThreadId,
+		MessageId:        m.MessageId,
+		TextBody:         m.TextBody,
+		MarkdownBody:     m.MarkdownBody,
+		HTMLBody:         m.HTMLBody,
+		OriginalTextBody: m.OriginalTextBody,
+		Customer:         customer,
+		Member:           member,
+		Channel:          m.Channel,
+		Kind:             m.Kind,
+		IsAutomated:      m.IsAutomated,
+		Delivery:         m.Delivery,
+		CreatedAt:        m.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:        m.UpdatedAt.Format(time.RFC3339),
+		Attachments:      formattedAttachments,
 	}
 	return json.Marshal(aux)
 }
@@ -647,7 +803,380 @@ type AddPostmarkMailServerDNS struct {
 }
 
 // ReplyThreadMailReq represents the reply thread mail request body
+// AddCc and RemoveCc change the Thread's CC participants with the reply, Bcc is only for the reply.
 type ReplyThreadMailReq struct {
+	HTMLBody      string   `json:"htmlBody"`
+	TextBody      string   `json:"textBody"`
+	AddCc         []string `json:"addCc"`
+	RemoveCc      []string `json:"removeCc"`
+	Bcc           []string `json:"bcc"`
+	AttachmentIds []string `json:"attachmentIds"`
+}
+
+// ThreadParticipantResp is the email Thread participant other than the Thread's Customer.
+type ThreadParticipantResp struct {
+	Email      string
+	Name       string
+	Role       string
+	CustomerId *string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (p ThreadParticipantResp) MarshalJSON() ([]byte, error) {
+	aux := &struct {
+		Email      string  `json:"email"`
+		Name       string  `json:"name"`
+		Role       string  `json:"role"`
+		CustomerId *string `json:"customerId,omitempty"`
+		CreatedAt  string  `json:"createdAt"`
+		UpdatedAt  string  `json:"updatedAt"`
+	}{
+		Email:      p.Email,
+		Name:       p.Name,
+		Role:       p.Role,
+		CustomerId: p.CustomerId,
+		CreatedAt:  p.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:  p.UpdatedAt.Format(time.RFC3339),
+	}
+	return json.Marshal(aux)
+}
+
+func (p ThreadParticipantResp) NewResponse(participant models.ThreadParticipant) ThreadParticipantResp {
+	return ThreadParticipantResp{
+		Email:      participant.Email,
+		Name:       participant.Name,
+		Role:       participant.Role,
+		CustomerId: participant.CustomerId,
+		CreatedAt:  participant.CreatedAt,
+		UpdatedAt:  participant.UpdatedAt,
+	}
+}
+
+// ForwardThreadReq represents the forward thread request body.
+// Without the message IDs the whole transcript is forwarded, replies are tracked unless disabled.
+type ForwardThreadReq struct {
+	To           string   `json:"to"`
+	MessageIds   []string `json:"messageIds"`
+	Note         string   `json:"note"`
+	TrackReplies *bool    `json:"trackReplies"`
+}
+
+type ThreadForwardResp struct {
+	ForwardId     string
+	ThreadId      string
+	MessageId     string
+	MemberId      string
+	ToEmail       string
+	ToName        string
+	MessageIds    []string
+	IsTranscript  bool
+	Note          string
+	TrackReplies  bool
+	ReplyCount    int
+	LastRepliedAt *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (f ThreadForwardResp) MarshalJSON() ([]byte, error) {
+	var lastRepliedAt *string
+	if f.LastRepliedAt != nil {
+		t := f.LastRepliedAt.Format(time.RFC3339)
+		lastRepliedAt = &t
+	}
+	aux := &struct {
+		ForwardId     string   `json:"forwardId"`
+		ThreadId      string   `json:"threadId"`
+		MessageId     string   `json:"messageId"`
+		MemberId      string   `json:"memberId"`
+		ToEmail       string   `json:"toEmail"`
+		ToName        string   `json:"toName"`
+		MessageIds    []string `json:"messageIds"`
+		IsTranscript  bool     `json:"isTranscript"`
+		Note          string   `json:"note"`
+		TrackReplies  bool     `json:"trackReplies"`
+		ReplyCount    int      `json:"replyCount"`
+		LastRepliedAt *string  `json:"lastRepliedAt"`
+		CreatedAt     string   `json:"createdAt"`
+		UpdatedAt     string   `json:"updatedAt"`
+	}{
+		ForwardId:     f.ForwardId,
+		ThreadId:      f.ThreadId,
+		MessageId:     f.MessageId,
+		MemberId:      f.MemberId,
+		ToEmail:       f.ToEmail,
+		ToName:        f.ToName,
+		MessageIds:    f.MessageIds,
+		IsTranscript:  f.IsTranscript,
+		Note:          f.Note,
+		TrackReplies:  f.TrackReplies,
+		ReplyCount:    f.ReplyCount,
+		LastRepliedAt: lastRepliedAt,
+		CreatedAt:     f.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     f.UpdatedAt.Format(time.RFC3339),
+	}
+	return json.Marshal(aux)
+}
+
+func (f ThreadForwardResp) NewResponse(forward models.ThreadForward) ThreadForwardResp {
+	messageIds := forward.MessageIds
+	if messageIds == nil {
+		messageIds = []string{}
+	}
+	return ThreadForwardResp{
+		ForwardId:     forward.ForwardId,
+		ThreadId:      forward.ThreadId,
+		MessageId:     forward.MessageId,
+		MemberId:      forward.MemberId,
+		ToEmail:       forward.ToEmail,
+		ToName:        forward.ToName,
+		MessageIds:    messageIds,
+		IsTranscript:  forward.IsTranscript,
+		Note:          forward.Note,
+		TrackReplies:  forward.TrackReplies,
+		ReplyCount:    forward.ReplyCount,
+		LastRepliedAt: forward.LastRepliedAt,
+		CreatedAt:     forward.CreatedAt,
+		UpdatedAt:     forward.UpdatedAt,
+	}
+}
+
+// ForwardThreadResp is the forward with the Thread timeline entry of the forward.
+type ForwardThreadResp struct {
+	Forward ThreadForwardResp `json:"forward"`
+	Message MessageResp       `json:"message"`
+}
+
+// ThreadFollowUpSettingReq represents the workspace follow-up setting request body.
+// Empty follow-up template uses the default template.
+type ThreadFollowUpSettingReq struct {
+	IsEnabled         bool   `json:"isEnabled"`
+	FollowUpAfterDays int    `json:"followUpAfterDays"`
+	SendFollowUp      bool   `json:"sendFollowUp"`
+	FollowUpTemplate  string `json:"followUpTemplate"`
+	ResolveAfterDays  int    `json:"resolveAfterDays"`
+}
+
+// SpamSettingReq represents the workspace spam setting request body.
+type SpamSettingReq struct {
+	IsEnabled          bool    `json:"isEnabled"`
+	Threshold          float64 `json:"threshold"`
+	ChatRateLimit      int     `json:"chatRateLimit"`
+	ChatRateWindowSecs int     `json:"chatRateWindowSecs"`
+}
+
+// SpamSenderRuleReq represents the spam sender allow rule request body.
+type SpamSenderRuleReq struct {
+	Kind   string `json:"kind"`
+	Value  string `json:"value"`
+	Action string `json:"action"`
+}
+
+// SenderBlockReq represents the sender blocklist entry request body.
+type SenderBlockReq struct {
+	Kind   string  `json:"kind"`
+	Value  string  `json:"value"`
+	Action string  `json:"action"`
+	Reason *string `json:"reason"`
+}
+
+// SMSSettingReq represents the workspace SMS setting request body.
+// Empty auth token keeps the existing auth token.
+type SMSSettingReq struct {
+	IsEnabled               bool   `json:"isEnabled"`
+	Provider                string `json:"provider"`
+	PhoneNumber             string `json:"phoneNumber"`
+	AccountSid              string `json:"accountSid"`
+	AuthToken               string `json:"authToken"`
+	ConversationWindowHours int    `json:"conversationWindowHours"`
+}
+
+// MailSettingReq represents the workspace mail sender request body.
+// Empty API key or SMTP password keeps the saved secret.
+type MailSettingReq struct {
+	IsEnabled    bool   `json:"isEnabled"`
+	Sender       string `json:"sender"`
+	FromName     string `json:"fromName"`
+	FromEmail    string `json:"fromEmail"`
+	ReplyTo      string `json:"replyTo"`
+	APIKey       string `json:"apiKey"`
+	SMTPHost     string `json:"smtpHost"`
+	SMTPPort     int    `json:"smtpPort"`
+	SMTPUsername string `json:"smtpUsername"`
+	SMTPPassword string `json:"smtpPassword"`
+	SMTPSecurity string `json:"smtpSecurity"`
+	SMTPAuth     string `json:"smtpAuth"`
+	// ReopenOnBounce reopens the thread when the reply hard bounces.
+	ReopenOnBounce bool `json:"reopenOnBounce"`
+}
+
+// MailReplySettingReq represents the workspace reply wrapper request body.
+type MailReplySettingReq struct {
+	IsBranded     bool   `json:"isBranded"`
+	LogoUrl       string `json:"logoUrl"`
+	Header        string `json:"header"`
+	Footer        string `json:"footer"`
+	IncludeQuote  bool   `json:"includeQuote"`
+	SignatureHTML string `json:"signatureHtml"`
+	SignatureText string `json:"signatureText"`
+}
+
+// CustomerMailSettingReq represents the workspace CSAT, transcript and notification mail request body.
+type CustomerMailSettingReq struct {
+	SendCSAT         bool   `json:"sendCsat"`
+	CSATUrl          string `json:"csatUrl"`
+	SendTranscript   bool   `json:"sendTranscript"`
+	SendNotification bool   `json:"sendNotification"`
+	ConversationUrl  string `json:"conversationUrl"`
+}
+
+// MailSignatureReq represents the member's mail signature request body.
+type MailSignatureReq struct {
+	HTMLBody string `json:"htmlBody"`
+	TextBody string `json:"textBody"`
+}
+
+// MailboxReq represents the workspace mailbox request body.
+type MailboxReq struct {
+	Name              string  `json:"name"`
+	Email             string  `json:"email"`
+	IsDefault         bool    `json:"isDefault"`
+	SignatureHTML     string  `json:"signatureHtml"`
+	SignatureText     string  `json:"signatureText"`
+	DefaultLabelId    *string `json:"defaultLabelId"`
+	DefaultAssigneeId *string `json:"defaultAssigneeId"`
+}
+
+// MailTemplateReq represents the workspace transactional mail template request body.
+// Subject, HTML and text are Go templates, either of the HTML or text is required.
+type MailTemplateReq struct {
+	Subject  string `json:"subject"`
+	HTMLBody string `json:"htmlBody"`
+	TextBody string `json:"textBody"`
+}
+
+// MailPreviewResp is the rendered mail as sent to the customer.
+type MailPreviewResp struct {
+	FromName string `json:"fromName"`
+	To       string `json:"to"`
+	Subject  string `json:"subject"`
 	HTMLBody string `json:"htmlBody"`
 	TextBody string `json:"textBody"`
 }
+
+// IMAPSettingReq represents the workspace IMAP mailbox request body.
+// Empty password keeps the saved password.
+type IMAPSettingReq struct {
+	IsEnabled bool   `json:"isEnabled"`
+	Host      string `json:"host"`
+	Port      int    `json:"port"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	Security  string `json:"security"`
+	Mailbox   string `json:"mailbox"`
+}
+
+// MailInboundResp represents the Thread and the message the inbound mail is processed into.
+type MailInboundResp struct {
+	ThreadId  string `json:"threadId"`
+	MessageId string `json:"messageId"`
+}
+
+// ReplyThreadSMSReq represents the reply thread SMS request body.
+type ReplyThreadSMSReq struct {
+	TextBody string `json:"textBody"`
+}
+
+// WhatsAppSettingReq represents the workspace WhatsApp setting request body.
+// Empty access token or app secret keeps the existing one.
+type WhatsAppSettingReq struct {
+	IsEnabled          bool   `json:"isEnabled"`
+	PhoneNumberId      string `json:"phoneNumberId"`
+	BusinessAccountId  string `json:"businessAccountId"`
+	DisplayPhoneNumber string `json:"displayPhoneNumber"`
+	AccessToken        string `json:"accessToken"`
+	AppSecret          string `json:"appSecret"`
+	VerifyToken        string `json:"verifyToken"`
+	APIBaseURL         string `json:"apiBaseUrl"`
+}
+
+// ReplyThreadWhatsAppReq represents the reply thread WhatsApp request body.
+// Template is required outside the session window.
+type ReplyThreadWhatsAppReq struct {
+	TextBody string                   `json:"textBody"`
+	Template *models.WhatsAppTemplate `json:"template"`
+}
+
+// BlockReasonReq represents the optional reason for the block or unblock.
+type BlockReasonReq struct {
+	Reason *string `json:"reason"`
+}
+
+// SlackInstallResp represents the Slack app install authorize URL.
+type SlackInstallResp struct {
+	URL string `json:"url"`
+}
+
+// SlackChannelReq represents the Slack channel mapping request body.
+type SlackChannelReq struct {
+	IsEnabled bool    `json:"isEnabled"`
+	LabelId   *string `json:"labelId"`
+}
+
+// ReplyThreadSlackReq represents the reply thread Slack request body.
+type ReplyThreadSlackReq struct {
+	TextBody string `json:"textBody"`
+}
+
+// APISettingReq represents the workspace API channel setting request body.
+// Empty webhook secret keeps the existing one.
+type APISettingReq struct {
+	IsEnabled     bool   `json:"isEnabled"`
+	WebhookUrl    string `json:"webhookUrl"`
+	WebhookSecret string `json:"webhookSecret"`
+}
+
+// CreateAPIThreadReq represents the create API thread request body.
+// Customer is identified by the external ID, email or phone in that order.
+type CreateAPIThreadReq struct {
+	Customer    CreateCustomerReq      `json:"customer"`
+	Title       string                 `json:"title"`
+	TextBody    string                 `json:"textBody"`
+	Attachments []models.APIAttachment `json:"attachments"`
+}
+
+// APIThreadMessageReq represents the Customer's message request body of the API thread.
+type APIThreadMessageReq struct {
+	TextBody    string                 `json:"textBody"`
+	Attachments []models.APIAttachment `json:"attachments"`
+}
+
+// ReplyThreadAPIReq represents the reply thread API request body.
+type ReplyThreadAPIReq struct {
+	TextBody string `json:"textBody"`
+}
+
+// APIThreadResp represents the API thread with the Customer's first message.
+type APIThreadResp struct {
+	Thread  ThreadResp                 `json:"thread"`
+	Message MessageWithAttachmentsResp `json:"message"`
+}
+
+// APIReplyResp represents the Member's reply with the webhook delivery.
+type APIReplyResp struct {
+	Message  MessageResp          `json:"message"`
+	Delivery models.APIMessageLog `json:"delivery"`
+}
+
+// WebhookCredentialResp is the rotated workspace webhook credential with the secret, shown only once,
+// and the webhook URLs authenticated with the credential. Credential of the mailbox is only of the raw mail URL.
+type WebhookCredentialResp struct {
+	Credential             models.WebhookCredential `json:"credential"`
+	Secret                 string                   `json:"secret"`
+	PostmarkInboundHookUrl string                   `json:"postmarkInboundHookUrl"`
+	PostmarkStatusHookUrl  string                   `json:"postmarkStatusHookUrl"`
+	RawMailInboundHookUrl  string                   `json:"rawMailInboundHookUrl"`
+	SMSInboundHookUrl      string                   `json:"smsInboundHookUrl"`
+	SMSStatusHookUrl       string                   `json:"smsStatusHookUrl"`
+}
@@ -27,17 +27,41 @@ func NewServer(
 	workspaceService ports.WorkspaceServicer,
 	customerService ports.CustomerServicer,
 	threadService ports.ThreadServicer,
+	channelService ports.ChannelServicer,
+	spamService ports.SpamServicer,
+	blocklistService ports.BlocklistServicer,
+	smsService ports.SMSServicer,
+	whatsAppService ports.WhatsAppServicer,
+	slackService ports.SlackServicer,
+	apiService ports.APIServicer,
+	mailService ports.MailServicer,
+	mailInboundService ports.MailInboundServicer,
+	threadForwardService ports.ThreadForwardServicer,
+	customerMailService ports.CustomerMailServicer,
 ) http.Handler {
 	mux := http.NewServeMux()
 
 	// initialize service handlers
 	ah := NewAccountHandler(accountService, workspaceService)
 	wh := NewWorkspaceHandler(workspaceService, accountService, customerService)
-	th := NewThreadHandler(workspaceService, threadService)
+	th := NewThreadHandler(
+		workspaceService, threadService, channelService, mailInboundService, spamService, threadForwardService,
+		customerMailService)
 	ch := NewCustomerHandler(workspaceService, customerService)
-
-	webhookUsername := zyg.WebhookUsername()
-	webhookPassword := zyg.WebhookPassword()
+	sh := NewSpamHandler(spamService)
+	bh := NewBlocklistHandler(workspaceService, blocklistService)
+	smh := NewSMSHandler(workspaceService, threadService, spamService, smsService)
+	wah := NewWhatsAppHandler(workspaceService, threadService, spamService, whatsAppService)
+	slh := NewSlackHandler(workspaceService, threadService, slackService)
+	aph := NewAPIHandler(workspaceService, threadService, apiService)
+	mh := NewMailHandler(workspaceService, threadService, mailService, mailInboundService)
+
+	// Global webhook credentials of the hooks configured before the workspace webhook credentials,
+	// accepted on the workspace webhooks until the cutover, see WorkspaceAuthWebhook.
+	var legacyUsername, legacyPassword string
+	if time.Now().Before(zyg.WebhookLegacyAuthUntil()) {
+		legacyUsername, legacyPassword = zyg.WebhookUsername(), zyg.WebhookPassword()
+	}
 
 	mux.HandleFunc("GET /{$}", handleGetIndex)
 	mux.HandleFunc("POST /accounts/auth/{$}", ah.handleGetOrCreateAccount)
@@ -99,15 +123,44 @@ func NewServer(
 	mux.Handle("GET /workspaces/{workspaceId}/threads/parts/labels/{labelId}/{$}",
 		NewEnsureMemberAuth(th.handleGetLabelledThreads, authService))
 
+	mux.Handle("POST /workspaces/{workspaceId}/threads/{threadId}/forwards/{$}",
+		NewEnsureMemberAuth(th.handleForwardThread, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/threads/{threadId}/forwards/{$}",
+		NewEnsureMemberAuth(th.handleGetThreadForwards, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/threads/{threadId}/transcript/{$}",
+		NewEnsureMemberAuth(th.handleSendThreadTranscript, authService))
+
 	mux.Handle("POST /workspaces/{workspaceId}/threads/chat/{threadId}/messages/{$}",
 		NewEnsureMemberAuth(th.handleCreateThreadChatMessage, authService))
 
 	mux.Handle("POST /workspaces/{workspaceId}/threads/email/{threadId}/messages/{$}",
 		NewEnsureMemberAuth(th.handleReplyThreadMail, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/threads/email/{threadId}/messages/preview/{$}",
+		NewEnsureMemberAuth(mh.handlePreviewReplyMail, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/threads/email/{threadId}/participants/{$}",
+		NewEnsureMemberAuth(th.handleGetThreadParticipants, authService))
+
+	mux.Handle("POST /workspaces/{workspaceId}/threads/sms/{threadId}/messages/{$}",
+		NewEnsureMemberAuth(smh.handleReplyThreadSMS, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/threads/sms/{threadId}/deliveries/{$}",
+		NewEnsureMemberAuth(smh.handleGetThreadSMSLogs, authService))
+
+	mux.Handle("POST /workspaces/{workspaceId}/threads/whatsapp/{threadId}/messages/{$}",
+		NewEnsureMemberAuth(wah.handleReplyThreadWhatsApp, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/threads/whatsapp/{threadId}/deliveries/{$}",
+		NewEnsureMemberAuth(wah.handleGetThreadWhatsAppLogs, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/threads/slack/{threadId}/messages/{$}",
+		NewEnsureMemberAuth(slh.handleReplyThreadSlack, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/threads/api/{threadId}/messages/{$}",
+		NewEnsureMemberAuth(aph.handleReplyThreadAPI, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/threads/api/{threadId}/deliveries/{$}",
+		NewEnsureMemberAuth(aph.handleGetThreadAPILogs, authService))
 
 	mux.Handle("GET /workspaces/{workspaceId}/threads/{threadId}/messages/{$}",
 		NewEnsureMemberAuth(th.handleGetThreadMessages, authService))
 
+	mux.Handle("POST /workspaces/{workspaceId}/threads/{threadId}/attachments/{$}",
+		NewEnsureMemberAuth(th.handleUploadThreadAttachment, authService))
 	mux.Handle("GET /workspaces/{workspaceId}/messages/{messageId}/attachments/{attachmentId}/{$}",
 		NewEnsureMemberAuth(th.handleGetMessageAttachment, authService))
 
@@ -121,6 +174,127 @@ func NewServer(
 	mux.Handle("GET /workspaces/{workspaceId}/threads/metrics/{$}",
 		NewEnsureMemberAuth(th.handleGetThreadMetrics, authService))
 
+	mux.Handle("POST /workspaces/{workspaceId}/threads/{threadId}/not-spam/{$}",
+		NewEnsureMemberAuth(th.handleMarkThreadNotSpam, authService))
+
+	mux.Handle("GET /workspaces/{workspaceId}/spam/setting/{$}",
+		NewEnsureMemberAuth(sh.handleGetSpamSetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/spam/setting/{$}",
+		NewEnsureMemberAuth(sh.handleUpdateSpamSetting, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/spam/rules/{$}",
+		NewEnsureMemberAuth(sh.handleGetSpamSenderRules, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/spam/rules/{$}",
+		NewEnsureMemberAuth(sh.handleCreateSpamSenderRule, authService))
+	mux.Handle("DELETE /workspaces/{workspaceId}/spam/rules/{ruleId}/{$}",
+		NewEnsureMemberAuth(sh.handleDeleteSpamSenderRule, authService))
+
+	mux.Handle("GET /workspaces/{workspaceId}/blocklist/{$}",
+		NewEnsureMemberAuth(bh.handleGetSenderBlocks, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/blocklist/{$}",
+		NewEnsureMemberAuth(bh.handleCreateSenderBlock, authService))
+	mux.Handle("DELETE /workspaces/{workspaceId}/blocklist/{blockId}/{$}",
+		NewEnsureMemberAuth(bh.handleDeleteSenderBlock, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/blocklist/audits/{$}",
+		NewEnsureMemberAuth(bh.handleGetBlockAudits, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/customers/{customerId}/block/{$}",
+		NewEnsureMemberAuth(bh.handleBlockCustomer, authService))
+	mux.Handle("DELETE /workspaces/{workspaceId}/customers/{customerId}/block/{$}",
+		NewEnsureMemberAuth(bh.handleUnblockCustomer, authService))
+
+	mux.Handle("GET /workspaces/{workspaceId}/threads/follow-up/setting/{$}",
+		NewEnsureMemberAuth(wh.handleGetThreadFollowUpSetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/threads/follow-up/setting/{$}",
+		NewEnsureMemberAuth(wh.handleUpdateThreadFollowUpSetting, authService))
+
+	mux.Handle("GET /workspaces/{workspaceId}/mail/setting/{$}",
+		NewEnsureMemberAuth(mh.handleGetMailSetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/mail/setting/{$}",
+		NewEnsureMemberAuth(mh.handleUpdateMailSetting, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/reply/setting/{$}",
+		NewEnsureMemberAuth(mh.handleGetMailReplySetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/mail/reply/setting/{$}",
+		NewEnsureMemberAuth(mh.handleUpdateMailReplySetting, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/customer/setting/{$}",
+		NewEnsureMemberAuth(mh.handleGetCustomerMailSetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/mail/customer/setting/{$}",
+		NewEnsureMemberAuth(mh.handleUpdateCustomerMailSetting, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/members/me/mail/signature/{$}",
+		NewEnsureMemberAuth(mh.handleGetMailSignature, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/members/me/mail/signature/{$}",
+		NewEnsureMemberAuth(mh.handleUpdateMailSignature, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/mailboxes/{$}",
+		NewEnsureMemberAuth(mh.handleGetMailboxes, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/mail/mailboxes/{$}",
+		NewEnsureMemberAuth(mh.handleCreateMailbox, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/mailboxes/{mailboxId}/{$}",
+		NewEnsureMemberAuth(mh.handleGetMailbox, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/mail/mailboxes/{mailboxId}/{$}",
+		NewEnsureMemberAuth(mh.handleUpdateMailbox, authService))
+	mux.Handle("DELETE /workspaces/{workspaceId}/mail/mailboxes/{mailboxId}/{$}",
+		NewEnsureMemberAuth(mh.handleDeleteMailbox, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/mail/mailboxes/{mailboxId}/dns/add/{$}",
+		NewEnsureMemberAuth(mh.handleMailboxAddDNS, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/mail/mailboxes/{mailboxId}/dns/verify/{$}",
+		NewEnsureMemberAuth(mh.handleMailboxVerifyDNS, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/mail/mailboxes/{mailboxId}/webhooks/credentials/rotate/{$}",
+		NewEnsureMemberAuth(mh.handleRotateMailboxWebhookCredential, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/templates/{$}",
+		NewEnsureMemberAuth(mh.handleGetMailTemplates, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/templates/{kind}/{locale}/{$}",
+		NewEnsureMemberAuth(mh.handleGetMailTemplate, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/mail/templates/{kind}/{locale}/{$}",
+		NewEnsureMemberAuth(mh.handleUpdateMailTemplate, authService))
+	mux.Handle("DELETE /workspaces/{workspaceId}/mail/templates/{kind}/{locale}/{$}",
+		NewEnsureMemberAuth(mh.handleDeleteMailTemplate, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/mail/templates/{kind}/{locale}/preview/{$}",
+		NewEnsureMemberAuth(mh.handlePreviewMailTemplate, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/imap/setting/{$}",
+		NewEnsureMemberAuth(mh.handleGetIMAPSetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/mail/imap/setting/{$}",
+		NewEnsureMemberAuth(mh.handleUpdateIMAPSetting, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/inbound/postmark/requests/{$}",
+		NewEnsureMemberAuth(mh.handleGetPostmarkInboundRequests, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/mail/inbound/postmark/requests/{messageId}/replay/{$}",
+		NewEnsureMemberAuth(mh.handleReplayPostmarkInboundRequest, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/mail/inbound/raw/{$}",
+		NewEnsureMemberAuth(mh.handleUploadRawMail, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/mail/outbound/{$}",
+		NewEnsureMemberAuth(th.handleGetOutboundMails, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/mail/outbound/{messageId}/retry/{$}",
+		NewEnsureMemberAuth(th.handleRetryOutboundMail, authService))
+
+	mux.Handle("GET /workspaces/{workspaceId}/sms/setting/{$}",
+		NewEnsureMemberAuth(smh.handleGetSMSSetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/sms/setting/{$}",
+		NewEnsureMemberAuth(smh.handleUpdateSMSSetting, authService))
+
+	mux.Handle("GET /workspaces/{workspaceId}/whatsapp/setting/{$}",
+		NewEnsureMemberAuth(wah.handleGetWhatsAppSetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/whatsapp/setting/{$}",
+		NewEnsureMemberAuth(wah.handleUpdateWhatsAppSetting, authService))
+
+	mux.Handle("GET /workspaces/{workspaceId}/slack/{$}",
+		NewEnsureMemberAuth(slh.handleGetSlackWorkspace, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/slack/install/{$}",
+		NewEnsureMemberAuth(slh.handleGetSlackInstallURL, authService))
+	mux.Handle("GET /workspaces/{workspaceId}/slack/channels/{$}",
+		NewEnsureMemberAuth(slh.handleGetSlackChannels, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/slack/channels/sync/{$}",
+		NewEnsureMemberAuth(slh.handleSyncSlackChannels, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/slack/channels/{channelId}/{$}",
+		NewEnsureMemberAuth(slh.handleUpdateSlackChannel, authService))
+
+	mux.Handle("GET /workspaces/{workspaceId}/api/setting/{$}",
+		NewEnsureMemberAuth(aph.handleGetAPISetting, authService))
+	mux.Handle("PUT /workspaces/{workspaceId}/api/setting/{$}",
+		NewEnsureMemberAuth(aph.handleUpdateAPISetting, authService))
+	// handles API channel requests from the workspace's own backend, authenticated with the PAT.
+	// Threads are created on behalf of the Customer.
+	mux.Handle("POST /workspaces/{workspaceId}/api/threads/{$}",
+		NewEnsureAPIMemberAuth(aph.handleCreateAPIThread, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/api/threads/{threadId}/messages/{$}",
+		NewEnsureAPIMemberAuth(aph.handleCreateAPIThreadMessage, authService))
+
 	mux.Handle("POST /workspaces/{workspaceId}/widgets/{$}",
 		NewEnsureMemberAuth(wh.handleCreateWidget, authService))
 	mux.Handle("GET /workspaces/{workspaceId}/widgets/{$}",
@@ -141,11 +315,50 @@ func NewServer(
 	mux.Handle("PATCH /workspaces/{workspaceId}/postmark/servers/{$}",
 		NewEnsureMemberAuth(wh.handlePostmarkUpdateMailServer, authService))
 
+	// Workspace webhook credentials of the inbound mail webhooks.
+	mux.Handle("GET /workspaces/{workspaceId}/webhooks/credentials/{$}",
+		NewEnsureMemberAuth(wh.handleGetWebhookCredentials, authService))
+	mux.Handle("POST /workspaces/{workspaceId}/webhooks/credentials/rotate/{$}",
+		NewEnsureMemberAuth(wh.handleRotateWebhookCredential, authService))
+	mux.Handle("DELETE /workspaces/{workspaceId}/webhooks/credentials/{credentialId}/{$}",
+		NewEnsureMemberAuth(wh.handleRevokeWebhookCredential, authService))
+
 	// Webhooks
 	// handles postmark inbound message webhook for workspace.
 	// This URL path must also be configured in the postmark inbound settings.
+	// Postmark and SMS webhooks authenticate with the workspace webhook credential, see WorkspaceAuthWebhook.
+	// The inbound mail webhooks also accept the credential of the workspace mailbox the mail is addressed to.
 	mux.HandleFunc("POST /webhooks/{workspaceId}/postmark/inbound/{$}",
-		BasicAuthWebhook(th.handlePostmarkInboundWebhook, webhookUsername, webhookPassword))
+		WorkspaceAuthWebhook(th.handlePostmarkInboundWebhook, workspaceService, legacyUsername, legacyPassword))
+	// handles raw RFC 5322 inbound mail piped from the MTA for workspace.
+	mux.HandleFunc("POST /webhooks/{workspaceId}/mail/inbound/raw/{$}",
+		WorkspaceAuthWebhook(mh.handleRawMailInboundWebhook, workspaceService, legacyUsername, legacyPassword))
+
+	// handles postmark delivery, bounce, spam complaint, open and click webhooks for workspace outbound mail.
+	mux.HandleFunc("POST /webhooks/{workspaceId}/postmark/status/{$}",
+		WorkspaceAuthWebhook(WorkspaceOnlyWebhook(th.handlePostmarkStatusWebhook),
+			workspaceService, legacyUsername, legacyPassword))
+
+	// handles SMS provider inbound message and delivery status webhooks for workspace.
+	// The inbound URL path must be configured as the messaging webhook of the sender number.
+	mux.HandleFunc("POST /webhooks/{workspaceId}/sms/inbound/{$}",
+		WorkspaceAuthWebhook(WorkspaceOnlyWebhook(smh.handleSMSInboundWebhook),
+			workspaceService, legacyUsername, legacyPassword))
+	mux.HandleFunc("POST /webhooks/{workspaceId}/sms/status/{$}",
+		WorkspaceAuthWebhook(WorkspaceOnlyWebhook(smh.handleSMSStatusWebhook),
+			workspaceService, legacyUsername, legacyPassword))
+
+	// handles WhatsApp Cloud API webhook subscription verification and notifications for workspace.
+	// Notifications are verified with the payload signature of the app secret instead of basic auth.
+	mux.HandleFunc("GET /webhooks/{workspaceId}/whatsapp/{$}", wah.handleWhatsAppVerifyWebhook)
+	mux.HandleFunc("POST /webhooks/{workspaceId}/whatsapp/{$}", wah.handleWhatsAppWebhook)
+
+	// handles Slack app install OAuth redirect, the workspace is taken from the signed state.
+	// This URL path must be configured as the redirect URL of the Slack app.
+	mux.HandleFunc("GET /slack/oauth/callback/{$}", slh.handleSlackOAuthCallback)
+	// handles Slack Events API requests for all the installed Slack workspaces.
+	// Requests are verified with the signing secret of the Slack app instead of basic auth.
+	mux.HandleFunc("POST /webhooks/slack/events/{$}", slh.handleSlackEventsWebhook)
 
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
@@ -0,0 +1,1214 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/zyghq/zyg"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services"
+)
+
+type MailHandler struct {
+	ws  ports.WorkspaceServicer
+	ths ports.ThreadServicer
+	ms  ports.MailServicer
+	mis ports.MailInboundServicer
+}
+
+func NewMailHandler(
+	ws ports.WorkspaceServicer, ths ports.ThreadServicer, ms ports.MailServicer, mis ports.MailInboundServicer,
+) *MailHandler {
+	return &MailHandler{ws: ws, ths: ths, ms: ms, mis: mis}
+}
+
+func (h *MailHandler) handleGetMailSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.ms.GetMailSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mail setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateMailSetting saves the workspace outbound mail sender.
+func (h *MailHandler) handleUpdateMailSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp MailSettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.ms.GetMailSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mail setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.IsEnabled = reqp.IsEnabled
+	setting.Sender = reqp.Sender
+	setting.FromName = strings.TrimSpace(reqp.FromName)
+	setting.FromEmail = strings.TrimSpace(reqp.FromEmail)
+	setting.ReplyTo = strings.TrimSpace(reqp.ReplyTo)
+	if reqp.APIKey != "" {
+		setting.APIKey = reqp.APIKey
+	}
+	setting.SMTPHost = strings.TrimSpace(reqp.SMTPHost)
+	setting.SMTPPort = reqp.SMTPPort
+	setting.SMTPUsername = strings.TrimSpace(reqp.SMTPUsername)
+	if reqp.SMTPPassword != "" {
+		setting.SMTPPassword = reqp.SMTPPassword
+	}
+	setting.SMTPSecurity = reqp.SMTPSecurity
+	setting.SMTPAuth = reqp.SMTPAuth
+	setting.ReopenOnBounce = reqp.ReopenOnBounce
+	if err := setting.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setting, err = h.ms.SaveMailSetting(ctx, setting)
+	if errors.Is(err, services.ErrMailSender) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save mail setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *MailHandler) handleGetMailReplySetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.ms.GetMailReplySetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mail reply setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateMailReplySetting saves the workspace reply wrapper and the default signature.
+func (h *MailHandler) handleUpdateMailReplySetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp MailReplySettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.ms.GetMailReplySetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mail reply setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.IsBranded = reqp.IsBranded
+	setting.LogoUrl = strings.TrimSpace(reqp.LogoUrl)
+	setting.Header = strings.TrimSpace(reqp.Header)
+	setting.Footer = strings.TrimSpace(reqp.Footer)
+	setting.IncludeQuote = reqp.IncludeQuote
+	setting.SignatureHTML = strings.TrimSpace(reqp.SignatureHTML)
+	setting.SignatureText = strings.TrimSpace(reqp.SignatureText)
+	if err := setting.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setting, err = h.ms.SaveMailReplySetting(ctx, setting)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save mail reply setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *MailHandler) handleGetCustomerMailSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.ms.GetCustomerMailSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer mail setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateCustomerMailSetting saves which of the CSAT, transcript and notification mails are sent to customers.
+func (h *MailHandler) handleUpdateCustomerMailSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp CustomerMailSettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.ms.GetCustomerMailSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer mail setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.SendCSAT = reqp.SendCSAT
+	setting.CSATUrl = strings.TrimSpace(reqp.CSATUrl)
+	setting.SendTranscript = reqp.SendTranscript
+	setting.SendNotification = reqp.SendNotification
+	setting.ConversationUrl = strings.TrimSpace(reqp.ConversationUrl)
+	if err := setting.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setting, err = h.ms.SaveCustomerMailSetting(ctx, setting)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save customer mail setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *MailHandler) handleGetMailSignature(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	signature, err := h.ms.GetMailSignature(ctx, member.WorkspaceId, member.MemberId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mail signature", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(signature); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateMailSignature saves the member's own mail signature.
+// Empty signature falls back to the workspace default signature.
+func (h *MailHandler) handleUpdateMailSignature(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp MailSignatureReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	signature, err := h.ms.GetMailSignature(ctx, member.WorkspaceId, member.MemberId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mail signature", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	signature.HTMLBody = strings.TrimSpace(reqp.HTMLBody)
+	signature.TextBody = strings.TrimSpace(reqp.TextBody)
+	if err := signature.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	signature, err = h.ms.SaveMailSignature(ctx, signature)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save mail signature", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(signature); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handlePreviewReplyMail renders the member's reply to the email thread as the final mail, without sending it.
+func (h *MailHandler) handlePreviewReplyMail(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	threadId := r.PathValue("threadId")
+
+	var reqp ReplyThreadMailReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	workspace, err := h.ws.GetWorkspace(ctx, member.WorkspaceId)
+	if errors.Is(err, services.ErrWorkspaceNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	channel := models.ThreadChannel{}.Email()
+	thread, err := h.ths.GetWorkspaceThread(ctx, workspace.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	customer, err := h.ws.GetCustomer(ctx, workspace.WorkspaceId, thread.Customer.CustomerId, nil)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	quote, err := h.ths.GetLatestThreadMessage(ctx, thread.ThreadId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread latest message", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	reply := models.Message{
+		ThreadId: thread.ThreadId,
+		TextBody: reqp.TextBody,
+		HTMLBody: reqp.HTMLBody,
+	}
+	mail, err := h.ms.ComposeReplyMail(ctx, workspace, thread, *member, customer, reply, quote)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to compose reply mail", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := MailPreviewResp{
+		FromName: mail.FromName,
+		To:       mail.To,
+		Subject:  mail.Subject,
+		HTMLBody: mail.HTMLBody,
+		TextBody: mail.TextBody,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *MailHandler) handleGetMailboxes(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	mailboxes, err := h.ms.ListMailboxes(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mailboxes", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mailboxes); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleCreateMailbox adds the workspace mailbox, the first mailbox is the default.
+func (h *MailHandler) handleCreateMailbox(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp MailboxReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	mailbox := models.NewMailbox(member.WorkspaceId, strings.TrimSpace(reqp.Name), reqp.Email)
+	mailbox, err = h.mailboxFromReq(ctx, mailbox, reqp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mailbox, err = h.ms.SaveMailbox(ctx, mailbox)
+	if errors.Is(err, services.ErrMailboxExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save mailbox", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(mailbox); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *MailHandler) handleGetMailbox(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	mailboxId := r.PathValue("mailboxId")
+	mailbox, err := h.ms.GetMailbox(ctx, member.WorkspaceId, mailboxId)
+	if errors.Is(err, services.ErrMailboxNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mailbox", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mailbox); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateMailbox saves the workspace mailbox, changed domain has to be added and verified again.
+func (h *MailHandler) handleUpdateMailbox(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp MailboxReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	mailboxId := r.PathValue("mailboxId")
+	mailbox, err := h.ms.GetMailbox(ctx, member.WorkspaceId, mailboxId)
+	if errors.Is(err, services.ErrMailboxNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mailbox", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	changed := models.NewMailbox(member.WorkspaceId, strings.TrimSpace(reqp.Name), reqp.Email)
+	if changed.Domain != mailbox.Domain {
+		changed.CreatedAt = mailbox.CreatedAt
+		changed.MailboxId = mailbox.MailboxId
+		mailbox = changed
+	} else {
+		mailbox.Name = changed.Name
+		mailbox.Email = changed.Email
+	}
+	mailbox, err = h.mailboxFromReq(ctx, mailbox, reqp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mailbox, err = h.ms.SaveMailbox(ctx, mailbox)
+	if errors.Is(err, services.ErrMailboxExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save mailbox", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mailbox); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// mailboxFromReq sets the mailbox signature and defaults from the request,
+// the default label and assignee must be of the workspace.
+func (h *MailHandler) mailboxFromReq(
+	ctx context.Context, mailbox models.Mailbox, reqp MailboxReq) (models.Mailbox, error) {
+	mailbox.IsDefault = reqp.IsDefault
+	mailbox.SignatureHTML = strings.TrimSpace(reqp.SignatureHTML)
+	mailbox.SignatureText = strings.TrimSpace(reqp.SignatureText)
+	mailbox.DefaultLabelId = nil
+	mailbox.DefaultAssigneeId = nil
+	if err := mailbox.Validate(); err != nil {
+		return models.Mailbox{}, err
+	}
+	if reqp.DefaultLabelId != nil && *reqp.DefaultLabelId != "" {
+		label, err := h.ws.GetLabel(ctx, mailbox.WorkspaceId, *reqp.DefaultLabelId)
+		if err != nil {
+			return models.Mailbox{}, errors.New("invalid default label")
+		}
+		mailbox.DefaultLabelId = &label.LabelId
+	}
+	if reqp.DefaultAssigneeId != nil && *reqp.DefaultAssigneeId != "" {
+		assignee, err := h.ws.GetMember(ctx, mailbox.WorkspaceId, *reqp.DefaultAssigneeId)
+		if err != nil {
+			return models.Mailbox{}, errors.New("invalid default assignee")
+		}
+		mailbox.DefaultAssigneeId = &assignee.MemberId
+	}
+	return mailbox, nil
+}
+
+// handleDeleteMailbox deletes the workspace mailbox, the Threads routed to it reply from the workspace sender.
+func (h *MailHandler) handleDeleteMailbox(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	mailboxId := r.PathValue("mailboxId")
+	err := h.ms.DeleteMailbox(ctx, member.WorkspaceId, mailboxId)
+	if errors.Is(err, services.ErrMailboxNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to delete mailbox", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMailboxAddDNS adds the mailbox domain in Postmark, returns the DKIM and the return path DNS records.
+func (h *MailHandler) handleMailboxAddDNS(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	mailboxId := r.PathValue("mailboxId")
+	mailbox, err := h.ms.GetMailbox(ctx, member.WorkspaceId, mailboxId)
+	if errors.Is(err, services.ErrMailboxNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mailbox", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	mailbox, created, err := h.ms.MailboxAddDomain(ctx, mailbox)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to add mailbox domain", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(mailbox); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *MailHandler) handleMailboxVerifyDNS(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	mailboxId := r.PathValue("mailboxId")
+	mailbox, err := h.ms.GetMailbox(ctx, member.WorkspaceId, mailboxId)
+	if errors.Is(err, services.ErrMailboxNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mailbox", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// dns domain ID must exist before verifying
+	if mailbox.DNSDomainId == nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	mailbox, err = h.ms.MailboxVerifyDomain(ctx, mailbox)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to verify mailbox domain", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mailbox); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleRotateMailboxWebhookCredential generates the new webhook credential of the workspace mailbox, only accepted
+// for the raw mail addressed to the mailbox, e.g. of the MTA of the mailbox domain. The earlier credentials of the
+// mailbox are accepted for the overlap. The secret is only returned here.
+func (h *MailHandler) handleRotateMailboxWebhookCredential(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	mailboxId := r.PathValue("mailboxId")
+	mailbox, err := h.ms.GetMailbox(ctx, member.WorkspaceId, mailboxId)
+	if errors.Is(err, services.ErrMailboxNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mailbox", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	credential, secret, err := h.ws.RotateWebhookCredential(ctx, member.WorkspaceId, &mailbox.MailboxId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to rotate mailbox webhook credential", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	rawMailInboundHookUrl, err := credential.HookURL(
+		zyg.WebhookServerUrl(), secret, models.RawMailInboundHookPath(member.WorkspaceId))
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to make webhook url", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := WebhookCredentialResp{
+		Credential:            credential,
+		Secret:                secret,
+		RawMailInboundHookUrl: rawMailInboundHookUrl,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *MailHandler) handleGetMailTemplates(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	templates, err := h.ms.ListMailTemplates(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mail templates", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(templates); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// mailTemplatePath returns the template kind and locale from the request path,
+// false if the kind or the locale is invalid.
+func mailTemplatePath(r *http.Request) (string, string, bool) {
+	kind := r.PathValue("kind")
+	locale := models.NormalizeLocale(r.PathValue("locale"))
+	if !(models.MailTemplateKind{}).IsValid(kind) || locale == "" {
+		return "", "", false
+	}
+	return kind, locale, true
+}
+
+// handleGetMailTemplate returns the workspace template of the kind and locale, otherwise the built-in default.
+func (h *MailHandler) handleGetMailTemplate(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	kind, locale, ok := mailTemplatePath(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tmpl, err := h.ms.GetMailTemplate(ctx, member.WorkspaceId, kind, locale)
+	if errors.Is(err, services.ErrMailTemplateNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch mail template", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(tmpl); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateMailTemplate saves the workspace template of the kind and locale, overriding the built-in default.
+// The template must render with the sample data.
+func (h *MailHandler) handleUpdateMailTemplate(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	kind, locale, ok := mailTemplatePath(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var reqp MailTemplateReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	tmpl := models.NewMailTemplate(member.WorkspaceId, kind, locale)
+	tmpl.Subject = strings.TrimSpace(reqp.Subject)
+	tmpl.HTMLBody = strings.TrimSpace(reqp.HTMLBody)
+	tmpl.TextBody = strings.TrimSpace(reqp.TextBody)
+	if err := tmpl.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tmpl, err = h.ms.SaveMailTemplate(ctx, tmpl)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save mail template", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(tmpl); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleDeleteMailTemplate deletes the workspace template, reverting to the built-in default if any.
+func (h *MailHandler) handleDeleteMailTemplate(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	kind, locale, ok := mailTemplatePath(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err := h.ms.DeleteMailTemplate(ctx, member.WorkspaceId, kind, locale)
+	if errors.Is(err, services.ErrMailTemplateNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to delete mail template", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePreviewMailTemplate renders the unsaved template of the kind and locale with the sample data
+// as the final mail, without sending it.
+func (h *MailHandler) handlePreviewMailTemplate(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	kind, locale, ok := mailTemplatePath(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var reqp MailTemplateReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.ws.GetWorkspace(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl := models.NewMailTemplate(member.WorkspaceId, kind, locale)
+	tmpl.Subject = strings.TrimSpace(reqp.Subject)
+	tmpl.HTMLBody = strings.TrimSpace(reqp.HTMLBody)
+	tmpl.TextBody = strings.TrimSpace(reqp.TextBody)
+	if err := tmpl.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data := models.SampleMailTemplateData(workspace.Name)
+	mail, err := h.ms.ComposeTemplateMail(ctx, workspace, tmpl, data)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to compose mail template preview", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := MailPreviewResp{
+		FromName: mail.FromName,
+		To:       data.CustomerEmail,
+		Subject:  mail.Subject,
+		HTMLBody: mail.HTMLBody,
+		TextBody: mail.TextBody,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleGetPostmarkInboundRequests returns the latest Postmark inbound requests of the workspace
+// of the status, the failed requests by default.
+func (h *MailHandler) handleGetPostmarkInboundRequests(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.InboundRequestStatus{}.Failed()
+	}
+	if !(models.InboundRequestStatus{}).IsValid(status) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	requests, err := h.mis.ListPostmarkInboundRequests(ctx, &member.WorkspaceId, status)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch postmark inbound requests", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(requests); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleReplayPostmarkInboundRequest processes the workspace Postmark inbound request again,
+// the request already processed is returned as is.
+func (h *MailHandler) handleReplayPostmarkInboundRequest(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	messageId := r.PathValue("messageId")
+	req, err := h.mis.GetPostmarkInboundRequest(ctx, messageId)
+	if errors.Is(err, services.ErrPostmarkInboundRequestNotFound) || req.WorkspaceId != member.WorkspaceId {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch postmark inbound request", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	req, err = h.mis.ReplayPostmarkInbound(ctx, req)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to replay postmark inbound request", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(req); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *MailHandler) handleGetIMAPSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.mis.GetIMAPSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch imap setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateIMAPSetting saves the workspace IMAP mailbox polled for the inbound mail.
+func (h *MailHandler) handleUpdateIMAPSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp IMAPSettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.mis.GetIMAPSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch imap setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.IsEnabled = reqp.IsEnabled
+	setting.Host = strings.TrimSpace(reqp.Host)
+	setting.Port = reqp.Port
+	setting.Username = strings.TrimSpace(reqp.Username)
+	if reqp.Password != "" {
+		setting.Password = reqp.Password
+	}
+	setting.Security = reqp.Security
+	setting.Mailbox = strings.TrimSpace(reqp.Mailbox)
+	if err := setting.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if setting.IsEnabled && setting.Security == (models.SMTPSecurity{}).None() && !zyg.IMAPInsecureLoginEnabled() {
+		http.Error(w, "imap login over the plain connection is not allowed", http.StatusBadRequest)
+		return
+	}
+
+	setting, err = h.mis.SaveIMAPSetting(ctx, setting)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save imap setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUploadRawMail processes the uploaded raw RFC 5322 mail e.g. the exported .eml file
+// as the inbound mail of the workspace.
+func (h *MailHandler) handleUploadRawMail(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, zyg.InboundMailMaxBytes()))
+	if err != nil || len(raw) == 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	workspace, err := h.ws.GetWorkspace(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	thread, message, err := h.mis.ProcessRawMail(ctx, workspace, models.MailInboundSource{}.Upload(), raw)
+	if errors.Is(err, services.ErrMailInboundInvalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrChannelInboundProcessed) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, services.ErrSenderBlocked) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to process uploaded raw mail", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := MailInboundResp{
+		ThreadId:  thread.ThreadId,
+		MessageId: message.MessageId,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleRawMailInboundWebhook processes the raw RFC 5322 mail piped from the MTA
+// as the inbound mail of the workspace. Already processed and blocked sender's mail is acknowledged.
+// Mail not addressed to the workspace mailboxes of the webhook credential is forbidden.
+func (h *MailHandler) handleRawMailInboundWebhook(
+	w http.ResponseWriter, r *http.Request, credential *models.WebhookCredential) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, zyg.InboundMailMaxBytes()))
+	if err != nil || len(raw) == 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	workspaceId := r.PathValue("workspaceId")
+	workspace, err := h.ws.GetWorkspace(ctx, workspaceId)
+	if errors.Is(err, services.ErrWorkspaceNotFound) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	thread, message, err := h.mis.ReceiveRawMail(ctx, workspace, credential, raw)
+	if errors.Is(err, services.ErrMailInboundInvalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrMailInboundRecipient) {
+		slog.Warn("refused raw inbound mail",
+			slog.Any("workspaceId", workspace.WorkspaceId), slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, services.ErrChannelInboundProcessed) || errors.Is(err, services.ErrSenderBlocked) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to process raw inbound mail", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("processed raw inbound mail",
+		slog.Any("threadId", thread.ThreadId), slog.Any("messageId", message.MessageId))
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
@@ -2,12 +2,15 @@ package handler
 
 import (
 	"crypto/subtle"
+	"errors"
 	"github.com/getsentry/sentry-go"
 	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/zyghq/zyg/models"
 	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services"
 )
 
 type wrappedWriter struct {
@@ -68,6 +71,7 @@ func (ea *EnsureAuthAccount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 type EnsureMemberAuth struct {
 	handler AuthenticatedMemberHandler
 	authz   ports.AuthServicer
+	api     bool // accepts the PAT of the API channel requests
 }
 
 func NewEnsureMemberAuth(
@@ -78,6 +82,17 @@ func NewEnsureMemberAuth(
 	}
 }
 
+// NewEnsureAPIMemberAuth authenticates the member of the API channel requests from the workspace's own backend,
+// with the PAT or the bearer token. PAT is not accepted on the other member routes.
+func NewEnsureAPIMemberAuth(
+	handler AuthenticatedMemberHandler, as ports.AuthServicer) *EnsureMemberAuth {
+	return &EnsureMemberAuth{
+		handler: handler,
+		authz:   as,
+		api:     true,
+	}
+}
+
 func (em *EnsureMemberAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	workspaceId := r.PathValue("workspaceId")
 	scheme, cred, err := CheckAuthCredentials(r)
@@ -85,7 +100,11 @@ func (em *EnsureMemberAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
 	}
-	member, err := AuthenticateMember(r.Context(), em.authz, workspaceId, scheme, cred)
+	authenticate := AuthenticateMember
+	if em.api {
+		authenticate = AuthenticateAPIMember
+	}
+	member, err := authenticate(r.Context(), em.authz, workspaceId, scheme, cred)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
@@ -120,3 +139,61 @@ func BasicAuthWebhook(handler http.HandlerFunc, username, password string) http.
 		handler(w, r)
 	}
 }
+
+// WorkspaceAuthWebhook authenticates the workspace webhook with the workspace webhook credential as basic auth.
+// The credential of one workspace is not accepted for the other workspaces.
+// The global legacy credentials if provided are accepted for the workspace as per VerifyLegacyWebhookAuth,
+// for the inbound hooks configured before the workspace credentials, until the cutover deadline.
+func WorkspaceAuthWebhook(
+	handler AuthenticatedWebhookHandler, ws ports.WorkspaceServicer, legacyUsername, legacyPassword string,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		hub := sentry.GetHubFromContext(ctx)
+
+		workspaceId := r.PathValue("workspaceId")
+		credentialId, secret, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var credential *models.WebhookCredential
+		var err error
+		if legacyUsername != "" && subtle.ConstantTimeCompare([]byte(credentialId), []byte(legacyUsername)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(secret), []byte(legacyPassword)) == 1 {
+			slog.Warn("workspace webhook authenticated with legacy credentials", slog.Any("workspaceId", workspaceId))
+			err = ws.VerifyLegacyWebhookAuth(ctx, workspaceId)
+		} else {
+			var verified models.WebhookCredential
+			verified, err = ws.VerifyWebhookCredential(ctx, workspaceId, credentialId, secret)
+			credential = &verified
+		}
+		if errors.Is(err, services.ErrWebhookUnauthorized) {
+			hub.CaptureMessage("Unauthorized Workspace Webhook Requested")
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to verify webhook credential", slog.Any("err", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		hub.Scope().SetTag("workspaceId", workspaceId)
+		handler(w, r, credential)
+	}
+}
+
+// WorkspaceOnlyWebhook is the workspace webhook not of the workspace mailboxes, e.g. the SMS webhooks,
+// forbidden with the webhook credential of the workspace mailbox.
+func WorkspaceOnlyWebhook(handler http.HandlerFunc) AuthenticatedWebhookHandler {
+	return func(w http.ResponseWriter, r *http.Request, credential *models.WebhookCredential) {
+		if credential != nil && credential.IsMailboxScoped() {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,413 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/integrations/slack"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services"
+)
+
+// slackAuthorizeURL is the Slack OAuth v2 authorize URL of the Slack app install.
+const slackAuthorizeURL = "https://slack.com/oauth/v2/authorize"
+
+// slackBotScopes are the bot token scopes requested on the Slack app install.
+const slackBotScopes = "channels:history,channels:read,groups:history,groups:read," +
+	"chat:write,users:read,users:read.email,team:read"
+
+type SlackHandler struct {
+	ws  ports.WorkspaceServicer
+	ths ports.ThreadServicer
+	ss  ports.SlackServicer
+}
+
+func NewSlackHandler(ws ports.WorkspaceServicer, ths ports.ThreadServicer, ss ports.SlackServicer) *SlackHandler {
+	return &SlackHandler{ws: ws, ths: ths, ss: ss}
+}
+
+// handleGetSlackInstallURL returns the Slack OAuth authorize URL with the signed state of the workspace.
+// After the install the Member is redirected to the optional redirect URL, of the dashboard or the relative path.
+func (h *SlackHandler) handleGetSlackInstallURL(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	redirectUrl := r.URL.Query().Get("redirectUrl")
+	if _, ok := dashboardRedirect(redirectUrl); redirectUrl != "" && !ok {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	state, err := h.ss.GenerateOAuthState(member.WorkspaceId, redirectUrl)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to generate slack oauth state", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	query := url.Values{}
+	query.Set("client_id", zyg.SlackClientId())
+	query.Set("scope", slackBotScopes)
+	query.Set("redirect_uri", zyg.SlackRedirectUrl())
+	query.Set("state", state)
+	resp := SlackInstallResp{
+		URL: slackAuthorizeURL + "?" + query.Encode(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleSlackOAuthCallback completes the Slack app install for the workspace of the signed state.
+// The Slack channels are synced right after the install.
+func (h *SlackHandler) handleSlackOAuthCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		slog.Info("slack app install denied", slog.Any("error", query.Get("error")))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.ss.VerifyOAuthState(query.Get("state"))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	slackWorkspace, err := h.ss.InstallSlackApp(ctx, claims.WorkspaceId, code)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to install slack app", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Failed sync is retried by the channel sync job.
+	if _, err := h.ss.SyncSlackChannels(ctx, slackWorkspace); err != nil {
+		slog.Error("failed to sync slack channels after install", slog.Any("err", err))
+	}
+
+	if redirectTo, ok := dashboardRedirect(claims.RedirectUrl); claims.RedirectUrl != "" && ok {
+		http.Redirect(w, r, redirectTo, http.StatusFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(slackWorkspace); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// dashboardRedirect returns the URL the Member is redirected to after the install, only of the dashboard origin
+// if configured, otherwise of the relative path. Reports false for the redirect URL of any other site.
+func dashboardRedirect(redirectUrl string) (string, bool) {
+	u, err := url.Parse(redirectUrl)
+	if err != nil || u.User != nil {
+		return "", false
+	}
+	dashboard, err := url.Parse(zyg.DashboardUrl())
+	if err != nil {
+		return "", false
+	}
+	// Relative path, not the protocol relative `//host` or `/\host` as read by the browsers.
+	if u.Scheme == "" && u.Host == "" {
+		if !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(redirectUrl, "//") ||
+			strings.HasPrefix(redirectUrl, "/\\") {
+			return "", false
+		}
+		if dashboard.Host == "" {
+			return u.String(), true
+		}
+		return dashboard.ResolveReference(u).String(), true
+	}
+	if dashboard.Host == "" || !strings.EqualFold(u.Scheme, dashboard.Scheme) ||
+		!strings.EqualFold(u.Host, dashboard.Host) {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// slackWorkspace returns the Slack workspace installed for the Member's workspace,
+// responds not found if not installed.
+func (h *SlackHandler) slackWorkspace(
+	w http.ResponseWriter, ctx context.Context, member *models.Member) (models.SlackWorkspace, bool) {
+	hub := sentry.GetHubFromContext(ctx)
+	slackWorkspace, err := h.ss.GetSlackWorkspace(ctx, member.WorkspaceId)
+	if errors.Is(err, services.ErrSlackWorkspaceNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return models.SlackWorkspace{}, false
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch slack workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return models.SlackWorkspace{}, false
+	}
+	return slackWorkspace, true
+}
+
+func (h *SlackHandler) handleGetSlackWorkspace(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	slackWorkspace, ok := h.slackWorkspace(w, r.Context(), member)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(slackWorkspace); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleSyncSlackChannels syncs the Slack channels visible to the bot right away.
+func (h *SlackHandler) handleSyncSlackChannels(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	slackWorkspace, ok := h.slackWorkspace(w, ctx, member)
+	if !ok {
+		return
+	}
+
+	channels, err := h.ss.SyncSlackChannels(ctx, slackWorkspace)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to sync slack channels", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(channels); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *SlackHandler) handleGetSlackChannels(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	slackWorkspace, ok := h.slackWorkspace(w, ctx, member)
+	if !ok {
+		return
+	}
+
+	channels, err := h.ss.ListSlackChannels(ctx, slackWorkspace)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch slack channels", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(channels); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateSlackChannel enables or disables the Slack channel and maps it to the label
+// applied to the Threads started in the channel.
+func (h *SlackHandler) handleUpdateSlackChannel(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	channelId := r.PathValue("channelId")
+
+	var reqp SlackChannelReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	slackWorkspace, ok := h.slackWorkspace(w, ctx, member)
+	if !ok {
+		return
+	}
+
+	if reqp.LabelId != nil {
+		_, err := h.ws.GetLabel(ctx, member.WorkspaceId, *reqp.LabelId)
+		if errors.Is(err, services.ErrLabelNotFound) {
+			http.Error(w, "label not found", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to fetch label", slog.Any("err", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	channel, err := h.ss.UpdateSlackChannelMapping(ctx, slackWorkspace, channelId, reqp.IsEnabled, reqp.LabelId)
+	if errors.Is(err, services.ErrSlackChannelNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to update slack channel", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(channel); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleReplyThreadSlack posts the Member's reply in the Slack thread of the Slack Thread.
+func (h *SlackHandler) handleReplyThreadSlack(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	var reqp ReplyThreadSlackReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(reqp.TextBody) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	channel := models.ThreadChannel{}.Slack()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	slackWorkspace, ok := h.slackWorkspace(w, ctx, member)
+	if !ok {
+		return
+	}
+	if !slackWorkspace.IsActive() {
+		http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+		return
+	}
+
+	message, err := h.ss.SendThreadSlackReply(ctx, slackWorkspace, thread, *member, reqp.TextBody)
+	if errors.Is(err, services.ErrSlackThreadNotLinked) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send thread slack reply", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := MessageResp{}.NewResponse(&message)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleSlackEventsWebhook handles the Slack Events API request, signed with the app signing secret.
+// Failed messages respond with an error for the event to be retried, processed messages are skipped.
+func (h *SlackHandler) handleSlackEventsWebhook(w http.ResponseWriter, r *http.Request) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error("error reading slack events body", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	isVerified := slack.VerifySignature(
+		body, zyg.SlackSigningSecret(),
+		r.Header.Get("X-Slack-Request-Timestamp"), r.Header.Get("X-Slack-Signature"), time.Now())
+	if !isVerified {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	payload, err := slack.ParseEvent(body)
+	if err != nil {
+		slog.Error("error parsing slack event", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if payload.IsURLVerification() {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(payload.Challenge))
+		return
+	}
+	if !payload.IsEventCallback() || !payload.Event.IsUserMessage() {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	inbound := payload.ToSlackInbound()
+	if err := h.ss.ReceiveInboundSlackMessage(ctx, &inbound); err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to process inbound slack message",
+			slog.Any("err", err), slog.Any("eventId", payload.EventId))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/integrations/sms"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services"
+)
+
+// emptyTwiML acknowledges the Twilio-compatible webhook without an automatic reply.
+const emptyTwiML = `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+
+type SMSHandler struct {
+	ws   ports.WorkspaceServicer
+	ths  ports.ThreadServicer
+	sps  ports.SpamServicer
+	smss ports.SMSServicer
+}
+
+func NewSMSHandler(
+	ws ports.WorkspaceServicer, ths ports.ThreadServicer,
+	sps ports.SpamServicer, smss ports.SMSServicer) *SMSHandler {
+	return &SMSHandler{ws: ws, ths: ths, sps: sps, smss: smss}
+}
+
+func writeEmptyTwiML(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(emptyTwiML))
+}
+
+func (h *SMSHandler) handleGetSMSSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.smss.GetSMSSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch sms setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateSMSSetting saves the workspace SMS provider account.
+func (h *SMSHandler) handleUpdateSMSSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp SMSSettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.smss.GetSMSSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch sms setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.IsEnabled = reqp.IsEnabled
+	setting.Provider = reqp.Provider
+	setting.PhoneNumber = strings.TrimSpace(reqp.PhoneNumber)
+	setting.AccountSid = strings.TrimSpace(reqp.AccountSid)
+	if reqp.AuthToken != "" {
+		setting.AuthToken = reqp.AuthToken
+	}
+	setting.ConversationWindowHours = reqp.ConversationWindowHours
+	if err := setting.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setting, err = h.smss.SaveSMSSetting(ctx, setting)
+	if errors.Is(err, services.ErrSMSProvider) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save sms setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleReplyThreadSMS sends the Member's reply to the SMS Thread customer.
+func (h *SMSHandler) handleReplyThreadSMS(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	var reqp ReplyThreadSMSReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil || strings.TrimSpace(reqp.TextBody) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	channel := models.ThreadChannel{}.SMS()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// SMS setting must be enabled before sending a reply
+	setting, err := h.smss.GetSMSSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch sms setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !setting.IsEnabled {
+		http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+		return
+	}
+
+	customer, err := h.ws.GetCustomer(ctx, member.WorkspaceId, thread.Customer.CustomerId, nil)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	message, err := h.smss.SendThreadSMSReply(ctx, setting, thread, *member, customer, reqp.TextBody)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send thread sms reply", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := MessageResp{}.NewResponse(&message)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleGetThreadSMSLogs returns the provider delivery status of the SMS Thread messages.
+func (h *SMSHandler) handleGetThreadSMSLogs(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	channel := models.ThreadChannel{}.SMS()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logs, err := h.smss.ListThreadSMSLogs(ctx, thread.ThreadId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread sms logs", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(logs); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleSMSInboundWebhook handles the Twilio-compatible inbound SMS webhook for workspace.
+// The Customer is resolved or created by the sender phone.
+func (h *SMSHandler) handleSMSInboundWebhook(w http.ResponseWriter, r *http.Request) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing sms inbound form", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	inbound := sms.FromTwilioInboundForm(r.PostForm)
+	if inbound.ProviderMessageId == "" || inbound.FromPhone == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	workspaceId := r.PathValue("workspaceId")
+	workspace, err := h.ws.GetWorkspace(ctx, workspaceId)
+	if errors.Is(err, services.ErrWorkspaceNotFound) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting, err := h.smss.GetSMSSetting(ctx, workspace.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch sms setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !setting.IsEnabled {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	// Check if the inbound SMS has already been processed, providers retry on failed delivery.
+	isProcessed, err := h.smss.IsInboundSMSProcessed(ctx, setting.Provider, inbound.ProviderMessageId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to check inbound sms processed", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if isProcessed {
+		slog.Info("inbound sms is already processed")
+		writeEmptyTwiML(w)
+		return
+	}
+
+	customer, _, err := h.ws.CreateCustomerWithPhone(ctx, workspace.WorkspaceId, inbound.FromPhone, inbound.FromPhone)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to create customer for inbound sms", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	// Blocked customer's SMS is acknowledged without being processed.
+	if customer.IsBlocked {
+		slog.Info("dropped inbound sms from blocked customer", slog.Any("customerId", customer.CustomerId))
+		writeEmptyTwiML(w)
+		return
+	}
+
+	inbound.Spam, err = h.sps.CheckInbound(ctx, models.SpamCandidate{
+		WorkspaceId: workspace.WorkspaceId,
+		Channel:     models.ThreadChannel{}.SMS(),
+		CustomerId:  customer.CustomerId,
+		TextBody:    inbound.Body,
+	})
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to check inbound sms for spam", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	member, err := h.ws.GetSystemMember(ctx, workspace.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to get system member for inbound sms", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	thread, message, err := h.smss.ProcessInboundSMS(
+		ctx, setting, customer.AsCustomerActor(), member.AsMemberActor(), &inbound)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to process inbound sms", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("processed inbound sms",
+		slog.Any("threadId", thread.ThreadId), slog.Any("messageId", message.MessageId))
+	writeEmptyTwiML(w)
+}
+
+// handleSMSStatusWebhook handles the Twilio-compatible outbound SMS status callback for workspace.
+func (h *SMSHandler) handleSMSStatusWebhook(w http.ResponseWriter, r *http.Request) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	if err := r.ParseForm(); err != nil {
+		slog.Error("error parsing sms status form", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	update := sms.FromTwilioStatusForm(r.PostForm)
+	if update.ProviderMessageId == "" || update.Status == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	workspaceId := r.PathValue("workspaceId")
+	setting, err := h.smss.GetSMSSetting(ctx, workspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch sms setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	messageLog, err := h.smss.UpdateSMSDeliveryStatus(ctx, setting, update)
+	if errors.Is(err, services.ErrSMSLogNotFound) {
+		// Unknown message or the status is already final, nothing to update.
+		slog.Info("sms status not updated",
+			slog.Any("providerMessageId", update.ProviderMessageId), slog.Any("status", update.Status))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to update sms delivery status", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("updated sms delivery status",
+		slog.Any("messageId", messageLog.MessageId), slog.Any("status", messageLog.Status))
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services"
+)
+
+type SpamHandler struct {
+	sps ports.SpamServicer
+}
+
+func NewSpamHandler(sps ports.SpamServicer) *SpamHandler {
+	return &SpamHandler{sps: sps}
+}
+
+func (h *SpamHandler) handleGetSpamSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.sps.GetSpamSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch spam setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *SpamHandler) handleUpdateSpamSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp SpamSettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if reqp.Threshold <= 0 || reqp.ChatRateLimit <= 0 || reqp.ChatRateWindowSecs <= 0 {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.sps.GetSpamSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch spam setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.IsEnabled = reqp.IsEnabled
+	setting.Threshold = reqp.Threshold
+	setting.ChatRateLimit = reqp.ChatRateLimit
+	setting.ChatRateWindowSecs = reqp.ChatRateWindowSecs
+
+	setting, err = h.sps.SaveSpamSetting(ctx, setting)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save spam setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *SpamHandler) handleGetSpamSenderRules(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	rules, err := h.sps.ListSenderRules(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch spam sender rules", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rules); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleCreateSpamSenderRule allows the sender by address, domain or customer,
+// senders are blocked with the workspace blocklist.
+func (h *SpamHandler) handleCreateSpamSenderRule(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp SpamSenderRuleReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if !(models.SpamRuleKind{}).IsValid(reqp.Kind) ||
+		!(models.SpamRuleAction{}).IsValid(reqp.Action) || strings.TrimSpace(reqp.Value) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	rule := models.NewSpamSenderRule(
+		member.WorkspaceId, reqp.Kind, reqp.Value, reqp.Action, member.AsMemberActor())
+	rule, err = h.sps.AddSenderRule(ctx, rule)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to add spam sender rule", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(rule); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *SpamHandler) handleDeleteSpamSenderRule(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	ruleId := r.PathValue("ruleId")
+	err := h.sps.RemoveSenderRule(ctx, member.WorkspaceId, ruleId)
+	if errors.Is(err, services.ErrSpamSenderRuleNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to delete spam sender rule", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
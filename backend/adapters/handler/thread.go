@@ -8,10 +8,10 @@ import (
 	"github.com/zyghq/zyg/adapters/store"
 	"io"
 	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"time"
 
-	"github.com/zyghq/zyg/integrations/email"
 	"github.com/zyghq/zyg/models"
 	"github.com/zyghq/zyg/ports"
 	"github.com/zyghq/zyg/services"
@@ -20,10 +20,18 @@ import (
 type ThreadHandler struct {
 	ws  ports.WorkspaceServicer
 	ths ports.ThreadServicer
+	chs ports.ChannelServicer
+	mis ports.MailInboundServicer
+	sps ports.SpamServicer
+	fws ports.ThreadForwardServicer
+	cms ports.CustomerMailServicer
 }
 
-func NewThreadHandler(ws ports.WorkspaceServicer, ths ports.ThreadServicer) *ThreadHandler {
-	return &ThreadHandler{ws: ws, ths: ths}
+func NewThreadHandler(
+	ws ports.WorkspaceServicer, ths ports.ThreadServicer, chs ports.ChannelServicer,
+	mis ports.MailInboundServicer, sps ports.SpamServicer, fws ports.ThreadForwardServicer,
+	cms ports.CustomerMailServicer) *ThreadHandler {
+	return &ThreadHandler{ws: ws, ths: ths, chs: chs, mis: mis, sps: sps, fws: fws, cms: cms}
 }
 
 // handleGetThreads returns a list of threads associated with the given member's workspace.
@@ -101,21 +109,28 @@ func (h *ThreadHandler) handleUpdateThread(
 	}
 
 	// Modify stage which indirectly modifies status, otherwise set default stage and status.
+	// Stage transitions are validated against the transition table.
+	var resolving bool
 	if stage, found := reqp["stage"]; found {
+		var nextStage string
 		if stage == nil {
 			// set the default stage
-			thread.SetDefaultStatus(member.AsMemberActor())
-			fields = append(fields, "stage")
+			nextStage = thread.DefaultStage()
 		} else {
-			stage := stage.(string)
-			isValid := thread.ThreadStatus.IsValidStage(stage)
-			if !isValid {
+			s, ok := stage.(string)
+			if !ok {
 				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 				return
 			}
-			thread.SetStatusStage(stage, member.AsMemberActor())
-			fields = append(fields, "stage")
+			nextStage = s
+		}
+		wasResolved := thread.IsResolved()
+		if err := thread.TransitionStage(nextStage, member.AsMemberActor()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		resolving = !wasResolved && thread.IsResolved()
+		fields = append(fields, "stage")
 	}
 
 	// Modify assignee if present, otherwise set default assignee.
@@ -147,6 +162,10 @@ func (h *ThreadHandler) handleUpdateThread(
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	// CSAT survey and transcript of the resolved Thread as per the workspace customer mail setting.
+	if resolving {
+		h.cms.OnThreadResolved(ctx, thread)
+	}
 	resp := ThreadResp{}.NewResponse(&thread)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -263,7 +282,19 @@ func (h *ThreadHandler) handleCreateThreadChatMessage(
 
 	ctx := r.Context()
 
-	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, nil)
+	workspace, err := h.ws.GetWorkspace(ctx, member.WorkspaceId)
+	if errors.Is(err, services.ErrWorkspaceNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	channel := models.ThreadChannel{}.InAppChat()
+	thread, err := h.ths.GetWorkspaceThread(ctx, workspace.WorkspaceId, threadId, &channel)
 	if errors.Is(err, services.ErrThreadNotFound) {
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 		return
@@ -274,39 +305,22 @@ func (h *ThreadHandler) handleCreateThreadChatMessage(
 		return
 	}
 
-	message, err := h.ths.AppendOutboundThreadChat(ctx, thread, *member, reqp.Message)
+	message, err := h.chs.SendReply(ctx, workspace, thread, *member, models.Customer{}, models.ChannelReply{
+		TextBody:      reqp.Message,
+		AttachmentIds: uniqueIds(reqp.AttachmentIds),
+	})
+	if isReplyAttachmentErr(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		slog.Error("failed to append thread chat message", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	h.cms.NotifyThreadReply(ctx, thread, message)
 
-	var messageCustomer *CustomerActorResp
-	var messageMember *MemberActorResp
-	if message.Customer != nil {
-		messageCustomer = &CustomerActorResp{
-			CustomerId: message.Customer.CustomerId,
-			Name:       message.Customer.Name,
-		}
-	} else if message.Member != nil {
-		messageMember = &MemberActorResp{
-			MemberId: message.Member.MemberId,
-			Name:     message.Member.Name,
-		}
-	}
-
-	resp := MessageResp{
-		ThreadId:     message.ThreadId,
-		MessageId:    message.MessageId,
-		TextBody:     message.TextBody,
-		MarkdownBody: message.MarkdownBody,
-		HTMLBody:     message.HTMLBody,
-		Customer:     messageCustomer,
-		Member:       messageMember,
-		Channel:      message.Channel,
-		CreatedAt:    message.CreatedAt,
-		UpdatedAt:    message.UpdatedAt,
-	}
+	resp := MessageResp{}.NewResponse(&message)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -332,6 +346,26 @@ func (h *ThreadHandler) handleReplyThreadMail(
 		return
 	}
 
+	addCc, err := parseMailAddresses(reqp.AddCc)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	bcc, err := parseMailAddresses(reqp.Bcc)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	removeCc := make([]string, 0, len(reqp.RemoveCc))
+	for _, email := range reqp.RemoveCc {
+		addr, err := models.ParseMailAddress(email)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		removeCc = append(removeCc, addr.Email)
+	}
+
 	ctx := r.Context()
 	hub := sentry.GetHubFromContext(ctx)
 
@@ -348,8 +382,9 @@ func (h *ThreadHandler) handleReplyThreadMail(
 		return
 	}
 
-	// Get workspace thread
-	thread, err := h.ths.GetWorkspaceThread(ctx, workspace.WorkspaceId, threadId, nil)
+	// Get workspace email thread
+	channel := models.ThreadChannel{}.Email()
+	thread, err := h.ths.GetWorkspaceThread(ctx, workspace.WorkspaceId, threadId, &channel)
 	if errors.Is(err, services.ErrThreadNotFound) {
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 		return
@@ -361,21 +396,6 @@ func (h *ThreadHandler) handleReplyThreadMail(
 		return
 	}
 
-	// Get Postmark setting for the workspace
-	// Postmark setting must be configured before sending a reply mail
-	setting, err := h.ws.GetPostmarkMailServerSetting(ctx, workspace.WorkspaceId)
-	if errors.Is(err, services.ErrPostmarkSettingNotFound) {
-		hub.CaptureMessage("postmark setting required before sending reply")
-		http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
-		return
-	}
-	if err != nil {
-		hub.CaptureException(err)
-		slog.Error("failed to fetch postmark mail server setting", slog.Any("err", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
-	}
-
 	// Get thread customer to send the reply mail
 	customer, err := h.ws.GetCustomer(ctx, workspace.WorkspaceId, thread.Customer.CustomerId, nil)
 	if err != nil {
@@ -385,8 +405,28 @@ func (h *ThreadHandler) handleReplyThreadMail(
 		return
 	}
 
-	message, err := h.ths.SendThreadMailReply(
-		ctx, workspace, setting, thread, *member, customer, reqp.TextBody, reqp.HTMLBody)
+	message, err := h.chs.SendReply(ctx, workspace, thread, *member, customer, models.ChannelReply{
+		TextBody:      reqp.TextBody,
+		HTMLBody:      reqp.HTMLBody,
+		AddCc:         addCc,
+		RemoveCc:      removeCc,
+		Bcc:           bcc,
+		AttachmentIds: uniqueIds(reqp.AttachmentIds),
+	})
+	if isReplyAttachmentErr(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrMailAttachments) {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	// Mail sender must be supported before sending a reply mail
+	if errors.Is(err, services.ErrMailSender) {
+		hub.CaptureMessage("supported mail sender required before sending reply")
+		http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+		return
+	}
 	if err != nil {
 		hub.CaptureException(err)
 		slog.Error("failed to send thread mail reply", slog.Any("err", err))
@@ -394,32 +434,7 @@ func (h *ThreadHandler) handleReplyThreadMail(
 		return
 	}
 
-	var messageCustomer *CustomerActorResp
-	var messageMember *MemberActorResp
-	if message.Customer != nil {
-		messageCustomer = &CustomerActorResp{
-			CustomerId: message.Customer.CustomerId,
-			Name:       message.Customer.Name,
-		}
-	} else if message.Member != nil {
-		messageMember = &MemberActorResp{
-			MemberId: message.Member.MemberId,
-			Name:     message.Member.Name,
-		}
-	}
-
-	resp := MessageResp{
-		ThreadId:     message.ThreadId,
-		MessageId:    message.MessageId,
-		TextBody:     message.TextBody,
-		MarkdownBody: message.MarkdownBody,
-		HTMLBody:     message.HTMLBody,
-		Customer:     messageCustomer,
-		Member:       messageMember,
-		Channel:      message.Channel,
-		CreatedAt:    message.CreatedAt,
-		UpdatedAt:    message.UpdatedAt,
-	}
+	resp := MessageResp{}.NewResponse(&message)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -454,35 +469,7 @@ func (h *ThreadHandler) handleGetThreadMessages(
 
 	items := make([]MessageWithAttachmentsResp, 0, 100)
 	for _, message := range messages {
-		var messageCustomer *CustomerActorResp
-		var messageMember *MemberActorResp
-		if message.Customer != nil {
-			messageCustomer = &CustomerActorResp{
-				CustomerId: message.Customer.CustomerId,
-				Name:       message.Customer.Name,
-			}
-		} else if message.Member != nil {
-			messageMember = &MemberActorResp{
-				MemberId: message.Member.MemberId,
-				Name:     message.Member.Name,
-			}
-		}
-
-		resp := MessageWithAttachmentsResp{
-			MessageResp: MessageResp{
-				ThreadId:     message.ThreadId,
-				MessageId:    message.MessageId,
-				TextBody:     message.TextBody,
-				MarkdownBody: message.MarkdownBody,
-				HTMLBody:     message.HTMLBody,
-				Customer:     messageCustomer,
-				Member:       messageMember,
-				Channel:      message.Channel,
-				CreatedAt:    message.CreatedAt,
-				UpdatedAt:    message.UpdatedAt,
-			},
-			Attachments: message.Attachments,
-		}
+		resp := MessageWithAttachmentsResp{}.NewResponse(&message)
 		items = append(items, resp)
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -789,7 +776,8 @@ func (h *WorkspaceHandler) handleCreateWidget(
 	}
 }
 
-func (h *ThreadHandler) handlePostmarkInboundWebhook(w http.ResponseWriter, r *http.Request) {
+func (h *ThreadHandler) handlePostmarkInboundWebhook(
+	w http.ResponseWriter, r *http.Request, credential *models.WebhookCredential) {
 	defer func(r io.ReadCloser) {
 		_, _ = io.Copy(io.Discard, r)
 		_ = r.Close()
@@ -820,7 +808,8 @@ func (h *ThreadHandler) handlePostmarkInboundWebhook(w http.ResponseWriter, r *h
 		return
 	}
 
-	inboundReq, err := email.FromPostmarkInboundRequest(reqp)
+	channel := models.ThreadChannel{}.Email()
+	inbound, err := h.chs.NormalizeInbound(ctx, channel, workspace.WorkspaceId, reqp)
 	if err != nil {
 		hub.CaptureException(err)
 		slog.Error("error parsing postmark inbound message", slog.Any("err", err))
@@ -828,65 +817,527 @@ func (h *ThreadHandler) handlePostmarkInboundWebhook(w http.ResponseWriter, r *h
 		return
 	}
 
+	// The inbound mail must be addressed to the workspace mailboxes of the webhook credential.
+	// Postmark stops retrying on forbidden.
+	err = h.mis.CheckInboundRecipient(ctx, workspace.WorkspaceId, credential, inbound)
+	if errors.Is(err, services.ErrMailInboundRecipient) {
+		hub.CaptureMessage("Postmark inbound recipient not of workspace")
+		slog.Warn("refused postmark inbound message",
+			slog.Any("workspaceId", workspace.WorkspaceId), slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to check postmark inbound recipient", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
 	// Log inbound request for history and auditability.
-	err = h.ths.LogPostmarkInboundRequest(ctx, workspaceId, inboundReq.MessageID, inboundReq.Payload)
+	// The request is also kept with the processing state for the retry, the log is not required.
+	err = h.ths.LogPostmarkInboundRequest(ctx, workspaceId, inbound.ExternalId, inbound.Payload)
 	if err != nil {
+		hub.CaptureException(err)
 		slog.Error("failed to log postmark inbound request", slog.Any("err", err))
+	}
+
+	// Process the Postmark inbound message.
+	// Failed processing is retried from the kept request, only failing to keep the request is retried by Postmark.
+	req, err := h.mis.ReceivePostmarkInbound(ctx, workspace, inbound)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to receive postmark inbound message", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("received postmark inbound message",
+		slog.Any("externalId", req.MessageId), slog.Any("status", req.Status))
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write([]byte("ok"))
+	if err != nil {
+		return
+	}
+}
+
+// handlePostmarkStatusWebhook updates the outbound mail delivery status as reported by Postmark.
+// Unsupported record types and mail not sent from the path workspace are acknowledged.
+func (h *ThreadHandler) handlePostmarkStatusWebhook(w http.ResponseWriter, r *http.Request) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp map[string]interface{}
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	workspaceId := r.PathValue("workspaceId")
+	_, err = h.ws.GetWorkspace(ctx, workspaceId)
+	if errors.Is(err, services.ErrWorkspaceNotFound) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
 	}
 
-	// Check if the Postmark inbound message request has already been processed.
-	isProcessed, err := h.ths.IsPostmarkInboundMessageProcessed(ctx, inboundReq.MessageID)
+	messageLog, err := h.chs.UpdateDeliveryStatus(ctx, workspaceId, models.ThreadChannel{}.Email(), reqp)
+	if errors.Is(err, services.ErrChannelUnsupported) || errors.Is(err, services.ErrChannelLogNotFound) {
+		slog.Info("skipped postmark status webhook", slog.Any("err", err))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
 	if err != nil {
 		hub.CaptureException(err)
-		slog.Error("failed to check inbound message request processed", slog.Any("err", err))
+		slog.Error("failed to update postmark delivery status", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	if isProcessed {
-		slog.Info("postmark inbound message is already processed")
-		http.Error(w, http.StatusText(http.StatusOK), http.StatusOK)
+	slog.Info("updated postmark delivery status",
+		slog.Any("messageId", messageLog.MessageId), slog.Any("status", messageLog.Status))
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// handleMarkThreadNotSpam moves the spam thread back to its default stage and trains the spam pipeline
+// to allow the customer's sender address, or the customer for channels without email.
+func (h *ThreadHandler) handleMarkThreadNotSpam(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, nil)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	// Convert inbound request to Postmark Inbound Message for further processing.
-	inboundMessage := inboundReq.ToPostmarkInboundMessage()
-	// This also marks the email as verified for the customer as inbound is received directly from mail provider.
-	customer, _, err := h.ws.CreateCustomerWithEmail(
-		ctx, workspace.WorkspaceId, inboundMessage.FromEmail, true, inboundMessage.FromName)
+	customer, err := h.ws.GetCustomer(ctx, member.WorkspaceId, thread.Customer.CustomerId, nil)
 	if err != nil {
 		hub.CaptureException(err)
-		slog.Error("failed to create customer for postmark inbound message", slog.Any("err", err))
+		slog.Error("failed to fetch thread customer", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	// Get the system member for the workspace which will process the inbound mail.
-	member, err := h.ws.GetSystemMember(ctx, workspace.WorkspaceId)
+	kind, value := models.SpamRuleKind{}.Customer(), customer.CustomerId
+	if customer.Email.Valid {
+		kind, value = models.SpamRuleKind{}.Address(), customer.Email.String
+	}
+	rule := models.NewSpamSenderRule(
+		member.WorkspaceId, kind, value, models.SpamRuleAction{}.Allow(), member.AsMemberActor())
+	if _, err := h.sps.AddSenderRule(ctx, rule); err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to add spam allow rule", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if thread.IsSpam() {
+		if err := thread.TransitionStage(thread.DefaultStage(), member.AsMemberActor()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		thread, err = h.ths.UpdateThread(ctx, thread, []string{"stage"})
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to update thread", slog.Any("err", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := ThreadResp{}.NewResponse(&thread)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *ThreadHandler) handleGetThreadParticipants(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	threadId := r.PathValue("threadId")
+
+	channel := models.ThreadChannel{}.Email()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		hub.CaptureException(err)
-		slog.Error("failed to get system member for postmark inbound message", slog.Any("err", err))
+		slog.Error("failed to fetch thread", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	// Process the Postmark inbound message.
-	thread, message, err := h.ths.ProcessPostmarkInbound(
-		ctx, workspace.WorkspaceId, customer.AsCustomerActor(),
-		member.AsMemberActor(), &inboundMessage,
-	)
+	participants, err := h.ths.ListThreadParticipants(ctx, thread.ThreadId)
 	if err != nil {
 		hub.CaptureException(err)
-		slog.Error("failed to process postmark inbound message", slog.Any("err", err))
+		slog.Error("failed to fetch thread participants", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
-	slog.Info("processed postmark inbound message",
-		slog.Any("threadId", thread.ThreadId), slog.Any("messageId", message.MessageId))
 
+	resp := make([]ThreadParticipantResp, 0, len(participants))
+	for _, p := range participants {
+		resp = append(resp, ThreadParticipantResp{}.NewResponse(p))
+	}
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	_, err = w.Write([]byte("ok"))
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		hub.CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleForwardThread forwards the selected messages or the whole transcript of the Thread by mail
+// to the external address, without adding the address as the Thread participant.
+func (h *ThreadHandler) handleForwardThread(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	threadId := r.PathValue("threadId")
+
+	var reqp ForwardThreadReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
 	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	to, err := models.ParseMailAddress(reqp.To)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 		return
 	}
+	trackReplies := true
+	if reqp.TrackReplies != nil {
+		trackReplies = *reqp.TrackReplies
+	}
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	workspace, err := h.ws.GetWorkspace(ctx, member.WorkspaceId)
+	if errors.Is(err, services.ErrWorkspaceNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	thread, err := h.ths.GetWorkspaceThread(ctx, workspace.WorkspaceId, threadId, nil)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	forward := models.NewThreadForward(thread, *member, to, reqp.MessageIds, reqp.Note, trackReplies)
+	forward, message, err := h.fws.ForwardThread(ctx, workspace, thread, *member, forward)
+	if errors.Is(err, services.ErrThreadForwardInvalid) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrMailAttachments) {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	// Mail sender must be supported before forwarding
+	if errors.Is(err, services.ErrMailSender) {
+		hub.CaptureMessage("supported mail sender required before forwarding thread")
+		http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to forward thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := ForwardThreadResp{
+		Forward: ThreadForwardResp{}.NewResponse(forward),
+		Message: MessageResp{}.NewResponse(&message),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		hub.CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleGetOutboundMails returns the latest queued mail replies of the workspace of the status,
+// the pending and the failed by default.
+func (h *ThreadHandler) handleGetOutboundMails(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	status := r.URL.Query().Get("status")
+	if status != "" && !(models.OutboundMailStatus{}).IsValid(status) {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	mails, err := h.chs.ListOutboundMails(ctx, member.WorkspaceId, status)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch outbound mails", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mails); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleRetryOutboundMail queues the failed mail reply to be delivered again.
+func (h *ThreadHandler) handleRetryOutboundMail(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	messageId := r.PathValue("messageId")
+	mail, err := h.chs.RetryOutboundMail(ctx, member.WorkspaceId, messageId)
+	if errors.Is(err, services.ErrOutboundMailNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, services.ErrOutboundMailNotFailed) {
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to retry outbound mail", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(mail); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *ThreadHandler) handleGetThreadForwards(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	threadId := r.PathValue("threadId")
+
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, nil)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	forwards, err := h.fws.ListThreadForwards(ctx, thread.ThreadId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread forwards", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]ThreadForwardResp, 0, len(forwards))
+	for _, f := range forwards {
+		resp = append(resp, ThreadForwardResp{}.NewResponse(f))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		hub.CaptureException(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleSendThreadTranscript mails the transcript of the thread to the customer.
+func (h *ThreadHandler) handleSendThreadTranscript(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	threadId := r.PathValue("threadId")
+
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, nil)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	err = h.cms.SendThreadTranscript(ctx, thread)
+	if errors.Is(err, services.ErrCustomerMailRecipient) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send thread transcript", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleUploadThreadAttachment uploads the member's file for the reply to the thread as the multipart `file`.
+// The returned attachment ID is attached with the chat or the email reply.
+func (h *ThreadHandler) handleUploadThreadAttachment(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	threadId := r.PathValue("threadId")
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, nil)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Leaves room for the multipart headers over the attachment size limit.
+	r.Body = http.MaxBytesReader(w, r.Body, models.MaxAttachmentUploadSize+(1<<20))
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	defer func(file multipart.File) {
+		_ = file.Close()
+	}(file)
+
+	content, err := io.ReadAll(io.LimitReader(file, models.MaxAttachmentUploadSize+1))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	// Content type is detected from the content if not specified by the client,
+	// otherwise the specified content type is checked against the detected.
+	detectedType := http.DetectContentType(content)
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = detectedType
+	}
+
+	upload, err := models.NewAttachmentUpload(
+		thread.WorkspaceId, thread.ThreadId, member.MemberId, header.Filename,
+		contentType, detectedType, int64(len(content)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	upload, err = h.ths.UploadReplyAttachment(ctx, upload, content)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to upload thread attachment", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(upload); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// isReplyAttachmentErr checks the reply's attachments are invalid, not found or already sent.
+func isReplyAttachmentErr(err error) bool {
+	return errors.Is(err, services.ErrMessageAttachmentNotFound) ||
+		errors.Is(err, services.ErrMessageAttachmentInvalid)
+}
+
+// uniqueIds returns the IDs without the duplicates, in order.
+func uniqueIds(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// parseMailAddresses parses the addresses as `Name <email>` or the plain email.
+func parseMailAddresses(addresses []string) ([]models.MailAddress, error) {
+	addrs := make([]models.MailAddress, 0, len(addresses))
+	for _, address := range addresses {
+		addr, err := models.ParseMailAddress(address)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
 }
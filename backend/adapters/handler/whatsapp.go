@@ -0,0 +1,419 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/integrations/whatsapp"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services"
+)
+
+type WhatsAppHandler struct {
+	ws  ports.WorkspaceServicer
+	ths ports.ThreadServicer
+	sps ports.SpamServicer
+	was ports.WhatsAppServicer
+}
+
+func NewWhatsAppHandler(
+	ws ports.WorkspaceServicer, ths ports.ThreadServicer,
+	sps ports.SpamServicer, was ports.WhatsAppServicer) *WhatsAppHandler {
+	return &WhatsAppHandler{ws: ws, ths: ths, sps: sps, was: was}
+}
+
+func (h *WhatsAppHandler) handleGetWhatsAppSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.was.GetWhatsAppSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch whatsapp setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateWhatsAppSetting saves the workspace WhatsApp Business phone number.
+func (h *WhatsAppHandler) handleUpdateWhatsAppSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp WhatsAppSettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.was.GetWhatsAppSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch whatsapp setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.IsEnabled = reqp.IsEnabled
+	setting.PhoneNumberId = strings.TrimSpace(reqp.PhoneNumberId)
+	setting.BusinessAccountId = strings.TrimSpace(reqp.BusinessAccountId)
+	setting.DisplayPhoneNumber = strings.TrimSpace(reqp.DisplayPhoneNumber)
+	if reqp.AccessToken != "" {
+		setting.AccessToken = reqp.AccessToken
+	}
+	if reqp.AppSecret != "" {
+		setting.AppSecret = reqp.AppSecret
+	}
+	setting.VerifyToken = reqp.VerifyToken
+	setting.APIBaseURL = strings.TrimSpace(reqp.APIBaseURL)
+	if err := setting.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setting, err = h.was.SaveWhatsAppSetting(ctx, setting)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save whatsapp setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleReplyThreadWhatsApp sends the Member's reply to the WhatsApp Thread customer.
+// Outside the session window the reply requires the template.
+func (h *WhatsAppHandler) handleReplyThreadWhatsApp(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	var reqp ReplyThreadWhatsAppReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if reqp.Template == nil && strings.TrimSpace(reqp.TextBody) == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if reqp.Template != nil && (reqp.Template.Name == "" || reqp.Template.Language == "") {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	channel := models.ThreadChannel{}.WhatsApp()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// WhatsApp setting must be enabled before sending a reply
+	setting, err := h.was.GetWhatsAppSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch whatsapp setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !setting.IsEnabled {
+		http.Error(w, http.StatusText(http.StatusPreconditionRequired), http.StatusPreconditionRequired)
+		return
+	}
+
+	customer, err := h.ws.GetCustomer(ctx, member.WorkspaceId, thread.Customer.CustomerId, nil)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	message, err := h.was.SendThreadWhatsAppReply(
+		ctx, setting, thread, *member, customer, reqp.TextBody, reqp.Template)
+	if errors.Is(err, services.ErrWhatsAppSessionClosed) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send thread whatsapp reply", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := MessageResp{}.NewResponse(&message)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleGetThreadWhatsAppLogs returns the delivery status and reactions of the WhatsApp Thread messages.
+func (h *WhatsAppHandler) handleGetThreadWhatsAppLogs(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+	threadId := r.PathValue("threadId")
+
+	channel := models.ThreadChannel{}.WhatsApp()
+	thread, err := h.ths.GetWorkspaceThread(ctx, member.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	logs, err := h.was.ListThreadWhatsAppLogs(ctx, thread.ThreadId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread whatsapp logs", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(logs); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleWhatsAppVerifyWebhook handles the webhook subscription verification request,
+// echoes the challenge if the verify token matches the workspace setting.
+func (h *WhatsAppHandler) handleWhatsAppVerifyWebhook(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	query := r.URL.Query()
+	mode := query.Get("hub.mode")
+	verifyToken := query.Get("hub.verify_token")
+	challenge := query.Get("hub.challenge")
+
+	workspaceId := r.PathValue("workspaceId")
+	setting, err := h.was.GetWhatsAppSetting(ctx, workspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch whatsapp setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if mode != "subscribe" || setting.VerifyToken == "" || verifyToken != setting.VerifyToken {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(challenge))
+}
+
+// handleWhatsAppWebhook handles the Cloud API webhook notification for workspace.
+// The payload is signed with the app secret of the workspace setting.
+// Failed messages respond with an error for the webhook to be retried, processed messages are skipped.
+func (h *WhatsAppHandler) handleWhatsAppWebhook(w http.ResponseWriter, r *http.Request) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error("error reading whatsapp webhook body", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	workspaceId := r.PathValue("workspaceId")
+	workspace, err := h.ws.GetWorkspace(ctx, workspaceId)
+	if errors.Is(err, services.ErrWorkspaceNotFound) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting, err := h.was.GetWhatsAppSetting(ctx, workspace.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch whatsapp setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !setting.IsEnabled {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if !whatsapp.VerifySignature(body, setting.AppSecret, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	events, err := whatsapp.ParseWebhook(body)
+	if err != nil {
+		slog.Error("error parsing whatsapp webhook", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	hasFailed := false
+	for i := range events.Messages {
+		inbound := &events.Messages[i]
+		if inbound.PhoneNumberId != setting.PhoneNumberId {
+			slog.Info("skipped whatsapp message for other phone number",
+				slog.Any("phoneNumberId", inbound.PhoneNumberId))
+			continue
+		}
+		if err := h.processInbound(ctx, workspace, setting, inbound); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to process inbound whatsapp message",
+				slog.Any("err", err), slog.Any("waMessageId", inbound.WAMessageId))
+			hasFailed = true
+		}
+	}
+
+	for _, reaction := range events.Reactions {
+		_, err := h.was.UpdateWhatsAppReaction(ctx, reaction)
+		if errors.Is(err, services.ErrWhatsAppLogNotFound) {
+			slog.Info("whatsapp reaction message not found", slog.Any("waMessageId", reaction.TargetMessageId))
+			continue
+		}
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to update whatsapp reaction", slog.Any("err", err))
+			hasFailed = true
+		}
+	}
+
+	for _, update := range events.Statuses {
+		messageLog, err := h.was.UpdateWhatsAppDeliveryStatus(ctx, update)
+		if errors.Is(err, services.ErrWhatsAppLogNotFound) {
+			// Unknown message or the status has already moved forward, nothing to update.
+			slog.Info("whatsapp status not updated",
+				slog.Any("waMessageId", update.WAMessageId), slog.Any("status", update.Status))
+			continue
+		}
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to update whatsapp delivery status", slog.Any("err", err))
+			hasFailed = true
+			continue
+		}
+		slog.Info("updated whatsapp delivery status",
+			slog.Any("messageId", messageLog.MessageId), slog.Any("status", messageLog.Status))
+	}
+
+	if hasFailed {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// processInbound processes the Customer's inbound message, the Customer is resolved or created by the WhatsApp ID.
+func (h *WhatsAppHandler) processInbound(
+	ctx context.Context, workspace models.Workspace, setting models.WhatsAppSetting, inbound *models.WhatsAppInbound,
+) error {
+	// Check if the inbound message has already been processed, webhooks are retried on failure.
+	isProcessed, err := h.was.IsInboundWhatsAppProcessed(ctx, inbound.WAMessageId)
+	if err != nil {
+		return err
+	}
+	if isProcessed {
+		slog.Info("inbound whatsapp message is already processed")
+		return nil
+	}
+
+	name := inbound.ProfileName
+	phone := models.WhatsAppPhone(inbound.FromWaId)
+	if name == "" {
+		name = phone
+	}
+	customer, _, err := h.ws.CreateCustomerWithPhone(ctx, workspace.WorkspaceId, phone, name)
+	if err != nil {
+		return err
+	}
+	// Blocked customer's message is acknowledged without being processed.
+	if customer.IsBlocked {
+		slog.Info("dropped inbound whatsapp message from blocked customer",
+			slog.Any("customerId", customer.CustomerId))
+		return nil
+	}
+
+	inbound.Spam, err = h.sps.CheckInbound(ctx, models.SpamCandidate{
+		WorkspaceId: workspace.WorkspaceId,
+		Channel:     models.ThreadChannel{}.WhatsApp(),
+		CustomerId:  customer.CustomerId,
+		TextBody:    inbound.TextBody(),
+	})
+	if err != nil {
+		return err
+	}
+
+	member, err := h.ws.GetSystemMember(ctx, workspace.WorkspaceId)
+	if err != nil {
+		return err
+	}
+
+	thread, message, err := h.was.ProcessInboundWhatsApp(
+		ctx, setting, customer.AsCustomerActor(), member.AsMemberActor(), inbound)
+	if err != nil {
+		return err
+	}
+	slog.Info("processed inbound whatsapp message",
+		slog.Any("threadId", thread.ThreadId), slog.Any("messageId", message.MessageId))
+	return nil
+}
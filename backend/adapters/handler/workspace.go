@@ -490,16 +490,20 @@ func (h *WorkspaceHandler) handleCreateWorkspaceCustomer(
 	}
 
 	resp := CustomerResp{
-		CustomerId:      customer.CustomerId,
-		Name:            customer.Name,
-		AvatarUrl:       customer.AvatarUrl(),
-		IsEmailVerified: customer.IsEmailVerified,
-		Role:            customer.Role,
-		ExternalId:      customer.ExternalId,
-		Email:           customer.Email,
-		Phone:           customer.Phone,
-		CreatedAt:       customer.CreatedAt,
-		UpdatedAt:       customer.UpdatedAt,
+		CustomerId:        customer.CustomerId,
+		Name:              customer.Name,
+		AvatarUrl:         customer.AvatarUrl(),
+		IsEmailVerified:   customer.IsEmailVerified,
+		Role:              customer.Role,
+		IsBlocked:         customer.IsBlocked,
+		IsEmailBounced:    customer.IsEmailBounced,
+		EmailBouncedAt:    customer.EmailBouncedAt,
+		EmailBounceReason: customer.EmailBounceReason,
+		ExternalId:        customer.ExternalId,
+		Email:             customer.Email,
+		Phone:             customer.Phone,
+		CreatedAt:         customer.CreatedAt,
+		UpdatedAt:         customer.UpdatedAt,
 	}
 	if isCreated {
 		w.Header().Set("Content-Type", "application/json")
@@ -584,15 +588,19 @@ func (h *WorkspaceHandler) handleGetWorkspaceCustomers(
 	items := make([]CustomerResp, 0, len(customers))
 	for _, c := range customers {
 		items = append(items, CustomerResp{
-			CustomerId:      c.CustomerId,
-			ExternalId:      c.ExternalId,
-			Email:           c.Email,
-			Phone:           c.Phone,
-			Name:            c.Name,
-			IsEmailVerified: c.IsEmailVerified,
-			Role:            c.Role,
-			CreatedAt:       c.CreatedAt,
-			UpdatedAt:       c.UpdatedAt,
+			CustomerId:        c.CustomerId,
+			ExternalId:        c.ExternalId,
+			Email:             c.Email,
+			Phone:             c.Phone,
+			Name:              c.Name,
+			IsEmailVerified:   c.IsEmailVerified,
+			Role:              c.Role,
+			IsBlocked:         c.IsBlocked,
+			IsEmailBounced:    c.IsEmailBounced,
+			EmailBouncedAt:    c.EmailBouncedAt,
+			EmailBounceReason: c.EmailBounceReason,
+			CreatedAt:         c.CreatedAt,
+			UpdatedAt:         c.UpdatedAt,
 		})
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -1048,3 +1056,176 @@ func (h *WorkspaceHandler) handlePostmarkUpdateMailServer(
 		slog.Error("failed to encode json", slog.Any("err", err))
 	}
 }
+
+func (h *WorkspaceHandler) handleGetThreadFollowUpSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+
+	hub := sentry.GetHubFromContext(ctx)
+
+	setting, err := h.ws.GetThreadFollowUpSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread follow up setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleUpdateThreadFollowUpSetting saves the workspace follow-up policy for threads waiting on the customer.
+func (h *WorkspaceHandler) handleUpdateThreadFollowUpSetting(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	ctx := r.Context()
+
+	hub := sentry.GetHubFromContext(ctx)
+
+	var reqp ThreadFollowUpSettingReq
+	err := json.NewDecoder(r.Body).Decode(&reqp)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	setting, err := h.ws.GetThreadFollowUpSetting(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread follow up setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	setting.IsEnabled = reqp.IsEnabled
+	setting.FollowUpAfterDays = reqp.FollowUpAfterDays
+	setting.SendFollowUp = reqp.SendFollowUp
+	setting.ResolveAfterDays = reqp.ResolveAfterDays
+	setting.FollowUpTemplate = reqp.FollowUpTemplate
+	if strings.TrimSpace(setting.FollowUpTemplate) == "" {
+		setting.FollowUpTemplate = models.DefaultFollowUpTemplate
+	}
+	if err := setting.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setting, err = h.ws.SaveThreadFollowUpSetting(ctx, setting)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to save thread follow up setting", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(setting); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+func (h *WorkspaceHandler) handleGetWebhookCredentials(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	credentials, err := h.ws.ListWebhookCredentials(ctx, member.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch webhook credentials", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(credentials); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleRotateWebhookCredential generates the new webhook credential of the workspace,
+// the earlier credentials are accepted for the overlap. The secret is only returned here.
+func (h *WorkspaceHandler) handleRotateWebhookCredential(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	credential, secret, err := h.ws.RotateWebhookCredential(ctx, member.WorkspaceId, nil)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to rotate webhook credential", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	// Webhook URLs of the workspace authenticated with the credential, to configure the sources with.
+	serverUrl := zyg.WebhookServerUrl()
+	hookUrls := make(map[string]string)
+	for name, path := range map[string]string{
+		"postmark inbound": models.PostmarkInboundHookPath(member.WorkspaceId),
+		"postmark status":  models.PostmarkStatusHookPath(member.WorkspaceId),
+		"raw mail inbound": models.RawMailInboundHookPath(member.WorkspaceId),
+		"sms inbound":      models.SMSInboundHookPath(member.WorkspaceId),
+		"sms status":       models.SMSStatusHookPath(member.WorkspaceId),
+	} {
+		hookUrl, err := credential.HookURL(serverUrl, secret, path)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to make webhook url", slog.Any("hook", name), slog.Any("err", err))
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		hookUrls[name] = hookUrl
+	}
+
+	resp := WebhookCredentialResp{
+		Credential:             credential,
+		Secret:                 secret,
+		PostmarkInboundHookUrl: hookUrls["postmark inbound"],
+		PostmarkStatusHookUrl:  hookUrls["postmark status"],
+		RawMailInboundHookUrl:  hookUrls["raw mail inbound"],
+		SMSInboundHookUrl:      hookUrls["sms inbound"],
+		SMSStatusHookUrl:       hookUrls["sms status"],
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
+
+// handleRevokeWebhookCredential expires the workspace webhook credential now.
+func (h *WorkspaceHandler) handleRevokeWebhookCredential(
+	w http.ResponseWriter, r *http.Request, member *models.Member) {
+	ctx := r.Context()
+	hub := sentry.GetHubFromContext(ctx)
+
+	credentialId := r.PathValue("credentialId")
+	credential, err := h.ws.RevokeWebhookCredential(ctx, member.WorkspaceId, credentialId)
+	if errors.Is(err, services.ErrWebhookCredentialNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to revoke webhook credential", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(credential); err != nil {
+		slog.Error("failed to encode json", slog.Any("err", err))
+	}
+}
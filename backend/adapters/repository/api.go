@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func apiSettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"is_enabled",
+		"webhook_url",
+		"webhook_secret",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func apiMessageLogCols() builq.Columns {
+	return builq.Columns{
+		"message_id",
+		"message_type",
+		"webhook_status",
+		"webhook_code",
+		"webhook_error",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func (a *APIDB) SaveAPISetting(ctx context.Context, setting models.APISetting) (models.APISetting, error) {
+	q := builq.New()
+	cols := apiSettingCols()
+	insertParams := []any{
+		setting.WorkspaceId, setting.IsEnabled, setting.WebhookUrl,
+		setting.WebhookSecret, setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO api_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("is_enabled = EXCLUDED.is_enabled,")
+	q("webhook_url = EXCLUDED.webhook_url,")
+	q("webhook_secret = EXCLUDED.webhook_secret,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.APISetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = a.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.WebhookUrl,
+		&setting.WebhookSecret, &setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.APISetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.APISetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (a *APIDB) FetchAPISettingById(ctx context.Context, workspaceId string) (models.APISetting, error) {
+	var setting models.APISetting
+
+	q := builq.New()
+	cols := apiSettingCols()
+	q("SELECT %s FROM api_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.APISetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = a.db.QueryRow(ctx, stmt, workspaceId).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.WebhookUrl,
+		&setting.WebhookSecret, &setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.APISetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.APISetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+// InsertAPIMessageLog inserts the API message log of the Thread message.
+func (a *APIDB) InsertAPIMessageLog(
+	ctx context.Context, messageLog models.APIMessageLog) (models.APIMessageLog, error) {
+	q := builq.New()
+	cols := apiMessageLogCols()
+	insertParams := []any{
+		messageLog.MessageId, messageLog.MessageType, messageLog.WebhookStatus,
+		messageLog.WebhookCode, messageLog.WebhookError, messageLog.CreatedAt,
+		messageLog.UpdatedAt,
+	}
+
+	q("INSERT INTO api_message_log (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.APIMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = a.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&messageLog.MessageId, &messageLog.MessageType, &messageLog.WebhookStatus,
+		&messageLog.WebhookCode, &messageLog.WebhookError, &messageLog.CreatedAt,
+		&messageLog.UpdatedAt,
+	)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.APIMessageLog{}, ErrQuery
+	}
+	return messageLog, nil
+}
+
+// FetchAPIMessageLogsByThreadId returns the API message logs of the Thread messages.
+func (a *APIDB) FetchAPIMessageLogsByThreadId(ctx context.Context, threadId string) ([]models.APIMessageLog, error) {
+	var messageLog models.APIMessageLog
+	logs := make([]models.APIMessageLog, 0, 100)
+
+	q := builq.New()
+	q("SELECT %s FROM api_message_log aml", builq.Columns{
+		"aml.message_id", "aml.message_type", "aml.webhook_status",
+		"aml.webhook_code", "aml.webhook_error", "aml.created_at",
+		"aml.updated_at",
+	})
+	q("INNER JOIN message m ON m.message_id = aml.message_id")
+	q("WHERE m.thread_id = %$", threadId)
+	q("ORDER BY aml.created_at DESC")
+	q("LIMIT 100")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.APIMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := a.db.Query(ctx, stmt, threadId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&messageLog.MessageId, &messageLog.MessageType, &messageLog.WebhookStatus,
+		&messageLog.WebhookCode, &messageLog.WebhookError, &messageLog.CreatedAt,
+		&messageLog.UpdatedAt,
+	}, func() error {
+		logs = append(logs, messageLog)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.APIMessageLog{}, ErrQuery
+	}
+	return logs, nil
+}
@@ -0,0 +1,404 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func senderBlockJoinedCols() builq.Columns {
+	return builq.Columns{
+		"sb.block_id",
+		"sb.workspace_id",
+		"sb.kind",
+		"sb.value",
+		"sb.action",
+		"sb.reason",
+		"m.member_id",
+		"m.name",
+		"sb.created_at",
+		"sb.updated_at",
+	}
+}
+
+func blockAuditJoinedCols() builq.Columns {
+	return builq.Columns{
+		"ba.audit_id",
+		"ba.workspace_id",
+		"ba.action",
+		"ba.kind",
+		"ba.value",
+		"ba.reason",
+		"m.member_id",
+		"m.name",
+		"ba.created_at",
+	}
+}
+
+// InsertBlockAuditTx records the block audit within the transaction.
+func InsertBlockAuditTx(ctx context.Context, tx pgx.Tx, audit models.BlockAudit) error {
+	q := builq.New()
+	insertParams := []any{
+		audit.AuditId, audit.WorkspaceId, audit.Action, audit.Kind, audit.Value, audit.Reason,
+		audit.Member.MemberId, audit.CreatedAt,
+	}
+
+	q("INSERT INTO block_audit (audit_id, workspace_id, action, kind, value, reason, member_id, created_at)")
+	q("VALUES (%+$)", insertParams)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	_, err = tx.Exec(ctx, stmt, insertParams...)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
+// UpsertSenderBlock inserts the sender block, or updates the action and reason of the existing
+// block for the same kind and value. The block is audited in the same transaction.
+func (b *BlocklistDB) UpsertSenderBlock(
+	ctx context.Context, block models.SenderBlock, audit models.BlockAudit) (models.SenderBlock, error) {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.SenderBlock{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	q := builq.New()
+	insertParams := []any{
+		block.BlockId, block.WorkspaceId, block.Kind, block.Value, block.Action, block.Reason,
+		block.CreatedBy.MemberId, block.CreatedAt, block.UpdatedAt,
+	}
+
+	q("WITH ins AS (")
+	q("INSERT INTO sender_block (block_id, workspace_id, kind, value, action, reason, created_by_id, created_at, updated_at)")
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id, kind, value) DO UPDATE SET")
+	q("action = EXCLUDED.action, reason = EXCLUDED.reason, created_by_id = EXCLUDED.created_by_id, updated_at = NOW()")
+	q("RETURNING *")
+	q(")")
+	q("SELECT %s FROM ins sb", senderBlockJoinedCols())
+	q("INNER JOIN member m ON sb.created_by_id = m.member_id")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SenderBlock{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
+		&block.BlockId, &block.WorkspaceId, &block.Kind, &block.Value, &block.Action, &block.Reason,
+		&block.CreatedBy.MemberId, &block.CreatedBy.Name,
+		&block.CreatedAt, &block.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.SenderBlock{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.SenderBlock{}, ErrQuery
+	}
+
+	if err := InsertBlockAuditTx(ctx, tx, audit); err != nil {
+		return models.SenderBlock{}, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.SenderBlock{}, ErrTxQuery
+	}
+	return block, nil
+}
+
+func (b *BlocklistDB) FetchSenderBlocksByWorkspaceId(
+	ctx context.Context, workspaceId string) ([]models.SenderBlock, error) {
+	var block models.SenderBlock
+	blocks := make([]models.SenderBlock, 0, 100)
+
+	q := builq.New()
+	q("SELECT %s FROM sender_block sb", senderBlockJoinedCols())
+	q("INNER JOIN member m ON sb.created_by_id = m.member_id")
+	q("WHERE sb.workspace_id = %$", workspaceId)
+	q("ORDER BY sb.created_at DESC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.SenderBlock{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := b.db.Query(ctx, stmt, workspaceId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&block.BlockId, &block.WorkspaceId, &block.Kind, &block.Value, &block.Action, &block.Reason,
+		&block.CreatedBy.MemberId, &block.CreatedBy.Name,
+		&block.CreatedAt, &block.UpdatedAt,
+	}, func() error {
+		blocks = append(blocks, block)
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.SenderBlock{}, ErrQuery
+	}
+	return blocks, nil
+}
+
+// FetchMatchingSenderBlocks returns the workspace sender blocks matching the email address, its domain
+// or the IP address within the blocked range. Empty values are not matched.
+func (b *BlocklistDB) FetchMatchingSenderBlocks(
+	ctx context.Context, workspaceId, email, domain, ip string) ([]models.SenderBlock, error) {
+	var block models.SenderBlock
+	blocks := make([]models.SenderBlock, 0, 3)
+
+	if email == "" && domain == "" && ip == "" {
+		return blocks, nil
+	}
+
+	kind := models.BlockKind{}
+	params := []any{workspaceId}
+	q := builq.New()
+	q("SELECT %s FROM sender_block sb", senderBlockJoinedCols())
+	q("INNER JOIN member m ON sb.created_by_id = m.member_id")
+	q("WHERE sb.workspace_id = %$ AND (FALSE", workspaceId)
+	if email != "" {
+		q("OR (sb.kind = %$ AND sb.value = %$)", kind.Email(), email)
+		params = append(params, kind.Email(), email)
+	}
+	if domain != "" {
+		q("OR (sb.kind = %$ AND sb.value = %$)", kind.Domain(), domain)
+		params = append(params, kind.Domain(), domain)
+	}
+	if ip != "" {
+		q("OR (sb.kind = %$ AND %$::inet <<= sb.value::cidr)", kind.IPRange(), ip)
+		params = append(params, kind.IPRange(), ip)
+	}
+	q(")")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.SenderBlock{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := b.db.Query(ctx, stmt, params...)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&block.BlockId, &block.WorkspaceId, &block.Kind, &block.Value, &block.Action, &block.Reason,
+		&block.CreatedBy.MemberId, &block.CreatedBy.Name,
+		&block.CreatedAt, &block.UpdatedAt,
+	}, func() error {
+		blocks = append(blocks, block)
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.SenderBlock{}, ErrQuery
+	}
+	return blocks, nil
+}
+
+// DeleteSenderBlockById removes the sender block and audits the unblock in the same transaction.
+// The audit kind and value are set from the removed block.
+func (b *BlocklistDB) DeleteSenderBlockById(
+	ctx context.Context, workspaceId, blockId string, audit models.BlockAudit) error {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	stmt := `DELETE FROM sender_block WHERE workspace_id = $1 AND block_id = $2 RETURNING kind, value`
+	err = tx.QueryRow(ctx, stmt, workspaceId, blockId).Scan(&audit.Kind, &audit.Value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to delete query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if err := InsertBlockAuditTx(ctx, tx, audit); err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return ErrTxQuery
+	}
+	return nil
+}
+
+// ModifyCustomerBlock persists the customer block flag and audits it in the same transaction.
+// Blocking revokes the customer's widget sessions, unblocking restores them.
+func (b *BlocklistDB) ModifyCustomerBlock(
+	ctx context.Context, customer models.Customer, audit models.BlockAudit) (models.Customer, error) {
+	tx, err := b.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.Customer{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	q := builq.New()
+	cols := customerCols()
+	q("UPDATE customer SET")
+	q("is_blocked = %$, blocked_at = %$, updated_at = NOW()", customer.IsBlocked, customer.BlockedAt)
+	q("WHERE workspace_id = %$ AND customer_id = %$", customer.WorkspaceId, customer.CustomerId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build update query", slog.Any("err", err))
+		return models.Customer{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = tx.QueryRow(
+		ctx, stmt, customer.IsBlocked, customer.BlockedAt, customer.WorkspaceId, customer.CustomerId).Scan(
+		&customer.CustomerId, &customer.WorkspaceId,
+		&customer.ExternalId, &customer.Email,
+		&customer.Phone, &customer.Name,
+		&customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
+		&customer.CreatedAt, &customer.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.Customer{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.Customer{}, ErrQuery
+	}
+
+	if customer.IsBlocked {
+		stmt = `UPDATE widget_session SET revoked_at = NOW(), updated_at = NOW()
+			WHERE customer_id = $1 AND revoked_at IS NULL`
+	} else {
+		stmt = `UPDATE widget_session SET revoked_at = NULL, updated_at = NOW()
+			WHERE customer_id = $1 AND revoked_at IS NOT NULL`
+	}
+	_, err = tx.Exec(ctx, stmt, customer.CustomerId)
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.Customer{}, ErrQuery
+	}
+
+	if err := InsertBlockAuditTx(ctx, tx, audit); err != nil {
+		return models.Customer{}, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.Customer{}, ErrTxQuery
+	}
+	return customer, nil
+}
+
+func (b *BlocklistDB) FetchBlockAuditsByWorkspaceId(
+	ctx context.Context, workspaceId string) ([]models.BlockAudit, error) {
+	var audit models.BlockAudit
+	limit := 100
+	audits := make([]models.BlockAudit, 0, limit)
+
+	q := builq.New()
+	q("SELECT %s FROM block_audit ba", blockAuditJoinedCols())
+	q("INNER JOIN member m ON ba.member_id = m.member_id")
+	q("WHERE ba.workspace_id = %$", workspaceId)
+	q("ORDER BY ba.created_at DESC")
+	q("LIMIT %d", limit)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.BlockAudit{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := b.db.Query(ctx, stmt, workspaceId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&audit.AuditId, &audit.WorkspaceId, &audit.Action, &audit.Kind, &audit.Value, &audit.Reason,
+		&audit.Member.MemberId, &audit.Member.Name,
+		&audit.CreatedAt,
+	}, func() error {
+		audits = append(audits, audit)
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.BlockAudit{}, ErrQuery
+	}
+	return audits, nil
+}
@@ -22,6 +22,12 @@ func customerCols() builq.Columns {
 		"name",
 		"is_email_verified",
 		"role",
+		"is_blocked",
+		"blocked_at",
+		"is_email_bounced",
+		"email_bounced_at",
+		"email_bounce_reason",
+		"locale",
 		"created_at",
 		"updated_at",
 	}
@@ -85,6 +91,8 @@ func (c *CustomerDB) LookupWorkspaceCustomerById(
 	err = c.db.QueryRow(ctx, stmt, params...).Scan(
 		&customer.CustomerId, &customer.WorkspaceId, &customer.ExternalId,
 		&customer.Email, &customer.Phone, &customer.Name, &customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
 		&customer.CreatedAt, &customer.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -128,6 +136,8 @@ func (c *CustomerDB) LookupWorkspaceCustomerByEmail(
 	err = c.db.QueryRow(ctx, stmt, params...).Scan(
 		&customer.CustomerId, &customer.WorkspaceId, &customer.ExternalId,
 		&customer.Email, &customer.Phone, &customer.Name, &customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
 		&customer.CreatedAt, &customer.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -149,12 +159,14 @@ func (c *CustomerDB) UpsertCustomerByExtId(
 	insertParams := []any{
 		cId, customer.WorkspaceId, customer.ExternalId, customer.Email, customer.Phone,
 		customer.Name, customer.IsEmailVerified, customer.Role,
+		customer.IsBlocked, customer.BlockedAt,
+		customer.IsEmailBounced, customer.EmailBouncedAt, customer.EmailBounceReason, customer.Locale,
 		customer.CreatedAt, customer.UpdatedAt,
 	}
 
 	// Build the insert query.
 	insertB.Addf("INSERT INTO customer (%s)", insertCols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
+	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
 	insertB.Addf("ON CONFLICT (workspace_id, external_id) DO NOTHING")
 	insertB.Addf("RETURNING %s, TRUE AS is_created", insertCols)
 
@@ -190,6 +202,8 @@ func (c *CustomerDB) UpsertCustomerByExtId(
 		&customer.ExternalId, &customer.Email,
 		&customer.Phone, &customer.Name,
 		&customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
 		&customer.CreatedAt, &customer.UpdatedAt, &isCreated,
 	)
 
@@ -213,12 +227,14 @@ func (c *CustomerDB) UpsertCustomerByEmail(
 	insertParams := []any{
 		cId, customer.WorkspaceId, customer.ExternalId, customer.Email, customer.Phone,
 		customer.Name, customer.IsEmailVerified, customer.Role,
+		customer.IsBlocked, customer.BlockedAt,
+		customer.IsEmailBounced, customer.EmailBouncedAt, customer.EmailBounceReason, customer.Locale,
 		customer.CreatedAt, customer.UpdatedAt,
 	}
 
 	// Build the insert query.
 	insertB.Addf("INSERT INTO customer (%s)", insertCols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
+	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
 	insertB.Addf("ON CONFLICT (workspace_id, email) DO NOTHING")
 	insertB.Addf("RETURNING %s, TRUE AS is_created", insertCols)
 
@@ -254,6 +270,8 @@ func (c *CustomerDB) UpsertCustomerByEmail(
 		&customer.ExternalId, &customer.Email,
 		&customer.Phone, &customer.Name,
 		&customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
 		&customer.CreatedAt, &customer.UpdatedAt, &isCreated,
 	)
 
@@ -277,12 +295,14 @@ func (c *CustomerDB) UpsertCustomerByPhone(
 	insertParams := []any{
 		cId, customer.WorkspaceId, customer.ExternalId, customer.Email, customer.Phone,
 		customer.Name, customer.IsEmailVerified, customer.Role,
+		customer.IsBlocked, customer.BlockedAt,
+		customer.IsEmailBounced, customer.EmailBouncedAt, customer.EmailBounceReason, customer.Locale,
 		customer.CreatedAt, customer.UpdatedAt,
 	}
 
 	// Build the insert query.
 	insertB.Addf("INSERT INTO customer (%s)", insertCols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
+	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
 	insertB.Addf("ON CONFLICT (workspace_id, phone) DO NOTHING")
 	insertB.Addf("RETURNING %s, TRUE AS is_created", insertCols)
 
@@ -318,6 +338,8 @@ func (c *CustomerDB) UpsertCustomerByPhone(
 		&customer.ExternalId, &customer.Email,
 		&customer.Phone, &customer.Name,
 		&customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
 		&customer.CreatedAt, &customer.UpdatedAt, &isCreated,
 	)
 
@@ -376,6 +398,8 @@ func (c *CustomerDB) FetchCustomersByWorkspaceId(
 		&customer.CustomerId, &customer.WorkspaceId,
 		&customer.ExternalId, &customer.Email, &customer.Phone,
 		&customer.Name, &customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
 		&customer.CreatedAt, &customer.UpdatedAt,
 	}, func() error {
 		customers = append(customers, customer)
@@ -474,6 +498,7 @@ func (c *CustomerDB) ModifyCustomerById(
 		customer.Name,
 		customer.IsEmailVerified,
 		customer.Role,
+		customer.Locale,
 		customer.CustomerId,
 	}
 
@@ -484,6 +509,7 @@ func (c *CustomerDB) ModifyCustomerById(
 	q("name = %$,", customer.Name)
 	q("is_email_verified = %$,", customer.IsEmailVerified)
 	q("role = %$,", customer.Role)
+	q("locale = %$,", customer.Locale)
 	q("updated_at = NOW()")
 	q("WHERE customer_id = %$", customer.CustomerId)
 	q("RETURNING %s", cols)
@@ -504,6 +530,59 @@ func (c *CustomerDB) ModifyCustomerById(
 		&customer.ExternalId, &customer.Email,
 		&customer.Phone, &customer.Name,
 		&customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
+		&customer.CreatedAt, &customer.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("error", err))
+		return models.Customer{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("error", err))
+		return models.Customer{}, ErrQuery
+	}
+	return customer, nil
+}
+
+// ModifyCustomerEmailBounceById updates the customer's email bounce state by ID.
+func (c *CustomerDB) ModifyCustomerEmailBounceById(
+	ctx context.Context, customer models.Customer) (models.Customer, error) {
+	q := builq.New()
+	cols := customerCols()
+	updateParams := []any{
+		customer.IsEmailBounced,
+		customer.EmailBouncedAt,
+		customer.EmailBounceReason,
+		customer.CustomerId,
+	}
+
+	q("UPDATE customer SET")
+	q("is_email_bounced = %$,", customer.IsEmailBounced)
+	q("email_bounced_at = %$,", customer.EmailBouncedAt)
+	q("email_bounce_reason = %$,", customer.EmailBounceReason)
+	q("updated_at = NOW()")
+	q("WHERE customer_id = %$", customer.CustomerId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build update query", slog.Any("error", err))
+		return models.Customer{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = c.db.QueryRow(ctx, stmt, updateParams...).Scan(
+		&customer.CustomerId, &customer.WorkspaceId,
+		&customer.ExternalId, &customer.Email,
+		&customer.Phone, &customer.Name,
+		&customer.IsEmailVerified, &customer.Role,
+		&customer.IsBlocked, &customer.BlockedAt,
+		&customer.IsEmailBounced, &customer.EmailBouncedAt, &customer.EmailBounceReason, &customer.Locale,
 		&customer.CreatedAt, &customer.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
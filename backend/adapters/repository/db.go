@@ -27,6 +27,34 @@ type ThreadDB struct {
 	rdb *redis.Client
 }
 
+type SpamDB struct {
+	db *pgxpool.Pool
+}
+
+type BlocklistDB struct {
+	db *pgxpool.Pool
+}
+
+type SMSDB struct {
+	db *pgxpool.Pool
+}
+
+type WhatsAppDB struct {
+	db *pgxpool.Pool
+}
+
+type SlackDB struct {
+	db *pgxpool.Pool
+}
+
+type APIDB struct {
+	db *pgxpool.Pool
+}
+
+type MailDB struct {
+	db *pgxpool.Pool
+}
+
 func NewAccountDB(db *pgxpool.Pool) *AccountDB {
 	return &AccountDB{
 		db: db,
@@ -58,6 +86,48 @@ func NewThreadDB(db *pgxpool.Pool, rdb *redis.Client) *ThreadDB {
 	}
 }
 
+func NewSpamDB(db *pgxpool.Pool) *SpamDB {
+	return &SpamDB{
+		db: db,
+	}
+}
+
+func NewBlocklistDB(db *pgxpool.Pool) *BlocklistDB {
+	return &BlocklistDB{
+		db: db,
+	}
+}
+
+func NewSMSDB(db *pgxpool.Pool) *SMSDB {
+	return &SMSDB{
+		db: db,
+	}
+}
+
+func NewWhatsAppDB(db *pgxpool.Pool) *WhatsAppDB {
+	return &WhatsAppDB{
+		db: db,
+	}
+}
+
+func NewSlackDB(db *pgxpool.Pool) *SlackDB {
+	return &SlackDB{
+		db: db,
+	}
+}
+
+func NewAPIDB(db *pgxpool.Pool) *APIDB {
+	return &APIDB{
+		db: db,
+	}
+}
+
+func NewMailDB(db *pgxpool.Pool) *MailDB {
+	return &MailDB{
+		db: db,
+	}
+}
+
 func debugQuery(query string) {
 	slog.Info("db", slog.Any("query", query))
 }
@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func threadFollowUpCols() builq.Columns {
+	return builq.Columns{
+		"thread_id",
+		"workspace_id",
+		"status",
+		"message_id", // nullable
+		"followed_up_at",
+		"resolved_at",  // nullable
+		"cancelled_at", // nullable
+		"created_at",
+		"updated_at",
+	}
+}
+
+// ClaimThreadsDueForFollowUp claims the threads waiting on the customer for longer than the
+// workspace follow-up policy, which are not yet followed up in the current waiting cycle.
+// A thread is followed up again if the stage changed after the previous follow-up.
+// Only the threads of the channels the follow-up is sent through are claimed.
+// Claimed threads are held until the lease, not to be followed up by the concurrent schedulers.
+func (th *ThreadDB) ClaimThreadsDueForFollowUp(
+	ctx context.Context, channels []string, now time.Time, leaseUntil time.Time, limit int) ([]models.Thread, error) {
+	q := builq.New()
+	q("WITH due AS (")
+	q("SELECT th.thread_id FROM thread th")
+	q("INNER JOIN thread_follow_up_setting fs ON th.workspace_id = fs.workspace_id")
+	q("LEFT OUTER JOIN thread_follow_up fu ON th.thread_id = fu.thread_id")
+	q("LEFT OUTER JOIN thread_follow_up_claim cl ON th.thread_id = cl.thread_id")
+	q("WHERE fs.is_enabled = TRUE")
+	q("AND th.stage = 'waiting_on_customer' AND th.status = 'todo'")
+	q("AND th.channel = ANY(%$::TEXT[])", channels)
+	q("AND th.status_changed_at <= (NOW() AT TIME ZONE 'UTC') - make_interval(days => fs.follow_up_after_days)")
+	q("AND (fu.thread_id IS NULL OR fu.status <> 'followed_up' OR th.status_changed_at > fu.followed_up_at)")
+	q("AND (cl.thread_id IS NULL OR cl.claimed_until <= %$)", now)
+	// Oldest waiting threads first.
+	q("ORDER BY th.status_changed_at ASC")
+	q("LIMIT %d", limit)
+	q("FOR UPDATE OF th SKIP LOCKED")
+	q(")")
+	return th.claimThreads(ctx, q, now, leaseUntil, limit)
+}
+
+// ClaimThreadsDueForResolve claims the followed up threads still waiting on the customer,
+// with no stage change since the follow-up, for longer than the workspace resolve policy.
+// Claimed threads are held until the lease, not to be resolved by the concurrent schedulers.
+func (th *ThreadDB) ClaimThreadsDueForResolve(
+	ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.Thread, error) {
+	q := builq.New()
+	q("WITH due AS (")
+	q("SELECT th.thread_id FROM thread th")
+	q("INNER JOIN thread_follow_up fu ON th.thread_id = fu.thread_id")
+	q("INNER JOIN thread_follow_up_setting fs ON th.workspace_id = fs.workspace_id")
+	q("LEFT OUTER JOIN thread_follow_up_claim cl ON th.thread_id = cl.thread_id")
+	q("WHERE fs.is_enabled = TRUE AND fu.status = 'followed_up'")
+	q("AND th.stage = 'waiting_on_customer' AND th.status = 'todo'")
+	q("AND th.status_changed_at <= fu.followed_up_at")
+	q("AND fu.followed_up_at <= (NOW() AT TIME ZONE 'UTC') - make_interval(days => fs.resolve_after_days)")
+	q("AND (cl.thread_id IS NULL OR cl.claimed_until <= %$)", now)
+	q("ORDER BY fu.followed_up_at ASC")
+	q("LIMIT %d", limit)
+	q("FOR UPDATE OF th SKIP LOCKED")
+	q(")")
+	return th.claimThreads(ctx, q, now, leaseUntil, limit)
+}
+
+// claimThreads holds the due threads selected with the `due` query until the lease, and returns the threads claimed.
+// The claim of the thread already held by the concurrent scheduler is not taken over until the lease is over.
+func (th *ThreadDB) claimThreads(
+	ctx context.Context, q builq.BuildFn, now time.Time, leaseUntil time.Time, limit int) ([]models.Thread, error) {
+	cols := threadJoinedCols()
+	q(", claimed AS (")
+	q("INSERT INTO thread_follow_up_claim (thread_id, claimed_until)")
+	q("SELECT thread_id, %$ FROM due", leaseUntil)
+	q("ON CONFLICT (thread_id) DO UPDATE SET claimed_until = EXCLUDED.claimed_until, updated_at = NOW()")
+	q("WHERE thread_follow_up_claim.claimed_until <= %$", now)
+	q("RETURNING thread_id")
+	q(")")
+	q("SELECT %s FROM %s", cols, "thread th")
+	q("INNER JOIN claimed ON th.thread_id = claimed.thread_id")
+	q("INNER JOIN customer c ON th.customer_id = c.customer_id")
+	q("LEFT OUTER JOIN member am ON th.assignee_id = am.member_id")
+	q("INNER JOIN member scm ON th.status_changed_by_id = scm.member_id")
+	q("LEFT OUTER JOIN inbound_message inb ON th.inbound_message_id = inb.message_id")
+	q("LEFT OUTER JOIN outbound_message oub ON th.outbound_message_id = oub.message_id")
+	q("LEFT OUTER JOIN customer inbc ON inb.customer_id = inbc.customer_id")
+	q("LEFT OUTER JOIN member oubm ON oub.member_id = oubm.member_id")
+	q("INNER JOIN member mc ON th.created_by_id = mc.member_id")
+	q("INNER JOIN member mu ON th.updated_by_id = mu.member_id")
+	q("ORDER BY th.status_changed_at ASC")
+
+	stmt, params, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.Thread{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+	return th.queryThreads(ctx, stmt, params, limit)
+}
+
+// queryThreads scans the threads selected with threadJoinedCols.
+func (th *ThreadDB) queryThreads(
+	ctx context.Context, stmt string, params []any, limit int) ([]models.Thread, error) {
+	var thread models.Thread
+	threads := make([]models.Thread, 0, limit)
+
+	var (
+		assignedMemberId    sql.NullString
+		assignedMemberName  sql.NullString
+		assignedAt          sql.NullTime
+		inboundMessageId    sql.NullString
+		inboundCustomerId   sql.NullString
+		inboundCustomerName sql.NullString
+		inboundPreviewText  sql.NullString
+		inboundFirstSeqId   sql.NullString
+		inboundLastSeqId    sql.NullString
+		inboundCreatedAt    sql.NullTime
+		inboundUpdatedAt    sql.NullTime
+		outboundMessageId   sql.NullString
+		outboundMemberId    sql.NullString
+		outboundMemberName  sql.NullString
+		outboundPreviewText sql.NullString
+		outboundFirstSeqId  sql.NullString
+		outboundLastSeqId   sql.NullString
+		outboundCreatedAt   sql.NullTime
+		outboundUpdatedAt   sql.NullTime
+	)
+
+	rows, _ := th.db.Query(ctx, stmt, params...)
+
+	defer rows.Close()
+
+	_, err := pgx.ForEachRow(rows, []any{
+		&thread.ThreadId, &thread.WorkspaceId, &thread.Customer.CustomerId, &thread.Customer.Name,
+		&assignedMemberId, &assignedMemberName, &assignedAt,
+		&thread.Title, &thread.Description,
+		&thread.ThreadStatus.Status,
+		&thread.ThreadStatus.StatusChangedAt,
+		&thread.ThreadStatus.StatusChangedBy.MemberId, &thread.ThreadStatus.StatusChangedBy.Name,
+		&thread.ThreadStatus.Stage,
+		&thread.Replied, &thread.Priority, &thread.Channel,
+		&inboundMessageId, &inboundCustomerId, &inboundCustomerName,
+		&inboundPreviewText, &inboundFirstSeqId, &inboundLastSeqId,
+		&inboundCreatedAt, &inboundUpdatedAt,
+		&outboundMessageId, &outboundMemberId, &outboundMemberName,
+		&outboundPreviewText, &outboundFirstSeqId, &outboundLastSeqId,
+		&outboundCreatedAt, &outboundUpdatedAt,
+		&thread.CreatedBy.MemberId, &thread.CreatedBy.Name,
+		&thread.UpdatedBy.MemberId, &thread.UpdatedBy.Name,
+		&thread.CreatedAt, &thread.UpdatedAt,
+	}, func() error {
+		if assignedMemberId.Valid {
+			memberActor := models.MemberActor{
+				MemberId: assignedMemberId.String,
+				Name:     assignedMemberName.String,
+			}
+			thread.AssignMember(memberActor, assignedAt.Time)
+		} else {
+			thread.ClearAssignedMember()
+		}
+		if inboundMessageId.Valid {
+			customer := models.CustomerActor{
+				CustomerId: inboundCustomerId.String,
+				Name:       inboundCustomerName.String,
+			}
+			thread.InboundMessage = &models.InboundMessage{
+				MessageId:   inboundMessageId.String,
+				Customer:    customer,
+				PreviewText: inboundPreviewText.String,
+				FirstSeqId:  inboundFirstSeqId.String,
+				LastSeqId:   inboundLastSeqId.String,
+				CreatedAt:   inboundCreatedAt.Time,
+				UpdatedAt:   inboundUpdatedAt.Time,
+			}
+		} else {
+			thread.ClearInboundMessage()
+		}
+		if outboundMessageId.Valid {
+			member := models.MemberActor{
+				MemberId: outboundMemberId.String,
+				Name:     outboundMemberName.String,
+			}
+			thread.OutboundMessage = &models.OutboundMessage{
+				MessageId:   outboundMessageId.String,
+				Member:      member,
+				PreviewText: outboundPreviewText.String,
+				FirstSeqId:  outboundFirstSeqId.String,
+				LastSeqId:   outboundLastSeqId.String,
+				CreatedAt:   outboundCreatedAt.Time,
+				UpdatedAt:   outboundUpdatedAt.Time,
+			}
+		} else {
+			thread.ClearOutboundMessage()
+		}
+		threads = append(threads, thread)
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.Thread{}, ErrQuery
+	}
+	return threads, nil
+}
+
+// SaveThreadFollowUp records the follow-up step of the thread, starting a new waiting cycle
+// if the thread was followed up before.
+func (th *ThreadDB) SaveThreadFollowUp(
+	ctx context.Context, followUp models.ThreadFollowUp) (models.ThreadFollowUp, error) {
+	var (
+		messageId               sql.NullString
+		resolvedAt, cancelledAt sql.NullTime
+	)
+	if followUp.MessageId != nil {
+		messageId = sql.NullString{String: *followUp.MessageId, Valid: true}
+	}
+
+	q := builq.New()
+	cols := threadFollowUpCols()
+	insertParams := []any{
+		followUp.ThreadId, followUp.WorkspaceId, followUp.Status, messageId,
+		followUp.FollowedUpAt, nil, nil, followUp.CreatedAt, followUp.UpdatedAt,
+	}
+
+	q("INSERT INTO thread_follow_up (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (thread_id) DO UPDATE SET")
+	q("status = EXCLUDED.status,")
+	q("message_id = EXCLUDED.message_id,")
+	q("followed_up_at = EXCLUDED.followed_up_at,")
+	q("resolved_at = NULL,")
+	q("cancelled_at = NULL,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.ThreadFollowUp{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = th.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&followUp.ThreadId, &followUp.WorkspaceId, &followUp.Status, &messageId,
+		&followUp.FollowedUpAt, &resolvedAt, &cancelledAt,
+		&followUp.CreatedAt, &followUp.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.ThreadFollowUp{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.ThreadFollowUp{}, ErrQuery
+	}
+
+	followUp.MessageId = nil
+	if messageId.Valid {
+		followUp.MessageId = &messageId.String
+	}
+	followUp.ResolvedAt = nil
+	if resolvedAt.Valid {
+		followUp.ResolvedAt = &resolvedAt.Time
+	}
+	followUp.CancelledAt = nil
+	if cancelledAt.Valid {
+		followUp.CancelledAt = &cancelledAt.Time
+	}
+	return followUp, nil
+}
+
+// ResolveThreadFollowUp persists the resolved thread status and marks the follow-up resolved.
+// Returns ErrEmpty if the follow-up is no longer pending, e.g. the customer replied in the meantime.
+func (th *ThreadDB) ResolveThreadFollowUp(ctx context.Context, thread *models.Thread) error {
+	tx, err := th.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	stmt := `UPDATE thread_follow_up SET
+		status = $1, resolved_at = NOW(), updated_at = NOW()
+		WHERE thread_id = $2 AND status = $3`
+
+	tag, err := tx.Exec(
+		ctx, stmt, models.ThreadFollowUpStatus{}.Resolved(), thread.ThreadId, models.ThreadFollowUpStatus{}.FollowedUp())
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return ErrQuery
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEmpty
+	}
+
+	if err := ModifyThreadStatusTx(ctx, tx, thread); err != nil {
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return ErrTxQuery
+	}
+	return nil
+}
+
+// CancelThreadFollowUpTx cancels the pending follow-up of the Thread within the transaction.
+// Used by the inbound append paths when the Customer replies.
+func CancelThreadFollowUpTx(ctx context.Context, tx pgx.Tx, threadId string) error {
+	stmt := `UPDATE thread_follow_up SET
+		status = $1, cancelled_at = NOW(), updated_at = NOW()
+		WHERE thread_id = $2 AND status = $3`
+
+	_, err := tx.Exec(
+		ctx, stmt, models.ThreadFollowUpStatus{}.Cancelled(), threadId, models.ThreadFollowUpStatus{}.FollowedUp())
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
@@ -0,0 +1,1248 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func mailSettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"is_enabled",
+		"sender",
+		"from_name",
+		"from_email",
+		"reply_to",
+		"api_key",
+		"smtp_host",
+		"smtp_port",
+		"smtp_username",
+		"smtp_password",
+		"smtp_security",
+		"smtp_auth",
+		"reopen_on_bounce",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func (m *MailDB) SaveMailSetting(ctx context.Context, setting models.MailSetting) (models.MailSetting, error) {
+	q := builq.New()
+	cols := mailSettingCols()
+	insertParams := []any{
+		setting.WorkspaceId, setting.IsEnabled, setting.Sender,
+		setting.FromName, setting.FromEmail, setting.ReplyTo, setting.APIKey,
+		setting.SMTPHost, setting.SMTPPort, setting.SMTPUsername, setting.SMTPPassword,
+		setting.SMTPSecurity, setting.SMTPAuth, setting.ReopenOnBounce, setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO mail_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("is_enabled = EXCLUDED.is_enabled,")
+	q("sender = EXCLUDED.sender,")
+	q("from_name = EXCLUDED.from_name,")
+	q("from_email = EXCLUDED.from_email,")
+	q("reply_to = EXCLUDED.reply_to,")
+	q("api_key = EXCLUDED.api_key,")
+	q("smtp_host = EXCLUDED.smtp_host,")
+	q("smtp_port = EXCLUDED.smtp_port,")
+	q("smtp_username = EXCLUDED.smtp_username,")
+	q("smtp_password = EXCLUDED.smtp_password,")
+	q("smtp_security = EXCLUDED.smtp_security,")
+	q("smtp_auth = EXCLUDED.smtp_auth,")
+	q("reopen_on_bounce = EXCLUDED.reopen_on_bounce,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.MailSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.Sender,
+		&setting.FromName, &setting.FromEmail, &setting.ReplyTo, &setting.APIKey,
+		&setting.SMTPHost, &setting.SMTPPort, &setting.SMTPUsername, &setting.SMTPPassword,
+		&setting.SMTPSecurity, &setting.SMTPAuth, &setting.ReopenOnBounce, &setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.MailSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.MailSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (m *MailDB) FetchMailSettingById(ctx context.Context, workspaceId string) (models.MailSetting, error) {
+	var setting models.MailSetting
+
+	q := builq.New()
+	cols := mailSettingCols()
+	q("SELECT %s FROM mail_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.MailSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, workspaceId).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.Sender,
+		&setting.FromName, &setting.FromEmail, &setting.ReplyTo, &setting.APIKey,
+		&setting.SMTPHost, &setting.SMTPPort, &setting.SMTPUsername, &setting.SMTPPassword,
+		&setting.SMTPSecurity, &setting.SMTPAuth, &setting.ReopenOnBounce, &setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.MailSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.MailSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func imapSettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"is_enabled",
+		"host",
+		"port",
+		"username",
+		"password",
+		"security",
+		"mailbox",
+		"uid_validity",
+		"last_uid",
+		"last_polled_at",
+		"poll_error",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func imapSettingDest(setting *models.IMAPSetting) []any {
+	return []any{
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.Host, &setting.Port,
+		&setting.Username, &setting.Password, &setting.Security, &setting.Mailbox,
+		&setting.UIDValidity, &setting.LastUID, &setting.LastPolledAt, &setting.PollError,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	}
+}
+
+func (m *MailDB) SaveIMAPSetting(ctx context.Context, setting models.IMAPSetting) (models.IMAPSetting, error) {
+	q := builq.New()
+	cols := imapSettingCols()
+	insertParams := []any{
+		setting.WorkspaceId, setting.IsEnabled, setting.Host, setting.Port,
+		setting.Username, setting.Password, setting.Security, setting.Mailbox,
+		setting.UIDValidity, setting.LastUID, setting.LastPolledAt, setting.PollError,
+		setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO imap_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("is_enabled = EXCLUDED.is_enabled,")
+	q("host = EXCLUDED.host,")
+	q("port = EXCLUDED.port,")
+	q("username = EXCLUDED.username,")
+	q("password = EXCLUDED.password,")
+	q("security = EXCLUDED.security,")
+	q("mailbox = EXCLUDED.mailbox,")
+	q("uid_validity = EXCLUDED.uid_validity,")
+	q("last_uid = EXCLUDED.last_uid,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, insertParams...).Scan(imapSettingDest(&setting)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (m *MailDB) FetchIMAPSettingById(ctx context.Context, workspaceId string) (models.IMAPSetting, error) {
+	var setting models.IMAPSetting
+
+	q := builq.New()
+	cols := imapSettingCols()
+	q("SELECT %s FROM imap_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, workspaceId).Scan(imapSettingDest(&setting)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (m *MailDB) FetchEnabledIMAPSettings(ctx context.Context) ([]models.IMAPSetting, error) {
+	var setting models.IMAPSetting
+	settings := make([]models.IMAPSetting, 0, 10)
+
+	q := builq.New()
+	cols := imapSettingCols()
+	q("SELECT %s FROM imap_setting", cols)
+	q("WHERE is_enabled = TRUE")
+	q("ORDER BY last_polled_at ASC NULLS FIRST")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.IMAPSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := m.db.Query(ctx, stmt)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, imapSettingDest(&setting), func() error {
+		settings = append(settings, setting)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.IMAPSetting{}, ErrQuery
+	}
+	return settings, nil
+}
+
+func (m *MailDB) ModifyIMAPSettingPollStatus(
+	ctx context.Context, setting models.IMAPSetting) (models.IMAPSetting, error) {
+	q := builq.New()
+	cols := imapSettingCols()
+	q("UPDATE imap_setting SET")
+	q("uid_validity = %$, last_uid = %$,", setting.UIDValidity, setting.LastUID)
+	q("last_polled_at = %$, poll_error = %$,", setting.LastPolledAt, setting.PollError)
+	q("updated_at = NOW()")
+	q("WHERE workspace_id = %$", setting.WorkspaceId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(
+		ctx, stmt, setting.UIDValidity, setting.LastUID,
+		setting.LastPolledAt, setting.PollError, setting.WorkspaceId,
+	).Scan(imapSettingDest(&setting)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.IMAPSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func mailReplySettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"is_branded",
+		"logo_url",
+		"header",
+		"footer",
+		"include_quote",
+		"signature_html",
+		"signature_text",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func (m *MailDB) SaveMailReplySetting(
+	ctx context.Context, setting models.MailReplySetting) (models.MailReplySetting, error) {
+	q := builq.New()
+	cols := mailReplySettingCols()
+	insertParams := []any{
+		setting.WorkspaceId, setting.IsBranded, setting.LogoUrl, setting.Header, setting.Footer,
+		setting.IncludeQuote, setting.SignatureHTML, setting.SignatureText,
+		setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO mail_reply_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("is_branded = EXCLUDED.is_branded,")
+	q("logo_url = EXCLUDED.logo_url,")
+	q("header = EXCLUDED.header,")
+	q("footer = EXCLUDED.footer,")
+	q("include_quote = EXCLUDED.include_quote,")
+	q("signature_html = EXCLUDED.signature_html,")
+	q("signature_text = EXCLUDED.signature_text,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.MailReplySetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&setting.WorkspaceId, &setting.IsBranded, &setting.LogoUrl, &setting.Header, &setting.Footer,
+		&setting.IncludeQuote, &setting.SignatureHTML, &setting.SignatureText,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.MailReplySetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.MailReplySetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (m *MailDB) FetchMailReplySettingById(
+	ctx context.Context, workspaceId string) (models.MailReplySetting, error) {
+	var setting models.MailReplySetting
+
+	q := builq.New()
+	cols := mailReplySettingCols()
+	q("SELECT %s FROM mail_reply_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.MailReplySetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, workspaceId).Scan(
+		&setting.WorkspaceId, &setting.IsBranded, &setting.LogoUrl, &setting.Header, &setting.Footer,
+		&setting.IncludeQuote, &setting.SignatureHTML, &setting.SignatureText,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.MailReplySetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.MailReplySetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func customerMailSettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"send_csat",
+		"csat_url",
+		"send_transcript",
+		"send_notification",
+		"conversation_url",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func (m *MailDB) SaveCustomerMailSetting(
+	ctx context.Context, setting models.CustomerMailSetting) (models.CustomerMailSetting, error) {
+	q := builq.New()
+	cols := customerMailSettingCols()
+	insertParams := []any{
+		setting.WorkspaceId, setting.SendCSAT, setting.CSATUrl, setting.SendTranscript,
+		setting.SendNotification, setting.ConversationUrl, setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO customer_mail_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("send_csat = EXCLUDED.send_csat,")
+	q("csat_url = EXCLUDED.csat_url,")
+	q("send_transcript = EXCLUDED.send_transcript,")
+	q("send_notification = EXCLUDED.send_notification,")
+	q("conversation_url = EXCLUDED.conversation_url,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.CustomerMailSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&setting.WorkspaceId, &setting.SendCSAT, &setting.CSATUrl, &setting.SendTranscript,
+		&setting.SendNotification, &setting.ConversationUrl, &setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.CustomerMailSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.CustomerMailSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (m *MailDB) FetchCustomerMailSettingById(
+	ctx context.Context, workspaceId string) (models.CustomerMailSetting, error) {
+	var setting models.CustomerMailSetting
+
+	q := builq.New()
+	cols := customerMailSettingCols()
+	q("SELECT %s FROM customer_mail_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.CustomerMailSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, workspaceId).Scan(
+		&setting.WorkspaceId, &setting.SendCSAT, &setting.CSATUrl, &setting.SendTranscript,
+		&setting.SendNotification, &setting.ConversationUrl, &setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.CustomerMailSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.CustomerMailSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func mailSignatureCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"member_id",
+		"html_body",
+		"text_body",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func (m *MailDB) SaveMailSignature(
+	ctx context.Context, signature models.MailSignature) (models.MailSignature, error) {
+	q := builq.New()
+	cols := mailSignatureCols()
+	insertParams := []any{
+		signature.WorkspaceId, signature.MemberId, signature.HTMLBody, signature.TextBody,
+		signature.CreatedAt, signature.UpdatedAt,
+	}
+
+	q("INSERT INTO mail_signature (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (member_id) DO UPDATE SET")
+	q("html_body = EXCLUDED.html_body,")
+	q("text_body = EXCLUDED.text_body,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.MailSignature{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&signature.WorkspaceId, &signature.MemberId, &signature.HTMLBody, &signature.TextBody,
+		&signature.CreatedAt, &signature.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.MailSignature{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.MailSignature{}, ErrQuery
+	}
+	return signature, nil
+}
+
+func (m *MailDB) FetchMailSignatureByMemberId(
+	ctx context.Context, workspaceId string, memberId string) (models.MailSignature, error) {
+	var signature models.MailSignature
+
+	q := builq.New()
+	cols := mailSignatureCols()
+	q("SELECT %s FROM mail_signature", cols)
+	q("WHERE workspace_id = %$ AND member_id = %$", workspaceId, memberId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.MailSignature{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, workspaceId, memberId).Scan(
+		&signature.WorkspaceId, &signature.MemberId, &signature.HTMLBody, &signature.TextBody,
+		&signature.CreatedAt, &signature.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.MailSignature{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.MailSignature{}, ErrQuery
+	}
+	return signature, nil
+}
+
+func mailboxCols() builq.Columns {
+	return builq.Columns{
+		"mailbox_id",
+		"workspace_id",
+		"name",
+		"email",
+		"domain",
+		"is_default",
+		"signature_html",
+		"signature_text",
+		"default_label_id",
+		"default_assignee_id",
+		"has_dns",
+		"is_dns_verified",
+		"dns_verified_at",
+		"dns_domain_id",
+		"dkim_host",
+		"dkim_text_value",
+		"dkim_update_status",
+		"return_path_domain",
+		"return_path_domain_cname",
+		"return_path_domain_verified",
+		"created_at",
+		"updated_at",
+	}
+}
+
+// mailboxJoinedCols is the mailbox columns prefixed with the table alias `mb`.
+func mailboxJoinedCols() builq.Columns {
+	cols := mailboxCols()
+	joined := make(builq.Columns, 0, len(cols))
+	for _, col := range cols {
+		joined = append(joined, "mb."+col)
+	}
+	return joined
+}
+
+func mailboxDest(mailbox *models.Mailbox) []any {
+	return []any{
+		&mailbox.MailboxId, &mailbox.WorkspaceId, &mailbox.Name, &mailbox.Email, &mailbox.Domain,
+		&mailbox.IsDefault, &mailbox.SignatureHTML, &mailbox.SignatureText,
+		&mailbox.DefaultLabelId, &mailbox.DefaultAssigneeId,
+		&mailbox.HasDNS, &mailbox.IsDNSVerified, &mailbox.DNSVerifiedAt, &mailbox.DNSDomainId,
+		&mailbox.DKIMHost, &mailbox.DKIMTextValue, &mailbox.DKIMUpdateStatus,
+		&mailbox.ReturnPathDomain, &mailbox.ReturnPathDomainCNAME, &mailbox.ReturnPathDomainVerified,
+		&mailbox.CreatedAt, &mailbox.UpdatedAt,
+	}
+}
+
+// SaveMailbox inserts or updates the mailbox, the default mailbox unsets the workspace's earlier default
+// in the same transaction.
+func (m *MailDB) SaveMailbox(ctx context.Context, mailbox models.Mailbox) (models.Mailbox, error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.Mailbox{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	if mailbox.IsDefault {
+		q := builq.New()
+		q("UPDATE mailbox SET is_default = FALSE, updated_at = NOW()")
+		q("WHERE workspace_id = %$ AND mailbox_id <> %$ AND is_default", mailbox.WorkspaceId, mailbox.MailboxId)
+
+		stmt, _, err := q.Build()
+		if err != nil {
+			slog.Error("failed to build query", slog.Any("err", err))
+			return models.Mailbox{}, ErrQuery
+		}
+
+		if zyg.DBQueryDebug() {
+			debug := q.DebugBuild()
+			debugQuery(debug)
+		}
+
+		if _, err := tx.Exec(ctx, stmt, mailbox.WorkspaceId, mailbox.MailboxId); err != nil {
+			slog.Error("failed to update query", slog.Any("err", err))
+			return models.Mailbox{}, ErrQuery
+		}
+	}
+
+	q := builq.New()
+	cols := mailboxCols()
+	insertParams := []any{
+		mailbox.MailboxId, mailbox.WorkspaceId, mailbox.Name, mailbox.Email, mailbox.Domain,
+		mailbox.IsDefault, mailbox.SignatureHTML, mailbox.SignatureText,
+		mailbox.DefaultLabelId, mailbox.DefaultAssigneeId,
+		mailbox.HasDNS, mailbox.IsDNSVerified, mailbox.DNSVerifiedAt, mailbox.DNSDomainId,
+		mailbox.DKIMHost, mailbox.DKIMTextValue, mailbox.DKIMUpdateStatus,
+		mailbox.ReturnPathDomain, mailbox.ReturnPathDomainCNAME, mailbox.ReturnPathDomainVerified,
+		mailbox.CreatedAt, mailbox.UpdatedAt,
+	}
+
+	q("INSERT INTO mailbox (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (mailbox_id) DO UPDATE SET")
+	q("name = EXCLUDED.name,")
+	q("email = EXCLUDED.email,")
+	q("domain = EXCLUDED.domain,")
+	q("is_default = EXCLUDED.is_default,")
+	q("signature_html = EXCLUDED.signature_html,")
+	q("signature_text = EXCLUDED.signature_text,")
+	q("default_label_id = EXCLUDED.default_label_id,")
+	q("default_assignee_id = EXCLUDED.default_assignee_id,")
+	q("has_dns = EXCLUDED.has_dns,")
+	q("is_dns_verified = EXCLUDED.is_dns_verified,")
+	q("dns_verified_at = EXCLUDED.dns_verified_at,")
+	q("dns_domain_id = EXCLUDED.dns_domain_id,")
+	q("dkim_host = EXCLUDED.dkim_host,")
+	q("dkim_text_value = EXCLUDED.dkim_text_value,")
+	q("dkim_update_status = EXCLUDED.dkim_update_status,")
+	q("return_path_domain = EXCLUDED.return_path_domain,")
+	q("return_path_domain_cname = EXCLUDED.return_path_domain_cname,")
+	q("return_path_domain_verified = EXCLUDED.return_path_domain_verified,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.Mailbox{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(mailboxDest(&mailbox)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.Mailbox{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.Mailbox{}, ErrQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.Mailbox{}, ErrTxQuery
+	}
+	return mailbox, nil
+}
+
+func (m *MailDB) LookupMailboxById(
+	ctx context.Context, workspaceId string, mailboxId string) (models.Mailbox, error) {
+	var mailbox models.Mailbox
+
+	q := builq.New()
+	cols := mailboxCols()
+	q("SELECT %s FROM mailbox", cols)
+	q("WHERE workspace_id = %$ AND mailbox_id = %$", workspaceId, mailboxId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.Mailbox{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, workspaceId, mailboxId).Scan(mailboxDest(&mailbox)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Mailbox{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.Mailbox{}, ErrQuery
+	}
+	return mailbox, nil
+}
+
+// FetchMailboxesByWorkspaceId returns the workspace mailboxes, the default mailbox first.
+func (m *MailDB) FetchMailboxesByWorkspaceId(ctx context.Context, workspaceId string) ([]models.Mailbox, error) {
+	var mailbox models.Mailbox
+	mailboxes := make([]models.Mailbox, 0, 10)
+
+	q := builq.New()
+	cols := mailboxCols()
+	q("SELECT %s FROM mailbox", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+	q("ORDER BY is_default DESC, created_at ASC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.Mailbox{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := m.db.Query(ctx, stmt, workspaceId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, mailboxDest(&mailbox), func() error {
+		mailboxes = append(mailboxes, mailbox)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.Mailbox{}, ErrQuery
+	}
+	return mailboxes, nil
+}
+
+// DeleteMailbox deletes the workspace mailbox, the Threads routed to the mailbox are unlinked.
+func (m *MailDB) DeleteMailbox(ctx context.Context, workspaceId string, mailboxId string) error {
+	q := builq.New()
+	q("DELETE FROM mailbox")
+	q("WHERE workspace_id = %$ AND mailbox_id = %$", workspaceId, mailboxId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	tag, err := m.db.Exec(ctx, stmt, workspaceId, mailboxId)
+	if err != nil {
+		slog.Error("failed to delete query", slog.Any("err", err))
+		return ErrQuery
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEmpty
+	}
+	return nil
+}
+
+// InsertThreadMailbox links the Thread to the mailbox it was routed to, the Thread's first mailbox is kept.
+// Returns true if the Thread is linked now.
+func (m *MailDB) InsertThreadMailbox(ctx context.Context, threadId string, mailboxId string) (bool, error) {
+	q := builq.New()
+	q("INSERT INTO thread_mailbox (thread_id, mailbox_id)")
+	q("VALUES (%$, %$)", threadId, mailboxId)
+	q("ON CONFLICT (thread_id) DO NOTHING")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return false, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	tag, err := m.db.Exec(ctx, stmt, threadId, mailboxId)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return false, ErrQuery
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// LookupMailboxByThreadId returns the mailbox the Thread was routed to.
+func (m *MailDB) LookupMailboxByThreadId(ctx context.Context, threadId string) (models.Mailbox, error) {
+	var mailbox models.Mailbox
+
+	q := builq.New()
+	q("SELECT %s FROM thread_mailbox tm", mailboxJoinedCols())
+	q("INNER JOIN mailbox mb ON tm.mailbox_id = mb.mailbox_id")
+	q("WHERE tm.thread_id = %$", threadId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.Mailbox{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, threadId).Scan(mailboxDest(&mailbox)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Mailbox{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.Mailbox{}, ErrQuery
+	}
+	return mailbox, nil
+}
+
+func mailTemplateCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"kind",
+		"locale",
+		"subject",
+		"html_body",
+		"text_body",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func mailTemplateDest(tmpl *models.MailTemplate) []any {
+	return []any{
+		&tmpl.WorkspaceId, &tmpl.Kind, &tmpl.Locale,
+		&tmpl.Subject, &tmpl.HTMLBody, &tmpl.TextBody,
+		&tmpl.CreatedAt, &tmpl.UpdatedAt,
+	}
+}
+
+// SaveMailTemplate inserts or updates the workspace template of the kind and locale.
+func (m *MailDB) SaveMailTemplate(ctx context.Context, tmpl models.MailTemplate) (models.MailTemplate, error) {
+	q := builq.New()
+	cols := mailTemplateCols()
+	insertParams := []any{
+		tmpl.WorkspaceId, tmpl.Kind, tmpl.Locale, tmpl.Subject, tmpl.HTMLBody, tmpl.TextBody,
+		tmpl.CreatedAt, tmpl.UpdatedAt,
+	}
+
+	q("INSERT INTO mail_template (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id, kind, locale) DO UPDATE SET")
+	q("subject = EXCLUDED.subject,")
+	q("html_body = EXCLUDED.html_body,")
+	q("text_body = EXCLUDED.text_body,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.MailTemplate{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, insertParams...).Scan(mailTemplateDest(&tmpl)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.MailTemplate{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.MailTemplate{}, ErrQuery
+	}
+	return tmpl, nil
+}
+
+// FetchMailTemplatesByWorkspaceId returns the workspace templates ordered by kind and locale.
+func (m *MailDB) FetchMailTemplatesByWorkspaceId(
+	ctx context.Context, workspaceId string) ([]models.MailTemplate, error) {
+	var tmpl models.MailTemplate
+	templates := make([]models.MailTemplate, 0, 10)
+
+	q := builq.New()
+	cols := mailTemplateCols()
+	q("SELECT %s FROM mail_template", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+	q("ORDER BY kind, locale")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.MailTemplate{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := m.db.Query(ctx, stmt, workspaceId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, mailTemplateDest(&tmpl), func() error {
+		templates = append(templates, tmpl)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.MailTemplate{}, ErrQuery
+	}
+	return templates, nil
+}
+
+// DeleteMailTemplate deletes the workspace template of the kind and locale, reverting to the built-in default.
+func (m *MailDB) DeleteMailTemplate(ctx context.Context, workspaceId string, kind string, locale string) error {
+	q := builq.New()
+	q("DELETE FROM mail_template")
+	q("WHERE workspace_id = %$ AND kind = %$ AND locale = %$", workspaceId, kind, locale)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	tag, err := m.db.Exec(ctx, stmt, workspaceId, kind, locale)
+	if err != nil {
+		slog.Error("failed to delete query", slog.Any("err", err))
+		return ErrQuery
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEmpty
+	}
+	return nil
+}
+
+func postmarkInboundRequestCols() builq.Columns {
+	return builq.Columns{
+		"message_id",
+		"workspace_id",
+		"payload",
+		"status",
+		"error",
+		"attempts",
+		"next_attempt_at",
+		"thread_id",
+		"processed_at",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func postmarkInboundRequestDest(req *models.PostmarkInboundRequest) []any {
+	return []any{
+		&req.MessageId, &req.WorkspaceId, &req.Payload,
+		&req.Status, &req.Error, &req.Attempts, &req.NextAttemptAt,
+		&req.ThreadId, &req.ProcessedAt, &req.CreatedAt, &req.UpdatedAt,
+	}
+}
+
+// InsertPostmarkInboundRequest inserts the received inbound request,
+// the request already received e.g. retried by Postmark is returned as is.
+func (m *MailDB) InsertPostmarkInboundRequest(
+	ctx context.Context, req models.PostmarkInboundRequest) (models.PostmarkInboundRequest, error) {
+	q := builq.New()
+	cols := postmarkInboundRequestCols()
+	insertParams := []any{
+		req.MessageId, req.WorkspaceId, req.Payload,
+		req.Status, req.Error, req.Attempts, req.NextAttemptAt,
+		req.ThreadId, req.ProcessedAt, req.CreatedAt, req.UpdatedAt,
+	}
+
+	q("INSERT INTO postmark_inbound_request (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (message_id) DO UPDATE SET")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.PostmarkInboundRequest{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, insertParams...).Scan(postmarkInboundRequestDest(&req)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.PostmarkInboundRequest{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.PostmarkInboundRequest{}, ErrQuery
+	}
+	return req, nil
+}
+
+// ModifyPostmarkInboundRequestStatus updates the processing state of the inbound request after processed.
+func (m *MailDB) ModifyPostmarkInboundRequestStatus(
+	ctx context.Context, req models.PostmarkInboundRequest) (models.PostmarkInboundRequest, error) {
+	q := builq.New()
+	cols := postmarkInboundRequestCols()
+	q("UPDATE postmark_inbound_request SET")
+	q("status = %$, error = %$, attempts = %$,", req.Status, req.Error, req.Attempts)
+	q("next_attempt_at = %$, thread_id = %$, processed_at = %$,", req.NextAttemptAt, req.ThreadId, req.ProcessedAt)
+	q("updated_at = NOW()")
+	q("WHERE message_id = %$", req.MessageId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.PostmarkInboundRequest{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(
+		ctx, stmt, req.Status, req.Error, req.Attempts,
+		req.NextAttemptAt, req.ThreadId, req.ProcessedAt, req.MessageId,
+	).Scan(postmarkInboundRequestDest(&req)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.PostmarkInboundRequest{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.PostmarkInboundRequest{}, ErrQuery
+	}
+	return req, nil
+}
+
+func (m *MailDB) LookupPostmarkInboundRequestById(
+	ctx context.Context, messageId string) (models.PostmarkInboundRequest, error) {
+	var req models.PostmarkInboundRequest
+
+	q := builq.New()
+	cols := postmarkInboundRequestCols()
+	q("SELECT %s FROM postmark_inbound_request", cols)
+	q("WHERE message_id = %$", messageId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.PostmarkInboundRequest{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = m.db.QueryRow(ctx, stmt, messageId).Scan(postmarkInboundRequestDest(&req)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.PostmarkInboundRequest{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.PostmarkInboundRequest{}, ErrQuery
+	}
+	return req, nil
+}
+
+// FetchPostmarkInboundRequests returns the inbound requests of the status, the latest first.
+// Requests of all the workspaces are returned if the workspace is not provided.
+func (m *MailDB) FetchPostmarkInboundRequests(
+	ctx context.Context, workspaceId *string, status string, limit int) ([]models.PostmarkInboundRequest, error) {
+	var req models.PostmarkInboundRequest
+	requests := make([]models.PostmarkInboundRequest, 0, limit)
+
+	args := []any{status}
+	q := builq.New()
+	cols := postmarkInboundRequestCols()
+	q("SELECT %s FROM postmark_inbound_request", cols)
+	q("WHERE status = %$", status)
+	if workspaceId != nil {
+		q("AND workspace_id = %$", *workspaceId)
+		args = append(args, *workspaceId)
+	}
+	q("ORDER BY created_at DESC")
+	q("LIMIT %d", limit)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.PostmarkInboundRequest{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := m.db.Query(ctx, stmt, args...)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, postmarkInboundRequestDest(&req), func() error {
+		requests = append(requests, req)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.PostmarkInboundRequest{}, ErrQuery
+	}
+	return requests, nil
+}
+
+// ClaimDuePostmarkInboundRequests returns the failed inbound requests due for the retry, the earliest first.
+// The claimed requests are leased until the time, so that the requests are not claimed again while retried
+// e.g. by the other server. The request not retried within the lease is claimed again after.
+func (m *MailDB) ClaimDuePostmarkInboundRequests(
+	ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.PostmarkInboundRequest, error) {
+	var req models.PostmarkInboundRequest
+	requests := make([]models.PostmarkInboundRequest, 0, limit)
+
+	q := builq.New()
+	cols := postmarkInboundRequestCols()
+	q("UPDATE postmark_inbound_request SET next_attempt_at = %$, updated_at = NOW()", leaseUntil)
+	q("WHERE message_id IN (")
+	q("SELECT message_id FROM postmark_inbound_request")
+	q("WHERE status = %$ AND next_attempt_at <= %$", models.InboundRequestStatus{}.Failed(), now)
+	q("ORDER BY next_attempt_at ASC")
+	q("LIMIT %d", limit)
+	q("FOR UPDATE SKIP LOCKED")
+	q(")")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.PostmarkInboundRequest{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := m.db.Query(ctx, stmt, leaseUntil, models.InboundRequestStatus{}.Failed(), now)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, postmarkInboundRequestDest(&req), func() error {
+		requests = append(requests, req)
+		// JSON is decoded into the existing map, not to be shared with the appended request.
+		req.Payload = nil
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.PostmarkInboundRequest{}, ErrQuery
+	}
+	return requests, nil
+}
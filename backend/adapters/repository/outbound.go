@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func outboundMailCols() builq.Columns {
+	return builq.Columns{
+		"message_id",
+		"workspace_id",
+		"thread_id",
+		"member_id",
+		"customer_id",
+		"participants",
+		"bcc",
+		"status",
+		"error",
+		"attempts",
+		"next_attempt_at",
+		"sent_at",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func outboundMailDest(mail *models.OutboundMail) []any {
+	return []any{
+		&mail.MessageId, &mail.WorkspaceId, &mail.ThreadId, &mail.MemberId, &mail.CustomerId,
+		&mail.Participants, &mail.Bcc,
+		&mail.Status, &mail.Error, &mail.Attempts, &mail.NextAttemptAt, &mail.SentAt,
+		&mail.CreatedAt, &mail.UpdatedAt,
+	}
+}
+
+// InsertOutboundMailTx inserts the outbound mail queued for the persisted reply message within the transaction.
+func InsertOutboundMailTx(ctx context.Context, tx pgx.Tx, mail *models.OutboundMail) error {
+	q := builq.New()
+	cols := outboundMailCols()
+	insertParams := []any{
+		mail.MessageId, mail.WorkspaceId, mail.ThreadId, mail.MemberId, mail.CustomerId,
+		mail.Participants, mail.Bcc,
+		mail.Status, mail.Error, mail.Attempts, mail.NextAttemptAt, mail.SentAt,
+		mail.CreatedAt, mail.UpdatedAt,
+	}
+
+	q("INSERT INTO outbound_mail (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	_, err = tx.Exec(ctx, stmt, insertParams...)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return ErrTxQuery
+	}
+	return nil
+}
+
+// ClaimDueOutboundMails returns the queued mails due for delivery, the earliest first.
+// The claimed mails are leased until the time, so that the mails are not claimed again while delivered.
+// The mail not delivered within the lease is claimed again after.
+func (th *ThreadDB) ClaimDueOutboundMails(
+	ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.OutboundMail, error) {
+	var mail models.OutboundMail
+	mails := make([]models.OutboundMail, 0, limit)
+
+	q := builq.New()
+	cols := outboundMailCols()
+	q("UPDATE outbound_mail SET next_attempt_at = %$, updated_at = NOW()", leaseUntil)
+	q("WHERE message_id IN (")
+	q("SELECT message_id FROM outbound_mail")
+	q("WHERE status = %$ AND next_attempt_at <= %$", models.OutboundMailStatus{}.Queued(), now)
+	q("ORDER BY next_attempt_at ASC")
+	q("LIMIT %d", limit)
+	q("FOR UPDATE SKIP LOCKED")
+	q(")")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.OutboundMail{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := th.db.Query(ctx, stmt, leaseUntil, models.OutboundMailStatus{}.Queued(), now)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, outboundMailDest(&mail), func() error {
+		mails = append(mails, mail)
+		// JSON is decoded into the existing slices, not to be shared with the appended mail.
+		mail.Participants, mail.Bcc = nil, nil
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.OutboundMail{}, ErrQuery
+	}
+	return mails, nil
+}
+
+// ModifyOutboundMailStatus updates the delivery state of the outbound mail.
+func (th *ThreadDB) ModifyOutboundMailStatus(
+	ctx context.Context, mail models.OutboundMail) (models.OutboundMail, error) {
+	q := builq.New()
+	cols := outboundMailCols()
+	q("UPDATE outbound_mail SET")
+	q("status = %$, error = %$, attempts = %$,", mail.Status, mail.Error, mail.Attempts)
+	q("next_attempt_at = %$, sent_at = %$,", mail.NextAttemptAt, mail.SentAt)
+	q("updated_at = NOW()")
+	q("WHERE message_id = %$", mail.MessageId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.OutboundMail{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = th.db.QueryRow(
+		ctx, stmt, mail.Status, mail.Error, mail.Attempts, mail.NextAttemptAt, mail.SentAt, mail.MessageId,
+	).Scan(outboundMailDest(&mail)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.OutboundMail{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.OutboundMail{}, ErrQuery
+	}
+	return mail, nil
+}
+
+// SaveOutboundMailSent inserts the message log of the delivered mail and updates the mail as sent
+// in a transaction.
+func (th *ThreadDB) SaveOutboundMailSent(
+	ctx context.Context, mail models.OutboundMail, messageLog *models.ChannelMessageLog,
+) (models.OutboundMail, error) {
+	tx, err := th.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.OutboundMail{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	if messageLog != nil {
+		messageLog.MessageId = mail.MessageId
+		if _, err := InsertChannelMessageLogTx(ctx, tx, messageLog); err != nil {
+			return models.OutboundMail{}, err
+		}
+	}
+
+	q := builq.New()
+	cols := outboundMailCols()
+	q("UPDATE outbound_mail SET")
+	q("status = %$, error = %$, attempts = %$,", mail.Status, mail.Error, mail.Attempts)
+	q("next_attempt_at = %$, sent_at = %$,", mail.NextAttemptAt, mail.SentAt)
+	q("updated_at = NOW()")
+	q("WHERE message_id = %$", mail.MessageId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.OutboundMail{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = tx.QueryRow(
+		ctx, stmt, mail.Status, mail.Error, mail.Attempts, mail.NextAttemptAt, mail.SentAt, mail.MessageId,
+	).Scan(outboundMailDest(&mail)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.OutboundMail{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.OutboundMail{}, ErrTxQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.OutboundMail{}, ErrTxQuery
+	}
+	return mail, nil
+}
+
+// CountOutboundMailsSentSince counts the mails of the workspace sent since the time.
+func (th *ThreadDB) CountOutboundMailsSentSince(
+	ctx context.Context, workspaceId string, since time.Time) (int, error) {
+	var count int
+
+	q := builq.New()
+	q("SELECT COUNT(*) FROM outbound_mail")
+	q("WHERE workspace_id = %$ AND status = %$", workspaceId, models.OutboundMailStatus{}.Sent())
+	q("AND sent_at >= %$", since)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return 0, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = th.db.QueryRow(ctx, stmt, workspaceId, models.OutboundMailStatus{}.Sent(), since).Scan(&count)
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return 0, ErrQuery
+	}
+	return count, nil
+}
+
+// FetchOutboundMailsByWorkspaceId returns the mails of the workspace in any of the statuses, the latest first.
+func (th *ThreadDB) FetchOutboundMailsByWorkspaceId(
+	ctx context.Context, workspaceId string, statuses []string, limit int) ([]models.OutboundMail, error) {
+	var mail models.OutboundMail
+	mails := make([]models.OutboundMail, 0, limit)
+
+	q := builq.New()
+	cols := outboundMailCols()
+	q("SELECT %s FROM outbound_mail", cols)
+	q("WHERE workspace_id = %$ AND status = ANY(%$)", workspaceId, statuses)
+	q("ORDER BY created_at DESC")
+	q("LIMIT %d", limit)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.OutboundMail{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := th.db.Query(ctx, stmt, workspaceId, statuses)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, outboundMailDest(&mail), func() error {
+		mails = append(mails, mail)
+		mail.Participants, mail.Bcc = nil, nil
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.OutboundMail{}, ErrQuery
+	}
+	return mails, nil
+}
+
+func (th *ThreadDB) LookupOutboundMailById(
+	ctx context.Context, workspaceId string, messageId string) (models.OutboundMail, error) {
+	var mail models.OutboundMail
+
+	q := builq.New()
+	cols := outboundMailCols()
+	q("SELECT %s FROM outbound_mail", cols)
+	q("WHERE workspace_id = %$ AND message_id = %$", workspaceId, messageId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.OutboundMail{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = th.db.QueryRow(ctx, stmt, workspaceId, messageId).Scan(outboundMailDest(&mail)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.OutboundMail{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.OutboundMail{}, ErrQuery
+	}
+	return mail, nil
+}
@@ -363,3 +363,103 @@ func (wrk *WorkspaceDB) ModifyPostmarkMailServerSettingById(
 	}
 	return setting, nil
 }
+
+func threadFollowUpSettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"is_enabled",
+		"follow_up_after_days",
+		"send_follow_up",
+		"follow_up_template",
+		"resolve_after_days",
+		"created_at",
+		"updated_at",
+	}
+}
+
+// SaveThreadFollowUpSetting inserts or updates the follow-up setting of the workspace.
+func (wrk *WorkspaceDB) SaveThreadFollowUpSetting(
+	ctx context.Context, setting models.ThreadFollowUpSetting) (models.ThreadFollowUpSetting, error) {
+	q := builq.New()
+	cols := threadFollowUpSettingCols()
+
+	insertParams := []any{
+		setting.WorkspaceId, setting.IsEnabled, setting.FollowUpAfterDays, setting.SendFollowUp,
+		setting.FollowUpTemplate, setting.ResolveAfterDays,
+		setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO thread_follow_up_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("is_enabled = EXCLUDED.is_enabled,")
+	q("follow_up_after_days = EXCLUDED.follow_up_after_days,")
+	q("send_follow_up = EXCLUDED.send_follow_up,")
+	q("follow_up_template = EXCLUDED.follow_up_template,")
+	q("resolve_after_days = EXCLUDED.resolve_after_days,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.ThreadFollowUpSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = wrk.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.FollowUpAfterDays, &setting.SendFollowUp,
+		&setting.FollowUpTemplate, &setting.ResolveAfterDays,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.ThreadFollowUpSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.ThreadFollowUpSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (wrk *WorkspaceDB) FetchThreadFollowUpSettingById(
+	ctx context.Context, workspaceId string) (models.ThreadFollowUpSetting, error) {
+	var setting models.ThreadFollowUpSetting
+
+	q := builq.New()
+	cols := threadFollowUpSettingCols()
+
+	q("SELECT %s FROM thread_follow_up_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.ThreadFollowUpSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = wrk.db.QueryRow(ctx, stmt, workspaceId).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.FollowUpAfterDays, &setting.SendFollowUp,
+		&setting.FollowUpTemplate, &setting.ResolveAfterDays,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.ThreadFollowUpSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.ThreadFollowUpSetting{}, ErrQuery
+	}
+	return setting, nil
+}
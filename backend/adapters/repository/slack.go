@@ -0,0 +1,698 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func slackWorkspaceCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"ref",
+		"url",
+		"name",
+		"status",
+		"sync_status",
+		"synced_at",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func slackBotCols() builq.Columns {
+	return builq.Columns{
+		"slack_workspace_ref",
+		"bot_id",
+		"bot_user_ref",
+		"bot_ref",
+		"app_ref",
+		"scope",
+		"access_token",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func slackChannelCols() builq.Columns {
+	return builq.Columns{
+		"slack_workspace_ref",
+		"channel_id",
+		"channel_ref",
+		"is_channel",
+		"is_ext_shared",
+		"is_general",
+		"is_group",
+		"is_im",
+		"is_member",
+		"is_mpim",
+		"is_org_shared",
+		"is_pending_ext_shared",
+		"is_private",
+		"is_shared",
+		"name",
+		"name_normalized",
+		"created",
+		"updated",
+		"status",
+		"is_enabled",
+		"label_id",
+		"synced_at",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func slackMessageLogCols() builq.Columns {
+	return builq.Columns{
+		"message_id",
+		"slack_workspace_ref",
+		"channel_ref",
+		"ts",
+		"thread_ts",
+		"user_ref",
+		"message_type",
+		"created_at",
+		"updated_at",
+	}
+}
+
+// slackChannelDest returns the scan destinations of the Slack channel columns.
+func slackChannelDest(ch *models.SlackChannel) []any {
+	return []any{
+		&ch.SlackWorkspaceRef, &ch.ChannelId, &ch.ChannelRef,
+		&ch.IsChannel, &ch.IsExtShared, &ch.IsGeneral,
+		&ch.IsGroup, &ch.IsIm, &ch.IsMember,
+		&ch.IsMpim, &ch.IsOrgShared, &ch.IsPendingExtShared,
+		&ch.IsPrivate, &ch.IsShared, &ch.Name,
+		&ch.NameNormalized, &ch.Created, &ch.Updated,
+		&ch.Status, &ch.IsEnabled, &ch.LabelId,
+		&ch.SyncedAt, &ch.CreatedAt, &ch.UpdatedAt,
+	}
+}
+
+func (s *SlackDB) SaveSlackInstall(
+	ctx context.Context, slackWorkspace models.SlackWorkspace, bot models.SlackBot,
+) (models.SlackWorkspace, models.SlackBot, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.SlackWorkspace{}, models.SlackBot{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	q := builq.New()
+	cols := slackWorkspaceCols()
+	insertParams := []any{
+		slackWorkspace.WorkspaceId, slackWorkspace.Ref, slackWorkspace.Url,
+		slackWorkspace.Name, slackWorkspace.Status, slackWorkspace.SyncStatus,
+		slackWorkspace.SyncedAt, slackWorkspace.CreatedAt, slackWorkspace.UpdatedAt,
+	}
+
+	q("INSERT INTO slack_workspace (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (ref) DO UPDATE SET")
+	q("workspace_id = EXCLUDED.workspace_id,")
+	q("url = EXCLUDED.url,")
+	q("name = EXCLUDED.name,")
+	q("status = EXCLUDED.status,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackWorkspace{}, models.SlackBot{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
+		&slackWorkspace.WorkspaceId, &slackWorkspace.Ref, &slackWorkspace.Url,
+		&slackWorkspace.Name, &slackWorkspace.Status, &slackWorkspace.SyncStatus,
+		&slackWorkspace.SyncedAt, &slackWorkspace.CreatedAt, &slackWorkspace.UpdatedAt,
+	)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.SlackWorkspace{}, models.SlackBot{}, ErrQuery
+	}
+
+	q = builq.New()
+	cols = slackBotCols()
+	insertParams = []any{
+		bot.SlackWorkspaceRef, bot.BotId, bot.BotUserRef,
+		bot.BotRef, bot.AppRef, bot.Scope,
+		bot.AccessToken, bot.CreatedAt, bot.UpdatedAt,
+	}
+
+	q("INSERT INTO slack_bot (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (slack_workspace_ref) DO UPDATE SET")
+	q("bot_user_ref = EXCLUDED.bot_user_ref,")
+	q("bot_ref = EXCLUDED.bot_ref,")
+	q("app_ref = EXCLUDED.app_ref,")
+	q("scope = EXCLUDED.scope,")
+	q("access_token = EXCLUDED.access_token,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err = q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackWorkspace{}, models.SlackBot{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
+		&bot.SlackWorkspaceRef, &bot.BotId, &bot.BotUserRef,
+		&bot.BotRef, &bot.AppRef, &bot.Scope,
+		&bot.AccessToken, &bot.CreatedAt, &bot.UpdatedAt,
+	)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.SlackWorkspace{}, models.SlackBot{}, ErrQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.SlackWorkspace{}, models.SlackBot{}, ErrTxQuery
+	}
+	return slackWorkspace, bot, nil
+}
+
+func (s *SlackDB) fetchSlackWorkspace(
+	ctx context.Context, column string, value string) (models.SlackWorkspace, error) {
+	var slackWorkspace models.SlackWorkspace
+
+	q := builq.New()
+	cols := slackWorkspaceCols()
+	q("SELECT %s FROM slack_workspace", cols)
+	if column == "ref" {
+		q("WHERE ref = %$", value)
+	} else {
+		q("WHERE workspace_id = %$", value)
+	}
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackWorkspace{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, value).Scan(
+		&slackWorkspace.WorkspaceId, &slackWorkspace.Ref, &slackWorkspace.Url,
+		&slackWorkspace.Name, &slackWorkspace.Status, &slackWorkspace.SyncStatus,
+		&slackWorkspace.SyncedAt, &slackWorkspace.CreatedAt, &slackWorkspace.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.SlackWorkspace{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.SlackWorkspace{}, ErrQuery
+	}
+	return slackWorkspace, nil
+}
+
+func (s *SlackDB) FetchSlackWorkspaceByWorkspaceId(
+	ctx context.Context, workspaceId string) (models.SlackWorkspace, error) {
+	return s.fetchSlackWorkspace(ctx, "workspace_id", workspaceId)
+}
+
+func (s *SlackDB) FetchSlackWorkspaceByRef(ctx context.Context, ref string) (models.SlackWorkspace, error) {
+	return s.fetchSlackWorkspace(ctx, "ref", ref)
+}
+
+func (s *SlackDB) FetchSlackBotByWorkspaceRef(
+	ctx context.Context, slackWorkspaceRef string) (models.SlackBot, error) {
+	var bot models.SlackBot
+
+	q := builq.New()
+	cols := slackBotCols()
+	q("SELECT %s FROM slack_bot", cols)
+	q("WHERE slack_workspace_ref = %$", slackWorkspaceRef)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackBot{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, slackWorkspaceRef).Scan(
+		&bot.SlackWorkspaceRef, &bot.BotId, &bot.BotUserRef,
+		&bot.BotRef, &bot.AppRef, &bot.Scope,
+		&bot.AccessToken, &bot.CreatedAt, &bot.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.SlackBot{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.SlackBot{}, ErrQuery
+	}
+	return bot, nil
+}
+
+func (s *SlackDB) ModifySlackWorkspaceSyncStatus(
+	ctx context.Context, ref string, syncStatus string, syncedAt *time.Time) (models.SlackWorkspace, error) {
+	var slackWorkspace models.SlackWorkspace
+
+	q := builq.New()
+	cols := slackWorkspaceCols()
+	q("UPDATE slack_workspace SET")
+	q("sync_status = %$, synced_at = COALESCE(%$, synced_at), updated_at = NOW()", syncStatus, syncedAt)
+	q("WHERE ref = %$", ref)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackWorkspace{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, syncStatus, syncedAt, ref).Scan(
+		&slackWorkspace.WorkspaceId, &slackWorkspace.Ref, &slackWorkspace.Url,
+		&slackWorkspace.Name, &slackWorkspace.Status, &slackWorkspace.SyncStatus,
+		&slackWorkspace.SyncedAt, &slackWorkspace.CreatedAt, &slackWorkspace.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.SlackWorkspace{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.SlackWorkspace{}, ErrQuery
+	}
+	return slackWorkspace, nil
+}
+
+// FetchSlackWorkspacesDueForSync returns the active Slack workspaces never synced or last synced before.
+func (s *SlackDB) FetchSlackWorkspacesDueForSync(
+	ctx context.Context, syncedBefore time.Time) ([]models.SlackWorkspace, error) {
+	var slackWorkspace models.SlackWorkspace
+	slackWorkspaces := make([]models.SlackWorkspace, 0, 100)
+
+	q := builq.New()
+	cols := slackWorkspaceCols()
+	q("SELECT %s FROM slack_workspace", cols)
+	q("WHERE status = %$", models.SlackWorkspaceStatus{}.Active())
+	q("AND (synced_at IS NULL OR synced_at < %$)", syncedBefore)
+	q("ORDER BY synced_at ASC NULLS FIRST")
+	q("LIMIT 100")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.SlackWorkspace{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := s.db.Query(ctx, stmt, models.SlackWorkspaceStatus{}.Active(), syncedBefore)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&slackWorkspace.WorkspaceId, &slackWorkspace.Ref, &slackWorkspace.Url,
+		&slackWorkspace.Name, &slackWorkspace.Status, &slackWorkspace.SyncStatus,
+		&slackWorkspace.SyncedAt, &slackWorkspace.CreatedAt, &slackWorkspace.UpdatedAt,
+	}, func() error {
+		slackWorkspaces = append(slackWorkspaces, slackWorkspace)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.SlackWorkspace{}, ErrQuery
+	}
+	return slackWorkspaces, nil
+}
+
+func (s *SlackDB) UpsertSlackChannels(
+	ctx context.Context, slackWorkspaceRef string, channels []models.SlackChannel,
+) ([]models.SlackChannel, error) {
+	synced := make([]models.SlackChannel, 0, len(channels))
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return []models.SlackChannel{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	cols := slackChannelCols()
+	channelRefs := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		q := builq.New()
+		insertParams := []any{
+			slackWorkspaceRef, ch.ChannelId, ch.ChannelRef,
+			ch.IsChannel, ch.IsExtShared, ch.IsGeneral,
+			ch.IsGroup, ch.IsIm, ch.IsMember,
+			ch.IsMpim, ch.IsOrgShared, ch.IsPendingExtShared,
+			ch.IsPrivate, ch.IsShared, ch.Name,
+			ch.NameNormalized, ch.Created, ch.Updated,
+			ch.Status, ch.IsEnabled, ch.LabelId,
+			ch.SyncedAt, ch.CreatedAt, ch.UpdatedAt,
+		}
+
+		// The channel mapping is kept as is for the existing channels.
+		q("INSERT INTO slack_channel (%s)", cols)
+		q("VALUES (%+$)", insertParams)
+		q("ON CONFLICT (slack_workspace_ref, channel_ref) DO UPDATE SET")
+		q("is_channel = EXCLUDED.is_channel,")
+		q("is_ext_shared = EXCLUDED.is_ext_shared,")
+		q("is_general = EXCLUDED.is_general,")
+		q("is_group = EXCLUDED.is_group,")
+		q("is_im = EXCLUDED.is_im,")
+		q("is_member = EXCLUDED.is_member,")
+		q("is_mpim = EXCLUDED.is_mpim,")
+		q("is_org_shared = EXCLUDED.is_org_shared,")
+		q("is_pending_ext_shared = EXCLUDED.is_pending_ext_shared,")
+		q("is_private = EXCLUDED.is_private,")
+		q("is_shared = EXCLUDED.is_shared,")
+		q("name = EXCLUDED.name,")
+		q("name_normalized = EXCLUDED.name_normalized,")
+		q("updated = EXCLUDED.updated,")
+		q("status = EXCLUDED.status,")
+		q("synced_at = EXCLUDED.synced_at,")
+		q("updated_at = NOW()")
+		q("RETURNING %s", cols)
+
+		stmt, _, err := q.Build()
+		if err != nil {
+			slog.Error("failed to build query", slog.Any("err", err))
+			return []models.SlackChannel{}, ErrQuery
+		}
+
+		if zyg.DBQueryDebug() {
+			debug := q.DebugBuild()
+			debugQuery(debug)
+		}
+
+		var channel models.SlackChannel
+		err = tx.QueryRow(ctx, stmt, insertParams...).Scan(slackChannelDest(&channel)...)
+		if err != nil {
+			slog.Error("failed to insert query", slog.Any("err", err))
+			return []models.SlackChannel{}, ErrQuery
+		}
+		synced = append(synced, channel)
+		channelRefs = append(channelRefs, ch.ChannelRef)
+	}
+
+	// Archive the channels no longer listed for the bot.
+	stmt := `UPDATE slack_channel SET status = $1, updated_at = NOW()
+		WHERE slack_workspace_ref = $2 AND channel_ref <> ALL($3)`
+	_, err = tx.Exec(ctx, stmt, models.SlackChannelStatus{}.Archived(), slackWorkspaceRef, channelRefs)
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return []models.SlackChannel{}, ErrQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return []models.SlackChannel{}, ErrTxQuery
+	}
+	return synced, nil
+}
+
+func (s *SlackDB) FetchSlackChannelsByWorkspaceRef(
+	ctx context.Context, slackWorkspaceRef string) ([]models.SlackChannel, error) {
+	var channel models.SlackChannel
+	channels := make([]models.SlackChannel, 0, 100)
+
+	q := builq.New()
+	cols := slackChannelCols()
+	q("SELECT %s FROM slack_channel", cols)
+	q("WHERE slack_workspace_ref = %$", slackWorkspaceRef)
+	q("ORDER BY name ASC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.SlackChannel{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := s.db.Query(ctx, stmt, slackWorkspaceRef)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, slackChannelDest(&channel), func() error {
+		channels = append(channels, channel)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.SlackChannel{}, ErrQuery
+	}
+	return channels, nil
+}
+
+func (s *SlackDB) fetchSlackChannel(
+	ctx context.Context, slackWorkspaceRef, column, value string) (models.SlackChannel, error) {
+	var channel models.SlackChannel
+
+	q := builq.New()
+	cols := slackChannelCols()
+	q("SELECT %s FROM slack_channel", cols)
+	if column == "channel_ref" {
+		q("WHERE slack_workspace_ref = %$ AND channel_ref = %$", slackWorkspaceRef, value)
+	} else {
+		q("WHERE slack_workspace_ref = %$ AND channel_id = %$", slackWorkspaceRef, value)
+	}
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackChannel{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, slackWorkspaceRef, value).Scan(slackChannelDest(&channel)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.SlackChannel{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.SlackChannel{}, ErrQuery
+	}
+	return channel, nil
+}
+
+func (s *SlackDB) FetchSlackChannelById(
+	ctx context.Context, slackWorkspaceRef, channelId string) (models.SlackChannel, error) {
+	return s.fetchSlackChannel(ctx, slackWorkspaceRef, "channel_id", channelId)
+}
+
+func (s *SlackDB) FetchSlackChannelByRef(
+	ctx context.Context, slackWorkspaceRef, channelRef string) (models.SlackChannel, error) {
+	return s.fetchSlackChannel(ctx, slackWorkspaceRef, "channel_ref", channelRef)
+}
+
+func (s *SlackDB) ModifySlackChannelMapping(
+	ctx context.Context, channel models.SlackChannel) (models.SlackChannel, error) {
+	q := builq.New()
+	cols := slackChannelCols()
+	q("UPDATE slack_channel SET")
+	q("is_enabled = %$, label_id = %$, updated_at = NOW()", channel.IsEnabled, channel.LabelId)
+	q("WHERE slack_workspace_ref = %$ AND channel_id = %$", channel.SlackWorkspaceRef, channel.ChannelId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackChannel{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(
+		ctx, stmt, channel.IsEnabled, channel.LabelId, channel.SlackWorkspaceRef, channel.ChannelId,
+	).Scan(slackChannelDest(&channel)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.SlackChannel{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.SlackChannel{}, ErrQuery
+	}
+	return channel, nil
+}
+
+func (s *SlackDB) InsertSlackMessageLog(
+	ctx context.Context, messageLog models.SlackMessageLog) (models.SlackMessageLog, error) {
+	q := builq.New()
+	cols := slackMessageLogCols()
+	insertParams := []any{
+		messageLog.MessageId, messageLog.SlackWorkspaceRef, messageLog.ChannelRef,
+		messageLog.Ts, messageLog.ThreadTs, messageLog.UserRef,
+		messageLog.MessageType, messageLog.CreatedAt, messageLog.UpdatedAt,
+	}
+
+	q("INSERT INTO slack_message_log (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&messageLog.MessageId, &messageLog.SlackWorkspaceRef, &messageLog.ChannelRef,
+		&messageLog.Ts, &messageLog.ThreadTs, &messageLog.UserRef,
+		&messageLog.MessageType, &messageLog.CreatedAt, &messageLog.UpdatedAt,
+	)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.SlackMessageLog{}, ErrQuery
+	}
+	return messageLog, nil
+}
+
+func (s *SlackDB) CheckSlackMessageExists(
+	ctx context.Context, slackWorkspaceRef, channelRef, ts string) (bool, error) {
+	var isExist bool
+	stmt := `SELECT EXISTS(
+		SELECT 1 FROM slack_message_log
+		WHERE slack_workspace_ref = $1 AND channel_ref = $2 AND ts = $3
+	)`
+
+	err := s.db.QueryRow(ctx, stmt, slackWorkspaceRef, channelRef, ts).Scan(&isExist)
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return false, ErrQuery
+	}
+	return isExist, nil
+}
+
+func (s *SlackDB) FetchSlackThreadIdByTs(
+	ctx context.Context, slackWorkspaceRef, channelRef, threadTs string) (string, error) {
+	var threadId string
+
+	q := builq.New()
+	q("SELECT m.thread_id FROM slack_message_log sml")
+	q("INNER JOIN message m ON m.message_id = sml.message_id")
+	q("WHERE sml.slack_workspace_ref = %$ AND sml.channel_ref = %$", slackWorkspaceRef, channelRef)
+	q("AND sml.thread_ts = %$", threadTs)
+	q("ORDER BY sml.created_at ASC")
+	q("LIMIT 1")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return "", ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, slackWorkspaceRef, channelRef, threadTs).Scan(&threadId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return "", ErrQuery
+	}
+	return threadId, nil
+}
+
+func (s *SlackDB) FetchSlackRootMessageLog(ctx context.Context, threadId string) (models.SlackMessageLog, error) {
+	var messageLog models.SlackMessageLog
+
+	q := builq.New()
+	q("SELECT %s FROM slack_message_log sml", builq.Columns{
+		"sml.message_id", "sml.slack_workspace_ref", "sml.channel_ref",
+		"sml.ts", "sml.thread_ts", "sml.user_ref",
+		"sml.message_type", "sml.created_at", "sml.updated_at",
+	})
+	q("INNER JOIN message m ON m.message_id = sml.message_id")
+	q("WHERE m.thread_id = %$", threadId)
+	q("ORDER BY sml.created_at ASC")
+	q("LIMIT 1")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SlackMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, threadId).Scan(
+		&messageLog.MessageId, &messageLog.SlackWorkspaceRef, &messageLog.ChannelRef,
+		&messageLog.Ts, &messageLog.ThreadTs, &messageLog.UserRef,
+		&messageLog.MessageType, &messageLog.CreatedAt, &messageLog.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.SlackMessageLog{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.SlackMessageLog{}, ErrQuery
+	}
+	return messageLog, nil
+}
@@ -0,0 +1,431 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func smsSettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"is_enabled",
+		"provider",
+		"phone_number",
+		"account_sid",
+		"auth_token",
+		"conversation_window_hours",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func smsMessageLogCols() builq.Columns {
+	return builq.Columns{
+		"message_id",
+		"provider",
+		"provider_message_id",
+		"message_type",
+		"from_phone",
+		"to_phone",
+		"status",
+		"error_code",
+		"error_message",
+		"payload",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func (s *SMSDB) SaveSMSSetting(ctx context.Context, setting models.SMSSetting) (models.SMSSetting, error) {
+	q := builq.New()
+	cols := smsSettingCols()
+	insertParams := []any{
+		setting.WorkspaceId, setting.IsEnabled, setting.Provider,
+		setting.PhoneNumber, setting.AccountSid, setting.AuthToken,
+		setting.ConversationWindowHours, setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO sms_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("is_enabled = EXCLUDED.is_enabled,")
+	q("provider = EXCLUDED.provider,")
+	q("phone_number = EXCLUDED.phone_number,")
+	q("account_sid = EXCLUDED.account_sid,")
+	q("auth_token = EXCLUDED.auth_token,")
+	q("conversation_window_hours = EXCLUDED.conversation_window_hours,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SMSSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.Provider,
+		&setting.PhoneNumber, &setting.AccountSid, &setting.AuthToken,
+		&setting.ConversationWindowHours, &setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.SMSSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.SMSSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (s *SMSDB) FetchSMSSettingById(ctx context.Context, workspaceId string) (models.SMSSetting, error) {
+	var setting models.SMSSetting
+
+	q := builq.New()
+	cols := smsSettingCols()
+	q("SELECT %s FROM sms_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SMSSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, workspaceId).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.Provider,
+		&setting.PhoneNumber, &setting.AccountSid, &setting.AuthToken,
+		&setting.ConversationWindowHours, &setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.SMSSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.SMSSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func InsertSMSMessageLogTx(ctx context.Context, tx pgx.Tx, messageLog *models.SMSMessageLog) error {
+	q := builq.New()
+	cols := smsMessageLogCols()
+	insertParams := []any{
+		messageLog.MessageId, messageLog.Provider, messageLog.ProviderMessageId,
+		messageLog.MessageType, messageLog.FromPhone, messageLog.ToPhone,
+		messageLog.Status, messageLog.ErrorCode, messageLog.ErrorMessage,
+		messageLog.Payload, messageLog.CreatedAt, messageLog.UpdatedAt,
+	}
+
+	q("INSERT INTO sms_message_log (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	_, err = tx.Exec(ctx, stmt, insertParams...)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
+// InsertSMSInboundThreadMessage inserts the new Thread with the Customer's inbound SMS.
+func (s *SMSDB) InsertSMSInboundThreadMessage(
+	ctx context.Context, thread *models.Thread, message *models.Message, messageLog *models.SMSMessageLog,
+) (*models.Thread, *models.Message, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return &models.Thread{}, &models.Message{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	// Insert workflows:
+	// 1. insert thread with inbound event
+	// 2. insert message linked to thread
+	// 3. insert sms message log
+	thread, err = InsertInboundThreadTx(ctx, tx, thread)
+	if err != nil {
+		slog.Error("failed to insert inbound thread", slog.Any("err", err))
+		return &models.Thread{}, &models.Message{}, ErrQuery
+	}
+
+	message, err = InsertThreadMessageTx(ctx, tx, message)
+	if err != nil {
+		slog.Error("failed to insert thread message", slog.Any("err", err))
+		return &models.Thread{}, &models.Message{}, ErrQuery
+	}
+
+	messageLog.MessageId = message.MessageId
+	if err := InsertSMSMessageLogTx(ctx, tx, messageLog); err != nil {
+		slog.Error("failed to insert sms message log", slog.Any("err", err))
+		return &models.Thread{}, &models.Message{}, ErrQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return &models.Thread{}, &models.Message{}, ErrTxQuery
+	}
+	return thread, message, nil
+}
+
+// AppendSMSInboundThreadMessage appends the Customer's inbound SMS to the existing Thread,
+// persisting the inbound event, the Thread status and the SMS message log.
+func (s *SMSDB) AppendSMSInboundThreadMessage(
+	ctx context.Context, thread *models.Thread, message *models.Message, messageLog *models.SMSMessageLog,
+) (*models.Message, error) {
+	if thread.InboundMessage == nil {
+		slog.Error("thread inbound message cannot be empty", slog.Any("threadId", thread.ThreadId))
+		return &models.Message{}, ErrQuery
+	}
+	return s.appendSMSThreadMessage(ctx, thread, message, messageLog, func(tx pgx.Tx) error {
+		if err := UpsertInboundMessageTx(ctx, tx, thread.ThreadId, thread.InboundMessage); err != nil {
+			return err
+		}
+		// Customer replied, cancel the pending follow-up if any.
+		return CancelThreadFollowUpTx(ctx, tx, thread.ThreadId)
+	})
+}
+
+// AppendSMSOutboundThreadMessage appends the Member's outbound SMS to the existing Thread,
+// persisting the outbound event, the Thread status and the SMS message log.
+func (s *SMSDB) AppendSMSOutboundThreadMessage(
+	ctx context.Context, thread *models.Thread, message *models.Message, messageLog *models.SMSMessageLog,
+) (*models.Message, error) {
+	if thread.OutboundMessage == nil {
+		slog.Error("thread outbound message cannot be empty", slog.Any("threadId", thread.ThreadId))
+		return &models.Message{}, ErrQuery
+	}
+	return s.appendSMSThreadMessage(ctx, thread, message, messageLog, func(tx pgx.Tx) error {
+		return UpsertOutboundMessageTx(ctx, tx, thread.ThreadId, thread.OutboundMessage)
+	})
+}
+
+func (s *SMSDB) appendSMSThreadMessage(
+	ctx context.Context, thread *models.Thread, message *models.Message, messageLog *models.SMSMessageLog,
+	upsertEvent func(tx pgx.Tx) error,
+) (*models.Message, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return &models.Message{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	// Append workflows:
+	// 1. upsert inbound or outbound event linked to thread
+	// 2. update thread status as per stage transition
+	// 3. insert message linked to thread
+	// 4. insert sms message log
+	if err := upsertEvent(tx); err != nil {
+		slog.Error("failed to upsert thread message event", slog.Any("err", err))
+		return &models.Message{}, ErrQuery
+	}
+
+	if err := ModifyThreadStatusTx(ctx, tx, thread); err != nil {
+		slog.Error("failed to update thread status", slog.Any("err", err))
+		return &models.Message{}, ErrQuery
+	}
+
+	message, err = InsertThreadMessageTx(ctx, tx, message)
+	if err != nil {
+		slog.Error("failed to insert thread message", slog.Any("err", err))
+		return &models.Message{}, ErrQuery
+	}
+
+	messageLog.MessageId = message.MessageId
+	if err := InsertSMSMessageLogTx(ctx, tx, messageLog); err != nil {
+		slog.Error("failed to insert sms message log", slog.Any("err", err))
+		return &models.Message{}, ErrQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return &models.Message{}, ErrTxQuery
+	}
+	return message, nil
+}
+
+// FetchRecentCustomerSMSThreadId returns the Customer's SMS Thread with the most recent message since.
+func (s *SMSDB) FetchRecentCustomerSMSThreadId(
+	ctx context.Context, workspaceId, customerId string, since time.Time) (string, error) {
+	var threadId string
+
+	q := builq.New()
+	q("SELECT th.thread_id FROM thread th")
+	q("INNER JOIN message m ON m.thread_id = th.thread_id")
+	q("WHERE th.workspace_id = %$ AND th.customer_id = %$", workspaceId, customerId)
+	q("AND th.channel = %$ AND m.created_at >= %$", models.ThreadChannel{}.SMS(), since)
+	q("ORDER BY m.created_at DESC")
+	q("LIMIT 1")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return "", ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, workspaceId, customerId, models.ThreadChannel{}.SMS(), since).Scan(&threadId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return "", ErrQuery
+	}
+	return threadId, nil
+}
+
+func (s *SMSDB) CheckSMSInboundMessageExists(ctx context.Context, provider, providerMessageId string) (bool, error) {
+	var isExist bool
+	stmt := `SELECT EXISTS(
+		SELECT 1 FROM sms_message_log
+		WHERE provider = $1 AND provider_message_id = $2 AND message_type = 'inbound'
+	)`
+
+	err := s.db.QueryRow(ctx, stmt, provider, providerMessageId).Scan(&isExist)
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return false, ErrQuery
+	}
+	return isExist, nil
+}
+
+// ModifySMSMessageLogStatus updates the delivery status of the outbound SMS.
+// Final statuses are not overwritten by the out of order status callbacks.
+func (s *SMSDB) ModifySMSMessageLogStatus(
+	ctx context.Context, provider string, update models.SMSStatusUpdate) (models.SMSMessageLog, error) {
+	var messageLog models.SMSMessageLog
+
+	q := builq.New()
+	cols := smsMessageLogCols()
+	finals := models.SMSStatus{}.Finals()
+
+	q("UPDATE sms_message_log SET")
+	q("status = %$, error_code = COALESCE(%$, error_code),", update.Status, update.ErrorCode)
+	q("error_message = COALESCE(%$, error_message), updated_at = NOW()", update.ErrorMessage)
+	q("WHERE provider = %$ AND provider_message_id = %$", provider, update.ProviderMessageId)
+	q("AND message_type = 'outbound' AND status NOT IN (%+$)", finals)
+	q("RETURNING %s", cols)
+
+	// Params include the expanded final statuses.
+	stmt, params, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SMSMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = s.db.QueryRow(ctx, stmt, params...).Scan(
+		&messageLog.MessageId, &messageLog.Provider, &messageLog.ProviderMessageId,
+		&messageLog.MessageType, &messageLog.FromPhone, &messageLog.ToPhone,
+		&messageLog.Status, &messageLog.ErrorCode, &messageLog.ErrorMessage,
+		&messageLog.Payload, &messageLog.CreatedAt, &messageLog.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.SMSMessageLog{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.SMSMessageLog{}, ErrQuery
+	}
+	return messageLog, nil
+}
+
+// FetchSMSMessageLogsByThreadId returns the SMS message logs of the Thread messages.
+func (s *SMSDB) FetchSMSMessageLogsByThreadId(ctx context.Context, threadId string) ([]models.SMSMessageLog, error) {
+	var messageLog models.SMSMessageLog
+	logs := make([]models.SMSMessageLog, 0, 100)
+
+	q := builq.New()
+	q("SELECT %s FROM sms_message_log sml", builq.Columns{
+		"sml.message_id", "sml.provider", "sml.provider_message_id",
+		"sml.message_type", "sml.from_phone", "sml.to_phone",
+		"sml.status", "sml.error_code", "sml.error_message",
+		"sml.payload", "sml.created_at", "sml.updated_at",
+	})
+	q("INNER JOIN message m ON m.message_id = sml.message_id")
+	q("WHERE m.thread_id = %$", threadId)
+	q("ORDER BY sml.created_at DESC")
+	q("LIMIT 100")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.SMSMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := s.db.Query(ctx, stmt, threadId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&messageLog.MessageId, &messageLog.Provider, &messageLog.ProviderMessageId,
+		&messageLog.MessageType, &messageLog.FromPhone, &messageLog.ToPhone,
+		&messageLog.Status, &messageLog.ErrorCode, &messageLog.ErrorMessage,
+		&messageLog.Payload, &messageLog.CreatedAt, &messageLog.UpdatedAt,
+	}, func() error {
+		logs = append(logs, messageLog)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.SMSMessageLog{}, ErrQuery
+	}
+	return logs, nil
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func spamSettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"is_enabled",
+		"threshold",
+		"chat_rate_limit",
+		"chat_rate_window_secs",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func spamSenderRuleJoinedCols() builq.Columns {
+	return builq.Columns{
+		"sr.rule_id",
+		"sr.workspace_id",
+		"sr.kind",
+		"sr.value",
+		"sr.action",
+		"m.member_id",
+		"m.name",
+		"sr.created_at",
+		"sr.updated_at",
+	}
+}
+
+func (sp *SpamDB) SaveSpamSetting(ctx context.Context, setting models.SpamSetting) (models.SpamSetting, error) {
+	q := builq.New()
+	cols := spamSettingCols()
+	insertParams := []any{
+		setting.WorkspaceId, setting.IsEnabled, setting.Threshold,
+		setting.ChatRateLimit, setting.ChatRateWindowSecs,
+		setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO spam_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("is_enabled = EXCLUDED.is_enabled,")
+	q("threshold = EXCLUDED.threshold,")
+	q("chat_rate_limit = EXCLUDED.chat_rate_limit,")
+	q("chat_rate_window_secs = EXCLUDED.chat_rate_window_secs,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SpamSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = sp.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.Threshold,
+		&setting.ChatRateLimit, &setting.ChatRateWindowSecs,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.SpamSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.SpamSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (sp *SpamDB) FetchSpamSettingById(ctx context.Context, workspaceId string) (models.SpamSetting, error) {
+	var setting models.SpamSetting
+
+	q := builq.New()
+	cols := spamSettingCols()
+	q("SELECT %s FROM spam_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SpamSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = sp.db.QueryRow(ctx, stmt, workspaceId).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.Threshold,
+		&setting.ChatRateLimit, &setting.ChatRateWindowSecs,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.SpamSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.SpamSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+// UpsertSpamSenderRule inserts the sender rule, or updates the action of the existing rule
+// for the same kind and value.
+func (sp *SpamDB) UpsertSpamSenderRule(
+	ctx context.Context, rule models.SpamSenderRule) (models.SpamSenderRule, error) {
+	q := builq.New()
+	insertParams := []any{
+		rule.RuleId, rule.WorkspaceId, rule.Kind, rule.Value, rule.Action, rule.CreatedBy.MemberId,
+		rule.CreatedAt, rule.UpdatedAt,
+	}
+
+	q("WITH ins AS (")
+	q("INSERT INTO spam_sender_rule (rule_id, workspace_id, kind, value, action, created_by_id, created_at, updated_at)")
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id, kind, value) DO UPDATE SET")
+	q("action = EXCLUDED.action, created_by_id = EXCLUDED.created_by_id, updated_at = NOW()")
+	q("RETURNING *")
+	q(")")
+	q("SELECT %s FROM ins sr", spamSenderRuleJoinedCols())
+	q("INNER JOIN member m ON sr.created_by_id = m.member_id")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.SpamSenderRule{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = sp.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&rule.RuleId, &rule.WorkspaceId, &rule.Kind, &rule.Value, &rule.Action,
+		&rule.CreatedBy.MemberId, &rule.CreatedBy.Name,
+		&rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.SpamSenderRule{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.SpamSenderRule{}, ErrQuery
+	}
+	return rule, nil
+}
+
+func (sp *SpamDB) FetchSpamSenderRulesByWorkspaceId(
+	ctx context.Context, workspaceId string) ([]models.SpamSenderRule, error) {
+	var rule models.SpamSenderRule
+	rules := make([]models.SpamSenderRule, 0, 100)
+
+	q := builq.New()
+	q("SELECT %s FROM spam_sender_rule sr", spamSenderRuleJoinedCols())
+	q("INNER JOIN member m ON sr.created_by_id = m.member_id")
+	q("WHERE sr.workspace_id = %$", workspaceId)
+	q("ORDER BY sr.created_at DESC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.SpamSenderRule{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := sp.db.Query(ctx, stmt, workspaceId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&rule.RuleId, &rule.WorkspaceId, &rule.Kind, &rule.Value, &rule.Action,
+		&rule.CreatedBy.MemberId, &rule.CreatedBy.Name,
+		&rule.CreatedAt, &rule.UpdatedAt,
+	}, func() error {
+		rules = append(rules, rule)
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.SpamSenderRule{}, ErrQuery
+	}
+	return rules, nil
+}
+
+// FetchMatchingSpamSenderRules returns the workspace sender rules matching any of the
+// specified kind and value pairs.
+func (sp *SpamDB) FetchMatchingSpamSenderRules(
+	ctx context.Context, workspaceId string, matches map[string]string) ([]models.SpamSenderRule, error) {
+	var rule models.SpamSenderRule
+	rules := make([]models.SpamSenderRule, 0, len(matches))
+	if len(matches) == 0 {
+		return rules, nil
+	}
+
+	params := []any{workspaceId}
+	q := builq.New()
+	q("SELECT %s FROM spam_sender_rule sr", spamSenderRuleJoinedCols())
+	q("INNER JOIN member m ON sr.created_by_id = m.member_id")
+	q("WHERE sr.workspace_id = %$ AND (", workspaceId)
+	first := true
+	for kind, value := range matches {
+		if !first {
+			q("OR")
+		}
+		first = false
+		q("(sr.kind = %$ AND sr.value = %$)", kind, value)
+		params = append(params, kind, value)
+	}
+	q(")")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.SpamSenderRule{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := sp.db.Query(ctx, stmt, params...)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&rule.RuleId, &rule.WorkspaceId, &rule.Kind, &rule.Value, &rule.Action,
+		&rule.CreatedBy.MemberId, &rule.CreatedBy.Name,
+		&rule.CreatedAt, &rule.UpdatedAt,
+	}, func() error {
+		rules = append(rules, rule)
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.SpamSenderRule{}, ErrQuery
+	}
+	return rules, nil
+}
+
+func (sp *SpamDB) DeleteSpamSenderRuleById(ctx context.Context, workspaceId, ruleId string) error {
+	stmt := `DELETE FROM spam_sender_rule WHERE workspace_id = $1 AND rule_id = $2`
+	tag, err := sp.db.Exec(ctx, stmt, workspaceId, ruleId)
+	if err != nil {
+		slog.Error("failed to delete query", slog.Any("err", err))
+		return ErrQuery
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrEmpty
+	}
+	return nil
+}
+
+// CountCustomerMessagesSince counts the messages sent by the customer since the specified time.
+func (sp *SpamDB) CountCustomerMessagesSince(
+	ctx context.Context, customerId string, since time.Time) (int, error) {
+	var count int
+	stmt := `SELECT COUNT(*) FROM message WHERE customer_id = $1 AND created_at >= $2`
+	err := sp.db.QueryRow(ctx, stmt, customerId, since).Scan(&count)
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return 0, ErrQuery
+	}
+	return count, nil
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/cristalhq/builq"
 	"github.com/jackc/pgx/v5"
@@ -148,14 +149,25 @@ func threadMessageCols() builq.Columns {
 		"text_body",
 		"markdown_body",
 		"html_body",
+		"original_text_body",
 		"customer_id", // FK Nullable to customer
 		"member_id",   // FK Nullable to member
 		"channel",
+		"kind",
+		"is_automated",
 		"created_at",
 		"updated_at",
 	}
 }
 
+// messageKind returns the message kind, defaults to the message exchanged with the Customer.
+func messageKind(kind string) string {
+	if kind == "" {
+		return models.MessageKind{}.Message()
+	}
+	return kind
+}
+
 func threadMessageJoinedCols() builq.Columns {
 	return builq.Columns{
 		"msg.message_id",
@@ -163,43 +175,62 @@ func threadMessageJoinedCols() builq.Columns {
 		"msg.text_body",
 		"msg.markdown_body",
 		"msg.html_body",
+		"msg.original_text_body",
 		"c.customer_id",
 		"c.name",
 		"m.member_id",
 		"m.name",
 		"msg.channel",
+		"msg.kind",
+		"msg.is_automated",
 		"msg.created_at",
 		"msg.updated_at",
 	}
 }
 
-// Deprecated
-//func postmarkInboundMessageCols() builq.Columns {
-//	return builq.Columns{
-//		"message_id", // PK
-//		"payload",
-//		"postmark_message_id",
-//		"mail_message_id",
-//		"reply_mail_message_id",
-//		"created_at",
-//		"updated_at",
-//	}
-//}
+// messageDeliveryJoinedCols returns the outbound message log columns joined as `cml`.
+// messageDeliveryJoinedCols returns the delivery status from the outbound message log,
+// otherwise from the outbound mail queued for delivery.
+func messageDeliveryJoinedCols() builq.Columns {
+	return builq.Columns{
+		"COALESCE(cml.status, om.status)",
+		"COALESCE(cml.has_error, om.error IS NOT NULL)",
+		"COALESCE(cml.error_message, om.error)",
+		"COALESCE(cml.updated_at, om.updated_at)",
+	}
+}
+
+// newMessageDelivery returns the message delivery status from the joined outbound message log
+// or the outbound mail, nil if the message is not tracked with the channel provider.
+func newMessageDelivery(
+	status sql.NullString, hasError sql.NullBool, errorMessage sql.NullString, updatedAt sql.NullTime,
+) *models.MessageDelivery {
+	if !status.Valid {
+		return nil
+	}
+	return &models.MessageDelivery{
+		Status:       status.String,
+		HasError:     hasError.Bool,
+		ErrorMessage: errorMessage.String,
+		UpdatedAt:    updatedAt.Time,
+	}
+}
 
-func postmarkMessageLogCols() builq.Columns {
+func channelMessageLogCols() builq.Columns {
 	return builq.Columns{
 		"message_id", // PK
+		"channel",
+		"message_type",
+		"external_id",
+		"external_ref",
+		"reply_ref",
 		"payload",
-		"postmark_message_id",
-		"mail_message_id",
-		"reply_mail_message_id",
+		"status",
 		"has_error",
-		"submitted_at",
 		"error_code",
-		"postmark_message",
-		"message_event",
+		"error_message",
 		"acknowledged",
-		"message_type",
+		"submitted_at",
 		"created_at",
 		"updated_at",
 	}
@@ -217,6 +248,7 @@ func messageAttachmentCols() builq.Columns {
 		"has_error",
 		"error",
 		"md5_hash",
+		"content_id",
 		"created_at",
 		"updated_at",
 	}
@@ -505,11 +537,13 @@ func (th *ThreadDB) InsertInboundThreadMessage(
 	messageCols = threadMessageCols()
 	insertParams = []any{
 		message.MessageId, message.ThreadId, message.TextBody, message.MarkdownBody, message.HTMLBody,
-		customerId, memberId, message.Channel, message.CreatedAt, message.UpdatedAt,
+		message.OriginalTextBody,
+		customerId, memberId, message.Channel, messageKind(message.Kind), message.IsAutomated,
+		message.CreatedAt, message.UpdatedAt,
 	}
 
 	insertB.Addf("INSERT INTO message (%s)", messageCols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
+	insertB.Addf("VALUES (%+$)", insertParams)
 	insertB.Addf("RETURNING %s", messageCols)
 
 	insertQuery, _, err = insertB.Build()
@@ -540,9 +574,10 @@ func (th *ThreadDB) InsertInboundThreadMessage(
 
 	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
 		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
+		&message.OriginalTextBody,
 		&customerId, &customerName,
 		&memberId, &memberName,
-		&message.Channel, &message.CreatedAt, &message.UpdatedAt,
+		&message.Channel, &message.Kind, &message.IsAutomated, &message.CreatedAt, &message.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		slog.Error("no rows returned", slog.Any("err", err))
@@ -566,6 +601,32 @@ func (th *ThreadDB) InsertInboundThreadMessage(
 		}
 	}
 
+	// Insert the channel message log if any.
+	if inbound.Log != nil {
+		inbound.Log.MessageId = message.MessageId
+		_, err = InsertChannelMessageLogTx(ctx, tx, inbound.Log)
+		if err != nil {
+			return models.Thread{}, models.Message{}, err
+		}
+	}
+
+	// Insert the WhatsApp message log if any.
+	if inbound.WhatsAppLog != nil {
+		inbound.WhatsAppLog.MessageId = message.MessageId
+		err = InsertWhatsAppMessageLogTx(ctx, tx, inbound.WhatsAppLog)
+		if err != nil {
+			return models.Thread{}, models.Message{}, err
+		}
+	}
+
+	// Insert the message participants if any.
+	if len(inbound.Participants) > 0 {
+		err = InsertMessageParticipantsTx(ctx, tx, message.MessageId, inbound.Participants)
+		if err != nil {
+			return models.Thread{}, models.Message{}, err
+		}
+	}
+
 	// commit transaction
 	err = tx.Commit(ctx)
 	if err != nil {
@@ -837,11 +898,13 @@ func InsertThreadMessageTx(
 	insertCols := threadMessageCols()
 	insertParams := []any{
 		message.MessageId, message.ThreadId, message.TextBody, message.MarkdownBody, message.HTMLBody,
-		customerId, memberId, message.Channel, message.CreatedAt, message.UpdatedAt,
+		message.OriginalTextBody,
+		customerId, memberId, message.Channel, messageKind(message.Kind), message.IsAutomated,
+		message.CreatedAt, message.UpdatedAt,
 	}
 
 	insertB.Addf("INSERT INTO message (%s)", insertCols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
+	insertB.Addf("VALUES (%+$)", insertParams)
 	insertB.Addf("RETURNING %s", insertCols)
 
 	insertQuery, _, err := insertB.Build()
@@ -872,9 +935,10 @@ func InsertThreadMessageTx(
 
 	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
 		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
+		&message.OriginalTextBody,
 		&customerId, &customerName,
 		&memberId, &memberName,
-		&message.Channel, &message.CreatedAt, &message.UpdatedAt,
+		&message.Channel, &message.Kind, &message.IsAutomated, &message.CreatedAt, &message.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		slog.Error("no rows returned", slog.Any("err", err))
@@ -900,27 +964,29 @@ func InsertThreadMessageTx(
 	return message, nil
 }
 
-func InsertPostmarkMessageLogTx(
-	ctx context.Context, tx pgx.Tx, messageId string, messageLog *models.PostmarkMessageLog,
-) (*models.PostmarkMessageLog, error) {
+// InsertChannelMessageLogTx inserts the channel message log of the persisted message within the transaction.
+func InsertChannelMessageLogTx(
+	ctx context.Context, tx pgx.Tx, messageLog *models.ChannelMessageLog,
+) (*models.ChannelMessageLog, error) {
 	q := builq.New()
-	logCols := postmarkMessageLogCols()
+	logCols := channelMessageLogCols()
 	insertParams := []any{
-		messageId, messageLog.Payload, messageLog.PostmarkMessageId,
-		messageLog.MailMessageId, messageLog.ReplyMailMessageId,
-		messageLog.HasError, messageLog.SubmittedAt, messageLog.ErrorCode,
-		messageLog.PostmarkMessage, messageLog.MessageEvent, messageLog.Acknowledged,
-		messageLog.MessageType, messageLog.CreatedAt, messageLog.UpdatedAt,
+		messageLog.MessageId, messageLog.Channel, messageLog.MessageType,
+		messageLog.ExternalId, messageLog.ExternalRef, messageLog.ReplyRef,
+		messageLog.Payload, messageLog.Status,
+		messageLog.HasError, messageLog.ErrorCode, messageLog.ErrorMessage,
+		messageLog.Acknowledged, messageLog.SubmittedAt,
+		messageLog.CreatedAt, messageLog.UpdatedAt,
 	}
 
-	q("INSERT INTO postmark_message_log (%s)", logCols)
-	q("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
+	q("INSERT INTO channel_message_log (%s)", logCols)
+	q("VALUES (%+$)", insertParams)
 	q("RETURNING %s", logCols)
 
 	stmt, _, err := q.Build()
 	if err != nil {
 		slog.Error("failed to build query", slog.Any("err", err))
-		return &models.PostmarkMessageLog{}, ErrQuery
+		return &models.ChannelMessageLog{}, ErrQuery
 	}
 
 	if zyg.DBQueryDebug() {
@@ -929,71 +995,229 @@ func InsertPostmarkMessageLogTx(
 	}
 
 	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
-		&messageLog.MessageId, &messageLog.Payload, &messageLog.PostmarkMessageId,
-		&messageLog.MailMessageId, &messageLog.ReplyMailMessageId,
-		&messageLog.HasError, &messageLog.SubmittedAt, &messageLog.ErrorCode,
-		&messageLog.PostmarkMessage, &messageLog.MessageEvent, &messageLog.Acknowledged,
-		&messageLog.MessageType, &messageLog.CreatedAt, &messageLog.UpdatedAt,
+		&messageLog.MessageId, &messageLog.Channel, &messageLog.MessageType,
+		&messageLog.ExternalId, &messageLog.ExternalRef, &messageLog.ReplyRef,
+		&messageLog.Payload, &messageLog.Status,
+		&messageLog.HasError, &messageLog.ErrorCode, &messageLog.ErrorMessage,
+		&messageLog.Acknowledged, &messageLog.SubmittedAt,
+		&messageLog.CreatedAt, &messageLog.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		slog.Error("no rows returned", slog.Any("err", err))
-		return &models.PostmarkMessageLog{}, ErrEmpty
+		return &models.ChannelMessageLog{}, ErrEmpty
 	}
 	if err != nil {
 		slog.Error("failed to insert query", slog.Any("err", err))
-		return &models.PostmarkMessageLog{}, ErrQuery
+		return &models.ChannelMessageLog{}, ErrQuery
 	}
 	return messageLog, nil
 }
 
-func (th *ThreadDB) InsertPostmarkInboundThreadMessage(
-	ctx context.Context, thread *models.Thread, postmarkMessageLog *models.PostmarkMessageLog,
-	message *models.Message,
-) (*models.Thread, *models.Message, error) {
-	tx, err := th.db.Begin(ctx)
+// ModifyThreadStatusTx persists the Thread stage, status and replied flag within the transaction.
+// Used by the append paths to persist the automatic stage transitions.
+func ModifyThreadStatusTx(ctx context.Context, tx pgx.Tx, thread *models.Thread) error {
+	q := builq.New()
+	params := []any{
+		thread.ThreadStatus.Stage, thread.ThreadStatus.Status,
+		thread.ThreadStatus.StatusChangedAt, thread.ThreadStatus.StatusChangedBy.MemberId,
+		thread.Replied, thread.ThreadId,
+	}
+	q("UPDATE thread SET")
+	q("stage = %$, status = %$, status_changed_at = %$, status_changed_by_id = %$,", params[:4]...)
+	q("replied = %$, updated_at = NOW()", thread.Replied)
+	q("WHERE thread_id = %$", thread.ThreadId)
+
+	stmt, _, err := q.Build()
 	if err != nil {
-		slog.Error("failed to begin transaction", slog.Any("err", err))
-		return &models.Thread{}, &models.Message{}, ErrTxQuery
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
 	}
 
-	defer func(tx pgx.Tx, ctx context.Context) {
-		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
-			slog.Error("failed to rollback transaction", slog.Any("err", err))
-		}
-	}(tx, ctx)
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
 
-	// Insert workflows:
-	// 1. insert thread with inbound event
-	// 2. insert message linked to thread
-	// 3. insert postmark message log
+	_, err = tx.Exec(ctx, stmt, params...)
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
+// UpsertInboundMessageTx upserts the Thread inbound message within the transaction,
+// linking it to the Thread if newly created.
+func UpsertInboundMessageTx(
+	ctx context.Context, tx pgx.Tx, threadId string, inboundEvent *models.InboundMessage) error {
+	var insertB builq.Builder
+	cols := inboundMessageCols()
+	insertParams := []any{
+		inboundEvent.MessageId, inboundEvent.Customer.CustomerId,
+		inboundEvent.PreviewText, inboundEvent.FirstSeqId, inboundEvent.LastSeqId,
+		inboundEvent.CreatedAt, inboundEvent.UpdatedAt,
+	}
+
+	// Build the upsert query to insert thread inbound message
+	insertB.Addf("INSERT INTO inbound_message (%s)", cols)
+	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$)", insertParams...)
+	insertB.Addf("ON CONFLICT (message_id)")
+	insertB.Addf("DO UPDATE")
+	insertB.Addf("SET")
+	insertB.Addf("preview_text = EXCLUDED.preview_text,")
+	insertB.Addf("last_seq_id = EXCLUDED.last_seq_id,")
+	insertB.Addf("updated_at = EXCLUDED.updated_at")
+	insertB.Addf("RETURNING %s, (xmax = 0) AS is_created", cols)
 
-	// Insert inbound thread
-	thread, err = InsertInboundThreadTx(ctx, tx, thread)
+	insertQuery, _, err := insertB.Build()
 	if err != nil {
-		slog.Error("failed to insert inbound thread", slog.Any("err", err))
-		return &models.Thread{}, &models.Message{}, ErrQuery
+		slog.Error("failed to build upsert query", slog.Any("error", err))
+		return ErrQuery
 	}
 
-	// Insert thread message
-	message, err = InsertThreadMessageTx(ctx, tx, message)
+	// Build the select query
+	q := builq.New()
+	joinedCols := inboundMessageJoinedCols()
+	q("WITH ups AS (%s)", insertQuery)
+	q("SELECT %s, im.is_created", joinedCols)
+	q("FROM ups im")
+	q("INNER JOIN customer c ON im.customer_id = c.customer_id")
+
+	stmt, _, err := q.Build()
 	if err != nil {
-		slog.Error("failed to insert thread message", slog.Any("err", err))
-		return &models.Thread{}, &models.Message{}, ErrQuery
+		slog.Error("failed to build query", slog.Any("error", err))
+		return ErrQuery
 	}
 
-	postmarkMessageLog, err = InsertPostmarkMessageLogTx(ctx, tx, message.MessageId, postmarkMessageLog)
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	var isCreated bool
+	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
+		&inboundEvent.MessageId,
+		&inboundEvent.Customer.CustomerId, &inboundEvent.Customer.Name,
+		&inboundEvent.PreviewText,
+		&inboundEvent.FirstSeqId, &inboundEvent.LastSeqId,
+		&inboundEvent.CreatedAt, &inboundEvent.UpdatedAt,
+		&isCreated,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return ErrEmpty
+	}
 	if err != nil {
-		slog.Error("failed to insert postmark message log", slog.Any("err", err))
-		return &models.Thread{}, &models.Message{}, ErrQuery
+		slog.Error("failed to query", slog.Any("err", err))
+		return ErrQuery
+	}
+	// Check if the inbound message is created
+	if isCreated {
+		// Link inbound message to the thread
+		q = builq.New()
+		updates := []any{inboundEvent.MessageId, threadId}
+		q("UPDATE thread SET")
+		q("inbound_message_id = %$, updated_at = NOW() WHERE thread_id = %$", updates...)
+
+		stmt, _, err = q.Build()
+		if err != nil {
+			slog.Error("failed to build query", slog.Any("error", err))
+			return ErrQuery
+		}
+		_, err = tx.Exec(ctx, stmt, updates...)
+		if err != nil {
+			slog.Error("failed to update query", slog.Any("err", err))
+			return ErrQuery
+		}
 	}
+	return nil
+}
 
-	// commit transaction
-	err = tx.Commit(ctx)
+// UpsertOutboundMessageTx upserts the Thread outbound message within the transaction,
+// linking it to the Thread if newly created.
+func UpsertOutboundMessageTx(
+	ctx context.Context, tx pgx.Tx, threadId string, outboundEvent *models.OutboundMessage) error {
+	var insertB builq.Builder
+	cols := outboundMessageCols()
+	insertParams := []any{
+		outboundEvent.MessageId, outboundEvent.Member.MemberId,
+		outboundEvent.PreviewText, outboundEvent.FirstSeqId, outboundEvent.LastSeqId,
+		outboundEvent.CreatedAt, outboundEvent.UpdatedAt,
+	}
+
+	// Build the upsert query to insert thread outbound message
+	insertB.Addf("INSERT INTO outbound_message (%s)", cols)
+	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$)", insertParams...)
+	insertB.Addf("ON CONFLICT (message_id)")
+	insertB.Addf("DO UPDATE")
+	insertB.Addf("SET")
+	insertB.Addf("preview_text = EXCLUDED.preview_text,")
+	insertB.Addf("last_seq_id = EXCLUDED.last_seq_id,")
+	insertB.Addf("updated_at = EXCLUDED.updated_at")
+	insertB.Addf("RETURNING %s, (xmax = 0) AS is_created", cols)
+
+	insertQuery, _, err := insertB.Build()
 	if err != nil {
-		slog.Error("failed to commit query", slog.Any("err", err))
-		return &models.Thread{}, &models.Message{}, ErrTxQuery
+		slog.Error("failed to build upsert query", slog.Any("error", err))
+		return ErrQuery
+	}
+
+	// Build the select query
+	q := builq.New()
+	joinedCols := outboundMessageJoinedCols()
+	q("WITH ups AS (%s)", insertQuery)
+	q("SELECT %s, om.is_created", joinedCols)
+	q("FROM ups om")
+	q("INNER JOIN member m ON om.member_id = m.member_id")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("error", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	var isCreated bool
+	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
+		&outboundEvent.MessageId,
+		&outboundEvent.Member.MemberId, &outboundEvent.Member.Name,
+		&outboundEvent.PreviewText,
+		&outboundEvent.FirstSeqId, &outboundEvent.LastSeqId,
+		&outboundEvent.CreatedAt, &outboundEvent.UpdatedAt,
+		&isCreated,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return ErrQuery
+	}
+	// Check if the outbound message is created
+	if isCreated {
+		// Link outbound message to the thread
+		q = builq.New()
+		updates := []any{outboundEvent.MessageId, threadId}
+		q("UPDATE thread SET")
+		q("outbound_message_id = %$, updated_at = NOW() WHERE thread_id = %$", updates...)
+
+		stmt, _, err = q.Build()
+		if err != nil {
+			slog.Error("failed to build query", slog.Any("error", err))
+			return ErrQuery
+		}
+		_, err = tx.Exec(ctx, stmt, updates...)
+		if err != nil {
+			slog.Error("failed to update query", slog.Any("err", err))
+			return ErrQuery
+		}
 	}
-	return thread, message, nil
+	return nil
 }
 
 func (th *ThreadDB) ModifyThreadById(
@@ -1457,13 +1681,16 @@ func (th *ThreadDB) FetchThreadsByCustomerId(
 	return threads, nil
 }
 
-func (th *ThreadDB) FetchThreadsByWorkspaceId(
-	ctx context.Context, workspaceId string, channel *string, role *string) ([]models.Thread, error) {
+// FetchRecentThreadsByCustomerId returns the Customer's Threads of the channel updated since,
+// most recently updated first.
+func (th *ThreadDB) FetchRecentThreadsByCustomerId(
+	ctx context.Context, workspaceId string, customerId string, channel string, since time.Time,
+) ([]models.Thread, error) {
 	var thread models.Thread
-	limit := 100
+	limit := 50
 	threads := make([]models.Thread, 0, limit)
 
-	params := []any{workspaceId}
+	params := []any{workspaceId, customerId, channel, since}
 	cols := threadJoinedCols()
 	q := builq.New()
 	q("SELECT %s FROM %s", cols, "thread th")
@@ -1477,20 +1704,11 @@ func (th *ThreadDB) FetchThreadsByWorkspaceId(
 	q("INNER JOIN member mc ON th.created_by_id = mc.member_id")
 	q("INNER JOIN member mu ON th.updated_by_id = mu.member_id")
 
-	q("WHERE th.workspace_id = %$", workspaceId)
-	if channel != nil {
-		q("AND th.channel = %$", *channel)
-		params = append(params, *channel)
-	}
-	if role != nil {
-		q("AND c.role = %$", *role)
-		params = append(params, *role)
-	}
-	q("AND c.role <> %$", models.Customer{}.Visitor())
-	params = append(params, models.Customer{}.Visitor())
+	q("WHERE th.workspace_id = %$ AND th.customer_id = %$", workspaceId, customerId)
+	q("AND th.channel = %$ AND th.updated_at >= %$", channel, since)
 
-	// Sort by earliest created threads.
-	q("ORDER BY th.created_at ASC")
+	// Sort by recently updated threads.
+	q("ORDER BY th.updated_at DESC")
 	q("LIMIT %d", limit)
 
 	stmt, _, err := q.Build()
@@ -1603,19 +1821,19 @@ func (th *ThreadDB) FetchThreadsByWorkspaceId(
 	})
 
 	if err != nil {
-		slog.Error("failed to query", slog.Any("err", err))
+		slog.Error("failed to scan", slog.Any("err", err))
 		return []models.Thread{}, ErrQuery
 	}
 	return threads, nil
 }
 
-func (th *ThreadDB) FetchThreadsByAssignedMemberId(
-	ctx context.Context, memberId string, channel *string, role *string) ([]models.Thread, error) {
+func (th *ThreadDB) FetchThreadsByWorkspaceId(
+	ctx context.Context, workspaceId string, channel *string, role *string) ([]models.Thread, error) {
 	var thread models.Thread
 	limit := 100
 	threads := make([]models.Thread, 0, limit)
 
-	params := []any{memberId}
+	params := []any{workspaceId}
 	cols := threadJoinedCols()
 	q := builq.New()
 	q("SELECT %s FROM %s", cols, "thread th")
@@ -1629,7 +1847,7 @@ func (th *ThreadDB) FetchThreadsByAssignedMemberId(
 	q("INNER JOIN member mc ON th.created_by_id = mc.member_id")
 	q("INNER JOIN member mu ON th.updated_by_id = mu.member_id")
 
-	q("WHERE th.assignee_id = %$", memberId)
+	q("WHERE th.workspace_id = %$", workspaceId)
 	if channel != nil {
 		q("AND th.channel = %$", *channel)
 		params = append(params, *channel)
@@ -1738,7 +1956,6 @@ func (th *ThreadDB) FetchThreadsByAssignedMemberId(
 				MemberId: outboundMemberId.String,
 				Name:     outboundMemberName.String,
 			}
-
 			thread.OutboundMessage = &models.OutboundMessage{
 				MessageId:   outboundMessageId.String,
 				Member:      member,
@@ -1756,19 +1973,19 @@ func (th *ThreadDB) FetchThreadsByAssignedMemberId(
 	})
 
 	if err != nil {
-		slog.Error("failed to query", slog.Any("error", err))
+		slog.Error("failed to query", slog.Any("err", err))
 		return []models.Thread{}, ErrQuery
 	}
 	return threads, nil
 }
 
-func (th *ThreadDB) FetchThreadsByMemberUnassigned(
-	ctx context.Context, workspaceId string, channel *string, role *string) ([]models.Thread, error) {
+func (th *ThreadDB) FetchThreadsByAssignedMemberId(
+	ctx context.Context, memberId string, channel *string, role *string) ([]models.Thread, error) {
 	var thread models.Thread
 	limit := 100
 	threads := make([]models.Thread, 0, limit)
 
-	params := []any{workspaceId}
+	params := []any{memberId}
 	cols := threadJoinedCols()
 	q := builq.New()
 	q("SELECT %s FROM %s", cols, "thread th")
@@ -1782,8 +1999,7 @@ func (th *ThreadDB) FetchThreadsByMemberUnassigned(
 	q("INNER JOIN member mc ON th.created_by_id = mc.member_id")
 	q("INNER JOIN member mu ON th.updated_by_id = mu.member_id")
 
-	q("WHERE th.workspace_id = %$", workspaceId)
-	q("AND th.assignee_id IS NULL")
+	q("WHERE th.assignee_id = %$", memberId)
 	if channel != nil {
 		q("AND th.channel = %$", *channel)
 		params = append(params, *channel)
@@ -1892,6 +2108,7 @@ func (th *ThreadDB) FetchThreadsByMemberUnassigned(
 				MemberId: outboundMemberId.String,
 				Name:     outboundMemberName.String,
 			}
+
 			thread.OutboundMessage = &models.OutboundMessage{
 				MessageId:   outboundMessageId.String,
 				Member:      member,
@@ -1915,13 +2132,13 @@ func (th *ThreadDB) FetchThreadsByMemberUnassigned(
 	return threads, nil
 }
 
-func (th *ThreadDB) FetchThreadsByLabelId(
-	ctx context.Context, labelId string, channel *string, role *string) ([]models.Thread, error) {
+func (th *ThreadDB) FetchThreadsByMemberUnassigned(
+	ctx context.Context, workspaceId string, channel *string, role *string) ([]models.Thread, error) {
 	var thread models.Thread
 	limit := 100
 	threads := make([]models.Thread, 0, limit)
 
-	params := []any{labelId}
+	params := []any{workspaceId}
 	cols := threadJoinedCols()
 	q := builq.New()
 	q("SELECT %s FROM %s", cols, "thread th")
@@ -1934,9 +2151,9 @@ func (th *ThreadDB) FetchThreadsByLabelId(
 	q("LEFT OUTER JOIN member oubm ON oub.member_id = oubm.member_id")
 	q("INNER JOIN member mc ON th.created_by_id = mc.member_id")
 	q("INNER JOIN member mu ON th.updated_by_id = mu.member_id")
-	q("INNER JOIN thread_label tl ON th.thread_id = tl.thread_id")
 
-	q("WHERE tl.label_id = %$", labelId)
+	q("WHERE th.workspace_id = %$", workspaceId)
+	q("AND th.assignee_id IS NULL")
 	if channel != nil {
 		q("AND th.channel = %$", *channel)
 		params = append(params, *channel)
@@ -2068,13 +2285,166 @@ func (th *ThreadDB) FetchThreadsByLabelId(
 	return threads, nil
 }
 
-func (th *ThreadDB) CheckThreadInWorkspaceExists(
-	ctx context.Context, workspaceId string, threadId string) (bool, error) {
-	var isExist bool
-	stmt := `SELECT EXISTS(
-		SELECT 1 FROM thread
-		WHERE workspace_id = $1 AND thread_id= $2
-	)`
+func (th *ThreadDB) FetchThreadsByLabelId(
+	ctx context.Context, labelId string, channel *string, role *string) ([]models.Thread, error) {
+	var thread models.Thread
+	limit := 100
+	threads := make([]models.Thread, 0, limit)
+
+	params := []any{labelId}
+	cols := threadJoinedCols()
+	q := builq.New()
+	q("SELECT %s FROM %s", cols, "thread th")
+	q("INNER JOIN customer c ON th.customer_id = c.customer_id")
+	q("LEFT OUTER JOIN member am ON th.assignee_id = am.member_id")
+	q("INNER JOIN member scm ON th.status_changed_by_id = scm.member_id")
+	q("LEFT OUTER JOIN inbound_message inb ON th.inbound_message_id = inb.message_id")
+	q("LEFT OUTER JOIN outbound_message oub ON th.outbound_message_id = oub.message_id")
+	q("LEFT OUTER JOIN customer inbc ON inb.customer_id = inbc.customer_id")
+	q("LEFT OUTER JOIN member oubm ON oub.member_id = oubm.member_id")
+	q("INNER JOIN member mc ON th.created_by_id = mc.member_id")
+	q("INNER JOIN member mu ON th.updated_by_id = mu.member_id")
+	q("INNER JOIN thread_label tl ON th.thread_id = tl.thread_id")
+
+	q("WHERE tl.label_id = %$", labelId)
+	if channel != nil {
+		q("AND th.channel = %$", *channel)
+		params = append(params, *channel)
+	}
+	if role != nil {
+		q("AND c.role = %$", *role)
+		params = append(params, *role)
+	}
+	q("AND c.role <> %$", models.Customer{}.Visitor())
+	params = append(params, models.Customer{}.Visitor())
+
+	// Sort by earliest created threads.
+	q("ORDER BY th.created_at ASC")
+	q("LIMIT %d", limit)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.Thread{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	var (
+		assignedMemberId    sql.NullString
+		assignedMemberName  sql.NullString
+		assignedAt          sql.NullTime
+		inboundMessageId    sql.NullString
+		inboundCustomerId   sql.NullString
+		inboundCustomerName sql.NullString
+		inboundPreviewText  sql.NullString
+		inboundFirstSeqId   sql.NullString
+		inboundLastSeqId    sql.NullString
+		inboundCreatedAt    sql.NullTime
+		inboundUpdatedAt    sql.NullTime
+		outboundMessageId   sql.NullString
+		outboundMemberId    sql.NullString
+		outboundMemberName  sql.NullString
+		outboundPreviewText sql.NullString
+		outboundFirstSeqId  sql.NullString
+		outboundLastSeqId   sql.NullString
+		outboundCreatedAt   sql.NullTime
+		outboundUpdatedAt   sql.NullTime
+	)
+
+	rows, _ := th.db.Query(ctx, stmt, params...)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&thread.ThreadId, &thread.WorkspaceId, &thread.Customer.CustomerId, &thread.Customer.Name,
+		&assignedMemberId, &assignedMemberName, &assignedAt,
+		&thread.Title, &thread.Description,
+		&thread.ThreadStatus.Status,
+		&thread.ThreadStatus.StatusChangedAt,
+		&thread.ThreadStatus.StatusChangedBy.MemberId, &thread.ThreadStatus.StatusChangedBy.Name,
+		&thread.ThreadStatus.Stage,
+		&thread.Replied, &thread.Priority, &thread.Channel,
+		&inboundMessageId, &inboundCustomerId, &inboundCustomerName,
+		&inboundPreviewText, &inboundFirstSeqId, &inboundLastSeqId,
+		&inboundCreatedAt, &inboundUpdatedAt,
+		&outboundMessageId, &outboundMemberId, &outboundMemberName,
+		&outboundPreviewText, &outboundFirstSeqId, &outboundLastSeqId,
+		&outboundCreatedAt, &outboundUpdatedAt,
+		&thread.CreatedBy.MemberId, &thread.CreatedBy.Name,
+		&thread.UpdatedBy.MemberId, &thread.UpdatedBy.Name,
+		&thread.CreatedAt, &thread.UpdatedAt,
+	}, func() error {
+		// Sets the assigned member if a valid assigned member exists,
+		// otherwise clears the assigned member.
+		if assignedMemberId.Valid {
+			memberActor := models.MemberActor{
+				MemberId: assignedMemberId.String,
+				Name:     assignedMemberName.String,
+			}
+			thread.AssignMember(memberActor, assignedAt.Time)
+		} else {
+			thread.ClearAssignedMember()
+		}
+		// Sets the inbound message an if valid inbound message exists,
+		// otherwise clears the inbound message.
+		if inboundMessageId.Valid {
+			customer := models.CustomerActor{
+				CustomerId: inboundCustomerId.String,
+				Name:       inboundCustomerName.String,
+			}
+			thread.InboundMessage = &models.InboundMessage{
+				MessageId:   inboundMessageId.String,
+				Customer:    customer,
+				PreviewText: inboundPreviewText.String,
+				FirstSeqId:  inboundFirstSeqId.String,
+				LastSeqId:   inboundLastSeqId.String,
+				CreatedAt:   inboundCreatedAt.Time,
+				UpdatedAt:   inboundUpdatedAt.Time,
+			}
+		} else {
+			thread.ClearInboundMessage()
+		}
+		// Sets the outbound message if a valid outbound message exists,
+		// otherwise clears the outbound message.
+		if outboundMessageId.Valid {
+			member := models.MemberActor{
+				MemberId: outboundMemberId.String,
+				Name:     outboundMemberName.String,
+			}
+			thread.OutboundMessage = &models.OutboundMessage{
+				MessageId:   outboundMessageId.String,
+				Member:      member,
+				PreviewText: outboundPreviewText.String,
+				FirstSeqId:  outboundFirstSeqId.String,
+				LastSeqId:   outboundLastSeqId.String,
+				CreatedAt:   outboundCreatedAt.Time,
+				UpdatedAt:   outboundUpdatedAt.Time,
+			}
+		} else {
+			thread.ClearOutboundMessage()
+		}
+		threads = append(threads, thread)
+		return nil
+	})
+
+	if err != nil {
+		slog.Error("failed to query", slog.Any("error", err))
+		return []models.Thread{}, ErrQuery
+	}
+	return threads, nil
+}
+
+func (th *ThreadDB) CheckThreadInWorkspaceExists(
+	ctx context.Context, workspaceId string, threadId string) (bool, error) {
+	var isExist bool
+	stmt := `SELECT EXISTS(
+		SELECT 1 FROM thread
+		WHERE workspace_id = $1 AND thread_id= $2
+	)`
 
 	err := th.db.QueryRow(ctx, stmt, workspaceId, threadId).Scan(&isExist)
 	if err != nil {
@@ -2222,6 +2592,7 @@ func (th *ThreadDB) AppendInboundThreadMessage(
 
 	// upsert thread linked inbound message
 	// based on upsert(created) flag insert to thread
+	// update thread status as per stage transition
 	// insert message
 
 	thread := inbound.Thread
@@ -2232,192 +2603,84 @@ func (th *ThreadDB) AppendInboundThreadMessage(
 		return models.Message{}, ErrQuery
 	}
 
-	inboundMessage := thread.InboundMessage
-
-	var insertB builq.Builder
-	cols := inboundMessageCols()
-	insertParams := []any{
-		inboundMessage.MessageId, inboundMessage.Customer.CustomerId,
-		inboundMessage.PreviewText, inboundMessage.FirstSeqId, inboundMessage.LastSeqId,
-		inboundMessage.CreatedAt, inboundMessage.UpdatedAt,
-	}
-
-	// Build the upsert query to insert thread inbound message
-	insertB.Addf("INSERT INTO inbound_message (%s)", cols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$)", insertParams...)
-	insertB.Addf("ON CONFLICT (message_id)")
-	insertB.Addf("DO UPDATE")
-	insertB.Addf("SET")
-	insertB.Addf("preview_text = EXCLUDED.preview_text,")
-	insertB.Addf("last_seq_id = EXCLUDED.last_seq_id,")
-	insertB.Addf("updated_at = EXCLUDED.updated_at")
-	insertB.Addf("RETURNING %s, (xmax = 0) AS is_created", cols)
-
-	insertQuery, _, err := insertB.Build()
+	// Upsert thread linked inbound message.
+	err = UpsertInboundMessageTx(ctx, tx, thread.ThreadId, thread.InboundMessage)
 	if err != nil {
-		slog.Error("failed to build upsert query", slog.Any("error", err))
-		return models.Message{}, ErrQuery
+		return models.Message{}, err
 	}
 
-	// Build the select query
-	q := builq.New()
-	joinedCols := inboundMessageJoinedCols()
-	q("WITH ups AS (%s)", insertQuery)
-	q("SELECT %s, im.is_created", joinedCols)
-	q("FROM ups im")
-	q("INNER JOIN customer c ON im.customer_id = c.customer_id")
-
-	stmt, _, err := q.Build()
+	// Customer replied, cancel the pending follow-up if any.
+	err = CancelThreadFollowUpTx(ctx, tx, thread.ThreadId)
 	if err != nil {
-		slog.Error("failed to build query", slog.Any("error", err))
-		return models.Message{}, ErrQuery
+		return models.Message{}, err
 	}
 
-	if zyg.DBQueryDebug() {
-		debug := q.DebugBuild()
-		debugQuery(debug)
+	// Persist the thread stage transition.
+	err = ModifyThreadStatusTx(ctx, tx, thread)
+	if err != nil {
+		return models.Message{}, err
 	}
 
-	var isCreated bool
-	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
-		&inboundMessage.MessageId,
-		&inboundMessage.Customer.CustomerId, &inboundMessage.Customer.Name,
-		&inboundMessage.PreviewText,
-		&inboundMessage.FirstSeqId, &inboundMessage.LastSeqId,
-		&inboundMessage.CreatedAt, &inboundMessage.UpdatedAt,
-		&isCreated,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		slog.Error("no rows returned", slog.Any("err", err))
-		return models.Message{}, ErrEmpty
-	}
+	// Insert thread message
+	message, err = InsertThreadMessageTx(ctx, tx, message)
 	if err != nil {
-		slog.Error("failed to query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
+		return models.Message{}, err
 	}
-	// Check if the inbound message is create or updated
-	if isCreated {
-		// Link inbound message to the thread
-		q = builq.New()
-		updates := []any{inboundMessage.MessageId, thread.ThreadId}
-		q("UPDATE thread SET")
-		q("inbound_message_id = %$, updated_at = NOW() WHERE thread_id = %$", updates...)
 
-		stmt, _, err = q.Build()
+	// Insert the channel message log if any.
+	if inbound.Log != nil {
+		inbound.Log.MessageId = message.MessageId
+		_, err = InsertChannelMessageLogTx(ctx, tx, inbound.Log)
 		if err != nil {
-			slog.Error("failed to build query", slog.Any("error", err))
-			return models.Message{}, ErrQuery
+			return models.Message{}, err
 		}
-		_, err = tx.Exec(ctx, stmt, updates...)
+	}
+
+	// Insert the WhatsApp message log if any.
+	if inbound.WhatsAppLog != nil {
+		inbound.WhatsAppLog.MessageId = message.MessageId
+		err = InsertWhatsAppMessageLogTx(ctx, tx, inbound.WhatsAppLog)
 		if err != nil {
-			slog.Error("failed to update query", slog.Any("err", err))
-			return models.Message{}, ErrQuery
+			return models.Message{}, err
 		}
 	}
 
-	// hold db nullables.
-	var customerId, customerName sql.NullString
-	var memberId, memberName sql.NullString
-	if message.Customer != nil {
-		customerId = sql.NullString{String: message.Customer.CustomerId, Valid: true}
-	}
-	if message.Member != nil {
-		memberId = sql.NullString{String: message.Member.MemberId, Valid: true}
+	// Insert the message participants if any.
+	if len(inbound.Participants) > 0 {
+		err = InsertMessageParticipantsTx(ctx, tx, message.MessageId, inbound.Participants)
+		if err != nil {
+			return models.Message{}, err
+		}
 	}
 
-	// Persist the message with referenced thread ID
-	insertB = builq.Builder{}
-	cols = threadMessageCols()
-	insertParams = []any{
-		message.MessageId, message.ThreadId, message.TextBody, message.MarkdownBody, message.HTMLBody,
-		customerId, memberId, message.Channel, message.CreatedAt, message.UpdatedAt,
+	// commit transaction
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.Message{}, ErrTxQuery
 	}
+	return *message, nil
+}
 
-	insertB.Addf("INSERT INTO message (%s)", cols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
-	insertB.Addf("RETURNING %s", cols)
-
-	insertQuery, _, err = insertB.Build()
+// AppendOutboundThreadMessage inserts a member chat into the database.
+func (th *ThreadDB) AppendOutboundThreadMessage(
+	ctx context.Context, outbound models.ThreadMessage,
+) (models.Message, error) {
+	tx, err := th.db.Begin(ctx)
 	if err != nil {
-		slog.Error("failed to build insert query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.Message{}, ErrTxQuery
 	}
 
-	// Build the select query required after insert
-	q = builq.New()
-	joinedCols = threadMessageJoinedCols()
-
-	q("WITH ins AS (%s)", insertQuery)
-	q("SELECT %s FROM %s", joinedCols, "ins msg")
-	q("LEFT OUTER JOIN customer c ON msg.customer_id = c.customer_id")
-	q("LEFT OUTER JOIN member m ON msg.member_id = m.member_id")
-
-	stmt, _, err = q.Build()
-	if err != nil {
-		slog.Error("failed to build query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
-	}
-
-	if zyg.DBQueryDebug() {
-		debug := q.DebugBuild()
-		debugQuery(debug)
-	}
-
-	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
-		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
-		&customerId, &customerName,
-		&memberId, &memberName,
-		&message.Channel, &message.CreatedAt, &message.UpdatedAt,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		slog.Error("no rows returned", slog.Any("err", err))
-		return models.Message{}, ErrEmpty
-	}
-	if err != nil {
-		slog.Error("failed to insert query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
-	}
-
-	if customerId.Valid {
-		message.Customer = &models.CustomerActor{
-			CustomerId: customerId.String,
-			Name:       customerName.String,
-		}
-	}
-	if memberId.Valid {
-		message.Member = &models.MemberActor{
-			MemberId: memberId.String,
-			Name:     memberName.String,
-		}
-	}
-
-	// commit transaction
-	err = tx.Commit(ctx)
-	if err != nil {
-		slog.Error("failed to commit query", slog.Any("err", err))
-		return models.Message{}, ErrTxQuery
-	}
-	return *message, nil
-}
-
-// AppendOutboundThreadMessage inserts a member chat into the database.
-func (th *ThreadDB) AppendOutboundThreadMessage(
-	ctx context.Context, outbound models.ThreadMessage,
-) (models.Message, error) {
-	tx, err := th.db.Begin(ctx)
-	if err != nil {
-		slog.Error("failed to begin transaction", slog.Any("err", err))
-		return models.Message{}, ErrTxQuery
-	}
-
-	defer func(tx pgx.Tx, ctx context.Context) {
-		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
-			slog.Error("failed to rollback transaction", slog.Any("err", err))
-		}
-	}(tx, ctx)
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
 
 	// upsert thread linked outbound message
 	// based on upsert(created) flag insert to thread
+	// update thread status as per stage transition
 	// insert message
 
 	thread := outbound.Thread
@@ -2428,162 +2691,64 @@ func (th *ThreadDB) AppendOutboundThreadMessage(
 		return models.Message{}, ErrEmpty
 	}
 
-	outboundMessage := thread.OutboundMessage
-
-	var insertB builq.Builder
-	cols := outboundMessageCols()
-	insertParams := []any{
-		outboundMessage.MessageId, outboundMessage.Member.MemberId,
-		outboundMessage.PreviewText, outboundMessage.FirstSeqId, outboundMessage.LastSeqId,
-		outboundMessage.CreatedAt, outboundMessage.UpdatedAt,
-	}
-
-	// Build the upsert query to insert thread outbound message
-	insertB.Addf("INSERT INTO outbound_message (%s)", cols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$)", insertParams...)
-	insertB.Addf("ON CONFLICT (message_id)")
-	insertB.Addf("DO UPDATE")
-	insertB.Addf("SET")
-	insertB.Addf("preview_text = EXCLUDED.preview_text,")
-	insertB.Addf("last_seq_id = EXCLUDED.last_seq_id,")
-	insertB.Addf("updated_at = EXCLUDED.updated_at")
-	insertB.Addf("RETURNING %s, (xmax = 0) AS is_created", cols)
-
-	insertQuery, _, err := insertB.Build()
+	// Upsert thread linked outbound message.
+	err = UpsertOutboundMessageTx(ctx, tx, thread.ThreadId, thread.OutboundMessage)
 	if err != nil {
-		slog.Error("failed to build insert query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
+		return models.Message{}, err
 	}
 
-	// Build the select query
-	q := builq.New()
-	joinedCols := outboundMessageJoinedCols()
-	q("WITH ups AS (%s)", insertQuery)
-	q("SELECT %s, om.is_created", joinedCols)
-	q("FROM ups om")
-	q("INNER JOIN member m ON om.member_id = m.member_id")
-
-	stmt, _, err := q.Build()
+	// Persist the thread stage transition.
+	err = ModifyThreadStatusTx(ctx, tx, thread)
 	if err != nil {
-		slog.Error("failed to build query", slog.Any("error", err))
-		return models.Message{}, ErrQuery
-	}
-
-	if zyg.DBQueryDebug() {
-		debug := q.DebugBuild()
-		debugQuery(debug)
+		return models.Message{}, err
 	}
 
-	var isCreated bool
-	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
-		&outboundMessage.MessageId,
-		&outboundMessage.Member.MemberId, &outboundMessage.Member.Name,
-		&outboundMessage.PreviewText,
-		&outboundMessage.FirstSeqId, &outboundMessage.LastSeqId,
-		&outboundMessage.CreatedAt, &outboundMessage.UpdatedAt,
-		&isCreated,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		slog.Error("no rows returned", slog.Any("err", err))
-		return models.Message{}, ErrEmpty
-	}
+	// Insert thread message
+	message, err = InsertThreadMessageTx(ctx, tx, message)
 	if err != nil {
-		slog.Error("failed to query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
+		return models.Message{}, err
 	}
-	// Check if the inbound message is create or updated
-	if isCreated {
-		// Link inbound message to the thread
-		q = builq.New()
-		updates := []any{outboundMessage.MessageId, thread.ThreadId}
-		q("UPDATE thread SET")
-		q("outbound_message_id = %$, updated_at = NOW() WHERE thread_id = %$", updates...)
 
-		stmt, _, err = q.Build()
-		if err != nil {
-			slog.Error("failed to build query", slog.Any("error", err))
-			return models.Message{}, ErrQuery
-		}
-		_, err = tx.Exec(ctx, stmt, updates...)
+	// Insert the channel message log if any.
+	if outbound.Log != nil {
+		outbound.Log.MessageId = message.MessageId
+		_, err = InsertChannelMessageLogTx(ctx, tx, outbound.Log)
 		if err != nil {
-			slog.Error("failed to update query", slog.Any("err", err))
-			return models.Message{}, ErrQuery
+			return models.Message{}, err
 		}
 	}
 
-	// hold db nullables.
-	var customerId, customerName sql.NullString
-	var memberId, memberName sql.NullString
-	if message.Customer != nil {
-		customerId = sql.NullString{String: message.Customer.CustomerId, Valid: true}
-	}
-	if message.Member != nil {
-		memberId = sql.NullString{String: message.Member.MemberId, Valid: true}
-	}
-
-	// Persist the message with referenced thread ID
-	insertB = builq.Builder{}
-	cols = threadMessageCols()
-	insertParams = []any{
-		message.MessageId, message.ThreadId, message.TextBody, message.MarkdownBody, message.HTMLBody,
-		customerId, memberId, message.Channel, message.CreatedAt, message.UpdatedAt,
-	}
-
-	insertB.Addf("INSERT INTO message (%s)", cols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$, %$, %$, %$)", insertParams...)
-	insertB.Addf("RETURNING %s", cols)
-
-	insertQuery, _, err = insertB.Build()
-	if err != nil {
-		slog.Error("failed to build insert query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
-	}
-
-	// Build the select query required after insert
-	q = builq.New()
-	joinedCols = threadMessageJoinedCols()
-
-	q("WITH ins AS (%s)", insertQuery)
-	q("SELECT %s FROM %s", joinedCols, "ins msg")
-	q("LEFT OUTER JOIN customer c ON msg.customer_id = c.customer_id")
-	q("LEFT OUTER JOIN member m ON msg.member_id = m.member_id")
-
-	stmt, _, err = q.Build()
-	if err != nil {
-		slog.Error("failed to build query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
-	}
-
-	if zyg.DBQueryDebug() {
-		debug := q.DebugBuild()
-		debugQuery(debug)
+	// Insert the WhatsApp message log if any.
+	if outbound.WhatsAppLog != nil {
+		outbound.WhatsAppLog.MessageId = message.MessageId
+		err = InsertWhatsAppMessageLogTx(ctx, tx, outbound.WhatsAppLog)
+		if err != nil {
+			return models.Message{}, err
+		}
 	}
 
-	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
-		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
-		&customerId, &customerName,
-		&memberId, &memberName,
-		&message.Channel, &message.CreatedAt, &message.UpdatedAt,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		slog.Error("no rows returned", slog.Any("err", err))
-		return models.Message{}, ErrEmpty
-	}
-	if err != nil {
-		slog.Error("failed to insert query", slog.Any("err", err))
-		return models.Message{}, ErrQuery
+	// Insert the message participants if any.
+	if len(outbound.Participants) > 0 {
+		err = InsertMessageParticipantsTx(ctx, tx, message.MessageId, outbound.Participants)
+		if err != nil {
+			return models.Message{}, err
+		}
 	}
 
-	if customerId.Valid {
-		message.Customer = &models.CustomerActor{
-			CustomerId: customerId.String,
-			Name:       customerName.String,
+	// Link the Member's uploads as the message attachments if any.
+	if len(outbound.Attachments) > 0 {
+		err = LinkAttachmentUploadsTx(ctx, tx, message.MessageId, outbound.Attachments)
+		if err != nil {
+			return models.Message{}, err
 		}
 	}
-	if memberId.Valid {
-		message.Member = &models.MemberActor{
-			MemberId: memberId.String,
-			Name:     memberName.String,
+
+	// Insert the outbound mail queued for delivery if any.
+	if outbound.Outbound != nil {
+		outbound.Outbound.MessageId = message.MessageId
+		err = InsertOutboundMailTx(ctx, tx, outbound.Outbound)
+		if err != nil {
+			return models.Message{}, err
 		}
 	}
 
@@ -2596,6 +2761,7 @@ func (th *ThreadDB) AppendOutboundThreadMessage(
 	return *message, nil
 }
 
+// FetchMessagesByThreadId returns the messages exchanged with the Customer, without the internal messages.
 func (th *ThreadDB) FetchMessagesByThreadId(
 	ctx context.Context, threadId string) ([]models.Message, error) {
 	var message models.Message
@@ -2603,10 +2769,13 @@ func (th *ThreadDB) FetchMessagesByThreadId(
 
 	q := builq.New()
 	messagesJoinedCols := threadMessageJoinedCols()
-	q("SELECT %s FROM message msg", messagesJoinedCols)
+	q("SELECT %s, %s FROM message msg", messagesJoinedCols, messageDeliveryJoinedCols())
 	q("LEFT OUTER JOIN customer c ON msg.customer_id = c.customer_id")
 	q("LEFT OUTER JOIN member m ON msg.member_id = m.member_id")
-	q("WHERE msg.thread_id = %$", threadId)
+	q("LEFT OUTER JOIN channel_message_log cml")
+	q("ON cml.message_id = msg.message_id AND cml.message_type = 'outbound'")
+	q("LEFT OUTER JOIN outbound_mail om ON om.message_id = msg.message_id")
+	q("WHERE msg.thread_id = %$ AND msg.kind = %$", threadId, models.MessageKind{}.Message())
 
 	q("ORDER BY msg.created_at ASC")
 	q("LIMIT 100")
@@ -2624,18 +2793,25 @@ func (th *ThreadDB) FetchMessagesByThreadId(
 
 	var customerId, customerName sql.NullString
 	var memberId, memberName sql.NullString
+	var deliveryStatus, deliveryErrorMessage sql.NullString
+	var deliveryHasError sql.NullBool
+	var deliveryUpdatedAt sql.NullTime
 
-	rows, _ := th.db.Query(ctx, stmt, threadId)
+	rows, _ := th.db.Query(ctx, stmt, threadId, models.MessageKind{}.Message())
 
 	defer rows.Close()
 
 	_, err = pgx.ForEachRow(rows, []any{
 		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
+		&message.OriginalTextBody,
 		&customerId, &customerName,
 		&memberId, &memberName,
-		&message.Channel,
+		&message.Channel, &message.Kind, &message.IsAutomated,
 		&message.CreatedAt, &message.UpdatedAt,
+		&deliveryStatus, &deliveryHasError, &deliveryErrorMessage, &deliveryUpdatedAt,
 	}, func() error {
+		message.Delivery = newMessageDelivery(
+			deliveryStatus, deliveryHasError, deliveryErrorMessage, deliveryUpdatedAt)
 		if customerId.Valid {
 			message.Customer = &models.CustomerActor{
 				CustomerId: customerId.String,
@@ -2658,60 +2834,22 @@ func (th *ThreadDB) FetchMessagesByThreadId(
 	return messages, nil
 }
 
-func (th *ThreadDB) FetchMessagesWithAttachmentsByThreadId(
-	ctx context.Context, threadId string) ([]models.MessageWithAttachments, error) {
-	var message models.MessageWithAttachments
-
-	limit := 100
-	messages := make([]models.MessageWithAttachments, 0, limit)
-	cols := threadMessageJoinedCols()
-
-	stmt := `SELECT
-		%s,
-		COALESCE(
-			(
-				SELECT JSON_AGG(
-					JSON_BUILD_OBJECT(
-						'attachmentId', ma.attachment_id,
-						'messageId', ma.message_id,
-						'name', ma.name,
-						'contentType', ma.content_type,
-						'contentKey', ma.content_key,
-						'contentUrl', ma.content_url,
-						'spam', ma.spam,
-						'hasError', ma.has_error,
-						'error', ma.error,
-						'md5Hash', ma.md5_hash,
-						'createdAt', ma.created_at AT TIME ZONE 'UTC',
-						'updatedAt', ma.updated_at AT TIME ZONE 'UTC'
-					)
-				)
-				FROM (
-					SELECT *
-					FROM message_attachment
-					WHERE message_id = msg.message_id
-					ORDER BY created_at ASC
-					LIMIT 10
-				) ma
-			), 
-			'[]'::json
-		) as attachments
-	FROM message msg
-	LEFT OUTER JOIN customer c ON msg.customer_id = c.customer_id
-	LEFT OUTER JOIN member m ON msg.member_id = m.member_id`
-
-	stmt = fmt.Sprintf(stmt, cols)
+// LookupLatestThreadMessage returns the most recent message of the thread exchanged with the Customer.
+func (th *ThreadDB) LookupLatestThreadMessage(ctx context.Context, threadId string) (models.Message, error) {
+	var message models.Message
 
 	q := builq.New()
-	q("%s", stmt)
-	q("WHERE msg.thread_id = %$", threadId)
-	q("ORDER BY msg.created_at ASC")
-	q("LIMIT %d", limit)
+	q("SELECT %s FROM message msg", threadMessageJoinedCols())
+	q("LEFT OUTER JOIN customer c ON msg.customer_id = c.customer_id")
+	q("LEFT OUTER JOIN member m ON msg.member_id = m.member_id")
+	q("WHERE msg.thread_id = %$ AND msg.kind = %$", threadId, models.MessageKind{}.Message())
+	q("ORDER BY msg.created_at DESC")
+	q("LIMIT 1")
 
 	stmt, _, err := q.Build()
 	if err != nil {
 		slog.Error("failed to build query", slog.Any("err", err))
-		return nil, ErrQuery
+		return models.Message{}, ErrQuery
 	}
 
 	if zyg.DBQueryDebug() {
@@ -2721,21 +2859,255 @@ func (th *ThreadDB) FetchMessagesWithAttachmentsByThreadId(
 
 	var customerId, customerName sql.NullString
 	var memberId, memberName sql.NullString
-	var attachmentsJson []byte
 
-	rows, _ := th.db.Query(ctx, stmt, threadId)
-
-	defer rows.Close()
-
-	_, err = pgx.ForEachRow(rows, []any{
+	err = th.db.QueryRow(ctx, stmt, threadId, models.MessageKind{}.Message()).Scan(
 		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
+		&message.OriginalTextBody,
 		&customerId, &customerName,
 		&memberId, &memberName,
-		&message.Channel,
+		&message.Channel, &message.Kind, &message.IsAutomated,
 		&message.CreatedAt, &message.UpdatedAt,
-		&attachmentsJson,
-	}, func() error {
-		if customerId.Valid {
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Message{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.Message{}, ErrQuery
+	}
+	if customerId.Valid {
+		message.Customer = &models.CustomerActor{
+			CustomerId: customerId.String,
+			Name:       customerName.String,
+		}
+	}
+	if memberId.Valid {
+		message.Member = &models.MemberActor{
+			MemberId: memberId.String,
+			Name:     memberName.String,
+		}
+	}
+	return message, nil
+}
+
+// LookupPrecedingThreadMessage returns the most recent message of the thread created before the time.
+func (th *ThreadDB) LookupPrecedingThreadMessage(
+	ctx context.Context, threadId string, before time.Time) (models.Message, error) {
+	var message models.Message
+
+	q := builq.New()
+	q("SELECT %s FROM message msg", threadMessageJoinedCols())
+	q("LEFT OUTER JOIN customer c ON msg.customer_id = c.customer_id")
+	q("LEFT OUTER JOIN member m ON msg.member_id = m.member_id")
+	q("WHERE msg.thread_id = %$ AND msg.kind = %$", threadId, models.MessageKind{}.Message())
+	q("AND msg.created_at < %$", before)
+	q("ORDER BY msg.created_at DESC")
+	q("LIMIT 1")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.Message{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	var customerId, customerName sql.NullString
+	var memberId, memberName sql.NullString
+
+	err = th.db.QueryRow(ctx, stmt, threadId, models.MessageKind{}.Message(), before).Scan(
+		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
+		&message.OriginalTextBody,
+		&customerId, &customerName,
+		&memberId, &memberName,
+		&message.Channel, &message.Kind, &message.IsAutomated,
+		&message.CreatedAt, &message.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Message{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.Message{}, ErrQuery
+	}
+	if customerId.Valid {
+		message.Customer = &models.CustomerActor{
+			CustomerId: customerId.String,
+			Name:       customerName.String,
+		}
+	}
+	if memberId.Valid {
+		message.Member = &models.MemberActor{
+			MemberId: memberId.String,
+			Name:     memberName.String,
+		}
+	}
+	return message, nil
+}
+
+// LookupThreadMessageById returns the thread message by the message ID.
+func (th *ThreadDB) LookupThreadMessageById(ctx context.Context, messageId string) (models.Message, error) {
+	var message models.Message
+
+	q := builq.New()
+	q("SELECT %s FROM message msg", threadMessageJoinedCols())
+	q("LEFT OUTER JOIN customer c ON msg.customer_id = c.customer_id")
+	q("LEFT OUTER JOIN member m ON msg.member_id = m.member_id")
+	q("WHERE msg.message_id = %$", messageId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.Message{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	var customerId, customerName sql.NullString
+	var memberId, memberName sql.NullString
+
+	err = th.db.QueryRow(ctx, stmt, messageId).Scan(
+		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
+		&message.OriginalTextBody,
+		&customerId, &customerName,
+		&memberId, &memberName,
+		&message.Channel, &message.Kind, &message.IsAutomated,
+		&message.CreatedAt, &message.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Message{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.Message{}, ErrQuery
+	}
+	if customerId.Valid {
+		message.Customer = &models.CustomerActor{
+			CustomerId: customerId.String,
+			Name:       customerName.String,
+		}
+	}
+	if memberId.Valid {
+		message.Member = &models.MemberActor{
+			MemberId: memberId.String,
+			Name:     memberName.String,
+		}
+	}
+	return message, nil
+}
+
+// CountAutomatedOutboundMessagesSince counts the automated outbound messages sent on the customer's threads
+// since the specified time.
+func (th *ThreadDB) CountAutomatedOutboundMessagesSince(
+	ctx context.Context, customerId string, since time.Time) (int, error) {
+	var count int
+	stmt := `SELECT COUNT(*) FROM message msg
+		INNER JOIN thread th ON msg.thread_id = th.thread_id
+		WHERE th.customer_id = $1 AND msg.member_id IS NOT NULL AND msg.is_automated AND msg.created_at >= $2`
+	err := th.db.QueryRow(ctx, stmt, customerId, since).Scan(&count)
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return 0, ErrQuery
+	}
+	return count, nil
+}
+
+func (th *ThreadDB) FetchMessagesWithAttachmentsByThreadId(
+	ctx context.Context, threadId string) ([]models.MessageWithAttachments, error) {
+	var message models.MessageWithAttachments
+
+	limit := 100
+	messages := make([]models.MessageWithAttachments, 0, limit)
+	cols := threadMessageJoinedCols()
+
+	stmt := `SELECT
+		%s,
+		%s,
+		COALESCE(
+			(
+				SELECT JSON_AGG(
+					JSON_BUILD_OBJECT(
+						'attachmentId', ma.attachment_id,
+						'messageId', ma.message_id,
+						'name', ma.name,
+						'contentType', ma.content_type,
+						'contentKey', ma.content_key,
+						'contentUrl', ma.content_url,
+						'spam', ma.spam,
+						'hasError', ma.has_error,
+						'error', ma.error,
+						'md5Hash', ma.md5_hash,
+						'contentId', ma.content_id,
+						'createdAt', ma.created_at AT TIME ZONE 'UTC',
+						'updatedAt', ma.updated_at AT TIME ZONE 'UTC'
+					)
+				)
+				FROM (
+					SELECT *
+					FROM message_attachment
+					WHERE message_id = msg.message_id
+					ORDER BY created_at ASC
+					LIMIT 10
+				) ma
+			), 
+			'[]'::json
+		) as attachments
+	FROM message msg
+	LEFT OUTER JOIN customer c ON msg.customer_id = c.customer_id
+	LEFT OUTER JOIN member m ON msg.member_id = m.member_id
+	LEFT OUTER JOIN channel_message_log cml
+	ON cml.message_id = msg.message_id AND cml.message_type = 'outbound'
+	LEFT OUTER JOIN outbound_mail om ON om.message_id = msg.message_id`
+
+	stmt = fmt.Sprintf(stmt, cols, messageDeliveryJoinedCols())
+
+	q := builq.New()
+	q("%s", stmt)
+	q("WHERE msg.thread_id = %$", threadId)
+	q("ORDER BY msg.created_at ASC")
+	q("LIMIT %d", limit)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return nil, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	var customerId, customerName sql.NullString
+	var memberId, memberName sql.NullString
+	var deliveryStatus, deliveryErrorMessage sql.NullString
+	var deliveryHasError sql.NullBool
+	var deliveryUpdatedAt sql.NullTime
+	var attachmentsJson []byte
+
+	rows, _ := th.db.Query(ctx, stmt, threadId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&message.MessageId, &message.ThreadId, &message.TextBody, &message.MarkdownBody, &message.HTMLBody,
+		&message.OriginalTextBody,
+		&customerId, &customerName,
+		&memberId, &memberName,
+		&message.Channel, &message.Kind, &message.IsAutomated,
+		&message.CreatedAt, &message.UpdatedAt,
+		&deliveryStatus, &deliveryHasError, &deliveryErrorMessage, &deliveryUpdatedAt,
+		&attachmentsJson,
+	}, func() error {
+		message.Delivery = newMessageDelivery(
+			deliveryStatus, deliveryHasError, deliveryErrorMessage, deliveryUpdatedAt)
+		if customerId.Valid {
 			message.Customer = &models.CustomerActor{
 				CustomerId: customerId.String,
 				Name:       customerName.String,
@@ -2744,6 +3116,7 @@ func (th *ThreadDB) FetchMessagesWithAttachmentsByThreadId(
 		if memberId.Valid {
 			message.Member = &models.MemberActor{
 				MemberId: memberId.String,
+				Name:     memberName.String,
 			}
 		}
 		var attachments []models.MessageAttachment
@@ -2883,15 +3256,22 @@ func (th *ThreadDB) ComputeLabelMetricsByWorkspaceId(
 	return metrics, nil
 }
 
-func (th *ThreadDB) FindThreadByPostmarkReplyMessageId(
-	ctx context.Context, workspaceId string, mailMessageId string) (models.Thread, error) {
+// FindThreadByChannelRefs returns the workspace Thread of the message with any of the channel protocol references
+// in order of preference, e.g. the mail `In-Reply-To` then the `References` chain most recent first.
+// The first reference with the message wins.
+// References of the internal messages are not matched, e.g. the forwarded mail.
+func (th *ThreadDB) FindThreadByChannelRefs(
+	ctx context.Context, workspaceId string, channel string, refs []string) (models.Thread, error) {
 	var thread models.Thread
 
 	var selectB builq.Builder
 	selectB.Addf("SELECT m.thread_id AS thread_id")
-	selectB.Addf("FROM postmark_message_log p")
-	selectB.Addf("INNER JOIN message m ON p.message_id = m.message_id")
-	selectB.Addf("WHERE p.mail_message_id = $2")
+	selectB.Addf("FROM channel_message_log cml")
+	selectB.Addf("INNER JOIN message m ON cml.message_id = m.message_id")
+	selectB.Addf("WHERE cml.channel = $2 AND cml.external_ref = ANY($3::TEXT[])")
+	selectB.Addf("AND m.kind = 'message'")
+	selectB.Addf("ORDER BY array_position($3::TEXT[], cml.external_ref) ASC")
+	selectB.Addf("LIMIT 1")
 
 	selectQuery, _, err := selectB.Build()
 	if err != nil {
@@ -2951,7 +3331,7 @@ func (th *ThreadDB) FindThreadByPostmarkReplyMessageId(
 		outboundUpdatedAt   sql.NullTime
 	)
 
-	err = th.db.QueryRow(ctx, stmt, workspaceId, mailMessageId).Scan(
+	err = th.db.QueryRow(ctx, stmt, workspaceId, channel, refs).Scan(
 		&thread.ThreadId, &thread.WorkspaceId, &thread.Customer.CustomerId, &thread.Customer.Name,
 		&assignedMemberId, &assignedMemberName, &assignedAt,
 		&thread.Title, &thread.Description,
@@ -3033,66 +3413,49 @@ func (th *ThreadDB) FindThreadByPostmarkReplyMessageId(
 	return thread, nil
 }
 
-func (th *ThreadDB) AppendPostmarkInboundThreadMessage(
-	ctx context.Context, threadId string, inboundEvent *models.InboundMessage,
-	postmarkMessageLog *models.PostmarkMessageLog, message *models.Message) (*models.Message, error) {
+// CheckChannelMessageExists checks if the inbound message with the provider's message ID is already persisted.
+func (th *ThreadDB) CheckChannelMessageExists(ctx context.Context, channel string, externalId string) (bool, error) {
+	var isExist bool
+	stmt := `SELECT EXISTS(
+		SELECT 1 FROM channel_message_log
+		WHERE channel = $1 AND external_id = $2 AND message_type = 'inbound'
+	)`
 
-	// start transaction
-	// If fails then stop the execution and return the error.
-	tx, err := th.db.Begin(ctx)
+	err := th.db.QueryRow(ctx, stmt, channel, externalId).Scan(&isExist)
 	if err != nil {
-		slog.Error("failed to start db tx", slog.Any("err", err))
-		return &models.Message{}, ErrQuery
-	}
-
-	defer func(tx pgx.Tx, ctx context.Context) {
-		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
-			slog.Error("failed to rollback transaction", slog.Any("err", err))
-		}
-	}(tx, ctx)
-
-	// Append workflows:
-	// 1. insert inbound event (formerly inbound_message) linked to thread, update if created new
-	// 2. insert message linked to thread
-	// 2. insert postmark message log
-
-	var insertB builq.Builder
-	cols := inboundMessageCols()
-	insertParams := []any{
-		inboundEvent.MessageId, inboundEvent.Customer.CustomerId,
-		inboundEvent.PreviewText, inboundEvent.FirstSeqId, inboundEvent.LastSeqId,
-		inboundEvent.CreatedAt, inboundEvent.UpdatedAt,
+		slog.Error("failed to query", slog.Any("err", err))
+		return false, ErrQuery
 	}
+	return isExist, nil
+}
 
-	// Build the upsert query to insert thread inbound message
-	insertB.Addf("INSERT INTO inbound_message (%s)", cols)
-	insertB.Addf("VALUES (%$, %$, %$, %$, %$, %$, %$)", insertParams...)
-	insertB.Addf("ON CONFLICT (message_id)")
-	insertB.Addf("DO UPDATE")
-	insertB.Addf("SET")
-	insertB.Addf("preview_text = EXCLUDED.preview_text,")
-	insertB.Addf("last_seq_id = EXCLUDED.last_seq_id,")
-	insertB.Addf("updated_at = EXCLUDED.updated_at")
-	insertB.Addf("RETURNING %s, (xmax = 0) AS is_created", cols)
-
-	insertQuery, _, err := insertB.Build()
-	if err != nil {
-		slog.Error("failed to build upsert query", slog.Any("error", err))
-		return &models.Message{}, ErrQuery
+// ModifyChannelMessageLogStatus updates the delivery status of the workspace outbound message log
+// as reported by the channel provider.
+func (th *ThreadDB) ModifyChannelMessageLogStatus(
+	ctx context.Context, workspaceId string, channel string, status models.ChannelDeliveryStatus,
+) (models.ChannelMessageLog, error) {
+	var messageLog models.ChannelMessageLog
+	cols := channelMessageLogCols()
+	params := []any{
+		status.Status, status.ErrorCode != 0 || status.ErrorMessage != "",
+		status.ErrorCode, status.ErrorMessage, channel, status.ExternalId, workspaceId,
 	}
 
-	// Build the select query
 	q := builq.New()
-	joinedCols := inboundMessageJoinedCols()
-	q("WITH ups AS (%s)", insertQuery)
-	q("SELECT %s, im.is_created", joinedCols)
-	q("FROM ups im")
-	q("INNER JOIN customer c ON im.customer_id = c.customer_id")
+	q("UPDATE channel_message_log SET")
+	q("status = %$, has_error = %$, error_code = %$, error_message = %$,", params[:4]...)
+	q("acknowledged = true, updated_at = NOW()")
+	q("WHERE channel = %$ AND external_id = %$ AND message_type = 'outbound'", params[4:6]...)
+	q("AND EXISTS (")
+	q("SELECT 1 FROM message m INNER JOIN thread th ON m.thread_id = th.thread_id")
+	q("WHERE m.message_id = channel_message_log.message_id AND th.workspace_id = %$", params[6])
+	q(")")
+	q("RETURNING %s", cols)
 
 	stmt, _, err := q.Build()
 	if err != nil {
-		slog.Error("failed to build query", slog.Any("error", err))
-		return &models.Message{}, ErrQuery
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.ChannelMessageLog{}, ErrQuery
 	}
 
 	if zyg.DBQueryDebug() {
@@ -3100,78 +3463,100 @@ func (th *ThreadDB) AppendPostmarkInboundThreadMessage(
 		debugQuery(debug)
 	}
 
-	var isCreated bool
-	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(
-		&inboundEvent.MessageId,
-		&inboundEvent.Customer.CustomerId, &inboundEvent.Customer.Name,
-		&inboundEvent.PreviewText,
-		&inboundEvent.FirstSeqId, &inboundEvent.LastSeqId,
-		&inboundEvent.CreatedAt, &inboundEvent.UpdatedAt,
-		&isCreated,
+	err = th.db.QueryRow(ctx, stmt, params...).Scan(
+		&messageLog.MessageId, &messageLog.Channel, &messageLog.MessageType,
+		&messageLog.ExternalId, &messageLog.ExternalRef, &messageLog.ReplyRef,
+		&messageLog.Payload, &messageLog.Status,
+		&messageLog.HasError, &messageLog.ErrorCode, &messageLog.ErrorMessage,
+		&messageLog.Acknowledged, &messageLog.SubmittedAt,
+		&messageLog.CreatedAt, &messageLog.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
-		slog.Error("no rows returned", slog.Any("err", err))
-		return &models.Message{}, ErrEmpty
+		return models.ChannelMessageLog{}, ErrEmpty
 	}
 	if err != nil {
-		slog.Error("failed to query", slog.Any("err", err))
-		return &models.Message{}, ErrQuery
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.ChannelMessageLog{}, ErrQuery
 	}
-	// Check if the inbound message is created
-	if isCreated {
-		// Link inbound message to the thread
-		q = builq.New()
-		updates := []any{inboundEvent.MessageId, threadId}
-		q("UPDATE thread SET")
-		q("inbound_message_id = %$, updated_at = NOW() WHERE thread_id = %$", updates...)
+	return messageLog, nil
+}
 
-		stmt, _, err = q.Build()
-		if err != nil {
-			slog.Error("failed to build query", slog.Any("error", err))
-			return &models.Message{}, ErrQuery
-		}
-		_, err = tx.Exec(ctx, stmt, updates...)
-		if err != nil {
-			slog.Error("failed to update query", slog.Any("err", err))
-			return &models.Message{}, ErrQuery
-		}
-	}
+// FetchChannelMessageLogByExternalId returns the workspace outbound message log
+// by the channel provider's message ID.
+func (th *ThreadDB) FetchChannelMessageLogByExternalId(
+	ctx context.Context, workspaceId string, channel string, externalId string) (models.ChannelMessageLog, error) {
+	var messageLog models.ChannelMessageLog
+	cols := channelMessageLogCols()
 
-	// Insert thread message
-	message, err = InsertThreadMessageTx(ctx, tx, message)
+	q := builq.New()
+	q("SELECT %s FROM channel_message_log", cols)
+	q("WHERE channel = %$ AND external_id = %$ AND message_type = 'outbound'", channel, externalId)
+	q("AND EXISTS (")
+	q("SELECT 1 FROM message m INNER JOIN thread th ON m.thread_id = th.thread_id")
+	q("WHERE m.message_id = channel_message_log.message_id AND th.workspace_id = %$", workspaceId)
+	q(")")
+
+	stmt, _, err := q.Build()
 	if err != nil {
-		slog.Error("failed to insert thread message", slog.Any("err", err))
-		return &models.Message{}, ErrQuery
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.ChannelMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
 	}
 
-	// Insert postmark message log
-	postmarkMessageLog, err = InsertPostmarkMessageLogTx(ctx, tx, message.MessageId, postmarkMessageLog)
+	err = th.db.QueryRow(ctx, stmt, channel, externalId, workspaceId).Scan(
+		&messageLog.MessageId, &messageLog.Channel, &messageLog.MessageType,
+		&messageLog.ExternalId, &messageLog.ExternalRef, &messageLog.ReplyRef,
+		&messageLog.Payload, &messageLog.Status,
+		&messageLog.HasError, &messageLog.ErrorCode, &messageLog.ErrorMessage,
+		&messageLog.Acknowledged, &messageLog.SubmittedAt,
+		&messageLog.CreatedAt, &messageLog.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ChannelMessageLog{}, ErrEmpty
+	}
 	if err != nil {
-		slog.Error("failed to insert postmark message log", slog.Any("err", err))
-		return &models.Message{}, ErrQuery
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.ChannelMessageLog{}, ErrQuery
 	}
+	return messageLog, nil
+}
 
-	// commit transaction
-	err = tx.Commit(ctx)
+// LookupInboundMessageIdByExternalId returns the ID of the inbound message with the provider's message ID.
+func (th *ThreadDB) LookupInboundMessageIdByExternalId(
+	ctx context.Context, channel string, externalId string) (string, error) {
+	var messageId string
+	stmt := `SELECT message_id FROM channel_message_log
+		WHERE channel = $1 AND external_id = $2 AND message_type = 'inbound'`
+
+	err := th.db.QueryRow(ctx, stmt, channel, externalId).Scan(&messageId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrEmpty
+	}
 	if err != nil {
-		slog.Error("failed to commit query", slog.Any("err", err))
-		return &models.Message{}, ErrTxQuery
+		slog.Error("failed to query", slog.Any("err", err))
+		return "", ErrQuery
 	}
-	return message, nil
+	return messageId, nil
 }
 
-func (th *ThreadDB) CheckPostmarkInboundMessageExists(ctx context.Context, messageId string) (bool, error) {
-	var isExist bool
-	stmt := `SELECT EXISTS(
-		SELECT 1 FROM postmark_message_log WHERE postmark_message_id = $1 AND message_type = 'inbound'
-	)`
+// LookupMessageThreadId returns the thread ID of the message.
+func (th *ThreadDB) LookupMessageThreadId(ctx context.Context, messageId string) (string, error) {
+	var threadId string
+	stmt := `SELECT thread_id FROM message WHERE message_id = $1`
 
-	err := th.db.QueryRow(ctx, stmt, messageId).Scan(&isExist)
+	err := th.db.QueryRow(ctx, stmt, messageId).Scan(&threadId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrEmpty
+	}
 	if err != nil {
 		slog.Error("failed to query", slog.Any("err", err))
-		return false, ErrQuery
+		return "", ErrQuery
 	}
-	return isExist, nil
+	return threadId, nil
 }
 
 func (th *ThreadDB) InsertMessageAttachment(
@@ -3181,7 +3566,7 @@ func (th *ThreadDB) InsertMessageAttachment(
 	insertParams := []any{
 		attachment.AttachmentId, attachment.MessageId, attachment.Name,
 		attachment.ContentType, attachment.ContentKey, attachment.ContentUrl,
-		attachment.Spam, attachment.HasError, attachment.Error, attachment.MD5Hash,
+		attachment.Spam, attachment.HasError, attachment.Error, attachment.MD5Hash, attachment.ContentId,
 		attachment.CreatedAt, attachment.UpdatedAt,
 	}
 	q("INSERT INTO message_attachment (%s)", cols)
@@ -3202,7 +3587,7 @@ func (th *ThreadDB) InsertMessageAttachment(
 	err = th.db.QueryRow(ctx, stmt, insertParams...).Scan(
 		&attachment.AttachmentId, &attachment.MessageId, &attachment.Name,
 		&attachment.ContentType, &attachment.ContentKey, &attachment.ContentUrl,
-		&attachment.Spam, &attachment.HasError, &attachment.Error, &attachment.MD5Hash,
+		&attachment.Spam, &attachment.HasError, &attachment.Error, &attachment.MD5Hash, &attachment.ContentId,
 		&attachment.CreatedAt, &attachment.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -3216,6 +3601,163 @@ func (th *ThreadDB) InsertMessageAttachment(
 	return attachment, nil
 }
 
+func attachmentUploadCols() builq.Columns {
+	return builq.Columns{
+		"attachment_id",
+		"workspace_id",
+		"thread_id",
+		"member_id",
+		"name",
+		"content_type",
+		"content_key",
+		"content_url",
+		"size",
+		"md5_hash",
+		"created_at",
+	}
+}
+
+func attachmentUploadDest(upload *models.AttachmentUpload) []any {
+	return []any{
+		&upload.AttachmentId, &upload.WorkspaceId, &upload.ThreadId, &upload.MemberId,
+		&upload.Name, &upload.ContentType, &upload.ContentKey, &upload.ContentUrl,
+		&upload.Size, &upload.MD5Hash, &upload.CreatedAt,
+	}
+}
+
+func (th *ThreadDB) InsertAttachmentUpload(
+	ctx context.Context, upload models.AttachmentUpload) (models.AttachmentUpload, error) {
+	cols := attachmentUploadCols()
+	q := builq.New()
+	insertParams := []any{
+		upload.AttachmentId, upload.WorkspaceId, upload.ThreadId, upload.MemberId,
+		upload.Name, upload.ContentType, upload.ContentKey, upload.ContentUrl,
+		upload.Size, upload.MD5Hash, upload.CreatedAt,
+	}
+	q("INSERT INTO attachment_upload (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.AttachmentUpload{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = th.db.QueryRow(ctx, stmt, insertParams...).Scan(attachmentUploadDest(&upload)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.AttachmentUpload{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.AttachmentUpload{}, ErrQuery
+	}
+	return upload, nil
+}
+
+// FetchAttachmentUploads returns the Member's uploads for the Thread not yet linked to a message.
+func (th *ThreadDB) FetchAttachmentUploads(
+	ctx context.Context, threadId string, memberId string, attachmentIds []string,
+) ([]models.AttachmentUpload, error) {
+	var upload models.AttachmentUpload
+	uploads := make([]models.AttachmentUpload, 0, len(attachmentIds))
+
+	q := builq.New()
+	q("SELECT %s FROM attachment_upload", attachmentUploadCols())
+	q("WHERE thread_id = %$ AND member_id = %$", threadId, memberId)
+	q("AND attachment_id = ANY(%$)", attachmentIds)
+	q("ORDER BY created_at ASC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.AttachmentUpload{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := th.db.Query(ctx, stmt, threadId, memberId, attachmentIds)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, attachmentUploadDest(&upload), func() error {
+		uploads = append(uploads, upload)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.AttachmentUpload{}, ErrQuery
+	}
+	return uploads, nil
+}
+
+// LinkAttachmentUploadsTx inserts the Member's uploads as the attachments of the persisted message
+// and removes the uploads within the transaction.
+func LinkAttachmentUploadsTx(
+	ctx context.Context, tx pgx.Tx, messageId string, attachments []models.MessageAttachment) error {
+	cols := messageAttachmentCols()
+	attachmentIds := make([]string, 0, len(attachments))
+	batch := &pgx.Batch{}
+	for _, a := range attachments {
+		insertParams := []any{
+			a.AttachmentId, messageId, a.Name,
+			a.ContentType, a.ContentKey, a.ContentUrl,
+			a.Spam, a.HasError, a.Error, a.MD5Hash, a.ContentId,
+			a.CreatedAt, a.UpdatedAt,
+		}
+		q := builq.New()
+		q("INSERT INTO message_attachment (%s)", cols)
+		q("VALUES (%+$)", insertParams)
+		stmt, _, err := q.Build()
+		if err != nil {
+			slog.Error("failed to build query", slog.Any("err", err))
+			return ErrQuery
+		}
+		if zyg.DBQueryDebug() {
+			debug := q.DebugBuild()
+			debugQuery(debug)
+		}
+		batch.Queue(stmt, insertParams...)
+		attachmentIds = append(attachmentIds, a.AttachmentId)
+	}
+
+	q := builq.New()
+	q("DELETE FROM attachment_upload WHERE attachment_id = ANY(%$)", attachmentIds)
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+	batch.Queue(stmt, attachmentIds)
+
+	results := tx.SendBatch(ctx, batch)
+	for range batch.Len() {
+		if _, err := results.Exec(); err != nil {
+			_ = results.Close()
+			slog.Error("failed to link attachment uploads in batch", slog.Any("err", err))
+			return ErrQuery
+		}
+	}
+	if err := results.Close(); err != nil {
+		slog.Error("failed to close batch results", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
 func (th *ThreadDB) FetchMessageAttachmentById(
 	ctx context.Context, messageId, attachmentId string) (models.MessageAttachment, error) {
 	var attachment models.MessageAttachment
@@ -3240,7 +3782,7 @@ func (th *ThreadDB) FetchMessageAttachmentById(
 	err = th.db.QueryRow(ctx, stmt, messageId, attachmentId).Scan(
 		&attachment.AttachmentId, &attachment.MessageId, &attachment.Name,
 		&attachment.ContentType, &attachment.ContentKey, &attachment.ContentUrl,
-		&attachment.Spam, &attachment.HasError, &attachment.Error, &attachment.MD5Hash,
+		&attachment.Spam, &attachment.HasError, &attachment.Error, &attachment.MD5Hash, &attachment.ContentId,
 		&attachment.CreatedAt, &attachment.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -3254,19 +3796,438 @@ func (th *ThreadDB) FetchMessageAttachmentById(
 	return attachment, nil
 }
 
-func (th *ThreadDB) GetRecentMailMessageIdByThreadId(ctx context.Context, threadId string) (string, error) {
-	var mailMessageId string
+// FetchMessageAttachmentsByMessageId returns the attachments of the message, the earliest first.
+func (th *ThreadDB) FetchMessageAttachmentsByMessageId(
+	ctx context.Context, messageId string) ([]models.MessageAttachment, error) {
+	var attachment models.MessageAttachment
+	limit := 50
+	attachments := make([]models.MessageAttachment, 0, limit)
+	cols := messageAttachmentCols()
+
+	q := builq.New()
+	q("SELECT %s FROM message_attachment", cols)
+	q("WHERE message_id = %$", messageId)
+	q("ORDER BY created_at ASC")
+	q("LIMIT %d", limit)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.MessageAttachment{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := th.db.Query(ctx, stmt, messageId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&attachment.AttachmentId, &attachment.MessageId, &attachment.Name,
+		&attachment.ContentType, &attachment.ContentKey, &attachment.ContentUrl,
+		&attachment.Spam, &attachment.HasError, &attachment.Error, &attachment.MD5Hash, &attachment.ContentId,
+		&attachment.CreatedAt, &attachment.UpdatedAt,
+	}, func() error {
+		attachments = append(attachments, attachment)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.MessageAttachment{}, ErrQuery
+	}
+	return attachments, nil
+}
+
+// DeleteFailedMessageAttachments deletes the attachments of the message kept with the error,
+// to be processed again.
+func (th *ThreadDB) DeleteFailedMessageAttachments(ctx context.Context, messageId string) error {
+	q := builq.New()
+	q("DELETE FROM message_attachment")
+	q("WHERE message_id = %$ AND has_error = true", messageId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	_, err = th.db.Exec(ctx, stmt, messageId)
+	if err != nil {
+		slog.Error("failed to delete query", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
+// FetchChannelRefsByThreadId returns the channel protocol references of the Thread's messages oldest first,
+// e.g. the mail `Message-ID` of each mail in the thread, the reply is sent `In-Reply-To` the most recent.
+// References of the internal messages are not exposed to the Customer.
+func (th *ThreadDB) FetchChannelRefsByThreadId(
+	ctx context.Context, threadId string, channel string) ([]string, error) {
+	var ref string
+	refs := make([]string, 0, 10)
+	q := builq.New()
+	q("SELECT cml.external_ref FROM channel_message_log cml")
+	q("INNER JOIN message m ON m.message_id = cml.message_id")
+	q("WHERE m.thread_id = %$ AND cml.channel = %$", threadId, channel)
+	q("AND cml.external_ref IS NOT NULL AND m.kind = %$", models.MessageKind{}.Message())
+	q("ORDER BY m.created_at ASC, m.message_id ASC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []string{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := th.db.Query(ctx, stmt, threadId, channel, models.MessageKind{}.Message())
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{&ref}, func() error {
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []string{}, ErrQuery
+	}
+	return refs, nil
+}
+
+func messageParticipantCols() builq.Columns {
+	return builq.Columns{
+		"message_id",
+		"email",
+		"name",
+		"role",
+		"created_at",
+	}
+}
+
+func threadParticipantCols() builq.Columns {
+	return builq.Columns{
+		"thread_id",
+		"email",
+		"name",
+		"role",
+		"customer_id",
+		"created_at",
+		"updated_at",
+	}
+}
+
+// InsertMessageParticipantsTx inserts the recipients of the persisted message within the transaction.
+func InsertMessageParticipantsTx(
+	ctx context.Context, tx pgx.Tx, messageId string, participants []models.MessageParticipant) error {
+	cols := messageParticipantCols()
+	batch := &pgx.Batch{}
+	for _, p := range participants {
+		insertParams := []any{messageId, p.Email, p.Name, p.Role, p.CreatedAt}
+		q := builq.New()
+		q("INSERT INTO message_participant (%s)", cols)
+		q("VALUES (%+$)", insertParams)
+		q("ON CONFLICT (message_id, email) DO NOTHING")
+		stmt, _, err := q.Build()
+		if err != nil {
+			slog.Error("failed to build query", slog.Any("err", err))
+			return ErrQuery
+		}
+		if zyg.DBQueryDebug() {
+			debug := q.DebugBuild()
+			debugQuery(debug)
+		}
+		batch.Queue(stmt, insertParams...)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for range participants {
+		if _, err := results.Exec(); err != nil {
+			_ = results.Close()
+			slog.Error("failed to insert message participants in batch", slog.Any("err", err))
+			return ErrQuery
+		}
+	}
+	if err := results.Close(); err != nil {
+		slog.Error("failed to close batch results", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
+// FetchThreadParticipants returns the participants of the thread, oldest first.
+func (th *ThreadDB) FetchThreadParticipants(
+	ctx context.Context, threadId string) ([]models.ThreadParticipant, error) {
+	var participant models.ThreadParticipant
+	participants := make([]models.ThreadParticipant, 0, 10)
+
+	q := builq.New()
+	q("SELECT %s FROM thread_participant", threadParticipantCols())
+	q("WHERE thread_id = %$", threadId)
+	q("ORDER BY created_at ASC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return nil, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := th.db.Query(ctx, stmt, threadId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&participant.ThreadId, &participant.Email, &participant.Name, &participant.Role,
+		&participant.CustomerId, &participant.CreatedAt, &participant.UpdatedAt,
+	}, func() error {
+		participants = append(participants, participant)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.ThreadParticipant{}, ErrQuery
+	}
+	return participants, nil
+}
+
+// UpsertThreadParticipants adds the participants to the thread.
+// Existing participant's role and name are updated, the customer if linked is kept.
+func (th *ThreadDB) UpsertThreadParticipants(ctx context.Context, participants []models.ThreadParticipant) error {
+	cols := threadParticipantCols()
+	batch := &pgx.Batch{}
+	for _, p := range participants {
+		insertParams := []any{p.ThreadId, p.Email, p.Name, p.Role, p.CustomerId, p.CreatedAt, p.UpdatedAt}
+		q := builq.New()
+		q("INSERT INTO thread_participant (%s)", cols)
+		q("VALUES (%+$)", insertParams)
+		q("ON CONFLICT (thread_id, email) DO UPDATE SET")
+		q("name = CASE WHEN EXCLUDED.name <> '' THEN EXCLUDED.name ELSE thread_participant.name END,")
+		q("role = EXCLUDED.role,")
+		q("customer_id = COALESCE(EXCLUDED.customer_id, thread_participant.customer_id),")
+		q("updated_at = NOW()")
+		stmt, _, err := q.Build()
+		if err != nil {
+			slog.Error("failed to build query", slog.Any("err", err))
+			return ErrQuery
+		}
+		if zyg.DBQueryDebug() {
+			debug := q.DebugBuild()
+			debugQuery(debug)
+		}
+		batch.Queue(stmt, insertParams...)
+	}
+
+	results := th.db.SendBatch(ctx, batch)
+	for range participants {
+		if _, err := results.Exec(); err != nil {
+			_ = results.Close()
+			slog.Error("failed to upsert thread participants in batch", slog.Any("err", err))
+			return ErrQuery
+		}
+	}
+	if err := results.Close(); err != nil {
+		slog.Error("failed to close batch results", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
+// DeleteThreadParticipants removes the participants by email from the thread.
+func (th *ThreadDB) DeleteThreadParticipants(ctx context.Context, threadId string, emails []string) error {
+	q := builq.New()
+	q("DELETE FROM thread_participant")
+	q("WHERE thread_id = %$ AND email = ANY(%$::TEXT[])", threadId, emails)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	if _, err := th.db.Exec(ctx, stmt, threadId, emails); err != nil {
+		slog.Error("failed to delete query", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
+func threadForwardCols() builq.Columns {
+	return builq.Columns{
+		"forward_id",
+		"workspace_id",
+		"thread_id",
+		"message_id",
+		"member_id",
+		"to_email",
+		"to_name",
+		"message_ids",
+		"is_transcript",
+		"note",
+		"track_replies",
+		"mail_message_id",
+		"reply_count",
+		"last_replied_at",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func threadForwardScanDest(forward *models.ThreadForward) []any {
+	return []any{
+		&forward.ForwardId, &forward.WorkspaceId, &forward.ThreadId, &forward.MessageId, &forward.MemberId,
+		&forward.ToEmail, &forward.ToName, &forward.MessageIds, &forward.IsTranscript, &forward.Note,
+		&forward.TrackReplies, &forward.MailMessageId, &forward.ReplyCount, &forward.LastRepliedAt,
+		&forward.CreatedAt, &forward.UpdatedAt,
+	}
+}
+
+// InsertThreadForward inserts the forward with the Thread timeline entry of the forward,
+// the message log and the recipients of the forwarded mail.
+func (th *ThreadDB) InsertThreadForward(
+	ctx context.Context, forward models.ThreadForward, outbound models.ThreadMessage,
+) (models.ThreadForward, models.Message, error) {
+	tx, err := th.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.ThreadForward{}, models.Message{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	message, err := InsertThreadMessageTx(ctx, tx, outbound.Message)
+	if err != nil {
+		return models.ThreadForward{}, models.Message{}, err
+	}
+
+	if outbound.Log != nil {
+		outbound.Log.MessageId = message.MessageId
+		_, err = InsertChannelMessageLogTx(ctx, tx, outbound.Log)
+		if err != nil {
+			return models.ThreadForward{}, models.Message{}, err
+		}
+	}
+
+	if len(outbound.Participants) > 0 {
+		err = InsertMessageParticipantsTx(ctx, tx, message.MessageId, outbound.Participants)
+		if err != nil {
+			return models.ThreadForward{}, models.Message{}, err
+		}
+	}
+
+	if forward.MessageIds == nil {
+		forward.MessageIds = []string{}
+	}
+	cols := threadForwardCols()
+	insertParams := []any{
+		forward.ForwardId, forward.WorkspaceId, forward.ThreadId, message.MessageId, forward.MemberId,
+		forward.ToEmail, forward.ToName, forward.MessageIds, forward.IsTranscript, forward.Note,
+		forward.TrackReplies, forward.MailMessageId, forward.ReplyCount, forward.LastRepliedAt,
+		forward.CreatedAt, forward.UpdatedAt,
+	}
+
 	q := builq.New()
-	q("SELECT pml.mail_message_id FROM postmark_message_log pml")
-	q("INNER JOIN message m ON m.message_id = pml.message_id")
-	q("WHERE m.thread_id = %$", threadId)
-	q("ORDER BY m.message_id DESC")
+	q("INSERT INTO thread_forward (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.ThreadForward{}, models.Message{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(threadForwardScanDest(&forward)...)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.ThreadForward{}, models.Message{}, ErrQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.ThreadForward{}, models.Message{}, ErrTxQuery
+	}
+	return forward, *message, nil
+}
+
+// LookupThreadForwardById returns the workspace forward tracking the replies.
+func (th *ThreadDB) LookupThreadForwardById(
+	ctx context.Context, workspaceId string, forwardId string) (models.ThreadForward, error) {
+	var forward models.ThreadForward
+
+	q := builq.New()
+	q("SELECT %s FROM thread_forward", threadForwardCols())
+	q("WHERE workspace_id = %$ AND forward_id = %$ AND track_replies", workspaceId, forwardId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.ThreadForward{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = th.db.QueryRow(ctx, stmt, workspaceId, forwardId).Scan(threadForwardScanDest(&forward)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ThreadForward{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.ThreadForward{}, ErrQuery
+	}
+	return forward, nil
+}
+
+// FindThreadForwardByMailRefs returns the workspace forward tracking the replies with any of the mail references,
+// e.g. the mail `References` chain oldest first, the most recent reference wins.
+func (th *ThreadDB) FindThreadForwardByMailRefs(
+	ctx context.Context, workspaceId string, refs []string) (models.ThreadForward, error) {
+	var forward models.ThreadForward
+
+	q := builq.New()
+	q("SELECT %s FROM thread_forward", threadForwardCols())
+	q("WHERE workspace_id = %$ AND mail_message_id = ANY(%$::TEXT[]) AND track_replies", workspaceId, refs)
+	q("ORDER BY array_position(%$::TEXT[], mail_message_id) DESC", refs)
 	q("LIMIT 1")
 
 	stmt, _, err := q.Build()
 	if err != nil {
 		slog.Error("failed to build query", slog.Any("err", err))
-		return "", ErrQuery
+		return models.ThreadForward{}, ErrQuery
 	}
 
 	if zyg.DBQueryDebug() {
@@ -3274,14 +4235,119 @@ func (th *ThreadDB) GetRecentMailMessageIdByThreadId(ctx context.Context, thread
 		debugQuery(debug)
 	}
 
-	err = th.db.QueryRow(ctx, stmt, threadId).Scan(&mailMessageId)
+	err = th.db.QueryRow(ctx, stmt, workspaceId, refs, refs).Scan(threadForwardScanDest(&forward)...)
 	if errors.Is(err, pgx.ErrNoRows) {
-		slog.Error("no rows returned", slog.Any("err", err))
-		return "", ErrEmpty
+		return models.ThreadForward{}, ErrEmpty
 	}
 	if err != nil {
 		slog.Error("failed to query", slog.Any("err", err))
-		return "", ErrQuery
+		return models.ThreadForward{}, ErrQuery
 	}
-	return mailMessageId, nil
+	return forward, nil
+}
+
+// FetchThreadForwardsByThreadId returns the forwards of the thread, oldest first.
+func (th *ThreadDB) FetchThreadForwardsByThreadId(
+	ctx context.Context, threadId string) ([]models.ThreadForward, error) {
+	var forward models.ThreadForward
+	forwards := make([]models.ThreadForward, 0, 10)
+
+	q := builq.New()
+	q("SELECT %s FROM thread_forward", threadForwardCols())
+	q("WHERE thread_id = %$", threadId)
+	q("ORDER BY created_at ASC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return nil, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := th.db.Query(ctx, stmt, threadId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, threadForwardScanDest(&forward), func() error {
+		forwards = append(forwards, forward)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.ThreadForward{}, ErrQuery
+	}
+	return forwards, nil
+}
+
+// AppendThreadForwardReply inserts the reply received from the forwarded address as the Thread's note
+// with the message log, and tracks the reply with the forward.
+// The Thread stage and the inbound sequence are not changed, the note is internal to the workspace.
+func (th *ThreadDB) AppendThreadForwardReply(
+	ctx context.Context, forward models.ThreadForward, inbound models.ThreadMessage,
+) (models.Message, error) {
+	tx, err := th.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.Message{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	message, err := InsertThreadMessageTx(ctx, tx, inbound.Message)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	if inbound.Log != nil {
+		inbound.Log.MessageId = message.MessageId
+		_, err = InsertChannelMessageLogTx(ctx, tx, inbound.Log)
+		if err != nil {
+			return models.Message{}, err
+		}
+	}
+
+	if len(inbound.Participants) > 0 {
+		err = InsertMessageParticipantsTx(ctx, tx, message.MessageId, inbound.Participants)
+		if err != nil {
+			return models.Message{}, err
+		}
+	}
+
+	q := builq.New()
+	q("UPDATE thread_forward SET")
+	q("reply_count = reply_count + 1, last_replied_at = %$, updated_at = %$",
+		forward.LastRepliedAt, forward.UpdatedAt)
+	q("WHERE forward_id = %$", forward.ForwardId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.Message{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	_, err = tx.Exec(ctx, stmt, forward.LastRepliedAt, forward.UpdatedAt, forward.ForwardId)
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.Message{}, ErrQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.Message{}, ErrTxQuery
+	}
+	return *message, nil
 }
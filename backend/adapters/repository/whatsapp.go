@@ -0,0 +1,397 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/cristalhq/builq"
+	"github.com/jackc/pgx/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+)
+
+func whatsAppSettingCols() builq.Columns {
+	return builq.Columns{
+		"workspace_id",
+		"is_enabled",
+		"phone_number_id",
+		"business_account_id",
+		"display_phone_number",
+		"access_token",
+		"app_secret",
+		"verify_token",
+		"api_base_url",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func whatsAppMessageLogCols() builq.Columns {
+	return builq.Columns{
+		"message_id",
+		"wa_message_id",
+		"wa_id",
+		"message_type",
+		"kind",
+		"status",
+		"reaction",
+		"error_code",
+		"error_title",
+		"payload",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func (w *WhatsAppDB) SaveWhatsAppSetting(
+	ctx context.Context, setting models.WhatsAppSetting) (models.WhatsAppSetting, error) {
+	q := builq.New()
+	cols := whatsAppSettingCols()
+	insertParams := []any{
+		setting.WorkspaceId, setting.IsEnabled, setting.PhoneNumberId,
+		setting.BusinessAccountId, setting.DisplayPhoneNumber, setting.AccessToken,
+		setting.AppSecret, setting.VerifyToken, setting.APIBaseURL,
+		setting.CreatedAt, setting.UpdatedAt,
+	}
+
+	q("INSERT INTO whatsapp_setting (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("ON CONFLICT (workspace_id) DO UPDATE SET")
+	q("is_enabled = EXCLUDED.is_enabled,")
+	q("phone_number_id = EXCLUDED.phone_number_id,")
+	q("business_account_id = EXCLUDED.business_account_id,")
+	q("display_phone_number = EXCLUDED.display_phone_number,")
+	q("access_token = EXCLUDED.access_token,")
+	q("app_secret = EXCLUDED.app_secret,")
+	q("verify_token = EXCLUDED.verify_token,")
+	q("api_base_url = EXCLUDED.api_base_url,")
+	q("updated_at = NOW()")
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.WhatsAppSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = w.db.QueryRow(ctx, stmt, insertParams...).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.PhoneNumberId,
+		&setting.BusinessAccountId, &setting.DisplayPhoneNumber, &setting.AccessToken,
+		&setting.AppSecret, &setting.VerifyToken, &setting.APIBaseURL,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.WhatsAppSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.WhatsAppSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+func (w *WhatsAppDB) FetchWhatsAppSettingById(
+	ctx context.Context, workspaceId string) (models.WhatsAppSetting, error) {
+	var setting models.WhatsAppSetting
+
+	q := builq.New()
+	cols := whatsAppSettingCols()
+	q("SELECT %s FROM whatsapp_setting", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.WhatsAppSetting{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = w.db.QueryRow(ctx, stmt, workspaceId).Scan(
+		&setting.WorkspaceId, &setting.IsEnabled, &setting.PhoneNumberId,
+		&setting.BusinessAccountId, &setting.DisplayPhoneNumber, &setting.AccessToken,
+		&setting.AppSecret, &setting.VerifyToken, &setting.APIBaseURL,
+		&setting.CreatedAt, &setting.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.WhatsAppSetting{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return models.WhatsAppSetting{}, ErrQuery
+	}
+	return setting, nil
+}
+
+// InsertWhatsAppMessageLogTx inserts the WhatsApp message log of the Thread message in the transaction.
+func InsertWhatsAppMessageLogTx(ctx context.Context, tx pgx.Tx, messageLog *models.WhatsAppMessageLog) error {
+	q := builq.New()
+	cols := whatsAppMessageLogCols()
+	insertParams := []any{
+		messageLog.MessageId, messageLog.WAMessageId, messageLog.WaId,
+		messageLog.MessageType, messageLog.Kind, messageLog.Status,
+		messageLog.Reaction, messageLog.ErrorCode, messageLog.ErrorTitle,
+		messageLog.Payload, messageLog.CreatedAt, messageLog.UpdatedAt,
+	}
+
+	q("INSERT INTO whatsapp_message_log (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	_, err = tx.Exec(ctx, stmt, insertParams...)
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return ErrQuery
+	}
+	return nil
+}
+
+func (w *WhatsAppDB) CheckWhatsAppInboundMessageExists(ctx context.Context, waMessageId string) (bool, error) {
+	var isExist bool
+	stmt := `SELECT EXISTS(
+		SELECT 1 FROM whatsapp_message_log
+		WHERE wa_message_id = $1 AND message_type = 'inbound'
+	)`
+
+	err := w.db.QueryRow(ctx, stmt, waMessageId).Scan(&isExist)
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return false, ErrQuery
+	}
+	return isExist, nil
+}
+
+// FetchOpenCustomerWhatsAppThreadId returns the Customer's most recent WhatsApp Thread yet to be done.
+func (w *WhatsAppDB) FetchOpenCustomerWhatsAppThreadId(
+	ctx context.Context, workspaceId, customerId string) (string, error) {
+	var threadId string
+	channel := models.ThreadChannel{}.WhatsApp()
+	status := (&models.ThreadStatus{}).Todo()
+
+	q := builq.New()
+	q("SELECT thread_id FROM thread")
+	q("WHERE workspace_id = %$ AND customer_id = %$", workspaceId, customerId)
+	q("AND channel = %$ AND status = %$", channel, status)
+	q("ORDER BY created_at DESC")
+	q("LIMIT 1")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return "", ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = w.db.QueryRow(ctx, stmt, workspaceId, customerId, channel, status).Scan(&threadId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return "", ErrQuery
+	}
+	return threadId, nil
+}
+
+// FetchLastCustomerWhatsAppInboundAt returns when the Customer's last inbound WhatsApp message was received.
+func (w *WhatsAppDB) FetchLastCustomerWhatsAppInboundAt(
+	ctx context.Context, workspaceId, customerId string) (time.Time, error) {
+	var lastInboundAt time.Time
+
+	q := builq.New()
+	q("SELECT wml.created_at FROM whatsapp_message_log wml")
+	q("INNER JOIN message m ON m.message_id = wml.message_id")
+	q("INNER JOIN thread th ON th.thread_id = m.thread_id")
+	q("WHERE th.workspace_id = %$ AND th.customer_id = %$", workspaceId, customerId)
+	q("AND wml.message_type = 'inbound'")
+	q("ORDER BY wml.created_at DESC")
+	q("LIMIT 1")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return time.Time{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = w.db.QueryRow(ctx, stmt, workspaceId, customerId).Scan(&lastInboundAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return time.Time{}, ErrQuery
+	}
+	return lastInboundAt, nil
+}
+
+// ModifyWhatsAppMessageLogStatus updates the status of the outbound WhatsApp message.
+// Status only moves forward, out of order status webhooks are ignored.
+func (w *WhatsAppDB) ModifyWhatsAppMessageLogStatus(
+	ctx context.Context, update models.WhatsAppStatusUpdate) (models.WhatsAppMessageLog, error) {
+	var messageLog models.WhatsAppMessageLog
+	precedes := models.WhatsAppStatus{}.Precedes(update.Status)
+	if len(precedes) == 0 {
+		return models.WhatsAppMessageLog{}, ErrEmpty
+	}
+
+	q := builq.New()
+	cols := whatsAppMessageLogCols()
+
+	q("UPDATE whatsapp_message_log SET")
+	q("status = %$, error_code = COALESCE(%$, error_code),", update.Status, update.ErrorCode)
+	q("error_title = COALESCE(%$, error_title), updated_at = NOW()", update.ErrorTitle)
+	q("WHERE wa_message_id = %$", update.WAMessageId)
+	q("AND message_type = 'outbound' AND status IN (%+$)", precedes)
+	q("RETURNING %s", cols)
+
+	// Params include the expanded preceding statuses.
+	stmt, params, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.WhatsAppMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = w.db.QueryRow(ctx, stmt, params...).Scan(
+		&messageLog.MessageId, &messageLog.WAMessageId, &messageLog.WaId,
+		&messageLog.MessageType, &messageLog.Kind, &messageLog.Status,
+		&messageLog.Reaction, &messageLog.ErrorCode, &messageLog.ErrorTitle,
+		&messageLog.Payload, &messageLog.CreatedAt, &messageLog.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.WhatsAppMessageLog{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.WhatsAppMessageLog{}, ErrQuery
+	}
+	return messageLog, nil
+}
+
+// ModifyWhatsAppMessageReaction sets the Customer's reaction on the message, empty emoji removes the reaction.
+func (w *WhatsAppDB) ModifyWhatsAppMessageReaction(
+	ctx context.Context, reaction models.WhatsAppReaction) (models.WhatsAppMessageLog, error) {
+	var messageLog models.WhatsAppMessageLog
+	var emoji *string
+	if reaction.Emoji != "" {
+		emoji = &reaction.Emoji
+	}
+
+	q := builq.New()
+	cols := whatsAppMessageLogCols()
+
+	q("UPDATE whatsapp_message_log SET")
+	q("reaction = %$, updated_at = NOW()", emoji)
+	q("WHERE wa_message_id = %$", reaction.TargetMessageId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.WhatsAppMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = w.db.QueryRow(ctx, stmt, emoji, reaction.TargetMessageId).Scan(
+		&messageLog.MessageId, &messageLog.WAMessageId, &messageLog.WaId,
+		&messageLog.MessageType, &messageLog.Kind, &messageLog.Status,
+		&messageLog.Reaction, &messageLog.ErrorCode, &messageLog.ErrorTitle,
+		&messageLog.Payload, &messageLog.CreatedAt, &messageLog.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.WhatsAppMessageLog{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.WhatsAppMessageLog{}, ErrQuery
+	}
+	return messageLog, nil
+}
+
+// FetchWhatsAppMessageLogsByThreadId returns the WhatsApp message logs of the Thread messages.
+func (w *WhatsAppDB) FetchWhatsAppMessageLogsByThreadId(
+	ctx context.Context, threadId string) ([]models.WhatsAppMessageLog, error) {
+	var messageLog models.WhatsAppMessageLog
+	logs := make([]models.WhatsAppMessageLog, 0, 100)
+
+	q := builq.New()
+	q("SELECT %s FROM whatsapp_message_log wml", builq.Columns{
+		"wml.message_id", "wml.wa_message_id", "wml.wa_id",
+		"wml.message_type", "wml.kind", "wml.status",
+		"wml.reaction", "wml.error_code", "wml.error_title",
+		"wml.payload", "wml.created_at", "wml.updated_at",
+	})
+	q("INNER JOIN message m ON m.message_id = wml.message_id")
+	q("WHERE m.thread_id = %$", threadId)
+	q("ORDER BY wml.created_at DESC")
+	q("LIMIT 100")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.WhatsAppMessageLog{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := w.db.Query(ctx, stmt, threadId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{
+		&messageLog.MessageId, &messageLog.WAMessageId, &messageLog.WaId,
+		&messageLog.MessageType, &messageLog.Kind, &messageLog.Status,
+		&messageLog.Reaction, &messageLog.ErrorCode, &messageLog.ErrorTitle,
+		&messageLog.Payload, &messageLog.CreatedAt, &messageLog.UpdatedAt,
+	}, func() error {
+		logs = append(logs, messageLog)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.WhatsAppMessageLog{}, ErrQuery
+	}
+	return logs, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/cristalhq/builq"
 	"github.com/jackc/pgerrcode"
@@ -527,14 +528,14 @@ func (wrk *WorkspaceDB) LookupWidgetSessionById(
 	var session models.WidgetSession
 
 	stmt := `SELECT
-		session_id, widget_id, data, expire_at,
-		created_at, updated_at
+		session_id, widget_id, customer_id, data, expire_at,
+		revoked_at, created_at, updated_at
 		FROM widget_session WHERE widget_id = $1 AND session_id = $2`
 
 	err := wrk.db.QueryRow(ctx, stmt, widgetId, sessionId).Scan(
-		&session.SessionId, &session.WidgetId,
+		&session.SessionId, &session.WidgetId, &session.CustomerId,
 		&session.Data, &session.ExpireAt,
-		&session.CreatedAt, &session.UpdatedAt,
+		&session.RevokedAt, &session.CreatedAt, &session.UpdatedAt,
 	)
 
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -553,23 +554,28 @@ func (wrk *WorkspaceDB) LookupWidgetSessionById(
 func (wrk *WorkspaceDB) UpsertWidgetSessionById(
 	ctx context.Context, session models.WidgetSession) (models.WidgetSession, bool, error) {
 	stmt := `WITH ins AS (
-		INSERT INTO widget_session (session_id, widget_id, data, expire_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO widget_session (session_id, widget_id, customer_id, data, expire_at)
+		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (session_id) DO UPDATE SET
-			data = $3,
-			expire_at = $4,
+			customer_id = $3,
+			data = $4,
+			expire_at = $5,
 			updated_at = now()
-		RETURNING session_id, widget_id, data, expire_at, created_at, updated_at, TRUE AS is_created
+		RETURNING session_id, widget_id, customer_id, data, expire_at, revoked_at,
+		created_at, updated_at, TRUE AS is_created
 	)
 	SELECT * FROM ins
 	UNION ALL
-	SELECT session_id, widget_id, data, expire_at, created_at, updated_at, FALSE AS is_created FROM widget_session
+	SELECT session_id, widget_id, customer_id, data, expire_at, revoked_at,
+	created_at, updated_at, FALSE AS is_created FROM widget_session
 	WHERE session_id = $1 AND NOT EXISTS (SELECT 1 FROM ins)`
 
 	var isCreated bool
-	err := wrk.db.QueryRow(ctx, stmt, session.SessionId, session.WidgetId, session.Data, session.ExpireAt).Scan(
-		&session.SessionId, &session.WidgetId, &session.Data, &session.ExpireAt,
-		&session.CreatedAt, &session.UpdatedAt, &isCreated,
+	err := wrk.db.QueryRow(
+		ctx, stmt, session.SessionId, session.WidgetId, session.CustomerId, session.Data, session.ExpireAt,
+	).Scan(
+		&session.SessionId, &session.WidgetId, &session.CustomerId, &session.Data, &session.ExpireAt,
+		&session.RevokedAt, &session.CreatedAt, &session.UpdatedAt, &isCreated,
 	)
 
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -675,3 +681,236 @@ func (wrk *WorkspaceDB) LookupSystemMemberByOldest(
 	}
 	return member, nil
 }
+
+func webhookCredentialCols() builq.Columns {
+	return builq.Columns{
+		"credential_id",
+		"workspace_id",
+		"mailbox_id",
+		"secret_hash",
+		"secret_hint",
+		"expires_at",
+		"created_at",
+		"updated_at",
+	}
+}
+
+func webhookCredentialDest(credential *models.WebhookCredential) []any {
+	return []any{
+		&credential.CredentialId, &credential.WorkspaceId, &credential.MailboxId,
+		&credential.SecretHash, &credential.SecretHint,
+		&credential.ExpiresAt, &credential.CreatedAt, &credential.UpdatedAt,
+	}
+}
+
+// RotateWebhookCredential inserts the new webhook credential, in the same transaction the other credentials of the
+// workspace or of the same mailbox expire no later than expiresAt and the already expired credentials are deleted.
+func (wrk *WorkspaceDB) RotateWebhookCredential(
+	ctx context.Context, credential models.WebhookCredential, expiresAt time.Time,
+) (models.WebhookCredential, error) {
+	tx, err := wrk.db.Begin(ctx)
+	if err != nil {
+		slog.Error("failed to begin transaction", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrTxQuery
+	}
+
+	defer func(tx pgx.Tx, ctx context.Context) {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			slog.Error("failed to rollback transaction", slog.Any("err", err))
+		}
+	}(tx, ctx)
+
+	q := builq.New()
+	q("DELETE FROM webhook_credential")
+	q("WHERE workspace_id = %$ AND expires_at <= NOW()", credential.WorkspaceId)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	if _, err := tx.Exec(ctx, stmt, credential.WorkspaceId); err != nil {
+		slog.Error("failed to delete query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrQuery
+	}
+
+	q = builq.New()
+	q("UPDATE webhook_credential SET expires_at = %$, updated_at = NOW()", expiresAt)
+	q("WHERE workspace_id = %$ AND mailbox_id IS NOT DISTINCT FROM %$", credential.WorkspaceId, credential.MailboxId)
+	q("AND (expires_at IS NULL OR expires_at > %$)", expiresAt)
+
+	stmt, params, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	if _, err := tx.Exec(ctx, stmt, params...); err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrQuery
+	}
+
+	q = builq.New()
+	cols := webhookCredentialCols()
+	insertParams := []any{
+		credential.CredentialId, credential.WorkspaceId, credential.MailboxId,
+		credential.SecretHash, credential.SecretHint,
+		credential.ExpiresAt, credential.CreatedAt, credential.UpdatedAt,
+	}
+
+	q("INSERT INTO webhook_credential (%s)", cols)
+	q("VALUES (%+$)", insertParams)
+	q("RETURNING %s", cols)
+
+	stmt, _, err = q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = tx.QueryRow(ctx, stmt, insertParams...).Scan(webhookCredentialDest(&credential)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Error("no rows returned", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to insert query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrQuery
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		slog.Error("failed to commit query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrTxQuery
+	}
+	return credential, nil
+}
+
+// FetchWebhookCredentialsByWorkspaceId returns the workspace webhook credentials, the latest first.
+func (wrk *WorkspaceDB) FetchWebhookCredentialsByWorkspaceId(
+	ctx context.Context, workspaceId string) ([]models.WebhookCredential, error) {
+	var credential models.WebhookCredential
+	credentials := make([]models.WebhookCredential, 0, 2)
+
+	q := builq.New()
+	cols := webhookCredentialCols()
+	q("SELECT %s FROM webhook_credential", cols)
+	q("WHERE workspace_id = %$", workspaceId)
+	q("ORDER BY created_at DESC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []models.WebhookCredential{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := wrk.db.Query(ctx, stmt, workspaceId)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, webhookCredentialDest(&credential), func() error {
+		credentials = append(credentials, credential)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []models.WebhookCredential{}, ErrQuery
+	}
+	return credentials, nil
+}
+
+// FetchWorkspaceIdsWithoutWebhookCredential returns the workspaces without the workspace webhook credential issued,
+// the credentials of the workspace mailboxes are not of the workspace.
+func (wrk *WorkspaceDB) FetchWorkspaceIdsWithoutWebhookCredential(ctx context.Context) ([]string, error) {
+	var workspaceId string
+	workspaceIds := make([]string, 0, 100)
+
+	q := builq.New()
+	q("SELECT w.workspace_id FROM workspace w")
+	q("WHERE NOT EXISTS (")
+	q("SELECT 1 FROM webhook_credential wc WHERE wc.workspace_id = w.workspace_id AND wc.mailbox_id IS NULL")
+	q(")")
+	q("ORDER BY w.created_at ASC")
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return []string{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	rows, _ := wrk.db.Query(ctx, stmt)
+
+	defer rows.Close()
+
+	_, err = pgx.ForEachRow(rows, []any{&workspaceId}, func() error {
+		workspaceIds = append(workspaceIds, workspaceId)
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to query", slog.Any("err", err))
+		return []string{}, ErrQuery
+	}
+	return workspaceIds, nil
+}
+
+// ExpireWebhookCredential expires the workspace webhook credential at expiresAt, if not expired earlier.
+func (wrk *WorkspaceDB) ExpireWebhookCredential(
+	ctx context.Context, workspaceId string, credentialId string, expiresAt time.Time,
+) (models.WebhookCredential, error) {
+	var credential models.WebhookCredential
+
+	q := builq.New()
+	cols := webhookCredentialCols()
+	q("UPDATE webhook_credential SET")
+	q("expires_at = LEAST(COALESCE(expires_at, %$), %$), updated_at = NOW()", expiresAt, expiresAt)
+	q("WHERE workspace_id = %$ AND credential_id = %$", workspaceId, credentialId)
+	q("RETURNING %s", cols)
+
+	stmt, _, err := q.Build()
+	if err != nil {
+		slog.Error("failed to build query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrQuery
+	}
+
+	if zyg.DBQueryDebug() {
+		debug := q.DebugBuild()
+		debugQuery(debug)
+	}
+
+	err = wrk.db.QueryRow(ctx, stmt, expiresAt, expiresAt, workspaceId, credentialId).Scan(
+		webhookCredentialDest(&credential)...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.WebhookCredential{}, ErrEmpty
+	}
+	if err != nil {
+		slog.Error("failed to update query", slog.Any("err", err))
+		return models.WebhookCredential{}, ErrQuery
+	}
+	return credential, nil
+}
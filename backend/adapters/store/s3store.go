@@ -7,6 +7,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"io"
 	"time"
 )
 
@@ -73,3 +74,16 @@ func PresignedUrl(ctx context.Context, s3Client S3Config, key string, expiresIn
 	}
 	return presignedReq.URL, nil
 }
+
+// GetObject returns the content of the object with the key.
+func GetObject(ctx context.Context, s3Client S3Config, key string) ([]byte, error) {
+	out, err := s3Client.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s3Client.BucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
@@ -54,6 +54,10 @@ func AuthenticateCustomer(
 			slog.Error("failed to fetch customer", slog.Any("err", err))
 			return customer, fmt.Errorf("failed to validate customer with customer id: %s got error: %v", sub, err)
 		}
+		// blocked customer's issued tokens are no longer valid.
+		if customer.IsBlocked {
+			return customer, fmt.Errorf("authenticated sub customer is blocked")
+		}
 		return customer, nil
 	} else {
 		return customer, fmt.Errorf("unsupported scheme: `%s` cannot authenticate", scheme)
@@ -6,11 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/store"
 	"github.com/zyghq/zyg/models"
 	"github.com/zyghq/zyg/services"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"time"
 )
@@ -63,6 +66,22 @@ func (h *CustomerHandler) handleInitWidget(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// Refuse the blocked senders by the claimed email address or the client IP.
+	var blockEmail string
+	if reqp.CustomerEmail != nil {
+		blockEmail = *reqp.CustomerEmail
+	}
+	blocked, err := h.bls.CheckSender(ctx, widget.WorkspaceId, blockEmail, clientIP(r))
+	if err != nil {
+		slog.Error("failed to check widget sender blocklist", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if blocked.IsBlocked {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
 	// Get or generate a new secret key for the workspace.
 	sk, err := h.ws.GetOrGenerateSecretKey(ctx, widget.WorkspaceId)
 	if err != nil {
@@ -170,6 +189,11 @@ func (h *CustomerHandler) handleInitWidget(w http.ResponseWriter, r *http.Reques
 		// Check if the session with the session ID is already created and verify the session.
 		// Otherwise, create a new session with an anonymous customer.
 		customer, err = h.ws.ValidateWidgetSession(ctx, sk.Hmac, widget.WidgetId, sessionId.String)
+		// Revoked or blocked sessions are refused, instead of starting a new session.
+		if errors.Is(err, services.ErrWidgetSessionRevoked) || errors.Is(err, services.ErrCustomerBlocked) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
 		if errors.Is(err, services.ErrWidgetSessionInvalid) {
 			customer, isCreated, err = h.ws.CreateWidgetSession(
 				ctx, sk.Hmac, widget.WorkspaceId, widget.WidgetId, sessionId.String, customerName)
@@ -188,6 +212,26 @@ func (h *CustomerHandler) handleInitWidget(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if customer.IsBlocked {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	// Keep the customer's locale for the transactional mails, as provided by the widget,
+	// otherwise from the browser's preferred language.
+	rawLocale := r.Header.Get("Accept-Language")
+	if reqp.Locale != nil {
+		rawLocale = *reqp.Locale
+	}
+	if locale := models.NormalizeLocale(rawLocale); locale != "" && locale != customer.Locale {
+		customer.Locale = locale
+		if updated, err := h.cs.UpdateCustomer(ctx, customer); err != nil {
+			slog.Error("failed to update customer locale", slog.Any("err", err))
+		} else {
+			customer = updated
+		}
+	}
+
 	// Generate JWT token for the customer and secret key.
 	jwt, err := h.cs.GenerateCustomerJwt(customer, sk.Hmac)
 	if err != nil {
@@ -324,8 +368,16 @@ func (h *CustomerHandler) handleCreateThreadChat(
 
 	ctx := r.Context()
 
+	spam, err := h.sps.CheckInbound(ctx, chatSpamCandidate(customer, reqp.Message))
+	if err != nil {
+		slog.Error("failed to check thread chat for spam", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
 	// Add claimed email for verification, only if the customer's email is not verified yet.
-	if !customer.IsEmailVerified && reqp.Email != nil {
+	// Spam is not notified.
+	if !customer.IsEmailVerified && reqp.Email != nil && !spam.IsSpam {
 		redirectTo := zyg.LandingPageUrl() + "/?utm_source=zyg&utm_medium=kyc"
 		if reqp.RedirectHost != nil {
 			redirectTo = *reqp.RedirectHost + "/?utm_source=zyg&utm_medium=kyc"
@@ -374,10 +426,10 @@ func (h *CustomerHandler) handleCreateThreadChat(
 		return
 	}
 
-	thread, message, err := h.ths.CreateInboundThreadChat(
-		ctx, customer.WorkspaceId, *customer, member.AsMemberActor(),
-		reqp.Message,
-	)
+	inbound := models.NewChatInbound(customer.CustomerId, nil, reqp.Message)
+	inbound.Spam = spam
+	thread, message, err := h.chs.ProcessInbound(
+		ctx, customer.WorkspaceId, *customer, member.AsMemberActor(), inbound)
 	if err != nil {
 		slog.Error("failed to create thread chat message", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -449,7 +501,33 @@ func (h *CustomerHandler) handleCreateThreadChatMessage(
 		return
 	}
 
-	message, err := h.ths.AppendInboundThreadChat(ctx, thread, reqp.Message)
+	// Return the system member for the workspace
+	// Customer reply might transition the thread stage, which is done by the system member.
+	member, err := h.ws.GetSystemMember(ctx, customer.WorkspaceId)
+	if errors.Is(err, services.ErrMemberNotFound) {
+		member, err = h.ws.CreateNewSystemMember(ctx, customer.WorkspaceId)
+	}
+	if err != nil {
+		slog.Error("failed to fetch system member", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	spam, err := h.sps.CheckInbound(ctx, chatSpamCandidate(customer, reqp.Message))
+	if err != nil {
+		slog.Error("failed to check thread chat message for spam", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	inbound := models.NewChatInbound(customer.CustomerId, &thread.ThreadId, reqp.Message)
+	inbound.Spam = spam
+	_, message, err := h.chs.ProcessInbound(
+		ctx, customer.WorkspaceId, *customer, member.AsMemberActor(), inbound)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		slog.Error("failed to create thread chat message", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -475,7 +553,7 @@ func (h *CustomerHandler) handleCreateThreadChatMessage(
 		MessageId:    message.MessageId,
 		TextBody:     message.TextBody,
 		MarkdownBody: message.MarkdownBody,
-		HTMLBody:     message.HTMLBody,
+		HTMLBody:     sanitizedHTMLBody(message.HTMLBody),
 		Customer:     messageCustomer,
 		Member:       messageMember,
 		Channel:      message.Channel,
@@ -508,7 +586,7 @@ func (h *CustomerHandler) handleGetThreadChatMessages(
 		return
 	}
 
-	messages, err := h.ths.ListThreadMessages(ctx, thread.ThreadId)
+	messages, err := h.ths.ListThreadMessagesWithAttachments(ctx, thread.ThreadId)
 	if err != nil {
 		slog.Error("failed to fetch thread messages", slog.Any("err", err))
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -517,6 +595,9 @@ func (h *CustomerHandler) handleGetThreadChatMessages(
 
 	items := make([]MessageResp, 0, 100)
 	for _, message := range messages {
+		if message.IsInternal() {
+			continue
+		}
 		var messageCustomer *CustomerActorResp
 		var messageMember *MemberActorResp
 		if message.Customer != nil {
@@ -535,9 +616,10 @@ func (h *CustomerHandler) handleGetThreadChatMessages(
 			MessageId:    message.MessageId,
 			TextBody:     message.TextBody,
 			MarkdownBody: message.MarkdownBody,
-			HTMLBody:     message.HTMLBody,
+			HTMLBody:     sanitizedHTMLBody(message.HTMLBody),
 			Customer:     messageCustomer,
 			Member:       messageMember,
+			Attachments:  widgetAttachments(message.Attachments),
 			Channel:      message.Channel,
 			CreatedAt:    message.CreatedAt,
 			UpdatedAt:    message.UpdatedAt,
@@ -553,6 +635,107 @@ func (h *CustomerHandler) handleGetThreadChatMessages(
 	}
 }
 
+// widgetAttachments returns the message attachments shown in the widget, failed and spam attachments are skipped.
+func widgetAttachments(attachments []models.MessageAttachment) []MessageAttachmentResp {
+	items := make([]MessageAttachmentResp, 0, len(attachments))
+	for _, a := range attachments {
+		if a.HasError || a.Spam {
+			continue
+		}
+		items = append(items, MessageAttachmentResp{
+			AttachmentId: a.AttachmentId,
+			MessageId:    a.MessageId,
+			Name:         a.Name,
+			ContentType:  a.ContentType,
+		})
+	}
+	return items
+}
+
+// handleGetThreadChatMessageAttachment returns the attachment of the customer's chat thread message
+// with the presigned content URL.
+func (h *CustomerHandler) handleGetThreadChatMessageAttachment(
+	w http.ResponseWriter, r *http.Request, customer *models.Customer) {
+	ctx := r.Context()
+
+	threadId := r.PathValue("threadId")
+	channel := models.ThreadChannel{}.InAppChat()
+	thread, err := h.ths.GetWorkspaceThread(ctx, customer.WorkspaceId, threadId, &channel)
+	if errors.Is(err, services.ErrThreadNotFound) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.Error("failed to fetch thread", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if thread.Customer.CustomerId != customer.CustomerId {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	messages, err := h.ths.ListThreadMessagesWithAttachments(ctx, thread.ThreadId)
+	if err != nil {
+		slog.Error("failed to fetch thread messages", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	messageId := r.PathValue("messageId")
+	attachmentId := r.PathValue("attachmentId")
+	var attachment *models.MessageAttachment
+	for _, message := range messages {
+		if message.MessageId != messageId || message.IsInternal() {
+			continue
+		}
+		for _, a := range message.Attachments {
+			if a.AttachmentId == attachmentId && !a.HasError && !a.Spam {
+				attachment = &a
+				break
+			}
+		}
+	}
+	if attachment == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	accountId := zyg.CFAccountId()
+	accessKeyId := zyg.R2AccessKeyId()
+	accessKeySecret := zyg.R2AccessSecretKey()
+	s3Bucket := zyg.S3Bucket()
+	s3Client, err := store.NewS3(ctx, s3Bucket, accountId, accessKeyId, accessKeySecret)
+	if err != nil {
+		slog.Error("failed to create s3 client", slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	expiresIn := time.Now().Add(time.Hour) // widget links are short-lived.
+	signedUrl, err := store.PresignedUrl(ctx, s3Client, attachment.ContentKey, expiresIn)
+	if err != nil {
+		slog.Error(
+			"failed to generate attachment signed url",
+			slog.Any("attachmentId", attachment.AttachmentId),
+			slog.Any("err", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := MessageAttachmentResp{
+		AttachmentId: attachment.AttachmentId,
+		MessageId:    attachment.MessageId,
+		Name:         attachment.Name,
+		ContentType:  attachment.ContentType,
+		ContentUrl:   signedUrl,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode json", slog.Any("error", err))
+	}
+}
+
 // (XXX) not an API endpoint, will be used for redirecting from mail verification URL.
 // In all the cases we redirect to either the default target URL or the URL provided in the JWT token.
 func (h *CustomerHandler) handleMailRedirectKyc(w http.ResponseWriter, r *http.Request) {
@@ -640,3 +823,59 @@ func (h *CustomerHandler) handleMailRedirectKyc(w http.ResponseWriter, r *http.R
 
 	http.Redirect(w, r, redirectTo, http.StatusFound)
 }
+
+// chatSpamCandidate returns the spam candidate for the customer's chat message.
+func chatSpamCandidate(customer *models.Customer, message string) models.SpamCandidate {
+	candidate := models.SpamCandidate{
+		WorkspaceId: customer.WorkspaceId,
+		Channel:     models.ThreadChannel{}.InAppChat(),
+		CustomerId:  customer.CustomerId,
+		TextBody:    message,
+	}
+	if customer.Email.Valid {
+		candidate.FromEmail = customer.Email.String
+	}
+	return candidate
+}
+
+// clientIP returns the client IP address of the request. The `X-Forwarded-For` is only honored if the request
+// is from the trusted proxy, the client IP is the nearest address not of the trusted proxies.
+// Returns empty string if the address is not valid.
+func clientIP(r *http.Request) string {
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip, err := netip.ParseAddr(strings.TrimSpace(addr))
+	if err != nil {
+		return ""
+	}
+	ip = ip.Unmap()
+
+	proxies := zyg.TrustedProxies()
+	isTrusted := func(ip netip.Addr) bool {
+		for _, proxy := range proxies {
+			if proxy.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	fwdHeader := strings.Join(r.Header.Values("X-Forwarded-For"), ",")
+	if fwdHeader == "" || !isTrusted(ip) {
+		return ip.String()
+	}
+	// Forwarded addresses are appended by each proxy, the rightmost are the most trusted.
+	forwarded := strings.Split(fwdHeader, ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		fwd, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			return ""
+		}
+		ip = fwd.Unmap()
+		if !isTrusted(ip) {
+			return ip.String()
+		}
+	}
+	return ip.String()
+}
@@ -3,9 +3,13 @@ package xhandler
 import (
 	"database/sql"
 	"encoding/json"
+	"log/slog"
+	"net/url"
 	"time"
 
+	"github.com/zyghq/zyg"
 	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/utils"
 )
 
 // CustomerResp represents the API response for a customer.
@@ -85,6 +89,7 @@ type WidgetInitReq struct {
 	CustomerEmail      *string         `json:"customerEmail"`
 	CustomerPhone      *string         `json:"customerPhone"`
 	Traits             *CustomerTraits `json:"traits"`
+	Locale             *string         `json:"locale"`
 }
 
 type WidgetInitResp struct {
@@ -263,12 +268,52 @@ type MessageResp struct {
 	HTMLBody     string
 	Customer     *CustomerActorResp
 	Member       *MemberActorResp
+	Attachments  []MessageAttachmentResp
 	// Deprecated
 	Channel   string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+// MessageAttachmentResp is the message attachment rendered in the widget,
+// the content URL is only set when the attachment is fetched.
+type MessageAttachmentResp struct {
+	AttachmentId string `json:"attachmentId"`
+	MessageId    string `json:"messageId"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	ContentUrl   string `json:"contentUrl,omitempty"`
+}
+
+// sanitizedHTMLBody returns the message HTML as served to the Customer in the widget, with only the allowlisted
+// tags and attributes. Inline images are removed as the attachments are served by the attachment endpoint,
+// remote images are loaded through the image proxy if configured.
+func sanitizedHTMLBody(htmlBody string) string {
+	if htmlBody == "" {
+		return ""
+	}
+	opts := utils.HTMLSanitizeOptions{
+		InlineImageURL: func(contentId string) (string, bool) {
+			return "", false
+		},
+	}
+	if proxyUrl, err := url.Parse(zyg.ImageProxyUrl()); err == nil && proxyUrl.Host != "" {
+		opts.ImageProxyURL = func(src string) string {
+			u := *proxyUrl
+			q := u.Query()
+			q.Set("url", src)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	sanitized, err := utils.SanitizeHTML(htmlBody, opts)
+	if err != nil {
+		slog.Error("failed to sanitize message html", slog.Any("err", err))
+		return ""
+	}
+	return sanitized
+}
+
 func (m MessageResp) MarshalJSON() ([]byte, error) {
 	var customer *CustomerActorResp
 	var member *MemberActorResp
@@ -279,18 +324,23 @@ func (m MessageResp) MarshalJSON() ([]byte, error) {
 	if m.Member != nil {
 		member = m.Member
 	}
+	attachments := m.Attachments
+	if attachments == nil {
+		attachments = []MessageAttachmentResp{}
+	}
 
 	aux := &struct {
-		ThreadId     string             `json:"threadId"`
-		MessageId    string             `json:"messageId"`
-		TextBody     string             `json:"textBody"`
-		MarkdownBody string             `json:"markdownBody"`
-		HTMLBody     string             `json:"htmlBody"`
-		Customer     *CustomerActorResp `json:"customer,omitempty"`
-		Member       *MemberActorResp   `json:"member,omitempty"`
-		Channel      string             `json:"channel"`
-		CreatedAt    string             `json:"createdAt"`
-		UpdatedAt    string             `json:"updatedAt"`
+		ThreadId     string                  `json:"threadId"`
+		MessageId    string                  `json:"messageId"`
+		TextBody     string                  `json:"textBody"`
+		MarkdownBody string                  `json:"markdownBody"`
+		HTMLBody     string                  `json:"htmlBody"`
+		Customer     *CustomerActorResp      `json:"customer,omitempty"`
+		Member       *MemberActorResp        `json:"member,omitempty"`
+		Attachments  []MessageAttachmentResp `json:"attachments"`
+		Channel      string                  `json:"channel"`
+		CreatedAt    string                  `json:"createdAt"`
+		UpdatedAt    string                  `json:"updatedAt"`
 	}{
 		ThreadId:     m.ThreadId,
 		MessageId:    m.MessageId,
@@ -299,6 +349,7 @@ func (m MessageResp) MarshalJSON() ([]byte, error) {
 		HTMLBody:     m.HTMLBody,
 		Customer:     customer,
 		Member:       member,
+		Attachments:  attachments,
 		Channel:      m.Channel,
 		CreatedAt:    m.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:    m.UpdatedAt.Format(time.RFC3339),
@@ -395,7 +446,7 @@ func (t ThreadChatResp) NewResponse(thread *models.Thread, message *models.Messa
 		MessageId:    message.MessageId,
 		TextBody:     message.TextBody,
 		MarkdownBody: message.MarkdownBody,
-		HTMLBody:     message.HTMLBody,
+		HTMLBody:     sanitizedHTMLBody(message.HTMLBody),
 		Customer:     messageCustomer,
 		Member:       messageMember,
 		Channel:      message.Channel,
@@ -12,17 +12,26 @@ type CustomerHandler struct {
 	ws  ports.WorkspaceServicer
 	cs  ports.CustomerServicer
 	ths ports.ThreadServicer
+	chs ports.ChannelServicer
+	sps ports.SpamServicer
+	bls ports.BlocklistServicer
 }
 
 func NewCustomerHandler(
 	ws ports.WorkspaceServicer,
 	cs ports.CustomerServicer,
 	ths ports.ThreadServicer,
+	chs ports.ChannelServicer,
+	sps ports.SpamServicer,
+	bls ports.BlocklistServicer,
 ) *CustomerHandler {
 	return &CustomerHandler{
 		ws:  ws,
 		cs:  cs,
 		ths: ths,
+		chs: chs,
+		sps: sps,
+		bls: bls,
 	}
 }
 
@@ -42,11 +51,15 @@ func NewServer(
 	workspaceService ports.WorkspaceServicer,
 	customerService ports.CustomerServicer,
 	threadService ports.ThreadServicer,
+	channelService ports.ChannelServicer,
+	spamService ports.SpamServicer,
+	blocklistService ports.BlocklistServicer,
 ) http.Handler {
 	// init new server mux
 	mux := http.NewServeMux()
 	// init handlers
-	ch := NewCustomerHandler(workspaceService, customerService, threadService)
+	ch := NewCustomerHandler(
+		workspaceService, customerService, threadService, channelService, spamService, blocklistService)
 
 	mux.HandleFunc("GET /{$}", handleGetIndex)
 
@@ -70,6 +83,9 @@ func NewServer(
 	// Returns a list of thread chat messages.
 	mux.Handle("GET /widgets/{widgetId}/threads/chat/{threadId}/messages/{$}",
 		NewEnsureAuth(ch.handleGetThreadChatMessages, authService))
+	// Returns the thread chat message attachment with the content URL.
+	mux.Handle("GET /widgets/{widgetId}/threads/chat/{threadId}/messages/{messageId}/attachments/{attachmentId}/{$}",
+		NewEnsureAuth(ch.handleGetThreadChatMessageAttachment, authService))
 
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
@@ -18,6 +18,12 @@ import (
 	"github.com/zyghq/zyg"
 	"github.com/zyghq/zyg/adapters/handler"
 	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/integrations/email"
+	"github.com/zyghq/zyg/integrations/slack"
+	"github.com/zyghq/zyg/integrations/sms"
+	"github.com/zyghq/zyg/integrations/webhook"
+	"github.com/zyghq/zyg/integrations/whatsapp"
+	"github.com/zyghq/zyg/models"
 	"github.com/zyghq/zyg/services"
 )
 
@@ -26,6 +32,18 @@ var port = flag.String("port", "8080", "port")
 
 var addr string
 
+// Operator commands for the Postmark inbound requests, run instead of the server.
+var listInboundFailures = flag.Bool(
+	"list-inbound-failures", false, "list the failed postmark inbound requests then exit")
+var replayInbound = flag.String(
+	"replay-inbound", "", "replay the postmark inbound request of the message ID, or all the failed with `failed`, then exit")
+
+// Operator command to issue the webhook credential of the workspaces without one and update the Postmark
+// inbound hooks, run instead of the server. Also run on each server start, the server does not start until
+// each workspace is issued. The global webhook credentials are accepted for the overlap after.
+var backfillWebhookCredentials = flag.Bool(
+	"backfill-webhook-credentials", false, "issue the workspace webhook credentials not issued yet then exit")
+
 func run(ctx context.Context) error {
 	var err error
 	ctx, cancel := context.WithCancel(ctx)
@@ -113,14 +131,101 @@ func run(ctx context.Context) error {
 	workspaceStore := repository.NewWorkspaceDB(db)
 	memberStore := repository.NewMemberDB(db)
 	customerStore := repository.NewCustomerDB(db)
+	mailStore := repository.NewMailDB(db)
 	threadStore := repository.NewThreadDB(db, rdb)
+	spamStore := repository.NewSpamDB(db)
+	blocklistStore := repository.NewBlocklistDB(db)
+	smsStore := repository.NewSMSDB(db)
+	whatsAppStore := repository.NewWhatsAppDB(db)
+	slackStore := repository.NewSlackDB(db)
+	apiStore := repository.NewAPIDB(db)
 
 	// init services
 	authService := services.NewAuthService(accountStore, memberStore)
 	accountService := services.NewAccountService(accountStore, workspaceStore)
 	workspaceService := services.NewWorkspaceService(workspaceStore, memberStore, customerStore)
-	customerService := services.NewCustomerService(customerStore)
+
+	// Required migration step of the workspace webhook credentials, the workspace webhooks are not accepted
+	// with the global webhook credentials after the cutover deadline.
+	backfillCtx := sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	issued, err := workspaceService.BackfillWebhookCredentials(backfillCtx)
+	if err != nil {
+		return fmt.Errorf("failed to backfill webhook credentials got error: %v", err)
+	}
+	if *backfillWebhookCredentials {
+		fmt.Printf("issued webhook credentials for %d workspaces\n", issued)
+		return nil
+	}
+	if issued > 0 {
+		slog.Info("backfilled workspace webhook credentials", slog.Any("issued", issued))
+	}
+	// Mail senders are selected as per the workspace mail setting or the deployment default.
+	mailService := services.NewMailService(mailStore, workspaceService,
+		email.NewPostmarkSender(), email.NewResendSender(), email.NewSMTPSender(),
+		email.NewCaptureSender(zyg.MailCaptureAddr()))
+	customerService := services.NewCustomerService(customerStore, mailService)
 	threadService := services.NewThreadService(threadStore)
+	// Channels plugged into the thread message pipeline.
+	channelService := services.NewChannelService(threadStore,
+		services.NewEmailChannel(workspaceService, mailService, customerService, threadStore),
+		services.NewChatChannel(workspaceService, threadStore),
+	)
+	blocklistService := services.NewBlocklistService(blocklistStore)
+	spamService := services.NewSpamService(spamStore, blocklistService)
+	// SMS providers are selected as per the workspace SMS setting.
+	smsService := services.NewSMSService(smsStore, threadStore, sms.NewTwilioProvider(), sms.NewFakeProvider())
+	// WhatsApp Cloud API client, the workspace setting API base URL points to the local stand-in.
+	whatsAppService := services.NewWhatsAppService(whatsAppStore, threadStore, whatsapp.NewCloudClient())
+	slackService := services.NewSlackService(
+		slackStore, threadStore, slack.NewClient(), workspaceService, spamService)
+	// API channel replies are delivered to the workspace webhook.
+	apiService := services.NewAPIService(apiStore, threadStore, webhook.NewSender())
+	threadForwardService := services.NewThreadForwardService(threadStore, workspaceService, mailService)
+	// CSAT, transcript and notification mail of the Thread sent to the Customer as per the workspace setting.
+	customerMailService := services.NewCustomerMailService(workspaceService, threadService, mailService)
+	// Inbound mail from each of the mail sources is processed the same, routed to the workspace mailboxes.
+	mailInboundService := services.NewMailInboundService(
+		mailStore, workspaceService, threadService, channelService, blocklistService, spamService,
+		threadForwardService, email.NewIMAPClient(zyg.InboundMailMaxBytes(), zyg.IMAPInsecureLoginEnabled()))
+
+	if *listInboundFailures || *replayInbound != "" {
+		return runInboundCommand(ctx, mailInboundService)
+	}
+
+	// Failed Postmark inbound requests are retried in the background until the server exits.
+	postmarkInboundRetrier := services.NewPostmarkInboundRetrier(
+		mailInboundService, zyg.PostmarkInboundRetryInterval())
+	go postmarkInboundRetrier.Run(ctx)
+
+	// Queued outbound mail replies are delivered in the background until the server exits.
+	outboundMailSender := services.NewOutboundMailSender(
+		workspaceService, threadService, channelService, zyg.OutboundMailSendInterval())
+	go outboundMailSender.Run(ctx)
+
+	// Follow-up scheduler runs in the background until the server exits.
+	followUpScheduler := services.NewFollowUpScheduler(
+		workspaceService, threadService, channelService, smsService, mailService, customerMailService,
+		zyg.FollowUpSchedulerInterval())
+	go followUpScheduler.Run(ctx)
+
+	// Slack channel sync job runs in the background until the server exits.
+	slackChannelSyncer := services.NewSlackChannelSyncer(slackService, zyg.SlackChannelSyncInterval())
+	go slackChannelSyncer.Run(ctx)
+
+	// IMAP poller runs in the background until the server exits.
+	imapPoller := services.NewIMAPPoller(mailInboundService, zyg.IMAPPollInterval())
+	go imapPoller.Run(ctx)
+
+	// Embedded SMTP listener for the inbound mail, enabled with the listen address.
+	if smtpAddr := zyg.SMTPListenAddr(); smtpAddr != "" {
+		smtpReceiver := email.NewSMTPReceiver(smtpAddr, zyg.ServerDomain(), zyg.InboundMailMaxBytes(),
+			mailInboundService.AcceptSMTPRecipient, mailInboundService.ReceiveSMTPMail)
+		go func() {
+			if err := smtpReceiver.ListenAndServe(ctx); err != nil {
+				slog.Error("smtp receiver stopped", slog.Any("err", err))
+			}
+		}()
+	}
 
 	// init server
 	srv := handler.NewServer(
@@ -129,6 +234,17 @@ func run(ctx context.Context) error {
 		workspaceService,
 		customerService,
 		threadService,
+		channelService,
+		spamService,
+		blocklistService,
+		smsService,
+		whatsAppService,
+		slackService,
+		apiService,
+		mailService,
+		mailInboundService,
+		threadForwardService,
+		customerMailService,
 	)
 
 	// wrap sentry
@@ -149,6 +265,45 @@ func run(ctx context.Context) error {
 	return err
 }
 
+// runInboundCommand lists or replays the failed Postmark inbound requests of all the workspaces.
+// Replay is idempotent, already processed requests are not processed again.
+func runInboundCommand(ctx context.Context, mis *services.MailInboundService) error {
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+	failed := models.InboundRequestStatus{}.Failed()
+
+	var requests []models.PostmarkInboundRequest
+	switch {
+	case *replayInbound != "" && *replayInbound != failed:
+		req, err := mis.GetPostmarkInboundRequest(ctx, *replayInbound)
+		if err != nil {
+			return fmt.Errorf("failed to fetch postmark inbound request got error: %v", err)
+		}
+		requests = append(requests, req)
+	default:
+		reqs, err := mis.ListPostmarkInboundRequests(ctx, nil, failed)
+		if err != nil {
+			return fmt.Errorf("failed to list postmark inbound requests got error: %v", err)
+		}
+		requests = reqs
+	}
+
+	for _, req := range requests {
+		if *replayInbound != "" {
+			replayed, err := mis.ReplayPostmarkInbound(ctx, req)
+			if err != nil {
+				return fmt.Errorf("failed to replay postmark inbound request %s got error: %v", req.MessageId, err)
+			}
+			req = replayed
+		}
+		var reqErr string
+		if req.Error != nil {
+			reqErr = *req.Error
+		}
+		fmt.Printf("%s\t%s\t%s\t%d\t%s\n", req.MessageId, req.WorkspaceId, req.Status, req.Attempts, reqErr)
+	}
+	return nil
+}
+
 func main() {
 	flag.Parse()
 	ctx := context.Background()
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/zyghq/zyg/integrations/whatsapp"
+)
+
+// Local stand-in for the WhatsApp Cloud API.
+// Set the workspace WhatsApp setting API base URL to the stand-in address,
+// and point the stand-in to the workspace webhook URL with the same app secret.
+var host = flag.String("host", "127.0.0.1", "host")
+var port = flag.String("port", "8090", "port")
+var webhook = flag.String("webhook", "", "workspace WhatsApp webhook URL")
+var appSecret = flag.String("app-secret", "", "app secret to sign the webhook payloads")
+
+func run() error {
+	if *webhook == "" || *appSecret == "" {
+		return fmt.Errorf("webhook and app-secret are required")
+	}
+
+	addr := fmt.Sprintf("%s:%s", *host, *port)
+	standIn := whatsapp.NewStandIn(*webhook, *appSecret, "http://"+addr)
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           standIn.Handler(),
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      90 * time.Second,
+		IdleTimeout:       5 * time.Minute,
+		ReadHeaderTimeout: time.Minute,
+	}
+
+	slog.Info("whatsapp stand-in up and running", slog.String("addr", addr))
+	return httpServer.ListenAndServe()
+}
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		_, err := fmt.Fprintf(os.Stderr, "%s\n", err)
+		if err != nil {
+			return
+		}
+		os.Exit(1)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/zyghq/zyg"
 	"github.com/zyghq/zyg/adapters/repository"
 	"github.com/zyghq/zyg/adapters/xhandler"
+	"github.com/zyghq/zyg/integrations/email"
 	"github.com/zyghq/zyg/services"
 )
 
@@ -88,13 +89,24 @@ func run(ctx context.Context) error {
 	workspaceStore := repository.NewWorkspaceDB(db)
 	memberStore := repository.NewMemberDB(db)
 	customerStore := repository.NewCustomerDB(db)
+	mailStore := repository.NewMailDB(db)
 	threadStore := repository.NewThreadDB(db, rdb)
+	spamStore := repository.NewSpamDB(db)
+	blocklistStore := repository.NewBlocklistDB(db)
 
 	// init respective services
 	authService := services.NewCustomerAuthService(customerStore)
 	workspaceService := services.NewWorkspaceService(workspaceStore, memberStore, customerStore)
-	customerService := services.NewCustomerService(customerStore)
+	// Mail senders are selected as per the workspace mail setting or the deployment default.
+	mailService := services.NewMailService(mailStore, workspaceService,
+		email.NewPostmarkSender(), email.NewResendSender(), email.NewSMTPSender(),
+		email.NewCaptureSender(zyg.MailCaptureAddr()))
+	customerService := services.NewCustomerService(customerStore, mailService)
 	threadService := services.NewThreadService(threadStore)
+	// Widget customers only chat.
+	channelService := services.NewChannelService(threadStore, services.NewChatChannel(workspaceService, threadStore))
+	blocklistService := services.NewBlocklistService(blocklistStore)
+	spamService := services.NewSpamService(spamStore, blocklistService)
 
 	// init server
 	srv := xhandler.NewServer(
@@ -102,6 +114,9 @@ func run(ctx context.Context) error {
 		workspaceService,
 		customerService,
 		threadService,
+		channelService,
+		spamService,
+		blocklistService,
 	)
 
 	addr = fmt.Sprintf("%s:%s", *host, *port)
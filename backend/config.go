@@ -3,8 +3,11 @@ package zyg
 import (
 	"fmt"
 	"github.com/google/uuid"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const DefaultSecretKeyLength = 64
@@ -183,6 +186,51 @@ func WebhookPassword() string {
 	return value
 }
 
+// WebhookLegacyAuthUntil returns the cutover deadline the global webhook credentials are accepted until on the
+// workspace webhooks, as RFC 3339 in ZYG_WEBHOOK_LEGACY_AUTH_UNTIL, e.g. `2026-11-01T00:00:00Z`.
+// Returns the zero time when unset or invalid, the global webhook credentials are then not accepted.
+func WebhookLegacyAuthUntil() time.Time {
+	until, err := time.Parse(time.RFC3339, os.Getenv("ZYG_WEBHOOK_LEGACY_AUTH_UNTIL"))
+	if err != nil {
+		return time.Time{}
+	}
+	return until.UTC()
+}
+
+// TrustedProxies returns the proxies the client IP forwarded with `X-Forwarded-For` is trusted from,
+// as the comma separated CIDRs or IPs in ZYG_TRUSTED_PROXIES. Invalid entries are ignored.
+// If not set, no proxy is trusted and the client IP is the remote address of the request.
+func TrustedProxies() []netip.Prefix {
+	var proxies []netip.Prefix
+	for _, value := range strings.Split(os.Getenv("ZYG_TRUSTED_PROXIES"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			proxies = append(proxies, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(value); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return proxies
+}
+
+// ImageProxyUrl returns the proxy the remote images of the message HTML are loaded through when served,
+// the image URL is passed as the `url` query param. Remote images are loaded as is if not set.
+func ImageProxyUrl() string {
+	value, ok := os.LookupEnv("ZYG_IMAGE_PROXY_URL")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// WebhookServerUrl returns the server URL the webhooks are served at, without the credentials.
+func WebhookServerUrl() string {
+	return fmt.Sprintf("%s://%s", ServerProto(), ServerDomain())
+}
+
 func WebhookUrl() string {
 	proto := ServerProto()
 	domain := ServerDomain()
@@ -191,10 +239,243 @@ func WebhookUrl() string {
 	return fmt.Sprintf("%s://%s:%s@%s", proto, u, p, domain)
 }
 
-func PostmarkDeliveryDomain() string {
-	value, ok := os.LookupEnv("POSTMARK_DELIVERY_DOMAIN")
+// FollowUpSchedulerInterval is the interval the follow-up scheduler scans threads waiting on the customer.
+func FollowUpSchedulerInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("ZYG_FOLLOW_UP_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 15 * time.Minute
+	}
+	return interval
+}
+
+// MailThreadSubjectWindow is the window the inbound mail without the matching references is threaded
+// by the subject with the Customer's recently updated Thread.
+func MailThreadSubjectWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("ZYG_MAIL_THREAD_SUBJECT_WINDOW"))
+	if err != nil || window <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return window
+}
+
+func SlackClientId() string {
+	value, ok := os.LookupEnv("SLACK_CLIENT_ID")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+func SlackClientSecret() string {
+	value, ok := os.LookupEnv("SLACK_CLIENT_SECRET")
 	if !ok {
-		return "mtasv.net"
+		return ""
 	}
 	return value
 }
+
+// SlackSigningSecret verifies the Slack Events API request signature.
+func SlackSigningSecret() string {
+	value, ok := os.LookupEnv("SLACK_SIGNING_SECRET")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// DashboardUrl is the origin of the dashboard the Members are redirected back to, e.g. after the Slack app install.
+// If not set, only the relative redirect paths are allowed.
+func DashboardUrl() string {
+	value, ok := os.LookupEnv("ZYG_DASHBOARD_URL")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// SlackRedirectUrl is the OAuth redirect URL of the Slack app installation.
+func SlackRedirectUrl() string {
+	value, ok := os.LookupEnv("SLACK_REDIRECT_URL")
+	if !ok {
+		return fmt.Sprintf("%s://%s/slack/oauth/callback/", ServerProto(), ServerDomain())
+	}
+	return value
+}
+
+// SlackChannelSyncInterval is the interval the Slack channels are synced for the installed Slack workspaces.
+func SlackChannelSyncInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("ZYG_SLACK_SYNC_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Hour
+	}
+	return interval
+}
+
+// MailSender is the deployment mail sender, used for the system mail and for the workspaces
+// without the mail sender of their own. Defaults to Resend if configured, otherwise to the local capture.
+func MailSender() string {
+	value, ok := os.LookupEnv("ZYG_MAIL_SENDER")
+	if !ok {
+		if ResendApiKey() != "" {
+			return "resend"
+		}
+		return "capture"
+	}
+	return value
+}
+
+func MailFromName() string {
+	value, ok := os.LookupEnv("ZYG_MAIL_FROM_NAME")
+	if !ok {
+		return "Zyg"
+	}
+	return value
+}
+
+func MailFromEmail() string {
+	value, ok := os.LookupEnv("ZYG_MAIL_FROM_EMAIL")
+	if !ok {
+		return "noreply@updates.zyg.ai"
+	}
+	return value
+}
+
+func MailReplyTo() string {
+	value, ok := os.LookupEnv("ZYG_MAIL_REPLY_TO")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// PostmarkServerToken is the deployment Postmark server token, when the mail sender is Postmark.
+func PostmarkServerToken() string {
+	value, ok := os.LookupEnv("POSTMARK_SERVER_TOKEN")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+func SMTPHost() string {
+	value, ok := os.LookupEnv("SMTP_HOST")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+func SMTPPort() int {
+	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil || port <= 0 {
+		return 587
+	}
+	return port
+}
+
+func SMTPUsername() string {
+	value, ok := os.LookupEnv("SMTP_USERNAME")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+func SMTPPassword() string {
+	value, ok := os.LookupEnv("SMTP_PASSWORD")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// SMTPSecurity is one of none, starttls or tls.
+func SMTPSecurity() string {
+	value, ok := os.LookupEnv("SMTP_SECURITY")
+	if !ok {
+		return "starttls"
+	}
+	return value
+}
+
+// SMTPAuth is one of none, plain or login.
+func SMTPAuth() string {
+	value, ok := os.LookupEnv("SMTP_AUTH")
+	if !ok {
+		return "plain"
+	}
+	return value
+}
+
+// MailCaptureAddr is the local SMTP capture server address e.g. MailHog, for the capture mail sender.
+func MailCaptureAddr() string {
+	value, ok := os.LookupEnv("ZYG_MAIL_CAPTURE_ADDR")
+	if !ok {
+		return "localhost:1025"
+	}
+	return value
+}
+
+// IMAPPollInterval is the interval the workspace IMAP mailboxes are polled for the inbound mail.
+func IMAPPollInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("ZYG_IMAP_POLL_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// PostmarkInboundRetryInterval is the interval the failed Postmark inbound requests due are retried.
+func PostmarkInboundRetryInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("ZYG_POSTMARK_INBOUND_RETRY_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// OutboundMailSendInterval is the interval the queued outbound mails due are delivered.
+func OutboundMailSendInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("ZYG_OUTBOUND_MAIL_SEND_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 5 * time.Second
+	}
+	return interval
+}
+
+// SMTPListenAddr is the address of the embedded SMTP listener for the inbound mail, disabled if not set.
+func SMTPListenAddr() string {
+	value, ok := os.LookupEnv("ZYG_SMTP_LISTEN_ADDR")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// SMTPInboundDomain is the recipient domain accepted by the SMTP listener, any domain if not set.
+func SMTPInboundDomain() string {
+	value, ok := os.LookupEnv("ZYG_SMTP_INBOUND_DOMAIN")
+	if !ok {
+		return ""
+	}
+	return value
+}
+
+// IMAPInsecureLoginEnabled allows the IMAP LOGIN over the plain connection with the security none,
+// e.g. for the IMAP server on the private network. Disabled by default, the credentials are sent in clear.
+func IMAPInsecureLoginEnabled() bool {
+	enabled, err := strconv.ParseBool(os.Getenv("ZYG_IMAP_INSECURE_LOGIN"))
+	if err != nil {
+		return false
+	}
+	return enabled
+}
+
+// InboundMailMaxBytes is the max size of the raw inbound mail, defaults to 25MB.
+func InboundMailMaxBytes() int64 {
+	size, err := strconv.ParseInt(os.Getenv("ZYG_INBOUND_MAIL_MAX_BYTES"), 10, 64)
+	if err != nil || size <= 0 {
+		return 25 << 20
+	}
+	return size
+}
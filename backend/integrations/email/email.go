@@ -1,12 +1,12 @@
 package email
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/zyghq/postmark"
 	"github.com/zyghq/zyg/integrations"
 	"github.com/zyghq/zyg/models"
-	"log/slog"
+	"net/textproto"
+	"strings"
 	"time"
 )
 
@@ -17,52 +17,80 @@ type PostmarkInboundMessageReq struct {
 	Payload map[string]interface{}
 }
 
-// ToPostmarkInboundMessage converts a PostmarkInboundMessageReq to a PostmarkInboundMessage model instance.
-func (p *PostmarkInboundMessageReq) ToPostmarkInboundMessage() models.PostmarkInboundMessage {
-	now := time.Now().UTC()
-	message := models.PostmarkInboundMessage{
-		Payload:           p.Payload,
-		PostmarkMessageId: p.MessageID, // Postmark MessageID
-		Subject:           p.Subject,
-		TextBody:          p.TextBody,
-		HTMLBody:          p.HTMLBody,
-		FromEmail:         p.FromFull.Email,
-		FromName:          p.FromFull.Name,
-		CreatedAt:         now,
-		UpdatedAt:         now,
-		Attachments:       p.ToPostmarkMessageAttachments(),
+// ToChannelInbound converts a PostmarkInboundMessageReq to the normalized email channel inbound message.
+func (p *PostmarkInboundMessageReq) ToChannelInbound() models.ChannelInbound {
+	message := models.ChannelInbound{
+		Channel:     models.ThreadChannel{}.Email(),
+		ExternalId:  p.MessageID, // Postmark MessageID
+		Payload:     p.Payload,
+		Subject:     p.Subject,
+		TextBody:    p.TextBody,
+		HTMLBody:    p.HTMLBody,
+		FromEmail:   p.FromFull.Email,
+		FromName:    p.FromFull.Name,
+		Attachments: p.ToChannelAttachments(),
+		Headers:     make(map[string]string, len(p.Headers)),
+		ReplyToken:  p.MailboxHash, // Postmark plus address tag of the inbound address
+		To:          inboundAddresses(p.ToFull),
+		Cc:          inboundAddresses(p.CcFull),
+		DeliveredTo: strings.ToLower(p.OriginalRecipient),
+		CreatedAt:   time.Now().UTC(),
 	}
 	for _, h := range p.Headers {
+		key := textproto.CanonicalMIMEHeaderKey(h.Name)
+		if _, ok := message.Headers[key]; !ok {
+			message.Headers[key] = h.Value
+		}
 		if h.Name == "Message-ID" {
-			message.MailMessageId = h.Value // From mail protocol headers
+			messageId := h.Value // From mail protocol headers
+			message.ExternalRef = &messageId
 		}
 		if h.Name == "In-Reply-To" {
-			message.ReplyMailMessageId = &h.Value // From mail protocol headers
+			replyTo := h.Value // From mail protocol headers
+			message.ReplyRef = &replyTo
+		}
+		if key == "References" {
+			message.References = messageIds(h.Value)
 		}
 	}
 	// If this message is a reply to an existing mail message ID
-	// check if the stripped text is provided - take that as the text body instead.
-	if message.ReplyMailMessageId != nil && p.StrippedTextReply != "" {
-		message.TextBody = p.StrippedTextReply
+	// check if the stripped text is provided - take that as the reply of the text body.
+	if message.ReplyRef != nil && p.StrippedTextReply != "" {
+		message.TextReply = p.StrippedTextReply
 	}
 	return message
 }
 
-func (p *PostmarkInboundMessageReq) ToPostmarkMessageAttachments() []models.PostmarkMessageAttachment {
-	var attachments []models.PostmarkMessageAttachment
-	now := time.Now().UTC()
+func (p *PostmarkInboundMessageReq) ToChannelAttachments() []models.ChannelAttachment {
+	var attachments []models.ChannelAttachment
 	for _, m := range p.Attachments {
-		attachments = append(attachments, models.PostmarkMessageAttachment{
-			CreatedAt:   now,
-			UpdatedAt:   now,
+		attachments = append(attachments, models.ChannelAttachment{
 			Name:        m.Name,
 			ContentType: m.ContentType,
 			Content:     m.Content,
+			ContentId:   contentId(m.ContentID),
 		})
 	}
 	return attachments
 }
 
+// contentId returns the inline attachment content ID without the enclosing angle brackets.
+func contentId(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
+
+// inboundAddresses converts the Postmark inbound recipients to mail addresses, the email is lower cased.
+func inboundAddresses(recipients []postmark.InboundRecipient) []models.MailAddress {
+	var addrs []models.MailAddress
+	for _, r := range recipients {
+		if r.Email == "" {
+			continue
+		}
+		addrs = append(addrs, models.MailAddress{Name: r.Name, Email: strings.ToLower(r.Email)})
+	}
+	return addrs
+}
+
 // FromPostmarkInboundRequest parses an inbound webhook payload from Postmark into a
 // PostmarkInboundMessageReq structure.
 // It takes a map[string]interface{} request payload and returns the parsed
@@ -84,55 +112,88 @@ func FromPostmarkInboundRequest(reqp map[string]interface{}) (PostmarkInboundMes
 	return payload, nil
 }
 
-type PostmarkEmailReqOption func(req *postmark.Email)
-
-func NewPostmarkEmailReq(subject, from, to string, opts ...PostmarkEmailReqOption) *postmark.Email {
-	req := &postmark.Email{
-		Subject: subject,
-		From:    from,
-		To:      to,
-	}
-	for _, opt := range opts {
-		opt(req)
-	}
-	return req
+// PostmarkStatusReq represents the Postmark delivery, bounce, spam complaint, open and click webhook request
+// with the raw JSON payload.
+type PostmarkStatusReq struct {
+	RecordType  string `json:"RecordType"`
+	MessageID   string `json:"MessageID"`
+	Type        string `json:"Type"`
+	TypeCode    int64  `json:"TypeCode"`
+	Description string `json:"Description"`
+	Details     string `json:"Details"`
+	Email       string `json:"Email"`     // Bounce and SpamComplaint
+	Recipient   string `json:"Recipient"` // Delivery, Open and Click
+	Inactive    bool   `json:"Inactive"`  // recipient deactivated by Postmark, no further mail is sent
+	Payload     map[string]interface{}
 }
 
-func SetPostmarkTextBody(textBody string) PostmarkEmailReqOption {
-	return func(req *postmark.Email) {
-		req.TextBody = textBody
-	}
+// postmarkNonFailureBounceTypes are the Postmark bounce types not failing the delivery.
+var postmarkNonFailureBounceTypes = map[string]bool{
+	"Transient":             true,
+	"Unsubscribe":           true,
+	"Subscribe":             true,
+	"AutoResponder":         true,
+	"AddressChange":         true,
+	"OpenRelayTest":         true,
+	"ChallengeVerification": true,
 }
 
-func SetPostmarkHTMLBody(htmlBody string) PostmarkEmailReqOption {
-	return func(req *postmark.Email) {
-		req.HTMLBody = htmlBody
-	}
+// postmarkHardBounceTypes are the Postmark bounce types of the bad recipient address.
+var postmarkHardBounceTypes = map[string]bool{
+	"HardBounce":          true,
+	"BadEmailAddress":     true,
+	"ManuallyDeactivated": true,
 }
 
-func WithPostmarkHeader(name, value string) PostmarkEmailReqOption {
-	return func(req *postmark.Email) {
-		req.Headers = append(req.Headers, postmark.Header{
-			Name:  name,
-			Value: value,
-		})
+// ToChannelDeliveryStatus converts the Postmark webhook to the email channel delivery status.
+func (p *PostmarkStatusReq) ToChannelDeliveryStatus() (models.ChannelDeliveryStatus, error) {
+	status := models.ChannelDeliveryStatus{
+		ExternalId: p.MessageID,
+		Payload:    p.Payload,
 	}
-}
-
-func SetPostmarkTag(tag string) PostmarkEmailReqOption {
-	return func(req *postmark.Email) {
-		req.Tag = tag
+	switch p.RecordType {
+	case "Delivery":
+		status.Status = models.ChannelMessageStatus{}.Delivered()
+		status.Recipient = p.Recipient
+	case "Bounce":
+		if postmarkNonFailureBounceTypes[p.Type] {
+			return models.ChannelDeliveryStatus{}, integrations.ErrPostmarkBounceType
+		}
+		status.Status = models.ChannelMessageStatus{}.Failed()
+		status.Recipient = p.Email
+		status.ErrorCode = p.TypeCode
+		status.ErrorMessage = p.Description
+		status.IsRecipientBad = p.Inactive || postmarkHardBounceTypes[p.Type]
+	case "SpamComplaint":
+		status.Status = models.ChannelMessageStatus{}.Complained()
+		status.Recipient = p.Email
+		status.ErrorCode = p.TypeCode
+		status.ErrorMessage = "Recipient marked the mail as spam"
+		status.IsRecipientBad = true
+	case "Open":
+		status.Status = models.ChannelMessageStatus{}.Opened()
+		status.Recipient = p.Recipient
+	case "Click":
+		status.Status = models.ChannelMessageStatus{}.Clicked()
+		status.Recipient = p.Recipient
+	default:
+		return models.ChannelDeliveryStatus{}, integrations.ErrPostmarkRecordType
 	}
+	return status, nil
 }
 
-func SendPostmarkMail(
-	ctx context.Context, setting models.PostmarkMailServerSetting, email *postmark.Email,
-) (postmark.EmailResponse, error) {
-	client := postmark.NewClient(setting.ServerToken, "")
-	r, err := client.SendEmail(ctx, *email)
+// FromPostmarkStatusRequest parses the Postmark delivery, bounce, spam complaint, open and click webhook payload.
+func FromPostmarkStatusRequest(reqp map[string]interface{}) (PostmarkStatusReq, error) {
+	jsonBytes, err := json.Marshal(reqp)
 	if err != nil {
-		slog.Error("failed to send email", slog.Any("error", err), slog.Any("email", email))
-		return postmark.EmailResponse{}, integrations.ErrPostmarkSendMail
+		return PostmarkStatusReq{}, err
 	}
-	return r, nil
+
+	var payload PostmarkStatusReq
+	if err := json.Unmarshal(jsonBytes, &payload); err != nil {
+		return PostmarkStatusReq{}, err
+	}
+
+	payload.Payload = reqp
+	return payload, nil
 }
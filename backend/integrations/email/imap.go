@@ -0,0 +1,299 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg/models"
+)
+
+const imapTimeout = 60 * time.Second
+
+var (
+	imapLiteralRe     = regexp.MustCompile(`\{(\d+)\}$`)
+	imapUIDValidityRe = regexp.MustCompile(`(?i)\[UIDVALIDITY (\d+)\]`)
+	imapUIDNextRe     = regexp.MustCompile(`(?i)\[UIDNEXT (\d+)\]`)
+	imapFetchUIDRe    = regexp.MustCompile(`(?i)\bUID (\d+)\b`)
+)
+
+// IMAPClient fetches the new mail from the IMAP mailbox of the workspace IMAP setting.
+// The mailbox is opened read-only, mail is fetched by UID after the last fetched UID
+// and the mail flags are left as is.
+type IMAPClient struct {
+	maxBytes      int64
+	insecureLogin bool
+}
+
+// NewIMAPClient returns the IMAP client fetching the mail up to maxBytes, with insecureLogin
+// the LOGIN is allowed over the plain connection with the security none.
+func NewIMAPClient(maxBytes int64, insecureLogin bool) *IMAPClient {
+	return &IMAPClient{maxBytes: maxBytes, insecureLogin: insecureLogin}
+}
+
+// FetchNew fetches up to limit mail with the UID after the last fetched UID of the setting.
+// When the mailbox UIDVALIDITY changed, nothing is fetched and the result has the mailbox UIDVALIDITY
+// and UIDNEXT to start again from.
+func (c *IMAPClient) FetchNew(
+	ctx context.Context, setting models.IMAPSetting, limit int) (models.IMAPFetchResult, error) {
+	if setting.Security == (models.SMTPSecurity{}).None() && !c.insecureLogin {
+		return models.IMAPFetchResult{}, errors.New("imap login over the plain connection is not allowed")
+	}
+	conn, err := dialIMAP(ctx, setting, c.maxBytes)
+	if err != nil {
+		return models.IMAPFetchResult{}, err
+	}
+	defer func(conn *imapConn) {
+		_ = conn.close()
+	}(conn)
+
+	if err := conn.login(setting.Username, setting.Password); err != nil {
+		return models.IMAPFetchResult{}, err
+	}
+
+	mailbox, err := imapQuote(setting.Mailbox)
+	if err != nil {
+		return models.IMAPFetchResult{}, err
+	}
+	var result models.IMAPFetchResult
+	lines, err := conn.command("EXAMINE " + mailbox)
+	if err != nil {
+		return models.IMAPFetchResult{}, err
+	}
+	for _, l := range lines {
+		if m := imapUIDValidityRe.FindStringSubmatch(l.text); m != nil {
+			result.UIDValidity, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+		if m := imapUIDNextRe.FindStringSubmatch(l.text); m != nil {
+			result.UIDNext, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+	}
+	if result.UIDValidity != setting.UIDValidity {
+		if result.UIDNext == 0 {
+			uids, err := conn.searchUIDs("1:*")
+			if err != nil {
+				return models.IMAPFetchResult{}, err
+			}
+			result.UIDNext = 1
+			if len(uids) > 0 {
+				result.UIDNext = uids[len(uids)-1] + 1
+			}
+		}
+		_, _ = conn.command("LOGOUT")
+		return result, nil
+	}
+
+	uids, err := conn.searchUIDs(fmt.Sprintf("%d:*", setting.LastUID+1))
+	if err != nil {
+		return models.IMAPFetchResult{}, err
+	}
+	for _, uid := range uids {
+		// `n:*` always matches the highest UID, even if below n.
+		if uid <= setting.LastUID {
+			continue
+		}
+		if limit > 0 && len(result.Messages) >= limit {
+			break
+		}
+		raw, err := conn.fetchRaw(uid)
+		if err != nil {
+			return result, err
+		}
+		result.Messages = append(result.Messages, models.IMAPMessage{UID: uid, Raw: raw})
+	}
+	_, _ = conn.command("LOGOUT")
+	return result, nil
+}
+
+type imapConn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	seq      int
+	maxBytes int64 // max size of the literal
+}
+
+// imapLine is the response line with the literals, e.g. the fetched message.
+type imapLine struct {
+	text     string
+	literals [][]byte
+}
+
+func dialIMAP(ctx context.Context, setting models.IMAPSetting, maxBytes int64) (*imapConn, error) {
+	dialer := &net.Dialer{Timeout: imapTimeout}
+	tlsConfig := &tls.Config{ServerName: setting.Host}
+
+	var conn net.Conn
+	var err error
+	if setting.Security == (models.SMTPSecurity{}).TLS() {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", setting.Addr())
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", setting.Addr())
+	}
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(imapTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn), maxBytes: maxBytes}
+	greeting, err := c.readLine()
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(strings.ToUpper(greeting.text), "* OK") {
+		_ = conn.Close()
+		return nil, fmt.Errorf("imap server not ready: %s", greeting.text)
+	}
+
+	if setting.Security == (models.SMTPSecurity{}).StartTLS() {
+		if _, err := c.command("STARTTLS"); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		c.conn = tlsConn
+		c.r = bufio.NewReader(tlsConn)
+	}
+	return c, nil
+}
+
+func (c *imapConn) close() error {
+	return c.conn.Close()
+}
+
+func (c *imapConn) login(username, password string) error {
+	quotedUsername, err := imapQuote(username)
+	if err != nil {
+		return err
+	}
+	quotedPassword, err := imapQuote(password)
+	if err != nil {
+		return err
+	}
+	if _, err := c.command("LOGIN " + quotedUsername + " " + quotedPassword); err != nil {
+		return errors.New("imap login failed")
+	}
+	return nil
+}
+
+// command sends the tagged command, returns the untagged response lines when completed with OK.
+func (c *imapConn) command(cmd string) ([]imapLine, error) {
+	c.seq++
+	tag := fmt.Sprintf("z%d", c.seq)
+	if _, err := io.WriteString(c.conn, tag+" "+cmd+"\r\n"); err != nil {
+		return nil, err
+	}
+	var lines []imapLine
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line.text, tag+" ") {
+			lines = append(lines, line)
+			continue
+		}
+		status := strings.TrimPrefix(line.text, tag+" ")
+		if !strings.HasPrefix(strings.ToUpper(status), "OK") {
+			return nil, fmt.Errorf("imap command failed: %s", status)
+		}
+		return lines, nil
+	}
+}
+
+// readLine reads the response line, literals `{n}` are read in full and the line continues after.
+// Literals larger than the max size are not read, the server sent size is not trusted.
+func (c *imapConn) readLine() (imapLine, error) {
+	var line imapLine
+	for {
+		text, err := c.r.ReadString('\n')
+		if err != nil {
+			return imapLine{}, err
+		}
+		text = strings.TrimRight(text, "\r\n")
+		line.text += text
+		m := imapLiteralRe.FindStringSubmatch(text)
+		if m == nil {
+			return line, nil
+		}
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return imapLine{}, err
+		}
+		if n > c.maxBytes {
+			return imapLine{}, fmt.Errorf("imap literal of %d bytes exceeds the max %d bytes", n, c.maxBytes)
+		}
+		literal := make([]byte, n)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return imapLine{}, err
+		}
+		line.literals = append(line.literals, literal)
+	}
+}
+
+func (c *imapConn) searchUIDs(set string) ([]int64, error) {
+	lines, err := c.command("UID SEARCH UID " + set)
+	if err != nil {
+		return nil, err
+	}
+	var uids []int64
+	for _, l := range lines {
+		fields := strings.Fields(l.text)
+		if len(fields) < 2 || !strings.EqualFold(fields[1], "SEARCH") {
+			continue
+		}
+		for _, f := range fields[2:] {
+			if uid, err := strconv.ParseInt(f, 10, 64); err == nil {
+				uids = append(uids, uid)
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids, nil
+}
+
+func (c *imapConn) fetchRaw(uid int64) ([]byte, error) {
+	lines, err := c.command(fmt.Sprintf("UID FETCH %d (UID BODY.PEEK[])", uid))
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		m := imapFetchUIDRe.FindStringSubmatch(l.text)
+		if m == nil || len(l.literals) == 0 {
+			continue
+		}
+		if fetched, _ := strconv.ParseInt(m[1], 10, 64); fetched == uid {
+			return l.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap message uid %d not fetched", uid)
+}
+
+// imapQuote returns the quoted string, the control characters e.g. the line breaks can't be quoted.
+func imapQuote(s string) (string, error) {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return "", errors.New("imap string must not contain control characters")
+		}
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`, nil
+}
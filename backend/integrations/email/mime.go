@@ -0,0 +1,314 @@
+package email
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg/models"
+	"golang.org/x/net/html/charset"
+)
+
+// maxMIMEDepth limits the nested multiparts, deeper parts are skipped.
+const maxMIMEDepth = 10
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// RawMail is the inbound mail parsed from the raw RFC 5322 message.
+type RawMail struct {
+	MessageId   string
+	InReplyTo   string
+	References  []string
+	FromName    string
+	FromEmail   string
+	To          []string // emails of the To and Cc recipients
+	ToAddrs     []models.MailAddress
+	CcAddrs     []models.MailAddress
+	MailboxHash string // plus address tag of the first plus addressed recipient
+	Subject     string
+	Date        time.Time
+	Headers     map[string]string // decoded headers in canonical form, first value wins.
+	TextBody    string
+	HTMLBody    string
+	Attachments []models.ChannelAttachment
+	Size        int
+	Hash        string // SHA-256 of the raw message
+}
+
+// ParseRawMail parses the raw RFC 5322 message with the MIME parts.
+// Nested multiparts are walked, the first text/plain and text/html parts are the bodies,
+// inline and attached files are the attachments. Text is decoded to UTF-8 from the part charset,
+// headers are decoded from the RFC 2047 encoded-words.
+func ParseRawMail(raw []byte) (RawMail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return RawMail{}, err
+	}
+
+	sum := sha256.Sum256(raw)
+	parsed := RawMail{
+		Headers: make(map[string]string, len(msg.Header)),
+		Size:    len(raw),
+		Hash:    hex.EncodeToString(sum[:]),
+	}
+	for name, values := range msg.Header {
+		if len(values) == 0 {
+			continue
+		}
+		parsed.Headers[textproto.CanonicalMIMEHeaderKey(name)] = decodeHeader(values[0])
+	}
+
+	parsed.Subject = decodeHeader(msg.Header.Get("Subject"))
+	parsed.MessageId = firstMessageId(msg.Header.Get("Message-Id"))
+	parsed.InReplyTo = firstMessageId(msg.Header.Get("In-Reply-To"))
+	parsed.References = messageIds(msg.Header.Get("References"))
+	if date, err := msg.Header.Date(); err == nil {
+		parsed.Date = date.UTC()
+	}
+
+	addrParser := &mail.AddressParser{WordDecoder: wordDecoder}
+	from, err := addrParser.Parse(msg.Header.Get("From"))
+	if err != nil {
+		return RawMail{}, fmt.Errorf("invalid from address: %w", err)
+	}
+	parsed.FromName = from.Name
+	parsed.FromEmail = strings.ToLower(from.Address)
+	for _, h := range []string{"To", "Cc"} {
+		if addrs, err := addrParser.ParseList(msg.Header.Get(h)); err == nil {
+			for _, a := range addrs {
+				addr := models.MailAddress{Name: a.Name, Email: strings.ToLower(a.Address)}
+				parsed.To = append(parsed.To, addr.Email)
+				if h == "To" {
+					parsed.ToAddrs = append(parsed.ToAddrs, addr)
+				} else {
+					parsed.CcAddrs = append(parsed.CcAddrs, addr)
+				}
+			}
+		}
+	}
+	// The envelope recipient as added by the receiving MTA is checked first.
+	recipients := []string{msg.Header.Get("Delivered-To"), msg.Header.Get("X-Original-To")}
+	parsed.MailboxHash = mailboxHash(append(recipients, parsed.To...))
+
+	if err := parsed.walkPart(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return RawMail{}, err
+	}
+	return parsed, nil
+}
+
+func (m *RawMail) walkPart(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > maxMIMEDepth {
+		return nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// As per RFC 2045 the default is plain text.
+		mediaType, params = "text/plain", map[string]string{"charset": "us-ascii"}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		boundary := params["boundary"]
+		if boundary == "" {
+			return errors.New("multipart without boundary")
+		}
+		mr := multipart.NewReader(body, boundary)
+		for {
+			part, err := mr.NextRawPart()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := m.walkPart(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := io.ReadAll(transferDecoder(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := decodeHeader(dparams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+
+	isBody := disposition != "attachment" && filename == ""
+	if isBody && mediaType == "text/plain" && m.TextBody == "" {
+		m.TextBody = decodeCharset(content, params["charset"])
+		return nil
+	}
+	if isBody && mediaType == "text/html" && m.HTMLBody == "" {
+		m.HTMLBody = decodeCharset(content, params["charset"])
+		return nil
+	}
+
+	if filename == "" && mediaType == "message/rfc822" {
+		filename = "attached.eml"
+	}
+	m.Attachments = append(m.Attachments, models.ChannelAttachment{
+		Name:        filename,
+		ContentType: mediaType,
+		Content:     base64.StdEncoding.EncodeToString(content),
+		ContentId:   contentId(header.Get("Content-Id")),
+	})
+	return nil
+}
+
+// ToChannelInbound converts the raw mail to the normalized email channel inbound message.
+// The mail `Message-ID` is the external ID, mail without one is identified by the raw message hash.
+func (m *RawMail) ToChannelInbound(source string) models.ChannelInbound {
+	externalId := m.MessageId
+	if externalId == "" {
+		externalId = "sha256:" + m.Hash
+	}
+	inbound := models.ChannelInbound{
+		Channel:     models.ThreadChannel{}.Email(),
+		ExternalId:  externalId,
+		Subject:     m.Subject,
+		TextBody:    m.TextBody,
+		HTMLBody:    m.HTMLBody,
+		FromEmail:   m.FromEmail,
+		FromName:    m.FromName,
+		Headers:     m.Headers,
+		Attachments: m.Attachments,
+		References:  m.References,
+		ReplyToken:  m.MailboxHash,
+		To:          m.ToAddrs,
+		Cc:          m.CcAddrs,
+		Payload: map[string]interface{}{
+			"Source":     source,
+			"MessageID":  m.MessageId,
+			"InReplyTo":  m.InReplyTo,
+			"References": m.References,
+			"From":       m.FromEmail,
+			"To":         m.To,
+			"Subject":    m.Subject,
+			"Date":       m.Date,
+			"Size":       m.Size,
+			"SHA256":     m.Hash,
+		},
+		CreatedAt: time.Now().UTC(),
+	}
+	if m.MessageId != "" {
+		messageId := m.MessageId
+		inbound.ExternalRef = &messageId
+	}
+	if m.InReplyTo != "" {
+		replyTo := m.InReplyTo
+		inbound.ReplyRef = &replyTo
+	}
+	return inbound
+}
+
+func transferDecoder(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64Cleaner{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// base64Cleaner drops the whitespace and stray characters of the base64 content as sent by some clients,
+// the decoder only ignores the line breaks.
+type base64Cleaner struct {
+	r io.Reader
+}
+
+func (c *base64Cleaner) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+				b == '+' || b == '/' || b == '=' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+func decodeCharset(content []byte, label string) string {
+	if label == "" || strings.EqualFold(label, "utf-8") || strings.EqualFold(label, "us-ascii") {
+		return string(content)
+	}
+	r, err := charset.NewReaderLabel(label, bytes.NewReader(content))
+	if err != nil {
+		return string(content)
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return string(content)
+	}
+	return string(decoded)
+}
+
+func decodeHeader(value string) string {
+	decoded, err := wordDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// messageIds returns the `<id>` message IDs of the header in order.
+func messageIds(value string) []string {
+	var ids []string
+	for {
+		start := strings.Index(value, "<")
+		if start == -1 {
+			return ids
+		}
+		end := strings.Index(value[start:], ">")
+		if end == -1 {
+			return ids
+		}
+		ids = append(ids, value[start:start+end+1])
+		value = value[start+end+1:]
+	}
+}
+
+// mailboxHash returns the plus address tag of the first plus addressed recipient.
+func mailboxHash(recipients []string) string {
+	for _, rcpt := range recipients {
+		at := strings.LastIndex(rcpt, "@")
+		if at <= 0 {
+			continue
+		}
+		if _, tag, ok := strings.Cut(rcpt[:at], "+"); ok && tag != "" {
+			return strings.TrimSpace(tag)
+		}
+	}
+	return ""
+}
+
+func firstMessageId(value string) string {
+	ids := messageIds(value)
+	if len(ids) == 0 {
+		return strings.TrimSpace(value)
+	}
+	return ids[0]
+}
@@ -0,0 +1,131 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zyghq/postmark"
+	"github.com/zyghq/zyg/integrations"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/utils"
+)
+
+// PostmarkSender sends the mail with the Postmark server of the mail setting API key as the server token.
+type PostmarkSender struct{}
+
+func NewPostmarkSender() *PostmarkSender {
+	return &PostmarkSender{}
+}
+
+func (p *PostmarkSender) Name() string {
+	return models.MailSenderName{}.Postmark()
+}
+
+func (p *PostmarkSender) Send(
+	ctx context.Context, setting models.MailSetting, mail models.Mail) (models.MailSendResult, error) {
+	email := postmark.Email{
+		From:     mail.From(),
+		To:       strings.Join(mail.ToAddrs(), ","),
+		Cc:       strings.Join(mail.Cc, ","),
+		Bcc:      strings.Join(mail.Bcc, ","),
+		ReplyTo:  mail.ReplyTo,
+		Subject:  mail.Subject,
+		Tag:      mail.Tag,
+		TextBody: mail.TextBody,
+		HTMLBody: mail.HTMLBody,
+	}
+	// Postmark keeps the `Message-ID` header as set, instead of its own.
+	for _, h := range mail.ThreadHeaders() {
+		email.Headers = append(email.Headers, postmark.Header{Name: h.Name, Value: h.Value})
+	}
+
+	payload, err := utils.StructToMap(email)
+	if err != nil {
+		slog.Error("failed to marshal postmark email", slog.Any("err", err))
+		return models.MailSendResult{}, integrations.ErrPostmarkSendMail
+	}
+	// Attachments are added after the payload is kept, only the attachment names are kept.
+	for _, a := range mail.Attachments {
+		email.Attachments = append(email.Attachments, postmark.Attachment{
+			Name:        a.Name,
+			Content:     base64.StdEncoding.EncodeToString(a.Content),
+			ContentType: a.ContentType,
+			ContentID:   a.ContentId,
+		})
+	}
+	if names := mailAttachmentNames(mail); len(names) > 0 {
+		payload["Attachments"] = names
+	}
+
+	client := postmark.NewClient(setting.APIKey, "")
+	r, err := client.SendEmail(ctx, email)
+	if err != nil {
+		slog.Error("failed to send email", slog.Any("error", err), slog.Any("to", mail.To))
+		return models.MailSendResult{}, integrations.ErrPostmarkSendMail
+	}
+
+	submittedAt := r.SubmittedAt
+	if submittedAt.IsZero() {
+		submittedAt = time.Now().UTC()
+	}
+	return models.MailSendResult{
+		Sender:            p.Name(),
+		ProviderMessageId: r.MessageID,
+		MessageId:         mail.MessageId,
+		SubmittedAt:       submittedAt,
+		Payload:           payload,
+	}, nil
+}
+
+// mailAttachmentNames returns the names of the mail attachments kept in the sent mail payload
+// instead of the content.
+func mailAttachmentNames(mail models.Mail) []string {
+	names := make([]string, 0, len(mail.Attachments))
+	for _, a := range mail.Attachments {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+// postmarkAPIUrl is the Postmark API base URL.
+const postmarkAPIUrl = "https://api.postmarkapp.com"
+
+// UpdatePostmarkInboundHook sets the inbound hook URL of the Postmark server with the account token.
+// The Postmark client's EditServer does not send the server fields, the request is made here instead.
+func UpdatePostmarkInboundHook(ctx context.Context, accountToken string, serverId int64, hookURL string) error {
+	body, err := json.Marshal(map[string]string{"InboundHookUrl": hookURL})
+	if err != nil {
+		return integrations.ErrPostmarkServer
+	}
+	url := fmt.Sprintf("%s/servers/%d", postmarkAPIUrl, serverId)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return integrations.ErrPostmarkServer
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Postmark-Account-Token", accountToken)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("failed to update postmark server", slog.Any("err", err))
+		return integrations.ErrPostmarkServer
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("failed to update postmark server",
+			slog.Any("serverId", serverId), slog.Any("status", resp.StatusCode))
+		return integrations.ErrPostmarkServer
+	}
+	return nil
+}
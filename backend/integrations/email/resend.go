@@ -0,0 +1,75 @@
+package email
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/resend/resend-go/v2"
+	"github.com/zyghq/zyg/integrations"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/utils"
+)
+
+// ResendSender sends the mail with Resend using the mail setting API key.
+type ResendSender struct{}
+
+func NewResendSender() *ResendSender {
+	return &ResendSender{}
+}
+
+func (r *ResendSender) Name() string {
+	return models.MailSenderName{}.Resend()
+}
+
+func (r *ResendSender) Send(
+	ctx context.Context, setting models.MailSetting, mail models.Mail) (models.MailSendResult, error) {
+	params := &resend.SendEmailRequest{
+		From:    mail.From(),
+		To:      mail.ToAddrs(),
+		Cc:      mail.Cc,
+		Bcc:     mail.Bcc,
+		Subject: mail.Subject,
+		Html:    mail.HTMLBody,
+		Text:    mail.TextBody,
+		ReplyTo: mail.ReplyTo,
+		Headers: make(map[string]string),
+	}
+	for _, h := range mail.ThreadHeaders() {
+		params.Headers[h.Name] = h.Value
+	}
+	if mail.Tag != "" {
+		params.Tags = []resend.Tag{{Name: "tag", Value: mail.Tag}}
+	}
+
+	payload, err := utils.StructToMap(params)
+	if err != nil {
+		slog.Error("failed to marshal resend email", slog.Any("err", err))
+		return models.MailSendResult{}, integrations.ErrResendSendMail
+	}
+	// Attachments are added after the payload is kept, only the attachment names are kept.
+	for _, a := range mail.Attachments {
+		params.Attachments = append(params.Attachments, &resend.Attachment{
+			Content:     a.Content,
+			Filename:    a.Name,
+			ContentType: a.ContentType,
+		})
+	}
+	if names := mailAttachmentNames(mail); len(names) > 0 {
+		payload["Attachments"] = names
+	}
+
+	client := resend.NewClient(setting.APIKey)
+	sent, err := client.Emails.SendWithContext(ctx, params)
+	if err != nil {
+		slog.Error("failed to send email", slog.Any("err", err), slog.Any("to", mail.To))
+		return models.MailSendResult{}, integrations.ErrResendSendMail
+	}
+	return models.MailSendResult{
+		Sender:            r.Name(),
+		ProviderMessageId: sent.Id,
+		MessageId:         mail.MessageId,
+		SubmittedAt:       time.Now().UTC(),
+		Payload:           payload,
+	}, nil
+}
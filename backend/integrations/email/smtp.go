@@ -0,0 +1,370 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg/integrations"
+	"github.com/zyghq/zyg/models"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPSender sends the mail with the SMTP server of the mail setting.
+// Supports STARTTLS and implicit TLS, authenticates with AUTH PLAIN or LOGIN.
+type SMTPSender struct{}
+
+func NewSMTPSender() *SMTPSender {
+	return &SMTPSender{}
+}
+
+func (s *SMTPSender) Name() string {
+	return models.MailSenderName{}.SMTP()
+}
+
+func (s *SMTPSender) Send(
+	ctx context.Context, setting models.MailSetting, mail models.Mail) (models.MailSendResult, error) {
+	return sendSMTPMail(ctx, s.Name(), smtpServer{
+		Addr:     setting.SMTPAddr(),
+		Host:     setting.SMTPHost,
+		Security: setting.SMTPSecurity,
+		Auth:     setting.SMTPAuth,
+		Username: setting.SMTPUsername,
+		Password: setting.SMTPPassword,
+	}, mail)
+}
+
+// CaptureSender sends the mail to the local SMTP capture server e.g. MailHog or Mailpit,
+// without TLS and authentication. Used in development, mails are never delivered.
+type CaptureSender struct {
+	addr string
+}
+
+func NewCaptureSender(addr string) *CaptureSender {
+	return &CaptureSender{addr: addr}
+}
+
+func (c *CaptureSender) Name() string {
+	return models.MailSenderName{}.Capture()
+}
+
+func (c *CaptureSender) Send(
+	ctx context.Context, _ models.MailSetting, mail models.Mail) (models.MailSendResult, error) {
+	host, _, err := net.SplitHostPort(c.addr)
+	if err != nil {
+		slog.Error("invalid mail capture address", slog.Any("err", err), slog.Any("addr", c.addr))
+		return models.MailSendResult{}, integrations.ErrSMTPSendMail
+	}
+	return sendSMTPMail(ctx, c.Name(), smtpServer{
+		Addr:     c.addr,
+		Host:     host,
+		Security: models.SMTPSecurity{}.None(),
+		Auth:     models.SMTPAuth{}.None(),
+	}, mail)
+}
+
+type smtpServer struct {
+	Addr     string
+	Host     string
+	Security string
+	Auth     string
+	Username string
+	Password string
+}
+
+func sendSMTPMail(
+	ctx context.Context, sender string, server smtpServer, mail models.Mail) (models.MailSendResult, error) {
+	msg, err := BuildMIMEMail(mail, time.Now())
+	if err != nil {
+		slog.Error("failed to build mime mail", slog.Any("err", err))
+		return models.MailSendResult{}, integrations.ErrSMTPSendMail
+	}
+	if err := deliverSMTP(ctx, server, mail.FromEmail, mail.Recipients(), msg); err != nil {
+		slog.Error("failed to send smtp mail",
+			slog.Any("err", err), slog.Any("addr", server.Addr), slog.Any("to", mail.To))
+		return models.MailSendResult{}, integrations.ErrSMTPSendMail
+	}
+	// The `Message-ID` is the only message ID with SMTP.
+	return models.MailSendResult{
+		Sender:            sender,
+		ProviderMessageId: mail.MessageId,
+		MessageId:         mail.MessageId,
+		SubmittedAt:       time.Now().UTC(),
+		Payload: map[string]interface{}{
+			"From":      mail.From(),
+			"To":        mail.ToAddrs(),
+			"Cc":        mail.Cc,
+			"Subject":   mail.Subject,
+			"MessageID": mail.MessageId,
+		},
+	}, nil
+}
+
+func deliverSMTP(ctx context.Context, server smtpServer, from string, to []string, msg []byte) error {
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	tlsConfig := &tls.Config{ServerName: server.Host}
+
+	var conn net.Conn
+	var err error
+	if server.Security == (models.SMTPSecurity{}).TLS() {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", server.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", server.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, server.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func(c *smtp.Client) {
+		_ = c.Close()
+	}(c)
+
+	if server.Security == (models.SMTPSecurity{}).StartTLS() {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not support STARTTLS")
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	var auth smtp.Auth
+	switch server.Auth {
+	case models.SMTPAuth{}.Plain():
+		auth = smtp.PlainAuth("", server.Username, server.Password, server.Host)
+	case models.SMTPAuth{}.Login():
+		auth = &loginAuth{username: server.Username, password: server.Password}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp server does not support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// loginAuth implements the AUTH LOGIN mechanism, not supported by net/smtp.
+// Like smtp.PlainAuth, credentials are only sent over TLS or to localhost.
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(bytes.ToLower(bytes.TrimSpace(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected smtp login challenge: %s", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// BuildMIMEMail returns the RFC 5322 mail message with the threading headers,
+// text and HTML bodies are sent as multipart/alternative, with the attachments as multipart/mixed.
+func BuildMIMEMail(mail models.Mail, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	// Header values are single line, line breaks would otherwise inject headers.
+	unfold := strings.NewReplacer("\r", "", "\n", "")
+	writeHeader := func(name, value string) {
+		buf.WriteString(name + ": " + unfold.Replace(value) + "\r\n")
+	}
+
+	writeHeader("Date", date.Format(time.RFC1123Z))
+	writeHeader("From", mail.From())
+	writeHeader("To", strings.Join(mail.ToAddrs(), ", "))
+	if len(mail.Cc) > 0 {
+		writeHeader("Cc", strings.Join(mail.Cc, ", "))
+	}
+	if mail.ReplyTo != "" {
+		writeHeader("Reply-To", mail.ReplyTo)
+	}
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", mail.Subject))
+	for _, h := range mail.ThreadHeaders() {
+		writeHeader(h.Name, h.Value)
+	}
+	writeHeader("MIME-Version", "1.0")
+
+	bodyHeader, body, err := mimeBody(mail)
+	if err != nil {
+		return nil, err
+	}
+	if len(mail.Attachments) == 0 {
+		for _, name := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			if value := bodyHeader.Get(name); value != "" {
+				writeHeader(name, value)
+			}
+		}
+		buf.WriteString("\r\n")
+		buf.Write(body)
+		return buf.Bytes(), nil
+	}
+
+	// Attachments are sent as multipart/mixed with the body as the first part.
+	mw := multipart.NewWriter(&buf)
+	writeHeader("Content-Type", mime.FormatMediaType(
+		"multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+	pw, err := mw.CreatePart(bodyHeader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pw.Write(body); err != nil {
+		return nil, err
+	}
+	for _, a := range mail.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType(contentType, map[string]string{"name": a.Name})},
+			"Content-Disposition": {
+				mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}),
+			},
+			"Content-Transfer-Encoding": {"base64"},
+		}
+		if a.ContentId != "" {
+			header.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": a.Name}))
+			header.Set("Content-Id", "<"+a.ContentId+">")
+		}
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64Lines(pw, a.Content); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mimeBody returns the MIME header and the encoded body of the mail text and HTML,
+// both are sent as multipart/alternative.
+func mimeBody(mail models.Mail) (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+	if mail.TextBody != "" && mail.HTMLBody != "" {
+		mw := multipart.NewWriter(&buf)
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain; charset=utf-8", mail.TextBody},
+			{"text/html; charset=utf-8", mail.HTMLBody},
+		} {
+			pw, err := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := writeQuotedPrintable(pw, part.body); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, nil, err
+		}
+		header := textproto.MIMEHeader{
+			"Content-Type": {mime.FormatMediaType(
+				"multipart/alternative", map[string]string{"boundary": mw.Boundary()})},
+		}
+		return header, buf.Bytes(), nil
+	}
+
+	contentType, body := "text/plain; charset=utf-8", mail.TextBody
+	if mail.HTMLBody != "" {
+		contentType, body = "text/html; charset=utf-8", mail.HTMLBody
+	}
+	if err := writeQuotedPrintable(&buf, body); err != nil {
+		return nil, nil, err
+	}
+	header := textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	}
+	return header, buf.Bytes(), nil
+}
+
+// writeBase64Lines writes the base64 encoded content in lines of 76 characters as required by RFC 2045.
+func writeBase64Lines(w io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qw := quotedprintable.NewWriter(w)
+	if _, err := qw.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qw.Close()
+}
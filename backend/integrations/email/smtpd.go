@@ -0,0 +1,196 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+const smtpReceiverTimeout = 5 * time.Minute
+
+// SMTPReceiver is the embedded SMTP listener receiving the inbound mail.
+// It's a plain receiving MTA without TLS and authentication, meant to run behind the edge MTA
+// or on the private network. Recipients are checked with accept, each accepted recipient's mail
+// is handed to receive as the raw message.
+type SMTPReceiver struct {
+	addr     string
+	hostname string
+	maxBytes int64
+	accept   func(ctx context.Context, rcpt string) error
+	receive  func(ctx context.Context, rcpt string, raw []byte) error
+}
+
+func NewSMTPReceiver(
+	addr, hostname string, maxBytes int64,
+	accept func(ctx context.Context, rcpt string) error,
+	receive func(ctx context.Context, rcpt string, raw []byte) error,
+) *SMTPReceiver {
+	return &SMTPReceiver{
+		addr:     addr,
+		hostname: hostname,
+		maxBytes: maxBytes,
+		accept:   accept,
+		receive:  receive,
+	}
+}
+
+// ListenAndServe accepts the SMTP connections until the context is done.
+func (s *SMTPReceiver) ListenAndServe(ctx context.Context) error {
+	ln, err := (&net.ListenConfig{}).Listen(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("smtp receiver listening", slog.Any("addr", s.addr))
+
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serve(ctx, conn)
+		}()
+	}
+}
+
+type smtpSession struct {
+	from  string
+	rcpts []string
+}
+
+func (s *SMTPReceiver) serve(ctx context.Context, conn net.Conn) {
+	defer func(conn net.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	tp := textproto.NewConn(conn)
+	reply := func(code int, msg string) {
+		_ = conn.SetWriteDeadline(time.Now().Add(smtpReceiverTimeout))
+		_ = tp.PrintfLine("%d %s", code, msg)
+	}
+
+	reply(220, s.hostname+" ESMTP ready")
+	var session smtpSession
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(smtpReceiverTimeout))
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			session = smtpSession{}
+			reply(250, s.hostname)
+		case "EHLO":
+			session = smtpSession{}
+			_ = tp.PrintfLine("250-%s", s.hostname)
+			_ = tp.PrintfLine("250-SIZE %d", s.maxBytes)
+			_ = tp.PrintfLine("250-8BITMIME")
+			reply(250, "SMTPUTF8")
+		case "MAIL":
+			from, ok := smtpPath(arg, "FROM:")
+			if !ok {
+				reply(501, "Syntax: MAIL FROM:<address>")
+				continue
+			}
+			session = smtpSession{from: from}
+			reply(250, "OK")
+		case "RCPT":
+			rcpt, ok := smtpPath(arg, "TO:")
+			if !ok || rcpt == "" {
+				reply(501, "Syntax: RCPT TO:<address>")
+				continue
+			}
+			if err := s.accept(ctx, rcpt); err != nil {
+				reply(550, "No such recipient")
+				continue
+			}
+			session.rcpts = append(session.rcpts, rcpt)
+			reply(250, "OK")
+		case "DATA":
+			if len(session.rcpts) == 0 {
+				reply(503, "RCPT first")
+				continue
+			}
+			reply(354, "End data with <CR><LF>.<CR><LF>")
+			dr := tp.DotReader()
+			raw, err := io.ReadAll(io.LimitReader(dr, s.maxBytes+1))
+			if err != nil {
+				return
+			}
+			if int64(len(raw)) > s.maxBytes {
+				// Drain the rest of the message before replying.
+				if _, err := io.Copy(io.Discard, dr); err != nil {
+					return
+				}
+				reply(552, "Message exceeds fixed maximum message size")
+				session = smtpSession{}
+				continue
+			}
+			failed := 0
+			for _, rcpt := range session.rcpts {
+				if err := s.receive(ctx, rcpt, raw); err != nil {
+					slog.Error("failed to receive smtp mail", slog.Any("err", err), slog.Any("rcpt", rcpt))
+					failed++
+				}
+			}
+			session = smtpSession{}
+			// Sender retries later, mail already processed for the other recipients is deduped.
+			if failed > 0 {
+				reply(451, "Requested action aborted: local error in processing")
+				continue
+			}
+			reply(250, "OK queued")
+		case "RSET":
+			session = smtpSession{}
+			reply(250, "OK")
+		case "NOOP":
+			reply(250, "OK")
+		case "QUIT":
+			reply(221, "Bye")
+			return
+		default:
+			reply(502, fmt.Sprintf("Command %s not implemented", verb))
+		}
+	}
+}
+
+// smtpPath returns the address of the `FROM:<address>` or `TO:<address>` argument, parameters are ignored.
+func smtpPath(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(path, "<") {
+		return "", false
+	}
+	end := strings.Index(path, ">")
+	if end == -1 {
+		return "", false
+	}
+	return strings.ToLower(path[1:end]), true
+}
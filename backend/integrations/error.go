@@ -7,5 +7,14 @@ func (err integrationErr) Error() string {
 }
 
 const (
-	ErrPostmarkSendMail = integrationErr("postmark send mail error")
+	ErrPostmarkSendMail   = integrationErr("postmark send mail error")
+	ErrPostmarkRecordType = integrationErr("postmark unsupported record type")
+	ErrPostmarkBounceType = integrationErr("postmark bounce type is not a delivery failure")
+	ErrPostmarkServer     = integrationErr("postmark server error")
+	ErrResendSendMail     = integrationErr("resend send mail error")
+	ErrSMTPSendMail       = integrationErr("smtp send mail error")
+	ErrSMSSend            = integrationErr("sms send error")
+	ErrWhatsAppSend       = integrationErr("whatsapp send error")
+	ErrWhatsAppMedia      = integrationErr("whatsapp media error")
+	ErrSlackAPI           = integrationErr("slack api error")
 )
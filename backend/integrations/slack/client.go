@@ -0,0 +1,254 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg/integrations"
+	"github.com/zyghq/zyg/models"
+)
+
+const apiBaseURL = "https://slack.com/api"
+
+// Client calls the Slack Web API with the installed bot token.
+type Client struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewClient() *Client {
+	return &Client{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: apiBaseURL,
+	}
+}
+
+// apiResp is the common envelope of the Slack Web API response.
+type apiResp struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// call posts the form to the Slack Web API method and decodes the response into v.
+// Slack responds with ok false and the error code on failures.
+func (c *Client) call(ctx context.Context, token, method string, form url.Values, v any) error {
+	endpoint := fmt.Sprintf("%s/%s", c.baseURL, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		slog.Error("failed to call slack api", slog.Any("method", method), slog.Any("err", err))
+		return integrations.ErrSlackAPI
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		slog.Error("failed to decode slack api response", slog.Any("method", method), slog.Any("err", err))
+		return integrations.ErrSlackAPI
+	}
+	var envelope apiResp
+	if err := json.Unmarshal(raw, &envelope); err != nil || !envelope.Ok {
+		slog.Error("slack api request failed",
+			slog.Any("method", method), slog.Any("status", resp.StatusCode), slog.Any("error", envelope.Error))
+		return integrations.ErrSlackAPI
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return integrations.ErrSlackAPI
+	}
+	return nil
+}
+
+type oauthAccessResp struct {
+	AccessToken string `json:"access_token"`
+	Scope       string `json:"scope"`
+	BotUserId   string `json:"bot_user_id"`
+	AppId       string `json:"app_id"`
+	Team        struct {
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"team"`
+}
+
+// OAuthAccess exchanges the OAuth code for the bot token of the Slack app installation.
+func (c *Client) OAuthAccess(
+	ctx context.Context, clientId, clientSecret, code, redirectUrl string) (models.SlackOAuthAccess, error) {
+	form := url.Values{}
+	form.Set("client_id", clientId)
+	form.Set("client_secret", clientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectUrl)
+
+	var resp oauthAccessResp
+	if err := c.call(ctx, "", "oauth.v2.access", form, &resp); err != nil {
+		return models.SlackOAuthAccess{}, err
+	}
+	return models.SlackOAuthAccess{
+		AccessToken: resp.AccessToken,
+		Scope:       resp.Scope,
+		BotUserRef:  resp.BotUserId,
+		AppRef:      resp.AppId,
+		TeamRef:     resp.Team.Id,
+		TeamName:    resp.Team.Name,
+	}, nil
+}
+
+type authTestResp struct {
+	Url    string `json:"url"`
+	TeamId string `json:"team_id"`
+	BotId  string `json:"bot_id"`
+}
+
+// AuthTest returns the Slack workspace URL and the bot ID of the token.
+func (c *Client) AuthTest(ctx context.Context, token string) (string, string, error) {
+	var resp authTestResp
+	if err := c.call(ctx, token, "auth.test", url.Values{}, &resp); err != nil {
+		return "", "", err
+	}
+	return resp.Url, resp.BotId, nil
+}
+
+type conversation struct {
+	Id                 string `json:"id"`
+	Name               string `json:"name"`
+	NameNormalized     string `json:"name_normalized"`
+	IsChannel          bool   `json:"is_channel"`
+	IsExtShared        bool   `json:"is_ext_shared"`
+	IsGeneral          bool   `json:"is_general"`
+	IsGroup            bool   `json:"is_group"`
+	IsIm               bool   `json:"is_im"`
+	IsMember           bool   `json:"is_member"`
+	IsMpim             bool   `json:"is_mpim"`
+	IsOrgShared        bool   `json:"is_org_shared"`
+	IsPendingExtShared bool   `json:"is_pending_ext_shared"`
+	IsPrivate          bool   `json:"is_private"`
+	IsShared           bool   `json:"is_shared"`
+	IsArchived         bool   `json:"is_archived"`
+	Created            int64  `json:"created"`
+	Updated            int64  `json:"updated"`
+}
+
+type conversationsListResp struct {
+	Channels         []conversation `json:"channels"`
+	ResponseMetadata struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+// ListChannels returns the public and private channels visible to the bot, following the pagination cursor.
+func (c *Client) ListChannels(ctx context.Context, token, slackWorkspaceRef string) ([]models.SlackChannel, error) {
+	channels := make([]models.SlackChannel, 0, 100)
+	cursor := ""
+	for {
+		form := url.Values{}
+		form.Set("types", "public_channel,private_channel")
+		form.Set("exclude_archived", "false")
+		form.Set("limit", "200")
+		if cursor != "" {
+			form.Set("cursor", cursor)
+		}
+
+		var resp conversationsListResp
+		if err := c.call(ctx, token, "conversations.list", form, &resp); err != nil {
+			return nil, err
+		}
+		for _, ch := range resp.Channels {
+			status := models.SlackChannelStatus{}.Active()
+			if ch.IsArchived {
+				status = models.SlackChannelStatus{}.Archived()
+			}
+			channels = append(channels, models.SlackChannel{
+				SlackWorkspaceRef:  slackWorkspaceRef,
+				ChannelRef:         ch.Id,
+				IsChannel:          ch.IsChannel,
+				IsExtShared:        ch.IsExtShared,
+				IsGeneral:          ch.IsGeneral,
+				IsGroup:            ch.IsGroup,
+				IsIm:               ch.IsIm,
+				IsMember:           ch.IsMember,
+				IsMpim:             ch.IsMpim,
+				IsOrgShared:        ch.IsOrgShared,
+				IsPendingExtShared: ch.IsPendingExtShared,
+				IsPrivate:          ch.IsPrivate,
+				IsShared:           ch.IsShared,
+				Name:               ch.Name,
+				NameNormalized:     ch.NameNormalized,
+				Created:            ch.Created,
+				Updated:            ch.Updated,
+				Status:             status,
+			})
+		}
+		cursor = resp.ResponseMetadata.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	return channels, nil
+}
+
+type userInfoResp struct {
+	User struct {
+		Id       string `json:"id"`
+		TeamId   string `json:"team_id"`
+		Name     string `json:"name"`
+		RealName string `json:"real_name"`
+		IsBot    bool   `json:"is_bot"`
+		Profile  struct {
+			Email string `json:"email"`
+		} `json:"profile"`
+	} `json:"user"`
+}
+
+// UserInfo returns the Slack user profile, email requires the users:read.email scope.
+func (c *Client) UserInfo(ctx context.Context, token, userRef string) (models.SlackUser, error) {
+	form := url.Values{}
+	form.Set("user", userRef)
+
+	var resp userInfoResp
+	if err := c.call(ctx, token, "users.info", form, &resp); err != nil {
+		return models.SlackUser{}, err
+	}
+	return models.SlackUser{
+		Ref:      resp.User.Id,
+		TeamRef:  resp.User.TeamId,
+		Name:     resp.User.Name,
+		RealName: resp.User.RealName,
+		Email:    resp.User.Profile.Email,
+		IsBot:    resp.User.IsBot,
+	}, nil
+}
+
+type postMessageResp struct {
+	Ts string `json:"ts"`
+}
+
+// PostMessage posts the message in the channel as the reply in the Slack thread, returns the message ts.
+func (c *Client) PostMessage(ctx context.Context, token, channelRef, text, threadTs string) (string, error) {
+	form := url.Values{}
+	form.Set("channel", channelRef)
+	form.Set("text", text)
+	if threadTs != "" {
+		form.Set("thread_ts", threadTs)
+	}
+
+	var resp postMessageResp
+	if err := c.call(ctx, token, "chat.postMessage", form, &resp); err != nil {
+		return "", err
+	}
+	return resp.Ts, nil
+}
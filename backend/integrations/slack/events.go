@@ -0,0 +1,104 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/zyghq/zyg/models"
+)
+
+// maxRequestAge is the max age of the signed request, older requests are rejected as replays.
+const maxRequestAge = 5 * time.Minute
+
+// VerifySignature verifies the X-Slack-Signature of the Events API request signed with the signing secret.
+func VerifySignature(body []byte, signingSecret, timestamp, signature string, now time.Time) bool {
+	if signingSecret == "" || timestamp == "" || signature == "" {
+		return false
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > maxRequestAge || age < -maxRequestAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	_, _ = fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// EventPayload is the Events API request payload.
+// URL verification request has the challenge, event callback has the event.
+type EventPayload struct {
+	Type      string       `json:"type"`
+	Challenge string       `json:"challenge"`
+	TeamId    string       `json:"team_id"`
+	EventId   string       `json:"event_id"`
+	Event     MessageEvent `json:"event"`
+}
+
+func (p EventPayload) IsURLVerification() bool {
+	return p.Type == "url_verification"
+}
+
+func (p EventPayload) IsEventCallback() bool {
+	return p.Type == "event_callback"
+}
+
+// MessageEvent is the message event posted in the channel.
+type MessageEvent struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	UserTeam string `json:"user_team"`
+	Team     string `json:"team"`
+	BotId    string `json:"bot_id"`
+	Text     string `json:"text"`
+	Ts       string `json:"ts"`
+	ThreadTs string `json:"thread_ts"`
+}
+
+// IsUserMessage checks if the event is the new message posted by the user.
+// Edits, deletes, joins and bot messages are not user messages.
+func (e MessageEvent) IsUserMessage() bool {
+	if e.Type != "message" || e.BotId != "" || e.User == "" {
+		return false
+	}
+	return e.Subtype == "" || e.Subtype == "file_share" || e.Subtype == "thread_broadcast"
+}
+
+// ParseEvent parses the Events API request payload.
+func ParseEvent(body []byte) (EventPayload, error) {
+	var payload EventPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return EventPayload{}, err
+	}
+	return payload, nil
+}
+
+// ToSlackInbound returns the inbound message of the event callback.
+func (p EventPayload) ToSlackInbound() models.SlackInbound {
+	userTeam := p.Event.UserTeam
+	if userTeam == "" {
+		userTeam = p.Event.Team
+	}
+	return models.SlackInbound{
+		TeamRef:    p.TeamId,
+		ChannelRef: p.Event.Channel,
+		UserRef:    p.Event.User,
+		UserTeam:   userTeam,
+		Text:       p.Event.Text,
+		Ts:         p.Event.Ts,
+		ThreadTs:   p.Event.ThreadTs,
+	}
+}
@@ -0,0 +1,55 @@
+package sms
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/rs/xid"
+	"github.com/zyghq/zyg/models"
+)
+
+// FakeProvider is the local SMS provider for development and tests.
+// Messages are recorded in memory instead of being sent and are reported as sent.
+type FakeProvider struct {
+	mu   sync.Mutex
+	sent []models.OutboundSMS
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (f *FakeProvider) Name() string {
+	return models.SMSProviderName{}.Fake()
+}
+
+func (f *FakeProvider) Send(
+	_ context.Context, _ models.SMSSetting, sms models.OutboundSMS) (models.SMSSendResult, error) {
+	f.mu.Lock()
+	f.sent = append(f.sent, sms)
+	f.mu.Unlock()
+
+	sid := "SM" + xid.New().String()
+	slog.Info("fake sms sent", slog.Any("sid", sid), slog.Any("to", sms.ToPhone), slog.Any("body", sms.Body))
+	return models.SMSSendResult{
+		ProviderMessageId: sid,
+		Status:            models.SMSStatus{}.Sent(),
+		Payload: map[string]interface{}{
+			"sid":    sid,
+			"from":   sms.FromPhone,
+			"to":     sms.ToPhone,
+			"body":   sms.Body,
+			"status": models.SMSStatus{}.Sent(),
+		},
+	}, nil
+}
+
+// Sent returns the messages recorded so far.
+func (f *FakeProvider) Sent() []models.OutboundSMS {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sent := make([]models.OutboundSMS, len(f.sent))
+	copy(sent, f.sent)
+	return sent
+}
@@ -0,0 +1,144 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg/integrations"
+	"github.com/zyghq/zyg/models"
+)
+
+const twilioBaseURL = "https://api.twilio.com"
+
+// TwilioProvider sends SMS with the Twilio Messages API.
+type TwilioProvider struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewTwilioProvider() *TwilioProvider {
+	return &TwilioProvider{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: twilioBaseURL,
+	}
+}
+
+func (t *TwilioProvider) Name() string {
+	return models.SMSProviderName{}.Twilio()
+}
+
+// twilioMessageResp is the subset of the Twilio message resource.
+type twilioMessageResp struct {
+	Sid          string  `json:"sid"`
+	Status       string  `json:"status"`
+	ErrorCode    *int    `json:"error_code"`
+	ErrorMessage *string `json:"error_message"`
+	Message      string  `json:"message"` // set on API errors
+}
+
+func (t *TwilioProvider) Send(
+	ctx context.Context, setting models.SMSSetting, sms models.OutboundSMS) (models.SMSSendResult, error) {
+	form := url.Values{}
+	form.Set("From", sms.FromPhone)
+	form.Set("To", sms.ToPhone)
+	form.Set("Body", sms.Body)
+	if sms.StatusCallback != "" {
+		form.Set("StatusCallback", sms.StatusCallback)
+	}
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", t.baseURL, url.PathEscape(setting.AccountSid))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return models.SMSSendResult{}, err
+	}
+	req.SetBasicAuth(setting.AccountSid, setting.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		slog.Error("failed to send twilio sms", slog.Any("err", err))
+		return models.SMSSendResult{}, integrations.ErrSMSSend
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var payload map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		slog.Error("failed to decode twilio sms response", slog.Any("err", err))
+		return models.SMSSendResult{}, integrations.ErrSMSSend
+	}
+	var msg twilioMessageResp
+	b, _ := json.Marshal(payload)
+	_ = json.Unmarshal(b, &msg)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		slog.Error("twilio sms request failed",
+			slog.Any("status", resp.StatusCode), slog.Any("message", msg.Message))
+		return models.SMSSendResult{}, integrations.ErrSMSSend
+	}
+
+	result := models.SMSSendResult{
+		ProviderMessageId: msg.Sid,
+		Status:            msg.Status,
+		ErrorMessage:      msg.ErrorMessage,
+		Payload:           payload,
+	}
+	if msg.ErrorCode != nil {
+		code := strconv.Itoa(*msg.ErrorCode)
+		result.ErrorCode = &code
+	}
+	return result, nil
+}
+
+// formPayload returns the form values as the raw payload, with the first value for each key.
+func formPayload(form url.Values) map[string]interface{} {
+	payload := make(map[string]interface{}, len(form))
+	for k := range form {
+		payload[k] = form.Get(k)
+	}
+	return payload
+}
+
+func optionalFormValue(form url.Values, key string) *string {
+	v := form.Get(key)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+// FromTwilioInboundForm parses the Twilio-compatible inbound message webhook form.
+func FromTwilioInboundForm(form url.Values) models.InboundSMS {
+	numMedia, _ := strconv.Atoi(form.Get("NumMedia"))
+	return models.InboundSMS{
+		ProviderMessageId: form.Get("MessageSid"),
+		FromPhone:         form.Get("From"),
+		ToPhone:           form.Get("To"),
+		Body:              form.Get("Body"),
+		NumMedia:          numMedia,
+		Payload:           formPayload(form),
+		CreatedAt:         time.Now().UTC(),
+	}
+}
+
+// FromTwilioStatusForm parses the Twilio-compatible message status callback form.
+func FromTwilioStatusForm(form url.Values) models.SMSStatusUpdate {
+	status := form.Get("MessageStatus")
+	if status == "" {
+		status = form.Get("SmsStatus")
+	}
+	return models.SMSStatusUpdate{
+		ProviderMessageId: form.Get("MessageSid"),
+		Status:            status,
+		ErrorCode:         optionalFormValue(form, "ErrorCode"),
+		ErrorMessage:      optionalFormValue(form, "ErrorMessage"),
+	}
+}
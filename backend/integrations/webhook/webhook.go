@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zyghq/zyg/models"
+)
+
+// Sender delivers the API channel events to the workspace webhook.
+// The payload is signed with the webhook secret, the receiver verifies the X-Zyg-Signature header
+// as the hex HMAC SHA256 of "{timestamp}.{body}" with the X-Zyg-Timestamp header.
+type Sender struct {
+	client *http.Client
+}
+
+func NewSender() *Sender {
+	return &Sender{
+		client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Sign returns the signature of the payload body sent at the timestamp.
+func Sign(body []byte, secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%s.", timestamp)
+	mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver posts the event to the webhook URL of the setting.
+// Failures are returned in the result, the caller records the delivery.
+func (s *Sender) Deliver(
+	ctx context.Context, setting models.APISetting, event models.APIWebhookEvent) models.APIWebhookResult {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return models.APIWebhookResult{Error: fmt.Sprintf("failed to encode event: %v", err)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, setting.WebhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return models.APIWebhookResult{Error: fmt.Sprintf("failed to create request: %v", err)}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Zyg-Event", event.Event)
+	req.Header.Set("X-Zyg-Timestamp", timestamp)
+	req.Header.Set("X-Zyg-Signature", Sign(body, setting.WebhookSecret, timestamp))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return models.APIWebhookResult{Error: fmt.Sprintf("failed to deliver webhook: %v", err)}
+	}
+	defer func() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	result := models.APIWebhookResult{StatusCode: resp.StatusCode}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("webhook responded with status %d", resp.StatusCode)
+	}
+	return result
+}
@@ -0,0 +1,204 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg/integrations"
+	"github.com/zyghq/zyg/models"
+)
+
+const (
+	graphBaseURL = "https://graph.facebook.com"
+	graphVersion = "v20.0"
+
+	// maxMediaSize is the max media size downloaded for the inbound message.
+	maxMediaSize = 25 << 20
+)
+
+// CloudClient sends messages and downloads media with the WhatsApp Cloud API.
+// The API base URL of the workspace setting points the client to the local stand-in.
+type CloudClient struct {
+	client *http.Client
+}
+
+func NewCloudClient() *CloudClient {
+	return &CloudClient{
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func baseURL(setting models.WhatsAppSetting) string {
+	if setting.APIBaseURL != "" {
+		return strings.TrimSuffix(setting.APIBaseURL, "/")
+	}
+	return graphBaseURL
+}
+
+type sendMessageResp struct {
+	Messages []struct {
+		Id string `json:"id"`
+	} `json:"messages"`
+	Error *struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+func (c *CloudClient) send(
+	ctx context.Context, setting models.WhatsAppSetting, reqBody map[string]interface{},
+) (models.WhatsAppSendResult, error) {
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return models.WhatsAppSendResult{}, err
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/%s/messages", baseURL(setting), graphVersion, setting.PhoneNumberId)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return models.WhatsAppSendResult{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+setting.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		slog.Error("failed to send whatsapp message", slog.Any("err", err))
+		return models.WhatsAppSendResult{}, integrations.ErrWhatsAppSend
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var payload map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		slog.Error("failed to decode whatsapp send response", slog.Any("err", err))
+		return models.WhatsAppSendResult{}, integrations.ErrWhatsAppSend
+	}
+	var msg sendMessageResp
+	b, _ := json.Marshal(payload)
+	_ = json.Unmarshal(b, &msg)
+
+	if resp.StatusCode >= http.StatusBadRequest || len(msg.Messages) == 0 {
+		if msg.Error != nil {
+			slog.Error("whatsapp send request failed",
+				slog.Any("status", resp.StatusCode),
+				slog.Any("code", msg.Error.Code), slog.Any("message", msg.Error.Message))
+		}
+		return models.WhatsAppSendResult{}, integrations.ErrWhatsAppSend
+	}
+	return models.WhatsAppSendResult{
+		WAMessageId: msg.Messages[0].Id,
+		Payload:     payload,
+	}, nil
+}
+
+// SendText sends the free-form text message, only allowed within the session window.
+func (c *CloudClient) SendText(
+	ctx context.Context, setting models.WhatsAppSetting, to, body string) (models.WhatsAppSendResult, error) {
+	return c.send(ctx, setting, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "text",
+		"text": map[string]interface{}{
+			"preview_url": false,
+			"body":        body,
+		},
+	})
+}
+
+// SendTemplate sends the pre-approved template message with the body parameters.
+func (c *CloudClient) SendTemplate(
+	ctx context.Context, setting models.WhatsAppSetting, to string, template models.WhatsAppTemplate,
+) (models.WhatsAppSendResult, error) {
+	tmpl := map[string]interface{}{
+		"name":     template.Name,
+		"language": map[string]string{"code": template.Language},
+	}
+	if len(template.Params) > 0 {
+		params := make([]map[string]string, 0, len(template.Params))
+		for _, p := range template.Params {
+			params = append(params, map[string]string{"type": "text", "text": p})
+		}
+		tmpl["components"] = []map[string]interface{}{
+			{"type": "body", "parameters": params},
+		}
+	}
+	return c.send(ctx, setting, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                to,
+		"type":              "template",
+		"template":          tmpl,
+	})
+}
+
+type mediaResp struct {
+	Url      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+// DownloadMedia fetches the media URL by the media ID and downloads the media content.
+func (c *CloudClient) DownloadMedia(
+	ctx context.Context, setting models.WhatsAppSetting, mediaId string) ([]byte, string, error) {
+	endpoint := fmt.Sprintf("%s/%s/%s", baseURL(setting), graphVersion, mediaId)
+	var media mediaResp
+	if err := c.getJSON(ctx, setting, endpoint, &media); err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, media.Url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+setting.AccessToken)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		slog.Error("failed to download whatsapp media", slog.Any("err", err))
+		return nil, "", integrations.ErrWhatsAppMedia
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("whatsapp media download failed", slog.Any("status", resp.StatusCode))
+		return nil, "", integrations.ErrWhatsAppMedia
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaSize))
+	if err != nil {
+		return nil, "", integrations.ErrWhatsAppMedia
+	}
+	return content, media.MimeType, nil
+}
+
+func (c *CloudClient) getJSON(ctx context.Context, setting models.WhatsAppSetting, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+setting.AccessToken)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		slog.Error("failed to fetch whatsapp media url", slog.Any("err", err))
+		return integrations.ErrWhatsAppMedia
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		slog.Error("whatsapp media url request failed", slog.Any("status", resp.StatusCode))
+		return integrations.ErrWhatsAppMedia
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return integrations.ErrWhatsAppMedia
+	}
+	return nil
+}
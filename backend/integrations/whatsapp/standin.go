@@ -0,0 +1,237 @@
+package whatsapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// StandIn is the local stand-in for the Cloud API to test the WhatsApp flow offline.
+// Point the workspace setting API base URL to the stand-in.
+// Sent messages are acknowledged with the sent and delivered status webhooks,
+// inbound messages are simulated with POST /simulate/inbound.
+type StandIn struct {
+	WebhookURL string // workspace WhatsApp webhook URL
+	AppSecret  string // signs the webhook payloads, same as the workspace setting
+	PublicURL  string // base URL the stand-in is reachable at
+
+	mu    sync.Mutex
+	media map[string]standInMedia
+}
+
+type standInMedia struct {
+	MimeType string
+	Content  []byte
+}
+
+func NewStandIn(webhookURL, appSecret, publicURL string) *StandIn {
+	return &StandIn{
+		WebhookURL: webhookURL,
+		AppSecret:  appSecret,
+		PublicURL:  publicURL,
+		media:      make(map[string]standInMedia),
+	}
+}
+
+func (s *StandIn) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{version}/{phoneNumberId}/messages", s.handleSendMessage)
+	mux.HandleFunc("GET /{version}/{mediaId}", s.handleGetMedia)
+	mux.HandleFunc("GET /media/{mediaId}", s.handleDownloadMedia)
+	mux.HandleFunc("POST /simulate/inbound", s.handleSimulateInbound)
+	return mux
+}
+
+func (s *StandIn) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	var reqp map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&reqp); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	to, _ := reqp["to"].(string)
+	phoneNumberId := r.PathValue("phoneNumberId")
+	wamid := "wamid." + xid.New().String()
+	slog.Info("stand-in whatsapp message sent", slog.Any("to", to), slog.Any("wamid", wamid))
+
+	go func() {
+		for _, status := range []string{"sent", "delivered"} {
+			time.Sleep(500 * time.Millisecond)
+			s.postWebhook(phoneNumberId, map[string]interface{}{
+				"statuses": []map[string]interface{}{{
+					"id":           wamid,
+					"status":       status,
+					"timestamp":    strconv.FormatInt(time.Now().Unix(), 10),
+					"recipient_id": to,
+				}},
+			})
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"contacts":          []map[string]string{{"input": to, "wa_id": to}},
+		"messages":          []map[string]string{{"id": wamid}},
+	})
+}
+
+func (s *StandIn) handleGetMedia(w http.ResponseWriter, r *http.Request) {
+	mediaId := r.PathValue("mediaId")
+	s.mu.Lock()
+	media, ok := s.media[mediaId]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        mediaId,
+		"url":       fmt.Sprintf("%s/media/%s", s.PublicURL, mediaId),
+		"mime_type": media.MimeType,
+		"file_size": len(media.Content),
+	})
+}
+
+func (s *StandIn) handleDownloadMedia(w http.ResponseWriter, r *http.Request) {
+	mediaId := r.PathValue("mediaId")
+	s.mu.Lock()
+	media, ok := s.media[mediaId]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", media.MimeType)
+	_, _ = w.Write(media.Content)
+}
+
+// SimulateInboundReq is the simulated inbound message from the Customer.
+// Media content is base64 encoded, reaction requires the reacted WhatsApp message ID.
+type SimulateInboundReq struct {
+	PhoneNumberId string  `json:"phoneNumberId"`
+	From          string  `json:"from"`
+	Name          string  `json:"name"`
+	Text          string  `json:"text"`
+	MediaType     string  `json:"mediaType"`
+	MimeType      string  `json:"mimeType"`
+	Filename      string  `json:"filename"`
+	Content       []byte  `json:"content"`
+	Caption       string  `json:"caption"`
+	ReactTo       *string `json:"reactTo"`
+	Emoji         string  `json:"emoji"`
+}
+
+func (s *StandIn) handleSimulateInbound(w http.ResponseWriter, r *http.Request) {
+	defer func(r io.ReadCloser) {
+		_, _ = io.Copy(io.Discard, r)
+		_ = r.Close()
+	}(r.Body)
+
+	var reqp SimulateInboundReq
+	if err := json.NewDecoder(r.Body).Decode(&reqp); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if reqp.From == "" || reqp.PhoneNumberId == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	wamid := "wamid." + xid.New().String()
+	message := map[string]interface{}{
+		"from":      reqp.From,
+		"id":        wamid,
+		"timestamp": strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	switch {
+	case reqp.ReactTo != nil:
+		message["type"] = "reaction"
+		message["reaction"] = map[string]string{"message_id": *reqp.ReactTo, "emoji": reqp.Emoji}
+	case reqp.MediaType != "":
+		mediaId := xid.New().String()
+		s.mu.Lock()
+		s.media[mediaId] = standInMedia{MimeType: reqp.MimeType, Content: reqp.Content}
+		s.mu.Unlock()
+		message["type"] = reqp.MediaType
+		message[reqp.MediaType] = map[string]string{
+			"id":        mediaId,
+			"mime_type": reqp.MimeType,
+			"filename":  reqp.Filename,
+			"caption":   reqp.Caption,
+		}
+	default:
+		message["type"] = "text"
+		message["text"] = map[string]string{"body": reqp.Text}
+	}
+
+	status := s.postWebhook(reqp.PhoneNumberId, map[string]interface{}{
+		"contacts": []map[string]interface{}{{
+			"profile": map[string]string{"name": reqp.Name},
+			"wa_id":   reqp.From,
+		}},
+		"messages": []map[string]interface{}{message},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":            wamid,
+		"webhookStatus": status,
+	})
+}
+
+// postWebhook posts the signed webhook notification with the value, returns the response status code.
+func (s *StandIn) postWebhook(phoneNumberId string, value map[string]interface{}) int {
+	value["messaging_product"] = "whatsapp"
+	value["metadata"] = map[string]string{
+		"display_phone_number": phoneNumberId,
+		"phone_number_id":      phoneNumberId,
+	}
+	payload := map[string]interface{}{
+		"object": "whatsapp_business_account",
+		"entry": []map[string]interface{}{{
+			"id": "standin",
+			"changes": []map[string]interface{}{{
+				"field": "messages",
+				"value": value,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("failed to marshal stand-in webhook", slog.Any("err", err))
+		return 0
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		slog.Error("failed to create stand-in webhook request", slog.Any("err", err))
+		return 0
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature-256", Sign(body, s.AppSecret))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("failed to post stand-in webhook", slog.Any("err", err))
+		return 0
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	return resp.StatusCode
+}
@@ -0,0 +1,210 @@
+package whatsapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg/models"
+)
+
+// WebhookPayload is the Cloud API webhook notification payload.
+type WebhookPayload struct {
+	Object string `json:"object"`
+	Entry  []struct {
+		Id      string `json:"id"`
+		Changes []struct {
+			Field string       `json:"field"`
+			Value WebhookValue `json:"value"`
+		} `json:"changes"`
+	} `json:"entry"`
+}
+
+type WebhookValue struct {
+	MessagingProduct string `json:"messaging_product"`
+	Metadata         struct {
+		DisplayPhoneNumber string `json:"display_phone_number"`
+		PhoneNumberId      string `json:"phone_number_id"`
+	} `json:"metadata"`
+	Contacts []struct {
+		Profile struct {
+			Name string `json:"name"`
+		} `json:"profile"`
+		WaId string `json:"wa_id"`
+	} `json:"contacts"`
+	Messages []WebhookMessage `json:"messages"`
+	Statuses []WebhookStatus  `json:"statuses"`
+}
+
+type WebhookMedia struct {
+	Id       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	Sha256   string `json:"sha256"`
+	Caption  string `json:"caption,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+type WebhookMessage struct {
+	From      string `json:"from"`
+	Id        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      *struct {
+		Body string `json:"body"`
+	} `json:"text,omitempty"`
+	Image    *WebhookMedia `json:"image,omitempty"`
+	Video    *WebhookMedia `json:"video,omitempty"`
+	Audio    *WebhookMedia `json:"audio,omitempty"`
+	Document *WebhookMedia `json:"document,omitempty"`
+	Sticker  *WebhookMedia `json:"sticker,omitempty"`
+	Reaction *struct {
+		MessageId string `json:"message_id"`
+		Emoji     string `json:"emoji"`
+	} `json:"reaction,omitempty"`
+}
+
+type WebhookStatus struct {
+	Id          string `json:"id"`
+	Status      string `json:"status"`
+	Timestamp   string `json:"timestamp"`
+	RecipientId string `json:"recipient_id"`
+	Errors      []struct {
+		Code  int    `json:"code"`
+		Title string `json:"title"`
+	} `json:"errors,omitempty"`
+}
+
+// WebhookEvents are the events of the webhook notification.
+type WebhookEvents struct {
+	Messages  []models.WhatsAppInbound
+	Reactions []models.WhatsAppReaction
+	Statuses  []models.WhatsAppStatusUpdate
+}
+
+// VerifySignature verifies the X-Hub-Signature-256 header of the payload signed with the app secret.
+func VerifySignature(body []byte, appSecret, signature string) bool {
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Sign returns the X-Hub-Signature-256 header value of the payload.
+func Sign(body []byte, appSecret string) string {
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func parseTimestamp(ts string) time.Time {
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Now().UTC()
+	}
+	return time.Unix(secs, 0).UTC()
+}
+
+func (m WebhookMessage) media() *WebhookMedia {
+	switch m.Type {
+	case "image":
+		return m.Image
+	case "video":
+		return m.Video
+	case "audio":
+		return m.Audio
+	case "document":
+		return m.Document
+	case "sticker":
+		return m.Sticker
+	default:
+		return nil
+	}
+}
+
+// ParseWebhook parses the webhook notification into the inbound messages, reactions and statuses.
+// Unsupported message types are skipped.
+func ParseWebhook(body []byte) (WebhookEvents, error) {
+	var payload WebhookPayload
+	events := WebhookEvents{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return events, err
+	}
+
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			value := change.Value
+			names := make(map[string]string, len(value.Contacts))
+			for _, c := range value.Contacts {
+				names[c.WaId] = c.Profile.Name
+			}
+
+			for _, m := range value.Messages {
+				if m.Type == "reaction" && m.Reaction != nil {
+					events.Reactions = append(events.Reactions, models.WhatsAppReaction{
+						FromWaId:        m.From,
+						TargetMessageId: m.Reaction.MessageId,
+						Emoji:           m.Reaction.Emoji,
+					})
+					continue
+				}
+
+				inbound := models.WhatsAppInbound{
+					WAMessageId:   m.Id,
+					FromWaId:      m.From,
+					ProfileName:   names[m.From],
+					PhoneNumberId: value.Metadata.PhoneNumberId,
+					Kind:          m.Type,
+					Timestamp:     parseTimestamp(m.Timestamp),
+				}
+				if m.Type == "text" && m.Text != nil {
+					inbound.Body = m.Text.Body
+				} else if media := m.media(); media != nil {
+					inbound.Media = &models.WhatsAppMedia{
+						MediaId:  media.Id,
+						MimeType: media.MimeType,
+						Filename: media.Filename,
+						Caption:  media.Caption,
+					}
+				} else {
+					continue
+				}
+
+				// Raw message is kept as payload of the message log.
+				var raw map[string]interface{}
+				b, _ := json.Marshal(m)
+				_ = json.Unmarshal(b, &raw)
+				inbound.Payload = raw
+				events.Messages = append(events.Messages, inbound)
+			}
+
+			for _, st := range value.Statuses {
+				update := models.WhatsAppStatusUpdate{
+					WAMessageId: st.Id,
+					Status:      st.Status,
+				}
+				if len(st.Errors) > 0 {
+					code := strconv.Itoa(st.Errors[0].Code)
+					title := st.Errors[0].Title
+					update.ErrorCode = &code
+					update.ErrorTitle = &title
+				}
+				events.Statuses = append(events.Statuses, update)
+			}
+		}
+	}
+	return events, nil
+}
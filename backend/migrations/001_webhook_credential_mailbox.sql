@@ -0,0 +1,18 @@
+-- Migrates the databases created from the earlier schema.sql, new databases are created from schema.sql.
+-- Apply in order of the file number, e.g. `psql "$DATABASE_URL" -f migrations/001_webhook_credential_mailbox.sql`.
+
+-- Webhook credentials of the workspace mailboxes, only accepting the inbound mail addressed to the mailbox.
+BEGIN;
+
+ALTER TABLE webhook_credential
+    ADD COLUMN mailbox_id VARCHAR(255) NULL; -- null for the workspace
+
+ALTER TABLE webhook_credential
+    ADD CONSTRAINT webhook_credential_mailbox_id_fkey
+        FOREIGN KEY (mailbox_id) REFERENCES mailbox (mailbox_id) ON DELETE CASCADE;
+
+COMMIT;
+
+-- The workspace webhook credentials are then backfilled on the server start, see BackfillWebhookCredentials.
+-- Set ZYG_WEBHOOK_LEGACY_AUTH_UNTIL to the cutover deadline for the global webhook credentials to be accepted
+-- until the inbound hooks are updated, unset the global webhook credentials are not accepted.
@@ -0,0 +1,58 @@
+-- Migrates the Postmark message logs to the channel message logs of the email channel, then drops
+-- postmark_message_log. Outbound mail without the error is sent, the delivery status was not tracked.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS channel_message_log
+(
+    message_id    VARCHAR(255) NOT NULL, -- References parent message
+    channel       VARCHAR(127) NOT NULL,
+    message_type  VARCHAR(127) NOT NULL, -- inbound or outbound
+    external_id   VARCHAR(255) NOT NULL, -- Provider's message ID
+    external_ref  VARCHAR(511) NULL,     -- Channel protocol reference, e.g. mail `Message-ID` header
+    reply_ref     VARCHAR(511) NULL,     -- Channel protocol reference replied to, e.g. mail `In-Reply-To` header
+    payload       JSONB        NOT NULL, -- Request payload
+    status        VARCHAR(127) NOT NULL,
+    has_error     BOOLEAN      NOT NULL DEFAULT FALSE,
+    error_code    BIGINT       NOT NULL DEFAULT 0,
+    error_message TEXT         NOT NULL DEFAULT '',
+    acknowledged  BOOLEAN      NOT NULL DEFAULT FALSE,
+    submitted_at  TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    created_at    TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT channel_message_log_message_id_pkey PRIMARY KEY (message_id),
+    CONSTRAINT channel_message_log_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id),
+
+    CONSTRAINT channel_message_log_external_id_key UNIQUE (channel, external_id),
+    CONSTRAINT channel_message_log_external_ref_key UNIQUE (channel, external_ref)
+);
+CREATE INDEX IF NOT EXISTS channel_message_log_reply_ref_idx ON channel_message_log (channel, reply_ref);
+
+INSERT INTO channel_message_log (message_id, channel, message_type, external_id, external_ref, reply_ref,
+                                 payload, status, has_error, error_code, error_message, acknowledged,
+                                 submitted_at, created_at, updated_at)
+SELECT pl.message_id,
+       'email',
+       pl.message_type,
+       pl.postmark_message_id,
+       pl.mail_message_id,
+       NULLIF(pl.reply_mail_message_id, ''),
+       pl.payload,
+       CASE
+           WHEN pl.message_type = 'inbound' THEN 'received'
+           WHEN pl.has_error THEN 'failed'
+           ELSE 'sent'
+           END,
+       pl.has_error,
+       pl.error_code,
+       CASE WHEN pl.error_code <> 0 THEN pl.postmark_message ELSE '' END,
+       pl.acknowledged,
+       pl.submitted_at,
+       pl.created_at,
+       pl.updated_at
+FROM postmark_message_log pl
+ON CONFLICT DO NOTHING;
+
+DROP TABLE postmark_message_log;
+
+COMMIT;
@@ -0,0 +1,19 @@
+-- Customer mail setting of the workspace, the CSAT, transcript and notification mail are not sent unless enabled.
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS customer_mail_setting
+(
+    workspace_id      VARCHAR(255) NOT NULL,
+    send_csat         BOOLEAN      NOT NULL DEFAULT FALSE,
+    csat_url          TEXT         NOT NULL, -- workspace survey linked with the thread ID
+    send_transcript   BOOLEAN      NOT NULL DEFAULT FALSE,
+    send_notification BOOLEAN      NOT NULL DEFAULT FALSE,
+    conversation_url  TEXT         NOT NULL, -- page of the chat widget linked in the notification
+    created_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT customer_mail_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT customer_mail_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+COMMIT;
@@ -0,0 +1,24 @@
+-- Spam sender block rules are moved to the workspace blocklist, the spam sender rules only allow the sender.
+-- Blocked senders were scored as spam, so they are quarantined on the blocklist. Customer rules are moved
+-- by the customer's email, blocked customers without the email are to be blocked with the customer block.
+BEGIN;
+
+INSERT INTO sender_block (block_id, workspace_id, kind, value, action, reason, created_by_id, created_at, updated_at)
+SELECT 'sb' || SUBSTRING(sr.rule_id FROM 3),
+       sr.workspace_id,
+       CASE WHEN sr.kind = 'domain' THEN 'domain' ELSE 'email' END,
+       CASE WHEN sr.kind = 'customer' THEN LOWER(c.email) ELSE sr.value END,
+       'quarantine',
+       'moved from the spam sender block list',
+       sr.created_by_id,
+       sr.created_at,
+       sr.updated_at
+FROM spam_sender_rule sr
+LEFT OUTER JOIN customer c ON sr.kind = 'customer' AND c.customer_id = sr.value
+WHERE sr.action = 'block'
+  AND (sr.kind <> 'customer' OR c.email IS NOT NULL)
+ON CONFLICT (workspace_id, kind, value) DO NOTHING;
+
+DELETE FROM spam_sender_rule WHERE action = 'block';
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- Thread participant emails are lower cased, participants are matched by the email case-insensitively.
+-- Of the participants with the same email in different cases, the lower cased or else the first is kept.
+BEGIN;
+
+DELETE FROM thread_participant tp
+USING thread_participant o
+WHERE tp.thread_id = o.thread_id
+  AND LOWER(tp.email) = LOWER(o.email)
+  AND tp.email <> o.email
+  AND (o.email = LOWER(o.email) OR (tp.email <> LOWER(tp.email) AND o.email < tp.email));
+
+UPDATE thread_participant SET email = LOWER(email), updated_at = NOW() WHERE email <> LOWER(email);
+
+COMMIT;
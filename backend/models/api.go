@@ -0,0 +1,152 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+// APISetting is the workspace API channel setting.
+// Threads are created from the workspace's own backend, Member replies are delivered to the webhook URL.
+type APISetting struct {
+	WorkspaceId   string    `json:"workspaceId"`
+	IsEnabled     bool      `json:"isEnabled"`
+	WebhookUrl    string    `json:"webhookUrl"`
+	WebhookSecret string    `json:"-"` // signs the webhook payload
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// NewAPISetting returns the default disabled API setting for the workspace.
+func NewAPISetting(workspaceId string) APISetting {
+	now := time.Now().UTC()
+	return APISetting{
+		WorkspaceId: workspaceId,
+		IsEnabled:   false,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func (s APISetting) Validate() error {
+	if s.WebhookUrl == "" {
+		return nil
+	}
+	u, err := url.Parse(s.WebhookUrl)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("webhook url must be a valid http or https url")
+	}
+	if s.WebhookSecret == "" {
+		return errors.New("webhook secret is required with the webhook url")
+	}
+	return nil
+}
+
+// HasWebhook checks if the Member replies can be delivered to the webhook.
+func (s APISetting) HasWebhook() bool {
+	return s.WebhookUrl != "" && s.WebhookSecret != ""
+}
+
+// APIWebhookStatus represents the webhook delivery status of the outbound API message.
+type APIWebhookStatus struct{}
+
+func (s APIWebhookStatus) Delivered() string {
+	return "delivered"
+}
+
+func (s APIWebhookStatus) Failed() string {
+	return "failed"
+}
+
+// APIMessageLog tracks the API channel message, for outbound the webhook delivery.
+type APIMessageLog struct {
+	MessageId     string    `json:"messageId"`
+	MessageType   string    `json:"messageType"` // inbound or outbound
+	WebhookStatus *string   `json:"webhookStatus"`
+	WebhookCode   *int      `json:"webhookCode"`
+	WebhookError  *string   `json:"webhookError"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// APIAttachment is the base64 encoded attachment of the Customer's message sent with the API.
+type APIAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+// APIWebhookEvent is the payload delivered to the workspace webhook on the Member's reply.
+type APIWebhookEvent struct {
+	Event       string                  `json:"event"`
+	WorkspaceId string                  `json:"workspaceId"`
+	ThreadId    string                  `json:"threadId"`
+	Customer    APIWebhookEventCustomer `json:"customer"`
+	Message     APIWebhookEventMessage  `json:"message"`
+}
+
+type APIWebhookEventCustomer struct {
+	CustomerId string  `json:"customerId"`
+	ExternalId *string `json:"externalId"`
+	Email      *string `json:"email"`
+	Phone      *string `json:"phone"`
+	Name       string  `json:"name"`
+}
+
+type APIWebhookEventMessage struct {
+	MessageId    string                `json:"messageId"`
+	TextBody     string                `json:"textBody"`
+	MarkdownBody string                `json:"markdownBody"`
+	Member       APIWebhookEventMember `json:"member"`
+	CreatedAt    time.Time             `json:"createdAt"`
+}
+
+type APIWebhookEventMember struct {
+	MemberId string `json:"memberId"`
+	Name     string `json:"name"`
+}
+
+// NewAPIReplyEvent returns the webhook event of the Member's reply on the API Thread.
+func NewAPIReplyEvent(thread Thread, customer Customer, message Message) APIWebhookEvent {
+	event := APIWebhookEvent{
+		Event:       "thread.message.replied",
+		WorkspaceId: thread.WorkspaceId,
+		ThreadId:    thread.ThreadId,
+		Customer: APIWebhookEventCustomer{
+			CustomerId: customer.CustomerId,
+			Name:       customer.Name,
+		},
+		Message: APIWebhookEventMessage{
+			MessageId:    message.MessageId,
+			TextBody:     message.TextBody,
+			MarkdownBody: message.MarkdownBody,
+			CreatedAt:    message.CreatedAt,
+		},
+	}
+	if customer.ExternalId.Valid {
+		event.Customer.ExternalId = &customer.ExternalId.String
+	}
+	if customer.Email.Valid {
+		event.Customer.Email = &customer.Email.String
+	}
+	if customer.Phone.Valid {
+		event.Customer.Phone = &customer.Phone.String
+	}
+	if message.Member != nil {
+		event.Message.Member = APIWebhookEventMember{
+			MemberId: message.Member.MemberId,
+			Name:     message.Member.Name,
+		}
+	}
+	return event
+}
+
+// APIWebhookResult is the result of the webhook delivery.
+type APIWebhookResult struct {
+	StatusCode int
+	Error      string
+}
+
+func (r APIWebhookResult) IsDelivered() bool {
+	return r.Error == "" && r.StatusCode >= 200 && r.StatusCode < 300
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// BlockKind represents what the block applies to.
+// Customer blocks are flagged on the Customer, the others are sender blocks.
+type BlockKind struct{}
+
+func (k BlockKind) Customer() string {
+	return "customer"
+}
+
+func (k BlockKind) Email() string {
+	return "email"
+}
+
+func (k BlockKind) Domain() string {
+	return "domain"
+}
+
+func (k BlockKind) IPRange() string {
+	return "ip_range"
+}
+
+// IsSender checks if the kind is valid for the sender block.
+func (k BlockKind) IsSender(s string) bool {
+	switch s {
+	case k.Email(), k.Domain(), k.IPRange():
+		return true
+	default:
+		return false
+	}
+}
+
+// BlockAction represents what happens to the inbound mail from the blocked sender.
+// Quarantined mail is processed into the spam stage, dropped mail is not processed.
+type BlockAction struct{}
+
+func (a BlockAction) Drop() string {
+	return "drop"
+}
+
+func (a BlockAction) Quarantine() string {
+	return "quarantine"
+}
+
+func (a BlockAction) IsValid(s string) bool {
+	switch s {
+	case a.Drop(), a.Quarantine():
+		return true
+	default:
+		return false
+	}
+}
+
+// SenderBlock blocks the sender email address, domain or IP range from the workspace.
+type SenderBlock struct {
+	BlockId     string      `json:"blockId"`
+	WorkspaceId string      `json:"workspaceId"`
+	Kind        string      `json:"kind"`
+	Value       string      `json:"value"`
+	Action      string      `json:"action"`
+	Reason      *string     `json:"reason"`
+	CreatedBy   MemberActor `json:"createdBy"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+func (b SenderBlock) GenId() string {
+	return "sb" + xid.New().String()
+}
+
+// NewSenderBlock returns the sender block with the normalized value.
+// Returns an error if the value is not valid for the kind.
+func NewSenderBlock(
+	workspaceId, kind, value, action string, reason *string, createdBy MemberActor) (SenderBlock, error) {
+	if !(BlockKind{}).IsSender(kind) {
+		return SenderBlock{}, errors.New("invalid sender block kind")
+	}
+	if !(BlockAction{}).IsValid(action) {
+		return SenderBlock{}, errors.New("invalid sender block action")
+	}
+	value, err := NormalizeBlockValue(kind, value)
+	if err != nil {
+		return SenderBlock{}, err
+	}
+	now := time.Now().UTC()
+	return SenderBlock{
+		BlockId:     SenderBlock{}.GenId(),
+		WorkspaceId: workspaceId,
+		Kind:        kind,
+		Value:       value,
+		Action:      action,
+		Reason:      reason,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// NormalizeBlockValue lower cases the email or domain, IP ranges are normalized to CIDR notation
+// and a single IP address is treated as the single address range.
+func NormalizeBlockValue(kind, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch kind {
+	case BlockKind{}.Email():
+		if i := strings.LastIndex(value, "@"); i <= 0 || i == len(value)-1 {
+			return "", errors.New("invalid email address")
+		}
+	case BlockKind{}.Domain():
+		value = strings.TrimPrefix(value, "@")
+		if value == "" || strings.ContainsAny(value, "@ ") {
+			return "", errors.New("invalid domain")
+		}
+	case BlockKind{}.IPRange():
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			return prefix.Masked().String(), nil
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return "", errors.New("invalid ip range")
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+	}
+	return value, nil
+}
+
+// SenderBlockVerdict is the outcome of checking the sender against the blocklist.
+type SenderBlockVerdict struct {
+	IsBlocked bool   `json:"isBlocked"`
+	Kind      string `json:"kind"`
+	Value     string `json:"value"`
+	Action    string `json:"action"`
+}
+
+// BlockedCustomerVerdict returns the verdict for the blocked customer, whose inbound mail is dropped.
+func BlockedCustomerVerdict(customerId string) SenderBlockVerdict {
+	return SenderBlockVerdict{
+		IsBlocked: true,
+		Kind:      BlockKind{}.Customer(),
+		Value:     customerId,
+		Action:    BlockAction{}.Drop(),
+	}
+}
+
+func (v SenderBlockVerdict) IsDrop() bool {
+	return v.IsBlocked && v.Action == (BlockAction{}).Drop()
+}
+
+func (v SenderBlockVerdict) IsQuarantine() bool {
+	return v.IsBlocked && v.Action == (BlockAction{}).Quarantine()
+}
+
+// BlockAuditAction represents the audited block action.
+type BlockAuditAction struct{}
+
+func (a BlockAuditAction) Block() string {
+	return "block"
+}
+
+func (a BlockAuditAction) Unblock() string {
+	return "unblock"
+}
+
+// BlockAudit records the block and unblock actions taken by the member.
+type BlockAudit struct {
+	AuditId     string      `json:"auditId"`
+	WorkspaceId string      `json:"workspaceId"`
+	Action      string      `json:"action"`
+	Kind        string      `json:"kind"`
+	Value       string      `json:"value"`
+	Reason      *string     `json:"reason"`
+	Member      MemberActor `json:"member"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+func (a BlockAudit) GenId() string {
+	return "ba" + xid.New().String()
+}
+
+func NewBlockAudit(workspaceId, action, kind, value string, reason *string, member MemberActor) BlockAudit {
+	return BlockAudit{
+		AuditId:     BlockAudit{}.GenId(),
+		WorkspaceId: workspaceId,
+		Action:      action,
+		Kind:        kind,
+		Value:       value,
+		Reason:      reason,
+		Member:      member,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
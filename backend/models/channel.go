@@ -0,0 +1,272 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ChannelMessageType represents the direction of the channel message.
+type ChannelMessageType struct{}
+
+func (t ChannelMessageType) Inbound() string {
+	return "inbound"
+}
+
+func (t ChannelMessageType) Outbound() string {
+	return "outbound"
+}
+
+// ChannelMessageStatus represents the delivery status of the channel message.
+type ChannelMessageStatus struct{}
+
+func (s ChannelMessageStatus) Received() string {
+	return "received"
+}
+
+func (s ChannelMessageStatus) Sent() string {
+	return "sent"
+}
+
+func (s ChannelMessageStatus) Delivered() string {
+	return "delivered"
+}
+
+func (s ChannelMessageStatus) Failed() string {
+	return "failed"
+}
+
+// Complained is the outbound message marked as spam by the recipient.
+func (s ChannelMessageStatus) Complained() string {
+	return "complained"
+}
+
+// Opened is the outbound message opened by the recipient, as tracked by the provider.
+func (s ChannelMessageStatus) Opened() string {
+	return "opened"
+}
+
+// Clicked is the outbound message link clicked by the recipient, as tracked by the provider.
+func (s ChannelMessageStatus) Clicked() string {
+	return "clicked"
+}
+
+// IsFinal checks if the status is final, no further status is applied after.
+func (s ChannelMessageStatus) IsFinal(status string) bool {
+	return status == s.Failed() || status == s.Complained()
+}
+
+// CanUpdate checks if the status reported by the provider updates the current status.
+// Provider callbacks can arrive out of order, the status only moves forward as in
+// sent, delivered, opened, clicked. Failed and complained are final, except the complaint after the failure.
+func (s ChannelMessageStatus) CanUpdate(current string, next string) bool {
+	if s.IsFinal(current) {
+		return current == s.Failed() && next == s.Complained()
+	}
+	if s.IsFinal(next) {
+		return true
+	}
+	rank := map[string]int{
+		s.Sent():      1,
+		s.Delivered(): 2,
+		s.Opened():    3,
+		s.Clicked():   4,
+	}
+	return rank[next] > rank[current]
+}
+
+// ChannelMessageLog tracks the Thread message as exchanged with the channel provider.
+// Persisted for both inbound and outbound messages of the channels with the external provider.
+type ChannelMessageLog struct {
+	MessageId    string
+	Channel      string
+	MessageType  string
+	ExternalId   string  // provider's message ID, e.g. Postmark `MessageID`
+	ExternalRef  *string // channel protocol reference, e.g. mail `Message-ID` header
+	ReplyRef     *string // channel protocol reference replied to, e.g. mail `In-Reply-To` header
+	Payload      map[string]interface{}
+	Status       string
+	HasError     bool
+	ErrorCode    int64
+	ErrorMessage string
+	Acknowledged bool
+	SubmittedAt  time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// ChannelAttachment is the attachment of the inbound channel message with the base64 encoded content.
+type ChannelAttachment struct {
+	Name        string
+	ContentType string
+	Content     string
+	ContentId   string // inline attachment referenced as `cid:` in the HTML, if any
+}
+
+// ChannelInbound is the inbound message normalized by the channel adapter.
+// Attributes not applicable to the channel are left empty.
+type ChannelInbound struct {
+	Channel string
+
+	// Provider's message ID, used to dedupe the inbound message.
+	// Channels without the provider e.g. in-app chat leave it empty, no message log is kept.
+	ExternalId  string
+	ExternalRef *string
+	ReplyRef    *string
+	Payload     map[string]interface{}
+
+	// Channel protocol references of the earlier messages oldest first, e.g. mail `References` header.
+	References []string
+	// Thread reply token addressed to, e.g. the mail plus address tag.
+	ReplyToken string
+
+	// Authenticated Customer and the Thread, as for the in-app chat.
+	CustomerId *string
+	ThreadId   *string
+
+	FromEmail string
+	FromName  string
+	// Recipients of the inbound message as addressed by the sender, e.g. mail `To` and `Cc`.
+	To []MailAddress
+	Cc []MailAddress
+	// Envelope recipient the message is delivered to, e.g. Postmark original recipient.
+	DeliveredTo string
+	Headers     map[string]string // protocol headers in canonical form, first value wins.
+
+	Subject      string
+	TextBody     string
+	HTMLBody     string
+	MarkdownBody string
+	// Reply text as stripped by the provider if any, e.g. Postmark stripped text reply.
+	TextReply string
+	// Text as received if the quoted history, the signature or the mobile footer is stripped from the reply.
+	OriginalTextBody string
+
+	Attachments []ChannelAttachment
+
+	// Spam verdict of the inbound message as per the spam pipeline.
+	Spam SpamVerdict
+	// Blocklist verdict of the inbound sender, blocked message is dropped or quarantined.
+	Block SenderBlockVerdict
+	// Auto-reply of the sender e.g. out-of-office, see IsAutoReplyMail.
+	IsAutomated bool
+
+	CreatedAt time.Time
+}
+
+// NewChatInbound returns the in-app chat inbound message of the authenticated Customer.
+// Without the Thread ID a new Thread is started.
+func NewChatInbound(customerId string, threadId *string, messageText string) ChannelInbound {
+	return ChannelInbound{
+		Channel:      ThreadChannel{}.InAppChat(),
+		CustomerId:   &customerId,
+		ThreadId:     threadId,
+		TextBody:     messageText,
+		MarkdownBody: messageText,
+		CreatedAt:    time.Now().UTC(),
+	}
+}
+
+// Recipients returns the recipient emails of the inbound message, the envelope recipient first
+// then the forwarded recipient headers, then as addressed in `To` and `Cc`.
+func (in ChannelInbound) Recipients() []string {
+	var rcpts []string
+	if in.DeliveredTo != "" {
+		rcpts = append(rcpts, in.DeliveredTo)
+	}
+	for _, key := range []string{"X-Original-To", "X-Forwarded-To", "Delivered-To"} {
+		if v := strings.TrimSpace(in.Headers[key]); v != "" {
+			rcpts = append(rcpts, v)
+		}
+	}
+	for _, addr := range in.To {
+		rcpts = append(rcpts, addr.Email)
+	}
+	for _, addr := range in.Cc {
+		rcpts = append(rcpts, addr.Email)
+	}
+	return rcpts
+}
+
+// MessageLog returns the inbound message log for the persisted message.
+func (in ChannelInbound) MessageLog(messageId string) *ChannelMessageLog {
+	if in.ExternalId == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	return &ChannelMessageLog{
+		MessageId:   messageId,
+		Channel:     in.Channel,
+		MessageType: ChannelMessageType{}.Inbound(),
+		ExternalId:  in.ExternalId,
+		ExternalRef: in.ExternalRef,
+		ReplyRef:    in.ReplyRef,
+		Payload:     in.Payload,
+		Status:      ChannelMessageStatus{}.Received(),
+		SubmittedAt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// MessageParticipants returns the recipients of the inbound message for the persisted message.
+func (in ChannelInbound) MessageParticipants(messageId string) []MessageParticipant {
+	roles := ThreadParticipantRole{}
+	now := time.Now().UTC()
+	participants := make([]MessageParticipant, 0, len(in.To)+len(in.Cc))
+	seen := make(map[string]bool, len(in.To)+len(in.Cc))
+	add := func(addrs []MailAddress, role string) {
+		for _, a := range addrs {
+			if a.Email == "" || seen[a.Email] {
+				continue
+			}
+			seen[a.Email] = true
+			participants = append(participants, MessageParticipant{
+				MessageId: messageId,
+				Email:     a.Email,
+				Name:      a.Name,
+				Role:      role,
+				CreatedAt: now,
+			})
+		}
+	}
+	add(in.To, roles.To())
+	add(in.Cc, roles.Cc())
+	return participants
+}
+
+// ChannelReply is the Member's reply to be delivered on the Thread channel.
+// Channels with the participants e.g. email, add or remove the Thread's CC participants with the reply,
+// Bcc is only for the reply.
+type ChannelReply struct {
+	TextBody string
+	HTMLBody string
+	AddCc    []MailAddress
+	RemoveCc []string
+	Bcc      []MailAddress
+	// Member's uploads for the Thread attached to the reply.
+	AttachmentIds []string
+}
+
+// ChannelDelivery is the outbound message to be delivered by the channel adapter.
+type ChannelDelivery struct {
+	Workspace    Workspace
+	Thread       Thread
+	Customer     Customer
+	Member       Member
+	Message      Message
+	Participants []ThreadParticipant // Thread participants other than the Customer
+	Bcc          []MailAddress
+	Attachments  []MessageAttachment // stored attachments of the reply, e.g. sent as the mail attachments
+}
+
+// ChannelDeliveryStatus is the delivery status of the outbound message as reported by the channel provider.
+type ChannelDeliveryStatus struct {
+	ExternalId   string
+	Status       string
+	ErrorCode    int64
+	ErrorMessage string
+	Recipient    string // recipient address the status is for, if reported
+	// Hard bounce or spam complaint, the recipient address should not be sent to again.
+	IsRecipientBad bool
+	Payload        map[string]interface{}
+}
@@ -21,8 +21,15 @@ type Customer struct {
 	Name            string
 	IsEmailVerified bool
 	Role            string
-	UpdatedAt       time.Time
-	CreatedAt       time.Time
+	IsBlocked       bool
+	BlockedAt       sql.NullTime
+	// Email hard bounced or complained as reported by the mail sender, the address is likely bad.
+	IsEmailBounced    bool
+	EmailBouncedAt    sql.NullTime
+	EmailBounceReason string
+	Locale            string // preferred locale e.g. `en` or `pt-br`, see NormalizeLocale
+	UpdatedAt         time.Time
+	CreatedAt         time.Time
 }
 
 func (c Customer) GenId() string {
@@ -66,6 +73,58 @@ func (c Customer) AsCustomerActor() CustomerActor {
 	}
 }
 
+// Block blocks the customer from the workspace.
+func (c *Customer) Block() {
+	c.IsBlocked = true
+	c.BlockedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+}
+
+// Unblock reverses the block, BlockedAt is kept as the last blocked time.
+func (c *Customer) Unblock() {
+	c.IsBlocked = false
+}
+
+// MarkEmailBounced marks the customer's email as bounced with the reason reported by the mail sender.
+func (c *Customer) MarkEmailBounced(reason string) {
+	c.IsEmailBounced = true
+	c.EmailBouncedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	c.EmailBounceReason = reason
+}
+
+// ClearEmailBounced clears the bounce once the mail is delivered again, EmailBouncedAt is kept as the last bounced time.
+func (c *Customer) ClearEmailBounced() {
+	c.IsEmailBounced = false
+	c.EmailBounceReason = ""
+}
+
+// NormalizeLocale returns the locale as the lower case language tag e.g. `en` or `pt-br`,
+// the first of the `Accept-Language` header value is taken. Returns empty if not a valid language tag.
+func NormalizeLocale(locale string) string {
+	locale, _, _ = strings.Cut(locale, ",")
+	locale, _, _ = strings.Cut(locale, ";")
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if locale == "" || len(locale) > 35 {
+		return ""
+	}
+	for i, part := range strings.Split(locale, "-") {
+		if part == "" || len(part) > 8 || (i == 0 && (len(part) < 2 || len(part) > 3)) {
+			return ""
+		}
+		for _, r := range part {
+			if (r < 'a' || r > 'z') && (i == 0 || r < '0' || r > '9') {
+				return ""
+			}
+		}
+	}
+	return locale
+}
+
+// LocaleLanguage returns the language of the locale e.g. `pt` of `pt-br`.
+func LocaleLanguage(locale string) string {
+	language, _, _ := strings.Cut(locale, "-")
+	return language
+}
+
 // IdentityHash is a hash of the customer's identity
 // Combined these fields create a unique hash for the customer
 // (XXX): You might have to update this if you plan to add more identity fields
@@ -90,15 +149,21 @@ func (c Customer) IdentityHash() string {
 
 func (c Customer) MakeCopy() Customer {
 	return Customer{
-		WorkspaceId:     c.WorkspaceId,
-		CustomerId:      c.CustomerId,
-		ExternalId:      c.ExternalId,
-		Email:           c.Email,
-		Phone:           c.Phone,
-		Name:            c.Name,
-		IsEmailVerified: c.IsEmailVerified,
-		Role:            c.Role,
-		CreatedAt:       c.CreatedAt,
-		UpdatedAt:       c.UpdatedAt,
+		WorkspaceId:       c.WorkspaceId,
+		CustomerId:        c.CustomerId,
+		ExternalId:        c.ExternalId,
+		Email:             c.Email,
+		Phone:             c.Phone,
+		Name:              c.Name,
+		IsEmailVerified:   c.IsEmailVerified,
+		Role:              c.Role,
+		IsBlocked:         c.IsBlocked,
+		BlockedAt:         c.BlockedAt,
+		IsEmailBounced:    c.IsEmailBounced,
+		EmailBouncedAt:    c.EmailBouncedAt,
+		EmailBounceReason: c.EmailBounceReason,
+		Locale:            c.Locale,
+		CreatedAt:         c.CreatedAt,
+		UpdatedAt:         c.UpdatedAt,
 	}
 }
@@ -0,0 +1,1068 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// maxMailReferences caps the `References` header, the first reference
+// starting the mail thread is always kept as per RFC 5322.
+const maxMailReferences = 20
+
+// mailReplyTokenSigLen is the length of the reply token signature in hex.
+const mailReplyTokenSigLen = 16
+
+// mailSubjectPrefixRe matches the reply and forward subject prefixes of the common mail clients and locales,
+// e.g. `Re:`, `Fwd:`, `AW:`, `SV:` also with the counter as in `Re[2]:`.
+var mailSubjectPrefixRe = regexp.MustCompile(
+	`(?i)^\s*((re|fwd?|aw|sv|wg|vs|antw|tr|rif|odp)\s*(\[\d+\]|\(\d+\))?\s*[:：]\s*)+`)
+
+// MailSenderName represents the supported outbound mail senders.
+type MailSenderName struct{}
+
+func (s MailSenderName) Postmark() string {
+	return "postmark"
+}
+
+func (s MailSenderName) Resend() string {
+	return "resend"
+}
+
+func (s MailSenderName) SMTP() string {
+	return "smtp"
+}
+
+// Capture is the local sender for development, mails are sent to the local SMTP capture server e.g. MailHog.
+func (s MailSenderName) Capture() string {
+	return "capture"
+}
+
+func (s MailSenderName) IsValid(name string) bool {
+	switch name {
+	case s.Postmark(), s.Resend(), s.SMTP(), s.Capture():
+		return true
+	default:
+		return false
+	}
+}
+
+// SMTPSecurity represents the SMTP connection security.
+type SMTPSecurity struct{}
+
+func (s SMTPSecurity) None() string {
+	return "none"
+}
+
+// StartTLS upgrades the plain connection with the STARTTLS command, usually on port 587.
+func (s SMTPSecurity) StartTLS() string {
+	return "starttls"
+}
+
+// TLS is the implicit TLS connection, usually on port 465.
+func (s SMTPSecurity) TLS() string {
+	return "tls"
+}
+
+func (s SMTPSecurity) IsValid(security string) bool {
+	switch security {
+	case s.None(), s.StartTLS(), s.TLS():
+		return true
+	default:
+		return false
+	}
+}
+
+// SMTPAuth represents the SMTP authentication mechanism.
+type SMTPAuth struct{}
+
+func (a SMTPAuth) None() string {
+	return "none"
+}
+
+func (a SMTPAuth) Plain() string {
+	return "plain"
+}
+
+func (a SMTPAuth) Login() string {
+	return "login"
+}
+
+func (a SMTPAuth) IsValid(auth string) bool {
+	switch auth {
+	case a.None(), a.Plain(), a.Login():
+		return true
+	default:
+		return false
+	}
+}
+
+// MailSetting is the workspace outbound mail sender.
+// When disabled the workspace mail is sent from the Postmark mail server if configured,
+// otherwise with the deployment mail sender.
+type MailSetting struct {
+	WorkspaceId  string `json:"workspaceId"`
+	IsEnabled    bool   `json:"isEnabled"`
+	Sender       string `json:"sender"`
+	FromName     string `json:"fromName"`
+	FromEmail    string `json:"fromEmail"`
+	ReplyTo      string `json:"replyTo"`
+	APIKey       string `json:"-"` // Postmark server token or Resend API key
+	SMTPHost     string `json:"smtpHost"`
+	SMTPPort     int    `json:"smtpPort"`
+	SMTPUsername string `json:"smtpUsername"`
+	SMTPPassword string `json:"-"`
+	SMTPSecurity string `json:"smtpSecurity"`
+	SMTPAuth     string `json:"smtpAuth"`
+	// Reopens the Thread when the reply hard bounces or is marked as spam by the Customer.
+	ReopenOnBounce bool      `json:"reopenOnBounce"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// NewMailSetting returns the default disabled mail setting for the workspace.
+func NewMailSetting(workspaceId string) MailSetting {
+	now := time.Now().UTC()
+	return MailSetting{
+		WorkspaceId:  workspaceId,
+		IsEnabled:    false,
+		Sender:       MailSenderName{}.Capture(),
+		SMTPPort:     587,
+		SMTPSecurity: SMTPSecurity{}.StartTLS(),
+		SMTPAuth:     SMTPAuth{}.Plain(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// SMTPAddr returns the SMTP server address as host:port.
+func (s MailSetting) SMTPAddr() string {
+	return fmt.Sprintf("%s:%d", s.SMTPHost, s.SMTPPort)
+}
+
+func (s MailSetting) Validate() error {
+	if !(MailSenderName{}).IsValid(s.Sender) {
+		return errors.New("unsupported mail sender")
+	}
+	if !(SMTPSecurity{}).IsValid(s.SMTPSecurity) {
+		return errors.New("unsupported smtp security")
+	}
+	if !(SMTPAuth{}).IsValid(s.SMTPAuth) {
+		return errors.New("unsupported smtp auth")
+	}
+	if s.FromEmail != "" {
+		if _, err := mail.ParseAddress(s.FromEmail); err != nil {
+			return errors.New("invalid from email")
+		}
+	}
+	if s.ReplyTo != "" {
+		if _, err := mail.ParseAddress(s.ReplyTo); err != nil {
+			return errors.New("invalid reply to email")
+		}
+	}
+	if !s.IsEnabled {
+		return nil
+	}
+	if s.FromEmail == "" {
+		return errors.New("from email is required")
+	}
+	switch s.Sender {
+	case MailSenderName{}.Postmark(), MailSenderName{}.Resend():
+		if s.APIKey == "" {
+			return errors.New("api key is required")
+		}
+	case MailSenderName{}.SMTP():
+		if s.SMTPHost == "" || s.SMTPPort <= 0 {
+			return errors.New("smtp host and port are required")
+		}
+		if s.SMTPAuth != (SMTPAuth{}).None() && (s.SMTPUsername == "" || s.SMTPPassword == "") {
+			return errors.New("smtp username and password are required")
+		}
+	}
+	return nil
+}
+
+// MailHeader is the mail protocol header, kept in order as sent.
+type MailHeader struct {
+	Name  string
+	Value string
+}
+
+// Mail is the outbound mail as sent by any of the mail senders.
+// MessageId, InReplyTo and References are set by us, not by the sender,
+// so the mail thread is maintained the same irrespective of the sender.
+type Mail struct {
+	FromName    string
+	FromEmail   string
+	To          string   // primary recipient
+	OtherTo     []string // other `To` recipients
+	Cc          []string
+	Bcc         []string
+	ReplyTo     string
+	Subject     string
+	TextBody    string
+	HTMLBody    string
+	MessageId   string   // `Message-ID` header with the angle brackets
+	InReplyTo   string   // `In-Reply-To` header with the angle brackets
+	References  []string // `References` header, oldest first
+	Headers     []MailHeader
+	Tag         string // sender specific tag for tracking, if supported
+	ReplyToken  string // plus addressed in the `Reply-To` address, see NewMailReplyToken
+	Attachments []MailAttachment
+}
+
+// MaxMailAttachmentsSize caps the total size of the mail attachments, as limited by the mail senders.
+const MaxMailAttachmentsSize = 10 << 20
+
+// MailAttachment is the file attached to the outbound mail.
+type MailAttachment struct {
+	Name        string
+	ContentType string
+	Content     []byte
+	ContentId   string // inline attachment referenced as `cid:` in the HTML, if any
+}
+
+// AttachmentsSize returns the total size of the mail attachments.
+func (m Mail) AttachmentsSize() int {
+	var size int
+	for _, a := range m.Attachments {
+		size += len(a.Content)
+	}
+	return size
+}
+
+// From returns the formatted `From` address.
+func (m Mail) From() string {
+	addr := mail.Address{Name: m.FromName, Address: m.FromEmail}
+	return addr.String()
+}
+
+// ToAddrs returns the primary recipient followed by the other `To` recipients.
+func (m Mail) ToAddrs() []string {
+	return append([]string{m.To}, m.OtherTo...)
+}
+
+// Recipients returns all the recipients of the mail including `Cc` and `Bcc`, as for the SMTP envelope.
+func (m Mail) Recipients() []string {
+	recipients := m.ToAddrs()
+	recipients = append(recipients, m.Cc...)
+	return append(recipients, m.Bcc...)
+}
+
+// SetThreadRefs sets the mail `In-Reply-To` the most recent reference of the mail thread,
+// refs are the `Message-ID` of the mails in the thread oldest first.
+func (m *Mail) SetThreadRefs(refs []string) {
+	seen := make(map[string]bool, len(refs))
+	unique := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if ref == "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		unique = append(unique, ref)
+	}
+	refs = unique
+	if len(refs) == 0 {
+		return
+	}
+	m.InReplyTo = refs[len(refs)-1]
+	if len(refs) > maxMailReferences {
+		trimmed := make([]string, 0, maxMailReferences)
+		trimmed = append(trimmed, refs[0])
+		refs = append(trimmed, refs[len(refs)-maxMailReferences+1:]...)
+	}
+	m.References = refs
+}
+
+// ThreadHeaders returns the mail threading headers followed by the custom headers.
+// Senders must send these headers as is.
+func (m Mail) ThreadHeaders() []MailHeader {
+	headers := make([]MailHeader, 0, len(m.Headers)+3)
+	if m.MessageId != "" {
+		headers = append(headers, MailHeader{Name: "Message-ID", Value: m.MessageId})
+	}
+	if m.InReplyTo != "" {
+		headers = append(headers, MailHeader{Name: "In-Reply-To", Value: m.InReplyTo})
+	}
+	if len(m.References) > 0 {
+		headers = append(headers, MailHeader{Name: "References", Value: strings.Join(m.References, " ")})
+	}
+	return append(headers, m.Headers...)
+}
+
+// NewMailMessageId returns the unique mail `Message-ID` at the domain of the from email.
+func NewMailMessageId(fromEmail string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(fromEmail, "@"); at != -1 && at < len(fromEmail)-1 {
+		domain = strings.ToLower(fromEmail[at+1:])
+	}
+	return fmt.Sprintf("<%s@%s>", xid.New().String(), domain)
+}
+
+// NormalizeMailSubject returns the mail subject without the reply and forward prefixes,
+// in lower case with the whitespace collapsed. Used to match the reply to the Thread by the subject.
+func NormalizeMailSubject(subject string) string {
+	subject = mailSubjectPrefixRe.ReplaceAllString(subject, "")
+	return strings.ToLower(strings.Join(strings.Fields(subject), " "))
+}
+
+// IsMailReplySubject reports whether the mail subject has the reply or forward prefix.
+func IsMailReplySubject(subject string) bool {
+	return mailSubjectPrefixRe.MatchString(subject)
+}
+
+// NewMailReplyToken returns the reply token of the Thread signed with the workspace secret,
+// plus addressed in the reply mail `Reply-To` so the reply is matched even without the mail headers.
+func NewMailReplyToken(threadId string, secret string) string {
+	return threadId + "-" + mailReplyTokenSig(threadId, secret)
+}
+
+// VerifyMailReplyToken returns the Thread ID of the reply token signed with the workspace secret.
+func VerifyMailReplyToken(token string, secret string) (string, bool) {
+	threadId, sig, ok := strings.Cut(strings.ToLower(token), "-")
+	if !ok || threadId == "" || len(sig) != mailReplyTokenSigLen {
+		return "", false
+	}
+	if !hmac.Equal([]byte(sig), []byte(mailReplyTokenSig(threadId, secret))) {
+		return "", false
+	}
+	return threadId, true
+}
+
+func mailReplyTokenSig(threadId string, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte("mail-reply:" + threadId))
+	return hex.EncodeToString(h.Sum(nil))[:mailReplyTokenSigLen]
+}
+
+// autoReplySubjectPrefixes are the subject prefixes of the common vacation and out-of-office replies, lower case.
+var autoReplySubjectPrefixes = []string{
+	"auto:",
+	"autoreply:",
+	"auto-reply:",
+	"auto reply:",
+	"automatic reply:",
+	"auto response:",
+	"out of office:",
+	"out of the office:",
+	"out of office reply:",
+	"vacation reply:",
+	"on vacation:",
+	"away from the office:",
+	"abwesenheitsnotiz:",
+	"réponse automatique:",
+	"respuesta automática:",
+	"risposta automatica:",
+}
+
+// IsAutoReplyMail checks if the mail is sent by the auto-responder, e.g. the out-of-office reply, as per
+// the `Auto-Submitted` (RFC 3834), `X-Autoreply`, `X-Autorespond` and `Precedence` headers,
+// otherwise as per the common vacation subjects. Headers are in canonical form.
+func IsAutoReplyMail(headers map[string]string, subject string) bool {
+	if v, ok := headers["Auto-Submitted"]; ok {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" && v != "no" {
+			return true
+		}
+	}
+	for _, key := range []string{"X-Autoreply", "X-Autorespond"} {
+		if v, ok := headers[key]; ok && !strings.EqualFold(strings.TrimSpace(v), "no") {
+			return true
+		}
+	}
+	if v, ok := headers["Precedence"]; ok {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "auto_reply", "bulk", "junk":
+			return true
+		}
+	}
+
+	subject = strings.ToLower(strings.TrimSpace(subject))
+	for _, prefix := range autoReplySubjectPrefixes {
+		if strings.HasPrefix(subject, prefix) {
+			return true
+		}
+	}
+	return strings.HasPrefix(subject, "out of office") || strings.Contains(subject, "(out of office)")
+}
+
+// PlusAddress returns the email with the tag plus addressed in the local part, e.g. `support+tag@example.com`.
+// The existing plus address tag is replaced.
+func PlusAddress(email string, tag string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || tag == "" {
+		return email
+	}
+	local, _, _ := strings.Cut(email[:at], "+")
+	return local + "+" + tag + email[at:]
+}
+
+// StripPlusAddress returns the email without the plus address tag of the local part.
+func StripPlusAddress(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email
+	}
+	local, _, _ := strings.Cut(email[:at], "+")
+	return local + email[at:]
+}
+
+// MailSendResult is the result of the mail sent by the sender.
+type MailSendResult struct {
+	Sender            string
+	ProviderMessageId string // sender's message ID, the `Message-ID` for SMTP
+	MessageId         string // `Message-ID` header of the sent mail
+	SubmittedAt       time.Time
+	Payload           map[string]interface{}
+}
+
+// MailInboundSource represents the source the inbound mail is received from.
+type MailInboundSource struct{}
+
+func (s MailInboundSource) Postmark() string {
+	return "postmark"
+}
+
+func (s MailInboundSource) IMAP() string {
+	return "imap"
+}
+
+func (s MailInboundSource) SMTP() string {
+	return "smtp"
+}
+
+// Upload is the raw mail uploaded with the API, e.g. piped from the MTA.
+func (s MailInboundSource) Upload() string {
+	return "upload"
+}
+
+// IMAPSetting is the workspace IMAP mailbox polled for the inbound mail.
+// Mail is fetched after the last fetched UID, the mailbox UIDVALIDITY change starts again
+// from the mail received after.
+type IMAPSetting struct {
+	WorkspaceId  string     `json:"workspaceId"`
+	IsEnabled    bool       `json:"isEnabled"`
+	Host         string     `json:"host"`
+	Port         int        `json:"port"`
+	Username     string     `json:"username"`
+	Password     string     `json:"-"`
+	Security     string     `json:"security"` // none, starttls or tls as for SMTPSecurity
+	Mailbox      string     `json:"mailbox"`
+	UIDValidity  int64      `json:"uidValidity"`
+	LastUID      int64      `json:"lastUid"`
+	LastPolledAt *time.Time `json:"lastPolledAt"`
+	PollError    *string    `json:"pollError"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// NewIMAPSetting returns the default disabled IMAP setting for the workspace.
+func NewIMAPSetting(workspaceId string) IMAPSetting {
+	now := time.Now().UTC()
+	return IMAPSetting{
+		WorkspaceId: workspaceId,
+		IsEnabled:   false,
+		Port:        993,
+		Security:    SMTPSecurity{}.TLS(),
+		Mailbox:     "INBOX",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Addr returns the IMAP server address as host:port.
+func (s IMAPSetting) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+func (s IMAPSetting) Validate() error {
+	if !(SMTPSecurity{}).IsValid(s.Security) {
+		return errors.New("unsupported imap security")
+	}
+	for _, v := range []string{s.Host, s.Username, s.Password, s.Mailbox} {
+		if strings.ContainsAny(v, "\r\n") {
+			return errors.New("imap setting must not contain line breaks")
+		}
+	}
+	if !s.IsEnabled {
+		return nil
+	}
+	if s.Host == "" || s.Port <= 0 {
+		return errors.New("imap host and port are required")
+	}
+	if s.Username == "" || s.Password == "" {
+		return errors.New("imap username and password are required")
+	}
+	if s.Mailbox == "" {
+		return errors.New("imap mailbox is required")
+	}
+	return nil
+}
+
+// IMAPMessage is the raw mail fetched from the IMAP mailbox.
+type IMAPMessage struct {
+	UID int64
+	Raw []byte
+}
+
+// IMAPFetchResult is the mail fetched from the IMAP mailbox with the mailbox UID state.
+type IMAPFetchResult struct {
+	UIDValidity int64
+	UIDNext     int64
+	Messages    []IMAPMessage
+}
+
+// MailAddress is the mail address with the display name.
+type MailAddress struct {
+	Name  string
+	Email string
+}
+
+// String returns the formatted address as in the mail headers.
+func (a MailAddress) String() string {
+	addr := mail.Address{Name: a.Name, Address: a.Email}
+	return addr.String()
+}
+
+// ParseMailAddress parses the address as `Name <email>` or the plain email, the email is lower cased.
+func ParseMailAddress(address string) (MailAddress, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(address))
+	if err != nil {
+		return MailAddress{}, err
+	}
+	return MailAddress{Name: addr.Name, Email: strings.ToLower(addr.Address)}, nil
+}
+
+// maxMailSignatureLen caps the signature HTML and text.
+const maxMailSignatureLen = 10000
+
+// maxMailReplyBrandingLen caps the reply wrapper header and footer.
+const maxMailReplyBrandingLen = 1000
+
+// MailSignature is the Member's signature appended to the mail replies.
+// Either of the HTML or text is enough, the other variant is derived when the reply is rendered.
+type MailSignature struct {
+	WorkspaceId string    `json:"workspaceId"`
+	MemberId    string    `json:"memberId"`
+	HTMLBody    string    `json:"htmlBody"`
+	TextBody    string    `json:"textBody"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// NewMailSignature returns the empty signature of the Member.
+func NewMailSignature(workspaceId string, memberId string) MailSignature {
+	now := time.Now().UTC()
+	return MailSignature{
+		WorkspaceId: workspaceId,
+		MemberId:    memberId,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func (s MailSignature) IsEmpty() bool {
+	return strings.TrimSpace(s.HTMLBody) == "" && strings.TrimSpace(s.TextBody) == ""
+}
+
+func (s MailSignature) Validate() error {
+	if len(s.HTMLBody) > maxMailSignatureLen || len(s.TextBody) > maxMailSignatureLen {
+		return fmt.Errorf("signature must not exceed %d characters", maxMailSignatureLen)
+	}
+	return nil
+}
+
+// MailReplySetting is the workspace reply wrapper of the mail replies.
+// When branded, the reply is wrapped with the workspace logo, header and footer.
+// The signature here is the workspace default for the Members without their own signature.
+type MailReplySetting struct {
+	WorkspaceId   string    `json:"workspaceId"`
+	IsBranded     bool      `json:"isBranded"`
+	LogoUrl       string    `json:"logoUrl"`
+	Header        string    `json:"header"`
+	Footer        string    `json:"footer"`
+	IncludeQuote  bool      `json:"includeQuote"` // quotes the previous message of the Thread
+	SignatureHTML string    `json:"signatureHtml"`
+	SignatureText string    `json:"signatureText"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// NewMailReplySetting returns the default reply setting for the workspace, unbranded with the quoted history.
+func NewMailReplySetting(workspaceId string) MailReplySetting {
+	now := time.Now().UTC()
+	return MailReplySetting{
+		WorkspaceId:  workspaceId,
+		IsBranded:    false,
+		IncludeQuote: true,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// DefaultSignature returns the workspace default signature.
+func (s MailReplySetting) DefaultSignature() MailSignature {
+	return MailSignature{
+		WorkspaceId: s.WorkspaceId,
+		HTMLBody:    s.SignatureHTML,
+		TextBody:    s.SignatureText,
+		CreatedAt:   s.CreatedAt,
+		UpdatedAt:   s.UpdatedAt,
+	}
+}
+
+func (s MailReplySetting) Validate() error {
+	if s.LogoUrl != "" {
+		u, err := url.Parse(s.LogoUrl)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.New("invalid logo url")
+		}
+	}
+	if len(s.Header) > maxMailReplyBrandingLen || len(s.Footer) > maxMailReplyBrandingLen {
+		return fmt.Errorf("header and footer must not exceed %d characters", maxMailReplyBrandingLen)
+	}
+	return s.DefaultSignature().Validate()
+}
+
+// CustomerMailSetting is the transactional mail of the Thread sent to the Customer with the workspace mail templates.
+// The CSAT survey and the transcript are sent when the Thread is resolved, the notification when the Member
+// replies on the chat Thread to the Customer with the verified email. Each is disabled until enabled.
+type CustomerMailSetting struct {
+	WorkspaceId      string    `json:"workspaceId"`
+	SendCSAT         bool      `json:"sendCsat"`
+	CSATUrl          string    `json:"csatUrl"` // workspace survey, linked with the Thread ID as `threadId`
+	SendTranscript   bool      `json:"sendTranscript"`
+	SendNotification bool      `json:"sendNotification"`
+	ConversationUrl  string    `json:"conversationUrl"` // page of the chat widget, linked in the notification
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// NewCustomerMailSetting returns the default customer mail setting for the workspace, nothing is sent.
+func NewCustomerMailSetting(workspaceId string) CustomerMailSetting {
+	now := time.Now().UTC()
+	return CustomerMailSetting{
+		WorkspaceId: workspaceId,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func (s CustomerMailSetting) Validate() error {
+	if s.SendCSAT && s.CSATUrl == "" {
+		return errors.New("csat url is required to send the csat survey")
+	}
+	if s.SendNotification && s.ConversationUrl == "" {
+		return errors.New("conversation url is required to send the notification")
+	}
+	for _, link := range []string{s.CSATUrl, s.ConversationUrl} {
+		if link == "" {
+			continue
+		}
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return errors.New("invalid url")
+		}
+	}
+	return nil
+}
+
+// ThreadLink returns the link with the Thread ID added as `threadId`, e.g. of the CSAT survey.
+func (s CustomerMailSetting) ThreadLink(link string, threadId string) string {
+	if link == "" {
+		return ""
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	q := u.Query()
+	q.Set("threadId", threadId)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Mailbox is the workspace support address e.g. support@, billing@ or security@ of the workspace domain.
+// Inbound mail is routed to the Mailbox by the recipient address, the plus address tag is ignored.
+// Replies of the Thread go out from the Mailbox the Customer wrote to, with the Mailbox signature
+// for the Members without their own signature.
+type Mailbox struct {
+	MailboxId                string     `json:"mailboxId"`
+	WorkspaceId              string     `json:"workspaceId"`
+	Name                     string     `json:"name"`
+	Email                    string     `json:"email"`
+	Domain                   string     `json:"domain"`
+	IsDefault                bool       `json:"isDefault"` // routed the inbound mail not addressed to any Mailbox
+	SignatureHTML            string     `json:"signatureHtml"`
+	SignatureText            string     `json:"signatureText"`
+	DefaultLabelId           *string    `json:"defaultLabelId"`
+	DefaultAssigneeId        *string    `json:"defaultAssigneeId"`
+	HasDNS                   bool       `json:"hasDNS"`
+	IsDNSVerified            bool       `json:"isDNSVerified"`
+	DNSVerifiedAt            *time.Time `json:"dnsVerifiedAt"`
+	DNSDomainId              *int64     `json:"dnsDomainId"`
+	DKIMHost                 *string    `json:"dkimHost"`
+	DKIMTextValue            *string    `json:"dkimTextValue"`
+	DKIMUpdateStatus         *string    `json:"dkimUpdateStatus"`
+	ReturnPathDomain         *string    `json:"returnPathDomain"`
+	ReturnPathDomainCNAME    *string    `json:"returnPathDomainCNAME"`
+	ReturnPathDomainVerified bool       `json:"returnPathDomainVerified"`
+	CreatedAt                time.Time  `json:"createdAt"`
+	UpdatedAt                time.Time  `json:"updatedAt"`
+}
+
+func (mb Mailbox) GenId() string {
+	return "mbx" + xid.New().String()
+}
+
+// NewMailbox returns the Mailbox of the workspace for the address, the email is lower cased.
+func NewMailbox(workspaceId string, name string, email string) Mailbox {
+	now := time.Now().UTC()
+	email = strings.ToLower(strings.TrimSpace(email))
+	var domain string
+	if at := strings.LastIndex(email, "@"); at > 0 {
+		domain = email[at+1:]
+	}
+	return Mailbox{
+		MailboxId:   Mailbox{}.GenId(),
+		WorkspaceId: workspaceId,
+		Name:        name,
+		Email:       email,
+		Domain:      domain,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Address returns the Mailbox address with the Mailbox name.
+func (mb Mailbox) Address() MailAddress {
+	return MailAddress{Name: mb.Name, Email: mb.Email}
+}
+
+// Signature returns the Mailbox signature.
+func (mb Mailbox) Signature() MailSignature {
+	return MailSignature{
+		WorkspaceId: mb.WorkspaceId,
+		HTMLBody:    mb.SignatureHTML,
+		TextBody:    mb.SignatureText,
+		CreatedAt:   mb.CreatedAt,
+		UpdatedAt:   mb.UpdatedAt,
+	}
+}
+
+// Matches checks the email is addressed to the Mailbox, with or without the plus address tag.
+func (mb Mailbox) Matches(email string) bool {
+	return strings.EqualFold(StripPlusAddress(strings.TrimSpace(email)), mb.Email)
+}
+
+// DNSHasVerified checks the DKIM and the return path domain of the Mailbox domain are verified.
+func (mb Mailbox) DNSHasVerified() bool {
+	if mb.DKIMUpdateStatus != nil {
+		return *mb.DKIMUpdateStatus == DKIMUpdateStatusVerified && mb.ReturnPathDomainVerified
+	}
+	return false
+}
+
+func (mb Mailbox) Validate() error {
+	if strings.TrimSpace(mb.Name) == "" {
+		return errors.New("mailbox name is required")
+	}
+	addr, err := ParseMailAddress(mb.Email)
+	if err != nil || addr.Email != mb.Email || addr.Name != "" {
+		return errors.New("invalid mailbox email")
+	}
+	if strings.Contains(mb.Email[:strings.LastIndex(mb.Email, "@")], "+") {
+		return errors.New("mailbox email must not be plus addressed")
+	}
+	return mb.Signature().Validate()
+}
+
+// AddressedMailboxes returns the Mailboxes the inbound mail is addressed to, in order of the recipients.
+func AddressedMailboxes(mailboxes []Mailbox, recipients []string) []Mailbox {
+	addressed := make([]Mailbox, 0, 1)
+	for _, rcpt := range recipients {
+		for _, mb := range mailboxes {
+			if mb.Matches(rcpt) {
+				addressed = append(addressed, mb)
+			}
+		}
+	}
+	return addressed
+}
+
+// RouteMailbox returns the Mailbox the inbound mail is addressed to, matched in order of the recipients,
+// otherwise the default Mailbox. Returns false if none of the Mailboxes match and there is no default.
+func RouteMailbox(mailboxes []Mailbox, recipients []string) (Mailbox, bool) {
+	if addressed := AddressedMailboxes(mailboxes, recipients); len(addressed) > 0 {
+		return addressed[0], true
+	}
+	for _, mb := range mailboxes {
+		if mb.IsDefault {
+			return mb, true
+		}
+	}
+	return Mailbox{}, false
+}
+
+// InboundRequestStatus represents the processing state of the inbound webhook request.
+type InboundRequestStatus struct{}
+
+func (s InboundRequestStatus) Received() string {
+	return "received"
+}
+
+func (s InboundRequestStatus) Processed() string {
+	return "processed"
+}
+
+func (s InboundRequestStatus) Failed() string {
+	return "failed"
+}
+
+// Skipped is the request of the inbound message already processed, e.g. the provider retried.
+func (s InboundRequestStatus) Skipped() string {
+	return "skipped"
+}
+
+func (s InboundRequestStatus) IsValid(status string) bool {
+	switch status {
+	case s.Received(), s.Processed(), s.Failed(), s.Skipped():
+		return true
+	}
+	return false
+}
+
+// InboundRequestMaxAttempts is the max processing attempts of the failed inbound request retried automatically,
+// then the request is only replayed.
+const InboundRequestMaxAttempts = 8
+
+// InboundRequestBackoff returns the wait before the next retry after the failed attempts,
+// doubling from a minute up to 6 hours.
+func InboundRequestBackoff(attempts int) time.Duration {
+	backoff := time.Minute
+	for i := 1; i < attempts && backoff < 6*time.Hour; i++ {
+		backoff *= 2
+	}
+	return min(backoff, 6*time.Hour)
+}
+
+// PostmarkInboundRequest is the Postmark inbound webhook request of the workspace as received,
+// tracked through processing for the retry and the replay.
+type PostmarkInboundRequest struct {
+	MessageId     string                 `json:"messageId"`
+	WorkspaceId   string                 `json:"workspaceId"`
+	Payload       map[string]interface{} `json:"-"`
+	Status        string                 `json:"status"`
+	Error         *string                `json:"error"`
+	Attempts      int                    `json:"attempts"`
+	NextAttemptAt *time.Time             `json:"nextAttemptAt"`
+	ThreadId      *string                `json:"threadId"`
+	ProcessedAt   *time.Time             `json:"processedAt"`
+	CreatedAt     time.Time              `json:"createdAt"`
+	UpdatedAt     time.Time              `json:"updatedAt"`
+}
+
+// NewPostmarkInboundRequest returns the received inbound request of the workspace.
+func NewPostmarkInboundRequest(
+	workspaceId string, messageId string, payload map[string]interface{}) PostmarkInboundRequest {
+	now := time.Now().UTC()
+	return PostmarkInboundRequest{
+		MessageId:   messageId,
+		WorkspaceId: workspaceId,
+		Payload:     payload,
+		Status:      InboundRequestStatus{}.Received(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// IsDone checks the request is processed or skipped, not processed again.
+func (r PostmarkInboundRequest) IsDone() bool {
+	return r.Status == InboundRequestStatus{}.Processed() || r.Status == InboundRequestStatus{}.Skipped()
+}
+
+// MarkProcessed marks the request processed into the Thread, dropped mail has no Thread.
+func (r *PostmarkInboundRequest) MarkProcessed(threadId *string, now time.Time) {
+	r.Attempts++
+	r.Status = InboundRequestStatus{}.Processed()
+	r.Error = nil
+	r.NextAttemptAt = nil
+	r.ThreadId = threadId
+	r.ProcessedAt = &now
+}
+
+// MarkSkipped marks the request skipped as the inbound message is already processed.
+func (r *PostmarkInboundRequest) MarkSkipped(now time.Time) {
+	r.Attempts++
+	r.Status = InboundRequestStatus{}.Skipped()
+	r.Error = nil
+	r.NextAttemptAt = nil
+	r.ProcessedAt = &now
+}
+
+// MarkFailed marks the request failed with the error, the next retry is scheduled with the backoff
+// until the max attempts.
+func (r *PostmarkInboundRequest) MarkFailed(err error, now time.Time) {
+	r.Attempts++
+	r.Status = InboundRequestStatus{}.Failed()
+	errMsg := err.Error()
+	r.Error = &errMsg
+	r.NextAttemptAt = nil
+	if r.Attempts < InboundRequestMaxAttempts {
+		next := now.Add(InboundRequestBackoff(r.Attempts))
+		r.NextAttemptAt = &next
+	}
+}
+
+// OutboundMailStatus represents the delivery state of the Member's queued mail reply.
+type OutboundMailStatus struct{}
+
+// Queued is the mail pending delivery, including the failed attempts to be retried.
+func (s OutboundMailStatus) Queued() string {
+	return "queued"
+}
+
+func (s OutboundMailStatus) Sent() string {
+	return "sent"
+}
+
+// Failed is the mail not delivered after the max attempts or with the error not retried,
+// delivered only when retried by the Member.
+func (s OutboundMailStatus) Failed() string {
+	return "failed"
+}
+
+func (s OutboundMailStatus) IsValid(status string) bool {
+	switch status {
+	case s.Queued(), s.Sent(), s.Failed():
+		return true
+	}
+	return false
+}
+
+// OutboundMailMaxAttempts is the max delivery attempts of the queued mail, then the mail is failed.
+const OutboundMailMaxAttempts = 6
+
+// Outbound mail delivered per workspace within the window is limited, mails over the limit
+// are kept queued for the next window.
+const (
+	OutboundMailRateLimit  = 60
+	OutboundMailRateWindow = time.Minute
+)
+
+// OutboundMailBackoff returns the wait before the next delivery attempt after the failed attempts,
+// doubling from 30 seconds up to 30 minutes.
+func OutboundMailBackoff(attempts int) time.Duration {
+	backoff := 30 * time.Second
+	for i := 1; i < attempts && backoff < 30*time.Minute; i++ {
+		backoff *= 2
+	}
+	return min(backoff, 30*time.Minute)
+}
+
+// OutboundMail is the Member's mail reply queued for delivery. The reply message is persisted
+// before the delivery, the mail is delivered in the background with the retries.
+type OutboundMail struct {
+	MessageId   string `json:"messageId"`
+	WorkspaceId string `json:"workspaceId"`
+	ThreadId    string `json:"threadId"`
+	MemberId    string `json:"memberId"`
+	CustomerId  string `json:"customerId"`
+	// Thread participants the mail is sent to other than the Customer, as of the reply.
+	Participants  []ThreadParticipant `json:"-"`
+	Bcc           []MailAddress       `json:"-"`
+	Status        string              `json:"status"`
+	Error         *string             `json:"error"`
+	Attempts      int                 `json:"attempts"`
+	NextAttemptAt *time.Time          `json:"nextAttemptAt"`
+	SentAt        *time.Time          `json:"sentAt"`
+	CreatedAt     time.Time           `json:"createdAt"`
+	UpdatedAt     time.Time           `json:"updatedAt"`
+}
+
+// NewOutboundMail returns the mail of the delivery queued to be delivered right away.
+func NewOutboundMail(delivery ChannelDelivery) OutboundMail {
+	now := time.Now().UTC()
+	participants := make([]ThreadParticipant, 0, len(delivery.Participants))
+	participants = append(participants, delivery.Participants...)
+	bcc := make([]MailAddress, 0, len(delivery.Bcc))
+	bcc = append(bcc, delivery.Bcc...)
+	return OutboundMail{
+		MessageId:     delivery.Message.MessageId,
+		WorkspaceId:   delivery.Workspace.WorkspaceId,
+		ThreadId:      delivery.Thread.ThreadId,
+		MemberId:      delivery.Member.MemberId,
+		CustomerId:    delivery.Customer.CustomerId,
+		Participants:  participants,
+		Bcc:           bcc,
+		Status:        OutboundMailStatus{}.Queued(),
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// MessageDelivery returns the delivery status of the reply message until delivered,
+// then the status is tracked with the provider.
+func (m OutboundMail) MessageDelivery() *MessageDelivery {
+	delivery := &MessageDelivery{
+		Status:    m.Status,
+		HasError:  m.Error != nil,
+		UpdatedAt: m.UpdatedAt,
+	}
+	if m.Error != nil {
+		delivery.ErrorMessage = *m.Error
+	}
+	return delivery
+}
+
+// MarkSent marks the mail delivered to the provider.
+func (m *OutboundMail) MarkSent(now time.Time) {
+	m.Attempts++
+	m.Status = OutboundMailStatus{}.Sent()
+	m.Error = nil
+	m.NextAttemptAt = nil
+	m.SentAt = &now
+}
+
+// MarkFailed marks the failed delivery attempt with the error. The mail is kept queued for the next attempt
+// with the backoff, failed after the max attempts or if the error is not retried.
+func (m *OutboundMail) MarkFailed(err error, retry bool, now time.Time) {
+	m.Attempts++
+	errMsg := err.Error()
+	m.Error = &errMsg
+	if retry && m.Attempts < OutboundMailMaxAttempts {
+		next := now.Add(OutboundMailBackoff(m.Attempts))
+		m.Status = OutboundMailStatus{}.Queued()
+		m.NextAttemptAt = &next
+		return
+	}
+	m.Status = OutboundMailStatus{}.Failed()
+	m.NextAttemptAt = nil
+}
+
+// Defer keeps the mail queued until the time without the attempt, as the workspace is rate limited.
+func (m *OutboundMail) Defer(until time.Time) {
+	m.NextAttemptAt = &until
+}
+
+// CanRetry checks if the mail can be retried by the Member. Only the failed mail is retried,
+// the queued mail is already retried with the backoff and may be in delivery.
+func (m OutboundMail) CanRetry() bool {
+	return m.Status == OutboundMailStatus{}.Failed()
+}
+
+// Retry queues the mail to be delivered right away, with the attempts reset.
+func (m *OutboundMail) Retry(now time.Time) {
+	m.Status = OutboundMailStatus{}.Queued()
+	m.Attempts = 0
+	m.NextAttemptAt = &now
+}
@@ -0,0 +1,373 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// MailTemplateKind represents the kind of the transactional mail sent to the Customer.
+type MailTemplateKind struct{}
+
+// Verification is the mail verification of the Customer's claimed email.
+func (k MailTemplateKind) Verification() string {
+	return "verification"
+}
+
+// CSAT is the satisfaction survey of the resolved Thread.
+func (k MailTemplateKind) CSAT() string {
+	return "csat"
+}
+
+// Transcript is the transcript of the Thread sent to the Customer.
+func (k MailTemplateKind) Transcript() string {
+	return "transcript"
+}
+
+// FollowUp is the follow-up of the Thread waiting on the Customer.
+func (k MailTemplateKind) FollowUp() string {
+	return "follow_up"
+}
+
+// Notification is the notification of the new message on the Thread.
+func (k MailTemplateKind) Notification() string {
+	return "notification"
+}
+
+func (k MailTemplateKind) Kinds() []string {
+	return []string{k.Verification(), k.CSAT(), k.Transcript(), k.FollowUp(), k.Notification()}
+}
+
+func (k MailTemplateKind) IsValid(kind string) bool {
+	return slices.Contains(k.Kinds(), kind)
+}
+
+// DefaultMailLocale is the locale of the transactional mail when there is no template in the Customer's locale.
+const DefaultMailLocale = "en"
+
+const (
+	maxMailTemplateSubjectLen = 998 // line length limit of RFC 5322
+	maxMailTemplateBodyLen    = 100_000
+)
+
+// MailTemplate is the transactional mail template of the kind in the locale.
+// Subject, HTML and text are Go templates executed with MailTemplateData, either of the HTML or text is enough,
+// the other variant is derived when the mail is rendered.
+// Workspace template overrides the built-in default template of the same kind and locale.
+type MailTemplate struct {
+	WorkspaceId string    `json:"workspaceId"`
+	Kind        string    `json:"kind"`
+	Locale      string    `json:"locale"`
+	Subject     string    `json:"subject"`
+	HTMLBody    string    `json:"htmlBody"`
+	TextBody    string    `json:"textBody"`
+	IsDefault   bool      `json:"isDefault"` // built-in default, not overridden by the workspace
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// NewMailTemplate returns the empty workspace template of the kind in the locale.
+func NewMailTemplate(workspaceId string, kind string, locale string) MailTemplate {
+	now := time.Now().UTC()
+	return MailTemplate{
+		WorkspaceId: workspaceId,
+		Kind:        kind,
+		Locale:      locale,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// MailTemplateData is the data available to the transactional mail templates, e.g. `{{.CustomerName}}`.
+// Not every kind has every attribute, e.g. the Transcript is only for the transcript mail.
+type MailTemplateData struct {
+	WorkspaceName string
+	CustomerName  string
+	CustomerEmail string
+	ThreadTitle   string
+	PreviewText   string // message the mail is about, e.g. the Customer's chat message
+	Link          string // action link, e.g. the verification or the survey link
+	Transcript    string // Thread transcript as text
+}
+
+// SampleMailTemplateData returns the sample data the templates are validated and previewed with.
+func SampleMailTemplateData(workspaceName string) MailTemplateData {
+	return MailTemplateData{
+		WorkspaceName: workspaceName,
+		CustomerName:  "Jane Doe",
+		CustomerEmail: "jane@example.com",
+		ThreadTitle:   "Unable to sign in",
+		PreviewText:   "Hi, I am unable to sign in to my account.",
+		Link:          "https://example.com",
+		Transcript:    "Jane Doe: Hi, I am unable to sign in to my account.\nSupport: Sorry about that, let me check.",
+	}
+}
+
+// ThreadTranscript returns the transcript of the Thread messages as text, each message with the author's name.
+// Internal messages are never in the transcript.
+func ThreadTranscript(messages []Message) string {
+	lines := make([]string, 0, len(messages))
+	for _, message := range messages {
+		if message.IsInternal() {
+			continue
+		}
+		text := message.TextBody
+		if text == "" {
+			text = message.MarkdownBody
+		}
+		var author string
+		if message.Customer != nil {
+			author = message.Customer.Name
+		} else if message.Member != nil {
+			author = message.Member.Name
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", author, strings.TrimSpace(text)))
+	}
+	return strings.Join(lines, "\n\n")
+}
+
+// Validate checks the kind, locale and the lengths, then that the template renders with the sample data.
+func (t MailTemplate) Validate() error {
+	if !(MailTemplateKind{}).IsValid(t.Kind) {
+		return errors.New("invalid mail template kind")
+	}
+	if t.Locale == "" || NormalizeLocale(t.Locale) != t.Locale {
+		return errors.New("invalid mail template locale")
+	}
+	if strings.TrimSpace(t.Subject) == "" {
+		return errors.New("mail template subject is required")
+	}
+	if strings.TrimSpace(t.HTMLBody) == "" && strings.TrimSpace(t.TextBody) == "" {
+		return errors.New("mail template html or text body is required")
+	}
+	if len(t.Subject) > maxMailTemplateSubjectLen {
+		return fmt.Errorf("mail template subject must not exceed %d characters", maxMailTemplateSubjectLen)
+	}
+	if len(t.HTMLBody) > maxMailTemplateBodyLen || len(t.TextBody) > maxMailTemplateBodyLen {
+		return fmt.Errorf("mail template body must not exceed %d characters", maxMailTemplateBodyLen)
+	}
+	if _, _, _, err := t.Render(SampleMailTemplateData("Acme")); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Render executes the template with the data, returns the subject, HTML and text.
+// HTML is escaped as per the context, the subject is kept to a single line.
+func (t MailTemplate) Render(data MailTemplateData) (subject string, htmlBody string, textBody string, err error) {
+	subject, err = executeTextTemplate("subject", t.Subject, data)
+	if err != nil {
+		return "", "", "", err
+	}
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	if t.HTMLBody != "" {
+		tmpl, err := template.New("html").Parse(t.HTMLBody)
+		if err != nil {
+			return "", "", "", err
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			return "", "", "", err
+		}
+		htmlBody = out.String()
+	}
+	if t.TextBody != "" {
+		textBody, err = executeTextTemplate("text", t.TextBody, data)
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+	return subject, htmlBody, textBody, nil
+}
+
+func executeTextTemplate(name string, text string, data MailTemplateData) (string, error) {
+	tmpl, err := texttemplate.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// DefaultMailTemplate returns the built-in default template of the kind in the locale,
+// false if there is no built-in template in the locale.
+func DefaultMailTemplate(workspaceId string, kind string, locale string) (MailTemplate, bool) {
+	content, ok := defaultMailTemplates[kind][locale]
+	if !ok {
+		return MailTemplate{}, false
+	}
+	tmpl := NewMailTemplate(workspaceId, kind, locale)
+	tmpl.Subject = content.Subject
+	tmpl.HTMLBody = content.HTMLBody
+	tmpl.TextBody = content.TextBody
+	tmpl.IsDefault = true
+	return tmpl, true
+}
+
+// DefaultMailLocales returns the locales of the built-in default templates of the kind, sorted.
+func DefaultMailLocales(kind string) []string {
+	locales := make([]string, 0, len(defaultMailTemplates[kind]))
+	for locale := range defaultMailTemplates[kind] {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+type mailTemplateContent struct {
+	Subject  string
+	HTMLBody string
+	TextBody string
+}
+
+// defaultMailTemplates are the built-in templates by kind and locale.
+// Templates without the HTML are rendered from the text.
+var defaultMailTemplates = map[string]map[string]mailTemplateContent{
+	MailTemplateKind{}.Verification(): {
+		"en": {
+			Subject: "Verify your email for {{.WorkspaceName}}",
+			HTMLBody: `<p>Hi {{.CustomerName}},</p><p>You started a conversation with {{.WorkspaceName}}.</p>` +
+				`<blockquote>{{.PreviewText}}</blockquote><p><a href="{{.Link}}">Verify your email</a></p>`,
+			TextBody: "Hi {{.CustomerName}},\n\nYou started a conversation with {{.WorkspaceName}}.\n\n" +
+				"{{.PreviewText}}\n\nVerify your email to get the replies by mail: {{.Link}}",
+		},
+		"es": {
+			Subject: "Verifica tu correo para {{.WorkspaceName}}",
+			HTMLBody: `<p>Hola {{.CustomerName}},</p><p>Iniciaste una conversación con {{.WorkspaceName}}.</p>` +
+				`<blockquote>{{.PreviewText}}</blockquote><p><a href="{{.Link}}">Verificar tu correo</a></p>`,
+			TextBody: "Hola {{.CustomerName}},\n\nIniciaste una conversación con {{.WorkspaceName}}.\n\n" +
+				"{{.PreviewText}}\n\nVerifica tu correo para recibir las respuestas por correo: {{.Link}}",
+		},
+		"fr": {
+			Subject: "Vérifiez votre e-mail pour {{.WorkspaceName}}",
+			HTMLBody: `<p>Bonjour {{.CustomerName}},</p><p>Vous avez démarré une conversation avec {{.WorkspaceName}}.</p>` +
+				`<blockquote>{{.PreviewText}}</blockquote><p><a href="{{.Link}}">Vérifier votre e-mail</a></p>`,
+			TextBody: "Bonjour {{.CustomerName}},\n\nVous avez démarré une conversation avec {{.WorkspaceName}}.\n\n" +
+				"{{.PreviewText}}\n\nVérifiez votre e-mail pour recevoir les réponses par e-mail : {{.Link}}",
+		},
+		"de": {
+			Subject: "Bestätigen Sie Ihre E-Mail-Adresse für {{.WorkspaceName}}",
+			HTMLBody: `<p>Hallo {{.CustomerName}},</p><p>Sie haben eine Unterhaltung mit {{.WorkspaceName}} begonnen.</p>` +
+				`<blockquote>{{.PreviewText}}</blockquote><p><a href="{{.Link}}">E-Mail-Adresse bestätigen</a></p>`,
+			TextBody: "Hallo {{.CustomerName}},\n\nSie haben eine Unterhaltung mit {{.WorkspaceName}} begonnen.\n\n" +
+				"{{.PreviewText}}\n\nBestätigen Sie Ihre E-Mail-Adresse, um die Antworten per E-Mail zu erhalten: {{.Link}}",
+		},
+	},
+	MailTemplateKind{}.CSAT(): {
+		"en": {
+			Subject: `How did we do on "{{.ThreadTitle}}"?`,
+			HTMLBody: `<p>Hi {{.CustomerName}},</p><p>Your conversation "{{.ThreadTitle}}" with {{.WorkspaceName}} ` +
+				`is resolved. How would you rate the support you received?</p>` +
+				`<p><a href="{{.Link}}">Rate the conversation</a></p>`,
+			TextBody: "Hi {{.CustomerName}},\n\nYour conversation \"{{.ThreadTitle}}\" with {{.WorkspaceName}} " +
+				"is resolved. How would you rate the support you received?\n\nRate the conversation: {{.Link}}",
+		},
+		"es": {
+			Subject: `¿Qué tal lo hicimos en "{{.ThreadTitle}}"?`,
+			HTMLBody: `<p>Hola {{.CustomerName}},</p><p>Tu conversación "{{.ThreadTitle}}" con {{.WorkspaceName}} ` +
+				`está resuelta. ¿Cómo valorarías la atención recibida?</p>` +
+				`<p><a href="{{.Link}}">Valorar la conversación</a></p>`,
+			TextBody: "Hola {{.CustomerName}},\n\nTu conversación \"{{.ThreadTitle}}\" con {{.WorkspaceName}} " +
+				"está resuelta. ¿Cómo valorarías la atención recibida?\n\nValora la conversación: {{.Link}}",
+		},
+		"fr": {
+			Subject: "Comment avons-nous géré « {{.ThreadTitle}} » ?",
+			HTMLBody: `<p>Bonjour {{.CustomerName}},</p><p>Votre conversation « {{.ThreadTitle}} » avec ` +
+				`{{.WorkspaceName}} est résolue. Comment évalueriez-vous l'assistance reçue ?</p>` +
+				`<p><a href="{{.Link}}">Évaluer la conversation</a></p>`,
+			TextBody: "Bonjour {{.CustomerName}},\n\nVotre conversation « {{.ThreadTitle}} » avec {{.WorkspaceName}} " +
+				"est résolue. Comment évalueriez-vous l'assistance reçue ?\n\nÉvaluer la conversation : {{.Link}}",
+		},
+		"de": {
+			Subject: "Wie zufrieden waren Sie mit „{{.ThreadTitle}}“?",
+			HTMLBody: `<p>Hallo {{.CustomerName}},</p><p>Ihre Unterhaltung „{{.ThreadTitle}}“ mit {{.WorkspaceName}} ` +
+				`ist gelöst. Wie bewerten Sie den erhaltenen Support?</p>` +
+				`<p><a href="{{.Link}}">Unterhaltung bewerten</a></p>`,
+			TextBody: "Hallo {{.CustomerName}},\n\nIhre Unterhaltung „{{.ThreadTitle}}“ mit {{.WorkspaceName}} " +
+				"ist gelöst. Wie bewerten Sie den erhaltenen Support?\n\nUnterhaltung bewerten: {{.Link}}",
+		},
+	},
+	MailTemplateKind{}.Transcript(): {
+		"en": {
+			Subject: `Transcript of "{{.ThreadTitle}}"`,
+			TextBody: "Hi {{.CustomerName}},\n\nHere is the transcript of your conversation with {{.WorkspaceName}}.\n\n" +
+				"{{.Transcript}}",
+		},
+		"es": {
+			Subject: `Transcripción de "{{.ThreadTitle}}"`,
+			TextBody: "Hola {{.CustomerName}},\n\nAquí tienes la transcripción de tu conversación con {{.WorkspaceName}}.\n\n" +
+				"{{.Transcript}}",
+		},
+		"fr": {
+			Subject: "Transcription de « {{.ThreadTitle}} »",
+			TextBody: "Bonjour {{.CustomerName}},\n\nVoici la transcription de votre conversation avec {{.WorkspaceName}}.\n\n" +
+				"{{.Transcript}}",
+		},
+		"de": {
+			Subject: "Verlauf von „{{.ThreadTitle}}“",
+			TextBody: "Hallo {{.CustomerName}},\n\nhier ist der Verlauf Ihrer Unterhaltung mit {{.WorkspaceName}}.\n\n" +
+				"{{.Transcript}}",
+		},
+	},
+	MailTemplateKind{}.FollowUp(): {
+		"en": {
+			Subject:  "Re: {{.ThreadTitle}}",
+			TextBody: DefaultFollowUpTemplate,
+		},
+		"es": {
+			Subject: "Re: {{.ThreadTitle}}",
+			TextBody: "Hola {{.CustomerName}},\n\nNo hemos tenido noticias tuyas sobre \"{{.ThreadTitle}}\". " +
+				"¿Hay algo más en lo que podamos ayudarte?\nSi no sabemos de ti, marcaremos esta conversación " +
+				"como resuelta. Siempre puedes responder para reabrirla.\n\n{{.WorkspaceName}}",
+		},
+		"fr": {
+			Subject: "Re: {{.ThreadTitle}}",
+			TextBody: "Bonjour {{.CustomerName}},\n\nNous n'avons pas eu de nouvelles de votre part concernant " +
+				"« {{.ThreadTitle}} ». Pouvons-nous vous aider pour autre chose ?\nSans réponse de votre part, " +
+				"nous marquerons cette conversation comme résolue. Vous pouvez toujours répondre pour la rouvrir." +
+				"\n\n{{.WorkspaceName}}",
+		},
+		"de": {
+			Subject: "Re: {{.ThreadTitle}}",
+			TextBody: "Hallo {{.CustomerName}},\n\nwir haben zu „{{.ThreadTitle}}“ noch nichts von Ihnen gehört. " +
+				"Können wir Ihnen noch bei etwas anderem helfen?\nWenn wir nichts von Ihnen hören, markieren wir " +
+				"diese Unterhaltung als gelöst. Sie können jederzeit antworten, um sie wieder zu öffnen." +
+				"\n\n{{.WorkspaceName}}",
+		},
+	},
+	MailTemplateKind{}.Notification(): {
+		"en": {
+			Subject: "{{.WorkspaceName}}: {{.ThreadTitle}}",
+			HTMLBody: `<p>Hi {{.CustomerName}},</p><p>{{.PreviewText}}</p>` +
+				`<p><a href="{{.Link}}">View the conversation</a></p>`,
+			TextBody: "Hi {{.CustomerName}},\n\n{{.PreviewText}}\n\nView the conversation: {{.Link}}",
+		},
+		"es": {
+			Subject: "{{.WorkspaceName}}: {{.ThreadTitle}}",
+			HTMLBody: `<p>Hola {{.CustomerName}},</p><p>{{.PreviewText}}</p>` +
+				`<p><a href="{{.Link}}">Ver la conversación</a></p>`,
+			TextBody: "Hola {{.CustomerName}},\n\n{{.PreviewText}}\n\nVer la conversación: {{.Link}}",
+		},
+		"fr": {
+			Subject: "{{.WorkspaceName}} : {{.ThreadTitle}}",
+			HTMLBody: `<p>Bonjour {{.CustomerName}},</p><p>{{.PreviewText}}</p>` +
+				`<p><a href="{{.Link}}">Voir la conversation</a></p>`,
+			TextBody: "Bonjour {{.CustomerName}},\n\n{{.PreviewText}}\n\nVoir la conversation : {{.Link}}",
+		},
+		"de": {
+			Subject: "{{.WorkspaceName}}: {{.ThreadTitle}}",
+			HTMLBody: `<p>Hallo {{.CustomerName}},</p><p>{{.PreviewText}}</p>` +
+				`<p><a href="{{.Link}}">Unterhaltung ansehen</a></p>`,
+			TextBody: "Hallo {{.CustomerName}},\n\n{{.PreviewText}}\n\nUnterhaltung ansehen: {{.Link}}",
+		},
+	},
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"errors"
 	"fmt"
+	"mime"
+	"strings"
 	"time"
 
 	"github.com/rs/xid"
@@ -14,15 +17,53 @@ type Message struct {
 	TextBody     string
 	MarkdownBody string
 	HTMLBody     string
-	Customer     *CustomerActor
-	Member       *MemberActor
-	Channel      string
-	CreatedAt    time.Time
+	// Inbound mail text as received including the quoted history and the signature,
+	// empty if nothing is stripped from the reply.
+	OriginalTextBody string
+	Customer         *CustomerActor
+	Member           *MemberActor
+	Channel          string
+	Kind             string           // see MessageKind
+	IsAutomated      bool             // auto-reply of the Customer e.g. out-of-office, or the automated outbound message
+	Delivery         *MessageDelivery // outbound message delivery status, if tracked with the provider
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// MessageDelivery is the delivery status of the outbound message as reported by the channel provider.
+type MessageDelivery struct {
+	Status       string
+	HasError     bool
+	ErrorMessage string
 	UpdatedAt    time.Time
 }
 
+// MessageKind represents the kind of the Thread message.
+// Forward and note messages are internal to the workspace, never shown to or sent to the Customer.
+type MessageKind struct{}
+
+// Message is the message exchanged with the Customer.
+func (k MessageKind) Message() string {
+	return "message"
+}
+
+// Forward is the Thread timeline entry of the messages forwarded by the Member to an external address.
+func (k MessageKind) Forward() string {
+	return "forward"
+}
+
+// Note is the internal note, e.g. the reply received from the forwarded address.
+func (k MessageKind) Note() string {
+	return "note"
+}
+
 type MessageOption func(message *Message)
 
+// IsInternal checks if the message is internal to the workspace.
+func (m *Message) IsInternal() bool {
+	return m.Kind != MessageKind{}.Message()
+}
+
 func (m *Message) GenId() string {
 	return "msg" + xid.New().String()
 }
@@ -41,6 +82,7 @@ func NewMessage(threadId string, channel string, opts ...MessageOption) *Message
 		MessageId: messageId,
 		ThreadId:  threadId,
 		Channel:   channel,
+		Kind:      MessageKind{}.Message(),
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
@@ -83,6 +125,25 @@ func SetHTMLBody(body string) MessageOption {
 	}
 }
 
+// SetMessageOriginalTextBody keeps the inbound mail text as received, the reply is the text body.
+func SetMessageOriginalTextBody(body string) MessageOption {
+	return func(message *Message) {
+		message.OriginalTextBody = body
+	}
+}
+
+func SetMessageKind(kind string) MessageOption {
+	return func(message *Message) {
+		message.Kind = kind
+	}
+}
+
+func SetMessageAutomated(isAutomated bool) MessageOption {
+	return func(message *Message) {
+		message.IsAutomated = isAutomated
+	}
+}
+
 // MessageAttachment represents metadata and identification details for a file attachment linked to a message.
 type MessageAttachment struct {
 	AttachmentId string    `json:"attachmentId"`
@@ -95,6 +156,7 @@ type MessageAttachment struct {
 	HasError     bool      `json:"hasError"`
 	Error        string    `json:"error"`
 	MD5Hash      string    `json:"md5Hash"`
+	ContentId    string    `json:"contentId"` // inline attachment referenced as `cid:` in the HTML, if any
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
 }
@@ -103,91 +165,183 @@ func (m *MessageAttachment) GenId() string {
 	return "at" + xid.New().String()
 }
 
-type MessageWithAttachments struct {
-	Message
-	Attachments []MessageAttachment
-}
+// MaxAttachmentUploadSize caps the size of each file uploaded for the Member's reply.
+const MaxAttachmentUploadSize = 10 << 20
 
-type PostmarkMessageAttachment struct {
-	Name        string
-	ContentType string
-	Content     string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-}
+// MaxReplyAttachments caps the number of attachments of the Member's reply.
+const MaxReplyAttachments = 10
 
-// PostmarkInboundMessage represents abstracted Postmark inbound webhook request.
-// Attributes are specific to Postmark and as required by the application.
-type PostmarkInboundMessage struct {
-	// Raw inbound request payload
-	Payload map[string]interface{}
-	// `MessageID` from Postmark
-	PostmarkMessageId string
+// attachmentUploadTypes are the media types the Member can upload for the reply,
+// the media type prefix ending with `/` allows all the subtypes.
+var attachmentUploadTypes = []string{
+	"image/",
+	"text/plain",
+	"text/csv",
+	"application/pdf",
+	"application/zip",
+	"application/json",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.",
+	"application/vnd.oasis.opendocument.",
+}
 
-	// From mail protocol `Message-ID` in headers
-	MailMessageId string
-	// From mail protocol `In-Reply-To` To `Message-ID` in headers
-	ReplyMailMessageId *string
+// attachmentUploadContentTypes are the media types detected from the content of the upload,
+// as by `http.DetectContentType`, for the declared media type not detected as is.
+// The media type prefix ending with `/` or `.` matches the declared media types with the prefix.
+var attachmentUploadContentTypes = map[string][]string{
+	"text/plain":       {"text/plain"},
+	"text/csv":         {"text/plain"},
+	"application/json": {"text/plain"},
+	// Office documents are the zip containers, the legacy documents are not detected.
+	"application/vnd.openxmlformats-officedocument.": {"application/zip"},
+	"application/vnd.oasis.opendocument.":            {"application/zip"},
+	"application/msword":                             {"application/octet-stream"},
+	"application/vnd.ms-excel":                       {"application/octet-stream"},
+	"application/vnd.ms-powerpoint":                  {"application/octet-stream"},
+}
 
-	FromEmail string
-	FromName  string
+// AttachmentUpload is the file uploaded by the Member for the reply to the Thread.
+// When the reply is sent the upload is linked to the reply message as the message attachment
+// with the same attachment ID and the stored content.
+type AttachmentUpload struct {
+	AttachmentId string    `json:"attachmentId"`
+	WorkspaceId  string    `json:"workspaceId"`
+	ThreadId     string    `json:"threadId"`
+	MemberId     string    `json:"memberId"`
+	Name         string    `json:"name"`
+	ContentType  string    `json:"contentType"`
+	ContentKey   string    `json:"contentKey"`
+	ContentUrl   string    `json:"contentUrl"`
+	Size         int64     `json:"size"`
+	MD5Hash      string    `json:"md5Hash"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
 
-	CreatedAt time.Time
-	UpdatedAt time.Time
+// NewAttachmentUpload returns the Member's upload for the reply to the Thread,
+// returns an error if the file is over the size limit or of the media type not allowed.
+// The declared content type must match the content type detected from the content.
+func NewAttachmentUpload(
+	workspaceId, threadId, memberId, name, contentType, detectedType string, size int64) (AttachmentUpload, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, "/\\\r\n") {
+		return AttachmentUpload{}, errors.New("invalid attachment name")
+	}
+	if size <= 0 {
+		return AttachmentUpload{}, errors.New("attachment is empty")
+	}
+	if size > MaxAttachmentUploadSize {
+		return AttachmentUpload{}, fmt.Errorf("attachment must not exceed %d bytes", MaxAttachmentUploadSize)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !IsAttachmentUploadType(mediaType) {
+		return AttachmentUpload{}, errors.New("attachment type not allowed")
+	}
+	if !IsAttachmentUploadContent(mediaType, detectedType) {
+		return AttachmentUpload{}, errors.New("attachment content does not match the type")
+	}
+	return AttachmentUpload{
+		AttachmentId: (&MessageAttachment{}).GenId(),
+		WorkspaceId:  workspaceId,
+		ThreadId:     threadId,
+		MemberId:     memberId,
+		Name:         name,
+		ContentType:  mediaType,
+		Size:         size,
+		CreatedAt:    time.Now().UTC(),
+	}, nil
+}
 
-	Subject  string
-	TextBody string
-	HTMLBody string
+// IsAttachmentUploadType checks the media type is allowed for the Member's upload.
+func IsAttachmentUploadType(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	// SVG images can carry scripts.
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	for _, t := range attachmentUploadTypes {
+		if strings.HasSuffix(t, "/") || strings.HasSuffix(t, ".") {
+			if strings.HasPrefix(mediaType, t) {
+				return true
+			}
+			continue
+		}
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
 
-	Attachments []PostmarkMessageAttachment
+// IsAttachmentUploadContent checks the media type detected from the content of the upload
+// matches the declared media type, e.g. not the HTML page uploaded as the image.
+func IsAttachmentUploadContent(mediaType, detectedType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	detected, _, err := mime.ParseMediaType(detectedType)
+	if err != nil {
+		return false
+	}
+	if detected == mediaType {
+		return true
+	}
+	// Any detected image type of the declared image, e.g. the JPEG named as PNG.
+	if strings.HasPrefix(mediaType, "image/") {
+		return strings.HasPrefix(detected, "image/") && IsAttachmentUploadType(detected)
+	}
+	for t, types := range attachmentUploadContentTypes {
+		if mediaType != t && !(strings.HasSuffix(t, ".") && strings.HasPrefix(mediaType, t)) {
+			continue
+		}
+		for _, dt := range types {
+			if detected == dt {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-func (p *PostmarkInboundMessage) ToPostmarkMessageLog(messageId string) PostmarkMessageLog {
+// MessageAttachment returns the upload as the attachment of the reply message.
+func (u AttachmentUpload) MessageAttachment(messageId string) MessageAttachment {
 	now := time.Now().UTC()
-	return PostmarkMessageLog{
-		MessageId:          messageId,
-		Payload:            p.Payload,
-		PostmarkMessageId:  p.PostmarkMessageId,
-		MailMessageId:      p.MailMessageId,
-		ReplyMailMessageId: p.ReplyMailMessageId,
-		HasError:           false,
-		SubmittedAt:        now,
-		MessageType:        "inbound",
-		CreatedAt:          now,
-		UpdatedAt:          now,
+	return MessageAttachment{
+		AttachmentId: u.AttachmentId,
+		MessageId:    messageId,
+		Name:         u.Name,
+		ContentType:  u.ContentType,
+		ContentKey:   u.ContentKey,
+		ContentUrl:   u.ContentUrl,
+		MD5Hash:      u.MD5Hash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
 	}
 }
 
+type MessageWithAttachments struct {
+	Message
+	Attachments []MessageAttachment
+}
+
 // ThreadMessage combines a Thread and its associated Message.
+// The channel message log if any is persisted along with the message, as the WhatsApp message log.
+// The Member's uploads attached to the outbound message are persisted as the message attachments.
+// The outbound mail queued for delivery if any is persisted along with the message.
 type ThreadMessage struct {
-	Thread  *Thread
-	Message *Message
-}
-
-type PostmarkMessageLog struct {
-	MessageId          string
-	Payload            map[string]interface{}
-	PostmarkMessageId  string
-	MailMessageId      string
-	ReplyMailMessageId *string
-	HasError           bool
-	SubmittedAt        time.Time
-	ErrorCode          int64
-	PostmarkMessage    string
-	MessageEvent       string
-	Acknowledged       bool // has Postmark outbound message acknowledged with details API
-	MessageType        string
-	CreatedAt          time.Time
-	UpdatedAt          time.Time
-}
-
-// SetOutboundMailMessageId sets outbound mail message ID with specified domain as configured.
-// This should be only used for outbound mails as inbound mail already has it generated from the client.
-func (m *PostmarkMessageLog) SetOutboundMailMessageId(d string) {
-	m.MailMessageId = fmt.Sprintf("<%s@%s>", m.PostmarkMessageId, d)
-}
-
-type PostmarkMailThreadMessage struct {
-	PostmarkMessageLog *PostmarkMessageLog
-	ThreadMessage      *ThreadMessage
+	Thread       *Thread
+	Message      *Message
+	Log          *ChannelMessageLog
+	WhatsAppLog  *WhatsAppMessageLog
+	Participants []MessageParticipant
+	Attachments  []MessageAttachment
+	Outbound     *OutboundMail
+}
+
+// MessageParticipant is the recipient of the message as addressed, e.g. mail `To`, `Cc` and `Bcc`.
+type MessageParticipant struct {
+	MessageId string
+	Email     string
+	Name      string
+	Role      string
+	CreatedAt time.Time
 }
@@ -356,18 +356,25 @@ type WidgetSessionData struct {
 }
 
 type WidgetSession struct {
-	SessionId string
-	WidgetId  string
-	Data      string
-	ExpireAt  time.Time
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	SessionId  string
+	WidgetId   string
+	CustomerId sql.NullString
+	Data       string
+	ExpireAt   time.Time
+	RevokedAt  sql.NullTime // Set when the session customer is blocked.
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 func (ws *WidgetSession) GenId() string {
 	return "ws" + xid.New().String()
 }
 
+// IsRevoked checks if the session is revoked.
+func (ws *WidgetSession) IsRevoked() bool {
+	return ws.RevokedAt.Valid
+}
+
 func (ws *WidgetSession) CreateSession(sessionId string, widgetId string) WidgetSession {
 	return WidgetSession{
 		SessionId: sessionId,
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"strings"
+	"text/template"
 	"time"
 )
 
@@ -63,3 +67,89 @@ func (pm PostmarkMailServerSetting) DNSHasVerified() bool {
 	}
 	return false
 }
+
+// IsInboundRecipient checks the inbound mail is delivered to the server's inbound address, with or without
+// the plus address tag, as any of the recipients.
+func (pm PostmarkMailServerSetting) IsInboundRecipient(recipients []string) bool {
+	if pm.InboundEmail == nil || *pm.InboundEmail == "" {
+		return false
+	}
+	for _, rcpt := range recipients {
+		if strings.EqualFold(StripPlusAddress(strings.TrimSpace(rcpt)), *pm.InboundEmail) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultFollowUpTemplate is the follow-up message sent to the Customer when the workspace
+// has not specified one.
+const DefaultFollowUpTemplate = `Hi {{.CustomerName}},
+
+We haven't heard back from you on "{{.ThreadTitle}}". Is there anything else we can help you with?
+If we don't hear from you, we will mark this conversation as resolved. You can always reply to reopen it.
+
+{{.WorkspaceName}}`
+
+// ThreadFollowUpSetting is the workspace policy for threads waiting on the Customer.
+// After FollowUpAfterDays with no Customer reply, the follow-up is optionally sent through the
+// thread's channel, then the thread is resolved after ResolveAfterDays more days.
+type ThreadFollowUpSetting struct {
+	WorkspaceId       string    `json:"workspaceId"`
+	IsEnabled         bool      `json:"isEnabled"`
+	FollowUpAfterDays int       `json:"followUpAfterDays"`
+	SendFollowUp      bool      `json:"sendFollowUp"`
+	FollowUpTemplate  string    `json:"followUpTemplate"`
+	ResolveAfterDays  int       `json:"resolveAfterDays"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// NewThreadFollowUpSetting returns the default follow-up setting for the workspace, disabled until enabled.
+func NewThreadFollowUpSetting(workspaceId string) ThreadFollowUpSetting {
+	now := time.Now().UTC()
+	return ThreadFollowUpSetting{
+		WorkspaceId:       workspaceId,
+		IsEnabled:         false,
+		FollowUpAfterDays: 3,
+		SendFollowUp:      true,
+		FollowUpTemplate:  DefaultFollowUpTemplate,
+		ResolveAfterDays:  4,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+}
+
+// FollowUpTemplateData is the data available to the follow-up template.
+type FollowUpTemplateData struct {
+	CustomerName  string
+	WorkspaceName string
+	ThreadTitle   string
+}
+
+// Validate checks the policy days and that the follow-up template parses.
+func (fs ThreadFollowUpSetting) Validate() error {
+	if fs.FollowUpAfterDays <= 0 {
+		return errors.New("follow up after days must be greater than zero")
+	}
+	if fs.ResolveAfterDays <= 0 {
+		return errors.New("resolve after days must be greater than zero")
+	}
+	if _, err := template.New("followUp").Parse(fs.FollowUpTemplate); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RenderFollowUp renders the follow-up template as plain text.
+func (fs ThreadFollowUpSetting) RenderFollowUp(data FollowUpTemplateData) (string, error) {
+	tmpl, err := template.New("followUp").Parse(fs.FollowUpTemplate)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/xid"
+)
+
+// SlackWorkspaceStatus represents the status of the Slack workspace with respect to the Workspace.
+type SlackWorkspaceStatus struct{}
+
+func (s SlackWorkspaceStatus) Active() string {
+	return "active"
+}
+
+func (s SlackWorkspaceStatus) Revoked() string {
+	return "revoked"
+}
+
+// SlackSyncStatus represents the channel sync status of the Slack workspace.
+type SlackSyncStatus struct{}
+
+func (s SlackSyncStatus) Pending() string {
+	return "pending"
+}
+
+func (s SlackSyncStatus) Syncing() string {
+	return "syncing"
+}
+
+func (s SlackSyncStatus) Synced() string {
+	return "synced"
+}
+
+func (s SlackSyncStatus) Failed() string {
+	return "failed"
+}
+
+// SlackChannelStatus represents the status of the Slack channel with respect to the Slack workspace.
+type SlackChannelStatus struct{}
+
+func (s SlackChannelStatus) Active() string {
+	return "active"
+}
+
+// Archived when the channel is archived or no longer listed for the bot.
+func (s SlackChannelStatus) Archived() string {
+	return "archived"
+}
+
+// SlackWorkspace is the Slack workspace (team) installed for the Workspace.
+type SlackWorkspace struct {
+	WorkspaceId string     `json:"workspaceId"`
+	Ref         string     `json:"ref"` // Slack team ID
+	Url         string     `json:"url"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	SyncStatus  string     `json:"syncStatus"`
+	SyncedAt    *time.Time `json:"syncedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func (sw SlackWorkspace) IsActive() bool {
+	return sw.Status == SlackWorkspaceStatus{}.Active()
+}
+
+// SlackBot is the bot user installed with the Slack app, there is only one bot per Slack workspace.
+type SlackBot struct {
+	SlackWorkspaceRef string    `json:"slackWorkspaceRef"`
+	BotId             string    `json:"botId"`
+	BotUserRef        string    `json:"botUserRef"` // Slack bot user ID
+	BotRef            *string   `json:"botRef"`     // Slack bot ID
+	AppRef            string    `json:"appRef"`
+	Scope             string    `json:"scope"`
+	AccessToken       string    `json:"-"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+func (sb SlackBot) GenId() string {
+	return "sb" + xid.New().String()
+}
+
+// SlackChannel is the Slack channel synced for the Slack workspace.
+// Messages in enabled channels are turned into Threads, labelled with the mapped label if any.
+type SlackChannel struct {
+	SlackWorkspaceRef  string     `json:"slackWorkspaceRef"`
+	ChannelId          string     `json:"channelId"`
+	ChannelRef         string     `json:"channelRef"` // Slack channel ID
+	IsChannel          bool       `json:"isChannel"`
+	IsExtShared        bool       `json:"isExtShared"`
+	IsGeneral          bool       `json:"isGeneral"`
+	IsGroup            bool       `json:"isGroup"`
+	IsIm               bool       `json:"isIm"`
+	IsMember           bool       `json:"isMember"`
+	IsMpim             bool       `json:"isMpim"`
+	IsOrgShared        bool       `json:"isOrgShared"`
+	IsPendingExtShared bool       `json:"isPendingExtShared"`
+	IsPrivate          bool       `json:"isPrivate"`
+	IsShared           bool       `json:"isShared"`
+	Name               string     `json:"name"`
+	NameNormalized     string     `json:"nameNormalized"`
+	Created            int64      `json:"created"`
+	Updated            int64      `json:"updated"`
+	Status             string     `json:"status"`
+	IsEnabled          bool       `json:"isEnabled"` // creates Threads from the channel messages
+	LabelId            *string    `json:"labelId"`   // label added to the Threads created from the channel
+	SyncedAt           *time.Time `json:"syncedAt"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+func (sc SlackChannel) GenId() string {
+	return "sc" + xid.New().String()
+}
+
+// IsConnect checks if the channel is shared with the external organizations with Slack Connect.
+func (sc SlackChannel) IsConnect() bool {
+	return sc.IsExtShared || sc.IsPendingExtShared
+}
+
+// SlackMessageLog links the Slack message to the Thread message.
+// ThreadTs is the Slack thread root message ts, the Slack thread maps to the Thread.
+type SlackMessageLog struct {
+	MessageId         string    `json:"messageId"`
+	SlackWorkspaceRef string    `json:"slackWorkspaceRef"`
+	ChannelRef        string    `json:"channelRef"`
+	Ts                string    `json:"ts"`
+	ThreadTs          string    `json:"threadTs"`
+	UserRef           string    `json:"userRef"`
+	MessageType       string    `json:"messageType"` // inbound or outbound
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// SlackInbound is the message posted in the Slack channel, received from the Events API.
+type SlackInbound struct {
+	TeamRef    string // Slack team ID the event is delivered for
+	ChannelRef string
+	UserRef    string
+	UserTeam   string // Slack team ID of the user, differs from the team in Slack Connect channels
+	Text       string
+	Ts         string
+	ThreadTs   string // empty for the top level message
+
+	// Spam verdict of the inbound message as per the spam pipeline.
+	Spam SpamVerdict
+}
+
+// IsReply checks if the message is posted as the reply in the Slack thread.
+func (m SlackInbound) IsReply() bool {
+	return m.ThreadTs != "" && m.ThreadTs != m.Ts
+}
+
+// RootTs returns the root message ts of the Slack thread.
+func (m SlackInbound) RootTs() string {
+	if m.ThreadTs != "" {
+		return m.ThreadTs
+	}
+	return m.Ts
+}
+
+// SlackUser is the Slack user profile of the message author.
+type SlackUser struct {
+	Ref      string
+	TeamRef  string
+	Name     string
+	RealName string
+	Email    string
+	IsBot    bool
+}
+
+// DisplayName returns the name of the Slack user as shown in Slack.
+func (u SlackUser) DisplayName() string {
+	if u.RealName != "" {
+		return u.RealName
+	}
+	return u.Name
+}
+
+// SlackOAuthAccess is the result of the Slack app installation with OAuth.
+type SlackOAuthAccess struct {
+	AccessToken string
+	Scope       string
+	BotUserRef  string
+	AppRef      string
+	TeamRef     string
+	TeamName    string
+}
+
+// SlackOAuthStateClaims is the signed OAuth state of the Slack app installation for the Workspace.
+type SlackOAuthStateClaims struct {
+	WorkspaceId string `json:"workspaceId"`
+	RedirectUrl string `json:"redirectUrl"`
+	jwt.RegisteredClaims
+}
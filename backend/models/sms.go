@@ -0,0 +1,196 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// SMSSetting is the workspace SMS provider account.
+// Inbound SMS from the Customer within the ConversationWindowHours of the last message
+// continues the Customer's recent SMS Thread, otherwise starts a new Thread.
+type SMSSetting struct {
+	WorkspaceId             string    `json:"workspaceId"`
+	IsEnabled               bool      `json:"isEnabled"`
+	Provider                string    `json:"provider"`
+	PhoneNumber             string    `json:"phoneNumber"` // E.164 sender number
+	AccountSid              string    `json:"accountSid"`
+	AuthToken               string    `json:"-"`
+	ConversationWindowHours int       `json:"conversationWindowHours"`
+	CreatedAt               time.Time `json:"createdAt"`
+	UpdatedAt               time.Time `json:"updatedAt"`
+}
+
+// NewSMSSetting returns the default disabled SMS setting for the workspace.
+func NewSMSSetting(workspaceId string) SMSSetting {
+	now := time.Now().UTC()
+	return SMSSetting{
+		WorkspaceId:             workspaceId,
+		IsEnabled:               false,
+		Provider:                SMSProviderName{}.Fake(),
+		ConversationWindowHours: 24,
+		CreatedAt:               now,
+		UpdatedAt:               now,
+	}
+}
+
+// ConversationWindow returns the conversation window as duration.
+func (s SMSSetting) ConversationWindow() time.Duration {
+	return time.Duration(s.ConversationWindowHours) * time.Hour
+}
+
+func (s SMSSetting) Validate() error {
+	if !(SMSProviderName{}).IsValid(s.Provider) {
+		return errors.New("unsupported sms provider")
+	}
+	if s.ConversationWindowHours <= 0 {
+		return errors.New("conversation window hours must be greater than zero")
+	}
+	if !s.IsEnabled {
+		return nil
+	}
+	if s.PhoneNumber == "" {
+		return errors.New("phone number is required")
+	}
+	if s.Provider == (SMSProviderName{}).Twilio() && (s.AccountSid == "" || s.AuthToken == "") {
+		return errors.New("account sid and auth token are required")
+	}
+	return nil
+}
+
+// SMSProviderName represents the supported SMS providers.
+type SMSProviderName struct{}
+
+func (p SMSProviderName) Twilio() string {
+	return "twilio"
+}
+
+// Fake is the local provider for development, messages are not sent.
+func (p SMSProviderName) Fake() string {
+	return "fake"
+}
+
+func (p SMSProviderName) IsValid(s string) bool {
+	switch s {
+	case p.Twilio(), p.Fake():
+		return true
+	default:
+		return false
+	}
+}
+
+// SMSStatus represents the delivery status of the SMS as reported by the provider.
+type SMSStatus struct{}
+
+func (s SMSStatus) Received() string {
+	return "received"
+}
+
+func (s SMSStatus) Queued() string {
+	return "queued"
+}
+
+func (s SMSStatus) Sent() string {
+	return "sent"
+}
+
+func (s SMSStatus) Delivered() string {
+	return "delivered"
+}
+
+func (s SMSStatus) Undelivered() string {
+	return "undelivered"
+}
+
+func (s SMSStatus) Failed() string {
+	return "failed"
+}
+
+// Finals returns the statuses after which the provider reports no further status.
+func (s SMSStatus) Finals() []string {
+	return []string{s.Delivered(), s.Undelivered(), s.Failed()}
+}
+
+// Normalize maps the provider reported status to the supported status.
+// Intermediate statuses e.g. accepted, scheduled or sending are reported as queued.
+func (s SMSStatus) Normalize(status string) string {
+	switch status {
+	case s.Received(), s.Queued(), s.Sent(), s.Delivered(), s.Undelivered(), s.Failed():
+		return status
+	case "canceled":
+		return s.Failed()
+	default:
+		return s.Queued()
+	}
+}
+
+// SMSMessageLog tracks the provider message of the inbound or outbound SMS linked to the Message.
+type SMSMessageLog struct {
+	MessageId         string                 `json:"messageId"`
+	Provider          string                 `json:"provider"`
+	ProviderMessageId string                 `json:"providerMessageId"`
+	MessageType       string                 `json:"messageType"` // inbound or outbound
+	FromPhone         string                 `json:"fromPhone"`
+	ToPhone           string                 `json:"toPhone"`
+	Status            string                 `json:"status"`
+	ErrorCode         *string                `json:"errorCode"`
+	ErrorMessage      *string                `json:"errorMessage"`
+	Payload           map[string]interface{} `json:"-"`
+	CreatedAt         time.Time              `json:"createdAt"`
+	UpdatedAt         time.Time              `json:"updatedAt"`
+}
+
+// InboundSMS is the inbound SMS received from the provider webhook.
+type InboundSMS struct {
+	ProviderMessageId string
+	FromPhone         string
+	ToPhone           string
+	Body              string
+	NumMedia          int
+	Payload           map[string]interface{}
+	CreatedAt         time.Time
+
+	// Spam verdict of the inbound SMS as per the spam pipeline.
+	Spam SpamVerdict
+}
+
+// ToSMSMessageLog returns the inbound message log for the Message.
+func (m InboundSMS) ToSMSMessageLog(messageId, provider string) SMSMessageLog {
+	now := time.Now().UTC()
+	return SMSMessageLog{
+		MessageId:         messageId,
+		Provider:          provider,
+		ProviderMessageId: m.ProviderMessageId,
+		MessageType:       "inbound",
+		FromPhone:         m.FromPhone,
+		ToPhone:           m.ToPhone,
+		Status:            SMSStatus{}.Received(),
+		Payload:           m.Payload,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+}
+
+// OutboundSMS is the SMS sent through the provider.
+type OutboundSMS struct {
+	FromPhone      string
+	ToPhone        string
+	Body           string
+	StatusCallback string // URL the provider reports the delivery status to
+}
+
+// SMSSendResult is the provider response for the OutboundSMS.
+type SMSSendResult struct {
+	ProviderMessageId string
+	Status            string
+	ErrorCode         *string
+	ErrorMessage      *string
+	Payload           map[string]interface{}
+}
+
+// SMSStatusUpdate is the delivery status reported by the provider for the sent SMS.
+type SMSStatusUpdate struct {
+	ProviderMessageId string
+	Status            string
+	ErrorCode         *string
+	ErrorMessage      *string
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// SpamSetting is the workspace spam filtering policy.
+// Inbound messages scoring at or over the Threshold move the Thread to spam stage.
+type SpamSetting struct {
+	WorkspaceId        string    `json:"workspaceId"`
+	IsEnabled          bool      `json:"isEnabled"`
+	Threshold          float64   `json:"threshold"`
+	ChatRateLimit      int       `json:"chatRateLimit"`      // Max chat messages per customer within the window.
+	ChatRateWindowSecs int       `json:"chatRateWindowSecs"` // Chat rate window in seconds.
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// NewSpamSetting returns the default disabled spam setting for the workspace,
+// inbound messages are not filtered until the workspace enables it.
+func NewSpamSetting(workspaceId string) SpamSetting {
+	now := time.Now().UTC()
+	return SpamSetting{
+		WorkspaceId:        workspaceId,
+		IsEnabled:          false,
+		Threshold:          5.0,
+		ChatRateLimit:      20,
+		ChatRateWindowSecs: 60,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+}
+
+// ChatRateWindow returns the chat rate window as duration.
+func (s SpamSetting) ChatRateWindow() time.Duration {
+	return time.Duration(s.ChatRateWindowSecs) * time.Second
+}
+
+// SpamRuleKind represents what the sender rule matches against.
+type SpamRuleKind struct{}
+
+func (k SpamRuleKind) Address() string {
+	return "address"
+}
+
+func (k SpamRuleKind) Domain() string {
+	return "domain"
+}
+
+func (k SpamRuleKind) Customer() string {
+	return "customer"
+}
+
+func (k SpamRuleKind) IsValid(s string) bool {
+	switch s {
+	case k.Address(), k.Domain(), k.Customer():
+		return true
+	default:
+		return false
+	}
+}
+
+// SpamRuleAction represents the action taken when the sender rule matches.
+// Senders are blocked with the workspace blocklist, see SenderBlock.
+type SpamRuleAction struct{}
+
+func (a SpamRuleAction) Allow() string {
+	return "allow"
+}
+
+func (a SpamRuleAction) IsValid(s string) bool {
+	switch s {
+	case a.Allow():
+		return true
+	default:
+		return false
+	}
+}
+
+// SpamSenderRule allows the inbound sender by address, domain or customer.
+// Allow rules are also added when members mark a spam thread as not spam.
+type SpamSenderRule struct {
+	RuleId      string      `json:"ruleId"`
+	WorkspaceId string      `json:"workspaceId"`
+	Kind        string      `json:"kind"`
+	Value       string      `json:"value"`
+	Action      string      `json:"action"`
+	CreatedBy   MemberActor `json:"createdBy"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+func (r SpamSenderRule) GenId() string {
+	return "sr" + xid.New().String()
+}
+
+func NewSpamSenderRule(workspaceId, kind, value, action string, createdBy MemberActor) SpamSenderRule {
+	now := time.Now().UTC()
+	return SpamSenderRule{
+		RuleId:      SpamSenderRule{}.GenId(),
+		WorkspaceId: workspaceId,
+		Kind:        kind,
+		Value:       NormalizeSpamRuleValue(value),
+		Action:      action,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// NormalizeSpamRuleValue lower cases the address or domain for matching.
+func NormalizeSpamRuleValue(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// SpamCandidate is the inbound message scored by the spam pipeline.
+type SpamCandidate struct {
+	WorkspaceId string
+	Channel     string
+	CustomerId  string
+	FromEmail   string            // empty for channels without email
+	Headers     map[string]string // mail protocol headers in canonical form
+	Subject     string
+	TextBody    string
+}
+
+// FromDomain returns the domain of the sender email if any.
+func (c SpamCandidate) FromDomain() string {
+	if i := strings.LastIndex(c.FromEmail, "@"); i >= 0 {
+		return NormalizeSpamRuleValue(c.FromEmail[i+1:])
+	}
+	return ""
+}
+
+// SpamSignal is the outcome of a single spam scorer.
+// Allow is set when the sender is explicitly allowed, overriding the total score.
+type SpamSignal struct {
+	Scorer string  `json:"scorer"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+	Allow  bool    `json:"allow"`
+}
+
+// SpamVerdict is the outcome of the spam pipeline for the inbound message.
+type SpamVerdict struct {
+	Score     float64      `json:"score"`
+	Threshold float64      `json:"threshold"`
+	IsSpam    bool         `json:"isSpam"`
+	Signals   []SpamSignal `json:"signals"`
+}
+
+// NewSpamVerdict totals the signals against the threshold.
+// Any allow signal marks the message as not spam.
+func NewSpamVerdict(threshold float64, signals []SpamSignal) SpamVerdict {
+	verdict := SpamVerdict{Threshold: threshold, Signals: signals}
+	var allowed bool
+	for _, s := range signals {
+		verdict.Score += s.Score
+		if s.Allow {
+			allowed = true
+		}
+	}
+	verdict.IsSpam = !allowed && verdict.Score >= threshold
+	return verdict
+}
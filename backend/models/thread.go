@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/rs/xid"
@@ -26,6 +29,10 @@ const (
 const (
 	inAppChat = "in_app_chat"
 	email     = "email"
+	sms       = "sms"
+	whatsapp  = "whatsapp"
+	slack     = "slack"
+	api       = "api"
 )
 
 // ThreadStatus represents the high level status of the Thread.
@@ -169,6 +176,28 @@ func (c ThreadChannel) Email() string {
 	return email
 }
 
+func (c ThreadChannel) SMS() string {
+	return sms
+}
+
+func (c ThreadChannel) WhatsApp() string {
+	return whatsapp
+}
+
+func (c ThreadChannel) Slack() string {
+	return slack
+}
+
+func (c ThreadChannel) API() string {
+	return api
+}
+
+// FollowUpChannels are the channels the follow-up is sent through,
+// the threads of the other channels are not followed up.
+func (c ThreadChannel) FollowUpChannels() []string {
+	return []string{c.InAppChat(), c.Email(), c.SMS()}
+}
+
 // InboundMessage tracks the inbound message received from the Customer.
 // Common across channels.
 // TODO: rename this to InboundEvent - tracks inbound metadata
@@ -399,11 +428,15 @@ func (th *Thread) CustomerPreviewText() string {
 // If not then it sets the default status as NeedsFirstResponse.
 // else it sets the default status as NeedsNextResponse.
 func (th *Thread) SetDefaultStatus(member MemberActor) {
+	th.SetStatusStage(th.DefaultStage(), member)
+}
+
+// DefaultStage returns the stage the Thread falls back to when it needs attention from the Member.
+func (th *Thread) DefaultStage() string {
 	if th.Replied {
-		th.ThreadStatus.NeedsNextResponse(member)
-	} else {
-		th.ThreadStatus.NeedsFirstResponse(member)
+		return needsNextResponse
 	}
+	return needsFirstResponse
 }
 
 func (th *Thread) SetStatusStage(stage string, member MemberActor) {
@@ -424,3 +457,326 @@ func (th *Thread) SetStatusStage(stage string, member MemberActor) {
 		th.SetDefaultStatus(member)
 	}
 }
+
+// TransitionStage moves the Thread to the given stage as a manual change made by the Member.
+// Returns ErrStageTransition describing why the change is not allowed from the current stage.
+func (th *Thread) TransitionStage(stage string, member MemberActor) error {
+	if err := th.CanTransitionStage(stage); err != nil {
+		return err
+	}
+	th.SetStatusStage(stage, member)
+	return nil
+}
+
+// CanTransitionStage checks the stage transition table and guards
+// for moving the Thread from its current stage to the given stage.
+func (th *Thread) CanTransitionStage(stage string) error {
+	from := th.ThreadStatus.Stage
+	if !th.ThreadStatus.IsValidStage(stage) {
+		return &StageTransitionError{From: from, To: stage, Reason: "unknown stage"}
+	}
+	// Setting the same stage again is a no-op.
+	if from == stage {
+		return nil
+	}
+	transition, ok := stageTransitions[from][stage]
+	if !ok {
+		return &StageTransitionError{From: from, To: stage, Reason: "transition is not allowed"}
+	}
+	if transition.guard != nil {
+		if reason := transition.guard(th); reason != "" {
+			return &StageTransitionError{From: from, To: stage, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// OnInboundMessage applies the automatic stage transition when the Customer sends a message.
+// Spam threads stay in spam, threads on hold stay on hold until the Member moves them,
+// otherwise the Thread moves to the stage that needs the Member's response.
+func (th *Thread) OnInboundMessage(member MemberActor) {
+	switch th.ThreadStatus.Stage {
+	case spam, hold, needsFirstResponse, needsNextResponse:
+		return
+	default:
+		th.SetStatusStage(th.DefaultStage(), member)
+	}
+}
+
+// OnOutboundMessage applies the automatic stage transition when the Member replies to the Customer.
+// The Thread is marked as replied and moves to waiting on customer.
+func (th *Thread) OnOutboundMessage(member MemberActor) {
+	th.Replied = true
+	th.SetStatusStage(waitingOnCustomer, member)
+}
+
+// OnDeliveryFailure moves the Thread back to the stage that needs the Member's response
+// when the Member's reply could not be delivered. Spam and on hold threads stay as is.
+func (th *Thread) OnDeliveryFailure(member MemberActor) {
+	th.OnInboundMessage(member)
+}
+
+// Resolve moves the Thread to resolved stage as per the allowed stage transitions.
+func (th *Thread) Resolve(member MemberActor) error {
+	return th.TransitionStage(resolved, member)
+}
+
+// IsResolved checks if the Thread is resolved.
+func (th *Thread) IsResolved() bool {
+	return th.ThreadStatus.Stage == resolved
+}
+
+// IsWaitingOnCustomer checks if the Thread is waiting on the Customer to reply.
+func (th *Thread) IsWaitingOnCustomer() bool {
+	return th.ThreadStatus.Stage == waitingOnCustomer
+}
+
+// MarkSpam moves the Thread to spam stage as flagged by the spam pipeline.
+// Applied as an automatic transition regardless of the current stage.
+func (th *Thread) MarkSpam(member MemberActor) {
+	th.SetStatusStage(spam, member)
+}
+
+// IsSpam checks if the Thread is in spam stage.
+func (th *Thread) IsSpam() bool {
+	return th.ThreadStatus.Stage == spam
+}
+
+// StageTransitionError describes a rejected Thread stage transition.
+type StageTransitionError struct {
+	From   string
+	To     string
+	Reason string
+}
+
+func (e *StageTransitionError) Error() string {
+	return fmt.Sprintf("cannot move thread from stage %s to %s: %s", e.From, e.To, e.Reason)
+}
+
+func (e *StageTransitionError) Is(target error) bool {
+	return target == ErrStageTransition
+}
+
+// ErrStageTransition is matched by every StageTransitionError.
+var ErrStageTransition = errors.New("invalid thread stage transition")
+
+// stageGuard returns the reason for rejecting the transition, or empty if allowed.
+type stageGuard func(th *Thread) string
+
+type stageTransition struct {
+	guard stageGuard
+}
+
+func requireReplied(th *Thread) string {
+	if !th.Replied {
+		return "thread has not been replied yet"
+	}
+	return ""
+}
+
+func requireNotReplied(th *Thread) string {
+	if th.Replied {
+		return "thread has already been replied"
+	}
+	return ""
+}
+
+// stageTransitions is the table of allowed manual stage transitions keyed by the current stage.
+// Automatic transitions are applied with OnInboundMessage and OnOutboundMessage.
+var stageTransitions = map[string]map[string]stageTransition{
+	needsFirstResponse: {
+		waitingOnCustomer: {guard: requireReplied},
+		hold:              {},
+		resolved:          {},
+		spam:              {},
+	},
+	needsNextResponse: {
+		waitingOnCustomer: {guard: requireReplied},
+		hold:              {},
+		resolved:          {},
+		spam:              {},
+	},
+	waitingOnCustomer: {
+		needsNextResponse: {guard: requireReplied},
+		hold:              {},
+		resolved:          {},
+		spam:              {},
+	},
+	hold: {
+		needsFirstResponse: {guard: requireNotReplied},
+		needsNextResponse:  {guard: requireReplied},
+		waitingOnCustomer:  {guard: requireReplied},
+		resolved:           {},
+		spam:               {},
+	},
+	resolved: {
+		needsFirstResponse: {guard: requireNotReplied},
+		needsNextResponse:  {guard: requireReplied},
+		spam:               {},
+	},
+	spam: {
+		needsFirstResponse: {guard: requireNotReplied},
+		needsNextResponse:  {guard: requireReplied},
+		resolved:           {},
+	},
+}
+
+// ThreadFollowUpStatus represents the status of the follow-up step taken on the Thread.
+type ThreadFollowUpStatus struct{}
+
+func (s ThreadFollowUpStatus) FollowedUp() string {
+	return "followed_up"
+}
+
+func (s ThreadFollowUpStatus) Resolved() string {
+	return "resolved"
+}
+
+func (s ThreadFollowUpStatus) Cancelled() string {
+	return "cancelled"
+}
+
+// ThreadFollowUp records the follow-up steps taken on the Thread waiting on the Customer.
+// Tracks the latest waiting cycle, cancelled if the Customer replies.
+type ThreadFollowUp struct {
+	ThreadId     string     `json:"threadId"`
+	WorkspaceId  string     `json:"workspaceId"`
+	Status       string     `json:"status"`
+	MessageId    *string    `json:"messageId"`
+	FollowedUpAt time.Time  `json:"followedUpAt"`
+	ResolvedAt   *time.Time `json:"resolvedAt"`
+	CancelledAt  *time.Time `json:"cancelledAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// ThreadParticipantRole represents the role of the Thread participant as addressed in the message.
+type ThreadParticipantRole struct{}
+
+func (r ThreadParticipantRole) To() string {
+	return "to"
+}
+
+func (r ThreadParticipantRole) Cc() string {
+	return "cc"
+}
+
+// Bcc is only for the message participant, never kept as the Thread participant.
+func (r ThreadParticipantRole) Bcc() string {
+	return "bcc"
+}
+
+func (r ThreadParticipantRole) IsValid(role string) bool {
+	switch role {
+	case r.To(), r.Cc():
+		return true
+	default:
+		return false
+	}
+}
+
+// ThreadParticipant is the additional participant of the email Thread other than the Thread's Customer,
+// e.g. the Customer's colleague in CC. The participant is the workspace Customer if exists with the email,
+// otherwise the external address.
+type ThreadParticipant struct {
+	ThreadId   string
+	Email      string
+	Name       string
+	Role       string
+	CustomerId *string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// NewThreadParticipant returns the participant of the Thread with the address and the role.
+// The email is lower cased, participants are matched by the email case-insensitively.
+func NewThreadParticipant(threadId string, addr MailAddress, role string) ThreadParticipant {
+	now := time.Now().UTC()
+	return ThreadParticipant{
+		ThreadId:  threadId,
+		Email:     strings.ToLower(strings.TrimSpace(addr.Email)),
+		Name:      addr.Name,
+		Role:      role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Address returns the participant's mail address.
+func (p ThreadParticipant) Address() MailAddress {
+	return MailAddress{Name: p.Name, Email: p.Email}
+}
+
+// ThreadForward is the Thread's messages forwarded by the Member to the external address, e.g. a vendor,
+// without adding the address as the Thread participant. Either the selected messages or the whole transcript
+// is forwarded. Replies from the forwarded address are added to the Thread as the internal notes if tracked.
+type ThreadForward struct {
+	ForwardId     string
+	WorkspaceId   string
+	ThreadId      string
+	MessageId     string // Thread timeline entry of the forward
+	MemberId      string
+	ToEmail       string
+	ToName        string
+	MessageIds    []string // forwarded messages, empty for the transcript
+	IsTranscript  bool
+	Note          string // Member's note to the forwarded address
+	TrackReplies  bool
+	MailMessageId string // `Message-ID` header of the forwarded mail
+	ReplyCount    int
+	LastRepliedAt *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (f *ThreadForward) GenId() string {
+	return "fwd" + xid.New().String()
+}
+
+// NewThreadForward returns the forward of the Thread's messages by the Member,
+// the transcript is forwarded without the selected messages.
+func NewThreadForward(
+	thread Thread, member Member, to MailAddress, messageIds []string, note string, trackReplies bool,
+) ThreadForward {
+	now := time.Now().UTC()
+	return ThreadForward{
+		ForwardId:    (&ThreadForward{}).GenId(),
+		WorkspaceId:  thread.WorkspaceId,
+		ThreadId:     thread.ThreadId,
+		MemberId:     member.MemberId,
+		ToEmail:      to.Email,
+		ToName:       to.Name,
+		MessageIds:   messageIds,
+		IsTranscript: len(messageIds) == 0,
+		Note:         note,
+		TrackReplies: trackReplies,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// To returns the forwarded mail address.
+func (f ThreadForward) To() MailAddress {
+	return MailAddress{Name: f.ToName, Email: f.ToEmail}
+}
+
+// OnReply tracks the reply received from the forwarded address.
+func (f *ThreadForward) OnReply() {
+	now := time.Now().UTC()
+	f.ReplyCount++
+	f.LastRepliedAt = &now
+	f.UpdatedAt = now
+}
+
+// Summary returns the Thread timeline text of the forward.
+func (f ThreadForward) Summary() string {
+	to := f.To().String()
+	switch {
+	case f.IsTranscript:
+		return fmt.Sprintf("Forwarded the conversation to %s", to)
+	case len(f.MessageIds) == 1:
+		return fmt.Sprintf("Forwarded a message to %s", to)
+	default:
+		return fmt.Sprintf("Forwarded %d messages to %s", len(f.MessageIds), to)
+	}
+}
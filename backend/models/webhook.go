@@ -0,0 +1,145 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// WebhookCredentialOverlap is how long the rotated webhook credential is still accepted,
+// for the sources to be updated with the new credential and the in-flight retries.
+const WebhookCredentialOverlap = 24 * time.Hour
+
+// AcceptsLegacyWebhookAuth checks the global webhook credentials of the earlier inbound hooks are still accepted
+// for the workspace of the credentials. Never accepted after the cutover deadline, nor with the zero deadline.
+// Before the deadline accepted for the overlap after the first workspace credential is issued, as for the rotated
+// credential, the workspace credentials are issued by the backfill.
+func AcceptsLegacyWebhookAuth(credentials []WebhookCredential, until time.Time, now time.Time) bool {
+	if until.IsZero() || !now.Before(until) {
+		return false
+	}
+	if len(credentials) == 0 {
+		return true
+	}
+	first := credentials[0].CreatedAt
+	for _, c := range credentials {
+		if c.CreatedAt.Before(first) {
+			first = c.CreatedAt
+		}
+	}
+	return now.Before(first.Add(WebhookCredentialOverlap))
+}
+
+// WebhookCredential is the workspace basic auth credential of the Postmark, raw mail and SMS webhooks.
+// The credential ID is the username, only the hash of the secret is kept and the secret is shown once.
+// Credential of the mailbox only accepts the inbound mail addressed to the mailbox, e.g. of the MTA of the
+// mailbox domain, and is not accepted on the SMS webhooks.
+type WebhookCredential struct {
+	CredentialId string     `json:"credentialId"`
+	WorkspaceId  string     `json:"workspaceId"`
+	MailboxId    *string    `json:"mailboxId"` // nil for the workspace
+	SecretHash   string     `json:"-"`
+	SecretHint   string     `json:"secretHint"` // last chars of the secret
+	ExpiresAt    *time.Time `json:"expiresAt"`  // not expired until rotated or revoked
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (c WebhookCredential) GenId() string {
+	return "whc" + xid.New().String()
+}
+
+// NewWebhookCredential returns the new credential of the workspace or of the workspace mailbox
+// with the generated secret.
+func NewWebhookCredential(workspaceId string, mailboxId *string) (WebhookCredential, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return WebhookCredential{}, "", err
+	}
+	secret := "whs" + base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now().UTC()
+	credential := WebhookCredential{
+		WorkspaceId: workspaceId,
+		MailboxId:   mailboxId,
+		SecretHash:  HashWebhookSecret(secret),
+		SecretHint:  secret[len(secret)-4:],
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	credential.CredentialId = credential.GenId()
+	return credential, secret, nil
+}
+
+// HashWebhookSecret returns the hex encoded SHA-256 of the secret.
+// Secrets are random with enough entropy, not requiring a slow hash.
+func HashWebhookSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsExpired checks if the credential is expired as of now.
+func (c WebhookCredential) IsExpired(now time.Time) bool {
+	return c.ExpiresAt != nil && !now.Before(*c.ExpiresAt)
+}
+
+// IsMailboxScoped checks the credential is of the workspace mailbox, not of the workspace.
+func (c WebhookCredential) IsMailboxScoped() bool {
+	return c.MailboxId != nil
+}
+
+// AllowsMailbox checks the inbound mail of the mailbox is accepted with the credential.
+func (c WebhookCredential) AllowsMailbox(mailboxId string) bool {
+	return c.MailboxId == nil || *c.MailboxId == mailboxId
+}
+
+// Verify checks the secret matches the credential and the credential is not expired.
+func (c WebhookCredential) Verify(secret string, now time.Time) bool {
+	if c.IsExpired(now) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashWebhookSecret(secret)), []byte(c.SecretHash)) == 1
+}
+
+// HookURL returns the webhook URL of the path with the credential and the secret as basic auth,
+// e.g. as configured in the Postmark inbound hook URL.
+func (c WebhookCredential) HookURL(serverUrl string, secret string, path string) (string, error) {
+	u, err := url.Parse(serverUrl)
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword(c.CredentialId, secret)
+	u.Path = path
+	return u.String(), nil
+}
+
+// PostmarkInboundHookPath returns the path of the workspace Postmark inbound webhook.
+func PostmarkInboundHookPath(workspaceId string) string {
+	return fmt.Sprintf("/webhooks/%s/postmark/inbound/", workspaceId)
+}
+
+// RawMailInboundHookPath returns the path of the workspace raw inbound mail webhook.
+func RawMailInboundHookPath(workspaceId string) string {
+	return fmt.Sprintf("/webhooks/%s/mail/inbound/raw/", workspaceId)
+}
+
+// PostmarkStatusHookPath returns the path of the workspace Postmark delivery status webhook.
+func PostmarkStatusHookPath(workspaceId string) string {
+	return fmt.Sprintf("/webhooks/%s/postmark/status/", workspaceId)
+}
+
+// SMSInboundHookPath returns the path of the workspace SMS inbound webhook.
+func SMSInboundHookPath(workspaceId string) string {
+	return fmt.Sprintf("/webhooks/%s/sms/inbound/", workspaceId)
+}
+
+// SMSStatusHookPath returns the path of the workspace SMS delivery status webhook.
+func SMSStatusHookPath(workspaceId string) string {
+	return fmt.Sprintf("/webhooks/%s/sms/status/", workspaceId)
+}
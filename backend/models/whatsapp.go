@@ -0,0 +1,260 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WhatsAppSessionWindow is the customer service window opened by the Customer's message.
+// Within the window free-form replies can be sent, outside it only template messages.
+const WhatsAppSessionWindow = 24 * time.Hour
+
+// WhatsAppSetting is the workspace WhatsApp Business phone number on the Cloud API.
+type WhatsAppSetting struct {
+	WorkspaceId        string    `json:"workspaceId"`
+	IsEnabled          bool      `json:"isEnabled"`
+	PhoneNumberId      string    `json:"phoneNumberId"`
+	BusinessAccountId  string    `json:"businessAccountId"`
+	DisplayPhoneNumber string    `json:"displayPhoneNumber"`
+	AccessToken        string    `json:"-"`
+	AppSecret          string    `json:"-"`           // verifies the webhook payload signature
+	VerifyToken        string    `json:"verifyToken"` // verifies the webhook subscription
+	APIBaseURL         string    `json:"apiBaseUrl"`  // empty for the Cloud API, set for the local stand-in
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
+
+// NewWhatsAppSetting returns the default disabled WhatsApp setting for the workspace.
+func NewWhatsAppSetting(workspaceId string) WhatsAppSetting {
+	now := time.Now().UTC()
+	return WhatsAppSetting{
+		WorkspaceId: workspaceId,
+		IsEnabled:   false,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func (s WhatsAppSetting) Validate() error {
+	if !s.IsEnabled {
+		return nil
+	}
+	if s.PhoneNumberId == "" || s.AccessToken == "" {
+		return errors.New("phone number id and access token are required")
+	}
+	if s.VerifyToken == "" || s.AppSecret == "" {
+		return errors.New("verify token and app secret are required")
+	}
+	return nil
+}
+
+// IsWhatsAppSessionOpen checks if the customer service window is open as of the Customer's last inbound message.
+func IsWhatsAppSessionOpen(lastInboundAt time.Time, now time.Time) bool {
+	if lastInboundAt.IsZero() {
+		return false
+	}
+	return now.Sub(lastInboundAt) < WhatsAppSessionWindow
+}
+
+// WhatsAppPhone returns the E.164 phone of the WhatsApp ID.
+func WhatsAppPhone(waId string) string {
+	return "+" + strings.TrimPrefix(waId, "+")
+}
+
+// WhatsAppId returns the WhatsApp ID of the E.164 phone.
+func WhatsAppId(phone string) string {
+	return strings.TrimPrefix(phone, "+")
+}
+
+// WhatsAppMessageKind represents the kind of the WhatsApp message.
+type WhatsAppMessageKind struct{}
+
+func (k WhatsAppMessageKind) Text() string {
+	return "text"
+}
+
+func (k WhatsAppMessageKind) Image() string {
+	return "image"
+}
+
+func (k WhatsAppMessageKind) Video() string {
+	return "video"
+}
+
+func (k WhatsAppMessageKind) Audio() string {
+	return "audio"
+}
+
+func (k WhatsAppMessageKind) Document() string {
+	return "document"
+}
+
+func (k WhatsAppMessageKind) Sticker() string {
+	return "sticker"
+}
+
+func (k WhatsAppMessageKind) Template() string {
+	return "template"
+}
+
+// IsMedia checks if the message kind carries media.
+func (k WhatsAppMessageKind) IsMedia(s string) bool {
+	switch s {
+	case k.Image(), k.Video(), k.Audio(), k.Document(), k.Sticker():
+		return true
+	default:
+		return false
+	}
+}
+
+// WhatsAppStatus represents the status of the WhatsApp message.
+type WhatsAppStatus struct{}
+
+func (s WhatsAppStatus) Received() string {
+	return "received"
+}
+
+func (s WhatsAppStatus) Accepted() string {
+	return "accepted"
+}
+
+func (s WhatsAppStatus) Sent() string {
+	return "sent"
+}
+
+func (s WhatsAppStatus) Delivered() string {
+	return "delivered"
+}
+
+func (s WhatsAppStatus) Read() string {
+	return "read"
+}
+
+func (s WhatsAppStatus) Failed() string {
+	return "failed"
+}
+
+// Precedes returns the statuses that can move to the status.
+// Status webhooks are not ordered, the status never moves back.
+func (s WhatsAppStatus) Precedes(status string) []string {
+	switch status {
+	case s.Sent():
+		return []string{s.Accepted()}
+	case s.Delivered():
+		return []string{s.Accepted(), s.Sent()}
+	case s.Read():
+		return []string{s.Accepted(), s.Sent(), s.Delivered()}
+	case s.Failed():
+		return []string{s.Accepted(), s.Sent()}
+	default:
+		return []string{}
+	}
+}
+
+// WhatsAppMessageLog tracks the WhatsApp message of the inbound or outbound Message.
+type WhatsAppMessageLog struct {
+	MessageId   string                 `json:"messageId"`
+	WAMessageId string                 `json:"waMessageId"`
+	WaId        string                 `json:"waId"`        // WhatsApp ID of the Customer
+	MessageType string                 `json:"messageType"` // inbound or outbound
+	Kind        string                 `json:"kind"`
+	Status      string                 `json:"status"`
+	Reaction    *string                `json:"reaction"` // Customer's reaction emoji to the message
+	ErrorCode   *string                `json:"errorCode"`
+	ErrorTitle  *string                `json:"errorTitle"`
+	Payload     map[string]interface{} `json:"-"`
+	CreatedAt   time.Time              `json:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt"`
+}
+
+// WhatsAppMedia is the media of the inbound WhatsApp message, downloaded by the media ID.
+type WhatsAppMedia struct {
+	MediaId  string
+	MimeType string
+	Filename string
+	Caption  string
+}
+
+// WhatsAppInbound is the inbound message received from the WhatsApp webhook.
+type WhatsAppInbound struct {
+	WAMessageId   string
+	FromWaId      string
+	ProfileName   string
+	PhoneNumberId string
+	Kind          string
+	Body          string
+	Media         *WhatsAppMedia
+	Timestamp     time.Time
+	Payload       map[string]interface{}
+
+	// Spam verdict of the inbound message as per the spam pipeline.
+	Spam SpamVerdict
+}
+
+// TextBody returns the message text, for media the caption or the media kind.
+func (m WhatsAppInbound) TextBody() string {
+	if m.Body != "" {
+		return m.Body
+	}
+	if m.Media != nil && m.Media.Caption != "" {
+		return m.Media.Caption
+	}
+	if m.Media != nil {
+		return fmt.Sprintf("[%s]", m.Kind)
+	}
+	return ""
+}
+
+// ToWhatsAppMessageLog returns the inbound message log for the Message.
+func (m WhatsAppInbound) ToWhatsAppMessageLog(messageId string) WhatsAppMessageLog {
+	now := time.Now().UTC()
+	return WhatsAppMessageLog{
+		MessageId:   messageId,
+		WAMessageId: m.WAMessageId,
+		WaId:        m.FromWaId,
+		MessageType: "inbound",
+		Kind:        m.Kind,
+		Status:      WhatsAppStatus{}.Received(),
+		Payload:     m.Payload,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// WhatsAppReaction is the Customer's reaction to the message, empty emoji removes the reaction.
+type WhatsAppReaction struct {
+	FromWaId        string
+	TargetMessageId string // WhatsApp message ID reacted to
+	Emoji           string
+}
+
+// WhatsAppStatusUpdate is the status reported by the webhook for the sent message.
+type WhatsAppStatusUpdate struct {
+	WAMessageId string
+	Status      string
+	ErrorCode   *string
+	ErrorTitle  *string
+}
+
+// WhatsAppTemplate is the pre-approved template message sent outside the session window.
+type WhatsAppTemplate struct {
+	Name     string   `json:"name"`
+	Language string   `json:"language"`
+	Params   []string `json:"params"` // body parameters in order
+}
+
+// Text returns the template as the message text.
+func (t WhatsAppTemplate) Text() string {
+	if len(t.Params) == 0 {
+		return fmt.Sprintf("[template: %s]", t.Name)
+	}
+	return fmt.Sprintf("[template: %s] %s", t.Name, strings.Join(t.Params, " | "))
+}
+
+// WhatsAppSendResult is the Cloud API response for the sent message.
+type WhatsAppSendResult struct {
+	WAMessageId string
+	Payload     map[string]interface{}
+}
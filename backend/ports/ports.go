@@ -105,6 +105,16 @@ type WorkspaceServicer interface {
 		ctx context.Context, workspaceId, email, domain string) (models.PostmarkMailServerSetting, error)
 	GetPostmarkMailServerSetting(
 		ctx context.Context, workspaceId string) (models.PostmarkMailServerSetting, error)
+	ListWebhookCredentials(
+		ctx context.Context, workspaceId string) ([]models.WebhookCredential, error)
+	RotateWebhookCredential(
+		ctx context.Context, workspaceId string, mailboxId *string) (models.WebhookCredential, string, error)
+	RevokeWebhookCredential(
+		ctx context.Context, workspaceId string, credentialId string) (models.WebhookCredential, error)
+	VerifyLegacyWebhookAuth(ctx context.Context, workspaceId string) error
+	BackfillWebhookCredentials(ctx context.Context) (int, error)
+	VerifyWebhookCredential(
+		ctx context.Context, workspaceId string, credentialId string, secret string) (models.WebhookCredential, error)
 	PostmarkMailServerAddDomain(
 		ctx context.Context, setting models.PostmarkMailServerSetting, domain string,
 	) (models.PostmarkMailServerSetting, bool, error)
@@ -113,6 +123,10 @@ type WorkspaceServicer interface {
 	PostmarkMailServerUpdate(
 		ctx context.Context, setting models.PostmarkMailServerSetting, fields []string,
 	) (models.PostmarkMailServerSetting, error)
+	GetThreadFollowUpSetting(
+		ctx context.Context, workspaceId string) (models.ThreadFollowUpSetting, error)
+	SaveThreadFollowUpSetting(
+		ctx context.Context, setting models.ThreadFollowUpSetting) (models.ThreadFollowUpSetting, error)
 }
 
 type CustomerServicer interface {
@@ -122,6 +136,7 @@ type CustomerServicer interface {
 	VerifyEmail(sk string, hash string, email string) bool
 	VerifyPhone(sk string, hash string, phone string) bool
 	UpdateCustomer(ctx context.Context, customer models.Customer) (models.Customer, error)
+	UpdateCustomerEmailBounce(ctx context.Context, customer models.Customer) (models.Customer, error)
 	AddClaimedMail(
 		ctx context.Context, claimed models.ClaimedMail) (models.ClaimedMail, error)
 	RemoveCustomerClaimedMail(
@@ -143,37 +158,72 @@ type CustomerServicer interface {
 	ListEvents(ctx context.Context, customerId string) ([]models.Event, error)
 }
 
-type ThreadServicer interface {
-	CreateInboundThreadChat(
-		ctx context.Context, workspaceId string,
-		customer models.Customer, createdBy models.MemberActor, messageText string,
-	) (models.Thread, models.Message, error)
-	AppendInboundThreadChat(
-		ctx context.Context, thread models.Thread, messageText string) (models.Message, error)
-
-	AppendOutboundThreadChat(
-		ctx context.Context, thread models.Thread, member models.Member, message string) (models.Message, error)
-
-	GetRecentThreadMailMessageId(
-		ctx context.Context, threadId string) (string, error)
-
-	SendThreadMailReply(
-		ctx context.Context,
-		workspace models.Workspace, setting models.PostmarkMailServerSetting, thread models.Thread,
-		member models.Member, customer models.Customer,
-		textBody, htmlBody string,
-	) (models.Message, error)
-
-	IsPostmarkInboundMessageProcessed(ctx context.Context, messageId string) (bool, error)
+// ChannelAdapter plugs the channel into the Thread message pipeline.
+// The adapter normalizes the channel's inbound message, resolves the Customer and the Thread it belongs to,
+// delivers the outbound message and parses the provider's delivery status callbacks.
+// Messages with the provider's message ID are logged in the generic channel message log.
+type ChannelAdapter interface {
+	Channel() string
+	NormalizeInbound(
+		ctx context.Context, workspaceId string, payload map[string]interface{}) (models.ChannelInbound, error)
+	ResolveCustomer(
+		ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound) (models.Customer, error)
+	// MatchThread returns the existing Thread the inbound message belongs to, nil to start a new Thread.
+	MatchThread(
+		ctx context.Context, workspaceId string, customer models.Customer, inbound models.ChannelInbound,
+	) (*models.Thread, error)
+	// InboundParticipants returns the participants of the inbound message to be kept with the Thread,
+	// other than the Thread's Customer and the workspace's own addresses.
+	InboundParticipants(
+		ctx context.Context, workspaceId string, thread models.Thread, inbound models.ChannelInbound,
+	) ([]models.ThreadParticipant, error)
+	// Deliver delivers the outbound message, returns the message log if tracked with the provider.
+	Deliver(ctx context.Context, delivery models.ChannelDelivery) (*models.ChannelMessageLog, error)
+	ParseDeliveryStatus(
+		ctx context.Context, payload map[string]interface{}) (models.ChannelDeliveryStatus, error)
+	// OnDeliveryStatus applies the updated delivery status of the outbound message to the Customer and the Thread.
+	OnDeliveryStatus(
+		ctx context.Context, workspaceId string, status models.ChannelDeliveryStatus,
+		messageLog models.ChannelMessageLog,
+	) error
+}
 
-	ProcessPostmarkInbound(
-		ctx context.Context, workspaceId string,
-		customer models.CustomerActor, createdBy models.MemberActor, inboundMessage *models.PostmarkInboundMessage,
+type ChannelServicer interface {
+	NormalizeInbound(
+		ctx context.Context, channel string, workspaceId string, payload map[string]interface{},
+	) (models.ChannelInbound, error)
+	IsInboundProcessed(ctx context.Context, channel string, externalId string) (bool, error)
+	ResolveCustomer(
+		ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound) (models.Customer, error)
+	ProcessInbound(
+		ctx context.Context, workspaceId string, customer models.Customer, createdBy models.MemberActor,
+		inbound models.ChannelInbound,
 	) (models.Thread, models.Message, error)
+	ResumeInboundAttachments(ctx context.Context, workspaceId string, inbound models.ChannelInbound) error
+	SendReply(
+		ctx context.Context, workspace models.Workspace, thread models.Thread,
+		member models.Member, customer models.Customer, reply models.ChannelReply,
+	) (models.Message, error)
+	UpdateDeliveryStatus(
+		ctx context.Context, workspaceId string, channel string, payload map[string]interface{},
+	) (models.ChannelMessageLog, error)
+	ClaimOutboundMails(ctx context.Context, limit int) ([]models.OutboundMail, error)
+	OutboundMailQuota(ctx context.Context, workspaceId string) (int, error)
+	DeferOutboundMail(
+		ctx context.Context, mail models.OutboundMail, until time.Time) (models.OutboundMail, error)
+	DeliverOutboundMail(
+		ctx context.Context, workspace models.Workspace, thread models.Thread,
+		member models.Member, customer models.Customer, mail models.OutboundMail,
+	) (models.OutboundMail, error)
+	FailOutboundMail(
+		ctx context.Context, mail models.OutboundMail, err error, retry bool) (models.OutboundMail, error)
+	ListOutboundMails(
+		ctx context.Context, workspaceId string, status string) ([]models.OutboundMail, error)
+	RetryOutboundMail(
+		ctx context.Context, workspaceId string, messageId string) (models.OutboundMail, error)
+}
 
-	GetPostmarkInReplyThread(
-		ctx context.Context, workspaceId, mailMessageId string) (*models.Thread, error)
-
+type ThreadServicer interface {
 	GetWorkspaceThread(
 		ctx context.Context, workspaceId string, threadId string, channel *string) (models.Thread, error)
 	UpdateThread(
@@ -204,13 +254,281 @@ type ThreadServicer interface {
 		ctx context.Context, threadId string) ([]models.Message, error)
 	ListThreadMessagesWithAttachments(
 		ctx context.Context, threadId string) ([]models.MessageWithAttachments, error)
+	GetLatestThreadMessage(ctx context.Context, threadId string) (*models.Message, error)
+	ListThreadParticipants(ctx context.Context, threadId string) ([]models.ThreadParticipant, error)
 
 	GetMessageAttachment(
 		ctx context.Context, messageId, attachmentId string) (models.MessageAttachment, error)
+	// UploadReplyAttachment stores the Member's upload for the reply, linked to the reply message when sent.
+	UploadReplyAttachment(
+		ctx context.Context, upload models.AttachmentUpload, content []byte) (models.AttachmentUpload, error)
 
 	GenerateMemberThreadMetrics(
 		ctx context.Context, workspaceId string, memberId string) (models.ThreadMemberMetrics, error)
 
 	LogPostmarkInboundRequest(
 		ctx context.Context, workspaceId, messageId string, payload map[string]interface{}) error
+
+	ClaimThreadsDueForFollowUp(ctx context.Context, limit int) ([]models.Thread, error)
+	ClaimThreadsDueForResolve(ctx context.Context, limit int) ([]models.Thread, error)
+	RecordThreadFollowUp(
+		ctx context.Context, thread models.Thread, messageId *string) (models.ThreadFollowUp, error)
+	AutoResolveThread(
+		ctx context.Context, thread models.Thread, member models.MemberActor) (models.Thread, error)
+}
+
+type ThreadForwardServicer interface {
+	ForwardThread(
+		ctx context.Context, workspace models.Workspace, thread models.Thread, member models.Member,
+		forward models.ThreadForward,
+	) (models.ThreadForward, models.Message, error)
+	ListThreadForwards(ctx context.Context, threadId string) ([]models.ThreadForward, error)
+	// ProcessForwardReply appends the inbound mail replying to the forward as the Thread's internal note.
+	ProcessForwardReply(
+		ctx context.Context, workspaceId string, inbound models.ChannelInbound,
+	) (models.Thread, models.Message, error)
+}
+
+type CustomerMailServicer interface {
+	OnThreadResolved(ctx context.Context, thread models.Thread)
+	SendThreadTranscript(ctx context.Context, thread models.Thread) error
+	NotifyThreadReply(ctx context.Context, thread models.Thread, message models.Message)
+}
+
+// SpamScorer scores the inbound message as a step of the spam pipeline.
+// Scorers are pluggable, the pipeline totals the signals against the workspace threshold.
+type SpamScorer interface {
+	Name() string
+	Score(ctx context.Context, setting models.SpamSetting, candidate models.SpamCandidate) (models.SpamSignal, error)
+}
+
+type SpamServicer interface {
+	CheckInbound(ctx context.Context, candidate models.SpamCandidate) (models.SpamVerdict, error)
+	GetSpamSetting(ctx context.Context, workspaceId string) (models.SpamSetting, error)
+	SaveSpamSetting(ctx context.Context, setting models.SpamSetting) (models.SpamSetting, error)
+	ListSenderRules(ctx context.Context, workspaceId string) ([]models.SpamSenderRule, error)
+	AddSenderRule(ctx context.Context, rule models.SpamSenderRule) (models.SpamSenderRule, error)
+	RemoveSenderRule(ctx context.Context, workspaceId, ruleId string) error
+}
+
+type BlocklistServicer interface {
+	CheckSender(ctx context.Context, workspaceId, email, ip string) (models.SenderBlockVerdict, error)
+	ListSenderBlocks(ctx context.Context, workspaceId string) ([]models.SenderBlock, error)
+	BlockSender(ctx context.Context, block models.SenderBlock) (models.SenderBlock, error)
+	UnblockSender(
+		ctx context.Context, workspaceId, blockId string, member models.MemberActor, reason *string) error
+	BlockCustomer(
+		ctx context.Context, customer models.Customer, member models.MemberActor, reason *string,
+	) (models.Customer, error)
+	UnblockCustomer(
+		ctx context.Context, customer models.Customer, member models.MemberActor, reason *string,
+	) (models.Customer, error)
+	ListBlockAudits(ctx context.Context, workspaceId string) ([]models.BlockAudit, error)
+}
+
+// SMSProvider sends the outbound SMS through the provider account of the workspace SMS setting.
+// Providers are selected by name as per the workspace SMS setting.
+type SMSProvider interface {
+	Name() string
+	Send(ctx context.Context, setting models.SMSSetting, sms models.OutboundSMS) (models.SMSSendResult, error)
+}
+
+type SMSServicer interface {
+	GetSMSSetting(ctx context.Context, workspaceId string) (models.SMSSetting, error)
+	SaveSMSSetting(ctx context.Context, setting models.SMSSetting) (models.SMSSetting, error)
+	IsInboundSMSProcessed(ctx context.Context, provider, providerMessageId string) (bool, error)
+	ProcessInboundSMS(
+		ctx context.Context, setting models.SMSSetting,
+		customer models.CustomerActor, createdBy models.MemberActor, inbound *models.InboundSMS,
+	) (models.Thread, models.Message, error)
+	SendThreadSMSReply(
+		ctx context.Context, setting models.SMSSetting, thread models.Thread,
+		member models.Member, customer models.Customer, textBody string,
+	) (models.Message, error)
+	UpdateSMSDeliveryStatus(
+		ctx context.Context, setting models.SMSSetting, update models.SMSStatusUpdate) (models.SMSMessageLog, error)
+	ListThreadSMSLogs(ctx context.Context, threadId string) ([]models.SMSMessageLog, error)
+}
+
+// WhatsAppClient sends messages and downloads media with the WhatsApp Cloud API
+// of the workspace WhatsApp setting.
+type WhatsAppClient interface {
+	SendText(
+		ctx context.Context, setting models.WhatsAppSetting, to, body string) (models.WhatsAppSendResult, error)
+	SendTemplate(
+		ctx context.Context, setting models.WhatsAppSetting, to string, template models.WhatsAppTemplate,
+	) (models.WhatsAppSendResult, error)
+	DownloadMedia(ctx context.Context, setting models.WhatsAppSetting, mediaId string) ([]byte, string, error)
+}
+
+type WhatsAppServicer interface {
+	GetWhatsAppSetting(ctx context.Context, workspaceId string) (models.WhatsAppSetting, error)
+	SaveWhatsAppSetting(ctx context.Context, setting models.WhatsAppSetting) (models.WhatsAppSetting, error)
+	IsInboundWhatsAppProcessed(ctx context.Context, waMessageId string) (bool, error)
+	ProcessInboundWhatsApp(
+		ctx context.Context, setting models.WhatsAppSetting,
+		customer models.CustomerActor, createdBy models.MemberActor, inbound *models.WhatsAppInbound,
+	) (models.Thread, models.Message, error)
+	SendThreadWhatsAppReply(
+		ctx context.Context, setting models.WhatsAppSetting, thread models.Thread,
+		member models.Member, customer models.Customer, textBody string, template *models.WhatsAppTemplate,
+	) (models.Message, error)
+	UpdateWhatsAppReaction(ctx context.Context, reaction models.WhatsAppReaction) (models.WhatsAppMessageLog, error)
+	UpdateWhatsAppDeliveryStatus(
+		ctx context.Context, update models.WhatsAppStatusUpdate) (models.WhatsAppMessageLog, error)
+	ListThreadWhatsAppLogs(ctx context.Context, threadId string) ([]models.WhatsAppMessageLog, error)
+}
+
+// APIWebhookSender delivers the API channel events to the workspace webhook of the API setting.
+type APIWebhookSender interface {
+	Deliver(ctx context.Context, setting models.APISetting, event models.APIWebhookEvent) models.APIWebhookResult
+}
+
+type APIServicer interface {
+	GetAPISetting(ctx context.Context, workspaceId string) (models.APISetting, error)
+	SaveAPISetting(ctx context.Context, setting models.APISetting) (models.APISetting, error)
+	CreateAPIThread(
+		ctx context.Context, workspaceId string, customer models.CustomerActor, createdBy models.MemberActor,
+		title, textBody string, attachments []models.APIAttachment,
+	) (models.Thread, models.Message, []models.MessageAttachment, error)
+	AppendAPIThreadMessage(
+		ctx context.Context, thread models.Thread, customer models.CustomerActor, createdBy models.MemberActor,
+		textBody string, attachments []models.APIAttachment,
+	) (models.Message, []models.MessageAttachment, error)
+	SendThreadAPIReply(
+		ctx context.Context, setting models.APISetting, thread models.Thread,
+		member models.Member, customer models.Customer, textBody string,
+	) (models.Message, models.APIMessageLog, error)
+	ListThreadAPILogs(ctx context.Context, threadId string) ([]models.APIMessageLog, error)
+}
+
+// SlackClient calls the Slack Web API with the bot token of the installed Slack app.
+type SlackClient interface {
+	OAuthAccess(
+		ctx context.Context, clientId, clientSecret, code, redirectUrl string) (models.SlackOAuthAccess, error)
+	AuthTest(ctx context.Context, token string) (string, string, error)
+	ListChannels(ctx context.Context, token, slackWorkspaceRef string) ([]models.SlackChannel, error)
+	UserInfo(ctx context.Context, token, userRef string) (models.SlackUser, error)
+	PostMessage(ctx context.Context, token, channelRef, text, threadTs string) (string, error)
+}
+
+type SlackServicer interface {
+	GenerateOAuthState(workspaceId, redirectUrl string) (string, error)
+	VerifyOAuthState(state string) (models.SlackOAuthStateClaims, error)
+	InstallSlackApp(ctx context.Context, workspaceId, code string) (models.SlackWorkspace, error)
+	GetSlackWorkspace(ctx context.Context, workspaceId string) (models.SlackWorkspace, error)
+	GetSlackWorkspaceByRef(ctx context.Context, ref string) (models.SlackWorkspace, error)
+	SyncSlackChannels(ctx context.Context, slackWorkspace models.SlackWorkspace) ([]models.SlackChannel, error)
+	ListSlackWorkspacesDueForSync(ctx context.Context, syncedBefore time.Time) ([]models.SlackWorkspace, error)
+	ListSlackChannels(ctx context.Context, slackWorkspace models.SlackWorkspace) ([]models.SlackChannel, error)
+	UpdateSlackChannelMapping(
+		ctx context.Context, slackWorkspace models.SlackWorkspace, channelId string, isEnabled bool, labelId *string,
+	) (models.SlackChannel, error)
+	GetSlackChannel(
+		ctx context.Context, slackWorkspace models.SlackWorkspace, channelRef string) (models.SlackChannel, error)
+	GetSlackUser(ctx context.Context, slackWorkspace models.SlackWorkspace, userRef string) (models.SlackUser, error)
+	IsSlackMessageProcessed(ctx context.Context, slackWorkspaceRef, channelRef, ts string) (bool, error)
+	ReceiveInboundSlackMessage(ctx context.Context, inbound *models.SlackInbound) error
+	ProcessInboundSlackMessage(
+		ctx context.Context, slackWorkspace models.SlackWorkspace, channel models.SlackChannel,
+		customer models.CustomerActor, createdBy models.MemberActor, inbound *models.SlackInbound,
+	) (models.Thread, models.Message, error)
+	SendThreadSlackReply(
+		ctx context.Context, slackWorkspace models.SlackWorkspace, thread models.Thread,
+		member models.Member, textBody string,
+	) (models.Message, error)
+}
+
+// MailSender sends the outbound mail through the sender account of the mail setting.
+// Senders are selected by name as per the workspace mail setting or the deployment default.
+type MailSender interface {
+	Name() string
+	Send(ctx context.Context, setting models.MailSetting, mail models.Mail) (models.MailSendResult, error)
+}
+
+type MailServicer interface {
+	GetMailSetting(ctx context.Context, workspaceId string) (models.MailSetting, error)
+	SaveMailSetting(ctx context.Context, setting models.MailSetting) (models.MailSetting, error)
+	// SendWorkspaceMail sends the mail with the workspace mail sender, from the workspace from address
+	// unless set in the mail.
+	SendWorkspaceMail(ctx context.Context, workspaceId string, mail models.Mail) (models.MailSendResult, error)
+	// SendSystemMail sends the mail with the deployment mail sender, not as any of the workspaces.
+	SendSystemMail(ctx context.Context, mail models.Mail) (models.MailSendResult, error)
+	GetMailReplySetting(ctx context.Context, workspaceId string) (models.MailReplySetting, error)
+	SaveMailReplySetting(ctx context.Context, setting models.MailReplySetting) (models.MailReplySetting, error)
+	GetCustomerMailSetting(ctx context.Context, workspaceId string) (models.CustomerMailSetting, error)
+	SaveCustomerMailSetting(
+		ctx context.Context, setting models.CustomerMailSetting) (models.CustomerMailSetting, error)
+	GetMailSignature(ctx context.Context, workspaceId string, memberId string) (models.MailSignature, error)
+	SaveMailSignature(ctx context.Context, signature models.MailSignature) (models.MailSignature, error)
+	// ComposeReplyMail renders the Member's reply as the final mail sent to the Customer.
+	ComposeReplyMail(
+		ctx context.Context, workspace models.Workspace, thread models.Thread, member models.Member,
+		customer models.Customer, reply models.Message, quote *models.Message,
+	) (models.Mail, error)
+	// ComposeForwardMail renders the Member's forward of the Thread's messages to the forwarded address.
+	ComposeForwardMail(
+		ctx context.Context, workspace models.Workspace, thread models.Thread, member models.Member,
+		forward models.ThreadForward, messages []models.MessageWithAttachments,
+	) (models.Mail, error)
+	ListMailboxes(ctx context.Context, workspaceId string) ([]models.Mailbox, error)
+	GetMailbox(ctx context.Context, workspaceId string, mailboxId string) (models.Mailbox, error)
+	SaveMailbox(ctx context.Context, mailbox models.Mailbox) (models.Mailbox, error)
+	DeleteMailbox(ctx context.Context, workspaceId string, mailboxId string) error
+	// GetThreadMailbox returns the mailbox the Thread was routed to, replies go out from the mailbox.
+	GetThreadMailbox(ctx context.Context, threadId string) (models.Mailbox, error)
+	MailboxAddDomain(ctx context.Context, mailbox models.Mailbox) (models.Mailbox, bool, error)
+	MailboxVerifyDomain(ctx context.Context, mailbox models.Mailbox) (models.Mailbox, error)
+	ListMailTemplates(ctx context.Context, workspaceId string) ([]models.MailTemplate, error)
+	GetMailTemplate(ctx context.Context, workspaceId string, kind string, locale string) (models.MailTemplate, error)
+	SaveMailTemplate(ctx context.Context, tmpl models.MailTemplate) (models.MailTemplate, error)
+	DeleteMailTemplate(ctx context.Context, workspaceId string, kind string, locale string) error
+	// ResolveMailTemplate returns the template of the kind for the Customer's locale, falls back to the
+	// language of the locale then the default locale.
+	ResolveMailTemplate(
+		ctx context.Context, workspaceId string, kind string, locale string) (models.MailTemplate, error)
+	// ComposeTemplateMail renders the transactional mail with the workspace branding, without the recipient.
+	ComposeTemplateMail(
+		ctx context.Context, workspace models.Workspace, tmpl models.MailTemplate, data models.MailTemplateData,
+	) (models.Mail, error)
+	// SendTemplateMail sends the transactional mail of the kind in the Customer's locale with the workspace
+	// mail sender.
+	SendTemplateMail(
+		ctx context.Context, workspaceId string, kind string, locale string, to string, data models.MailTemplateData,
+	) (models.MailSendResult, error)
+}
+
+// IMAPFetcher fetches the new mail from the IMAP mailbox of the workspace IMAP setting.
+type IMAPFetcher interface {
+	FetchNew(ctx context.Context, setting models.IMAPSetting, limit int) (models.IMAPFetchResult, error)
+}
+
+type MailInboundServicer interface {
+	ProcessMailInbound(
+		ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound,
+	) (models.Thread, models.Message, error)
+	ProcessRawMail(
+		ctx context.Context, workspace models.Workspace, source string, raw []byte,
+	) (models.Thread, models.Message, error)
+	ReceiveRawMail(
+		ctx context.Context, workspace models.Workspace, credential *models.WebhookCredential, raw []byte,
+	) (models.Thread, models.Message, error)
+	CheckInboundRecipient(
+		ctx context.Context, workspaceId string, credential *models.WebhookCredential, inbound models.ChannelInbound,
+	) error
+	GetIMAPSetting(ctx context.Context, workspaceId string) (models.IMAPSetting, error)
+	SaveIMAPSetting(ctx context.Context, setting models.IMAPSetting) (models.IMAPSetting, error)
+	ListEnabledIMAPSettings(ctx context.Context) ([]models.IMAPSetting, error)
+	PollIMAPMailbox(ctx context.Context, setting models.IMAPSetting) (int, error)
+	AcceptSMTPRecipient(ctx context.Context, rcpt string) error
+	ReceiveSMTPMail(ctx context.Context, rcpt string, raw []byte) error
+	ReceivePostmarkInbound(
+		ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound,
+	) (models.PostmarkInboundRequest, error)
+	GetPostmarkInboundRequest(ctx context.Context, messageId string) (models.PostmarkInboundRequest, error)
+	ListPostmarkInboundRequests(
+		ctx context.Context, workspaceId *string, status string) ([]models.PostmarkInboundRequest, error)
+	ReplayPostmarkInbound(
+		ctx context.Context, req models.PostmarkInboundRequest) (models.PostmarkInboundRequest, error)
+	RetryPostmarkInboundRequests(ctx context.Context) (int, error)
 }
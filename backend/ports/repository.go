@@ -2,6 +2,8 @@ package ports
 
 import (
 	"context"
+	"time"
+
 	"github.com/zyghq/zyg/models"
 )
 
@@ -66,6 +68,19 @@ type WorkspaceRepositorer interface {
 	ModifyPostmarkMailServerSettingById(
 		ctx context.Context, setting models.PostmarkMailServerSetting, fields []string,
 	) (models.PostmarkMailServerSetting, error)
+	RotateWebhookCredential(
+		ctx context.Context, credential models.WebhookCredential, expiresAt time.Time,
+	) (models.WebhookCredential, error)
+	FetchWebhookCredentialsByWorkspaceId(
+		ctx context.Context, workspaceId string) ([]models.WebhookCredential, error)
+	FetchWorkspaceIdsWithoutWebhookCredential(ctx context.Context) ([]string, error)
+	ExpireWebhookCredential(
+		ctx context.Context, workspaceId string, credentialId string, expiresAt time.Time,
+	) (models.WebhookCredential, error)
+	SaveThreadFollowUpSetting(
+		ctx context.Context, setting models.ThreadFollowUpSetting) (models.ThreadFollowUpSetting, error)
+	FetchThreadFollowUpSettingById(
+		ctx context.Context, workspaceId string) (models.ThreadFollowUpSetting, error)
 }
 
 type MemberRepositorer interface {
@@ -96,6 +111,8 @@ type CustomerRepositorer interface {
 		ctx context.Context, widgetId string) (models.WorkspaceSecret, error)
 	ModifyCustomerById(
 		ctx context.Context, customer models.Customer) (models.Customer, error)
+	ModifyCustomerEmailBounceById(
+		ctx context.Context, customer models.Customer) (models.Customer, error)
 	CheckEmailExists(
 		ctx context.Context, workspaceId string, email string) (bool, error)
 	InsertClaimedMail(
@@ -117,34 +134,97 @@ type ThreadRepositorer interface {
 	InsertInboundThreadMessage(
 		ctx context.Context, message models.ThreadMessage) (models.Thread, models.Message, error)
 
-	InsertPostmarkInboundThreadMessage(
-		ctx context.Context, thread *models.Thread, postmarkMessageLog *models.PostmarkMessageLog,
-		message *models.Message) (*models.Thread, *models.Message, error)
-
-	// AppendInboundThreadMessage appends an inbound message to the thread.
+	// AppendInboundThreadMessage appends an inbound message to the thread
+	// and persists the thread stage transition.
 	AppendInboundThreadMessage(
 		ctx context.Context, inbound models.ThreadMessage) (models.Message, error)
 
-	AppendPostmarkInboundThreadMessage(
-		ctx context.Context, threadId string, inboundEvent *models.InboundMessage,
-		postmarkMessageLog *models.PostmarkMessageLog, message *models.Message) (*models.Message, error)
-
-	// AppendOutboundThreadMessage appends an outbound message to the thread.
+	// AppendOutboundThreadMessage appends an outbound message to the thread
+	// and persists the thread stage transition.
 	AppendOutboundThreadMessage(
 		ctx context.Context, outbound models.ThreadMessage) (models.Message, error)
 
-	CheckPostmarkInboundMessageExists(ctx context.Context, messageId string) (bool, error)
+	// CheckChannelMessageExists checks if the channel inbound message is already persisted.
+	CheckChannelMessageExists(ctx context.Context, channel string, externalId string) (bool, error)
+	LookupInboundMessageIdByExternalId(ctx context.Context, channel string, externalId string) (string, error)
+
+	// ModifyChannelMessageLogStatus updates the workspace outbound message log status
+	// as reported by the channel provider.
+	ModifyChannelMessageLogStatus(
+		ctx context.Context, workspaceId string, channel string, status models.ChannelDeliveryStatus,
+	) (models.ChannelMessageLog, error)
+
+	// FetchChannelMessageLogByExternalId returns the workspace outbound message log
+	// by the channel provider's message ID.
+	FetchChannelMessageLogByExternalId(
+		ctx context.Context, workspaceId string, channel string, externalId string) (models.ChannelMessageLog, error)
+
+	FetchThreadParticipants(ctx context.Context, threadId string) ([]models.ThreadParticipant, error)
+	UpsertThreadParticipants(ctx context.Context, participants []models.ThreadParticipant) error
+	DeleteThreadParticipants(ctx context.Context, threadId string, emails []string) error
+
+	InsertThreadForward(
+		ctx context.Context, forward models.ThreadForward, outbound models.ThreadMessage,
+	) (models.ThreadForward, models.Message, error)
+	LookupThreadForwardById(ctx context.Context, workspaceId string, forwardId string) (models.ThreadForward, error)
+	FindThreadForwardByMailRefs(ctx context.Context, workspaceId string, refs []string) (models.ThreadForward, error)
+	FetchThreadForwardsByThreadId(ctx context.Context, threadId string) ([]models.ThreadForward, error)
+	AppendThreadForwardReply(
+		ctx context.Context, forward models.ThreadForward, inbound models.ThreadMessage) (models.Message, error)
+
+	// LookupLatestThreadMessage returns the most recent message of the thread.
+	LookupLatestThreadMessage(ctx context.Context, threadId string) (models.Message, error)
+
+	// LookupPrecedingThreadMessage returns the most recent message of the thread created before the time.
+	LookupPrecedingThreadMessage(ctx context.Context, threadId string, before time.Time) (models.Message, error)
+
+	LookupThreadMessageById(ctx context.Context, messageId string) (models.Message, error)
+
+	// ClaimDueOutboundMails returns the queued mails due for delivery, leased until the time
+	// so that the mails are not claimed again while delivered.
+	ClaimDueOutboundMails(
+		ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.OutboundMail, error)
+
+	ModifyOutboundMailStatus(ctx context.Context, mail models.OutboundMail) (models.OutboundMail, error)
+
+	// SaveOutboundMailSent persists the message log of the delivered mail along with the mail sent.
+	SaveOutboundMailSent(
+		ctx context.Context, mail models.OutboundMail, messageLog *models.ChannelMessageLog,
+	) (models.OutboundMail, error)
+
+	CountOutboundMailsSentSince(ctx context.Context, workspaceId string, since time.Time) (int, error)
+
+	FetchOutboundMailsByWorkspaceId(
+		ctx context.Context, workspaceId string, statuses []string, limit int) ([]models.OutboundMail, error)
+
+	LookupOutboundMailById(ctx context.Context, workspaceId string, messageId string) (models.OutboundMail, error)
+
+	// CountAutomatedOutboundMessagesSince counts the automated outbound messages sent on the customer's threads
+	// since the specified time.
+	CountAutomatedOutboundMessagesSince(ctx context.Context, customerId string, since time.Time) (int, error)
+
+	// LookupMessageThreadId returns the thread ID of the message.
+	LookupMessageThreadId(ctx context.Context, messageId string) (string, error)
 
 	InsertMessageAttachment(
 		ctx context.Context, message models.MessageAttachment) (models.MessageAttachment, error)
 
 	FetchMessageAttachmentById(
 		ctx context.Context, messageId, attachmentId string) (models.MessageAttachment, error)
+	FetchMessageAttachmentsByMessageId(
+		ctx context.Context, messageId string) ([]models.MessageAttachment, error)
+	DeleteFailedMessageAttachments(ctx context.Context, messageId string) error
+	InsertAttachmentUpload(
+		ctx context.Context, upload models.AttachmentUpload) (models.AttachmentUpload, error)
+	// FetchAttachmentUploads returns the Member's uploads for the Thread not yet linked to a message.
+	FetchAttachmentUploads(
+		ctx context.Context, threadId string, memberId string, attachmentIds []string,
+	) ([]models.AttachmentUpload, error)
 
-	FindThreadByPostmarkReplyMessageId(
-		ctx context.Context, workspaceId string, inReplyMessageId string) (models.Thread, error)
+	FindThreadByChannelRefs(
+		ctx context.Context, workspaceId string, channel string, refs []string) (models.Thread, error)
 
-	GetRecentMailMessageIdByThreadId(ctx context.Context, threadId string) (string, error)
+	FetchChannelRefsByThreadId(ctx context.Context, threadId string, channel string) ([]string, error)
 
 	LookupByWorkspaceThreadId(
 		ctx context.Context, workspaceId string, threadId string, channel *string) (models.Thread, error)
@@ -152,6 +232,9 @@ type ThreadRepositorer interface {
 		ctx context.Context, thread models.Thread, fields []string) (models.Thread, error)
 	FetchThreadsByCustomerId(
 		ctx context.Context, customerId string, channel *string) ([]models.Thread, error)
+	FetchRecentThreadsByCustomerId(
+		ctx context.Context, workspaceId string, customerId string, channel string, since time.Time,
+	) ([]models.Thread, error)
 	FetchThreadsByWorkspaceId(
 		ctx context.Context, workspaceId string, channel *string, role *string) ([]models.Thread, error)
 	FetchThreadsByAssignedMemberId(
@@ -181,4 +264,154 @@ type ThreadRepositorer interface {
 		ctx context.Context, workspaceId string) ([]models.ThreadLabelMetric, error)
 	DeleteThreadLabelById(
 		ctx context.Context, threadId string, labelId string) error
+
+	// ClaimThreadsDueForFollowUp claims the threads of the channels waiting on the customer due for follow-up
+	// as per the workspace follow-up policy, held until the lease.
+	ClaimThreadsDueForFollowUp(
+		ctx context.Context, channels []string, now time.Time, leaseUntil time.Time, limit int) ([]models.Thread, error)
+	// ClaimThreadsDueForResolve claims the followed up threads due to be resolved
+	// as per the workspace follow-up policy, held until the lease.
+	ClaimThreadsDueForResolve(
+		ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.Thread, error)
+	SaveThreadFollowUp(
+		ctx context.Context, followUp models.ThreadFollowUp) (models.ThreadFollowUp, error)
+	ResolveThreadFollowUp(ctx context.Context, thread *models.Thread) error
+}
+
+type SpamRepositorer interface {
+	SaveSpamSetting(ctx context.Context, setting models.SpamSetting) (models.SpamSetting, error)
+	FetchSpamSettingById(ctx context.Context, workspaceId string) (models.SpamSetting, error)
+	UpsertSpamSenderRule(
+		ctx context.Context, rule models.SpamSenderRule) (models.SpamSenderRule, error)
+	FetchSpamSenderRulesByWorkspaceId(
+		ctx context.Context, workspaceId string) ([]models.SpamSenderRule, error)
+	// FetchMatchingSpamSenderRules returns the workspace sender rules matching any of kind and value pairs.
+	FetchMatchingSpamSenderRules(
+		ctx context.Context, workspaceId string, matches map[string]string) ([]models.SpamSenderRule, error)
+	DeleteSpamSenderRuleById(ctx context.Context, workspaceId, ruleId string) error
+	CountCustomerMessagesSince(ctx context.Context, customerId string, since time.Time) (int, error)
+}
+
+type BlocklistRepositorer interface {
+	UpsertSenderBlock(
+		ctx context.Context, block models.SenderBlock, audit models.BlockAudit) (models.SenderBlock, error)
+	FetchSenderBlocksByWorkspaceId(ctx context.Context, workspaceId string) ([]models.SenderBlock, error)
+	// FetchMatchingSenderBlocks returns the sender blocks matching the email, domain or IP, empty values are skipped.
+	FetchMatchingSenderBlocks(
+		ctx context.Context, workspaceId, email, domain, ip string) ([]models.SenderBlock, error)
+	DeleteSenderBlockById(ctx context.Context, workspaceId, blockId string, audit models.BlockAudit) error
+	ModifyCustomerBlock(
+		ctx context.Context, customer models.Customer, audit models.BlockAudit) (models.Customer, error)
+	FetchBlockAuditsByWorkspaceId(ctx context.Context, workspaceId string) ([]models.BlockAudit, error)
+}
+
+type SMSRepositorer interface {
+	SaveSMSSetting(ctx context.Context, setting models.SMSSetting) (models.SMSSetting, error)
+	FetchSMSSettingById(ctx context.Context, workspaceId string) (models.SMSSetting, error)
+	InsertSMSInboundThreadMessage(
+		ctx context.Context, thread *models.Thread, message *models.Message, messageLog *models.SMSMessageLog,
+	) (*models.Thread, *models.Message, error)
+	AppendSMSInboundThreadMessage(
+		ctx context.Context, thread *models.Thread, message *models.Message, messageLog *models.SMSMessageLog,
+	) (*models.Message, error)
+	AppendSMSOutboundThreadMessage(
+		ctx context.Context, thread *models.Thread, message *models.Message, messageLog *models.SMSMessageLog,
+	) (*models.Message, error)
+	// FetchRecentCustomerSMSThreadId returns the Customer's SMS Thread with the most recent message since.
+	FetchRecentCustomerSMSThreadId(
+		ctx context.Context, workspaceId, customerId string, since time.Time) (string, error)
+	CheckSMSInboundMessageExists(ctx context.Context, provider, providerMessageId string) (bool, error)
+	ModifySMSMessageLogStatus(
+		ctx context.Context, provider string, update models.SMSStatusUpdate) (models.SMSMessageLog, error)
+	FetchSMSMessageLogsByThreadId(ctx context.Context, threadId string) ([]models.SMSMessageLog, error)
+}
+
+type WhatsAppRepositorer interface {
+	SaveWhatsAppSetting(ctx context.Context, setting models.WhatsAppSetting) (models.WhatsAppSetting, error)
+	FetchWhatsAppSettingById(ctx context.Context, workspaceId string) (models.WhatsAppSetting, error)
+	CheckWhatsAppInboundMessageExists(ctx context.Context, waMessageId string) (bool, error)
+	// FetchOpenCustomerWhatsAppThreadId returns the Customer's most recent WhatsApp Thread yet to be done.
+	FetchOpenCustomerWhatsAppThreadId(ctx context.Context, workspaceId, customerId string) (string, error)
+	// FetchLastCustomerWhatsAppInboundAt returns when the Customer's last inbound WhatsApp message was received.
+	FetchLastCustomerWhatsAppInboundAt(ctx context.Context, workspaceId, customerId string) (time.Time, error)
+	ModifyWhatsAppMessageLogStatus(
+		ctx context.Context, update models.WhatsAppStatusUpdate) (models.WhatsAppMessageLog, error)
+	ModifyWhatsAppMessageReaction(
+		ctx context.Context, reaction models.WhatsAppReaction) (models.WhatsAppMessageLog, error)
+	FetchWhatsAppMessageLogsByThreadId(ctx context.Context, threadId string) ([]models.WhatsAppMessageLog, error)
+}
+
+type APIRepositorer interface {
+	SaveAPISetting(ctx context.Context, setting models.APISetting) (models.APISetting, error)
+	FetchAPISettingById(ctx context.Context, workspaceId string) (models.APISetting, error)
+	InsertAPIMessageLog(ctx context.Context, messageLog models.APIMessageLog) (models.APIMessageLog, error)
+	FetchAPIMessageLogsByThreadId(ctx context.Context, threadId string) ([]models.APIMessageLog, error)
+}
+
+type SlackRepositorer interface {
+	// SaveSlackInstall persists the installed Slack workspace with the bot, the reinstall replaces the bot token.
+	SaveSlackInstall(
+		ctx context.Context, slackWorkspace models.SlackWorkspace, bot models.SlackBot,
+	) (models.SlackWorkspace, models.SlackBot, error)
+	FetchSlackWorkspaceByWorkspaceId(ctx context.Context, workspaceId string) (models.SlackWorkspace, error)
+	FetchSlackWorkspaceByRef(ctx context.Context, ref string) (models.SlackWorkspace, error)
+	FetchSlackBotByWorkspaceRef(ctx context.Context, slackWorkspaceRef string) (models.SlackBot, error)
+	ModifySlackWorkspaceSyncStatus(
+		ctx context.Context, ref string, syncStatus string, syncedAt *time.Time) (models.SlackWorkspace, error)
+	FetchSlackWorkspacesDueForSync(
+		ctx context.Context, syncedBefore time.Time) ([]models.SlackWorkspace, error)
+	// UpsertSlackChannels inserts or updates the synced Slack channels keeping the channel mapping,
+	// channels no longer listed are archived.
+	UpsertSlackChannels(
+		ctx context.Context, slackWorkspaceRef string, channels []models.SlackChannel,
+	) ([]models.SlackChannel, error)
+	FetchSlackChannelsByWorkspaceRef(ctx context.Context, slackWorkspaceRef string) ([]models.SlackChannel, error)
+	FetchSlackChannelById(ctx context.Context, slackWorkspaceRef, channelId string) (models.SlackChannel, error)
+	FetchSlackChannelByRef(ctx context.Context, slackWorkspaceRef, channelRef string) (models.SlackChannel, error)
+	ModifySlackChannelMapping(ctx context.Context, channel models.SlackChannel) (models.SlackChannel, error)
+	InsertSlackMessageLog(ctx context.Context, messageLog models.SlackMessageLog) (models.SlackMessageLog, error)
+	CheckSlackMessageExists(ctx context.Context, slackWorkspaceRef, channelRef, ts string) (bool, error)
+	// FetchSlackThreadIdByTs returns the Thread mapped to the Slack thread of the root message ts.
+	FetchSlackThreadIdByTs(ctx context.Context, slackWorkspaceRef, channelRef, threadTs string) (string, error)
+	// FetchSlackRootMessageLog returns the Slack message log of the Thread's root Slack message.
+	FetchSlackRootMessageLog(ctx context.Context, threadId string) (models.SlackMessageLog, error)
+}
+
+type MailRepositorer interface {
+	SaveMailSetting(ctx context.Context, setting models.MailSetting) (models.MailSetting, error)
+	FetchMailSettingById(ctx context.Context, workspaceId string) (models.MailSetting, error)
+	SaveIMAPSetting(ctx context.Context, setting models.IMAPSetting) (models.IMAPSetting, error)
+	FetchIMAPSettingById(ctx context.Context, workspaceId string) (models.IMAPSetting, error)
+	FetchEnabledIMAPSettings(ctx context.Context) ([]models.IMAPSetting, error)
+	// ModifyIMAPSettingPollStatus updates the mailbox UID state and the poll error after polled.
+	ModifyIMAPSettingPollStatus(ctx context.Context, setting models.IMAPSetting) (models.IMAPSetting, error)
+	InsertPostmarkInboundRequest(
+		ctx context.Context, req models.PostmarkInboundRequest) (models.PostmarkInboundRequest, error)
+	ModifyPostmarkInboundRequestStatus(
+		ctx context.Context, req models.PostmarkInboundRequest) (models.PostmarkInboundRequest, error)
+	LookupPostmarkInboundRequestById(ctx context.Context, messageId string) (models.PostmarkInboundRequest, error)
+	FetchPostmarkInboundRequests(
+		ctx context.Context, workspaceId *string, status string, limit int) ([]models.PostmarkInboundRequest, error)
+	// ClaimDuePostmarkInboundRequests returns the failed requests due for the retry, leased until the time
+	// not to be claimed again while retried.
+	ClaimDuePostmarkInboundRequests(
+		ctx context.Context, now time.Time, leaseUntil time.Time, limit int) ([]models.PostmarkInboundRequest, error)
+	SaveMailReplySetting(ctx context.Context, setting models.MailReplySetting) (models.MailReplySetting, error)
+	FetchMailReplySettingById(ctx context.Context, workspaceId string) (models.MailReplySetting, error)
+	SaveCustomerMailSetting(
+		ctx context.Context, setting models.CustomerMailSetting) (models.CustomerMailSetting, error)
+	FetchCustomerMailSettingById(ctx context.Context, workspaceId string) (models.CustomerMailSetting, error)
+	SaveMailSignature(ctx context.Context, signature models.MailSignature) (models.MailSignature, error)
+	FetchMailSignatureByMemberId(
+		ctx context.Context, workspaceId string, memberId string) (models.MailSignature, error)
+	SaveMailbox(ctx context.Context, mailbox models.Mailbox) (models.Mailbox, error)
+	LookupMailboxById(ctx context.Context, workspaceId string, mailboxId string) (models.Mailbox, error)
+	FetchMailboxesByWorkspaceId(ctx context.Context, workspaceId string) ([]models.Mailbox, error)
+	DeleteMailbox(ctx context.Context, workspaceId string, mailboxId string) error
+	// InsertThreadMailbox links the Thread to the routed mailbox, returns false if already linked.
+	InsertThreadMailbox(ctx context.Context, threadId string, mailboxId string) (bool, error)
+	LookupMailboxByThreadId(ctx context.Context, threadId string) (models.Mailbox, error)
+	SaveMailTemplate(ctx context.Context, tmpl models.MailTemplate) (models.MailTemplate, error)
+	FetchMailTemplatesByWorkspaceId(ctx context.Context, workspaceId string) ([]models.MailTemplate, error)
+	DeleteMailTemplate(ctx context.Context, workspaceId string, kind string, locale string) error
 }
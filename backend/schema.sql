@@ -100,16 +100,22 @@ CREATE TABLE member
 -- - workspace_id + phone
 CREATE TABLE customer
 (
-    customer_id       VARCHAR(255) NOT NULL,               -- primary key
-    workspace_id      VARCHAR(255) NOT NULL,               -- fk to workspace
-    external_id       VARCHAR(255) NULL,                   -- external id of the customer (optional identifier)
-    email             VARCHAR(255) NULL,                   -- email address of the customer (optional identifier)
-    phone             VARCHAR(255) NULL,                   -- phone number of the customer (optional identifier)
-    name              VARCHAR(255) NOT NULL,               -- display name of the customer
-    role              VARCHAR(255) NOT NULL,               -- role/type of the customer
-    is_email_verified BOOLEAN      NOT NULL DEFAULT FALSE, -- whether email has been verified
-    created_at        TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
-    updated_at        TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    customer_id         VARCHAR(255) NOT NULL,               -- primary key
+    workspace_id        VARCHAR(255) NOT NULL,               -- fk to workspace
+    external_id         VARCHAR(255) NULL,                   -- external id of the customer (optional identifier)
+    email               VARCHAR(255) NULL,                   -- email address of the customer (optional identifier)
+    phone               VARCHAR(255) NULL,                   -- phone number of the customer (optional identifier)
+    name                VARCHAR(255) NOT NULL,               -- display name of the customer
+    role                VARCHAR(255) NOT NULL,               -- role/type of the customer
+    is_email_verified   BOOLEAN      NOT NULL DEFAULT FALSE, -- whether email has been verified
+    is_blocked          BOOLEAN      NOT NULL DEFAULT FALSE, -- whether blocked by the workspace
+    blocked_at          TIMESTAMP    NULL,                   -- when the customer was last blocked
+    is_email_bounced    BOOLEAN      NOT NULL DEFAULT FALSE, -- whether the email hard bounced or complained
+    email_bounced_at    TIMESTAMP    NULL,                   -- when the email last bounced
+    email_bounce_reason TEXT         NOT NULL DEFAULT '',    -- bounce reason as reported by the mail sender
+    locale              VARCHAR(35)  NOT NULL DEFAULT '',    -- preferred locale e.g. en or pt-br, for the mail
+    created_at          TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
 
     CONSTRAINT customer_customer_id_pkey PRIMARY KEY (customer_id),
     CONSTRAINT customer_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
@@ -300,9 +306,12 @@ CREATE TABLE message
     text_body     TEXT         NOT NULL,               -- Plain text content of the message
     markdown_body TEXT         NOT NULL,               -- Rich text/formatted content of the message
     html_body     TEXT         NOT NULL,               -- Rich text/formatted HTML content of the message
+    original_text_body TEXT    NOT NULL DEFAULT '',    -- Inbound mail text as received, if the quoted history is stripped
     customer_id   VARCHAR(255) NULL,                   -- Customer who sent the message (if from customer)
     member_id     VARCHAR(255) NULL,                   -- Member who sent the message (if from member)
     channel       VARCHAR(255) NOT NULL,               -- Communication channel used (email, chat, etc)
+    kind          VARCHAR(127) NOT NULL DEFAULT 'message', -- message, or internal forward and note
+    is_automated  BOOLEAN      NOT NULL DEFAULT FALSE,  -- auto-reply e.g. out-of-office, or automated outbound
     created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Timestamp when the message was created
     updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP, -- Timestamp when the message was last updated
 
@@ -333,6 +342,7 @@ CREATE TABLE message_attachment
     has_error     BOOLEAN      NOT NULL DEFAULT FALSE,
     error         TEXT         NOT NULL,
     md5_hash      VARCHAR(511) NOT NULL,
+    content_id    VARCHAR(511) NOT NULL DEFAULT '', -- inline attachment referenced as `cid:` in the HTML
     created_at    timestamp             DEFAULT CURRENT_TIMESTAMP,
     updated_at    timestamp             DEFAULT CURRENT_TIMESTAMP,
 
@@ -340,31 +350,151 @@ CREATE TABLE message_attachment
     CONSTRAINT message_attachment_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id)
 );
 
+-- Represents the files uploaded by the member for the reply to the thread.
+-- Linked to the reply message as the message attachment with the same attachment ID when the reply is sent.
+CREATE TABLE attachment_upload
+(
+    attachment_id VARCHAR(255) NOT NULL,
+    workspace_id  VARCHAR(255) NOT NULL,
+    thread_id     VARCHAR(255) NOT NULL,
+    member_id     VARCHAR(255) NOT NULL,
+    name          VARCHAR(255) NOT NULL,
+    content_type  VARCHAR(511) NOT NULL,
+    content_key   VARCHAR(511) NOT NULL,
+    content_url   VARCHAR(511) NOT NULL,
+    size          BIGINT       NOT NULL,
+    md5_hash      VARCHAR(511) NOT NULL,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT attachment_upload_attachment_id_pkey PRIMARY KEY (attachment_id),
+    CONSTRAINT attachment_upload_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT attachment_upload_thread_id_fkey FOREIGN KEY (thread_id) REFERENCES thread (thread_id),
+    CONSTRAINT attachment_upload_member_id_fkey FOREIGN KEY (member_id) REFERENCES member (member_id)
+);
 
-CREATE TABLE postmark_message_log
+-- Represents the recipients of the message as addressed, e.g. mail To, Cc and Bcc.
+CREATE TABLE message_participant
 (
-    message_id            VARCHAR(255) NOT NULL, -- References parent message
-    payload               JSONB        NOT NULL, -- Request payload
-    postmark_message_id   VARCHAR(255) NOT NULL, -- Postmark's internal message ID
-    mail_message_id       VARCHAR(255) NOT NULL, -- Email `Message-ID` header
-    reply_mail_message_id VARCHAR(255) NULL,     -- Email `In-Reply-To` header
-    has_error             BOOLEAN      NOT NULL DEFAULT FALSE,
-    submitted_at          TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
-    error_code            bigint       NOT NULL,
-    postmark_message      VARCHAR(255) NOT NULL,
-    message_event         VARCHAR(255) NOT NULL,
-    acknowledged          BOOLEAN      NOT NULL DEFAULT FALSE,
-    message_type          VARCHAR(255) NOT NULL,
-    created_at            TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
-    updated_at            TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    message_id VARCHAR(255) NOT NULL,
+    email      VARCHAR(255) NOT NULL,
+    name       VARCHAR(255) NOT NULL,
+    role       VARCHAR(127) NOT NULL, -- to, cc or bcc
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 
-    CONSTRAINT postmark_log_message_id_pkey PRIMARY KEY (message_id),
-    CONSTRAINT postmark_log_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id),
+    CONSTRAINT message_participant_message_id_email_pkey PRIMARY KEY (message_id, email),
+    CONSTRAINT message_participant_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id)
+);
 
-    CONSTRAINT postmark_log_pm_message_id_key UNIQUE (postmark_message_id),
-    CONSTRAINT postmark_log_mail_message_id_key UNIQUE (mail_message_id)
+-- Represents the additional participants of the email thread other than the thread customer.
+-- The participant is the workspace customer if exists with the email, otherwise the external address.
+CREATE TABLE thread_participant
+(
+    thread_id   VARCHAR(255) NOT NULL,
+    email       VARCHAR(255) NOT NULL,
+    name        VARCHAR(255) NOT NULL,
+    role        VARCHAR(127) NOT NULL, -- to or cc
+    customer_id VARCHAR(255) NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT thread_participant_thread_id_email_pkey PRIMARY KEY (thread_id, email),
+    CONSTRAINT thread_participant_thread_id_fkey FOREIGN KEY (thread_id) REFERENCES thread (thread_id),
+    CONSTRAINT thread_participant_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES customer (customer_id)
+);
+
+-- Represents the Thread's messages forwarded by the Member to the external address.
+-- The forward is kept as the `forward` message in the Thread timeline, replies from the forwarded address
+-- are matched with the forwarded mail `Message-ID` or the reply token, kept as the `note` messages.
+CREATE TABLE thread_forward
+(
+    forward_id      VARCHAR(255) NOT NULL,
+    workspace_id    VARCHAR(255) NOT NULL,
+    thread_id       VARCHAR(255) NOT NULL,
+    message_id      VARCHAR(255) NOT NULL,          -- Thread timeline entry of the forward
+    member_id       VARCHAR(255) NOT NULL,
+    to_email        VARCHAR(511) NOT NULL,
+    to_name         VARCHAR(511) NOT NULL DEFAULT '',
+    message_ids     TEXT[]       NOT NULL DEFAULT '{}', -- forwarded messages, empty for the transcript
+    is_transcript   BOOLEAN      NOT NULL DEFAULT FALSE,
+    note            TEXT         NOT NULL DEFAULT '',
+    track_replies   BOOLEAN      NOT NULL DEFAULT TRUE,
+    mail_message_id VARCHAR(511) NOT NULL,          -- `Message-ID` header of the forwarded mail
+    reply_count     INT          NOT NULL DEFAULT 0,
+    last_replied_at TIMESTAMP    NULL,
+    created_at      TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT thread_forward_forward_id_pkey PRIMARY KEY (forward_id),
+    CONSTRAINT thread_forward_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT thread_forward_thread_id_fkey FOREIGN KEY (thread_id) REFERENCES thread (thread_id),
+    CONSTRAINT thread_forward_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id),
+    CONSTRAINT thread_forward_member_id_fkey FOREIGN KEY (member_id) REFERENCES member (member_id),
+    CONSTRAINT thread_forward_mail_message_id_key UNIQUE (workspace_id, mail_message_id)
 );
-CREATE INDEX postmark_log_reply_mail_message_idx ON postmark_message_log (reply_mail_message_id);
+
+
+-- Represents the channel message log table
+-- This table is used to track the thread message as exchanged with the channel provider.
+-- Each message is uniquely identified per channel by the provider's message ID as `external_id`,
+-- the channel protocol reference e.g. mail `Message-ID` as `external_ref` is used to match replies.
+-- Replaces postmark_message_log, migrated with migrations/002_channel_message_log.sql.
+-- The channels keyed by the other than the provider's message ID keep the own log tables:
+-- sms_message_log is unique per provider with the phones the Customer is matched by,
+-- whatsapp_message_log keeps the message kind and the reactions of the WhatsApp message,
+-- slack_message_log threads by the Slack channel and `thread_ts` of the Slack workspace,
+-- api_message_log has no provider's message ID, only the webhook delivery result of the reply.
+CREATE TABLE channel_message_log
+(
+    message_id    VARCHAR(255) NOT NULL, -- References parent message
+    channel       VARCHAR(127) NOT NULL,
+    message_type  VARCHAR(127) NOT NULL, -- inbound or outbound
+    external_id   VARCHAR(255) NOT NULL, -- Provider's message ID
+    external_ref  VARCHAR(511) NULL,     -- Channel protocol reference, e.g. mail `Message-ID` header
+    reply_ref     VARCHAR(511) NULL,     -- Channel protocol reference replied to, e.g. mail `In-Reply-To` header
+    payload       JSONB        NOT NULL, -- Request payload
+    status        VARCHAR(127) NOT NULL,
+    has_error     BOOLEAN      NOT NULL DEFAULT FALSE,
+    error_code    BIGINT       NOT NULL DEFAULT 0,
+    error_message TEXT         NOT NULL DEFAULT '',
+    acknowledged  BOOLEAN      NOT NULL DEFAULT FALSE,
+    submitted_at  TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    created_at    TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT channel_message_log_message_id_pkey PRIMARY KEY (message_id),
+    CONSTRAINT channel_message_log_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id),
+
+    CONSTRAINT channel_message_log_external_id_key UNIQUE (channel, external_id),
+    CONSTRAINT channel_message_log_external_ref_key UNIQUE (channel, external_ref)
+);
+CREATE INDEX channel_message_log_reply_ref_idx ON channel_message_log (channel, reply_ref);
+
+-- Represents the member's mail reply queued for delivery, the reply message is persisted before delivered.
+-- Delivered in the background with the retries, within the workspace rate limit.
+CREATE TABLE outbound_mail
+(
+    message_id      VARCHAR(255) NOT NULL,
+    workspace_id    VARCHAR(255) NOT NULL,
+    thread_id       VARCHAR(255) NOT NULL,
+    member_id       VARCHAR(255) NOT NULL,
+    customer_id     VARCHAR(255) NOT NULL,
+    participants    JSONB        NOT NULL, -- Thread participants the mail is sent to other than the customer
+    bcc             JSONB        NOT NULL,
+    status          VARCHAR(127) NOT NULL, -- queued, sent or failed
+    error           TEXT         NULL,
+    attempts        INT          NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP    NULL,     -- next delivery attempt of the queued mail
+    sent_at         TIMESTAMP    NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT outbound_mail_message_id_pkey PRIMARY KEY (message_id),
+    CONSTRAINT outbound_mail_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id),
+    CONSTRAINT outbound_mail_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+CREATE INDEX outbound_mail_queued_idx ON outbound_mail (next_attempt_at) WHERE status = 'queued';
+CREATE INDEX outbound_mail_sent_idx ON outbound_mail (workspace_id, sent_at) WHERE status = 'sent';
 
 -- Represents the label table
 -- This table is used to store the labels linked to the workspace.
@@ -398,6 +528,103 @@ CREATE TABLE thread_label
     CONSTRAINT thread_label_thread_label_id_key UNIQUE (thread_id, label_id)
 );
 
+-- Supports the follow-up scheduler scanning threads waiting on the customer.
+CREATE INDEX thread_stage_status_changed_at_idx ON thread (stage, status_changed_at);
+
+-- Represents the workspace follow-up policy for threads waiting on the customer.
+-- After `follow_up_after_days` with no customer reply, optionally send the follow-up,
+-- then auto resolve the thread after `resolve_after_days`.
+CREATE TABLE thread_follow_up_setting
+(
+    workspace_id         VARCHAR(255) NOT NULL,
+    is_enabled           BOOLEAN      NOT NULL DEFAULT FALSE,
+    follow_up_after_days INT          NOT NULL,
+    send_follow_up       BOOLEAN      NOT NULL DEFAULT TRUE,
+    follow_up_template   TEXT         NOT NULL,
+    resolve_after_days   INT          NOT NULL,
+    created_at           TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    updated_at           TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT thread_follow_up_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT thread_follow_up_setting_workspace_id_fkey FOREIGN KEY (workspace_id)
+        REFERENCES workspace (workspace_id),
+    CONSTRAINT thread_follow_up_setting_follow_up_after_days_check CHECK (follow_up_after_days > 0),
+    CONSTRAINT thread_follow_up_setting_resolve_after_days_check CHECK (resolve_after_days > 0)
+);
+
+-- Records the follow-up steps taken on a thread waiting on the customer.
+-- Tracks the latest waiting cycle of the thread, cancelled when the customer replies.
+CREATE TABLE thread_follow_up
+(
+    thread_id       VARCHAR(255) NOT NULL,
+    workspace_id    VARCHAR(255) NOT NULL,
+    status          VARCHAR(127) NOT NULL, -- followed_up, resolved or cancelled
+    message_id      VARCHAR(255) NULL,     -- follow-up message if sent
+    followed_up_at  TIMESTAMP    NOT NULL,
+    resolved_at     TIMESTAMP    NULL,
+    cancelled_at    TIMESTAMP    NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT thread_follow_up_thread_id_pkey PRIMARY KEY (thread_id),
+    CONSTRAINT thread_follow_up_thread_id_fkey FOREIGN KEY (thread_id) REFERENCES thread (thread_id),
+    CONSTRAINT thread_follow_up_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT thread_follow_up_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id)
+);
+CREATE INDEX thread_follow_up_status_followed_up_at_idx ON thread_follow_up (status, followed_up_at);
+
+-- Represents the claim of the thread due for the follow-up step, held by the follow-up scheduler until the lease.
+-- Concurrent schedulers skip the claimed thread until the lease is over.
+CREATE TABLE thread_follow_up_claim
+(
+    thread_id     VARCHAR(255) NOT NULL,
+    claimed_until TIMESTAMP    NOT NULL,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT thread_follow_up_claim_thread_id_pkey PRIMARY KEY (thread_id),
+    CONSTRAINT thread_follow_up_claim_thread_id_fkey FOREIGN KEY (thread_id) REFERENCES thread (thread_id)
+);
+
+-- Represents the workspace spam filtering policy.
+-- Inbound messages scoring at or over the threshold move the thread to spam stage.
+CREATE TABLE spam_setting
+(
+    workspace_id          VARCHAR(255)     NOT NULL,
+    is_enabled            BOOLEAN          NOT NULL DEFAULT FALSE,
+    threshold             DOUBLE PRECISION NOT NULL,
+    chat_rate_limit       INT              NOT NULL, -- max chat messages per customer within the window
+    chat_rate_window_secs INT              NOT NULL,
+    created_at            TIMESTAMP                 DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMP                 DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT spam_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT spam_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT spam_setting_chat_rate_check CHECK (chat_rate_limit > 0 AND chat_rate_window_secs > 0)
+);
+
+-- Represents the workspace spam sender allow list, senders are blocked with sender_block.
+-- Matches the sender by email address, domain or customer.
+CREATE TABLE spam_sender_rule
+(
+    rule_id       VARCHAR(255) NOT NULL,
+    workspace_id  VARCHAR(255) NOT NULL,
+    kind          VARCHAR(127) NOT NULL, -- address, domain or customer
+    value         VARCHAR(511) NOT NULL,
+    action        VARCHAR(127) NOT NULL, -- allow
+    created_by_id VARCHAR(255) NOT NULL,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT spam_sender_rule_rule_id_pkey PRIMARY KEY (rule_id),
+    CONSTRAINT spam_sender_rule_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT spam_sender_rule_created_by_id_fkey FOREIGN KEY (created_by_id) REFERENCES member (member_id),
+    CONSTRAINT spam_sender_rule_workspace_id_kind_value_key UNIQUE (workspace_id, kind, value)
+);
+
+-- Supports the per customer chat rate heuristics.
+CREATE INDEX message_customer_id_created_at_idx ON message (customer_id, created_at);
+
 -- Represents the widget table
 -- This table is used to store the widgets linked to the workspace.
 CREATE TABLE widget
@@ -431,15 +658,171 @@ CREATE TABLE workspace_secret
 -- This table is used to store the widget session linked to the widget.
 CREATE TABLE widget_session
 (
-    session_id VARCHAR(255) NOT NULL,
-    widget_id  VARCHAR(255) NOT NULL,
-    data       TEXT         NOT NULL,
-    expire_at  TIMESTAMP    NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    session_id  VARCHAR(255) NOT NULL,
+    widget_id   VARCHAR(255) NOT NULL,
+    customer_id VARCHAR(255) NULL,     -- customer of the session, used to revoke
+    data        TEXT         NOT NULL,
+    expire_at   TIMESTAMP    NOT NULL,
+    revoked_at  TIMESTAMP    NULL,     -- set when the customer is blocked
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 
     CONSTRAINT widget_session_session_id_pkey PRIMARY KEY (session_id),
-    CONSTRAINT widget_session_widget_id_fkey FOREIGN KEY (widget_id) REFERENCES widget (widget_id)
+    CONSTRAINT widget_session_widget_id_fkey FOREIGN KEY (widget_id) REFERENCES widget (widget_id),
+    CONSTRAINT widget_session_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES customer (customer_id)
+);
+CREATE INDEX widget_session_customer_id_idx ON widget_session (customer_id);
+
+-- Represents the workspace blocklist of sender email addresses, domains and IP ranges.
+-- Matching inbound mail is dropped or quarantined, matching widget customers are refused.
+CREATE TABLE sender_block
+(
+    block_id      VARCHAR(255) NOT NULL,
+    workspace_id  VARCHAR(255) NOT NULL,
+    kind          VARCHAR(127) NOT NULL, -- email, domain or ip_range
+    value         VARCHAR(255) NOT NULL, -- ip_range is stored in CIDR notation
+    action        VARCHAR(127) NOT NULL, -- drop or quarantine
+    reason        TEXT         NULL,
+    created_by_id VARCHAR(255) NOT NULL,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT sender_block_block_id_pkey PRIMARY KEY (block_id),
+    CONSTRAINT sender_block_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT sender_block_created_by_id_fkey FOREIGN KEY (created_by_id) REFERENCES member (member_id),
+    CONSTRAINT sender_block_workspace_id_kind_value_key UNIQUE (workspace_id, kind, value)
+);
+
+-- Audit trail of the block and unblock actions taken on customers and senders.
+CREATE TABLE block_audit
+(
+    audit_id     VARCHAR(255) NOT NULL,
+    workspace_id VARCHAR(255) NOT NULL,
+    action       VARCHAR(127) NOT NULL, -- block or unblock
+    kind         VARCHAR(127) NOT NULL, -- customer, email, domain or ip_range
+    value        VARCHAR(255) NOT NULL, -- customer ID or the sender value
+    reason       TEXT         NULL,
+    member_id    VARCHAR(255) NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT block_audit_audit_id_pkey PRIMARY KEY (audit_id),
+    CONSTRAINT block_audit_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT block_audit_member_id_fkey FOREIGN KEY (member_id) REFERENCES member (member_id)
+);
+CREATE INDEX block_audit_workspace_id_created_at_idx ON block_audit (workspace_id, created_at);
+
+-- Represents the workspace SMS provider account.
+-- There is only one SMS setting per workspace.
+CREATE TABLE sms_setting
+(
+    workspace_id              VARCHAR(255) NOT NULL,
+    is_enabled                BOOLEAN      NOT NULL DEFAULT FALSE,
+    provider                  VARCHAR(127) NOT NULL, -- twilio or fake
+    phone_number              VARCHAR(127) NOT NULL, -- E.164 sender number
+    account_sid               VARCHAR(255) NOT NULL,
+    auth_token                VARCHAR(255) NOT NULL,
+    conversation_window_hours INT          NOT NULL, -- inbound within the window continues the recent thread
+    created_at                TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at                TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT sms_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT sms_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT sms_setting_conversation_window_hours_check CHECK (conversation_window_hours > 0)
+);
+
+-- Represents the provider message of the inbound or outbound SMS linked to the message.
+-- Status of the outbound SMS is updated as reported by the provider.
+CREATE TABLE sms_message_log
+(
+    message_id          VARCHAR(255) NOT NULL, -- References parent message
+    provider            VARCHAR(127) NOT NULL,
+    provider_message_id VARCHAR(255) NOT NULL, -- Provider's message ID e.g. Twilio MessageSid
+    message_type        VARCHAR(127) NOT NULL, -- inbound or outbound
+    from_phone          VARCHAR(127) NOT NULL,
+    to_phone            VARCHAR(127) NOT NULL,
+    status              VARCHAR(127) NOT NULL,
+    error_code          VARCHAR(127) NULL,
+    error_message       TEXT         NULL,
+    payload             JSONB        NOT NULL,
+    created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT sms_message_log_message_id_pkey PRIMARY KEY (message_id),
+    CONSTRAINT sms_message_log_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id),
+    CONSTRAINT sms_message_log_provider_message_id_key UNIQUE (provider, provider_message_id)
+);
+
+-- Represents the WhatsApp Business phone number setting of the workspace on the Cloud API.
+-- API base URL is set for the local stand-in, empty for the Cloud API.
+CREATE TABLE whatsapp_setting
+(
+    workspace_id         VARCHAR(255) NOT NULL,
+    is_enabled           BOOLEAN      NOT NULL DEFAULT FALSE,
+    phone_number_id      VARCHAR(255) NOT NULL,
+    business_account_id  VARCHAR(255) NOT NULL,
+    display_phone_number VARCHAR(127) NOT NULL,
+    access_token         TEXT         NOT NULL,
+    app_secret           VARCHAR(255) NOT NULL, -- verifies the webhook payload signature
+    verify_token         VARCHAR(255) NOT NULL, -- verifies the webhook subscription
+    api_base_url         VARCHAR(511) NOT NULL,
+    created_at           TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at           TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT whatsapp_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT whatsapp_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+-- Represents the WhatsApp message of the inbound or outbound message linked to the message.
+-- Status of the outbound message and the Customer's reaction are updated as reported by the webhook.
+CREATE TABLE whatsapp_message_log
+(
+    message_id    VARCHAR(255) NOT NULL, -- References parent message
+    wa_message_id VARCHAR(255) NOT NULL, -- WhatsApp message ID e.g. wamid.xxx
+    wa_id         VARCHAR(127) NOT NULL, -- WhatsApp ID of the Customer
+    message_type  VARCHAR(127) NOT NULL, -- inbound or outbound
+    kind          VARCHAR(127) NOT NULL, -- text, image, template, etc.
+    status        VARCHAR(127) NOT NULL,
+    reaction      VARCHAR(63)  NULL,
+    error_code    VARCHAR(127) NULL,
+    error_title   TEXT         NULL,
+    payload       JSONB        NOT NULL,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT whatsapp_message_log_message_id_pkey PRIMARY KEY (message_id),
+    CONSTRAINT whatsapp_message_log_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id),
+    CONSTRAINT whatsapp_message_log_wa_message_id_key UNIQUE (wa_message_id)
+);
+
+-- Represents the API channel setting of the workspace.
+-- Member replies on API threads are delivered to the webhook URL signed with the webhook secret.
+CREATE TABLE api_setting
+(
+    workspace_id   VARCHAR(255) NOT NULL,
+    is_enabled     BOOLEAN      NOT NULL DEFAULT FALSE,
+    webhook_url    VARCHAR(511) NOT NULL,
+    webhook_secret VARCHAR(255) NOT NULL, -- signs the webhook payload
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT api_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT api_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+-- Represents the API channel message linked to the message.
+-- Webhook status of the outbound message is as per the last webhook delivery.
+CREATE TABLE api_message_log
+(
+    message_id     VARCHAR(255) NOT NULL, -- References parent message
+    message_type   VARCHAR(127) NOT NULL, -- inbound or outbound
+    webhook_status VARCHAR(127) NULL,     -- delivered or failed, null for inbound
+    webhook_code   INT          NULL,     -- response status code of the webhook
+    webhook_error  TEXT         NULL,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT api_message_log_message_id_pkey PRIMARY KEY (message_id),
+    CONSTRAINT api_message_log_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id)
 );
 
 -- ************************************ --
@@ -473,7 +856,7 @@ CREATE TABLE event
 );
 
 -- ************************************ --
--- Will work on Slack integration later --
+-- Slack integration                    --
 -- ************************************ --
 
 -- Represents the Slack workspace table
@@ -535,14 +918,247 @@ CREATE TABLE slack_channel
     created               BIGINT       NOT NULL,
     updated               BIGINT       NOT NULL,
     status                VARCHAR(127) NOT NULL,          -- custom status of Slack Channel with respect to Slack workspace
+    is_enabled            BOOLEAN      NOT NULL DEFAULT FALSE, -- creates threads from the channel messages
+    label_id              VARCHAR(255) NULL,              -- label added to the threads created from the channel
     synced_at             TIMESTAMP    NULL DEFAULT NULL, -- custom timestamp Slack channel was synced defaults to NULL
     created_at            TIMESTAMP         DEFAULT CURRENT_TIMESTAMP,
     updated_at            TIMESTAMP         DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT slack_channel_channel_id_pkey PRIMARY KEY (channel_id),
     CONSTRAINT slack_channel_slack_workspace_ref_fkey FOREIGN KEY (slack_workspace_ref) REFERENCES slack_workspace (ref),
+    CONSTRAINT slack_channel_label_id_fkey FOREIGN KEY (label_id) REFERENCES label (label_id),
     CONSTRAINT slack_channel_slack_workspace_ref_channel_ref_key UNIQUE (slack_workspace_ref, channel_ref)
 );
 
+-- Represents the Slack message linked to the message.
+-- The Slack thread is identified by the root message ts within the channel, and maps to the thread.
+CREATE TABLE slack_message_log
+(
+    message_id          VARCHAR(255) NOT NULL, -- References parent message
+    slack_workspace_ref VARCHAR(255) NOT NULL, -- fk to slack_workspace
+    channel_ref         VARCHAR(255) NOT NULL, -- reference to Slack channel ID
+    ts                  VARCHAR(63)  NOT NULL, -- Slack message ts
+    thread_ts           VARCHAR(63)  NOT NULL, -- Slack thread root message ts
+    user_ref            VARCHAR(255) NOT NULL, -- Slack user ID of the author, bot user for outbound
+    message_type        VARCHAR(127) NOT NULL, -- inbound or outbound
+    created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT slack_message_log_message_id_pkey PRIMARY KEY (message_id),
+    CONSTRAINT slack_message_log_message_id_fkey FOREIGN KEY (message_id) REFERENCES message (message_id),
+    CONSTRAINT slack_message_log_slack_workspace_ref_fkey FOREIGN KEY (slack_workspace_ref)
+        REFERENCES slack_workspace (ref),
+    CONSTRAINT slack_message_log_channel_ref_ts_key UNIQUE (slack_workspace_ref, channel_ref, ts)
+);
+
+CREATE INDEX slack_message_log_thread_ts_idx ON slack_message_log (slack_workspace_ref, channel_ref, thread_ts);
+
+-- Represents the outbound mail sender of the workspace.
+-- When disabled the workspace mail is sent from the Postmark mail server if configured,
+-- otherwise with the deployment mail sender.
+CREATE TABLE mail_setting
+(
+    workspace_id     VARCHAR(255) NOT NULL,
+    is_enabled       BOOLEAN      NOT NULL DEFAULT FALSE,
+    sender           VARCHAR(127) NOT NULL, -- postmark, resend, smtp or capture
+    from_name        VARCHAR(255) NOT NULL,
+    from_email       VARCHAR(255) NOT NULL,
+    reply_to         VARCHAR(255) NOT NULL,
+    api_key          VARCHAR(255) NOT NULL, -- Postmark server token or Resend API key
+    smtp_host        VARCHAR(255) NOT NULL,
+    smtp_port        INT          NOT NULL,
+    smtp_username    VARCHAR(255) NOT NULL,
+    smtp_password    VARCHAR(255) NOT NULL,
+    smtp_security    VARCHAR(127) NOT NULL, -- none, starttls or tls
+    smtp_auth        VARCHAR(127) NOT NULL, -- none, plain or login
+    reopen_on_bounce BOOLEAN      NOT NULL DEFAULT FALSE, -- reopen the thread when the reply hard bounces
+    created_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at       TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT mail_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT mail_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+-- Represents the Postmark inbound webhook request of the workspace and its processing state,
+-- received, processed, failed or skipped as already processed. Failed requests are retried with backoff
+-- until the max attempts, then kept for the replay.
+CREATE TABLE postmark_inbound_request
+(
+    message_id      VARCHAR(255) NOT NULL, -- Postmark inbound message ID
+    workspace_id    VARCHAR(255) NOT NULL,
+    payload         JSONB        NOT NULL, -- Request payload, replayed as received
+    status          VARCHAR(127) NOT NULL, -- received, processed, failed or skipped
+    error           TEXT         NULL,
+    attempts        INT          NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP    NULL,     -- next retry of the failed request, if any
+    thread_id       VARCHAR(255) NULL,     -- Thread the inbound mail is processed into
+    processed_at    TIMESTAMP    NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT postmark_inbound_request_message_id_pkey PRIMARY KEY (message_id),
+    CONSTRAINT postmark_inbound_request_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+CREATE INDEX postmark_inbound_request_failed_idx ON postmark_inbound_request (next_attempt_at) WHERE status = 'failed';
+
+-- Represents the IMAP mailbox of the workspace polled for the inbound mail.
+-- Mail is fetched after the last fetched UID of the mailbox UIDVALIDITY.
+CREATE TABLE imap_setting
+(
+    workspace_id   VARCHAR(255) NOT NULL,
+    is_enabled     BOOLEAN      NOT NULL DEFAULT FALSE,
+    host           VARCHAR(255) NOT NULL,
+    port           INT          NOT NULL,
+    username       VARCHAR(255) NOT NULL,
+    password       VARCHAR(255) NOT NULL,
+    security       VARCHAR(127) NOT NULL, -- none, starttls or tls
+    mailbox        VARCHAR(255) NOT NULL,
+    uid_validity   BIGINT       NOT NULL DEFAULT 0,
+    last_uid       BIGINT       NOT NULL DEFAULT 0,
+    last_polled_at TIMESTAMP    NULL,
+    poll_error     TEXT         NULL,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT imap_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT imap_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+-- Represents the workspace reply wrapper of the mail replies.
+-- The signature is the workspace default for the members without their own signature.
+CREATE TABLE mail_reply_setting
+(
+    workspace_id   VARCHAR(255) NOT NULL,
+    is_branded     BOOLEAN      NOT NULL DEFAULT FALSE, -- wraps the reply with logo, header and footer
+    logo_url       TEXT         NOT NULL,
+    header         TEXT         NOT NULL,
+    footer         TEXT         NOT NULL,
+    include_quote  BOOLEAN      NOT NULL DEFAULT TRUE,  -- quotes the previous message of the thread
+    signature_html TEXT         NOT NULL,
+    signature_text TEXT         NOT NULL,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT mail_reply_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT mail_reply_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+-- Represents the transactional mail of the thread sent to the customer with the workspace mail templates.
+-- CSAT survey and transcript are sent when the thread is resolved, notification on the member's chat reply.
+CREATE TABLE customer_mail_setting
+(
+    workspace_id      VARCHAR(255) NOT NULL,
+    send_csat         BOOLEAN      NOT NULL DEFAULT FALSE,
+    csat_url          TEXT         NOT NULL, -- workspace survey linked with the thread ID
+    send_transcript   BOOLEAN      NOT NULL DEFAULT FALSE,
+    send_notification BOOLEAN      NOT NULL DEFAULT FALSE,
+    conversation_url  TEXT         NOT NULL, -- page of the chat widget linked in the notification
+    created_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at        TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT customer_mail_setting_workspace_id_pkey PRIMARY KEY (workspace_id),
+    CONSTRAINT customer_mail_setting_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
+-- Represents the member's signature appended to the mail replies.
+CREATE TABLE mail_signature
+(
+    workspace_id VARCHAR(255) NOT NULL,
+    member_id    VARCHAR(255) NOT NULL,
+    html_body    TEXT         NOT NULL,
+    text_body    TEXT         NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT mail_signature_member_id_pkey PRIMARY KEY (member_id),
+    CONSTRAINT mail_signature_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT mail_signature_member_id_fkey FOREIGN KEY (member_id) REFERENCES member (member_id)
+);
+
+-- Represents the workspace mailboxes e.g. support@, billing@ and security@, each with its own sender domain.
+-- Inbound mail is routed to the mailbox by the recipient address, otherwise to the default mailbox.
+CREATE TABLE mailbox
+(
+    mailbox_id                  VARCHAR(255) NOT NULL,
+    workspace_id                VARCHAR(255) NOT NULL,
+    name                        VARCHAR(255) NOT NULL,
+    email                       VARCHAR(255) NOT NULL,
+    domain                      VARCHAR(255) NOT NULL,
+    is_default                  BOOLEAN      NOT NULL DEFAULT FALSE,
+    signature_html              TEXT         NOT NULL,
+    signature_text              TEXT         NOT NULL,
+    default_label_id            VARCHAR(255) NULL,
+    default_assignee_id         VARCHAR(255) NULL,
+    has_dns                     BOOLEAN      NOT NULL DEFAULT FALSE,
+    is_dns_verified             BOOLEAN      NOT NULL DEFAULT FALSE,
+    dns_verified_at             TIMESTAMP    NULL,
+    dns_domain_id               BIGINT       NULL,
+    dkim_host                   VARCHAR(255) NULL,
+    dkim_text_value             TEXT         NULL,
+    dkim_update_status          VARCHAR(255) NULL,
+    return_path_domain          VARCHAR(255) NULL,
+    return_path_domain_cname    VARCHAR(255) NULL,
+    return_path_domain_verified BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at                  TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+    updated_at                  TIMESTAMP             DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT mailbox_mailbox_id_pkey PRIMARY KEY (mailbox_id),
+    CONSTRAINT mailbox_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT mailbox_default_label_id_fkey FOREIGN KEY (default_label_id) REFERENCES label (label_id) ON DELETE SET NULL,
+    CONSTRAINT mailbox_default_assignee_id_fkey FOREIGN KEY (default_assignee_id) REFERENCES member (member_id) ON DELETE SET NULL,
+    CONSTRAINT mailbox_workspace_id_email_key UNIQUE (workspace_id, email)
+);
+
+-- Only one default mailbox per workspace.
+CREATE UNIQUE INDEX mailbox_workspace_id_is_default_idx ON mailbox (workspace_id) WHERE is_default;
+
+-- Represents the workspace webhook credentials of the inbound mail webhooks, as basic auth.
+-- Only the SHA-256 hash of the secret is kept. On rotation the previous credentials expire after the overlap.
+-- Credential of the mailbox only accepts the inbound mail addressed to the mailbox.
+CREATE TABLE webhook_credential
+(
+    credential_id VARCHAR(255) NOT NULL, -- basic auth username
+    workspace_id  VARCHAR(255) NOT NULL,
+    mailbox_id    VARCHAR(255) NULL,     -- null for the workspace
+    secret_hash   VARCHAR(255) NOT NULL,
+    secret_hint   VARCHAR(31)  NOT NULL,
+    expires_at    TIMESTAMP    NULL,     -- not expired until rotated or revoked
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT webhook_credential_credential_id_pkey PRIMARY KEY (credential_id),
+    CONSTRAINT webhook_credential_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id),
+    CONSTRAINT webhook_credential_mailbox_id_fkey FOREIGN KEY (mailbox_id) REFERENCES mailbox (mailbox_id) ON DELETE CASCADE,
+    CONSTRAINT webhook_credential_secret_hash_key UNIQUE (secret_hash)
+);
+
+-- Represents the mailbox the email thread was routed to, replies go out from the mailbox.
+CREATE TABLE thread_mailbox
+(
+    thread_id  VARCHAR(255) NOT NULL,
+    mailbox_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT thread_mailbox_thread_id_pkey PRIMARY KEY (thread_id),
+    CONSTRAINT thread_mailbox_thread_id_fkey FOREIGN KEY (thread_id) REFERENCES thread (thread_id),
+    CONSTRAINT thread_mailbox_mailbox_id_fkey FOREIGN KEY (mailbox_id) REFERENCES mailbox (mailbox_id) ON DELETE CASCADE
+);
+
+-- Represents the workspace overrides of the built-in transactional mail templates, by kind and customer locale.
+CREATE TABLE mail_template
+(
+    workspace_id VARCHAR(255) NOT NULL,
+    kind         VARCHAR(127) NOT NULL, -- verification, csat, transcript, follow_up or notification
+    locale       VARCHAR(35)  NOT NULL, -- e.g. en or pt-br
+    subject      TEXT         NOT NULL,
+    html_body    TEXT         NOT NULL,
+    text_body    TEXT         NOT NULL,
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT mail_template_workspace_id_kind_locale_pkey PRIMARY KEY (workspace_id, kind, locale),
+    CONSTRAINT mail_template_workspace_id_fkey FOREIGN KEY (workspace_id) REFERENCES workspace (workspace_id)
+);
+
 -- Stored procedure to generate next id
 CREATE OR REPLACE FUNCTION fn_next_id(OUT result bigint) AS
 $$
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/adapters/store"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// APIService handles the API channel, Threads are created from the workspace's own backend
+// on behalf of the Customer and Member replies are delivered to the workspace webhook.
+type APIService struct {
+	repo       ports.APIRepositorer
+	threadRepo ports.ThreadRepositorer
+	webhook    ports.APIWebhookSender
+}
+
+func NewAPIService(
+	repo ports.APIRepositorer, threadRepo ports.ThreadRepositorer, webhook ports.APIWebhookSender,
+) *APIService {
+	return &APIService{
+		repo:       repo,
+		threadRepo: threadRepo,
+		webhook:    webhook,
+	}
+}
+
+// GetAPISetting returns the API setting of the workspace,
+// or the default disabled setting if not configured.
+func (s *APIService) GetAPISetting(ctx context.Context, workspaceId string) (models.APISetting, error) {
+	setting, err := s.repo.FetchAPISettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewAPISetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.APISetting{}, ErrAPISetting
+	}
+	return setting, nil
+}
+
+func (s *APIService) SaveAPISetting(ctx context.Context, setting models.APISetting) (models.APISetting, error) {
+	setting, err := s.repo.SaveAPISetting(ctx, setting)
+	if err != nil {
+		return models.APISetting{}, ErrAPISetting
+	}
+	return setting, nil
+}
+
+// CreateAPIThread starts a new Thread with the Customer's message.
+func (s *APIService) CreateAPIThread(
+	ctx context.Context, workspaceId string, customer models.CustomerActor, createdBy models.MemberActor,
+	title, textBody string, attachments []models.APIAttachment,
+) (models.Thread, models.Message, []models.MessageAttachment, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	channel := models.ThreadChannel{}.API()
+
+	if title == "" {
+		title = textBody
+	}
+	thread := models.NewThread(
+		workspaceId, customer, createdBy, channel,
+		models.SetThreadTitle(title),
+		models.SetThreadDescription(textBody),
+	)
+	newMessage := models.NewMessage(
+		thread.ThreadId, channel,
+		models.SetMessageCustomer(customer),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(textBody),
+	)
+	thread.SetNextInboundSeq(newMessage.PreviewText())
+
+	threadMessage := models.ThreadMessage{
+		Thread:  thread,
+		Message: newMessage,
+	}
+	insThread, message, err := s.threadRepo.InsertInboundThreadMessage(ctx, threadMessage)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to insert inbound api message to new thread", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, []models.MessageAttachment{}, ErrAPIInbound
+	}
+
+	if err := s.logInbound(ctx, message); err != nil {
+		return models.Thread{}, models.Message{}, []models.MessageAttachment{}, err
+	}
+
+	insAttachments := s.processAttachments(ctx, insThread, message, attachments)
+	return insThread, message, insAttachments, nil
+}
+
+// AppendAPIThreadMessage appends the Customer's message to the existing API Thread.
+func (s *APIService) AppendAPIThreadMessage(
+	ctx context.Context, thread models.Thread, customer models.CustomerActor, createdBy models.MemberActor,
+	textBody string, attachments []models.APIAttachment,
+) (models.Message, []models.MessageAttachment, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, models.ThreadChannel{}.API(),
+		models.SetMessageCustomer(customer),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(textBody),
+	)
+	thread.SetNextInboundSeq(newMessage.PreviewText())
+	// Customer reply on existing thread applies the automatic stage transition.
+	thread.OnInboundMessage(createdBy)
+
+	threadMessage := models.ThreadMessage{
+		Thread:  &thread,
+		Message: newMessage,
+	}
+	message, err := s.threadRepo.AppendInboundThreadMessage(ctx, threadMessage)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to append inbound api message to existing thread", slog.Any("err", err))
+		return models.Message{}, []models.MessageAttachment{}, ErrAPIInbound
+	}
+
+	if err := s.logInbound(ctx, message); err != nil {
+		return models.Message{}, []models.MessageAttachment{}, err
+	}
+
+	insAttachments := s.processAttachments(ctx, thread, message, attachments)
+	return message, insAttachments, nil
+}
+
+func (s *APIService) logInbound(ctx context.Context, message models.Message) error {
+	hub := sentry.GetHubFromContext(ctx)
+	now := time.Now().UTC()
+	messageLog := models.APIMessageLog{
+		MessageId:   message.MessageId,
+		MessageType: "inbound",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := s.repo.InsertAPIMessageLog(ctx, messageLog); err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to insert inbound api message log", slog.Any("err", err))
+		return ErrAPIInbound
+	}
+	return nil
+}
+
+// processAttachments stores the Customer's attachments of the message.
+// Failures are reported, the message is already persisted.
+func (s *APIService) processAttachments(
+	ctx context.Context, thread models.Thread, message models.Message, attachments []models.APIAttachment,
+) []models.MessageAttachment {
+	hub := sentry.GetHubFromContext(ctx)
+	insAttachments := make([]models.MessageAttachment, 0, len(attachments))
+	if len(attachments) == 0 {
+		return insAttachments
+	}
+
+	accountId := zyg.CFAccountId()
+	accessKeyId := zyg.R2AccessKeyId()
+	accessKeySecret := zyg.R2AccessSecretKey()
+	s3Bucket := zyg.S3Bucket()
+	s3Client, err := store.NewS3(ctx, s3Bucket, accountId, accessKeyId, accessKeySecret)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to connect S3 to process api attachments", slog.Any("err", err))
+		return insAttachments
+	}
+
+	for _, a := range attachments {
+		att, attErr := ProcessMessageAttachment(
+			ctx, thread.WorkspaceId, thread.ThreadId, message.MessageId,
+			a.Content, a.ContentType, a.Filename, s3Client,
+		)
+		if attErr != nil {
+			hub.Scope().SetTag("messageId", att.MessageId)
+			hub.Scope().SetTag("attachmentId", att.AttachmentId)
+			hub.CaptureException(attErr)
+			slog.Error(
+				"failed to process api message attachment",
+				slog.Any("err", attErr),
+				slog.Any("attachmentId", att.AttachmentId),
+			)
+		}
+		insAttachment, err := s.threadRepo.InsertMessageAttachment(ctx, att)
+		if err != nil {
+			slog.Error("failed to insert api message attachment", slog.Any("err", err))
+			continue
+		}
+		insAttachments = append(insAttachments, insAttachment)
+	}
+	return insAttachments
+}
+
+// SendThreadAPIReply appends the Member's reply to the API Thread and delivers it to the workspace webhook.
+// Failed delivery is recorded in the message log, the reply is kept.
+func (s *APIService) SendThreadAPIReply(
+	ctx context.Context, setting models.APISetting, thread models.Thread,
+	member models.Member, customer models.Customer, textBody string,
+) (models.Message, models.APIMessageLog, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, models.ThreadChannel{}.API(),
+		models.SetMessageMember(member.AsMemberActor()),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(textBody),
+	)
+	thread.SetNextOutboundSeq(member.AsMemberActor(), newMessage.PreviewText())
+	thread.OnOutboundMessage(member.AsMemberActor())
+
+	threadMessage := models.ThreadMessage{
+		Thread:  &thread,
+		Message: newMessage,
+	}
+	message, err := s.threadRepo.AppendOutboundThreadMessage(ctx, threadMessage)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to append outbound api thread message", slog.Any("err", err))
+		return models.Message{}, models.APIMessageLog{}, ErrAPIOutbound
+	}
+
+	result := s.webhook.Deliver(ctx, setting, models.NewAPIReplyEvent(thread, customer, message))
+	status := models.APIWebhookStatus{}.Delivered()
+	if !result.IsDelivered() {
+		status = models.APIWebhookStatus{}.Failed()
+		slog.Error("failed to deliver api reply webhook",
+			slog.Any("messageId", message.MessageId), slog.Any("error", result.Error))
+	}
+
+	now := time.Now().UTC()
+	messageLog := models.APIMessageLog{
+		MessageId:     message.MessageId,
+		MessageType:   "outbound",
+		WebhookStatus: &status,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if result.StatusCode != 0 {
+		messageLog.WebhookCode = &result.StatusCode
+	}
+	if result.Error != "" {
+		messageLog.WebhookError = &result.Error
+	}
+	messageLog, err = s.repo.InsertAPIMessageLog(ctx, messageLog)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to insert outbound api message log", slog.Any("err", err))
+		return models.Message{}, models.APIMessageLog{}, ErrAPIOutbound
+	}
+	return message, messageLog, nil
+}
+
+func (s *APIService) ListThreadAPILogs(ctx context.Context, threadId string) ([]models.APIMessageLog, error) {
+	logs, err := s.repo.FetchAPIMessageLogsByThreadId(ctx, threadId)
+	if err != nil {
+		return []models.APIMessageLog{}, ErrAPILog
+	}
+	return logs, nil
+}
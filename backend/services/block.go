@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+type BlocklistService struct {
+	repo ports.BlocklistRepositorer
+}
+
+func NewBlocklistService(repo ports.BlocklistRepositorer) *BlocklistService {
+	return &BlocklistService{
+		repo: repo,
+	}
+}
+
+// CheckSender checks the sender email address, its domain and the IP address against the workspace blocklist.
+// When more than one block matches, drop takes precedence over quarantine.
+func (b *BlocklistService) CheckSender(
+	ctx context.Context, workspaceId, email, ip string) (models.SenderBlockVerdict, error) {
+	var domain string
+	email = strings.ToLower(strings.TrimSpace(email))
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		domain = email[i+1:]
+	}
+
+	blocks, err := b.repo.FetchMatchingSenderBlocks(ctx, workspaceId, email, domain, ip)
+	if err != nil {
+		return models.SenderBlockVerdict{}, ErrBlocklist
+	}
+
+	var verdict models.SenderBlockVerdict
+	for _, block := range blocks {
+		if verdict.IsDrop() {
+			break
+		}
+		verdict = models.SenderBlockVerdict{
+			IsBlocked: true,
+			Kind:      block.Kind,
+			Value:     block.Value,
+			Action:    block.Action,
+		}
+	}
+	if verdict.IsBlocked {
+		slog.Info("sender blocked",
+			slog.Any("workspaceId", workspaceId),
+			slog.Any("kind", verdict.Kind), slog.Any("action", verdict.Action))
+	}
+	return verdict, nil
+}
+
+func (b *BlocklistService) ListSenderBlocks(ctx context.Context, workspaceId string) ([]models.SenderBlock, error) {
+	blocks, err := b.repo.FetchSenderBlocksByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return []models.SenderBlock{}, ErrBlocklist
+	}
+	return blocks, nil
+}
+
+// BlockSender adds the sender block, replacing the action of the existing block for the same sender.
+func (b *BlocklistService) BlockSender(ctx context.Context, block models.SenderBlock) (models.SenderBlock, error) {
+	audit := models.NewBlockAudit(
+		block.WorkspaceId, models.BlockAuditAction{}.Block(), block.Kind, block.Value, block.Reason, block.CreatedBy)
+	block, err := b.repo.UpsertSenderBlock(ctx, block, audit)
+	if err != nil {
+		return models.SenderBlock{}, ErrBlocklist
+	}
+	return block, nil
+}
+
+func (b *BlocklistService) UnblockSender(
+	ctx context.Context, workspaceId, blockId string, member models.MemberActor, reason *string) error {
+	// kind and value are set from the removed block.
+	audit := models.NewBlockAudit(workspaceId, models.BlockAuditAction{}.Unblock(), "", "", reason, member)
+	err := b.repo.DeleteSenderBlockById(ctx, workspaceId, blockId, audit)
+	if errors.Is(err, repository.ErrEmpty) {
+		return ErrSenderBlockNotFound
+	}
+	if err != nil {
+		return ErrBlocklist
+	}
+	return nil
+}
+
+// BlockCustomer blocks the customer and revokes the customer's widget sessions.
+func (b *BlocklistService) BlockCustomer(
+	ctx context.Context, customer models.Customer, member models.MemberActor, reason *string,
+) (models.Customer, error) {
+	customer.Block()
+	audit := models.NewBlockAudit(
+		customer.WorkspaceId, models.BlockAuditAction{}.Block(),
+		models.BlockKind{}.Customer(), customer.CustomerId, reason, member)
+	customer, err := b.repo.ModifyCustomerBlock(ctx, customer, audit)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.Customer{}, ErrCustomerNotFound
+	}
+	if err != nil {
+		return models.Customer{}, ErrBlocklist
+	}
+	return customer, nil
+}
+
+// UnblockCustomer reverses the customer block and restores the customer's widget sessions.
+func (b *BlocklistService) UnblockCustomer(
+	ctx context.Context, customer models.Customer, member models.MemberActor, reason *string,
+) (models.Customer, error) {
+	customer.Unblock()
+	audit := models.NewBlockAudit(
+		customer.WorkspaceId, models.BlockAuditAction{}.Unblock(),
+		models.BlockKind{}.Customer(), customer.CustomerId, reason, member)
+	customer, err := b.repo.ModifyCustomerBlock(ctx, customer, audit)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.Customer{}, ErrCustomerNotFound
+	}
+	if err != nil {
+		return models.Customer{}, ErrBlocklist
+	}
+	return customer, nil
+}
+
+func (b *BlocklistService) ListBlockAudits(ctx context.Context, workspaceId string) ([]models.BlockAudit, error) {
+	audits, err := b.repo.FetchBlockAuditsByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return []models.BlockAudit{}, ErrBlocklist
+	}
+	return audits, nil
+}
@@ -0,0 +1,618 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/adapters/store"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/utils"
+)
+
+// Automated mail e.g. the follow-up sent to the Customer within the window is limited,
+// so that the auto-responder on the other end is not flooded.
+const (
+	automatedMailRateLimit  = 3
+	automatedMailRateWindow = 24 * time.Hour
+)
+
+// ChannelService runs the Thread message pipeline for the channels plugged in with the ChannelAdapter.
+// Channel specifics are left to the adapter, persisting the Thread, message and the message log is common.
+type ChannelService struct {
+	repo     ports.ThreadRepositorer
+	adapters map[string]ports.ChannelAdapter
+}
+
+func NewChannelService(repo ports.ThreadRepositorer, adapters ...ports.ChannelAdapter) *ChannelService {
+	s := &ChannelService{
+		repo:     repo,
+		adapters: make(map[string]ports.ChannelAdapter, len(adapters)),
+	}
+	for _, a := range adapters {
+		s.adapters[a.Channel()] = a
+	}
+	return s
+}
+
+func (s *ChannelService) adapter(channel string) (ports.ChannelAdapter, error) {
+	a, ok := s.adapters[channel]
+	if !ok {
+		return nil, ErrChannelUnsupported
+	}
+	return a, nil
+}
+
+// NormalizeInbound normalizes the channel's inbound request payload.
+func (s *ChannelService) NormalizeInbound(
+	ctx context.Context, channel string, workspaceId string, payload map[string]interface{},
+) (models.ChannelInbound, error) {
+	adapter, err := s.adapter(channel)
+	if err != nil {
+		return models.ChannelInbound{}, err
+	}
+	return adapter.NormalizeInbound(ctx, workspaceId, payload)
+}
+
+// IsInboundProcessed checks if the channel inbound message is already processed, providers retry webhooks.
+func (s *ChannelService) IsInboundProcessed(ctx context.Context, channel string, externalId string) (bool, error) {
+	exists, err := s.repo.CheckChannelMessageExists(ctx, channel, externalId)
+	if err != nil {
+		return false, ErrChannelInbound
+	}
+	return exists, nil
+}
+
+// ResolveCustomer returns the Customer the inbound message is from.
+func (s *ChannelService) ResolveCustomer(
+	ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound) (models.Customer, error) {
+	adapter, err := s.adapter(inbound.Channel)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	return adapter.ResolveCustomer(ctx, workspace, inbound)
+}
+
+// ProcessInbound appends the Customer's inbound message to the Thread matched by the channel adapter,
+// otherwise starts a new Thread. Blocked sender's message is dropped without being processed.
+// Attachments if any are uploaded and persisted after the message.
+func (s *ChannelService) ProcessInbound(
+	ctx context.Context, workspaceId string, customer models.Customer, createdBy models.MemberActor,
+	inbound models.ChannelInbound,
+) (models.Thread, models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	adapter, err := s.adapter(inbound.Channel)
+	if err != nil {
+		return models.Thread{}, models.Message{}, err
+	}
+
+	if inbound.Block.IsDrop() {
+		slog.Info("dropped channel inbound from blocked sender",
+			slog.Any("channel", inbound.Channel), slog.Any("kind", inbound.Block.Kind))
+		return models.Thread{}, models.Message{}, ErrSenderBlocked
+	}
+
+	thread, err := adapter.MatchThread(ctx, workspaceId, customer, inbound)
+	if err != nil {
+		return models.Thread{}, models.Message{}, err
+	}
+	threadExists := thread != nil
+	if !threadExists {
+		var opts []models.ThreadOption
+		if inbound.Subject != "" {
+			opts = append(opts,
+				models.SetThreadTitle(inbound.Subject), models.SetThreadDescription(inbound.TextBody))
+		}
+		thread = models.NewThread(workspaceId, customer.AsCustomerActor(), createdBy, inbound.Channel, opts...)
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, inbound.Channel,
+		models.SetMessageCustomer(customer.AsCustomerActor()),
+		models.SetHTMLBody(sanitizeMessageHTML(inbound.HTMLBody)),
+		models.SetMessageTextBody(inbound.TextBody),
+		models.SetMarkdownBody(inbound.MarkdownBody),
+		models.SetMessageOriginalTextBody(inbound.OriginalTextBody),
+		models.SetMessageAutomated(inbound.IsAutomated),
+	)
+	thread.SetNextInboundSeq(newMessage.PreviewText())
+	// Customer reply on existing thread applies the automatic stage transition.
+	// Auto-reply e.g. out-of-office keeps the stage as is, the Customer is yet to respond.
+	if threadExists && !inbound.IsAutomated {
+		thread.OnInboundMessage(createdBy)
+	}
+	// Flagged by the spam pipeline, overrides the stage transition.
+	// Quarantined by the blocklist, message from the blocked sender is kept in spam stage for review.
+	if inbound.Spam.IsSpam || inbound.Block.IsQuarantine() {
+		thread.MarkSpam(createdBy)
+	}
+
+	threadMessage := models.ThreadMessage{
+		Thread:       thread,
+		Message:      newMessage,
+		Log:          inbound.MessageLog(newMessage.MessageId),
+		Participants: inbound.MessageParticipants(newMessage.MessageId),
+	}
+	var message models.Message
+	if threadExists {
+		message, err = s.repo.AppendInboundThreadMessage(ctx, threadMessage)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to append channel inbound message", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrChannelInbound
+		}
+	} else {
+		var insThread models.Thread
+		insThread, message, err = s.repo.InsertInboundThreadMessage(ctx, threadMessage)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to insert channel inbound message", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrChannelInbound
+		}
+		thread = &insThread
+	}
+
+	// The message is persisted, attachments failed to upload are resumed when the inbound is processed again.
+	var attErr error
+	if len(inbound.Attachments) > 0 {
+		attErr = saveMessageAttachments(ctx, s.repo, *thread, message, inbound.Attachments)
+	}
+
+	// The message is persisted, failing to keep the participants with the Thread is not retried.
+	participants, err := adapter.InboundParticipants(ctx, workspaceId, *thread, inbound)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to get channel inbound participants", slog.Any("err", err))
+	} else if len(participants) > 0 {
+		if err := s.repo.UpsertThreadParticipants(ctx, participants); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to save thread participants", slog.Any("err", err))
+		}
+	}
+	return *thread, message, attErr
+}
+
+// ResumeInboundAttachments saves the attachments of the already processed inbound message that were not saved
+// on the earlier processing, e.g. failed to upload. Saved attachments are kept, failed attachments are
+// processed again. Returns ErrChannelInboundAttachments if any still fails.
+func (s *ChannelService) ResumeInboundAttachments(
+	ctx context.Context, workspaceId string, inbound models.ChannelInbound) error {
+	if len(inbound.Attachments) == 0 {
+		return nil
+	}
+	messageId, err := s.repo.LookupInboundMessageIdByExternalId(ctx, inbound.Channel, inbound.ExternalId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return nil
+	}
+	if err != nil {
+		return ErrChannelInbound
+	}
+	message, err := s.repo.LookupThreadMessageById(ctx, messageId)
+	if err != nil {
+		return ErrChannelInbound
+	}
+	saved, err := s.repo.FetchMessageAttachmentsByMessageId(ctx, messageId)
+	if err != nil {
+		return ErrChannelInbound
+	}
+	pending := pendingAttachments(inbound.Attachments, saved)
+	if len(pending) == 0 {
+		return nil
+	}
+	if err := s.repo.DeleteFailedMessageAttachments(ctx, messageId); err != nil {
+		return ErrChannelInbound
+	}
+	slog.Info("resuming channel inbound attachments",
+		slog.Any("messageId", messageId), slog.Any("pending", len(pending)))
+	thread := models.Thread{WorkspaceId: workspaceId, ThreadId: message.ThreadId}
+	return saveMessageAttachments(ctx, s.repo, thread, message, pending)
+}
+
+// pendingAttachments returns the inbound attachments without the saved attachment, matched by the name,
+// the content type and the content ID. Unnamed attachments are saved with the generated name.
+func pendingAttachments(
+	attachments []models.ChannelAttachment, saved []models.MessageAttachment) []models.ChannelAttachment {
+	matched := make([]bool, len(saved))
+	var pending []models.ChannelAttachment
+	for _, a := range attachments {
+		found := false
+		for i, att := range saved {
+			if matched[i] || att.HasError || att.ContentType != a.ContentType || att.ContentId != a.ContentId {
+				continue
+			}
+			if (a.Name != "" && att.Name == a.Name) || (a.Name == "" && strings.HasPrefix(att.Name, "Attachment_")) {
+				matched[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			pending = append(pending, a)
+		}
+	}
+	return pending
+}
+
+// saveMessageAttachments uploads and persists the attachments of the inbound message,
+// failed attachments are kept with the error. Returns ErrChannelInboundAttachments if any failed
+// to upload or persist, to be resumed.
+func saveMessageAttachments(
+	ctx context.Context, repo ports.ThreadRepositorer, thread models.Thread, message models.Message,
+	attachments []models.ChannelAttachment,
+) error {
+	hub := sentry.GetHubFromContext(ctx)
+
+	accountId := zyg.CFAccountId()
+	accessKeyId := zyg.R2AccessKeyId()
+	accessKeySecret := zyg.R2AccessSecretKey()
+	s3Bucket := zyg.S3Bucket()
+	s3Client, err := store.NewS3(ctx, s3Bucket, accountId, accessKeyId, accessKeySecret)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to connect S3 to process inbound message attachments", slog.Any("err", err))
+		return ErrChannelInboundAttachments
+	}
+
+	var failed bool
+
+	for _, a := range attachments {
+		att, attErr := ProcessMessageAttachment(
+			ctx, thread.WorkspaceId, thread.ThreadId, message.MessageId,
+			a.Content, a.ContentType, a.Name, s3Client,
+		)
+		if attErr != nil {
+			hub.Scope().SetTag("messageId", att.MessageId)
+			hub.Scope().SetTag("attachmentId", att.AttachmentId)
+			hub.Scope().SetTag("attachmentName", att.Name)
+			hub.Scope().SetTag("attachmentMD5Hash", att.MD5Hash)
+			hub.CaptureException(attErr)
+			slog.Error(
+				"failed to process inbound message attachment",
+				slog.Any("err", attErr),
+				slog.Any("attachmentId", att.AttachmentId),
+			)
+			// Content is as received, only the failed upload is resumed.
+			if errors.Is(attErr, ErrMessageAttachmentUpload) {
+				failed = true
+			}
+		}
+		att.ContentId = a.ContentId
+		// Persists processed inbound message attachment, failed attachments are kept with the error.
+		if _, err := repo.InsertMessageAttachment(ctx, att); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to insert inbound message attachment", slog.Any("err", err))
+			failed = true
+		}
+	}
+	if failed {
+		return ErrChannelInboundAttachments
+	}
+	return nil
+}
+
+// sanitizeMessageHTML returns the message HTML with only the allowlisted tags and attributes,
+// inline images are kept to be resolved with the message attachments when served.
+// HTML that fails to parse is dropped, the message is kept with the text.
+func sanitizeMessageHTML(htmlBody string) string {
+	if htmlBody == "" {
+		return ""
+	}
+	sanitized, err := utils.SanitizeHTML(htmlBody, utils.HTMLSanitizeOptions{})
+	if err != nil {
+		slog.Error("failed to sanitize message html", slog.Any("err", err))
+		return ""
+	}
+	return sanitized
+}
+
+// SendReply delivers the Member's reply on the Thread channel, then appends the reply to the Thread
+// with the message log if tracked with the channel provider.
+func (s *ChannelService) SendReply(
+	ctx context.Context, workspace models.Workspace, thread models.Thread,
+	member models.Member, customer models.Customer, reply models.ChannelReply,
+) (models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	adapter, err := s.adapter(thread.Channel)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	// Member's HTML is kept sanitized, as delivered and rendered in the dashboard.
+	htmlBody := sanitizeMessageHTML(reply.HTMLBody)
+
+	// extract from HTML if text is empty
+	// fallback to specified text in any case
+	textBody := reply.TextBody
+	if textBody == "" && htmlBody != "" {
+		extractedText, err := utils.ExtractTextFromHTML(htmlBody)
+		if err != nil {
+			hub.CaptureException(err)
+		} else {
+			textBody = extractedText
+		}
+	}
+
+	markdownBody := reply.TextBody
+	if htmlBody != "" {
+		markdownBody, err = utils.HTMLToMarkdown(htmlBody)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to convert HTML to markdown for channel reply", slog.Any("err", err))
+			markdownBody = htmlBody // fallback to HTML
+		}
+	}
+
+	// Reply by the system member is automated, e.g. the follow-up.
+	isAutomated := member.IsMemberSystem()
+	if isAutomated {
+		if err := s.checkAutomatedReply(ctx, thread, customer); err != nil {
+			return models.Message{}, err
+		}
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, thread.Channel,
+		models.SetMessageMember(member.AsMemberActor()),
+		models.SetHTMLBody(htmlBody),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(markdownBody),
+		models.SetMessageAutomated(isAutomated),
+	)
+	thread.SetNextOutboundSeq(member.AsMemberActor(), newMessage.PreviewText())
+	thread.OnOutboundMessage(member.AsMemberActor())
+
+	attachments, err := s.replyAttachments(ctx, thread, member, newMessage.MessageId, reply.AttachmentIds)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	current, err := s.repo.FetchThreadParticipants(ctx, thread.ThreadId)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.Message{}, ErrChannelOutbound
+	}
+	participants, added, removed := replyParticipants(thread, customer, current, reply)
+
+	delivery := models.ChannelDelivery{
+		Workspace:    workspace,
+		Thread:       thread,
+		Customer:     customer,
+		Member:       member,
+		Message:      *newMessage,
+		Participants: participants,
+		Bcc:          reply.Bcc,
+		Attachments:  attachments,
+	}
+	threadMessage := models.ThreadMessage{
+		Thread:      &thread,
+		Message:     newMessage,
+		Attachments: attachments,
+	}
+	if thread.Channel == (models.ThreadChannel{}).Email() {
+		// Mail reply is persisted first and queued, delivered in the background with the retries.
+		// Mail recipients are kept with the reply.
+		outbound := models.NewOutboundMail(delivery)
+		threadMessage.Outbound = &outbound
+		threadMessage.Participants = replyMessageParticipants(
+			newMessage.MessageId, customer, participants, reply.Bcc)
+	} else {
+		messageLog, err := adapter.Deliver(ctx, delivery)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to deliver channel reply",
+				slog.Any("channel", thread.Channel), slog.Any("err", err))
+			return models.Message{}, err
+		}
+		threadMessage.Log = messageLog
+	}
+	message, err := s.repo.AppendOutboundThreadMessage(ctx, threadMessage)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to append channel outbound message", slog.Any("err", err))
+		return models.Message{}, ErrChannelOutbound
+	}
+	if threadMessage.Outbound != nil {
+		message.Delivery = threadMessage.Outbound.MessageDelivery()
+	}
+
+	// The reply is accepted, failing to update the Thread participants is not retried.
+	if len(added) > 0 {
+		if err := s.repo.UpsertThreadParticipants(ctx, added); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to save thread participants", slog.Any("err", err))
+		}
+	}
+	if len(removed) > 0 {
+		if err := s.repo.DeleteThreadParticipants(ctx, thread.ThreadId, removed); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to remove thread participants", slog.Any("err", err))
+		}
+	}
+	return message, nil
+}
+
+// checkAutomatedReply checks the automated reply can be sent on the Thread.
+// Automated reply to the Customer's auto-reply is suppressed to avoid the auto-reply loop,
+// automated mail is rate limited per Customer.
+func (s *ChannelService) checkAutomatedReply(
+	ctx context.Context, thread models.Thread, customer models.Customer) error {
+	latest, err := s.repo.LookupLatestThreadMessage(ctx, thread.ThreadId)
+	if err != nil && !errors.Is(err, repository.ErrEmpty) {
+		return ErrChannelOutbound
+	}
+	if err == nil && latest.IsAutomated && latest.Customer != nil {
+		slog.Info("suppressed automated reply to auto-reply", slog.Any("threadId", thread.ThreadId))
+		return ErrAutomatedReplySuppressed
+	}
+
+	if thread.Channel != (models.ThreadChannel{}).Email() {
+		return nil
+	}
+	since := time.Now().UTC().Add(-automatedMailRateWindow)
+	count, err := s.repo.CountAutomatedOutboundMessagesSince(ctx, customer.CustomerId, since)
+	if err != nil {
+		return ErrChannelOutbound
+	}
+	if count >= automatedMailRateLimit {
+		slog.Info("automated mail rate limited",
+			slog.Any("customerId", customer.CustomerId), slog.Any("count", count))
+		return ErrAutomatedReplyRateLimited
+	}
+	return nil
+}
+
+// replyAttachments returns the Member's uploads for the Thread as the attachments of the reply message.
+// Returns ErrMessageAttachmentNotFound if any of the uploads is not found or already sent.
+func (s *ChannelService) replyAttachments(
+	ctx context.Context, thread models.Thread, member models.Member, messageId string, attachmentIds []string,
+) ([]models.MessageAttachment, error) {
+	if len(attachmentIds) == 0 {
+		return nil, nil
+	}
+	if len(attachmentIds) > models.MaxReplyAttachments {
+		return nil, ErrMessageAttachmentInvalid
+	}
+	uploads, err := s.repo.FetchAttachmentUploads(ctx, thread.ThreadId, member.MemberId, attachmentIds)
+	if err != nil {
+		return nil, ErrMessageAttachment
+	}
+	if len(uploads) != len(attachmentIds) {
+		return nil, ErrMessageAttachmentNotFound
+	}
+	var size int64
+	attachments := make([]models.MessageAttachment, 0, len(uploads))
+	for _, u := range uploads {
+		size += u.Size
+		attachments = append(attachments, u.MessageAttachment(messageId))
+	}
+	// Mail reply is queued for delivery, the attachments over the mail size are not retried.
+	if thread.Channel == (models.ThreadChannel{}).Email() && size > models.MaxMailAttachmentsSize {
+		return nil, ErrMailAttachments
+	}
+	return attachments, nil
+}
+
+// replyParticipants applies the reply's CC changes to the Thread participants.
+// Returns the participants the reply is sent to, the added and the removed participant's emails.
+// The Thread's Customer is always the recipient, never added as the participant.
+func replyParticipants(
+	thread models.Thread, customer models.Customer, current []models.ThreadParticipant, reply models.ChannelReply,
+) ([]models.ThreadParticipant, []models.ThreadParticipant, []string) {
+	removing := make(map[string]bool, len(reply.RemoveCc))
+	for _, email := range reply.RemoveCc {
+		removing[strings.ToLower(strings.TrimSpace(email))] = true
+	}
+	seen := make(map[string]bool, len(current)+len(reply.AddCc))
+	if customer.Email.Valid {
+		seen[strings.ToLower(customer.Email.String)] = true
+	}
+
+	var participants, added []models.ThreadParticipant
+	var removed []string
+	for _, p := range current {
+		// Removed as stored, participants stored before the emails were lower cased are matched too.
+		email := strings.ToLower(p.Email)
+		if removing[email] {
+			removed = append(removed, p.Email)
+			continue
+		}
+		seen[email] = true
+		participants = append(participants, p)
+	}
+	for _, addr := range reply.AddCc {
+		addr.Email = strings.ToLower(strings.TrimSpace(addr.Email))
+		if addr.Email == "" || seen[addr.Email] || removing[addr.Email] {
+			continue
+		}
+		seen[addr.Email] = true
+		p := models.NewThreadParticipant(thread.ThreadId, addr, models.ThreadParticipantRole{}.Cc())
+		participants = append(participants, p)
+		added = append(added, p)
+	}
+	return participants, added, removed
+}
+
+// replyMessageParticipants returns the recipients of the reply, the Customer as To,
+// the Thread participants with their role and the reply's Bcc.
+func replyMessageParticipants(
+	messageId string, customer models.Customer, participants []models.ThreadParticipant, bcc []models.MailAddress,
+) []models.MessageParticipant {
+	roles := models.ThreadParticipantRole{}
+	now := time.Now().UTC()
+	recipients := make([]models.MessageParticipant, 0, len(participants)+len(bcc)+1)
+	seen := make(map[string]bool, cap(recipients))
+	add := func(addr models.MailAddress, role string) {
+		email := strings.ToLower(addr.Email)
+		if email == "" || seen[email] {
+			return
+		}
+		seen[email] = true
+		recipients = append(recipients, models.MessageParticipant{
+			MessageId: messageId,
+			Email:     email,
+			Name:      addr.Name,
+			Role:      role,
+			CreatedAt: now,
+		})
+	}
+	if customer.Email.Valid {
+		add(models.MailAddress{Name: customer.Name, Email: customer.Email.String}, roles.To())
+	}
+	for _, p := range participants {
+		add(p.Address(), p.Role)
+	}
+	for _, addr := range bcc {
+		add(addr, roles.Bcc())
+	}
+	return recipients
+}
+
+// UpdateDeliveryStatus updates the workspace outbound message log with the delivery status
+// as reported by the channel provider.
+// Provider callbacks can arrive out of order, the status that does not move forward is skipped
+// and the current message log is returned as is.
+func (s *ChannelService) UpdateDeliveryStatus(
+	ctx context.Context, workspaceId string, channel string, payload map[string]interface{},
+) (models.ChannelMessageLog, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	adapter, err := s.adapter(channel)
+	if err != nil {
+		return models.ChannelMessageLog{}, err
+	}
+	status, err := adapter.ParseDeliveryStatus(ctx, payload)
+	if err != nil {
+		return models.ChannelMessageLog{}, err
+	}
+	current, err := s.repo.FetchChannelMessageLogByExternalId(ctx, workspaceId, channel, status.ExternalId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.ChannelMessageLog{}, ErrChannelLogNotFound
+	}
+	if err != nil {
+		return models.ChannelMessageLog{}, ErrChannel
+	}
+	if !(models.ChannelMessageStatus{}).CanUpdate(current.Status, status.Status) {
+		return current, nil
+	}
+	messageLog, err := s.repo.ModifyChannelMessageLogStatus(ctx, workspaceId, channel, status)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.ChannelMessageLog{}, ErrChannelLogNotFound
+	}
+	if err != nil {
+		return models.ChannelMessageLog{}, ErrChannel
+	}
+	// The status is persisted, failing to apply it to the Customer and the Thread is not retried.
+	if err := adapter.OnDeliveryStatus(ctx, workspaceId, status, messageLog); err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to apply channel delivery status",
+			slog.Any("messageId", messageLog.MessageId), slog.Any("err", err))
+	}
+	return messageLog, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// ChatChannel is the in-app chat channel adapter.
+// Chat is received from the authenticated Customer with the widget, and is read back by the widget,
+// there is no provider to normalize inbound from, deliver to or receive delivery status from.
+type ChatChannel struct {
+	ws   ports.WorkspaceServicer
+	repo ports.ThreadRepositorer
+}
+
+func NewChatChannel(ws ports.WorkspaceServicer, repo ports.ThreadRepositorer) *ChatChannel {
+	return &ChatChannel{
+		ws:   ws,
+		repo: repo,
+	}
+}
+
+func (c *ChatChannel) Channel() string {
+	return models.ThreadChannel{}.InAppChat()
+}
+
+// NormalizeInbound is not supported, chat inbound is created with models.NewChatInbound.
+func (c *ChatChannel) NormalizeInbound(
+	context.Context, string, map[string]interface{}) (models.ChannelInbound, error) {
+	return models.ChannelInbound{}, ErrChannelUnsupported
+}
+
+// ResolveCustomer returns the authenticated Customer of the chat.
+func (c *ChatChannel) ResolveCustomer(
+	ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound) (models.Customer, error) {
+	if inbound.CustomerId == nil {
+		return models.Customer{}, ErrCustomerNotFound
+	}
+	return c.ws.GetCustomer(ctx, workspace.WorkspaceId, *inbound.CustomerId, nil)
+}
+
+// MatchThread returns the Customer's chat Thread the message is sent on.
+// Without the Thread ID a new Thread is started.
+func (c *ChatChannel) MatchThread(
+	ctx context.Context, workspaceId string, customer models.Customer, inbound models.ChannelInbound,
+) (*models.Thread, error) {
+	if inbound.ThreadId == nil {
+		return nil, nil
+	}
+	channel := c.Channel()
+	thread, err := c.repo.LookupByWorkspaceThreadId(ctx, workspaceId, *inbound.ThreadId, &channel)
+	if errors.Is(err, repository.ErrEmpty) {
+		return nil, ErrThreadNotFound
+	}
+	if err != nil {
+		return nil, ErrThreadChat
+	}
+	if thread.Customer.CustomerId != customer.CustomerId {
+		return nil, ErrThreadNotFound
+	}
+	return &thread, nil
+}
+
+// InboundParticipants has no participants, chat is only with the Thread's Customer.
+func (c *ChatChannel) InboundParticipants(
+	context.Context, string, models.Thread, models.ChannelInbound) ([]models.ThreadParticipant, error) {
+	return nil, nil
+}
+
+// Deliver has nothing to deliver, the widget reads the Thread messages.
+func (c *ChatChannel) Deliver(context.Context, models.ChannelDelivery) (*models.ChannelMessageLog, error) {
+	return nil, nil
+}
+
+// ParseDeliveryStatus is not supported, chat has no delivery status callbacks.
+func (c *ChatChannel) ParseDeliveryStatus(
+	context.Context, map[string]interface{}) (models.ChannelDeliveryStatus, error) {
+	return models.ChannelDeliveryStatus{}, ErrChannelUnsupported
+}
+
+// OnDeliveryStatus has nothing to apply, chat has no delivery status callbacks.
+func (c *ChatChannel) OnDeliveryStatus(
+	context.Context, string, models.ChannelDeliveryStatus, models.ChannelMessageLog) error {
+	return nil
+}
@@ -0,0 +1,521 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/integrations/email"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/utils"
+)
+
+// EmailChannel is the email channel adapter with Postmark as the inbound mail provider.
+// Inbound mail is matched to the Thread with the mail threading headers, the reply token and the subject,
+// replies are sent with the workspace mail sender.
+type EmailChannel struct {
+	ws   ports.WorkspaceServicer
+	ms   ports.MailServicer
+	cs   ports.CustomerServicer
+	repo ports.ThreadRepositorer
+}
+
+func NewEmailChannel(
+	ws ports.WorkspaceServicer, ms ports.MailServicer, cs ports.CustomerServicer,
+	repo ports.ThreadRepositorer,
+) *EmailChannel {
+	return &EmailChannel{
+		ws:   ws,
+		ms:   ms,
+		cs:   cs,
+		repo: repo,
+	}
+}
+
+func (c *EmailChannel) Channel() string {
+	return models.ThreadChannel{}.Email()
+}
+
+// NormalizeInbound parses the Postmark inbound webhook payload, the mail is stripped to the reply.
+func (c *EmailChannel) NormalizeInbound(
+	_ context.Context, _ string, payload map[string]interface{}) (models.ChannelInbound, error) {
+	inboundReq, err := email.FromPostmarkInboundRequest(payload)
+	if err != nil {
+		slog.Error("failed to parse postmark inbound request", slog.Any("err", err))
+		return models.ChannelInbound{}, ErrPostmarkInbound
+	}
+	inbound := inboundReq.ToChannelInbound()
+	return inboundMailReply(inbound), nil
+}
+
+// inboundMailReply strips the quoted history, the signature and the mobile footer from the inbound mail.
+// The text as received is kept as the original if anything is stripped, the HTML is kept as is.
+// Text stripped by the provider is preferred as the reply.
+func inboundMailReply(inbound models.ChannelInbound) models.ChannelInbound {
+	text := inbound.TextBody
+	if inbound.TextReply != "" {
+		text = inbound.TextReply
+	}
+	reply := utils.ParseTextReply(text)
+	if reply.Text != strings.TrimSpace(inbound.TextBody) {
+		inbound.OriginalTextBody = inbound.TextBody
+		inbound.TextBody = reply.Text
+	}
+	inbound.MarkdownBody = inboundMailMarkdown(inbound)
+	return inbound
+}
+
+// inboundMailMarkdown cleans the inbound mail HTML into markdown - in case of error use sanitized HTML as fallback.
+// The markdown is stripped to the reply as the text. Mail without HTML is taken as the text reply.
+func inboundMailMarkdown(inbound models.ChannelInbound) string {
+	if inbound.HTMLBody == "" {
+		return inbound.TextBody
+	}
+	cleanedHTML, err := utils.CleanHTML(inbound.HTMLBody, utils.DefaultHTMLMatchers())
+	if err != nil {
+		slog.Error("failed to clean up inbound mail html", slog.Any("err", err))
+		return sanitizeMessageHTML(inbound.HTMLBody)
+	}
+	markdown, err := utils.HTMLToMarkdown(sanitizeMessageHTML(cleanedHTML))
+	if err != nil {
+		slog.Error("failed to convert html to markdown", slog.Any("err", err))
+		return sanitizeMessageHTML(inbound.HTMLBody)
+	}
+	return utils.ParseTextReply(markdown).Text
+}
+
+// ResolveCustomer returns the Customer with the sender's email, created if not exists.
+// This also marks the email as verified for the customer as inbound is received directly from mail provider.
+func (c *EmailChannel) ResolveCustomer(
+	ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound) (models.Customer, error) {
+	customer, _, err := c.ws.CreateCustomerWithEmail(
+		ctx, workspace.WorkspaceId, inbound.FromEmail, true, inbound.FromName)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	return customer, nil
+}
+
+// MatchThread returns the Thread of the mail replied to.
+// The mail is matched in order with the `In-Reply-To` and the `References` chain, the most recent
+// reference first, with the plus addressed reply token, then with the normalized subject of the Customer's
+// recently updated Thread. Otherwise, a new Thread is started.
+func (c *EmailChannel) MatchThread(
+	ctx context.Context, workspaceId string, customer models.Customer, inbound models.ChannelInbound,
+) (*models.Thread, error) {
+	// References in order of preference, the `In-Reply-To` then the `References` chain most recent first.
+	refs := make([]string, 0, len(inbound.References)+1)
+	if inbound.ReplyRef != nil {
+		refs = append(refs, *inbound.ReplyRef)
+	}
+	for i := len(inbound.References) - 1; i >= 0; i-- {
+		refs = append(refs, inbound.References[i])
+	}
+	if len(refs) > 0 {
+		thread, err := c.repo.FindThreadByChannelRefs(ctx, workspaceId, c.Channel(), refs)
+		if err == nil {
+			return &thread, nil
+		}
+		if !errors.Is(err, repository.ErrEmpty) {
+			slog.Error("failed to get existing thread for inbound mail references", slog.Any("err", err))
+			return nil, ErrThread
+		}
+		slog.Info("thread not found for inbound mail references", slog.Any("refs", len(refs)))
+	}
+
+	if inbound.ReplyToken != "" {
+		thread, err := c.matchReplyToken(ctx, workspaceId, inbound.ReplyToken)
+		if err != nil {
+			return nil, err
+		}
+		if thread != nil {
+			return thread, nil
+		}
+	}
+	return c.matchSubject(ctx, workspaceId, customer, inbound.Subject)
+}
+
+// matchReplyToken returns the Thread of the reply token signed with the workspace secret.
+func (c *EmailChannel) matchReplyToken(
+	ctx context.Context, workspaceId string, token string) (*models.Thread, error) {
+	sk, err := c.ws.GetSecretKey(ctx, workspaceId)
+	if errors.Is(err, ErrSecretKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("failed to get workspace secret key for reply token", slog.Any("err", err))
+		return nil, ErrThread
+	}
+	threadId, ok := models.VerifyMailReplyToken(token, sk.Hmac)
+	if !ok {
+		slog.Info("inbound mail reply token is not valid")
+		return nil, nil
+	}
+	channel := c.Channel()
+	thread, err := c.repo.LookupByWorkspaceThreadId(ctx, workspaceId, threadId, &channel)
+	if errors.Is(err, repository.ErrEmpty) {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Error("failed to get thread for inbound mail reply token", slog.Any("err", err))
+		return nil, ErrThread
+	}
+	return &thread, nil
+}
+
+// matchSubject returns the Customer's recently updated Thread with the same normalized subject.
+// Only the reply or forward subject is matched, the Customer's new mail with the same subject starts a new Thread.
+func (c *EmailChannel) matchSubject(
+	ctx context.Context, workspaceId string, customer models.Customer, subject string) (*models.Thread, error) {
+	normalized := models.NormalizeMailSubject(subject)
+	if normalized == "" || customer.CustomerId == "" || !models.IsMailReplySubject(subject) {
+		return nil, nil
+	}
+	since := time.Now().UTC().Add(-zyg.MailThreadSubjectWindow())
+	threads, err := c.repo.FetchRecentThreadsByCustomerId(ctx, workspaceId, customer.CustomerId, c.Channel(), since)
+	if err != nil {
+		slog.Error("failed to get recent threads for inbound mail subject", slog.Any("err", err))
+		return nil, ErrThread
+	}
+	for _, thread := range threads {
+		if models.NormalizeMailSubject(thread.Title) == normalized {
+			return &thread, nil
+		}
+	}
+	return nil, nil
+}
+
+// InboundParticipants returns the To and CC recipients of the inbound mail as the Thread participants.
+// The sender is added as CC if not the Thread's Customer, e.g. the Customer's colleague replying to all.
+// The workspace's own inbound and sender addresses are excluded, the participant is linked to the
+// existing Customer with the same email.
+func (c *EmailChannel) InboundParticipants(
+	ctx context.Context, workspaceId string, thread models.Thread, inbound models.ChannelInbound,
+) ([]models.ThreadParticipant, error) {
+	roles := models.ThreadParticipantRole{}
+	own := c.workspaceAddresses(ctx, workspaceId)
+	seen := make(map[string]bool)
+	if thread.Customer.CustomerId != "" {
+		customer, err := c.ws.GetCustomer(ctx, workspaceId, thread.Customer.CustomerId, nil)
+		if err != nil {
+			return nil, err
+		}
+		if customer.Email.Valid {
+			seen[strings.ToLower(customer.Email.String)] = true
+		}
+	}
+
+	var participants []models.ThreadParticipant
+	add := func(addr models.MailAddress, role string) {
+		email := strings.ToLower(addr.Email)
+		if email == "" || seen[email] || c.isWorkspaceAddress(workspaceId, own, email) {
+			return
+		}
+		seen[email] = true
+		addr.Email = email
+		participants = append(participants, models.NewThreadParticipant(thread.ThreadId, addr, role))
+	}
+	for _, addr := range inbound.To {
+		add(addr, roles.To())
+	}
+	for _, addr := range inbound.Cc {
+		add(addr, roles.Cc())
+	}
+	add(models.MailAddress{Name: inbound.FromName, Email: inbound.FromEmail}, roles.Cc())
+
+	for i, p := range participants {
+		customer, err := c.ws.GetCustomerByEmail(ctx, workspaceId, p.Email)
+		if errors.Is(err, ErrCustomerNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		participants[i].CustomerId = &customer.CustomerId
+	}
+	return participants, nil
+}
+
+// workspaceAddresses returns the workspace's inbound, sender and mailbox addresses without the plus address tag.
+func (c *EmailChannel) workspaceAddresses(ctx context.Context, workspaceId string) map[string]bool {
+	addrs := []string{zyg.MailFromEmail(), zyg.MailReplyTo()}
+	pmSetting, err := c.ws.GetPostmarkMailServerSetting(ctx, workspaceId)
+	if err == nil {
+		addrs = append(addrs, pmSetting.Email)
+		if pmSetting.InboundEmail != nil {
+			addrs = append(addrs, *pmSetting.InboundEmail)
+		}
+	}
+	setting, err := c.ms.GetMailSetting(ctx, workspaceId)
+	if err == nil {
+		addrs = append(addrs, setting.FromEmail, setting.ReplyTo)
+	}
+	mailboxes, err := c.ms.ListMailboxes(ctx, workspaceId)
+	if err == nil {
+		for _, mb := range mailboxes {
+			addrs = append(addrs, mb.Email)
+		}
+	}
+	own := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		if addr != "" {
+			own[models.StripPlusAddress(strings.ToLower(addr))] = true
+		}
+	}
+	return own
+}
+
+// isWorkspaceAddress checks the email is one of the workspace's own addresses,
+// including the SMTP inbound address `<workspaceId>@<inbound domain>`.
+func (c *EmailChannel) isWorkspaceAddress(workspaceId string, own map[string]bool, email string) bool {
+	email = models.StripPlusAddress(email)
+	if own[email] {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || !strings.EqualFold(email[:at], workspaceId) {
+		return false
+	}
+	inboundDomain := zyg.SMTPInboundDomain()
+	return inboundDomain == "" || strings.EqualFold(email[at+1:], inboundDomain)
+}
+
+// Deliver sends the reply mail with the workspace mail sender `In-Reply-To` the Thread's most recent mail,
+// referencing the earlier mails of the Thread, maintaining the mail thread.
+func (c *EmailChannel) Deliver(
+	ctx context.Context, delivery models.ChannelDelivery) (*models.ChannelMessageLog, error) {
+	customer := delivery.Customer
+	// Make sure we have the customer email, to send reply.
+	if !customer.Email.Valid {
+		slog.Error("customer email is not valid",
+			slog.Any("customerId", customer.CustomerId), slog.Any("email", customer.Email))
+		return nil, ErrChannelOutbound
+	}
+
+	// The mail message IDs of the Thread are used in headers for `In-Reply-To` and `References`.
+	refs, err := c.repo.FetchChannelRefsByThreadId(ctx, delivery.Thread.ThreadId, c.Channel())
+	if err != nil {
+		slog.Error("failed to get thread mail message IDs", slog.Any("err", err))
+		return nil, ErrChannelOutbound
+	}
+
+	// The message of the Thread preceding the reply is quoted in the reply.
+	var quote *models.Message
+	preceding, err := c.repo.LookupPrecedingThreadMessage(
+		ctx, delivery.Thread.ThreadId, delivery.Message.CreatedAt)
+	if err != nil && !errors.Is(err, repository.ErrEmpty) {
+		slog.Error("failed to get thread preceding message", slog.Any("err", err))
+		return nil, ErrChannelOutbound
+	}
+	if err == nil {
+		quote = &preceding
+	}
+
+	mail, err := c.ms.ComposeReplyMail(
+		ctx, delivery.Workspace, delivery.Thread, delivery.Member, customer, delivery.Message, quote)
+	if err != nil {
+		slog.Error("failed to compose reply mail", slog.Any("err", err))
+		return nil, ErrChannelOutbound
+	}
+	mail.SetThreadRefs(refs)
+	// Automated mail is marked as per RFC 3834, so that the auto-responders do not reply.
+	if delivery.Message.IsAutomated {
+		mail.Headers = append(mail.Headers, models.MailHeader{Name: "Auto-Submitted", Value: "auto-generated"})
+	}
+	mail.Attachments, err = mailAttachments(ctx, delivery.Attachments)
+	if err != nil {
+		slog.Error("failed to fetch reply mail attachments", slog.Any("err", err))
+		return nil, err
+	}
+
+	// Reply to all, the Thread participants are kept as addressed, Bcc is only for the reply.
+	roles := models.ThreadParticipantRole{}
+	for _, p := range delivery.Participants {
+		if p.Role == roles.To() {
+			mail.OtherTo = append(mail.OtherTo, p.Address().String())
+		} else {
+			mail.Cc = append(mail.Cc, p.Address().String())
+		}
+	}
+	for _, addr := range delivery.Bcc {
+		mail.Bcc = append(mail.Bcc, addr.Email)
+	}
+
+	// Replies without the mail headers are matched with the reply token.
+	sk, err := c.ws.GetOrGenerateSecretKey(ctx, delivery.Workspace.WorkspaceId)
+	if err != nil {
+		slog.Error("failed to get workspace secret key for reply token", slog.Any("err", err))
+	} else {
+		mail.ReplyToken = models.NewMailReplyToken(delivery.Thread.ThreadId, sk.Hmac)
+	}
+
+	result, err := c.ms.SendWorkspaceMail(ctx, delivery.Workspace.WorkspaceId, mail)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	messageLog := &models.ChannelMessageLog{
+		MessageId:   delivery.Message.MessageId,
+		Channel:     c.Channel(),
+		MessageType: models.ChannelMessageType{}.Outbound(),
+		ExternalId:  result.ProviderMessageId,
+		ExternalRef: &result.MessageId,
+		Payload:     result.Payload,
+		Status:      models.ChannelMessageStatus{}.Sent(),
+		SubmittedAt: result.SubmittedAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if mail.InReplyTo != "" {
+		messageLog.ReplyRef = &mail.InReplyTo
+	}
+	return messageLog, nil
+}
+
+// ParseDeliveryStatus parses the Postmark delivery, bounce, spam complaint, open and click webhook payload.
+func (c *EmailChannel) ParseDeliveryStatus(
+	_ context.Context, payload map[string]interface{}) (models.ChannelDeliveryStatus, error) {
+	statusReq, err := email.FromPostmarkStatusRequest(payload)
+	if err != nil {
+		slog.Error("failed to parse postmark status request", slog.Any("err", err))
+		return models.ChannelDeliveryStatus{}, ErrChannel
+	}
+	status, err := statusReq.ToChannelDeliveryStatus()
+	if err != nil {
+		return models.ChannelDeliveryStatus{}, ErrChannelUnsupported
+	}
+	return status, nil
+}
+
+// OnDeliveryStatus applies the delivery status to the recipient Customer and the Thread.
+// Hard bounce or spam complaint marks the Customer's email as bounced and adds the error event to the Customer,
+// the Thread is moved back to needs response if enabled in the mail setting.
+// Successful delivery clears the Customer's earlier bounce.
+func (c *EmailChannel) OnDeliveryStatus(
+	ctx context.Context, workspaceId string, status models.ChannelDeliveryStatus,
+	messageLog models.ChannelMessageLog,
+) error {
+	statuses := models.ChannelMessageStatus{}
+	if !status.IsRecipientBad && status.Status != statuses.Delivered() {
+		return nil
+	}
+
+	threadId, err := c.repo.LookupMessageThreadId(ctx, messageLog.MessageId)
+	if err != nil {
+		return ErrThread
+	}
+	// Delivery of the forwarded mail is only tracked with the message log, the recipient is not the Customer.
+	forwards, err := c.repo.FetchThreadForwardsByThreadId(ctx, threadId)
+	if err != nil {
+		return ErrThreadForward
+	}
+	for _, f := range forwards {
+		if f.MessageId == messageLog.MessageId {
+			return nil
+		}
+	}
+	channel := models.ThreadChannel{}.Email()
+	thread, err := c.repo.LookupByWorkspaceThreadId(ctx, workspaceId, threadId, &channel)
+	if errors.Is(err, repository.ErrEmpty) {
+		return ErrThreadNotFound
+	}
+	if err != nil {
+		return ErrThread
+	}
+
+	customer, err := c.deliveryCustomer(ctx, workspaceId, thread, status.Recipient)
+	if err != nil {
+		return err
+	}
+
+	if !status.IsRecipientBad {
+		if !customer.IsEmailBounced {
+			return nil
+		}
+		customer.ClearEmailBounced()
+		_, err = c.cs.UpdateCustomerEmailBounce(ctx, customer)
+		return err
+	}
+
+	customer.MarkEmailBounced(status.ErrorMessage)
+	customer, err = c.cs.UpdateCustomerEmailBounce(ctx, customer)
+	if err != nil {
+		return err
+	}
+
+	title := "Email bounced"
+	if status.Status == statuses.Complained() {
+		title = "Email marked as spam"
+	}
+	components := []models.EventComponent{
+		{
+			ComponentText: &models.ComponentText{
+				Text:      fmt.Sprintf("Reply to %s could not be delivered.", customer.Email.String),
+				TextSize:  models.TextSizeS,
+				TextColor: models.TextError,
+			},
+		},
+	}
+	if status.ErrorMessage != "" {
+		components = append(components, models.EventComponent{
+			ComponentText: &models.ComponentText{
+				Text:      status.ErrorMessage,
+				TextSize:  models.TextSizeXS,
+				TextColor: models.TextMuted,
+			},
+		})
+	}
+	event, err := models.NewEvent(
+		title,
+		models.SetEventCustomer(customer.AsCustomerActor()),
+		models.SetEventSeverity(models.SeverityError.String()),
+		models.SetEventTimestampFromStr(time.Now().UTC().Format(time.RFC3339)),
+		models.WithEventComponents(components),
+	)
+	if err != nil {
+		return ErrCustomerEvent
+	}
+	if _, err := c.cs.AddEvent(ctx, *event); err != nil {
+		return err
+	}
+
+	setting, err := c.ms.GetMailSetting(ctx, workspaceId)
+	if err != nil {
+		return err
+	}
+	if !setting.ReopenOnBounce {
+		return nil
+	}
+	member, err := c.ws.GetSystemMember(ctx, workspaceId)
+	if err != nil {
+		return err
+	}
+	thread.OnDeliveryFailure(member.AsMemberActor())
+	if _, err := c.repo.ModifyThreadById(ctx, thread, []string{"stage"}); err != nil {
+		return ErrThread
+	}
+	return nil
+}
+
+// deliveryCustomer returns the Customer of the delivery status recipient,
+// the Thread's Customer if the recipient is not reported or not a Customer.
+func (c *EmailChannel) deliveryCustomer(
+	ctx context.Context, workspaceId string, thread models.Thread, recipient string) (models.Customer, error) {
+	if recipient != "" {
+		customer, err := c.ws.GetCustomerByEmail(ctx, workspaceId, recipient)
+		if err == nil {
+			return customer, nil
+		}
+		if !errors.Is(err, ErrCustomerNotFound) {
+			return models.Customer{}, err
+		}
+	}
+	return c.ws.GetCustomer(ctx, workspaceId, thread.Customer.CustomerId, nil)
+}
@@ -15,17 +15,18 @@ import (
 	"github.com/zyghq/zyg/adapters/repository"
 	"github.com/zyghq/zyg/models"
 	"github.com/zyghq/zyg/ports"
-	"github.com/zyghq/zyg/services/tasks"
 )
 
 type CustomerService struct {
 	repo ports.CustomerRepositorer
+	ms   ports.MailServicer
 }
 
 func NewCustomerService(
-	repo ports.CustomerRepositorer) *CustomerService {
+	repo ports.CustomerRepositorer, ms ports.MailServicer) *CustomerService {
 	return &CustomerService{
 		repo: repo,
+		ms:   ms,
 	}
 }
 
@@ -100,6 +101,19 @@ func (s *CustomerService) UpdateCustomer(
 	return customer, nil
 }
 
+// UpdateCustomerEmailBounce persists the customer's email bounce state.
+func (s *CustomerService) UpdateCustomerEmailBounce(
+	ctx context.Context, customer models.Customer) (models.Customer, error) {
+	customer, err := s.repo.ModifyCustomerEmailBounceById(ctx, customer)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.Customer{}, ErrCustomerNotFound
+	}
+	if err != nil {
+		return models.Customer{}, ErrCustomer
+	}
+	return customer, nil
+}
+
 func (s *CustomerService) AddClaimedMail(
 	ctx context.Context, claimed models.ClaimedMail) (models.ClaimedMail, error) {
 	claim, err := s.repo.InsertClaimedMail(ctx, claimed)
@@ -223,14 +237,23 @@ func (s *CustomerService) ClaimMailForVerification(
 		if name != nil {
 			dup.Name = *name
 		}
-		dup, err = s.UpdateCustomer(ctx, dup)
+		customer, err = s.UpdateCustomer(ctx, dup)
 		if err != nil {
 			return models.ClaimedMail{}, ErrCustomer
 		}
 	}
-	verifyLink := zyg.GetXServerUrl() + "/mail/kyc/?t=" + claim.Token
-	err = tasks.SendKycMail(claim.Email, contextMessage, verifyLink)
-	slog.Error("tasks send kyc mail failed", slog.Any("err", err))
+	// Verification mail is sent in the Customer's locale with the workspace mail sender.
+	data := models.MailTemplateData{
+		CustomerName:  customer.Name,
+		CustomerEmail: claim.Email,
+		PreviewText:   contextMessage,
+		Link:          zyg.GetXServerUrl() + "/mail/kyc/?t=" + claim.Token,
+	}
+	_, err = s.ms.SendTemplateMail(
+		ctx, customer.WorkspaceId, models.MailTemplateKind{}.Verification(), customer.Locale, claim.Email, data)
+	if err != nil {
+		slog.Error("failed to send verification mail", slog.Any("err", err))
+	}
 	return claim, nil
 }
 
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// CustomerMailService sends the transactional mail of the Thread to the Customer with the workspace mail templates
+// and the workspace mail sender - the CSAT survey and the transcript of the resolved Thread, and the notification
+// of the Member's reply on the chat Thread. Each is sent as per the workspace customer mail setting.
+type CustomerMailService struct {
+	ws  ports.WorkspaceServicer
+	ths ports.ThreadServicer
+	ms  ports.MailServicer
+}
+
+func NewCustomerMailService(
+	ws ports.WorkspaceServicer, ths ports.ThreadServicer, ms ports.MailServicer) *CustomerMailService {
+	return &CustomerMailService{
+		ws:  ws,
+		ths: ths,
+		ms:  ms,
+	}
+}
+
+// OnThreadResolved sends the CSAT survey and, unless of the email Thread already with the Customer's mailbox,
+// the transcript of the resolved Thread as enabled. The Thread is already resolved, failures are only reported.
+func (s *CustomerMailService) OnThreadResolved(ctx context.Context, thread models.Thread) {
+	hub := sentry.GetHubFromContext(ctx)
+	if !thread.IsResolved() {
+		return
+	}
+	setting, err := s.ms.GetCustomerMailSetting(ctx, thread.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer mail setting", slog.Any("err", err))
+		return
+	}
+	if setting.SendCSAT {
+		if err := s.sendThreadCSAT(ctx, setting, thread); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to send thread csat mail",
+				slog.Any("threadId", thread.ThreadId), slog.Any("err", err))
+		}
+	}
+	if setting.SendTranscript && thread.Channel != (models.ThreadChannel{}).Email() {
+		if err := s.SendThreadTranscript(ctx, thread); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to send thread transcript mail",
+				slog.Any("threadId", thread.ThreadId), slog.Any("err", err))
+		}
+	}
+}
+
+// sendThreadCSAT sends the CSAT survey of the resolved Thread to the Customer with the email,
+// linking the workspace survey with the Thread ID.
+func (s *CustomerMailService) sendThreadCSAT(
+	ctx context.Context, setting models.CustomerMailSetting, thread models.Thread) error {
+	customer, err := s.threadCustomer(ctx, thread)
+	if err != nil || !customer.Email.Valid {
+		return err
+	}
+	data := models.MailTemplateData{
+		CustomerName:  customer.Name,
+		CustomerEmail: customer.Email.String,
+		ThreadTitle:   thread.Title,
+		Link:          setting.ThreadLink(setting.CSATUrl, thread.ThreadId),
+	}
+	_, err = s.ms.SendTemplateMail(
+		ctx, thread.WorkspaceId, models.MailTemplateKind{}.CSAT(), customer.Locale, customer.Email.String, data)
+	return err
+}
+
+// SendThreadTranscript sends the transcript of the Thread to the Customer, e.g. as requested by the Member.
+// Returns ErrCustomerMailRecipient if the Customer has no email.
+func (s *CustomerMailService) SendThreadTranscript(ctx context.Context, thread models.Thread) error {
+	customer, err := s.threadCustomer(ctx, thread)
+	if err != nil {
+		return err
+	}
+	if !customer.Email.Valid {
+		return ErrCustomerMailRecipient
+	}
+	messages, err := s.ths.ListThreadMessages(ctx, thread.ThreadId)
+	if err != nil {
+		return err
+	}
+	data := models.MailTemplateData{
+		CustomerName:  customer.Name,
+		CustomerEmail: customer.Email.String,
+		ThreadTitle:   thread.Title,
+		Transcript:    models.ThreadTranscript(messages),
+	}
+	_, err = s.ms.SendTemplateMail(
+		ctx, thread.WorkspaceId, models.MailTemplateKind{}.Transcript(), customer.Locale, customer.Email.String, data)
+	return err
+}
+
+// NotifyThreadReply notifies the Customer of the Member's reply on the chat Thread by mail, if enabled,
+// only to the Customer's verified email. The reply is already sent, failures are only reported.
+func (s *CustomerMailService) NotifyThreadReply(ctx context.Context, thread models.Thread, message models.Message) {
+	hub := sentry.GetHubFromContext(ctx)
+	if thread.Channel != (models.ThreadChannel{}).InAppChat() || message.Member == nil || message.IsInternal() {
+		return
+	}
+	setting, err := s.ms.GetCustomerMailSetting(ctx, thread.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch customer mail setting", slog.Any("err", err))
+		return
+	}
+	if !setting.SendNotification {
+		return
+	}
+	customer, err := s.threadCustomer(ctx, thread)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch thread customer", slog.Any("err", err))
+		return
+	}
+	if !customer.Email.Valid || !customer.IsEmailVerified {
+		return
+	}
+	data := models.MailTemplateData{
+		CustomerName:  customer.Name,
+		CustomerEmail: customer.Email.String,
+		ThreadTitle:   thread.Title,
+		PreviewText:   message.PreviewText(),
+		Link:          setting.ThreadLink(setting.ConversationUrl, thread.ThreadId),
+	}
+	_, err = s.ms.SendTemplateMail(
+		ctx, thread.WorkspaceId, models.MailTemplateKind{}.Notification(), customer.Locale, customer.Email.String, data)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send thread notification mail",
+			slog.Any("threadId", thread.ThreadId), slog.Any("err", err))
+	}
+}
+
+func (s *CustomerMailService) threadCustomer(ctx context.Context, thread models.Thread) (models.Customer, error) {
+	customer, err := s.ws.GetCustomer(ctx, thread.WorkspaceId, thread.Customer.CustomerId, nil)
+	if err != nil {
+		return models.Customer{}, ErrCustomerMail
+	}
+	return customer, nil
+}
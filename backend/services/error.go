@@ -31,6 +31,10 @@ const (
 
 	ErrThreadMessage = serviceErr("thread message error")
 
+	ErrThreadForward         = serviceErr("thread forward error")
+	ErrThreadForwardNotFound = serviceErr("thread forward not found")
+	ErrThreadForwardInvalid  = serviceErr("thread forward messages not found")
+
 	ErrCustomer         = serviceErr("customer error")
 	ErrCustomerNotFound = serviceErr("customer not found")
 
@@ -42,6 +46,7 @@ const (
 
 	ErrWidgetSession        = serviceErr("widget session error")
 	ErrWidgetSessionInvalid = serviceErr("widget session invalid")
+	ErrWidgetSessionRevoked = serviceErr("widget session revoked")
 
 	ErrClaimedMail         = serviceErr("claimed mail error")
 	ErrClaimedMailNotFound = serviceErr("claimed mail not found")
@@ -51,12 +56,97 @@ const (
 
 	ErrMessageAttachment         = serviceErr("message attachment error")
 	ErrMessageAttachmentNotFound = serviceErr("message attachment not found")
+	ErrMessageAttachmentInvalid  = serviceErr("invalid message attachment")
+	ErrMessageAttachmentUpload   = serviceErr("message attachment upload error")
 
 	ErrPostmarkSettingNotFound = serviceErr("postmark setting not found")
 	ErrPostmarkSetting         = serviceErr("postmark setting error")
 
-	ErrPostmarkLog         = serviceErr("postmark log error")
-	ErrPostmarkLogNotFound = serviceErr("postmark log not found")
-	ErrPostmarkInbound     = serviceErr("postmark inbound error")
-	ErrPostmarkOutbound    = serviceErr("postmark outbound error")
+	ErrPostmarkInbound                = serviceErr("postmark inbound error")
+	ErrPostmarkInboundRequest         = serviceErr("postmark inbound request error")
+	ErrPostmarkInboundRequestNotFound = serviceErr("postmark inbound request not found")
+
+	ErrWebhookCredential         = serviceErr("webhook credential error")
+	ErrWebhookCredentialNotFound = serviceErr("webhook credential not found")
+	ErrWebhookUnauthorized       = serviceErr("webhook unauthorized")
+
+	ErrChannel                   = serviceErr("channel error")
+	ErrChannelUnsupported        = serviceErr("channel not supported")
+	ErrChannelInboundProcessed   = serviceErr("channel inbound already processed")
+	ErrChannelInbound            = serviceErr("channel inbound error")
+	ErrChannelInboundAttachments = serviceErr("channel inbound attachments error")
+	ErrChannelOutbound           = serviceErr("channel outbound error")
+	ErrChannelLogNotFound        = serviceErr("channel message log not found")
+	ErrAutomatedReplySuppressed  = serviceErr("automated reply to auto-reply suppressed")
+	ErrAutomatedReplyRateLimited = serviceErr("automated reply rate limited")
+
+	ErrFollowUpSetting = serviceErr("follow up setting error")
+	ErrFollowUp        = serviceErr("follow up error")
+	ErrFollowUpStale   = serviceErr("follow up no longer pending")
+
+	ErrSpam                   = serviceErr("spam error")
+	ErrSpamSetting            = serviceErr("spam setting error")
+	ErrSpamSenderRule         = serviceErr("spam sender rule error")
+	ErrSpamSenderRuleNotFound = serviceErr("spam sender rule not found")
+
+	ErrBlocklist           = serviceErr("blocklist error")
+	ErrSenderBlockNotFound = serviceErr("sender block not found")
+	ErrSenderBlocked       = serviceErr("sender blocked")
+	ErrCustomerBlocked     = serviceErr("customer blocked")
+
+	ErrSMSSetting     = serviceErr("sms setting error")
+	ErrSMSProvider    = serviceErr("sms provider not supported")
+	ErrSMSInbound     = serviceErr("sms inbound error")
+	ErrSMSOutbound    = serviceErr("sms outbound error")
+	ErrSMSLog         = serviceErr("sms log error")
+	ErrSMSLogNotFound = serviceErr("sms log not found")
+
+	ErrWhatsAppSetting       = serviceErr("whatsapp setting error")
+	ErrWhatsAppInbound       = serviceErr("whatsapp inbound error")
+	ErrWhatsAppOutbound      = serviceErr("whatsapp outbound error")
+	ErrWhatsAppSessionClosed = serviceErr("whatsapp session window closed")
+	ErrWhatsAppLog           = serviceErr("whatsapp log error")
+	ErrWhatsAppLogNotFound   = serviceErr("whatsapp log not found")
+
+	ErrSlackOAuth             = serviceErr("slack oauth error")
+	ErrSlackWorkspace         = serviceErr("slack workspace error")
+	ErrSlackWorkspaceNotFound = serviceErr("slack workspace not found")
+	ErrSlackChannel           = serviceErr("slack channel error")
+	ErrSlackChannelNotFound   = serviceErr("slack channel not found")
+	ErrSlackSync              = serviceErr("slack channel sync error")
+	ErrSlackInbound           = serviceErr("slack inbound error")
+	ErrSlackOutbound          = serviceErr("slack outbound error")
+	ErrSlackThreadNotLinked   = serviceErr("slack thread not linked")
+
+	ErrMailSetting          = serviceErr("mail setting error")
+	ErrMailSender           = serviceErr("mail sender not supported")
+	ErrMailSend             = serviceErr("mail send error")
+	ErrMailReplySetting     = serviceErr("mail reply setting error")
+	ErrMailSignature        = serviceErr("mail signature error")
+	ErrMailCompose          = serviceErr("mail compose error")
+	ErrMailAttachments      = serviceErr("mail attachments too large")
+	ErrMailbox              = serviceErr("mailbox error")
+	ErrMailboxNotFound      = serviceErr("mailbox not found")
+	ErrMailboxExists        = serviceErr("mailbox already exists")
+	ErrMailTemplate         = serviceErr("mail template error")
+	ErrMailTemplateNotFound = serviceErr("mail template not found")
+
+	ErrCustomerMailSetting   = serviceErr("customer mail setting error")
+	ErrCustomerMail          = serviceErr("customer mail error")
+	ErrCustomerMailRecipient = serviceErr("customer mail recipient not found")
+
+	ErrOutboundMail          = serviceErr("outbound mail error")
+	ErrOutboundMailNotFound  = serviceErr("outbound mail not found")
+	ErrOutboundMailNotFailed = serviceErr("outbound mail not failed")
+
+	ErrMailInboundInvalid   = serviceErr("invalid inbound mail")
+	ErrMailInboundRecipient = serviceErr("inbound mail recipient not of mailbox")
+	ErrMailRecipient        = serviceErr("mail recipient not found")
+	ErrIMAPSetting          = serviceErr("imap setting error")
+	ErrIMAPPoll             = serviceErr("imap poll error")
+
+	ErrAPISetting  = serviceErr("api setting error")
+	ErrAPIInbound  = serviceErr("api inbound error")
+	ErrAPIOutbound = serviceErr("api outbound error")
+	ErrAPILog      = serviceErr("api log error")
 )
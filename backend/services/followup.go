@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/utils"
+)
+
+// followUpBatchSize is the max number of threads processed per scan.
+const followUpBatchSize = 100
+
+// FollowUpScheduler periodically scans the threads waiting on the customer,
+// sends the follow-up and auto resolves as per the workspace follow-up policy.
+// Each step is taken as the workspace system member.
+type FollowUpScheduler struct {
+	ws       ports.WorkspaceServicer
+	ths      ports.ThreadServicer
+	chs      ports.ChannelServicer
+	smss     ports.SMSServicer
+	ms       ports.MailServicer
+	cms      ports.CustomerMailServicer
+	interval time.Duration
+}
+
+func NewFollowUpScheduler(
+	ws ports.WorkspaceServicer, ths ports.ThreadServicer, chs ports.ChannelServicer, smss ports.SMSServicer,
+	ms ports.MailServicer, cms ports.CustomerMailServicer, interval time.Duration) *FollowUpScheduler {
+	return &FollowUpScheduler{
+		ws:       ws,
+		ths:      ths,
+		chs:      chs,
+		smss:     smss,
+		ms:       ms,
+		cms:      cms,
+		interval: interval,
+	}
+}
+
+// Run scans on every interval until the context is done.
+func (fs *FollowUpScheduler) Run(ctx context.Context) {
+	// Background context has no request hub, services capture exceptions with the context hub.
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	slog.Info("follow up scheduler running", slog.Any("interval", fs.interval))
+	ticker := time.NewTicker(fs.interval)
+	defer ticker.Stop()
+	for {
+		fs.Scan(ctx)
+		select {
+		case <-ctx.Done():
+			slog.Info("follow up scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan processes one batch of the threads due for follow-up and the threads due to be resolved.
+// Threads are claimed with the lease, so the schedulers of the concurrent instances process each thread once.
+func (fs *FollowUpScheduler) Scan(ctx context.Context) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	// Resolve before follow-up, so that the threads followed up in this scan are not resolved.
+	threads, err := fs.ths.ClaimThreadsDueForResolve(ctx, followUpBatchSize)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to claim threads due for resolve", slog.Any("err", err))
+	}
+	for _, thread := range threads {
+		if err := fs.resolve(ctx, thread); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to auto resolve thread",
+				slog.Any("threadId", thread.ThreadId), slog.Any("err", err))
+		}
+	}
+
+	threads, err = fs.ths.ClaimThreadsDueForFollowUp(ctx, followUpBatchSize)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to claim threads due for follow up", slog.Any("err", err))
+	}
+	for _, thread := range threads {
+		if err := fs.followUp(ctx, thread); err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to follow up thread",
+				slog.Any("threadId", thread.ThreadId), slog.Any("err", err))
+		}
+	}
+}
+
+func (fs *FollowUpScheduler) systemMember(ctx context.Context, workspaceId string) (models.Member, error) {
+	member, err := fs.ws.GetSystemMember(ctx, workspaceId)
+	if errors.Is(err, ErrMemberNotFound) {
+		return fs.ws.CreateNewSystemMember(ctx, workspaceId)
+	}
+	return member, err
+}
+
+func (fs *FollowUpScheduler) resolve(ctx context.Context, thread models.Thread) error {
+	member, err := fs.systemMember(ctx, thread.WorkspaceId)
+	if err != nil {
+		return err
+	}
+	thread, err = fs.ths.AutoResolveThread(ctx, thread, member.AsMemberActor())
+	if errors.Is(err, ErrFollowUpStale) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fs.cms.OnThreadResolved(ctx, thread)
+	return nil
+}
+
+// followUp sends the follow-up through the thread's channel if enabled, and records the step.
+func (fs *FollowUpScheduler) followUp(ctx context.Context, thread models.Thread) error {
+	setting, err := fs.ws.GetThreadFollowUpSetting(ctx, thread.WorkspaceId)
+	if err != nil {
+		return err
+	}
+	if !setting.SendFollowUp {
+		_, err = fs.ths.RecordThreadFollowUp(ctx, thread, nil)
+		return err
+	}
+
+	workspace, err := fs.ws.GetWorkspace(ctx, thread.WorkspaceId)
+	if err != nil {
+		return err
+	}
+	member, err := fs.systemMember(ctx, thread.WorkspaceId)
+	if err != nil {
+		return err
+	}
+
+	textBody, err := setting.RenderFollowUp(models.FollowUpTemplateData{
+		CustomerName:  thread.Customer.Name,
+		WorkspaceName: workspace.Name,
+		ThreadTitle:   thread.Title,
+	})
+	if err != nil {
+		return err
+	}
+
+	var message models.Message
+	switch thread.Channel {
+	case models.ThreadChannel{}.InAppChat():
+		message, err = fs.chs.SendReply(
+			ctx, workspace, thread, member, models.Customer{}, models.ChannelReply{TextBody: textBody})
+		if isFollowUpSuppressed(err) {
+			_, err = fs.ths.RecordThreadFollowUp(ctx, thread, nil)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	case models.ThreadChannel{}.Email():
+		customer, err := fs.ws.GetCustomer(ctx, thread.WorkspaceId, thread.Customer.CustomerId, nil)
+		if err != nil {
+			return err
+		}
+		reply, err := fs.followUpMail(ctx, workspace, thread, customer, textBody)
+		if err != nil {
+			return err
+		}
+		message, err = fs.chs.SendReply(ctx, workspace, thread, member, customer, reply)
+		if isFollowUpSuppressed(err) {
+			_, err = fs.ths.RecordThreadFollowUp(ctx, thread, nil)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	case models.ThreadChannel{}.SMS():
+		smsSetting, err := fs.smss.GetSMSSetting(ctx, thread.WorkspaceId)
+		if err != nil {
+			return err
+		}
+		if !smsSetting.IsEnabled {
+			_, err = fs.ths.RecordThreadFollowUp(ctx, thread, nil)
+			return err
+		}
+		customer, err := fs.ws.GetCustomer(ctx, thread.WorkspaceId, thread.Customer.CustomerId, nil)
+		if err != nil {
+			return err
+		}
+		message, err = fs.smss.SendThreadSMSReply(ctx, smsSetting, thread, member, customer, textBody)
+		if err != nil {
+			return err
+		}
+	default:
+		// Not claimed for follow-up, see FollowUpChannels. Nothing is sent, so the follow-up is not recorded.
+		return ErrChannelUnsupported
+	}
+	_, err = fs.ths.RecordThreadFollowUp(ctx, thread, &message.MessageId)
+	return err
+}
+
+// followUpMail returns the follow-up mail reply in the Customer's locale as per the follow-up mail template.
+// Unless overridden by the workspace or localized, the follow-up is as per the follow-up setting.
+func (fs *FollowUpScheduler) followUpMail(
+	ctx context.Context, workspace models.Workspace, thread models.Thread, customer models.Customer, textBody string,
+) (models.ChannelReply, error) {
+	reply := models.ChannelReply{
+		TextBody: textBody,
+		HTMLBody: utils.TextToHTML(textBody),
+	}
+	tmpl, err := fs.ms.ResolveMailTemplate(
+		ctx, workspace.WorkspaceId, models.MailTemplateKind{}.FollowUp(), customer.Locale)
+	if err != nil {
+		return models.ChannelReply{}, err
+	}
+	if tmpl.IsDefault && tmpl.Locale == models.DefaultMailLocale {
+		return reply, nil
+	}
+	_, htmlBody, text, err := tmpl.Render(models.MailTemplateData{
+		WorkspaceName: workspace.Name,
+		CustomerName:  customer.Name,
+		CustomerEmail: customer.Email.String,
+		ThreadTitle:   thread.Title,
+	})
+	if err != nil {
+		return models.ChannelReply{}, err
+	}
+	if htmlBody == "" {
+		htmlBody = utils.TextToHTML(text)
+	}
+	return models.ChannelReply{TextBody: text, HTMLBody: htmlBody}, nil
+}
+
+// isFollowUpSuppressed checks if the follow-up is not sent to avoid the auto-reply loop,
+// the follow-up step is recorded without the message.
+func isFollowUpSuppressed(err error) bool {
+	return errors.Is(err, ErrAutomatedReplySuppressed) || errors.Is(err, ErrAutomatedReplyRateLimited)
+}
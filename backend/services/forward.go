@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/adapters/store"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// ThreadForwardService forwards the Thread's messages by mail to the external address, e.g. a vendor,
+// with the workspace mail sender. The forward is kept in the Thread timeline as the internal message,
+// replies from the forwarded address are added to the Thread as the internal notes if tracked.
+type ThreadForwardService struct {
+	repo ports.ThreadRepositorer
+	ws   ports.WorkspaceServicer
+	ms   ports.MailServicer
+}
+
+func NewThreadForwardService(
+	repo ports.ThreadRepositorer, ws ports.WorkspaceServicer, ms ports.MailServicer) *ThreadForwardService {
+	return &ThreadForwardService{
+		repo: repo,
+		ws:   ws,
+		ms:   ms,
+	}
+}
+
+// ForwardThread sends the forward mail of the selected messages or the whole transcript with the attachments,
+// then appends the forward to the Thread timeline with the message log.
+func (s *ThreadForwardService) ForwardThread(
+	ctx context.Context, workspace models.Workspace, thread models.Thread, member models.Member,
+	forward models.ThreadForward,
+) (models.ThreadForward, models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	messages, err := s.forwardMessages(ctx, thread, forward)
+	if err != nil {
+		return models.ThreadForward{}, models.Message{}, err
+	}
+
+	mail, err := s.ms.ComposeForwardMail(ctx, workspace, thread, member, forward, messages)
+	if err != nil {
+		return models.ThreadForward{}, models.Message{}, err
+	}
+	mail.Attachments, err = forwardAttachments(ctx, messages)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.ThreadForward{}, models.Message{}, err
+	}
+
+	// Replies without the mail headers are matched with the reply token of the forward.
+	if forward.TrackReplies {
+		sk, err := s.ws.GetOrGenerateSecretKey(ctx, workspace.WorkspaceId)
+		if err != nil {
+			slog.Error("failed to get workspace secret key for forward reply token", slog.Any("err", err))
+		} else {
+			mail.ReplyToken = models.NewMailReplyToken(forward.ForwardId, sk.Hmac)
+		}
+	}
+
+	result, err := s.ms.SendWorkspaceMail(ctx, workspace.WorkspaceId, mail)
+	if err != nil {
+		return models.ThreadForward{}, models.Message{}, err
+	}
+	forward.MailMessageId = result.MessageId
+
+	summary := forward.Summary()
+	newMessage := models.NewMessage(
+		thread.ThreadId, models.ThreadChannel{}.Email(),
+		models.SetMessageMember(member.AsMemberActor()),
+		models.SetMessageKind(models.MessageKind{}.Forward()),
+		models.SetMessageTextBody(summary),
+		models.SetMarkdownBody(summary),
+	)
+	if forward.Note != "" {
+		newMessage.TextBody = summary + "\n\n" + forward.Note
+		newMessage.MarkdownBody = newMessage.TextBody
+	}
+
+	now := time.Now().UTC()
+	threadMessage := models.ThreadMessage{
+		Thread:  &thread,
+		Message: newMessage,
+		Log: &models.ChannelMessageLog{
+			MessageId:   newMessage.MessageId,
+			Channel:     models.ThreadChannel{}.Email(),
+			MessageType: models.ChannelMessageType{}.Outbound(),
+			ExternalId:  result.ProviderMessageId,
+			ExternalRef: &result.MessageId,
+			Payload:     result.Payload,
+			Status:      models.ChannelMessageStatus{}.Sent(),
+			SubmittedAt: result.SubmittedAt,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		Participants: []models.MessageParticipant{
+			{
+				MessageId: newMessage.MessageId,
+				Email:     forward.ToEmail,
+				Name:      forward.ToName,
+				Role:      models.ThreadParticipantRole{}.To(),
+				CreatedAt: now,
+			},
+		},
+	}
+	forward, message, err := s.repo.InsertThreadForward(ctx, forward, threadMessage)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to insert thread forward", slog.Any("err", err))
+		return models.ThreadForward{}, models.Message{}, ErrThreadForward
+	}
+	return forward, message, nil
+}
+
+// forwardMessages returns the forwarded messages oldest first, the selected messages must be of the Thread.
+// Internal messages of the Thread are never forwarded.
+func (s *ThreadForwardService) forwardMessages(
+	ctx context.Context, thread models.Thread, forward models.ThreadForward,
+) ([]models.MessageWithAttachments, error) {
+	messages, err := s.repo.FetchMessagesWithAttachmentsByThreadId(ctx, thread.ThreadId)
+	if err != nil {
+		return nil, ErrThreadMessage
+	}
+
+	selected := make(map[string]bool, len(forward.MessageIds))
+	for _, messageId := range forward.MessageIds {
+		selected[messageId] = true
+	}
+	forwarded := make([]models.MessageWithAttachments, 0, len(messages))
+	for _, message := range messages {
+		if message.IsInternal() {
+			continue
+		}
+		if !forward.IsTranscript && !selected[message.MessageId] {
+			continue
+		}
+		forwarded = append(forwarded, message)
+	}
+	if len(forwarded) == 0 || (!forward.IsTranscript && len(forwarded) != len(selected)) {
+		return nil, ErrThreadForwardInvalid
+	}
+	return forwarded, nil
+}
+
+// forwardAttachments returns the stored attachments of the forwarded messages as the mail attachments.
+func forwardAttachments(
+	ctx context.Context, messages []models.MessageWithAttachments) ([]models.MailAttachment, error) {
+	var stored []models.MessageAttachment
+	for _, message := range messages {
+		stored = append(stored, message.Attachments...)
+	}
+	return mailAttachments(ctx, stored)
+}
+
+// mailAttachments fetches the content of the stored attachments as the mail attachments.
+// Failed and spam attachments are skipped, returns ErrMailAttachments if over the mail attachments size.
+func mailAttachments(ctx context.Context, attachments []models.MessageAttachment) ([]models.MailAttachment, error) {
+	var stored []models.MessageAttachment
+	for _, a := range attachments {
+		if !a.HasError && !a.Spam && a.ContentKey != "" {
+			stored = append(stored, a)
+		}
+	}
+	if len(stored) == 0 {
+		return nil, nil
+	}
+
+	accountId := zyg.CFAccountId()
+	accessKeyId := zyg.R2AccessKeyId()
+	accessKeySecret := zyg.R2AccessSecretKey()
+	s3Bucket := zyg.S3Bucket()
+	s3Client, err := store.NewS3(ctx, s3Bucket, accountId, accessKeyId, accessKeySecret)
+	if err != nil {
+		slog.Error("failed to connect S3 to fetch mail attachments", slog.Any("err", err))
+		return nil, ErrMessageAttachment
+	}
+
+	var size int
+	mailAttachments := make([]models.MailAttachment, 0, len(stored))
+	for _, a := range stored {
+		content, err := store.GetObject(ctx, s3Client, a.ContentKey)
+		if err != nil {
+			slog.Error("failed to fetch mail attachment",
+				slog.Any("attachmentId", a.AttachmentId), slog.Any("err", err))
+			return nil, ErrMessageAttachment
+		}
+		size += len(content)
+		if size > models.MaxMailAttachmentsSize {
+			return nil, ErrMailAttachments
+		}
+		mailAttachments = append(mailAttachments, models.MailAttachment{
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			Content:     content,
+		})
+	}
+	return mailAttachments, nil
+}
+
+// ListThreadForwards returns the forwards of the Thread, oldest first.
+func (s *ThreadForwardService) ListThreadForwards(
+	ctx context.Context, threadId string) ([]models.ThreadForward, error) {
+	forwards, err := s.repo.FetchThreadForwardsByThreadId(ctx, threadId)
+	if err != nil {
+		return []models.ThreadForward{}, ErrThreadForward
+	}
+	return forwards, nil
+}
+
+// ProcessForwardReply appends the inbound mail replying to the forward as the Thread's internal note.
+// The reply is matched with the `In-Reply-To` and the `References` chain, then with the reply token of the forward.
+// Returns ErrThreadForwardNotFound if the inbound mail is not the reply to the tracked forward.
+func (s *ThreadForwardService) ProcessForwardReply(
+	ctx context.Context, workspaceId string, inbound models.ChannelInbound,
+) (models.Thread, models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	forward, err := s.matchForward(ctx, workspaceId, inbound)
+	if err != nil {
+		return models.Thread{}, models.Message{}, err
+	}
+
+	thread, err := s.repo.LookupByWorkspaceThreadId(ctx, workspaceId, forward.ThreadId, nil)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.Thread{}, models.Message{}, ErrThreadNotFound
+	}
+	if err != nil {
+		return models.Thread{}, models.Message{}, ErrThread
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, models.ThreadChannel{}.Email(),
+		models.SetMessageKind(models.MessageKind{}.Note()),
+		models.SetHTMLBody(sanitizeMessageHTML(inbound.HTMLBody)),
+		models.SetMessageTextBody(inbound.TextBody),
+		models.SetMarkdownBody(inbound.MarkdownBody),
+	)
+	forward.OnReply()
+
+	threadMessage := models.ThreadMessage{
+		Thread:       &thread,
+		Message:      newMessage,
+		Log:          inbound.MessageLog(newMessage.MessageId),
+		Participants: inbound.MessageParticipants(newMessage.MessageId),
+	}
+	message, err := s.repo.AppendThreadForwardReply(ctx, forward, threadMessage)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to append thread forward reply", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, ErrThreadForward
+	}
+
+	// The note is persisted, attachments failed to upload are resumed when the inbound is processed again.
+	if len(inbound.Attachments) > 0 {
+		err = saveMessageAttachments(ctx, s.repo, thread, message, inbound.Attachments)
+	}
+	return thread, message, err
+}
+
+// matchForward returns the tracked forward the inbound mail is replying to.
+func (s *ThreadForwardService) matchForward(
+	ctx context.Context, workspaceId string, inbound models.ChannelInbound) (models.ThreadForward, error) {
+	refs := make([]string, 0, len(inbound.References)+1)
+	refs = append(refs, inbound.References...)
+	if inbound.ReplyRef != nil {
+		refs = append(refs, *inbound.ReplyRef)
+	}
+	if len(refs) > 0 {
+		forward, err := s.repo.FindThreadForwardByMailRefs(ctx, workspaceId, refs)
+		if err == nil {
+			return forward, nil
+		}
+		if !errors.Is(err, repository.ErrEmpty) {
+			return models.ThreadForward{}, ErrThreadForward
+		}
+	}
+
+	// Forward reply token is signed with the forward ID, Thread reply token is signed with the Thread ID.
+	if inbound.ReplyToken == "" {
+		return models.ThreadForward{}, ErrThreadForwardNotFound
+	}
+	sk, err := s.ws.GetSecretKey(ctx, workspaceId)
+	if errors.Is(err, ErrSecretKeyNotFound) {
+		return models.ThreadForward{}, ErrThreadForwardNotFound
+	}
+	if err != nil {
+		return models.ThreadForward{}, ErrThreadForward
+	}
+	forwardId, ok := models.VerifyMailReplyToken(inbound.ReplyToken, sk.Hmac)
+	if !ok || !strings.HasPrefix(forwardId, "fwd") {
+		return models.ThreadForward{}, ErrThreadForwardNotFound
+	}
+	forward, err := s.repo.LookupThreadForwardById(ctx, workspaceId, forwardId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.ThreadForward{}, ErrThreadForwardNotFound
+	}
+	if err != nil {
+		return models.ThreadForward{}, ErrThreadForward
+	}
+	return forward, nil
+}
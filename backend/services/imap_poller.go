@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/ports"
+)
+
+// IMAPPoller periodically polls the enabled workspace IMAP mailboxes for the inbound mail.
+type IMAPPoller struct {
+	mis      ports.MailInboundServicer
+	interval time.Duration
+}
+
+func NewIMAPPoller(mis ports.MailInboundServicer, interval time.Duration) *IMAPPoller {
+	return &IMAPPoller{
+		mis:      mis,
+		interval: interval,
+	}
+}
+
+// Run polls on every interval until the context is done.
+func (p *IMAPPoller) Run(ctx context.Context) {
+	// Background context has no request hub, services capture exceptions with the context hub.
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	slog.Info("imap poller running", slog.Any("interval", p.interval))
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			slog.Info("imap poller stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll polls each of the enabled IMAP mailboxes.
+func (p *IMAPPoller) Poll(ctx context.Context) {
+	settings, err := p.mis.ListEnabledIMAPSettings(ctx)
+	if err != nil {
+		slog.Error("failed to list enabled imap settings", slog.Any("err", err))
+		return
+	}
+	for _, setting := range settings {
+		processed, err := p.mis.PollIMAPMailbox(ctx, setting)
+		if err != nil {
+			slog.Error("failed to poll imap mailbox",
+				slog.Any("err", err), slog.Any("workspaceId", setting.WorkspaceId))
+		}
+		if processed > 0 {
+			slog.Info("processed imap inbound mail",
+				slog.Any("workspaceId", setting.WorkspaceId), slog.Any("processed", processed))
+		}
+	}
+}
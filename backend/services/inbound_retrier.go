@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/ports"
+)
+
+// PostmarkInboundRetrier periodically retries the failed Postmark inbound requests due, with backoff
+// until the max attempts.
+type PostmarkInboundRetrier struct {
+	mis      ports.MailInboundServicer
+	interval time.Duration
+}
+
+func NewPostmarkInboundRetrier(mis ports.MailInboundServicer, interval time.Duration) *PostmarkInboundRetrier {
+	return &PostmarkInboundRetrier{
+		mis:      mis,
+		interval: interval,
+	}
+}
+
+// Run retries on every interval until the context is done.
+func (r *PostmarkInboundRetrier) Run(ctx context.Context) {
+	// Background context has no request hub, services capture exceptions with the context hub.
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	slog.Info("postmark inbound retrier running", slog.Any("interval", r.interval))
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		processed, err := r.mis.RetryPostmarkInboundRequests(ctx)
+		if err != nil {
+			slog.Error("failed to retry postmark inbound requests", slog.Any("err", err))
+		}
+		if processed > 0 {
+			slog.Info("retried postmark inbound requests", slog.Any("processed", processed))
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("postmark inbound retrier stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
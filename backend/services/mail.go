@@ -86,13 +86,6 @@ func removeDataURLPrefix(base64String string) string {
 func ProcessMessageAttachment(
 	ctx context.Context, workspaceId, threadId, messageId,
 	base64Content, contentType, filename string, s3Client store.S3Config) (models.MessageAttachment, error) {
-	if base64Content == "" {
-		return models.MessageAttachment{
-			HasError: true,
-			Error:    "base64Content cannot be empty",
-		}, errors.New("base64Content cannot be empty")
-	}
-
 	now := time.Now().UTC()
 	attachmentId := (&models.MessageAttachment{}).GenId()
 	attachment := models.MessageAttachment{
@@ -104,6 +97,12 @@ func ProcessMessageAttachment(
 		UpdatedAt:    now,
 	}
 
+	if base64Content == "" {
+		attachment.HasError = true
+		attachment.Error = "base64Content cannot be empty"
+		return attachment, errors.New("base64Content cannot be empty")
+	}
+
 	// Decode the base64Content, stripping any data URL prefix
 	decodedData, err := base64.StdEncoding.DecodeString(removeDataURLPrefix(base64Content))
 	if err != nil {
@@ -124,7 +123,7 @@ func ProcessMessageAttachment(
 	if err != nil {
 		attachment.HasError = true
 		attachment.Error = fmt.Sprintf("failed to upload attachment: %v", err)
-		return attachment, errors.New("failed to upload attachment")
+		return attachment, ErrMessageAttachmentUpload
 	}
 
 	hash := md5.Sum(decodedData)
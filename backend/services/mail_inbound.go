@@ -0,0 +1,553 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/integrations/email"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// imapPollLimit is the max mail fetched from the IMAP mailbox per poll, the rest on the next poll.
+const imapPollLimit = 50
+
+// MailInboundService processes the inbound mail from each of the mail sources - the Postmark inbound webhook,
+// the raw mail upload, the IMAP mailbox and the SMTP listener. Mail is checked for duplicates, for replies to forwards,
+// against the workspace blocklist and with the spam pipeline before appended to the email Thread.
+// The email Thread is routed to the workspace mailbox the mail is addressed to.
+type MailInboundService struct {
+	repo ports.MailRepositorer
+	ws   ports.WorkspaceServicer
+	ths  ports.ThreadServicer
+	chs  ports.ChannelServicer
+	bls  ports.BlocklistServicer
+	sps  ports.SpamServicer
+	fws  ports.ThreadForwardServicer
+	imap ports.IMAPFetcher
+}
+
+func NewMailInboundService(
+	repo ports.MailRepositorer, ws ports.WorkspaceServicer, ths ports.ThreadServicer, chs ports.ChannelServicer,
+	bls ports.BlocklistServicer, sps ports.SpamServicer, fws ports.ThreadForwardServicer, imap ports.IMAPFetcher,
+) *MailInboundService {
+	return &MailInboundService{
+		repo: repo,
+		ws:   ws,
+		ths:  ths,
+		chs:  chs,
+		bls:  bls,
+		sps:  sps,
+		fws:  fws,
+		imap: imap,
+	}
+}
+
+// ProcessMailInbound processes the normalized inbound mail of the workspace.
+// Reply to the Thread's forward is added to the Thread as the internal note.
+// Already processed mail returns ErrChannelInboundProcessed, mail from the dropped sender
+// returns ErrSenderBlocked, both are acknowledged by the source.
+// Mail persisted with the attachments failed to upload returns ErrChannelInboundAttachments,
+// the attachments are resumed when the mail is processed again.
+func (s *MailInboundService) ProcessMailInbound(
+	ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound,
+) (models.Thread, models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	channel := models.ThreadChannel{}.Email()
+
+	// Check if the inbound mail has already been processed, sources retry.
+	isProcessed, err := s.chs.IsInboundProcessed(ctx, channel, inbound.ExternalId)
+	if err != nil {
+		return models.Thread{}, models.Message{}, err
+	}
+	if isProcessed {
+		slog.Info("inbound mail is already processed", slog.Any("externalId", inbound.ExternalId))
+		// Attachments failed on the earlier processing are resumed, not to be lost with the retry skipped.
+		if err := s.chs.ResumeInboundAttachments(ctx, workspace.WorkspaceId, inbound); err != nil {
+			return models.Thread{}, models.Message{}, err
+		}
+		return models.Thread{}, models.Message{}, ErrChannelInboundProcessed
+	}
+
+	// Replies from the forwarded address are added to the Thread as the internal notes.
+	thread, note, err := s.fws.ProcessForwardReply(ctx, workspace.WorkspaceId, inbound)
+	if err == nil || errors.Is(err, ErrChannelInboundAttachments) {
+		return thread, note, err
+	}
+	if !errors.Is(err, ErrThreadForwardNotFound) {
+		hub.CaptureException(err)
+		slog.Error("failed to process inbound mail forward reply", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, err
+	}
+
+	// Check the sender against the workspace blocklist.
+	// Dropped senders are acknowledged without being created as customers.
+	inbound.Block, err = s.bls.CheckSender(ctx, workspace.WorkspaceId, inbound.FromEmail, "")
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to check inbound mail sender blocklist", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, err
+	}
+	if inbound.Block.IsDrop() {
+		slog.Info("dropped inbound mail from blocked sender",
+			slog.Any("kind", inbound.Block.Kind), slog.Any("value", inbound.Block.Value))
+		return models.Thread{}, models.Message{}, ErrSenderBlocked
+	}
+
+	customer, err := s.chs.ResolveCustomer(ctx, workspace, inbound)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to create customer for inbound mail", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, err
+	}
+	if customer.IsBlocked {
+		inbound.Block = models.BlockedCustomerVerdict(customer.CustomerId)
+	}
+
+	// Run the inbound mail through the spam pipeline, flagged mail moves the thread to spam stage.
+	inbound.Spam, err = s.sps.CheckInbound(ctx, models.SpamCandidate{
+		WorkspaceId: workspace.WorkspaceId,
+		Channel:     channel,
+		CustomerId:  customer.CustomerId,
+		FromEmail:   inbound.FromEmail,
+		Headers:     inbound.Headers,
+		Subject:     inbound.Subject,
+		TextBody:    inbound.TextBody,
+	})
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to check inbound mail for spam", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, err
+	}
+
+	// Auto-reply e.g. out-of-office is kept as automated, not changing the thread stage.
+	inbound.IsAutomated = models.IsAutoReplyMail(inbound.Headers, inbound.Subject)
+	if inbound.IsAutomated {
+		slog.Info("inbound mail is auto-reply", slog.Any("externalId", inbound.ExternalId))
+	}
+
+	// Get the system member for the workspace which will process the inbound mail.
+	member, err := s.ws.GetSystemMember(ctx, workspace.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to get system member for inbound mail", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, err
+	}
+	thread, message, err := s.chs.ProcessInbound(ctx, workspace.WorkspaceId, customer, member.AsMemberActor(), inbound)
+	if err != nil && !errors.Is(err, ErrChannelInboundAttachments) {
+		return models.Thread{}, models.Message{}, err
+	}
+	// The mail is persisted, routed to the mailbox even if the attachments are to be resumed.
+	return s.routeMailbox(ctx, thread, inbound), message, err
+}
+
+// routeMailbox links the Thread to the mailbox the inbound mail is addressed to, otherwise to the default mailbox,
+// then applies the mailbox default label and assignee. Thread already linked keeps the mailbox routed first.
+// The inbound mail is already appended to the Thread, failures are only reported.
+func (s *MailInboundService) routeMailbox(
+	ctx context.Context, thread models.Thread, inbound models.ChannelInbound) models.Thread {
+	hub := sentry.GetHubFromContext(ctx)
+
+	mailboxes, err := s.repo.FetchMailboxesByWorkspaceId(ctx, thread.WorkspaceId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to fetch workspace mailboxes", slog.Any("err", err))
+		return thread
+	}
+	mailbox, ok := models.RouteMailbox(mailboxes, inbound.Recipients())
+	if !ok {
+		return thread
+	}
+	linked, err := s.repo.InsertThreadMailbox(ctx, thread.ThreadId, mailbox.MailboxId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to link thread mailbox", slog.Any("err", err))
+		return thread
+	}
+	if !linked {
+		return thread
+	}
+
+	if mailbox.DefaultLabelId != nil {
+		_, _, err := s.ths.SetLabel(ctx, thread.ThreadId, *mailbox.DefaultLabelId, models.LabelAddedBy{}.System())
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to set mailbox default label", slog.Any("err", err))
+		}
+	}
+	if mailbox.DefaultAssigneeId != nil && thread.AssignedMember == nil {
+		assignee, err := s.ws.GetMember(ctx, thread.WorkspaceId, *mailbox.DefaultAssigneeId)
+		if err != nil {
+			slog.Error("failed to fetch mailbox default assignee", slog.Any("err", err))
+			return thread
+		}
+		assigned := thread
+		assigned.AssignMember(assignee.AsMemberActor(), time.Now().UTC())
+		assigned, err = s.ths.UpdateThread(ctx, assigned, []string{"assignee"})
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to assign mailbox default assignee", slog.Any("err", err))
+			return thread
+		}
+		return assigned
+	}
+	return thread
+}
+
+// ProcessRawMail parses the raw RFC 5322 mail received from the source and processes it as the inbound mail.
+func (s *MailInboundService) ProcessRawMail(
+	ctx context.Context, workspace models.Workspace, source string, raw []byte,
+) (models.Thread, models.Message, error) {
+	rawMail, err := email.ParseRawMail(raw)
+	if err != nil {
+		slog.Error("failed to parse raw inbound mail", slog.Any("source", source), slog.Any("err", err))
+		return models.Thread{}, models.Message{}, ErrMailInboundInvalid
+	}
+	inbound := inboundMailReply(rawMail.ToChannelInbound(source))
+	return s.ProcessMailInbound(ctx, workspace, inbound)
+}
+
+// ReceiveRawMail parses the raw RFC 5322 mail of the workspace webhook, checks the mail is addressed to the workspace
+// as per CheckInboundRecipient then processes it as the inbound mail.
+func (s *MailInboundService) ReceiveRawMail(
+	ctx context.Context, workspace models.Workspace, credential *models.WebhookCredential, raw []byte,
+) (models.Thread, models.Message, error) {
+	source := models.MailInboundSource{}.Upload()
+	rawMail, err := email.ParseRawMail(raw)
+	if err != nil {
+		slog.Error("failed to parse raw inbound mail", slog.Any("source", source), slog.Any("err", err))
+		return models.Thread{}, models.Message{}, ErrMailInboundInvalid
+	}
+	inbound := inboundMailReply(rawMail.ToChannelInbound(source))
+	if err := s.CheckInboundRecipient(ctx, workspace.WorkspaceId, credential, inbound); err != nil {
+		return models.Thread{}, models.Message{}, err
+	}
+	return s.ProcessMailInbound(ctx, workspace, inbound)
+}
+
+// CheckInboundRecipient checks the inbound mail of the workspace webhook is addressed to the workspace mailbox of the
+// webhook credential, or to any of the workspace mailboxes with the workspace credential, as the webhook path alone
+// does not scope the mail. The mail to the workspace Postmark server inbound address is accepted with the workspace
+// credential. The nil credential is of the global legacy credentials, accepted as the workspace credential.
+// Returns ErrMailInboundRecipient otherwise.
+func (s *MailInboundService) CheckInboundRecipient(
+	ctx context.Context, workspaceId string, credential *models.WebhookCredential, inbound models.ChannelInbound,
+) error {
+	mailboxes, err := s.repo.FetchMailboxesByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return ErrMailbox
+	}
+	recipients := inbound.Recipients()
+	for _, mb := range models.AddressedMailboxes(mailboxes, recipients) {
+		if credential == nil || credential.AllowsMailbox(mb.MailboxId) {
+			return nil
+		}
+	}
+	if credential != nil && credential.IsMailboxScoped() {
+		return ErrMailInboundRecipient
+	}
+
+	setting, err := s.ws.GetPostmarkMailServerSetting(ctx, workspaceId)
+	if errors.Is(err, ErrPostmarkSettingNotFound) {
+		return ErrMailInboundRecipient
+	}
+	if err != nil {
+		return err
+	}
+	if !setting.IsInboundRecipient(recipients) {
+		return ErrMailInboundRecipient
+	}
+	return nil
+}
+
+func (s *MailInboundService) GetIMAPSetting(ctx context.Context, workspaceId string) (models.IMAPSetting, error) {
+	setting, err := s.repo.FetchIMAPSettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewIMAPSetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.IMAPSetting{}, ErrIMAPSetting
+	}
+	return setting, nil
+}
+
+// SaveIMAPSetting saves the IMAP mailbox, changed mailbox is polled from the mail received after.
+func (s *MailInboundService) SaveIMAPSetting(
+	ctx context.Context, setting models.IMAPSetting) (models.IMAPSetting, error) {
+	current, err := s.repo.FetchIMAPSettingById(ctx, setting.WorkspaceId)
+	if err != nil && !errors.Is(err, repository.ErrEmpty) {
+		return models.IMAPSetting{}, ErrIMAPSetting
+	}
+	if current.Host != setting.Host || current.Username != setting.Username || current.Mailbox != setting.Mailbox {
+		setting.UIDValidity = 0
+		setting.LastUID = 0
+	}
+	setting, err = s.repo.SaveIMAPSetting(ctx, setting)
+	if err != nil {
+		return models.IMAPSetting{}, ErrIMAPSetting
+	}
+	return setting, nil
+}
+
+func (s *MailInboundService) ListEnabledIMAPSettings(ctx context.Context) ([]models.IMAPSetting, error) {
+	settings, err := s.repo.FetchEnabledIMAPSettings(ctx)
+	if err != nil {
+		return []models.IMAPSetting{}, ErrIMAPSetting
+	}
+	return settings, nil
+}
+
+// PollIMAPMailbox fetches the new mail from the workspace IMAP mailbox and processes each.
+// The last fetched UID is advanced past the processed mail, mail failed to process is fetched
+// again on the next poll. Returns the number of mail processed.
+func (s *MailInboundService) PollIMAPMailbox(ctx context.Context, setting models.IMAPSetting) (int, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	workspace, err := s.ws.GetWorkspace(ctx, setting.WorkspaceId)
+	if err != nil {
+		return 0, err
+	}
+
+	result, fetchErr := s.imap.FetchNew(ctx, setting, imapPollLimit)
+	if fetchErr != nil {
+		slog.Error("failed to fetch imap mailbox",
+			slog.Any("workspaceId", setting.WorkspaceId), slog.Any("err", fetchErr))
+	}
+
+	// Mailbox is new or recreated, start from the mail received after.
+	if fetchErr == nil && result.UIDValidity != setting.UIDValidity {
+		setting.UIDValidity = result.UIDValidity
+		setting.LastUID = max(result.UIDNext-1, 0)
+	}
+
+	processed := 0
+	var processErr error
+	for _, m := range result.Messages {
+		_, _, err := s.ProcessRawMail(ctx, workspace, models.MailInboundSource{}.IMAP(), m.Raw)
+		if err != nil && !isMailInboundAcknowledged(err) {
+			hub.CaptureException(err)
+			processErr = err
+			break
+		}
+		setting.LastUID = m.UID
+		processed++
+	}
+
+	now := time.Now().UTC()
+	setting.LastPolledAt = &now
+	setting.PollError = nil
+	if err := errors.Join(fetchErr, processErr); err != nil {
+		pollError := err.Error()
+		setting.PollError = &pollError
+	}
+	if _, err := s.repo.ModifyIMAPSettingPollStatus(ctx, setting); err != nil {
+		return processed, ErrIMAPSetting
+	}
+	if fetchErr != nil || processErr != nil {
+		return processed, ErrIMAPPoll
+	}
+	return processed, nil
+}
+
+// isMailInboundAcknowledged reports whether the inbound mail is done with, though not appended to the Thread.
+func isMailInboundAcknowledged(err error) bool {
+	return errors.Is(err, ErrChannelInboundProcessed) ||
+		errors.Is(err, ErrSenderBlocked) ||
+		errors.Is(err, ErrMailInboundInvalid)
+}
+
+// recipientWorkspace returns the workspace of the SMTP recipient `<workspaceId>@<inbound domain>`,
+// the plus address suffix of the local part is ignored.
+func (s *MailInboundService) recipientWorkspace(ctx context.Context, rcpt string) (models.Workspace, error) {
+	at := strings.LastIndex(rcpt, "@")
+	if at <= 0 {
+		return models.Workspace{}, ErrMailRecipient
+	}
+	local, domain := rcpt[:at], rcpt[at+1:]
+	if inboundDomain := zyg.SMTPInboundDomain(); inboundDomain != "" && !strings.EqualFold(domain, inboundDomain) {
+		return models.Workspace{}, ErrMailRecipient
+	}
+	workspaceId, _, _ := strings.Cut(local, "+")
+	workspace, err := s.ws.GetWorkspace(ctx, workspaceId)
+	if errors.Is(err, ErrWorkspaceNotFound) {
+		return models.Workspace{}, ErrMailRecipient
+	}
+	if err != nil {
+		return models.Workspace{}, err
+	}
+	return workspace, nil
+}
+
+// AcceptSMTPRecipient checks the SMTP recipient is of the existing workspace.
+func (s *MailInboundService) AcceptSMTPRecipient(ctx context.Context, rcpt string) error {
+	_, err := s.recipientWorkspace(ctx, rcpt)
+	return err
+}
+
+// ReceiveSMTPMail processes the raw mail received by the SMTP listener for the recipient.
+// Returns error only for the mail to be retried by the sending MTA.
+func (s *MailInboundService) ReceiveSMTPMail(ctx context.Context, rcpt string, raw []byte) error {
+	// SMTP listener context has no request hub, services capture exceptions with the context hub.
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	workspace, err := s.recipientWorkspace(ctx, rcpt)
+	if err != nil {
+		return err
+	}
+	thread, message, err := s.ProcessRawMail(ctx, workspace, models.MailInboundSource{}.SMTP(), raw)
+	if isMailInboundAcknowledged(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("processed smtp inbound mail",
+		slog.Any("threadId", thread.ThreadId), slog.Any("messageId", message.MessageId))
+	return nil
+}
+
+// postmarkInboundRetryLimit is the max failed Postmark inbound requests retried per run, the rest on the next run.
+const postmarkInboundRetryLimit = 50
+
+// postmarkInboundRetryLease is how long the claimed request is held by the retrier before it can be claimed again,
+// e.g. if the server exits while retrying.
+const postmarkInboundRetryLease = 5 * time.Minute
+
+// ReceivePostmarkInbound records the Postmark inbound request as received, then processes the inbound mail.
+// Failed processing is kept with the error for the retry with backoff and the replay, the request is
+// acknowledged. Only failing to record the request returns the error, for Postmark to retry the webhook.
+func (s *MailInboundService) ReceivePostmarkInbound(
+	ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound,
+) (models.PostmarkInboundRequest, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	req := models.NewPostmarkInboundRequest(workspace.WorkspaceId, inbound.ExternalId, inbound.Payload)
+	req, err := s.repo.InsertPostmarkInboundRequest(ctx, req)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.PostmarkInboundRequest{}, ErrPostmarkInboundRequest
+	}
+	// Already processed on the earlier delivery of the request.
+	if req.IsDone() {
+		return req, nil
+	}
+	return s.processPostmarkInbound(ctx, workspace, inbound, req)
+}
+
+// processPostmarkInbound processes the inbound mail of the request and updates the processing state.
+func (s *MailInboundService) processPostmarkInbound(
+	ctx context.Context, workspace models.Workspace, inbound models.ChannelInbound, req models.PostmarkInboundRequest,
+) (models.PostmarkInboundRequest, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	thread, message, err := s.ProcessMailInbound(ctx, workspace, inbound)
+	now := time.Now().UTC()
+	switch {
+	case err == nil:
+		req.MarkProcessed(&thread.ThreadId, now)
+		slog.Info("processed postmark inbound message",
+			slog.Any("threadId", thread.ThreadId), slog.Any("messageId", message.MessageId))
+	case errors.Is(err, ErrChannelInboundProcessed) && req.Status == (models.InboundRequestStatus{}).Failed():
+		// Retried after the mail is persisted, the attachments are resumed.
+		req.MarkProcessed(req.ThreadId, now)
+	case errors.Is(err, ErrChannelInboundProcessed):
+		req.MarkSkipped(now)
+	case errors.Is(err, ErrSenderBlocked):
+		req.MarkProcessed(nil, now)
+	default:
+		hub.CaptureException(err)
+		slog.Error("failed to process postmark inbound message",
+			slog.Any("externalId", req.MessageId), slog.Any("err", err))
+		// The mail is persisted with the attachments to be resumed.
+		if thread.ThreadId != "" {
+			req.ThreadId = &thread.ThreadId
+		}
+		req.MarkFailed(err, now)
+	}
+	return s.savePostmarkInboundRequest(ctx, req)
+}
+
+func (s *MailInboundService) savePostmarkInboundRequest(
+	ctx context.Context, req models.PostmarkInboundRequest) (models.PostmarkInboundRequest, error) {
+	req, err := s.repo.ModifyPostmarkInboundRequestStatus(ctx, req)
+	if err != nil {
+		sentry.GetHubFromContext(ctx).CaptureException(err)
+		return models.PostmarkInboundRequest{}, ErrPostmarkInboundRequest
+	}
+	return req, nil
+}
+
+func (s *MailInboundService) GetPostmarkInboundRequest(
+	ctx context.Context, messageId string) (models.PostmarkInboundRequest, error) {
+	req, err := s.repo.LookupPostmarkInboundRequestById(ctx, messageId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.PostmarkInboundRequest{}, ErrPostmarkInboundRequestNotFound
+	}
+	if err != nil {
+		return models.PostmarkInboundRequest{}, ErrPostmarkInboundRequest
+	}
+	return req, nil
+}
+
+// ListPostmarkInboundRequests returns the latest Postmark inbound requests of the status,
+// of all the workspaces if the workspace is not provided.
+func (s *MailInboundService) ListPostmarkInboundRequests(
+	ctx context.Context, workspaceId *string, status string) ([]models.PostmarkInboundRequest, error) {
+	requests, err := s.repo.FetchPostmarkInboundRequests(ctx, workspaceId, status, 100)
+	if err != nil {
+		return []models.PostmarkInboundRequest{}, ErrPostmarkInboundRequest
+	}
+	return requests, nil
+}
+
+// ReplayPostmarkInbound processes the inbound request again from the payload as received.
+// Replay is idempotent, the request already processed is returned as is and the inbound mail
+// already processed is skipped.
+func (s *MailInboundService) ReplayPostmarkInbound(
+	ctx context.Context, req models.PostmarkInboundRequest) (models.PostmarkInboundRequest, error) {
+	if req.IsDone() {
+		return req, nil
+	}
+
+	workspace, err := s.ws.GetWorkspace(ctx, req.WorkspaceId)
+	if err != nil {
+		return models.PostmarkInboundRequest{}, err
+	}
+	inbound, err := s.chs.NormalizeInbound(ctx, models.ThreadChannel{}.Email(), req.WorkspaceId, req.Payload)
+	if err != nil {
+		req.MarkFailed(err, time.Now().UTC())
+		return s.savePostmarkInboundRequest(ctx, req)
+	}
+	return s.processPostmarkInbound(ctx, workspace, inbound, req)
+}
+
+// RetryPostmarkInboundRequests replays the failed Postmark inbound requests due for the retry.
+// Returns the count of the requests processed now.
+func (s *MailInboundService) RetryPostmarkInboundRequests(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	requests, err := s.repo.ClaimDuePostmarkInboundRequests(
+		ctx, now, now.Add(postmarkInboundRetryLease), postmarkInboundRetryLimit)
+	if err != nil {
+		return 0, ErrPostmarkInboundRequest
+	}
+
+	var processed int
+	for _, req := range requests {
+		replayed, err := s.ReplayPostmarkInbound(ctx, req)
+		if err != nil {
+			slog.Error("failed to retry postmark inbound request",
+				slog.Any("externalId", req.MessageId), slog.Any("err", err))
+			continue
+		}
+		if replayed.IsDone() {
+			processed++
+		}
+	}
+	return processed, nil
+}
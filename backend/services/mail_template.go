@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/services/tasks"
+)
+
+// ListMailTemplates returns the templates of each kind in each of the built-in and the workspace locales,
+// the workspace templates override the built-in defaults.
+func (s *MailService) ListMailTemplates(ctx context.Context, workspaceId string) ([]models.MailTemplate, error) {
+	overrides, err := s.repo.FetchMailTemplatesByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return []models.MailTemplate{}, ErrMailTemplate
+	}
+
+	templates := make([]models.MailTemplate, 0, len(overrides)+20)
+	for _, kind := range (models.MailTemplateKind{}).Kinds() {
+		overridden := make(map[string]bool)
+		for _, tmpl := range overrides {
+			if tmpl.Kind == kind {
+				templates = append(templates, tmpl)
+				overridden[tmpl.Locale] = true
+			}
+		}
+		for _, locale := range models.DefaultMailLocales(kind) {
+			if overridden[locale] {
+				continue
+			}
+			tmpl, _ := models.DefaultMailTemplate(workspaceId, kind, locale)
+			templates = append(templates, tmpl)
+		}
+	}
+	return templates, nil
+}
+
+// GetMailTemplate returns the workspace template of the kind in the locale, otherwise the built-in default.
+func (s *MailService) GetMailTemplate(
+	ctx context.Context, workspaceId string, kind string, locale string) (models.MailTemplate, error) {
+	overrides, err := s.repo.FetchMailTemplatesByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return models.MailTemplate{}, ErrMailTemplate
+	}
+	if tmpl, ok := findMailTemplate(overrides, workspaceId, kind, locale); ok {
+		return tmpl, nil
+	}
+	return models.MailTemplate{}, ErrMailTemplateNotFound
+}
+
+func (s *MailService) SaveMailTemplate(ctx context.Context, tmpl models.MailTemplate) (models.MailTemplate, error) {
+	tmpl, err := s.repo.SaveMailTemplate(ctx, tmpl)
+	if err != nil {
+		return models.MailTemplate{}, ErrMailTemplate
+	}
+	return tmpl, nil
+}
+
+// DeleteMailTemplate deletes the workspace template, the built-in default of the kind and locale if any applies.
+func (s *MailService) DeleteMailTemplate(ctx context.Context, workspaceId string, kind string, locale string) error {
+	err := s.repo.DeleteMailTemplate(ctx, workspaceId, kind, locale)
+	if errors.Is(err, repository.ErrEmpty) {
+		return ErrMailTemplateNotFound
+	}
+	if err != nil {
+		return ErrMailTemplate
+	}
+	return nil
+}
+
+// ResolveMailTemplate returns the template of the kind for the Customer's locale, as in the locale,
+// then the language of the locale, then the default locale. In each, the workspace template
+// is preferred over the built-in default.
+func (s *MailService) ResolveMailTemplate(
+	ctx context.Context, workspaceId string, kind string, locale string) (models.MailTemplate, error) {
+	overrides, err := s.repo.FetchMailTemplatesByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return models.MailTemplate{}, ErrMailTemplate
+	}
+
+	locales := make([]string, 0, 3)
+	if locale = models.NormalizeLocale(locale); locale != "" {
+		locales = append(locales, locale)
+		if language := models.LocaleLanguage(locale); language != locale {
+			locales = append(locales, language)
+		}
+	}
+	locales = append(locales, models.DefaultMailLocale)
+	for _, l := range locales {
+		if tmpl, ok := findMailTemplate(overrides, workspaceId, kind, l); ok {
+			return tmpl, nil
+		}
+	}
+	return models.MailTemplate{}, ErrMailTemplateNotFound
+}
+
+// findMailTemplate returns the workspace template of the kind in the locale, otherwise the built-in default.
+func findMailTemplate(
+	overrides []models.MailTemplate, workspaceId string, kind string, locale string) (models.MailTemplate, bool) {
+	for _, tmpl := range overrides {
+		if tmpl.Kind == kind && tmpl.Locale == locale {
+			return tmpl, true
+		}
+	}
+	return models.DefaultMailTemplate(workspaceId, kind, locale)
+}
+
+// ComposeTemplateMail renders the template with the data as the mail from the workspace,
+// branded with the workspace logo, header and footer of the reply setting.
+func (s *MailService) ComposeTemplateMail(
+	ctx context.Context, workspace models.Workspace, tmpl models.MailTemplate, data models.MailTemplateData,
+) (models.Mail, error) {
+	setting, err := s.GetMailReplySetting(ctx, workspace.WorkspaceId)
+	if err != nil {
+		return models.Mail{}, err
+	}
+	if data.WorkspaceName == "" {
+		data.WorkspaceName = workspace.Name
+	}
+
+	subject, htmlBody, textBody, err := tmpl.Render(data)
+	if err != nil {
+		slog.Error("failed to render mail template",
+			slog.Any("kind", tmpl.Kind), slog.Any("locale", tmpl.Locale), slog.Any("err", err))
+		return models.Mail{}, ErrMailCompose
+	}
+
+	mail := models.Mail{
+		FromName: workspace.Name,
+		Subject:  subject,
+	}
+	mailData := tasks.NewTransactionalMailData(workspace, setting, tmpl.Locale, htmlBody, textBody)
+	mail, err = tasks.NewTransactionalMail(mail, mailData)
+	if err != nil {
+		return models.Mail{}, ErrMailCompose
+	}
+	return mail, nil
+}
+
+// SendTemplateMail sends the transactional mail of the kind in the Customer's locale to the address,
+// with the workspace mail sender.
+func (s *MailService) SendTemplateMail(
+	ctx context.Context, workspaceId string, kind string, locale string, to string, data models.MailTemplateData,
+) (models.MailSendResult, error) {
+	workspace, err := s.ws.GetWorkspace(ctx, workspaceId)
+	if err != nil {
+		return models.MailSendResult{}, err
+	}
+	tmpl, err := s.ResolveMailTemplate(ctx, workspaceId, kind, locale)
+	if err != nil {
+		return models.MailSendResult{}, err
+	}
+	mail, err := s.ComposeTemplateMail(ctx, workspace, tmpl, data)
+	if err != nil {
+		return models.MailSendResult{}, err
+	}
+	mail.To = to
+	return s.SendWorkspaceMail(ctx, workspaceId, mail)
+}
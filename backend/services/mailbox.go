@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/postmark"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+)
+
+// ListMailboxes returns the workspace mailboxes, the default mailbox first.
+func (s *MailService) ListMailboxes(ctx context.Context, workspaceId string) ([]models.Mailbox, error) {
+	mailboxes, err := s.repo.FetchMailboxesByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return []models.Mailbox{}, ErrMailbox
+	}
+	return mailboxes, nil
+}
+
+func (s *MailService) GetMailbox(ctx context.Context, workspaceId string, mailboxId string) (models.Mailbox, error) {
+	mailbox, err := s.repo.LookupMailboxById(ctx, workspaceId, mailboxId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.Mailbox{}, ErrMailboxNotFound
+	}
+	if err != nil {
+		return models.Mailbox{}, ErrMailbox
+	}
+	return mailbox, nil
+}
+
+// SaveMailbox saves the mailbox, the email must be unique in the workspace.
+// The first mailbox of the workspace is the default.
+func (s *MailService) SaveMailbox(ctx context.Context, mailbox models.Mailbox) (models.Mailbox, error) {
+	mailboxes, err := s.ListMailboxes(ctx, mailbox.WorkspaceId)
+	if err != nil {
+		return models.Mailbox{}, err
+	}
+	for _, mb := range mailboxes {
+		if mb.MailboxId != mailbox.MailboxId && mb.Email == mailbox.Email {
+			return models.Mailbox{}, ErrMailboxExists
+		}
+	}
+	if len(mailboxes) == 0 {
+		mailbox.IsDefault = true
+	}
+	mailbox, err = s.repo.SaveMailbox(ctx, mailbox)
+	if err != nil {
+		return models.Mailbox{}, ErrMailbox
+	}
+	return mailbox, nil
+}
+
+func (s *MailService) DeleteMailbox(ctx context.Context, workspaceId string, mailboxId string) error {
+	err := s.repo.DeleteMailbox(ctx, workspaceId, mailboxId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return ErrMailboxNotFound
+	}
+	if err != nil {
+		return ErrMailbox
+	}
+	return nil
+}
+
+// GetThreadMailbox returns the mailbox the Thread was routed to.
+func (s *MailService) GetThreadMailbox(ctx context.Context, threadId string) (models.Mailbox, error) {
+	mailbox, err := s.repo.LookupMailboxByThreadId(ctx, threadId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.Mailbox{}, ErrMailboxNotFound
+	}
+	if err != nil {
+		return models.Mailbox{}, ErrMailbox
+	}
+	return mailbox, nil
+}
+
+// MailboxAddDomain adds the mailbox domain in Postmark for the DKIM and the return path DNS records.
+// Mailboxes of the same domain share the Postmark domain, already added domain is fetched instead.
+// Returns true if the domain is added now.
+func (s *MailService) MailboxAddDomain(ctx context.Context, mailbox models.Mailbox) (models.Mailbox, bool, error) {
+	var created bool
+	var addedDomain postmark.DomainDetail
+
+	hub := sentry.GetHubFromContext(ctx)
+	client := postmark.NewClient("", zyg.PostmarkAccountToken())
+
+	if mailbox.DNSDomainId == nil {
+		mailboxes, err := s.ListMailboxes(ctx, mailbox.WorkspaceId)
+		if err != nil {
+			return models.Mailbox{}, created, err
+		}
+		for _, mb := range mailboxes {
+			if mb.Domain == mailbox.Domain && mb.DNSDomainId != nil {
+				mailbox.DNSDomainId = mb.DNSDomainId
+				break
+			}
+		}
+	}
+
+	var err error
+	if mailbox.DNSDomainId != nil {
+		addedDomain, err = client.GetDomain(ctx, *mailbox.DNSDomainId)
+		if err != nil {
+			hub.CaptureException(err)
+			return models.Mailbox{}, created, err
+		}
+	} else {
+		addedDomain, err = client.CreateDomain(ctx, postmark.CreateDomainRequest{Name: mailbox.Domain})
+		if err != nil {
+			hub.CaptureException(err)
+			return models.Mailbox{}, created, err
+		}
+		hub.CaptureMessage(fmt.Sprintf("postmark domain created with ID: %d", addedDomain.ID))
+		created = true
+	}
+
+	dkimHost, dkimTextValue := latestDKIM(addedDomain)
+	mailbox.HasDNS = true
+	mailbox.IsDNSVerified = false
+	mailbox.DNSDomainId = &addedDomain.ID
+	mailbox.DKIMHost = &dkimHost
+	mailbox.DKIMTextValue = &dkimTextValue
+	mailbox.DKIMUpdateStatus = &addedDomain.DKIMUpdateStatus
+	mailbox.ReturnPathDomain = &addedDomain.ReturnPathDomain
+	mailbox.ReturnPathDomainCNAME = &addedDomain.ReturnPathDomainCNAMEValue
+	mailbox.ReturnPathDomainVerified = addedDomain.ReturnPathDomainVerified
+
+	mailbox, err = s.repo.SaveMailbox(ctx, mailbox)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.Mailbox{}, created, ErrMailbox
+	}
+	return mailbox, created, nil
+}
+
+// MailboxVerifyDomain verifies the DKIM and the return path DNS records of the mailbox domain in Postmark.
+func (s *MailService) MailboxVerifyDomain(ctx context.Context, mailbox models.Mailbox) (models.Mailbox, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	client := postmark.NewClient("", zyg.PostmarkAccountToken())
+
+	now := time.Now().UTC()
+	verifiedDKIM, err := client.VerifyDKIM(ctx, *mailbox.DNSDomainId)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.Mailbox{}, err
+	}
+
+	verifiedReturnPath, err := client.VerifyReturnPath(ctx, *mailbox.DNSDomainId)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.Mailbox{}, err
+	}
+
+	dkimHost, dkimTextValue := latestDKIM(verifiedDKIM)
+	mailbox.HasDNS = true
+	mailbox.DNSVerifiedAt = &now
+	mailbox.DKIMHost = &dkimHost
+	mailbox.DKIMTextValue = &dkimTextValue
+	mailbox.DKIMUpdateStatus = &verifiedDKIM.DKIMUpdateStatus
+	mailbox.ReturnPathDomain = &verifiedReturnPath.ReturnPathDomain
+	mailbox.ReturnPathDomainCNAME = &verifiedReturnPath.ReturnPathDomainCNAMEValue
+	mailbox.ReturnPathDomainVerified = verifiedReturnPath.ReturnPathDomainVerified
+	mailbox.IsDNSVerified = mailbox.DNSHasVerified()
+
+	mailbox, err = s.repo.SaveMailbox(ctx, mailbox)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.Mailbox{}, ErrMailbox
+	}
+	return mailbox, nil
+}
+
+// latestDKIM returns the DKIM host and the TXT value of the domain.
+// As per the Postmark docs *Pending* should be the latest.
+func latestDKIM(domain postmark.DomainDetail) (string, string) {
+	dkimHost, dkimTextValue := domain.DKIMHost, domain.DKIMTextValue
+	if domain.DKIMPendingHost != "" {
+		dkimHost = domain.DKIMPendingHost
+	}
+	if domain.DKIMPendingTextValue != "" {
+		dkimTextValue = domain.DKIMPendingTextValue
+	}
+	return dkimHost, dkimTextValue
+}
+
+// composeMailbox returns the mailbox of the Thread the mail is sent from, nil if the Thread has none.
+// Mail is sent from the mailbox address if the mailbox domain is verified or the workspace sends
+// with its own mail sender, replies are always received at the mailbox.
+func (s *MailService) composeMailbox(ctx context.Context, thread models.Thread, mail *models.Mail) *models.Mailbox {
+	mailbox, err := s.GetThreadMailbox(ctx, thread.ThreadId)
+	if err != nil {
+		return nil
+	}
+	mail.ReplyTo = mailbox.Email
+	if mailbox.IsDNSVerified {
+		mail.FromEmail = mailbox.Email
+		return &mailbox
+	}
+	setting, err := s.GetMailSetting(ctx, thread.WorkspaceId)
+	if err == nil && setting.IsEnabled {
+		mail.FromEmail = mailbox.Email
+	}
+	return &mailbox
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+	"github.com/zyghq/zyg/services/tasks"
+)
+
+// MailService sends the outbound mail through the sender selected by the workspace mail setting,
+// otherwise from the workspace Postmark mail server, falling back to the deployment mail sender.
+// The `Message-ID` is generated here, so the mail thread is the same irrespective of the sender.
+type MailService struct {
+	repo    ports.MailRepositorer
+	ws      ports.WorkspaceServicer
+	senders map[string]ports.MailSender
+}
+
+func NewMailService(
+	repo ports.MailRepositorer, ws ports.WorkspaceServicer, senders ...ports.MailSender) *MailService {
+	registered := make(map[string]ports.MailSender, len(senders))
+	for _, sender := range senders {
+		registered[sender.Name()] = sender
+	}
+	return &MailService{
+		repo:    repo,
+		ws:      ws,
+		senders: registered,
+	}
+}
+
+// GetMailSetting returns the mail setting of the workspace,
+// or the default disabled setting if not configured.
+func (s *MailService) GetMailSetting(ctx context.Context, workspaceId string) (models.MailSetting, error) {
+	setting, err := s.repo.FetchMailSettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewMailSetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.MailSetting{}, ErrMailSetting
+	}
+	return setting, nil
+}
+
+func (s *MailService) SaveMailSetting(ctx context.Context, setting models.MailSetting) (models.MailSetting, error) {
+	if _, ok := s.senders[setting.Sender]; !ok {
+		return models.MailSetting{}, ErrMailSender
+	}
+	setting, err := s.repo.SaveMailSetting(ctx, setting)
+	if err != nil {
+		return models.MailSetting{}, ErrMailSetting
+	}
+	return setting, nil
+}
+
+// GetMailReplySetting returns the reply setting of the workspace,
+// or the default unbranded setting if not configured.
+func (s *MailService) GetMailReplySetting(
+	ctx context.Context, workspaceId string) (models.MailReplySetting, error) {
+	setting, err := s.repo.FetchMailReplySettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewMailReplySetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.MailReplySetting{}, ErrMailReplySetting
+	}
+	return setting, nil
+}
+
+func (s *MailService) SaveMailReplySetting(
+	ctx context.Context, setting models.MailReplySetting) (models.MailReplySetting, error) {
+	setting, err := s.repo.SaveMailReplySetting(ctx, setting)
+	if err != nil {
+		return models.MailReplySetting{}, ErrMailReplySetting
+	}
+	return setting, nil
+}
+
+// GetCustomerMailSetting returns the customer mail setting of the workspace,
+// or the default setting sending nothing if not configured.
+func (s *MailService) GetCustomerMailSetting(
+	ctx context.Context, workspaceId string) (models.CustomerMailSetting, error) {
+	setting, err := s.repo.FetchCustomerMailSettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewCustomerMailSetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.CustomerMailSetting{}, ErrCustomerMailSetting
+	}
+	return setting, nil
+}
+
+func (s *MailService) SaveCustomerMailSetting(
+	ctx context.Context, setting models.CustomerMailSetting) (models.CustomerMailSetting, error) {
+	setting, err := s.repo.SaveCustomerMailSetting(ctx, setting)
+	if err != nil {
+		return models.CustomerMailSetting{}, ErrCustomerMailSetting
+	}
+	return setting, nil
+}
+
+// GetMailSignature returns the Member's own signature, empty if not configured.
+func (s *MailService) GetMailSignature(
+	ctx context.Context, workspaceId string, memberId string) (models.MailSignature, error) {
+	signature, err := s.repo.FetchMailSignatureByMemberId(ctx, workspaceId, memberId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewMailSignature(workspaceId, memberId), nil
+	}
+	if err != nil {
+		return models.MailSignature{}, ErrMailSignature
+	}
+	return signature, nil
+}
+
+func (s *MailService) SaveMailSignature(
+	ctx context.Context, signature models.MailSignature) (models.MailSignature, error) {
+	signature, err := s.repo.SaveMailSignature(ctx, signature)
+	if err != nil {
+		return models.MailSignature{}, ErrMailSignature
+	}
+	return signature, nil
+}
+
+// ComposeReplyMail renders the Member's reply to the Customer as the final mail from the Thread's mailbox,
+// with the Member's signature, the mailbox or the workspace default, wrapped with the workspace reply wrapper.
+// The previous message of the Thread if any is quoted as per the reply setting.
+func (s *MailService) ComposeReplyMail(
+	ctx context.Context, workspace models.Workspace, thread models.Thread, member models.Member,
+	customer models.Customer, reply models.Message, quote *models.Message,
+) (models.Mail, error) {
+	setting, err := s.GetMailReplySetting(ctx, workspace.WorkspaceId)
+	if err != nil {
+		return models.Mail{}, err
+	}
+
+	mail := models.Mail{
+		FromName: fmt.Sprintf("%s at %s", member.Name, workspace.Name),
+		To:       customer.Email.String,
+		Subject:  fmt.Sprintf("Re: %s", thread.Title),
+		Tag:      customer.CustomerId,
+	}
+	mailbox := s.composeMailbox(ctx, thread, &mail)
+	signature, err := s.memberSignature(ctx, setting, mailbox, member)
+	if err != nil {
+		return models.Mail{}, err
+	}
+	data := tasks.NewReplyMailData(workspace, setting, signature, reply, quote)
+	mail, err = tasks.NewReplyMail(mail, data)
+	if err != nil {
+		return models.Mail{}, ErrMailCompose
+	}
+	return mail, nil
+}
+
+// ComposeForwardMail renders the Member's forward of the Thread's messages to the forwarded address
+// from the Thread's mailbox, with the Member's signature, the mailbox or the workspace default.
+func (s *MailService) ComposeForwardMail(
+	ctx context.Context, workspace models.Workspace, thread models.Thread, member models.Member,
+	forward models.ThreadForward, messages []models.MessageWithAttachments,
+) (models.Mail, error) {
+	setting, err := s.GetMailReplySetting(ctx, workspace.WorkspaceId)
+	if err != nil {
+		return models.Mail{}, err
+	}
+
+	mail := models.Mail{
+		FromName: fmt.Sprintf("%s at %s", member.Name, workspace.Name),
+		To:       forward.To().String(),
+		Subject:  fmt.Sprintf("Fwd: %s", thread.Title),
+		Tag:      forward.ForwardId,
+	}
+	mailbox := s.composeMailbox(ctx, thread, &mail)
+	signature, err := s.memberSignature(ctx, setting, mailbox, member)
+	if err != nil {
+		return models.Mail{}, err
+	}
+	data := tasks.NewForwardMailData(thread, forward, signature, messages)
+	mail, err = tasks.NewForwardMail(mail, data)
+	if err != nil {
+		return models.Mail{}, ErrMailCompose
+	}
+	return mail, nil
+}
+
+// memberSignature returns the Member's own signature, otherwise the mailbox signature
+// or the workspace default signature.
+func (s *MailService) memberSignature(
+	ctx context.Context, setting models.MailReplySetting, mailbox *models.Mailbox, member models.Member,
+) (models.MailSignature, error) {
+	signature, err := s.GetMailSignature(ctx, setting.WorkspaceId, member.MemberId)
+	if err != nil {
+		return models.MailSignature{}, err
+	}
+	if signature.IsEmpty() && mailbox != nil {
+		signature = mailbox.Signature()
+	}
+	if signature.IsEmpty() {
+		signature = setting.DefaultSignature()
+	}
+	return signature, nil
+}
+
+// deploymentMailSetting returns the deployment mail sender as configured with the environment.
+func deploymentMailSetting() models.MailSetting {
+	setting := models.NewMailSetting("")
+	setting.IsEnabled = true
+	setting.Sender = zyg.MailSender()
+	setting.FromName = zyg.MailFromName()
+	setting.FromEmail = zyg.MailFromEmail()
+	setting.ReplyTo = zyg.MailReplyTo()
+	switch setting.Sender {
+	case models.MailSenderName{}.Postmark():
+		setting.APIKey = zyg.PostmarkServerToken()
+	case models.MailSenderName{}.Resend():
+		setting.APIKey = zyg.ResendApiKey()
+	}
+	setting.SMTPHost = zyg.SMTPHost()
+	setting.SMTPPort = zyg.SMTPPort()
+	setting.SMTPUsername = zyg.SMTPUsername()
+	setting.SMTPPassword = zyg.SMTPPassword()
+	setting.SMTPSecurity = zyg.SMTPSecurity()
+	setting.SMTPAuth = zyg.SMTPAuth()
+	return setting
+}
+
+// workspaceMailSetting resolves the mail sender of the workspace.
+func (s *MailService) workspaceMailSetting(ctx context.Context, workspaceId string) (models.MailSetting, error) {
+	setting, err := s.GetMailSetting(ctx, workspaceId)
+	if err != nil {
+		return models.MailSetting{}, err
+	}
+	if setting.IsEnabled {
+		return setting, nil
+	}
+
+	pmSetting, err := s.ws.GetPostmarkMailServerSetting(ctx, workspaceId)
+	if errors.Is(err, ErrPostmarkSettingNotFound) {
+		return deploymentMailSetting(), nil
+	}
+	if err != nil {
+		return models.MailSetting{}, err
+	}
+	setting.IsEnabled = true
+	setting.Sender = models.MailSenderName{}.Postmark()
+	setting.APIKey = pmSetting.ServerToken
+	setting.FromEmail = pmSetting.Email
+	return setting, nil
+}
+
+func (s *MailService) send(
+	ctx context.Context, setting models.MailSetting, mail models.Mail) (models.MailSendResult, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	sender, ok := s.senders[setting.Sender]
+	if !ok {
+		slog.Error("mail sender not registered", slog.Any("sender", setting.Sender))
+		return models.MailSendResult{}, ErrMailSender
+	}
+
+	if mail.FromEmail == "" {
+		mail.FromEmail = setting.FromEmail
+	}
+	if mail.FromName == "" {
+		mail.FromName = setting.FromName
+	}
+	if mail.ReplyTo == "" {
+		mail.ReplyTo = setting.ReplyTo
+	}
+	// Replies are received at the plus addressed reply to, otherwise at the from address.
+	if mail.ReplyToken != "" {
+		replyTo := mail.ReplyTo
+		if replyTo == "" {
+			replyTo = mail.FromEmail
+		}
+		mail.ReplyTo = models.PlusAddress(replyTo, mail.ReplyToken)
+	}
+	if mail.MessageId == "" {
+		mail.MessageId = models.NewMailMessageId(mail.FromEmail)
+	}
+
+	result, err := sender.Send(ctx, setting, mail)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send mail", slog.Any("sender", sender.Name()), slog.Any("err", err))
+		return models.MailSendResult{}, ErrMailSend
+	}
+	return result, nil
+}
+
+func (s *MailService) SendWorkspaceMail(
+	ctx context.Context, workspaceId string, mail models.Mail) (models.MailSendResult, error) {
+	setting, err := s.workspaceMailSetting(ctx, workspaceId)
+	if err != nil {
+		return models.MailSendResult{}, err
+	}
+	return s.send(ctx, setting, mail)
+}
+
+func (s *MailService) SendSystemMail(ctx context.Context, mail models.Mail) (models.MailSendResult, error) {
+	return s.send(ctx, deploymentMailSetting(), mail)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+)
+
+// outboundMailLease is how long the claimed mail is held by the sender before it can be claimed again,
+// e.g. if the sender exits while delivering.
+const outboundMailLease = 5 * time.Minute
+
+// ClaimOutboundMails returns the queued mails due for delivery, held by the sender for the lease.
+func (s *ChannelService) ClaimOutboundMails(ctx context.Context, limit int) ([]models.OutboundMail, error) {
+	now := time.Now().UTC()
+	mails, err := s.repo.ClaimDueOutboundMails(ctx, now, now.Add(outboundMailLease), limit)
+	if err != nil {
+		return []models.OutboundMail{}, ErrOutboundMail
+	}
+	return mails, nil
+}
+
+// OutboundMailQuota returns the count of mails the workspace can still send within the current rate window.
+func (s *ChannelService) OutboundMailQuota(ctx context.Context, workspaceId string) (int, error) {
+	since := time.Now().UTC().Add(-models.OutboundMailRateWindow)
+	count, err := s.repo.CountOutboundMailsSentSince(ctx, workspaceId, since)
+	if err != nil {
+		return 0, ErrOutboundMail
+	}
+	return max(models.OutboundMailRateLimit-count, 0), nil
+}
+
+// DeferOutboundMail keeps the claimed mail queued until the time without the delivery attempt.
+func (s *ChannelService) DeferOutboundMail(
+	ctx context.Context, mail models.OutboundMail, until time.Time) (models.OutboundMail, error) {
+	mail.Defer(until)
+	mail, err := s.repo.ModifyOutboundMailStatus(ctx, mail)
+	if err != nil {
+		return models.OutboundMail{}, ErrOutboundMail
+	}
+	return mail, nil
+}
+
+// DeliverOutboundMail delivers the queued mail reply with the email channel, the mail is marked sent
+// with the message log, otherwise kept queued to be retried with the backoff.
+// Missing sender and oversized attachments are not retried, the mail is failed right away.
+func (s *ChannelService) DeliverOutboundMail(
+	ctx context.Context, workspace models.Workspace, thread models.Thread,
+	member models.Member, customer models.Customer, mail models.OutboundMail,
+) (models.OutboundMail, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	adapter, err := s.adapter(models.ThreadChannel{}.Email())
+	if err != nil {
+		return models.OutboundMail{}, err
+	}
+	message, err := s.repo.LookupThreadMessageById(ctx, mail.MessageId)
+	if err != nil {
+		return models.OutboundMail{}, ErrOutboundMail
+	}
+	attachments, err := s.repo.FetchMessageAttachmentsByMessageId(ctx, mail.MessageId)
+	if err != nil {
+		return models.OutboundMail{}, ErrOutboundMail
+	}
+
+	messageLog, err := adapter.Deliver(ctx, models.ChannelDelivery{
+		Workspace:    workspace,
+		Thread:       thread,
+		Customer:     customer,
+		Member:       member,
+		Message:      message,
+		Participants: mail.Participants,
+		Bcc:          mail.Bcc,
+		Attachments:  attachments,
+	})
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to deliver outbound mail",
+			slog.Any("messageId", mail.MessageId), slog.Any("attempts", mail.Attempts+1), slog.Any("err", err))
+		retry := !errors.Is(err, ErrMailSender) && !errors.Is(err, ErrMailAttachments)
+		return s.FailOutboundMail(ctx, mail, err, retry)
+	}
+
+	mail.MarkSent(time.Now().UTC())
+	sent, err := s.repo.SaveOutboundMailSent(ctx, mail, messageLog)
+	if err != nil {
+		// The mail is delivered, not marked sent it is delivered again after the lease.
+		hub.CaptureException(err)
+		slog.Error("failed to save outbound mail sent",
+			slog.Any("messageId", mail.MessageId), slog.Any("err", err))
+		return models.OutboundMail{}, ErrOutboundMail
+	}
+	return sent, nil
+}
+
+// FailOutboundMail records the failed delivery attempt of the mail, kept queued to be retried with the backoff
+// if retried, otherwise failed.
+func (s *ChannelService) FailOutboundMail(
+	ctx context.Context, mail models.OutboundMail, err error, retry bool) (models.OutboundMail, error) {
+	mail.MarkFailed(err, retry, time.Now().UTC())
+	mail, err = s.repo.ModifyOutboundMailStatus(ctx, mail)
+	if err != nil {
+		return models.OutboundMail{}, ErrOutboundMail
+	}
+	return mail, nil
+}
+
+// ListOutboundMails returns the workspace mails in the status, the latest first.
+// If not specified, returns the mails pending and the mails failed.
+func (s *ChannelService) ListOutboundMails(
+	ctx context.Context, workspaceId string, status string) ([]models.OutboundMail, error) {
+	statuses := []string{models.OutboundMailStatus{}.Queued(), models.OutboundMailStatus{}.Failed()}
+	if status != "" {
+		statuses = []string{status}
+	}
+	mails, err := s.repo.FetchOutboundMailsByWorkspaceId(ctx, workspaceId, statuses, 100)
+	if err != nil {
+		return []models.OutboundMail{}, ErrOutboundMail
+	}
+	return mails, nil
+}
+
+// RetryOutboundMail queues the failed mail to be delivered right away with the attempts reset.
+func (s *ChannelService) RetryOutboundMail(
+	ctx context.Context, workspaceId string, messageId string) (models.OutboundMail, error) {
+	mail, err := s.repo.LookupOutboundMailById(ctx, workspaceId, messageId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.OutboundMail{}, ErrOutboundMailNotFound
+	}
+	if err != nil {
+		return models.OutboundMail{}, ErrOutboundMail
+	}
+	if !mail.CanRetry() {
+		return models.OutboundMail{}, ErrOutboundMailNotFailed
+	}
+	mail.Retry(time.Now().UTC())
+	mail, err = s.repo.ModifyOutboundMailStatus(ctx, mail)
+	if err != nil {
+		return models.OutboundMail{}, ErrOutboundMail
+	}
+	return mail, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// outboundMailBatchSize is the max number of queued mails claimed per send.
+const outboundMailBatchSize = 100
+
+// OutboundMailSender periodically delivers the queued outbound mail replies due, within the workspace
+// rate limit. Failed deliveries are retried with backoff until the max attempts.
+type OutboundMailSender struct {
+	ws       ports.WorkspaceServicer
+	ths      ports.ThreadServicer
+	chs      ports.ChannelServicer
+	interval time.Duration
+}
+
+func NewOutboundMailSender(
+	ws ports.WorkspaceServicer, ths ports.ThreadServicer, chs ports.ChannelServicer,
+	interval time.Duration) *OutboundMailSender {
+	return &OutboundMailSender{
+		ws:       ws,
+		ths:      ths,
+		chs:      chs,
+		interval: interval,
+	}
+}
+
+// Run sends on every interval until the context is done.
+func (s *OutboundMailSender) Run(ctx context.Context) {
+	// Background context has no request hub, services capture exceptions with the context hub.
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	slog.Info("outbound mail sender running", slog.Any("interval", s.interval))
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.Send(ctx)
+		select {
+		case <-ctx.Done():
+			slog.Info("outbound mail sender stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Send delivers one batch of the queued mails due. Mails over the workspace rate limit are kept queued
+// for the next rate window.
+func (s *OutboundMailSender) Send(ctx context.Context) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	mails, err := s.chs.ClaimOutboundMails(ctx, outboundMailBatchSize)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to claim outbound mails", slog.Any("err", err))
+		return
+	}
+
+	quotas := make(map[string]int)
+	for _, mail := range mails {
+		quota, ok := quotas[mail.WorkspaceId]
+		if !ok {
+			quota, err = s.chs.OutboundMailQuota(ctx, mail.WorkspaceId)
+			if err != nil {
+				hub.CaptureException(err)
+				slog.Error("failed to get outbound mail quota",
+					slog.Any("workspaceId", mail.WorkspaceId), slog.Any("err", err))
+				continue
+			}
+		}
+		if quota <= 0 {
+			quotas[mail.WorkspaceId] = 0
+			until := time.Now().UTC().Add(models.OutboundMailRateWindow)
+			if _, err := s.chs.DeferOutboundMail(ctx, mail, until); err != nil {
+				hub.CaptureException(err)
+				slog.Error("failed to defer outbound mail",
+					slog.Any("messageId", mail.MessageId), slog.Any("err", err))
+			}
+			continue
+		}
+		quotas[mail.WorkspaceId] = quota - 1
+
+		delivered, err := s.deliver(ctx, mail)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to send outbound mail",
+				slog.Any("messageId", mail.MessageId), slog.Any("err", err))
+			continue
+		}
+		if delivered.Status == (models.OutboundMailStatus{}).Sent() {
+			slog.Info("sent outbound mail", slog.Any("messageId", mail.MessageId))
+		}
+	}
+}
+
+// deliver delivers the mail as the Member who replied. The mail of the Thread, Customer or Member
+// no longer in the workspace is failed, not retried.
+func (s *OutboundMailSender) deliver(ctx context.Context, mail models.OutboundMail) (models.OutboundMail, error) {
+	workspace, err := s.ws.GetWorkspace(ctx, mail.WorkspaceId)
+	if err != nil {
+		return s.fail(ctx, mail, err)
+	}
+	thread, err := s.ths.GetWorkspaceThread(ctx, mail.WorkspaceId, mail.ThreadId, nil)
+	if err != nil {
+		return s.fail(ctx, mail, err)
+	}
+	member, err := s.ws.GetMember(ctx, mail.WorkspaceId, mail.MemberId)
+	if err != nil {
+		return s.fail(ctx, mail, err)
+	}
+	customer, err := s.ws.GetCustomer(ctx, mail.WorkspaceId, mail.CustomerId, nil)
+	if err != nil {
+		return s.fail(ctx, mail, err)
+	}
+	return s.chs.DeliverOutboundMail(ctx, workspace, thread, member, customer, mail)
+}
+
+func (s *OutboundMailSender) fail(
+	ctx context.Context, mail models.OutboundMail, err error) (models.OutboundMail, error) {
+	retry := !errors.Is(err, ErrWorkspaceNotFound) && !errors.Is(err, ErrThreadNotFound) &&
+		!errors.Is(err, ErrMemberNotFound) && !errors.Is(err, ErrCustomerNotFound)
+	if _, ferr := s.chs.FailOutboundMail(ctx, mail, err, retry); ferr != nil {
+		return mail, ferr
+	}
+	return mail, err
+}
@@ -0,0 +1,560 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// slackOAuthStateTTL is how long the Slack app install OAuth state is valid.
+const slackOAuthStateTTL = 10 * time.Minute
+
+// SlackService handles the two-way Slack integration.
+// Messages in the enabled Slack channels become Threads, Slack thread replies become Thread messages
+// and Member replies are posted back in the Slack thread with the bot token.
+type SlackService struct {
+	repo       ports.SlackRepositorer
+	threadRepo ports.ThreadRepositorer
+	client     ports.SlackClient
+	ws         ports.WorkspaceServicer
+	sps        ports.SpamServicer
+}
+
+func NewSlackService(
+	repo ports.SlackRepositorer, threadRepo ports.ThreadRepositorer, client ports.SlackClient,
+	ws ports.WorkspaceServicer, sps ports.SpamServicer,
+) *SlackService {
+	return &SlackService{
+		repo:       repo,
+		threadRepo: threadRepo,
+		client:     client,
+		ws:         ws,
+		sps:        sps,
+	}
+}
+
+// GenerateOAuthState returns the signed OAuth state of the Slack app install for the Workspace.
+func (s *SlackService) GenerateOAuthState(workspaceId string, redirectUrl string) (string, error) {
+	now := time.Now().UTC()
+	claims := models.SlackOAuthStateClaims{
+		WorkspaceId: workspaceId,
+		RedirectUrl: redirectUrl,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "slack.zyg.ai",
+			Subject:   workspaceId,
+			Audience:  []string{"slack"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(slackOAuthStateTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	j, err := token.SignedString([]byte(zyg.SlackClientSecret()))
+	if err != nil {
+		slog.Error("failed to sign slack oauth state", slog.Any("err", err))
+		return "", ErrSlackOAuth
+	}
+	return j, nil
+}
+
+func (s *SlackService) VerifyOAuthState(state string) (models.SlackOAuthStateClaims, error) {
+	t, err := jwt.ParseWithClaims(
+		state, &models.SlackOAuthStateClaims{}, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("%v", token.Header["alg"])
+			}
+			return []byte(zyg.SlackClientSecret()), nil
+		}, jwt.WithAudience("slack"))
+	if err != nil {
+		slog.Error("failed to verify slack oauth state", slog.Any("err", err))
+		return models.SlackOAuthStateClaims{}, ErrSlackOAuth
+	}
+	if claims, ok := t.Claims.(*models.SlackOAuthStateClaims); ok {
+		return *claims, nil
+	}
+	return models.SlackOAuthStateClaims{}, ErrSlackOAuth
+}
+
+// InstallSlackApp exchanges the OAuth code for the bot token and saves the Slack workspace install.
+// The Slack channels are synced next by the channel sync job.
+func (s *SlackService) InstallSlackApp(
+	ctx context.Context, workspaceId string, code string) (models.SlackWorkspace, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	access, err := s.client.OAuthAccess(
+		ctx, zyg.SlackClientId(), zyg.SlackClientSecret(), code, zyg.SlackRedirectUrl())
+	if err != nil {
+		hub.CaptureException(err)
+		return models.SlackWorkspace{}, ErrSlackOAuth
+	}
+
+	url, botRef, err := s.client.AuthTest(ctx, access.AccessToken)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.SlackWorkspace{}, ErrSlackOAuth
+	}
+
+	now := time.Now().UTC()
+	slackWorkspace := models.SlackWorkspace{
+		WorkspaceId: workspaceId,
+		Ref:         access.TeamRef,
+		Url:         url,
+		Name:        access.TeamName,
+		Status:      models.SlackWorkspaceStatus{}.Active(),
+		SyncStatus:  models.SlackSyncStatus{}.Pending(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	bot := models.SlackBot{
+		SlackWorkspaceRef: access.TeamRef,
+		BotId:             models.SlackBot{}.GenId(),
+		BotUserRef:        access.BotUserRef,
+		AppRef:            access.AppRef,
+		Scope:             access.Scope,
+		AccessToken:       access.AccessToken,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if botRef != "" {
+		bot.BotRef = &botRef
+	}
+
+	slackWorkspace, _, err = s.repo.SaveSlackInstall(ctx, slackWorkspace, bot)
+	if err != nil {
+		return models.SlackWorkspace{}, ErrSlackWorkspace
+	}
+	return slackWorkspace, nil
+}
+
+func (s *SlackService) GetSlackWorkspace(ctx context.Context, workspaceId string) (models.SlackWorkspace, error) {
+	slackWorkspace, err := s.repo.FetchSlackWorkspaceByWorkspaceId(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.SlackWorkspace{}, ErrSlackWorkspaceNotFound
+	}
+	if err != nil {
+		return models.SlackWorkspace{}, ErrSlackWorkspace
+	}
+	return slackWorkspace, nil
+}
+
+func (s *SlackService) GetSlackWorkspaceByRef(ctx context.Context, ref string) (models.SlackWorkspace, error) {
+	slackWorkspace, err := s.repo.FetchSlackWorkspaceByRef(ctx, ref)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.SlackWorkspace{}, ErrSlackWorkspaceNotFound
+	}
+	if err != nil {
+		return models.SlackWorkspace{}, ErrSlackWorkspace
+	}
+	return slackWorkspace, nil
+}
+
+// botToken returns the access token of the bot installed in the Slack workspace.
+func (s *SlackService) botToken(ctx context.Context, slackWorkspaceRef string) (string, error) {
+	bot, err := s.repo.FetchSlackBotByWorkspaceRef(ctx, slackWorkspaceRef)
+	if err != nil {
+		return "", err
+	}
+	return bot.AccessToken, nil
+}
+
+// SyncSlackChannels fetches the Slack channels visible to the bot and saves them.
+// New Slack Connect channels are enabled by default, the existing channel mapping is kept.
+func (s *SlackService) SyncSlackChannels(
+	ctx context.Context, slackWorkspace models.SlackWorkspace) ([]models.SlackChannel, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	_, err := s.repo.ModifySlackWorkspaceSyncStatus(
+		ctx, slackWorkspace.Ref, models.SlackSyncStatus{}.Syncing(), nil)
+	if err != nil {
+		return []models.SlackChannel{}, ErrSlackSync
+	}
+
+	channels, err := s.syncChannels(ctx, slackWorkspace)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to sync slack channels", slog.Any("err", err), slog.Any("ref", slackWorkspace.Ref))
+		_, _ = s.repo.ModifySlackWorkspaceSyncStatus(
+			ctx, slackWorkspace.Ref, models.SlackSyncStatus{}.Failed(), nil)
+		return []models.SlackChannel{}, ErrSlackSync
+	}
+
+	now := time.Now().UTC()
+	_, err = s.repo.ModifySlackWorkspaceSyncStatus(
+		ctx, slackWorkspace.Ref, models.SlackSyncStatus{}.Synced(), &now)
+	if err != nil {
+		return []models.SlackChannel{}, ErrSlackSync
+	}
+	return channels, nil
+}
+
+func (s *SlackService) syncChannels(
+	ctx context.Context, slackWorkspace models.SlackWorkspace) ([]models.SlackChannel, error) {
+	token, err := s.botToken(ctx, slackWorkspace.Ref)
+	if err != nil {
+		return nil, err
+	}
+	listed, err := s.client.ListChannels(ctx, token, slackWorkspace.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	channels := make([]models.SlackChannel, 0, len(listed))
+	for _, ch := range listed {
+		ch.ChannelId = ch.GenId()
+		ch.IsEnabled = ch.IsConnect()
+		ch.SyncedAt = &now
+		ch.CreatedAt = now
+		ch.UpdatedAt = now
+		channels = append(channels, ch)
+	}
+	return s.repo.UpsertSlackChannels(ctx, slackWorkspace.Ref, channels)
+}
+
+func (s *SlackService) ListSlackWorkspacesDueForSync(
+	ctx context.Context, syncedBefore time.Time) ([]models.SlackWorkspace, error) {
+	slackWorkspaces, err := s.repo.FetchSlackWorkspacesDueForSync(ctx, syncedBefore)
+	if err != nil {
+		return []models.SlackWorkspace{}, ErrSlackWorkspace
+	}
+	return slackWorkspaces, nil
+}
+
+func (s *SlackService) ListSlackChannels(
+	ctx context.Context, slackWorkspace models.SlackWorkspace) ([]models.SlackChannel, error) {
+	channels, err := s.repo.FetchSlackChannelsByWorkspaceRef(ctx, slackWorkspace.Ref)
+	if err != nil {
+		return []models.SlackChannel{}, ErrSlackChannel
+	}
+	return channels, nil
+}
+
+// UpdateSlackChannelMapping enables or disables the Slack channel and sets the label applied to its Threads.
+func (s *SlackService) UpdateSlackChannelMapping(
+	ctx context.Context, slackWorkspace models.SlackWorkspace, channelId string, isEnabled bool, labelId *string,
+) (models.SlackChannel, error) {
+	channel, err := s.repo.FetchSlackChannelById(ctx, slackWorkspace.Ref, channelId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.SlackChannel{}, ErrSlackChannelNotFound
+	}
+	if err != nil {
+		return models.SlackChannel{}, ErrSlackChannel
+	}
+
+	channel.IsEnabled = isEnabled
+	channel.LabelId = labelId
+	channel, err = s.repo.ModifySlackChannelMapping(ctx, channel)
+	if err != nil {
+		return models.SlackChannel{}, ErrSlackChannel
+	}
+	return channel, nil
+}
+
+func (s *SlackService) GetSlackChannel(
+	ctx context.Context, slackWorkspace models.SlackWorkspace, channelRef string) (models.SlackChannel, error) {
+	channel, err := s.repo.FetchSlackChannelByRef(ctx, slackWorkspace.Ref, channelRef)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.SlackChannel{}, ErrSlackChannelNotFound
+	}
+	if err != nil {
+		return models.SlackChannel{}, ErrSlackChannel
+	}
+	return channel, nil
+}
+
+func (s *SlackService) GetSlackUser(
+	ctx context.Context, slackWorkspace models.SlackWorkspace, userRef string) (models.SlackUser, error) {
+	token, err := s.botToken(ctx, slackWorkspace.Ref)
+	if err != nil {
+		return models.SlackUser{}, ErrSlackWorkspace
+	}
+	user, err := s.client.UserInfo(ctx, token, userRef)
+	if err != nil {
+		return models.SlackUser{}, ErrSlackInbound
+	}
+	return user, nil
+}
+
+func (s *SlackService) IsSlackMessageProcessed(
+	ctx context.Context, slackWorkspaceRef, channelRef, ts string) (bool, error) {
+	exists, err := s.repo.CheckSlackMessageExists(ctx, slackWorkspaceRef, channelRef, ts)
+	if err != nil {
+		return false, ErrSlackInbound
+	}
+	return exists, nil
+}
+
+// ReceiveInboundSlackMessage processes the message posted in the enabled Slack channel.
+// The Customer is resolved or created by the Slack user's email, otherwise by the Slack user.
+func (s *SlackService) ReceiveInboundSlackMessage(ctx context.Context, inbound *models.SlackInbound) error {
+	slackWorkspace, err := s.GetSlackWorkspaceByRef(ctx, inbound.TeamRef)
+	if errors.Is(err, ErrSlackWorkspaceNotFound) {
+		slog.Info("skipped slack message for unknown slack workspace", slog.Any("teamRef", inbound.TeamRef))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !slackWorkspace.IsActive() {
+		return nil
+	}
+
+	channel, err := s.GetSlackChannel(ctx, slackWorkspace, inbound.ChannelRef)
+	if errors.Is(err, ErrSlackChannelNotFound) {
+		slog.Info("skipped slack message for unsynced channel", slog.Any("channelRef", inbound.ChannelRef))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !channel.IsEnabled {
+		return nil
+	}
+	// In Slack Connect channels only the messages from the customer's side are inbound,
+	// the workspace's own Slack users reply from the dashboard.
+	if channel.IsConnect() && inbound.UserTeam == slackWorkspace.Ref {
+		return nil
+	}
+
+	// Check if the message has already been processed, events are retried on failure.
+	isProcessed, err := s.IsSlackMessageProcessed(ctx, slackWorkspace.Ref, inbound.ChannelRef, inbound.Ts)
+	if err != nil {
+		return err
+	}
+	if isProcessed {
+		slog.Info("inbound slack message is already processed")
+		return nil
+	}
+
+	user, err := s.GetSlackUser(ctx, slackWorkspace, inbound.UserRef)
+	if err != nil {
+		return err
+	}
+	if user.IsBot {
+		return nil
+	}
+
+	var customer models.Customer
+	if user.Email != "" {
+		customer, _, err = s.ws.CreateCustomerWithEmail(
+			ctx, slackWorkspace.WorkspaceId, user.Email, false, user.DisplayName())
+	} else {
+		externalId := "slack:" + inbound.UserTeam + ":" + inbound.UserRef
+		customer, _, err = s.ws.CreateCustomerWithExternalId(
+			ctx, slackWorkspace.WorkspaceId, externalId, user.DisplayName())
+	}
+	if err != nil {
+		return err
+	}
+	// Blocked customer's message is acknowledged without being processed.
+	if customer.IsBlocked {
+		slog.Info("dropped inbound slack message from blocked customer",
+			slog.Any("customerId", customer.CustomerId))
+		return nil
+	}
+
+	inbound.Spam, err = s.sps.CheckInbound(ctx, models.SpamCandidate{
+		WorkspaceId: slackWorkspace.WorkspaceId,
+		Channel:     models.ThreadChannel{}.Slack(),
+		CustomerId:  customer.CustomerId,
+		TextBody:    inbound.Text,
+	})
+	if err != nil {
+		return err
+	}
+
+	member, err := s.ws.GetSystemMember(ctx, slackWorkspace.WorkspaceId)
+	if err != nil {
+		return err
+	}
+
+	thread, message, err := s.ProcessInboundSlackMessage(
+		ctx, slackWorkspace, channel, customer.AsCustomerActor(), member.AsMemberActor(), inbound)
+	if err != nil {
+		return err
+	}
+	slog.Info("processed inbound slack message",
+		slog.Any("threadId", thread.ThreadId), slog.Any("messageId", message.MessageId))
+	return nil
+}
+
+// ProcessInboundSlackMessage appends the Slack thread reply to the mapped Thread,
+// otherwise the top level message starts a new Thread labelled as per the channel mapping.
+func (s *SlackService) ProcessInboundSlackMessage(
+	ctx context.Context, slackWorkspace models.SlackWorkspace, channel models.SlackChannel,
+	customer models.CustomerActor, createdBy models.MemberActor, inbound *models.SlackInbound,
+) (models.Thread, models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	threadChannel := models.ThreadChannel{}.Slack()
+
+	var thread *models.Thread
+	threadExists := false
+	threadId, err := s.repo.FetchSlackThreadIdByTs(ctx, slackWorkspace.Ref, channel.ChannelRef, inbound.RootTs())
+	switch {
+	case errors.Is(err, repository.ErrEmpty):
+		thread = models.NewThread(
+			slackWorkspace.WorkspaceId, customer, createdBy, threadChannel,
+			models.SetThreadTitle(inbound.Text),
+			models.SetThreadDescription(inbound.Text),
+		)
+	case err != nil:
+		hub.CaptureException(err)
+		slog.Error("failed to fetch slack thread", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, ErrSlackInbound
+	default:
+		existingThread, err := s.threadRepo.LookupByWorkspaceThreadId(
+			ctx, slackWorkspace.WorkspaceId, threadId, &threadChannel)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to fetch slack thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrSlackInbound
+		}
+		thread = &existingThread
+		threadExists = true
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, threadChannel,
+		models.SetMessageCustomer(customer),
+		models.SetMessageTextBody(inbound.Text),
+		models.SetMarkdownBody(inbound.Text),
+	)
+	thread.SetNextInboundSeq(newMessage.PreviewText())
+	// Customer reply on existing thread applies the automatic stage transition.
+	if threadExists {
+		thread.OnInboundMessage(createdBy)
+	}
+	// Flagged by the spam pipeline, overrides the stage transition.
+	if inbound.Spam.IsSpam {
+		thread.MarkSpam(createdBy)
+	}
+
+	threadMessage := models.ThreadMessage{
+		Thread:  thread,
+		Message: newMessage,
+	}
+	var message models.Message
+	if threadExists {
+		message, err = s.threadRepo.AppendInboundThreadMessage(ctx, threadMessage)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to append inbound slack message to existing thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrSlackInbound
+		}
+	} else {
+		var insThread models.Thread
+		insThread, message, err = s.threadRepo.InsertInboundThreadMessage(ctx, threadMessage)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to insert inbound slack message to new thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrSlackInbound
+		}
+		thread = &insThread
+
+		if channel.LabelId != nil {
+			label := models.ThreadLabel{
+				ThreadId: thread.ThreadId,
+				LabelId:  *channel.LabelId,
+				AddedBy:  models.LabelAddedBy{}.System(),
+			}
+			if _, _, err := s.threadRepo.SetThreadLabel(ctx, label); err != nil {
+				hub.CaptureException(err)
+				slog.Error("failed to set slack channel label", slog.Any("err", err))
+			}
+		}
+	}
+
+	// Message log maps the Slack thread to the Thread and marks the inbound message as processed.
+	now := time.Now().UTC()
+	messageLog := models.SlackMessageLog{
+		MessageId:         message.MessageId,
+		SlackWorkspaceRef: slackWorkspace.Ref,
+		ChannelRef:        channel.ChannelRef,
+		Ts:                inbound.Ts,
+		ThreadTs:          inbound.RootTs(),
+		UserRef:           inbound.UserRef,
+		MessageType:       "inbound",
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if _, err := s.repo.InsertSlackMessageLog(ctx, messageLog); err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to insert inbound slack message log", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, ErrSlackInbound
+	}
+	return *thread, message, nil
+}
+
+// SendThreadSlackReply posts the Member's reply in the Slack thread the Thread is mapped to.
+func (s *SlackService) SendThreadSlackReply(
+	ctx context.Context, slackWorkspace models.SlackWorkspace, thread models.Thread,
+	member models.Member, textBody string,
+) (models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	root, err := s.repo.FetchSlackRootMessageLog(ctx, thread.ThreadId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.Message{}, ErrSlackThreadNotLinked
+	}
+	if err != nil {
+		return models.Message{}, ErrSlackOutbound
+	}
+
+	token, err := s.botToken(ctx, slackWorkspace.Ref)
+	if err != nil {
+		return models.Message{}, ErrSlackOutbound
+	}
+	ts, err := s.client.PostMessage(ctx, token, root.ChannelRef, textBody, root.ThreadTs)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to post slack message", slog.Any("err", err))
+		return models.Message{}, ErrSlackOutbound
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, models.ThreadChannel{}.Slack(),
+		models.SetMessageMember(member.AsMemberActor()),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(textBody),
+	)
+	thread.SetNextOutboundSeq(member.AsMemberActor(), newMessage.PreviewText())
+	thread.OnOutboundMessage(member.AsMemberActor())
+
+	threadMessage := models.ThreadMessage{
+		Thread:  &thread,
+		Message: newMessage,
+	}
+	message, err := s.threadRepo.AppendOutboundThreadMessage(ctx, threadMessage)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to append outbound slack thread message", slog.Any("err", err))
+		return models.Message{}, ErrSlackOutbound
+	}
+
+	now := time.Now().UTC()
+	messageLog := models.SlackMessageLog{
+		MessageId:         message.MessageId,
+		SlackWorkspaceRef: slackWorkspace.Ref,
+		ChannelRef:        root.ChannelRef,
+		Ts:                ts,
+		ThreadTs:          root.ThreadTs,
+		MessageType:       "outbound",
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	if _, err := s.repo.InsertSlackMessageLog(ctx, messageLog); err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to insert outbound slack message log", slog.Any("err", err))
+		return models.Message{}, ErrSlackOutbound
+	}
+	return message, nil
+}
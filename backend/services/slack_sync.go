@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg/ports"
+)
+
+// SlackChannelSyncer periodically syncs the Slack channels of the installed Slack workspaces
+// not synced within the interval.
+type SlackChannelSyncer struct {
+	ss       ports.SlackServicer
+	interval time.Duration
+}
+
+func NewSlackChannelSyncer(ss ports.SlackServicer, interval time.Duration) *SlackChannelSyncer {
+	return &SlackChannelSyncer{
+		ss:       ss,
+		interval: interval,
+	}
+}
+
+// Run syncs on every interval until the context is done.
+func (sc *SlackChannelSyncer) Run(ctx context.Context) {
+	// Background context has no request hub, services capture exceptions with the context hub.
+	ctx = sentry.SetHubOnContext(ctx, sentry.CurrentHub().Clone())
+
+	slog.Info("slack channel syncer running", slog.Any("interval", sc.interval))
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+	for {
+		sc.Sync(ctx)
+		select {
+		case <-ctx.Done():
+			slog.Info("slack channel syncer stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync syncs the channels of the Slack workspaces due for sync.
+func (sc *SlackChannelSyncer) Sync(ctx context.Context) {
+	syncedBefore := time.Now().UTC().Add(-sc.interval)
+	slackWorkspaces, err := sc.ss.ListSlackWorkspacesDueForSync(ctx, syncedBefore)
+	if err != nil {
+		slog.Error("failed to list slack workspaces due for sync", slog.Any("err", err))
+		return
+	}
+	for _, slackWorkspace := range slackWorkspaces {
+		channels, err := sc.ss.SyncSlackChannels(ctx, slackWorkspace)
+		if err != nil {
+			slog.Error("failed to sync slack channels",
+				slog.Any("err", err), slog.Any("ref", slackWorkspace.Ref))
+			continue
+		}
+		slog.Info("synced slack channels",
+			slog.Any("ref", slackWorkspace.Ref), slog.Any("channels", len(channels)))
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// SMSService handles the SMS channel, outbound SMS is sent through the provider
+// selected by the workspace SMS setting.
+type SMSService struct {
+	repo       ports.SMSRepositorer
+	threadRepo ports.ThreadRepositorer
+	providers  map[string]ports.SMSProvider
+}
+
+func NewSMSService(
+	repo ports.SMSRepositorer, threadRepo ports.ThreadRepositorer, providers ...ports.SMSProvider) *SMSService {
+	registered := make(map[string]ports.SMSProvider, len(providers))
+	for _, p := range providers {
+		registered[p.Name()] = p
+	}
+	return &SMSService{
+		repo:       repo,
+		threadRepo: threadRepo,
+		providers:  registered,
+	}
+}
+
+// GetSMSSetting returns the SMS setting of the workspace,
+// or the default disabled setting if not configured.
+func (s *SMSService) GetSMSSetting(ctx context.Context, workspaceId string) (models.SMSSetting, error) {
+	setting, err := s.repo.FetchSMSSettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewSMSSetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.SMSSetting{}, ErrSMSSetting
+	}
+	return setting, nil
+}
+
+func (s *SMSService) SaveSMSSetting(ctx context.Context, setting models.SMSSetting) (models.SMSSetting, error) {
+	if _, ok := s.providers[setting.Provider]; !ok {
+		return models.SMSSetting{}, ErrSMSProvider
+	}
+	setting, err := s.repo.SaveSMSSetting(ctx, setting)
+	if err != nil {
+		return models.SMSSetting{}, ErrSMSSetting
+	}
+	return setting, nil
+}
+
+func (s *SMSService) IsInboundSMSProcessed(ctx context.Context, provider, providerMessageId string) (bool, error) {
+	exists, err := s.repo.CheckSMSInboundMessageExists(ctx, provider, providerMessageId)
+	if err != nil {
+		return false, ErrSMSLog
+	}
+	return exists, nil
+}
+
+// ProcessInboundSMS appends the Customer's inbound SMS to the Customer's recent SMS Thread
+// within the conversation window, otherwise starts a new Thread.
+func (s *SMSService) ProcessInboundSMS(
+	ctx context.Context, setting models.SMSSetting,
+	customer models.CustomerActor, createdBy models.MemberActor, inbound *models.InboundSMS,
+) (models.Thread, models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	channel := models.ThreadChannel{}.SMS()
+
+	textBody := inbound.Body
+	if textBody == "" && inbound.NumMedia > 0 {
+		textBody = fmt.Sprintf("[%d media attachment(s)]", inbound.NumMedia)
+	}
+
+	var thread *models.Thread
+	threadExists := false
+	since := time.Now().UTC().Add(-setting.ConversationWindow())
+	threadId, err := s.repo.FetchRecentCustomerSMSThreadId(ctx, setting.WorkspaceId, customer.CustomerId, since)
+	switch {
+	case errors.Is(err, repository.ErrEmpty):
+		thread = models.NewThread(
+			setting.WorkspaceId, customer, createdBy, channel,
+			models.SetThreadTitle(textBody),
+			models.SetThreadDescription(textBody),
+		)
+	case err != nil:
+		hub.CaptureException(err)
+		slog.Error("failed to fetch recent customer sms thread", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, ErrSMSInbound
+	default:
+		existingThread, err := s.threadRepo.LookupByWorkspaceThreadId(ctx, setting.WorkspaceId, threadId, &channel)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to fetch recent customer sms thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrSMSInbound
+		}
+		thread = &existingThread
+		threadExists = true
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, channel,
+		models.SetMessageCustomer(customer),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(textBody),
+	)
+	thread.SetNextInboundSeq(newMessage.PreviewText())
+	// Customer reply on existing thread applies the automatic stage transition.
+	if threadExists {
+		thread.OnInboundMessage(createdBy)
+	}
+	// Flagged by the spam pipeline, overrides the stage transition.
+	if inbound.Spam.IsSpam {
+		thread.MarkSpam(createdBy)
+	}
+	messageLog := inbound.ToSMSMessageLog(newMessage.MessageId, setting.Provider)
+
+	if threadExists {
+		newMessage, err = s.repo.AppendSMSInboundThreadMessage(ctx, thread, newMessage, &messageLog)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to append inbound sms to existing thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrSMSInbound
+		}
+	} else {
+		thread, newMessage, err = s.repo.InsertSMSInboundThreadMessage(ctx, thread, newMessage, &messageLog)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to insert inbound sms to new thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrSMSInbound
+		}
+	}
+	return *thread, *newMessage, nil
+}
+
+// SendThreadSMSReply sends the Member's reply to the Customer's phone through the workspace SMS provider.
+// Delivery status is reported by the provider to the status webhook of the workspace, with the global webhook
+// credentials until the cutover, then to the status webhook configured on the sender with the workspace credential.
+func (s *SMSService) SendThreadSMSReply(
+	ctx context.Context, setting models.SMSSetting, thread models.Thread,
+	member models.Member, customer models.Customer, textBody string,
+) (models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	if !customer.Phone.Valid {
+		slog.Error("customer phone is not valid", slog.Any("customerId", customer.CustomerId))
+		hub.Scope().SetTag("customerId", customer.CustomerId)
+		hub.CaptureMessage("customer phone is not valid or does not exist - cannot send reply sms")
+		return models.Message{}, ErrSMSOutbound
+	}
+
+	provider, ok := s.providers[setting.Provider]
+	if !ok {
+		return models.Message{}, ErrSMSProvider
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, models.ThreadChannel{}.SMS(),
+		models.SetMessageMember(member.AsMemberActor()),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(textBody),
+	)
+	thread.SetNextOutboundSeq(member.AsMemberActor(), newMessage.PreviewText())
+	thread.OnOutboundMessage(member.AsMemberActor())
+
+	outbound := models.OutboundSMS{
+		FromPhone: setting.PhoneNumber,
+		ToPhone:   customer.Phone.String,
+		Body:      textBody,
+	}
+	if time.Now().Before(zyg.WebhookLegacyAuthUntil()) {
+		outbound.StatusCallback = zyg.WebhookUrl() + models.SMSStatusHookPath(setting.WorkspaceId)
+	}
+	result, err := provider.Send(ctx, setting, outbound)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send sms", slog.Any("err", err))
+		return models.Message{}, ErrSMSOutbound
+	}
+
+	now := time.Now().UTC()
+	messageLog := models.SMSMessageLog{
+		MessageId:         newMessage.MessageId,
+		Provider:          provider.Name(),
+		ProviderMessageId: result.ProviderMessageId,
+		MessageType:       "outbound",
+		FromPhone:         outbound.FromPhone,
+		ToPhone:           outbound.ToPhone,
+		Status:            models.SMSStatus{}.Normalize(result.Status),
+		ErrorCode:         result.ErrorCode,
+		ErrorMessage:      result.ErrorMessage,
+		Payload:           result.Payload,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	newMessage, err = s.repo.AppendSMSOutboundThreadMessage(ctx, &thread, newMessage, &messageLog)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to append outbound sms thread message", slog.Any("err", err))
+		return models.Message{}, ErrSMSOutbound
+	}
+	return *newMessage, nil
+}
+
+// UpdateSMSDeliveryStatus updates the outbound SMS status as reported by the provider.
+func (s *SMSService) UpdateSMSDeliveryStatus(
+	ctx context.Context, setting models.SMSSetting, update models.SMSStatusUpdate) (models.SMSMessageLog, error) {
+	update.Status = models.SMSStatus{}.Normalize(update.Status)
+	messageLog, err := s.repo.ModifySMSMessageLogStatus(ctx, setting.Provider, update)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.SMSMessageLog{}, ErrSMSLogNotFound
+	}
+	if err != nil {
+		return models.SMSMessageLog{}, ErrSMSLog
+	}
+	return messageLog, nil
+}
+
+func (s *SMSService) ListThreadSMSLogs(ctx context.Context, threadId string) ([]models.SMSMessageLog, error) {
+	logs, err := s.repo.FetchSMSMessageLogsByThreadId(ctx, threadId)
+	if err != nil {
+		return []models.SMSMessageLog{}, ErrSMSLog
+	}
+	return logs, nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// Scores contributed by the built-in scorers, on the same scale as SpamAssassin.
+const (
+	spamStatusScore    = 5.0   // X-Spam-Status flagged without a parsable score
+	bulkMailScore      = 3.0   // Precedence bulk, junk or list
+	autoSubmitScore    = 2.0   // Auto-Submitted other than no
+	blockedSenderScore = 100.0 // Sender on the workspace block list
+	chatRateScore      = 5.0   // Customer chat over the rate limit
+)
+
+type SpamService struct {
+	repo    ports.SpamRepositorer
+	scorers []ports.SpamScorer
+}
+
+// NewSpamService creates the spam service with the specified scorers.
+// If no scorers are specified, DefaultSpamScorers are used.
+func NewSpamService(
+	repo ports.SpamRepositorer, bls ports.BlocklistServicer, scorers ...ports.SpamScorer) *SpamService {
+	if len(scorers) == 0 {
+		scorers = DefaultSpamScorers(repo, bls)
+	}
+	return &SpamService{
+		repo:    repo,
+		scorers: scorers,
+	}
+}
+
+// DefaultSpamScorers returns the built-in spam scorers.
+func DefaultSpamScorers(repo ports.SpamRepositorer, bls ports.BlocklistServicer) []ports.SpamScorer {
+	return []ports.SpamScorer{
+		SpamHeaderScorer{},
+		BulkMailScorer{},
+		NewSenderListScorer(repo, bls),
+		NewChatRateScorer(repo),
+	}
+}
+
+// CheckInbound runs the inbound message through the spam pipeline.
+// Scorer failures are logged and skipped, so that the inbound message is never lost.
+func (s *SpamService) CheckInbound(
+	ctx context.Context, candidate models.SpamCandidate) (models.SpamVerdict, error) {
+	setting, err := s.GetSpamSetting(ctx, candidate.WorkspaceId)
+	if err != nil {
+		return models.SpamVerdict{}, err
+	}
+	if !setting.IsEnabled {
+		return models.NewSpamVerdict(setting.Threshold, nil), nil
+	}
+
+	signals := make([]models.SpamSignal, 0, len(s.scorers))
+	for _, scorer := range s.scorers {
+		signal, err := scorer.Score(ctx, setting, candidate)
+		if err != nil {
+			slog.Error("failed to score inbound for spam",
+				slog.Any("scorer", scorer.Name()), slog.Any("err", err))
+			continue
+		}
+		if signal.Score == 0 && !signal.Allow {
+			continue
+		}
+		signal.Scorer = scorer.Name()
+		signals = append(signals, signal)
+	}
+
+	verdict := models.NewSpamVerdict(setting.Threshold, signals)
+	if verdict.IsSpam {
+		slog.Info("inbound flagged as spam",
+			slog.Any("workspaceId", candidate.WorkspaceId),
+			slog.Any("customerId", candidate.CustomerId),
+			slog.Any("score", verdict.Score))
+	}
+	return verdict, nil
+}
+
+// GetSpamSetting returns the spam setting of the workspace,
+// or the default disabled setting if the workspace has not saved one.
+func (s *SpamService) GetSpamSetting(ctx context.Context, workspaceId string) (models.SpamSetting, error) {
+	setting, err := s.repo.FetchSpamSettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewSpamSetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.SpamSetting{}, ErrSpamSetting
+	}
+	return setting, nil
+}
+
+func (s *SpamService) SaveSpamSetting(ctx context.Context, setting models.SpamSetting) (models.SpamSetting, error) {
+	setting, err := s.repo.SaveSpamSetting(ctx, setting)
+	if err != nil {
+		return models.SpamSetting{}, ErrSpamSetting
+	}
+	return setting, nil
+}
+
+func (s *SpamService) ListSenderRules(ctx context.Context, workspaceId string) ([]models.SpamSenderRule, error) {
+	rules, err := s.repo.FetchSpamSenderRulesByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return []models.SpamSenderRule{}, ErrSpamSenderRule
+	}
+	return rules, nil
+}
+
+// AddSenderRule adds the sender rule, replacing the action of the existing rule for the same sender.
+func (s *SpamService) AddSenderRule(
+	ctx context.Context, rule models.SpamSenderRule) (models.SpamSenderRule, error) {
+	rule, err := s.repo.UpsertSpamSenderRule(ctx, rule)
+	if err != nil {
+		return models.SpamSenderRule{}, ErrSpamSenderRule
+	}
+	return rule, nil
+}
+
+func (s *SpamService) RemoveSenderRule(ctx context.Context, workspaceId, ruleId string) error {
+	err := s.repo.DeleteSpamSenderRuleById(ctx, workspaceId, ruleId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return ErrSpamSenderRuleNotFound
+	}
+	if err != nil {
+		return ErrSpamSenderRule
+	}
+	return nil
+}
+
+// SpamHeaderScorer scores the spam headers added by the mail provider,
+// `X-Spam-Score` and `X-Spam-Status` as added by Postmark.
+type SpamHeaderScorer struct{}
+
+func (SpamHeaderScorer) Name() string {
+	return "spam_header"
+}
+
+func (SpamHeaderScorer) Score(
+	_ context.Context, _ models.SpamSetting, candidate models.SpamCandidate) (models.SpamSignal, error) {
+	if v, ok := candidate.Headers["X-Spam-Score"]; ok {
+		if score, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && score > 0 {
+			return models.SpamSignal{Score: score, Reason: "X-Spam-Score " + v}, nil
+		}
+	}
+	// e.g. `Yes, score=6.1 required=5.0 tests=...`
+	status, ok := candidate.Headers["X-Spam-Status"]
+	if !ok || !strings.HasPrefix(strings.ToLower(strings.TrimSpace(status)), "yes") {
+		return models.SpamSignal{}, nil
+	}
+	for _, field := range strings.Fields(strings.ReplaceAll(status, ",", " ")) {
+		if v, found := strings.CutPrefix(field, "score="); found {
+			if score, err := strconv.ParseFloat(v, 64); err == nil && score > 0 {
+				return models.SpamSignal{Score: score, Reason: "X-Spam-Status flagged"}, nil
+			}
+		}
+	}
+	return models.SpamSignal{Score: spamStatusScore, Reason: "X-Spam-Status flagged"}, nil
+}
+
+// BulkMailScorer scores the automated and bulk mail as per `Auto-Submitted` and `Precedence` headers.
+type BulkMailScorer struct{}
+
+func (BulkMailScorer) Name() string {
+	return "bulk_mail"
+}
+
+func (BulkMailScorer) Score(
+	_ context.Context, _ models.SpamSetting, candidate models.SpamCandidate) (models.SpamSignal, error) {
+	var signal models.SpamSignal
+	var reasons []string
+	if v, ok := candidate.Headers["Auto-Submitted"]; ok {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" && v != "no" {
+			signal.Score += autoSubmitScore
+			reasons = append(reasons, "Auto-Submitted "+v)
+		}
+	}
+	if v, ok := candidate.Headers["Precedence"]; ok {
+		switch v = strings.ToLower(strings.TrimSpace(v)); v {
+		case "bulk", "junk", "list":
+			signal.Score += bulkMailScore
+			reasons = append(reasons, "Precedence "+v)
+		}
+	}
+	signal.Reason = strings.Join(reasons, ", ")
+	return signal, nil
+}
+
+// SenderListScorer matches the sender against the workspace spam allow list by email address, domain
+// and customer, and against the workspace blocklist by email address and domain.
+// Allow takes precedence over block.
+type SenderListScorer struct {
+	repo ports.SpamRepositorer
+	bls  ports.BlocklistServicer
+}
+
+func NewSenderListScorer(repo ports.SpamRepositorer, bls ports.BlocklistServicer) SenderListScorer {
+	return SenderListScorer{repo: repo, bls: bls}
+}
+
+func (SenderListScorer) Name() string {
+	return "sender_list"
+}
+
+func (sl SenderListScorer) Score(
+	ctx context.Context, _ models.SpamSetting, candidate models.SpamCandidate) (models.SpamSignal, error) {
+	kind := models.SpamRuleKind{}
+	matches := make(map[string]string, 3)
+	if candidate.FromEmail != "" {
+		matches[kind.Address()] = models.NormalizeSpamRuleValue(candidate.FromEmail)
+	}
+	if domain := candidate.FromDomain(); domain != "" {
+		matches[kind.Domain()] = domain
+	}
+	if candidate.CustomerId != "" {
+		matches[kind.Customer()] = candidate.CustomerId
+	}
+
+	rules, err := sl.repo.FetchMatchingSpamSenderRules(ctx, candidate.WorkspaceId, matches)
+	if err != nil {
+		return models.SpamSignal{}, err
+	}
+
+	for _, rule := range rules {
+		if rule.Action == (models.SpamRuleAction{}).Allow() {
+			return models.SpamSignal{
+				Allow: true, Reason: fmt.Sprintf("%s %s allowed", rule.Kind, rule.Value)}, nil
+		}
+	}
+
+	if candidate.FromEmail == "" {
+		return models.SpamSignal{}, nil
+	}
+	verdict, err := sl.bls.CheckSender(ctx, candidate.WorkspaceId, candidate.FromEmail, "")
+	if err != nil {
+		return models.SpamSignal{}, err
+	}
+	if verdict.IsBlocked {
+		return models.SpamSignal{
+			Score: blockedSenderScore, Reason: fmt.Sprintf("%s %s blocked", verdict.Kind, verdict.Value)}, nil
+	}
+	return models.SpamSignal{}, nil
+}
+
+// ChatRateScorer scores the customer sending chat messages over the workspace rate limit.
+type ChatRateScorer struct {
+	repo ports.SpamRepositorer
+}
+
+func NewChatRateScorer(repo ports.SpamRepositorer) ChatRateScorer {
+	return ChatRateScorer{repo: repo}
+}
+
+func (ChatRateScorer) Name() string {
+	return "chat_rate"
+}
+
+func (cr ChatRateScorer) Score(
+	ctx context.Context, setting models.SpamSetting, candidate models.SpamCandidate) (models.SpamSignal, error) {
+	if candidate.Channel != (models.ThreadChannel{}).InAppChat() || candidate.CustomerId == "" {
+		return models.SpamSignal{}, nil
+	}
+	since := time.Now().UTC().Add(-setting.ChatRateWindow())
+	count, err := cr.repo.CountCustomerMessagesSince(ctx, candidate.CustomerId, since)
+	if err != nil {
+		return models.SpamSignal{}, err
+	}
+	if count < setting.ChatRateLimit {
+		return models.SpamSignal{}, nil
+	}
+	return models.SpamSignal{
+		Score:  chatRateScore,
+		Reason: fmt.Sprintf("%d chat messages within %s", count, setting.ChatRateWindow()),
+	}, nil
+}
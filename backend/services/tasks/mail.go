@@ -2,83 +2,321 @@ package tasks
 
 import (
 	"bytes"
-	"fmt"
 	"html/template"
 	"log/slog"
+	"strings"
+	texttemplate "text/template"
 
-	"github.com/resend/resend-go/v2"
-	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/utils"
 )
 
-type KycMailData struct {
-	PreviewText string
-	MagicLink   string
+// TransactionalMailData is the data available to the transactional mail wrapper templates,
+// the mail body is rendered from the workspace or the built-in template of the kind.
+type TransactionalMailData struct {
+	PreviewText   string
+	Locale        string
+	WorkspaceName string
+	IsBranded     bool
+	LogoUrl       string
+	Header        string
+	Footer        string
+	HTMLBody      template.HTML
+	TextBody      string
 }
 
-func SendKycMail(to string, body string, verifyLink string) error {
-	subject := "Started a new chat on Zyg."
-	htmlTempl, err := template.ParseFiles("static/templates/mails/kyc.html")
+// NewTransactionalMailData returns the transactional mail wrapper data branded as per the workspace reply setting.
+// The missing body variant is derived from the other.
+func NewTransactionalMailData(
+	workspace models.Workspace, setting models.MailReplySetting, locale string, htmlBody string, textBody string,
+) TransactionalMailData {
+	if textBody == "" && htmlBody != "" {
+		extracted, err := utils.ExtractTextFromHTML(htmlBody)
+		if err != nil {
+			slog.Error("failed to extract transactional mail text", slog.Any("err", err))
+		}
+		textBody = strings.TrimSpace(extracted)
+	}
+	data := TransactionalMailData{
+		PreviewText:   previewText(textBody),
+		Locale:        locale,
+		WorkspaceName: workspace.Name,
+		IsBranded:     setting.IsBranded,
+		LogoUrl:       setting.LogoUrl,
+		Header:        setting.Header,
+		Footer:        setting.Footer,
+		TextBody:      textBody,
+	}
+	if htmlBody != "" {
+		data.HTMLBody = template.HTML(htmlBody)
+	} else {
+		data.HTMLBody = template.HTML(utils.TextToHTML(textBody))
+	}
+	return data
+}
+
+// NewTransactionalMail renders the transactional mail HTML and text with the workspace branding.
+func NewTransactionalMail(mail models.Mail, data TransactionalMailData) (models.Mail, error) {
+	htmlTempl, err := template.ParseFiles("static/templates/mails/transactional.html")
 	if err != nil {
 		slog.Error("error parsing html template file", slog.Any("err", err))
-		return err
+		return models.Mail{}, err
 	}
-	// Read text template for non html text content.
-	textTempl, err := template.ParseFiles("static/templates/mails/text/kyc.txt")
+	textTempl, err := texttemplate.ParseFiles("static/templates/mails/text/transactional.txt")
 	if err != nil {
 		slog.Error("error parsing text template file", slog.Any("err", err))
-		return err
+		return models.Mail{}, err
+	}
+
+	var htmlTemplOutput bytes.Buffer
+	err = htmlTempl.Execute(&htmlTemplOutput, data)
+	if err != nil {
+		slog.Error("error executing html template", slog.Any("err", err))
+		return models.Mail{}, err
+	}
+
+	var textTemplOutput bytes.Buffer
+	err = textTempl.Execute(&textTemplOutput, data)
+	if err != nil {
+		slog.Error("error executing text template", slog.Any("err", err))
+		return models.Mail{}, err
 	}
 
-	data := KycMailData{
-		PreviewText: body,
-		MagicLink:   verifyLink,
+	mail.HTMLBody = htmlTemplOutput.String()
+	mail.TextBody = textTemplOutput.String()
+	return mail, nil
+}
+
+// ReplyMailQuote is the previous message of the Thread quoted in the reply.
+type ReplyMailQuote struct {
+	Author     string
+	SentAt     string
+	TextBody   string
+	QuotedText string // TextBody with the `> ` quote prefix
+}
+
+// ReplyMailData is the data available to the reply mail templates.
+// Both HTML and text variants are rendered from the same data, keeping the variants consistent.
+type ReplyMailData struct {
+	PreviewText   string
+	WorkspaceName string
+	IsBranded     bool
+	LogoUrl       string
+	Header        string
+	Footer        string
+	HTMLBody      template.HTML
+	TextBody      string
+	SignatureHTML template.HTML
+	SignatureText string
+	Quote         *ReplyMailQuote
+}
+
+// NewReplyMailData returns the reply mail template data for the Member's reply.
+// The reply HTML is the Member's own, as sent from the editor, otherwise rendered from the text.
+// The missing signature variant is derived from the other.
+func NewReplyMailData(
+	workspace models.Workspace, setting models.MailReplySetting, signature models.MailSignature,
+	reply models.Message, quote *models.Message,
+) ReplyMailData {
+	data := ReplyMailData{
+		PreviewText:   previewText(reply.TextBody),
+		WorkspaceName: workspace.Name,
+		IsBranded:     setting.IsBranded,
+		LogoUrl:       setting.LogoUrl,
+		Header:        setting.Header,
+		Footer:        setting.Footer,
+		TextBody:      reply.TextBody,
+	}
+	if reply.HTMLBody != "" {
+		data.HTMLBody = template.HTML(reply.HTMLBody)
+	} else {
+		data.HTMLBody = template.HTML(utils.TextToHTML(reply.TextBody))
+	}
+
+	data.SignatureHTML, data.SignatureText = signatureVariants(signature)
+
+	if setting.IncludeQuote && quote != nil {
+		text := quote.TextBody
+		if text == "" {
+			text = quote.MarkdownBody
+		}
+		var author string
+		if quote.Customer != nil {
+			author = quote.Customer.Name
+		} else if quote.Member != nil {
+			author = quote.Member.Name
+		}
+		data.Quote = &ReplyMailQuote{
+			Author:     author,
+			SentAt:     quote.CreatedAt.UTC().Format("Mon, 2 Jan 2006 at 15:04 MST"),
+			TextBody:   text,
+			QuotedText: quoteText(text),
+		}
+	}
+	return data
+}
+
+// NewReplyMail renders the reply mail HTML and text with the workspace reply wrapper.
+func NewReplyMail(mail models.Mail, data ReplyMailData) (models.Mail, error) {
+	htmlTempl, err := template.ParseFiles("static/templates/mails/reply.html")
+	if err != nil {
+		slog.Error("error parsing html template file", slog.Any("err", err))
+		return models.Mail{}, err
+	}
+	textTempl, err := texttemplate.ParseFiles("static/templates/mails/text/reply.txt")
+	if err != nil {
+		slog.Error("error parsing text template file", slog.Any("err", err))
+		return models.Mail{}, err
 	}
 
 	var htmlTemplOutput bytes.Buffer
 	err = htmlTempl.Execute(&htmlTemplOutput, data)
 	if err != nil {
 		slog.Error("error executing html template", slog.Any("err", err))
-		return err
+		return models.Mail{}, err
 	}
-	htmlOutput := htmlTemplOutput.String()
 
 	var textTemplOutput bytes.Buffer
 	err = textTempl.Execute(&textTemplOutput, data)
 	if err != nil {
 		slog.Error("error executing text template", slog.Any("err", err))
-		return err
+		return models.Mail{}, err
 	}
-	textOutput := textTemplOutput.String()
 
-	fmt.Println("************* HTML FOR MAIL **************")
-	fmt.Println(htmlOutput)
-	fmt.Println("************* END HTML FOR MAIL **************")
+	mail.HTMLBody = htmlTemplOutput.String()
+	mail.TextBody = textTemplOutput.String()
+	return mail, nil
+}
 
-	fmt.Println("******* send mail to **********")
-	fmt.Println(to)
-	fmt.Println(subject)
-	fmt.Println(textOutput)
-	fmt.Println("******* END send mail to **********")
+// ForwardMailMessage is the Thread message forwarded with the forward mail.
+type ForwardMailMessage struct {
+	Author          string
+	SentAt          string
+	HTMLBody        template.HTML
+	TextBody        string
+	AttachmentNames []string
+}
+
+// ForwardMailData is the data available to the forward mail templates.
+// Forwarded messages are rendered from the text, the Customer's mail HTML is not forwarded as is.
+type ForwardMailData struct {
+	PreviewText   string
+	Subject       string
+	NoteHTML      template.HTML
+	NoteText      string
+	SignatureHTML template.HTML
+	SignatureText string
+	Messages      []ForwardMailMessage
+}
 
-	apiKey := zyg.ResendApiKey()
-	client := resend.NewClient(apiKey)
+// NewForwardMailData returns the forward mail template data for the Member's forward of the messages, oldest first.
+func NewForwardMailData(
+	thread models.Thread, forward models.ThreadForward, signature models.MailSignature,
+	messages []models.MessageWithAttachments,
+) ForwardMailData {
+	data := ForwardMailData{
+		PreviewText: previewText(forward.Note),
+		Subject:     thread.Title,
+		NoteText:    forward.Note,
+	}
+	if forward.Note != "" {
+		data.NoteHTML = template.HTML(utils.TextToHTML(forward.Note))
+	}
+	data.SignatureHTML, data.SignatureText = signatureVariants(signature)
+
+	for _, message := range messages {
+		text := message.TextBody
+		if text == "" {
+			text = message.MarkdownBody
+		}
+		var author string
+		if message.Customer != nil {
+			author = message.Customer.Name
+		} else if message.Member != nil {
+			author = message.Member.Name
+		}
+		m := ForwardMailMessage{
+			Author:   author,
+			SentAt:   message.CreatedAt.UTC().Format("Mon, 2 Jan 2006 at 15:04 MST"),
+			HTMLBody: template.HTML(utils.TextToHTML(text)),
+			TextBody: text,
+		}
+		for _, a := range message.Attachments {
+			if !a.HasError && !a.Spam {
+				m.AttachmentNames = append(m.AttachmentNames, a.Name)
+			}
+		}
+		data.Messages = append(data.Messages, m)
+	}
+	return data
+}
+
+// NewForwardMail renders the forward mail HTML and text.
+func NewForwardMail(mail models.Mail, data ForwardMailData) (models.Mail, error) {
+	htmlTempl, err := template.ParseFiles("static/templates/mails/forward.html")
+	if err != nil {
+		slog.Error("error parsing html template file", slog.Any("err", err))
+		return models.Mail{}, err
+	}
+	textTempl, err := texttemplate.ParseFiles("static/templates/mails/text/forward.txt")
+	if err != nil {
+		slog.Error("error parsing text template file", slog.Any("err", err))
+		return models.Mail{}, err
+	}
 
-	params := &resend.SendEmailRequest{
-		From:    "Sanchit <sanchit@updates.zyg.ai>",
-		To:      []string{to},
-		Subject: subject,
-		Html:    htmlOutput,
-		Text:    textOutput,
-		ReplyTo: "sanchit@zyg.ai",
+	var htmlTemplOutput bytes.Buffer
+	err = htmlTempl.Execute(&htmlTemplOutput, data)
+	if err != nil {
+		slog.Error("error executing html template", slog.Any("err", err))
+		return models.Mail{}, err
 	}
 
-	sent, err := client.Emails.Send(params)
+	var textTemplOutput bytes.Buffer
+	err = textTempl.Execute(&textTemplOutput, data)
 	if err != nil {
-		slog.Error("failed to send email", slog.Any("err", err))
-		return err
+		slog.Error("error executing text template", slog.Any("err", err))
+		return models.Mail{}, err
 	}
 
-	slog.Info("sent email", slog.Any("Id", sent.Id))
+	mail.HTMLBody = htmlTemplOutput.String()
+	mail.TextBody = textTemplOutput.String()
+	return mail, nil
+}
 
-	return nil
+// signatureVariants returns the HTML and text variants of the signature,
+// the missing variant is derived from the other.
+func signatureVariants(signature models.MailSignature) (template.HTML, string) {
+	if signature.IsEmpty() {
+		return "", ""
+	}
+	text := signature.TextBody
+	if text == "" {
+		extracted, err := utils.ExtractTextFromHTML(signature.HTMLBody)
+		if err != nil {
+			slog.Error("failed to extract signature text", slog.Any("err", err))
+		}
+		text = strings.TrimSpace(extracted)
+	}
+	if signature.HTMLBody != "" {
+		return template.HTML(signature.HTMLBody), text
+	}
+	return template.HTML(utils.TextToHTML(signature.TextBody)), text
+}
+
+// quoteText prefixes each line of the text with the `> ` quote prefix.
+func quoteText(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// previewText returns the first line of the text as the mail preview.
+func previewText(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(line); len(runes) > 140 {
+		return string(runes[:140])
+	}
+	return line
 }
@@ -3,17 +3,14 @@ package services
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/getsentry/sentry-go"
 	"github.com/zyghq/zyg"
 	"github.com/zyghq/zyg/adapters/store"
-	"github.com/zyghq/zyg/integrations/email"
-	"github.com/zyghq/zyg/utils"
-	"log/slog"
 	"time"
 
 	"github.com/zyghq/zyg/adapters/repository"
@@ -32,205 +29,6 @@ func NewThreadService(
 	}
 }
 
-// CreateInboundThreadChat creates a new inbound thread chat for the customer.
-// This is usually triggered when a customer sends a message.
-// Inbound is always assumed as a customer message.
-func (s *ThreadService) CreateInboundThreadChat(
-	ctx context.Context, workspaceId string, customer models.Customer,
-	createdBy models.MemberActor, messageText string,
-) (models.Thread, models.Message, error) {
-	// Creates new thread for the in-app chat channel.
-	channel := models.ThreadChannel{}.InAppChat() // source channel the thread belongs to
-	newThread := models.NewThread(
-		workspaceId, customer.AsCustomerActor(), createdBy, channel,
-	)
-	// Create new Customer inbound message.
-	newMessage := models.NewMessage(
-		newThread.ThreadId, channel,
-		models.SetMessageCustomer(customer.AsCustomerActor()),
-		models.SetMessageTextBody(messageText),
-		models.SetMarkdownBody(messageText),
-	)
-	newThread.SetNextInboundSeq(newMessage.PreviewText())
-
-	threadMessage := models.ThreadMessage{
-		Thread:  newThread,
-		Message: newMessage,
-	}
-	insThread, insMessage, err := s.repo.InsertInboundThreadMessage(ctx, threadMessage)
-	if err != nil {
-		return models.Thread{}, models.Message{}, ErrThreadChat
-	}
-	return insThread, insMessage, nil
-}
-
-func (s *ThreadService) GetPostmarkInReplyThread(
-	ctx context.Context, workspaceId string, mailMessageId string) (*models.Thread, error) {
-	thread, err := s.repo.FindThreadByPostmarkReplyMessageId(ctx, workspaceId, mailMessageId)
-	if errors.Is(err, repository.ErrEmpty) {
-		return nil, ErrThreadNotFound
-	}
-	if err != nil {
-		slog.Error("failed to fetch thread by postmark inbound reply message id", slog.Any("err", err))
-		return nil, ErrThread
-	}
-	return &thread, nil
-}
-
-func (s *ThreadService) IsPostmarkInboundMessageProcessed(ctx context.Context, messageId string) (bool, error) {
-	exists, err := s.repo.CheckPostmarkInboundMessageExists(ctx, messageId)
-	if err != nil {
-		return false, ErrPostmarkInbound
-	}
-	return exists, nil
-}
-
-// ProcessPostmarkInbound processes an inbound Postmark email, handling threading logic, message creation, and attachments.
-// It creates or finds an existing email thread based on the provided inbound message and processes HTML/Markdown conversions.
-// If attachments exist, they are uploaded and persisted. Returns the updated or new thread and message, or an error if any.
-func (s *ThreadService) ProcessPostmarkInbound(
-	ctx context.Context, workspaceId string,
-	customer models.CustomerActor, createdBy models.MemberActor, inboundMessage *models.PostmarkInboundMessage,
-) (models.Thread, models.Message, error) {
-	hub := sentry.GetHubFromContext(ctx)
-
-	// Check if an existing thread already exists for the inbound Postmark based on reply mail message ID
-	// otherwise, creates a new thread for the channel.
-	channel := models.ThreadChannel{}.Email()
-	var thread, threadExists, err = func(channel string) (*models.Thread, bool, error) {
-		// Check if this inboundMessage is a reply to existing inboundMessage.
-		// It's possible that reply mail message ID might exist for the inbound without
-		// the corresponding thread in our system.
-		if inboundMessage.ReplyMailMessageId != nil {
-			// Get existing thread for Postmark inbound in-reply inboundMessage if exists.
-			// Otherwise, creates a new thread.
-			existingThread, err := s.GetPostmarkInReplyThread(ctx, workspaceId, *inboundMessage.ReplyMailMessageId)
-			if errors.Is(err, ErrThreadNotFound) {
-				slog.Info("thread not found for postmark inbound reply mail message ID should start new thread")
-				newThread := models.NewThread(
-					workspaceId, customer, createdBy, channel,
-					models.SetThreadTitle(inboundMessage.Subject),
-					models.SetThreadDescription(inboundMessage.TextBody),
-				)
-				return newThread, false, nil
-			}
-			if err != nil {
-				hub.CaptureException(err)
-				slog.Error(
-					"failed to get existing thread for postmark inbound in-reply", slog.Any("err", err))
-				return nil, false, ErrThread
-			}
-			// Returns existing thread.
-			return existingThread, true, nil
-		}
-		// If inboundMessage is not a reply, start a new thread.
-		newThread := models.NewThread(
-			workspaceId, customer, createdBy, channel,
-			models.SetThreadTitle(inboundMessage.Subject),
-			models.SetThreadDescription(inboundMessage.TextBody),
-		)
-		// Return new thread.
-		return newThread, false, nil
-	}(channel)
-	if err != nil {
-		hub.CaptureException(err)
-		slog.Error("failed to get existing thread or create one", slog.Any("err", err))
-		return models.Thread{}, models.Message{}, ErrThread
-	}
-
-	// Clean HTML into markdown - in case of error use HTML as fallback
-	var markdownBody string
-	cleanedHTML, err := utils.CleanHTML(inboundMessage.HTMLBody, utils.DefaultHTMLMatchers())
-	if err != nil {
-		slog.Error("failed to clean up postmark inbound mail html", slog.Any("err", err))
-		markdownBody = inboundMessage.HTMLBody // Set fallback to raw HTMLBody
-	} else {
-		// Proceed to attempt converting the cleaned HTML to Markdown
-		markdownBody, err = utils.HTMLToMarkdown(cleanedHTML)
-		if err != nil {
-			slog.Error("failed to convert html to markdown", slog.Any("err", err))
-			markdownBody = inboundMessage.HTMLBody // Set fallback to raw HTMLBody
-		}
-	}
-
-	newMessage := models.NewMessage(
-		thread.ThreadId, channel,
-		models.SetMessageCustomer(customer),
-		models.SetHTMLBody(inboundMessage.HTMLBody),
-		models.SetMessageTextBody(inboundMessage.TextBody),
-		models.SetMarkdownBody(markdownBody),
-	)
-	thread.SetNextInboundSeq(newMessage.PreviewText())
-	// Convert postmark inbound message into Postmark message log.
-	// The is persisted for both inbound and outbound messages.
-	// Inbound as received from Postmark.
-	postmarkMessageLog := inboundMessage.ToPostmarkMessageLog(newMessage.MessageId)
-
-	// If thread exists, append to the existing thread.
-	if threadExists {
-		newMessage, err = s.repo.AppendPostmarkInboundThreadMessage(
-			ctx, thread.ThreadId, thread.InboundMessage, &postmarkMessageLog, newMessage)
-		if err != nil {
-			hub.CaptureException(err)
-			slog.Error("failed to append postmark inbound message to existing thread", slog.Any("err", err))
-			return models.Thread{}, models.Message{}, ErrPostmarkInbound
-		}
-	} else {
-		thread, newMessage, err = s.repo.InsertPostmarkInboundThreadMessage(
-			ctx, thread, &postmarkMessageLog, newMessage)
-		if err != nil {
-			hub.CaptureException(err)
-			slog.Error("failed to insert postmark inbound message to new thread", slog.Any("err", err))
-			return models.Thread{}, models.Message{}, ErrPostmarkInbound
-		}
-	}
-
-	accountId := zyg.CFAccountId()
-	accessKeyId := zyg.R2AccessKeyId()
-	accessKeySecret := zyg.R2AccessSecretKey()
-	s3Bucket := zyg.S3Bucket()
-	s3Client, err := store.NewS3(ctx, s3Bucket, accountId, accessKeyId, accessKeySecret)
-	if err != nil {
-		hub.CaptureException(err)
-		slog.Error("failed to connect S3 to process inbound message attachments", slog.Any("err", err))
-		return *thread, *newMessage, nil
-	}
-
-	// Process attachments if any.
-	if len(inboundMessage.Attachments) > 0 {
-		attachments := make([]models.MessageAttachment, 0, len(inboundMessage.Attachments))
-		for _, a := range inboundMessage.Attachments {
-			att, attErr := ProcessMessageAttachment(
-				ctx, thread.WorkspaceId, thread.ThreadId, newMessage.MessageId,
-				a.Content, a.ContentType, a.Name, s3Client,
-			)
-			if attErr != nil {
-				hub.Scope().SetTag("messageId", att.MessageId)
-				hub.Scope().SetTag("attachmentId", att.AttachmentId)
-				hub.Scope().SetTag("attachmentName", att.Name)
-				hub.Scope().SetTag("attachmentMD5Hash", att.MD5Hash)
-				hub.CaptureException(attErr)
-				slog.Error(
-					"failed to process inbound message attachment",
-					slog.Any("err", attErr),
-					slog.Any("attachmentId", att.AttachmentId),
-				)
-			}
-			attachments = append(attachments, att)
-		}
-		// Persists processed inbound message attachments
-		// @sanchitrk: bulk inserts?
-		for _, a := range attachments {
-			_, err := s.repo.InsertMessageAttachment(ctx, a)
-			if err != nil {
-				slog.Error(
-					"failed to insert inbound message attachment", slog.Any("err", err))
-			}
-		}
-	}
-	return *thread, *newMessage, nil
-}
-
 func (s *ThreadService) UpdateThread(
 	ctx context.Context, thread models.Thread, fields []string) (models.Thread, error) {
 	thread, err := s.repo.ModifyThreadById(ctx, thread, fields)
@@ -337,172 +135,35 @@ func (s *ThreadService) ListThreadLabels(
 	return labels, nil
 }
 
-// AppendInboundThreadChat adds inbound message to an existing thread.
-func (s *ThreadService) AppendInboundThreadChat(
-	ctx context.Context, thread models.Thread, messageText string) (models.Message, error) {
-
-	channel := models.ThreadChannel{}.InAppChat()
-	newMessage := models.NewMessage(
-		thread.ThreadId, channel,
-		models.SetMessageCustomer(thread.Customer),
-		models.SetMessageTextBody(messageText),
-		models.SetMarkdownBody(messageText),
-	)
-	thread.SetNextInboundSeq(newMessage.PreviewText())
-
-	threadMessage := models.ThreadMessage{
-		Thread:  &thread,
-		Message: newMessage,
-	}
-	message, err := s.repo.AppendInboundThreadMessage(ctx, threadMessage)
-	if err != nil {
-		return models.Message{}, ErrThreadMessage
-	}
-	return message, nil
-}
-
-// AppendOutboundThreadChat appends a new chat message to an existing thread.
-func (s *ThreadService) AppendOutboundThreadChat(
-	ctx context.Context, thread models.Thread, member models.Member, messageText string) (models.Message, error) {
-
-	channel := models.ThreadChannel{}.InAppChat()
-	newMessage := models.NewMessage(
-		thread.ThreadId, channel,
-		models.SetMessageMember(member.AsMemberActor()),
-		models.SetMessageTextBody(messageText),
-		models.SetMarkdownBody(messageText),
-	)
-	thread.SetNextInboundSeq(newMessage.PreviewText())
-
-	threadMessage := models.ThreadMessage{
-		Thread:  &thread,
-		Message: newMessage,
-	}
-	message, err := s.repo.AppendOutboundThreadMessage(ctx, threadMessage)
+func (s *ThreadService) ListThreadMessages(
+	ctx context.Context, threadId string) ([]models.Message, error) {
+	messages, err := s.repo.FetchMessagesByThreadId(ctx, threadId)
 	if err != nil {
-		return models.Message{}, ErrThreadMessage
+		return []models.Message{}, ErrThreadMessage
 	}
-	return message, nil
+	return messages, nil
 }
 
-func (s *ThreadService) SendThreadMailReply(
-	ctx context.Context,
-	workspace models.Workspace,
-	setting models.PostmarkMailServerSetting, thread models.Thread,
-	member models.Member, customer models.Customer, textBody, htmlBody string,
-) (models.Message, error) {
-	hub := sentry.GetHubFromContext(ctx)
-
-	// Make sure web have the customer email, to send reply.
-	if !customer.Email.Valid {
-		slog.Error("customer email is not valid",
-			slog.Any("customerId", customer.CustomerId), slog.Any("email", customer.Email))
-		hub.Scope().SetTag("customerId", customer.CustomerId)
-		hub.CaptureMessage("customer email is not valid or does not exist - cannot send reply mail")
-		return models.Message{}, ErrPostmarkOutbound
-	}
-
-	fromName := fmt.Sprintf("%s at %s", member.Name, workspace.Name)
-	from := fmt.Sprintf("%s <%s>", fromName, setting.Email)
-	replySubject := fmt.Sprintf("Re: %s", thread.Title)
-
-	// extract from HTML if text is empty
-	// fallback to specified text in any case
-	var textBodyFmt string
-	if textBody != "" {
-		textBodyFmt = textBody
-	} else {
-		extractedText, err := utils.ExtractTextFromHTML(htmlBody)
-		if err != nil {
-			hub.CaptureException(err)
-			textBodyFmt = textBody
-		} else {
-			textBodyFmt = extractedText
-		}
-	}
-
-	markdownBody, err := utils.HTMLToMarkdown(htmlBody)
-	if err != nil {
-		hub.CaptureMessage("failed to convert HTML to markdown for send reply mail")
-		hub.CaptureException(err)
-		slog.Error("failed to convert HTML to markdown for send reply mail", slog.Any("err", err))
-		markdownBody = htmlBody // fallback to HTML
-	}
-
-	// Get recent Postmark mail message ID
-	// The mail message ID is used in header for `In-Reply-To` maintaining a mail thread.
-	replyMailMessageId, err := s.GetRecentThreadMailMessageId(ctx, thread.ThreadId)
-	if err != nil {
-		hub.CaptureException(err)
-		slog.Error("failed to get recent postmark message log mail message ID", slog.Any("err", err))
-		return models.Message{}, ErrPostmarkOutbound
-	}
-
-	newMessage := models.NewMessage(
-		thread.ThreadId, models.ThreadChannel{}.Email(),
-		models.SetMessageMember(member.AsMemberActor()),
-		models.SetHTMLBody(htmlBody),
-		models.SetMessageTextBody(textBodyFmt),
-		models.SetMarkdownBody(markdownBody),
-	)
-	thread.SetNextOutboundSeq(member.AsMemberActor(), newMessage.PreviewText())
-
-	pmEmailReq := email.NewPostmarkEmailReq(
-		replySubject, from, customer.Email.String,
-		email.WithPostmarkHeader("In-Reply-To", replyMailMessageId),
-		email.SetPostmarkTag(customer.CustomerId),
-		email.SetPostmarkTextBody(newMessage.TextBody),
-		email.SetPostmarkHTMLBody(newMessage.HTMLBody),
-	)
-
-	payload, err := utils.StructToMap(pmEmailReq)
-	if err != nil {
-		hub.CaptureException(err)
-		slog.Error("failed to marshal postmark email request", slog.Any("err", err))
-		return models.Message{}, ErrPostmarkOutbound
+// GetLatestThreadMessage returns the most recent message of the Thread, nil if the Thread has no messages.
+func (s *ThreadService) GetLatestThreadMessage(
+	ctx context.Context, threadId string) (*models.Message, error) {
+	message, err := s.repo.LookupLatestThreadMessage(ctx, threadId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return nil, nil
 	}
-
-	resp, err := email.SendPostmarkMail(ctx, setting, pmEmailReq)
 	if err != nil {
-		hub.CaptureException(err)
-		slog.Error("failed to send postmark email", slog.Any("err", err))
-		return models.Message{}, ErrPostmarkOutbound
-	}
-
-	messageLog := models.PostmarkMessageLog{
-		MessageId:          newMessage.MessageId,
-		Payload:            payload,
-		PostmarkMessageId:  resp.MessageID,
-		ReplyMailMessageId: &replyMailMessageId,
-		HasError:           false,
-		SubmittedAt:        resp.SubmittedAt,
-		ErrorCode:          resp.ErrorCode,
-		PostmarkMessage:    resp.Message,
-		Acknowledged:       false,
-		MessageType:        "outbound",
-		CreatedAt:          time.Now(),
-		UpdatedAt:          time.Now(),
-	}
-	// Set outbound mail message ID for this message
-	messageLog.SetOutboundMailMessageId(zyg.PostmarkDeliveryDomain())
-
-	newMessage, err = s.repo.AppendPostmarkInboundThreadMessage(
-		ctx, thread.ThreadId, thread.InboundMessage, &messageLog, newMessage)
-	if err != nil {
-		hub.CaptureException(err)
-		slog.Error("failed to append postmark inbound thread message", slog.Any("err", err))
-		return models.Message{}, ErrPostmarkInbound
+		return nil, ErrThreadMessage
 	}
-	return *newMessage, nil
+	return &message, nil
 }
 
-func (s *ThreadService) ListThreadMessages(
-	ctx context.Context, threadId string) ([]models.Message, error) {
-	messages, err := s.repo.FetchMessagesByThreadId(ctx, threadId)
+func (s *ThreadService) ListThreadParticipants(
+	ctx context.Context, threadId string) ([]models.ThreadParticipant, error) {
+	participants, err := s.repo.FetchThreadParticipants(ctx, threadId)
 	if err != nil {
-		return []models.Message{}, ErrThreadMessage
+		return []models.ThreadParticipant{}, ErrThread
 	}
-	return messages, nil
+	return participants, nil
 }
 
 func (s *ThreadService) ListThreadMessagesWithAttachments(
@@ -595,13 +256,100 @@ func (s *ThreadService) GetMessageAttachment(
 	return attachment, nil
 }
 
-func (s *ThreadService) GetRecentThreadMailMessageId(ctx context.Context, threadId string) (string, error) {
-	mailMessageId, err := s.repo.GetRecentMailMessageIdByThreadId(ctx, threadId)
+// UploadReplyAttachment stores the Member's upload for the reply to the Thread.
+// The upload is linked to the reply message when the reply is sent.
+func (s *ThreadService) UploadReplyAttachment(
+	ctx context.Context, upload models.AttachmentUpload, content []byte) (models.AttachmentUpload, error) {
+	accountId := zyg.CFAccountId()
+	accessKeyId := zyg.R2AccessKeyId()
+	accessKeySecret := zyg.R2AccessSecretKey()
+	s3Bucket := zyg.S3Bucket()
+	s3Client, err := store.NewS3(ctx, s3Bucket, accountId, accessKeyId, accessKeySecret)
+	if err != nil {
+		return models.AttachmentUpload{}, ErrMessageAttachment
+	}
+
+	s3Key := generateS3Key(upload.WorkspaceId, upload.ThreadId, upload.AttachmentId, upload.Name)
+	_, err = s3Client.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s3Client.BucketName),
+		Key:         aws.String(s3Key),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String(upload.ContentType),
+	})
+	if err != nil {
+		return models.AttachmentUpload{}, ErrMessageAttachment
+	}
+
+	upload.ContentKey = s3Key
+	upload.ContentUrl = generateS3URL(s3Client.BaseEndpoint, s3Client.BucketName, s3Key)
+	upload.MD5Hash = fmt.Sprintf("%x", md5.Sum(content))
+	upload, err = s.repo.InsertAttachmentUpload(ctx, upload)
+	if err != nil {
+		return models.AttachmentUpload{}, ErrMessageAttachment
+	}
+	return upload, nil
+}
+
+// followUpLease is how long the claimed thread is held by the follow-up scheduler before it can be claimed again,
+// e.g. if the scheduler exits while sending the follow-up.
+const followUpLease = 5 * time.Minute
+
+// ClaimThreadsDueForFollowUp returns the threads waiting on the customer due for follow-up across workspaces,
+// held by the scheduler for the lease.
+func (s *ThreadService) ClaimThreadsDueForFollowUp(ctx context.Context, limit int) ([]models.Thread, error) {
+	now := time.Now().UTC()
+	threads, err := s.repo.ClaimThreadsDueForFollowUp(
+		ctx, models.ThreadChannel{}.FollowUpChannels(), now, now.Add(followUpLease), limit)
+	if err != nil {
+		return []models.Thread{}, ErrThread
+	}
+	return threads, nil
+}
+
+// ClaimThreadsDueForResolve returns the followed up threads due to be resolved across workspaces,
+// held by the scheduler for the lease.
+func (s *ThreadService) ClaimThreadsDueForResolve(ctx context.Context, limit int) ([]models.Thread, error) {
+	now := time.Now().UTC()
+	threads, err := s.repo.ClaimThreadsDueForResolve(ctx, now, now.Add(followUpLease), limit)
+	if err != nil {
+		return []models.Thread{}, ErrThread
+	}
+	return threads, nil
+}
+
+// RecordThreadFollowUp records the follow-up step of the thread with the follow-up message if sent.
+func (s *ThreadService) RecordThreadFollowUp(
+	ctx context.Context, thread models.Thread, messageId *string) (models.ThreadFollowUp, error) {
+	now := time.Now().UTC()
+	followUp := models.ThreadFollowUp{
+		ThreadId:     thread.ThreadId,
+		WorkspaceId:  thread.WorkspaceId,
+		Status:       models.ThreadFollowUpStatus{}.FollowedUp(),
+		MessageId:    messageId,
+		FollowedUpAt: now,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	followUp, err := s.repo.SaveThreadFollowUp(ctx, followUp)
+	if err != nil {
+		return models.ThreadFollowUp{}, ErrFollowUp
+	}
+	return followUp, nil
+}
+
+// AutoResolveThread resolves the followed up thread as the specified member, usually the system member.
+// Returns ErrFollowUpStale if the follow-up was cancelled in the meantime.
+func (s *ThreadService) AutoResolveThread(
+	ctx context.Context, thread models.Thread, member models.MemberActor) (models.Thread, error) {
+	if err := thread.Resolve(member); err != nil {
+		return models.Thread{}, err
+	}
+	err := s.repo.ResolveThreadFollowUp(ctx, &thread)
 	if errors.Is(err, repository.ErrEmpty) {
-		return "", ErrPostmarkLogNotFound
+		return models.Thread{}, ErrFollowUpStale
 	}
 	if err != nil {
-		return "", ErrPostmarkLog
+		return models.Thread{}, ErrFollowUp
 	}
-	return mailMessageId, nil
+	return thread, nil
 }
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/zyghq/zyg"
+	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/adapters/store"
+	"github.com/zyghq/zyg/models"
+	"github.com/zyghq/zyg/ports"
+)
+
+// WhatsAppService handles the WhatsApp channel with the Cloud API.
+// Threads and messages are created through the Thread repository, the channel specifics
+// are tracked in the WhatsApp message log.
+type WhatsAppService struct {
+	repo       ports.WhatsAppRepositorer
+	threadRepo ports.ThreadRepositorer
+	client     ports.WhatsAppClient
+}
+
+func NewWhatsAppService(
+	repo ports.WhatsAppRepositorer, threadRepo ports.ThreadRepositorer, client ports.WhatsAppClient,
+) *WhatsAppService {
+	return &WhatsAppService{
+		repo:       repo,
+		threadRepo: threadRepo,
+		client:     client,
+	}
+}
+
+// GetWhatsAppSetting returns the WhatsApp setting of the workspace,
+// or the default disabled setting if not configured.
+func (s *WhatsAppService) GetWhatsAppSetting(ctx context.Context, workspaceId string) (models.WhatsAppSetting, error) {
+	setting, err := s.repo.FetchWhatsAppSettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewWhatsAppSetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.WhatsAppSetting{}, ErrWhatsAppSetting
+	}
+	return setting, nil
+}
+
+func (s *WhatsAppService) SaveWhatsAppSetting(
+	ctx context.Context, setting models.WhatsAppSetting) (models.WhatsAppSetting, error) {
+	setting, err := s.repo.SaveWhatsAppSetting(ctx, setting)
+	if err != nil {
+		return models.WhatsAppSetting{}, ErrWhatsAppSetting
+	}
+	return setting, nil
+}
+
+func (s *WhatsAppService) IsInboundWhatsAppProcessed(ctx context.Context, waMessageId string) (bool, error) {
+	exists, err := s.repo.CheckWhatsAppInboundMessageExists(ctx, waMessageId)
+	if err != nil {
+		return false, ErrWhatsAppLog
+	}
+	return exists, nil
+}
+
+// ProcessInboundWhatsApp appends the Customer's inbound message to the Customer's open WhatsApp Thread,
+// otherwise starts a new Thread. Media is downloaded and stored as the message attachment.
+func (s *WhatsAppService) ProcessInboundWhatsApp(
+	ctx context.Context, setting models.WhatsAppSetting,
+	customer models.CustomerActor, createdBy models.MemberActor, inbound *models.WhatsAppInbound,
+) (models.Thread, models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	channel := models.ThreadChannel{}.WhatsApp()
+	textBody := inbound.TextBody()
+
+	var thread *models.Thread
+	threadExists := false
+	threadId, err := s.repo.FetchOpenCustomerWhatsAppThreadId(ctx, setting.WorkspaceId, customer.CustomerId)
+	switch {
+	case errors.Is(err, repository.ErrEmpty):
+		thread = models.NewThread(
+			setting.WorkspaceId, customer, createdBy, channel,
+			models.SetThreadTitle(textBody),
+			models.SetThreadDescription(textBody),
+		)
+	case err != nil:
+		hub.CaptureException(err)
+		slog.Error("failed to fetch open customer whatsapp thread", slog.Any("err", err))
+		return models.Thread{}, models.Message{}, ErrWhatsAppInbound
+	default:
+		existingThread, err := s.threadRepo.LookupByWorkspaceThreadId(ctx, setting.WorkspaceId, threadId, &channel)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to fetch open customer whatsapp thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrWhatsAppInbound
+		}
+		thread = &existingThread
+		threadExists = true
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, channel,
+		models.SetMessageCustomer(customer),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(textBody),
+	)
+	thread.SetNextInboundSeq(newMessage.PreviewText())
+	// Customer reply on existing thread applies the automatic stage transition.
+	if threadExists {
+		thread.OnInboundMessage(createdBy)
+	}
+	// Flagged by the spam pipeline, overrides the stage transition.
+	if inbound.Spam.IsSpam {
+		thread.MarkSpam(createdBy)
+	}
+
+	// Message log also marks the inbound message as processed.
+	messageLog := inbound.ToWhatsAppMessageLog(newMessage.MessageId)
+	threadMessage := models.ThreadMessage{
+		Thread:      thread,
+		Message:     newMessage,
+		WhatsAppLog: &messageLog,
+	}
+	var message models.Message
+	if threadExists {
+		message, err = s.threadRepo.AppendInboundThreadMessage(ctx, threadMessage)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to append inbound whatsapp message to existing thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrWhatsAppInbound
+		}
+	} else {
+		var insThread models.Thread
+		insThread, message, err = s.threadRepo.InsertInboundThreadMessage(ctx, threadMessage)
+		if err != nil {
+			hub.CaptureException(err)
+			slog.Error("failed to insert inbound whatsapp message to new thread", slog.Any("err", err))
+			return models.Thread{}, models.Message{}, ErrWhatsAppInbound
+		}
+		thread = &insThread
+	}
+
+	if inbound.Media != nil {
+		s.processInboundMedia(ctx, setting, *thread, message, *inbound.Media)
+	}
+	return *thread, message, nil
+}
+
+// processInboundMedia downloads the inbound media and stores it as the message attachment.
+// Failures are reported, the message is already persisted.
+func (s *WhatsAppService) processInboundMedia(
+	ctx context.Context, setting models.WhatsAppSetting,
+	thread models.Thread, message models.Message, media models.WhatsAppMedia,
+) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	content, mimeType, err := s.client.DownloadMedia(ctx, setting, media.MediaId)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to download whatsapp media", slog.Any("err", err), slog.Any("mediaId", media.MediaId))
+		return
+	}
+	if media.MimeType != "" {
+		mimeType = media.MimeType
+	}
+
+	accountId := zyg.CFAccountId()
+	accessKeyId := zyg.R2AccessKeyId()
+	accessKeySecret := zyg.R2AccessSecretKey()
+	s3Bucket := zyg.S3Bucket()
+	s3Client, err := store.NewS3(ctx, s3Bucket, accountId, accessKeyId, accessKeySecret)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to connect S3 to process whatsapp media", slog.Any("err", err))
+		return
+	}
+
+	att, attErr := ProcessMessageAttachment(
+		ctx, thread.WorkspaceId, thread.ThreadId, message.MessageId,
+		base64.StdEncoding.EncodeToString(content), mimeType, media.Filename, s3Client,
+	)
+	if attErr != nil {
+		hub.Scope().SetTag("messageId", att.MessageId)
+		hub.Scope().SetTag("attachmentId", att.AttachmentId)
+		hub.CaptureException(attErr)
+		slog.Error(
+			"failed to process whatsapp media attachment",
+			slog.Any("err", attErr),
+			slog.Any("attachmentId", att.AttachmentId),
+		)
+	}
+	if _, err := s.threadRepo.InsertMessageAttachment(ctx, att); err != nil {
+		slog.Error("failed to insert whatsapp media attachment", slog.Any("err", err))
+	}
+}
+
+// SendThreadWhatsAppReply sends the Member's reply to the Customer's WhatsApp.
+// Free-form text is only sent within the session window of the Customer's last inbound message,
+// outside the window the template message is required.
+func (s *WhatsAppService) SendThreadWhatsAppReply(
+	ctx context.Context, setting models.WhatsAppSetting, thread models.Thread,
+	member models.Member, customer models.Customer, textBody string, template *models.WhatsAppTemplate,
+) (models.Message, error) {
+	hub := sentry.GetHubFromContext(ctx)
+
+	if !customer.Phone.Valid {
+		slog.Error("customer phone is not valid", slog.Any("customerId", customer.CustomerId))
+		hub.Scope().SetTag("customerId", customer.CustomerId)
+		hub.CaptureMessage("customer phone is not valid or does not exist - cannot send reply whatsapp")
+		return models.Message{}, ErrWhatsAppOutbound
+	}
+	to := models.WhatsAppId(customer.Phone.String)
+
+	lastInboundAt, err := s.repo.FetchLastCustomerWhatsAppInboundAt(ctx, setting.WorkspaceId, customer.CustomerId)
+	if err != nil && !errors.Is(err, repository.ErrEmpty) {
+		return models.Message{}, ErrWhatsAppOutbound
+	}
+	isSessionOpen := models.IsWhatsAppSessionOpen(lastInboundAt, time.Now().UTC())
+
+	var result models.WhatsAppSendResult
+	var kind string
+	switch {
+	case template != nil:
+		kind = models.WhatsAppMessageKind{}.Template()
+		textBody = template.Text()
+		result, err = s.client.SendTemplate(ctx, setting, to, *template)
+	case isSessionOpen:
+		kind = models.WhatsAppMessageKind{}.Text()
+		result, err = s.client.SendText(ctx, setting, to, textBody)
+	default:
+		return models.Message{}, ErrWhatsAppSessionClosed
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to send whatsapp message", slog.Any("err", err))
+		return models.Message{}, ErrWhatsAppOutbound
+	}
+
+	newMessage := models.NewMessage(
+		thread.ThreadId, models.ThreadChannel{}.WhatsApp(),
+		models.SetMessageMember(member.AsMemberActor()),
+		models.SetMessageTextBody(textBody),
+		models.SetMarkdownBody(textBody),
+	)
+	thread.SetNextOutboundSeq(member.AsMemberActor(), newMessage.PreviewText())
+	thread.OnOutboundMessage(member.AsMemberActor())
+
+	now := time.Now().UTC()
+	messageLog := models.WhatsAppMessageLog{
+		MessageId:   newMessage.MessageId,
+		WAMessageId: result.WAMessageId,
+		WaId:        to,
+		MessageType: "outbound",
+		Kind:        kind,
+		Status:      models.WhatsAppStatus{}.Accepted(),
+		Payload:     result.Payload,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	threadMessage := models.ThreadMessage{
+		Thread:      &thread,
+		Message:     newMessage,
+		WhatsAppLog: &messageLog,
+	}
+	message, err := s.threadRepo.AppendOutboundThreadMessage(ctx, threadMessage)
+	if err != nil {
+		hub.CaptureException(err)
+		slog.Error("failed to append outbound whatsapp thread message", slog.Any("err", err))
+		return models.Message{}, ErrWhatsAppOutbound
+	}
+	return message, nil
+}
+
+// UpdateWhatsAppReaction sets the Customer's reaction on the message.
+func (s *WhatsAppService) UpdateWhatsAppReaction(
+	ctx context.Context, reaction models.WhatsAppReaction) (models.WhatsAppMessageLog, error) {
+	messageLog, err := s.repo.ModifyWhatsAppMessageReaction(ctx, reaction)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.WhatsAppMessageLog{}, ErrWhatsAppLogNotFound
+	}
+	if err != nil {
+		return models.WhatsAppMessageLog{}, ErrWhatsAppLog
+	}
+	return messageLog, nil
+}
+
+// UpdateWhatsAppDeliveryStatus updates the outbound message status as reported by the webhook.
+func (s *WhatsAppService) UpdateWhatsAppDeliveryStatus(
+	ctx context.Context, update models.WhatsAppStatusUpdate) (models.WhatsAppMessageLog, error) {
+	messageLog, err := s.repo.ModifyWhatsAppMessageLogStatus(ctx, update)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.WhatsAppMessageLog{}, ErrWhatsAppLogNotFound
+	}
+	if err != nil {
+		return models.WhatsAppMessageLog{}, ErrWhatsAppLog
+	}
+	return messageLog, nil
+}
+
+func (s *WhatsAppService) ListThreadWhatsAppLogs(
+	ctx context.Context, threadId string) ([]models.WhatsAppMessageLog, error) {
+	logs, err := s.repo.FetchWhatsAppMessageLogsByThreadId(ctx, threadId)
+	if err != nil {
+		return []models.WhatsAppMessageLog{}, ErrWhatsAppLog
+	}
+	return logs, nil
+}
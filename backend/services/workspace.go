@@ -15,6 +15,7 @@ import (
 
 	"github.com/zyghq/zyg"
 	"github.com/zyghq/zyg/adapters/repository"
+	"github.com/zyghq/zyg/integrations/email"
 	"github.com/zyghq/zyg/models"
 	"github.com/zyghq/zyg/ports"
 )
@@ -395,6 +396,10 @@ func (ws *WorkspaceService) ValidateWidgetSession(
 	if err != nil {
 		return models.Customer{}, ErrWidgetSession
 	}
+	// revoked when the customer is blocked, shall not be replaced with a new session.
+	if session.IsRevoked() {
+		return models.Customer{}, ErrWidgetSessionRevoked
+	}
 
 	// decode data from the widget session.
 	// if there is an error, we assume the widget session is invalid.
@@ -413,6 +418,9 @@ func (ws *WorkspaceService) ValidateWidgetSession(
 	if customer.IdentityHash() != data.IdentityHash {
 		return models.Customer{}, ErrWidgetSessionInvalid
 	}
+	if customer.IsBlocked {
+		return models.Customer{}, ErrCustomerBlocked
+	}
 	return customer, nil
 }
 
@@ -430,6 +438,7 @@ func (ws *WorkspaceService) CreateWidgetSession(
 	// creates a new widget session with session data for the new customer ID
 	// and calculated identity hash.
 	session := (&models.WidgetSession{}).CreateSession(sessionId, widgetId)
+	session.CustomerId = models.NullString(&customer.CustomerId)
 	data := session.CreateSessionData(workspaceId, customer.CustomerId, customer.IdentityHash())
 	// set the encoded data for the provided secret key.
 	err = session.SetEncodeData(sk, data)
@@ -466,13 +475,23 @@ func (ws *WorkspaceService) PostmarkCreateMailServer(
 	// - OpenHookUrl
 	// - DeliveryHookUrl
 	// - ClickHookUrl
-	inboundHookURL := fmt.Sprintf("%s/webhooks/%s/postmark/inbound/", zyg.WebhookUrl(), workspaceId)
+	// The inbound hook URL authenticates with the new workspace webhook credential.
+	credential, secret, err := ws.issueWebhookCredential(ctx, workspaceId, nil)
+	if err != nil {
+		return models.PostmarkMailServerSetting{}, err
+	}
+	inboundHookURL, err := credential.HookURL(
+		zyg.WebhookServerUrl(), secret, models.PostmarkInboundHookPath(workspaceId))
+	if err != nil {
+		hub.CaptureException(err)
+		return models.PostmarkMailServerSetting{}, err
+	}
 	server := postmark.Server{
 		Name:           workspaceId,
 		Color:          "Green",
 		InboundHookURL: inboundHookURL,
 	}
-	server, err := client.CreateServer(ctx, server)
+	server, err = client.CreateServer(ctx, server)
 	if err != nil {
 		hub.CaptureException(err)
 		return models.PostmarkMailServerSetting{}, err
@@ -658,3 +677,168 @@ func (ws *WorkspaceService) PostmarkMailServerUpdate(
 	}
 	return setting, nil
 }
+
+// ListWebhookCredentials returns the workspace webhook credentials, the latest first.
+func (ws *WorkspaceService) ListWebhookCredentials(
+	ctx context.Context, workspaceId string) ([]models.WebhookCredential, error) {
+	credentials, err := ws.workspaceRepo.FetchWebhookCredentialsByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return []models.WebhookCredential{}, ErrWebhookCredential
+	}
+	return credentials, nil
+}
+
+// issueWebhookCredential generates the new webhook credential of the workspace or of the workspace mailbox,
+// the earlier credentials of the same are accepted for the overlap.
+func (ws *WorkspaceService) issueWebhookCredential(
+	ctx context.Context, workspaceId string, mailboxId *string) (models.WebhookCredential, string, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	credential, secret, err := models.NewWebhookCredential(workspaceId, mailboxId)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.WebhookCredential{}, "", ErrWebhookCredential
+	}
+	expiresAt := time.Now().UTC().Add(models.WebhookCredentialOverlap)
+	credential, err = ws.workspaceRepo.RotateWebhookCredential(ctx, credential, expiresAt)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.WebhookCredential{}, "", ErrWebhookCredential
+	}
+	return credential, secret, nil
+}
+
+// RotateWebhookCredential generates the new webhook credential of the workspace and updates the inbound hook URL
+// of the workspace Postmark server. The earlier credentials are accepted for the overlap, for the other sources
+// e.g. the MTA piping raw mail to be updated. Returns the secret of the credential, only the hash is kept.
+// Credential of the workspace mailbox, with the mailboxId, is only of the mail addressed to the mailbox,
+// the Postmark server inbound hook is of the workspace and kept.
+func (ws *WorkspaceService) RotateWebhookCredential(
+	ctx context.Context, workspaceId string, mailboxId *string) (models.WebhookCredential, string, error) {
+	hub := sentry.GetHubFromContext(ctx)
+	credential, secret, err := ws.issueWebhookCredential(ctx, workspaceId, mailboxId)
+	if err != nil {
+		return models.WebhookCredential{}, "", err
+	}
+	if credential.IsMailboxScoped() {
+		return credential, secret, nil
+	}
+
+	setting, err := ws.workspaceRepo.FetchPostmarkMailServerSettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return credential, secret, nil
+	}
+	if err != nil {
+		hub.CaptureException(err)
+		return models.WebhookCredential{}, "", ErrPostmarkSetting
+	}
+	inboundHookURL, err := credential.HookURL(
+		zyg.WebhookServerUrl(), secret, models.PostmarkInboundHookPath(workspaceId))
+	if err != nil {
+		hub.CaptureException(err)
+		return models.WebhookCredential{}, "", ErrWebhookCredential
+	}
+	err = email.UpdatePostmarkInboundHook(ctx, zyg.PostmarkAccountToken(), setting.ServerId, inboundHookURL)
+	if err != nil {
+		hub.CaptureException(err)
+		return models.WebhookCredential{}, "", ErrPostmarkSetting
+	}
+	hub.CaptureMessage(fmt.Sprintf("postmark server inbound hook rotated for ID: %d", setting.ServerId))
+	return credential, secret, nil
+}
+
+// RevokeWebhookCredential expires the workspace webhook credential now, e.g. when leaked.
+func (ws *WorkspaceService) RevokeWebhookCredential(
+	ctx context.Context, workspaceId string, credentialId string) (models.WebhookCredential, error) {
+	credential, err := ws.workspaceRepo.ExpireWebhookCredential(ctx, workspaceId, credentialId, time.Now().UTC())
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.WebhookCredential{}, ErrWebhookCredentialNotFound
+	}
+	if err != nil {
+		return models.WebhookCredential{}, ErrWebhookCredential
+	}
+	return credential, nil
+}
+
+// VerifyWebhookCredential checks the credential and the secret are of the workspace and not expired,
+// returning the credential. Returns ErrWebhookUnauthorized otherwise.
+func (ws *WorkspaceService) VerifyWebhookCredential(
+	ctx context.Context, workspaceId string, credentialId string, secret string) (models.WebhookCredential, error) {
+	credentials, err := ws.workspaceRepo.FetchWebhookCredentialsByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return models.WebhookCredential{}, ErrWebhookCredential
+	}
+	now := time.Now().UTC()
+	for _, credential := range credentials {
+		if credential.CredentialId == credentialId && credential.Verify(secret, now) {
+			return credential, nil
+		}
+	}
+	return models.WebhookCredential{}, ErrWebhookUnauthorized
+}
+
+// VerifyLegacyWebhookAuth checks the global webhook credentials are still accepted for the workspace,
+// so that the inbound hooks configured before the workspace webhook credentials keep working until updated,
+// never after the cutover deadline of zyg.WebhookLegacyAuthUntil. Returns ErrWebhookUnauthorized otherwise.
+func (ws *WorkspaceService) VerifyLegacyWebhookAuth(ctx context.Context, workspaceId string) error {
+	until := zyg.WebhookLegacyAuthUntil()
+	now := time.Now().UTC()
+	if !models.AcceptsLegacyWebhookAuth(nil, until, now) {
+		return ErrWebhookUnauthorized
+	}
+	credentials, err := ws.workspaceRepo.FetchWebhookCredentialsByWorkspaceId(ctx, workspaceId)
+	if err != nil {
+		return ErrWebhookCredential
+	}
+	if !models.AcceptsLegacyWebhookAuth(credentials, until, now) {
+		return ErrWebhookUnauthorized
+	}
+	return nil
+}
+
+// BackfillWebhookCredentials issues the webhook credential of each workspace without one, updating the inbound
+// hook URL of the workspace Postmark server. The global webhook credentials are then accepted for the overlap.
+// Run on the server start, the server does not start until each workspace is issued.
+// Returns the count of the workspaces issued, with ErrWebhookCredential if any of the workspaces failed.
+func (ws *WorkspaceService) BackfillWebhookCredentials(ctx context.Context) (int, error) {
+	workspaceIds, err := ws.workspaceRepo.FetchWorkspaceIdsWithoutWebhookCredential(ctx)
+	if err != nil {
+		return 0, ErrWebhookCredential
+	}
+	var issued, failed int
+	for _, workspaceId := range workspaceIds {
+		if _, _, err := ws.RotateWebhookCredential(ctx, workspaceId, nil); err != nil {
+			slog.Error("failed to backfill workspace webhook credential",
+				slog.Any("workspaceId", workspaceId), slog.Any("err", err))
+			failed++
+			continue
+		}
+		issued++
+	}
+	if failed > 0 {
+		return issued, ErrWebhookCredential
+	}
+	return issued, nil
+}
+
+// GetThreadFollowUpSetting returns the follow-up setting of the workspace,
+// or the default disabled setting if the workspace has not saved one.
+func (ws *WorkspaceService) GetThreadFollowUpSetting(
+	ctx context.Context, workspaceId string) (models.ThreadFollowUpSetting, error) {
+	setting, err := ws.workspaceRepo.FetchThreadFollowUpSettingById(ctx, workspaceId)
+	if errors.Is(err, repository.ErrEmpty) {
+		return models.NewThreadFollowUpSetting(workspaceId), nil
+	}
+	if err != nil {
+		return models.ThreadFollowUpSetting{}, ErrFollowUpSetting
+	}
+	return setting, nil
+}
+
+func (ws *WorkspaceService) SaveThreadFollowUpSetting(
+	ctx context.Context, setting models.ThreadFollowUpSetting) (models.ThreadFollowUpSetting, error) {
+	setting, err := ws.workspaceRepo.SaveThreadFollowUpSetting(ctx, setting)
+	if err != nil {
+		return models.ThreadFollowUpSetting{}, ErrFollowUpSetting
+	}
+	return setting, nil
+}
@@ -12,10 +12,13 @@ import (
 // Tag specifies the HTML tag to match.
 // Class specifies the CSS class to match.
 // Attributes specifies a map of attribute key-value pairs to match.
+// Siblings specifies to also remove the siblings following the matched element, as in Outlook
+// where the quoted mail follows the reply header.
 type HTMLMatcher struct {
 	Tag        string
 	Class      string
 	Attributes map[string]string
+	Siblings   bool
 }
 
 // CleanHTML removes unwanted HTML elements from the provided content based on the specified matchers.
@@ -32,12 +35,21 @@ func CleanHTML(content string, matchers []HTMLMatcher) (string, error) {
 		// Track nodes to remove
 		var toRemove []*html.Node
 
+	children:
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			if c.Type == html.ElementNode {
 				for _, matcher := range matchers {
-					if shouldRemove(c, matcher) {
-						toRemove = append(toRemove, c)
+					if !shouldRemove(c, matcher) {
+						continue
 					}
+					if matcher.Siblings {
+						for s := c; s != nil; s = s.NextSibling {
+							toRemove = append(toRemove, s)
+						}
+						break children
+					}
+					toRemove = append(toRemove, c)
+					continue children
 				}
 			}
 			traverse(c)
@@ -70,6 +82,11 @@ func shouldRemove(n *html.Node, matcher HTMLMatcher) bool {
 		return n.Data == matcher.Tag
 	}
 
+	// Match tag along with the class or attributes
+	if matcher.Tag != "" && n.Data != matcher.Tag {
+		return false
+	}
+
 	// Match class
 	if matcher.Class != "" {
 		classFound := false
@@ -104,13 +121,52 @@ func shouldRemove(n *html.Node, matcher HTMLMatcher) bool {
 }
 
 // DefaultHTMLMatchers returns a slice of HTMLMatcher objects with predefined criteria for matching HTML elements.
-// GMail is a good starting point.
+// Matches the quoted history and the signature of GMail, Apple Mail, Thunderbird, Yahoo and Outlook.
 func DefaultHTMLMatchers() []HTMLMatcher {
 	return []HTMLMatcher{
 		{
 			Class: "gmail_quote",
 		},
+		{
+			Class: "gmail_signature",
+		},
+		{
+			Tag:        "blockquote",
+			Attributes: map[string]string{"type": "cite"},
+		},
+		{
+			Class: "moz-cite-prefix",
+		},
+		{
+			Class: "moz-signature",
+		},
+		{
+			Class: "yahoo_quoted",
+		},
+		{
+			Attributes: map[string]string{"id": "appendonsend"},
+			Siblings:   true,
+		},
+		{
+			Attributes: map[string]string{"id": "divRplyFwdMsg"},
+			Siblings:   true,
+		},
+	}
+}
+
+// TextToHTML formats the plain text as escaped HTML paragraphs, keeping the line breaks.
+func TextToHTML(text string) string {
+	var b strings.Builder
+	for _, p := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		p = html.EscapeString(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(p, "\n", "<br>"))
+		b.WriteString("</p>")
 	}
+	return b.String()
 }
 
 func HTMLToMarkdown(html string) (string, error) {
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MailReply is the reply of the inbound mail text, without the quoted history, the signature
+// and the mobile footer.
+type MailReply struct {
+	Text      string
+	Quoted    string // quoted history stripped from the reply, if any
+	Signature string // signature or mobile footer stripped from the reply, if any
+}
+
+// quoteHeaderRe matches the reply header of the mail client quoting the earlier mail,
+// e.g. Gmail, Apple Mail and Thunderbird "On <date>, <sender> wrote:", in English and the common languages.
+var quoteHeaderRe = regexp.MustCompile(`(?i)^\s*(on|le|am|el|em|il|op|w dniu|på|den|dne|в|kello)\s.*` +
+	`(wrote|a écrit|schrieb|escribió|escreveu|ha scritto|schreef|napisał\(a\)|napisał|napisała|skrev|napsal|написал\(а\)|написал|kirjoitti)` +
+	`\s*.{0,80}:\s*$`)
+
+// originalMessageRe matches the Outlook and Thunderbird original message separator.
+var originalMessageRe = regexp.MustCompile(`(?i)^\s*-{2,}\s*(original message|ursprüngliche nachricht|message d'origine|` +
+	`mensaje original|messaggio originale|oorspronkelijk bericht|mensagem original|originalmeddelande|` +
+	`wiadomość oryginalna|исходное сообщение)\s*-{2,}\s*$`)
+
+// underscoreRuleRe matches the Outlook rule above the reply header block.
+var underscoreRuleRe = regexp.MustCompile(`^\s*_{10,}\s*$`)
+
+// headerFromRe and headerSentRe match the Outlook reply header block, e.g. "From: ..." followed by "Sent: ...",
+// also as bold in markdown.
+var headerFromRe = regexp.MustCompile(`(?i)^\s*\**\s*(from|de|von|da|van|od|från|fra|от)\s*:\s*\**\s*\S`)
+var headerSentRe = regexp.MustCompile(`(?i)^\s*\**\s*(sent|date|envoyé|gesendet|datum|enviado|enviada|fecha|data|` +
+	`inviato|verzonden|wysłano|skickat|sendt|отправлено)\s*:`)
+
+// signatureDelimiterRe matches the signature delimiter "-- " of RFC 3676, also without the trailing space.
+var signatureDelimiterRe = regexp.MustCompile(`^--\s?$`)
+
+// mobileFooterRe matches the footer added by the mobile and the desktop mail apps.
+var mobileFooterRe = regexp.MustCompile(`(?i)^\s*(sent from my .+|sent from (outlook|mail|yahoo mail|gmail|aol)( for .+)?|` +
+	`sent from samsung .+|sent via .+|get outlook for .+|sent with proton mail .+|` +
+	`envoyé de mon .+|envoyé depuis .+|von meinem .+ gesendet|gesendet von .+|enviado desde mi .+|` +
+	`enviado do meu .+|inviato da .+|verzonden vanaf mijn .+|verstuurd vanaf mijn .+|skickat från min .+|` +
+	`wysłane z .+)\s*$`)
+
+// ParseTextReply returns the reply of the inbound mail text. The quoted history of Gmail, Outlook, Apple Mail,
+// Thunderbird and the lines quoted with ">" after the reply are stripped, then the signature and the mobile footer.
+// Quotes interleaved with the reply are kept. The text is returned as is if nothing is left of the reply.
+func ParseTextReply(text string) MailReply {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	reply := MailReply{Text: strings.TrimSpace(text)}
+	cut := quoteStart(lines)
+	body := lines[:cut]
+	quoted := strings.TrimSpace(strings.Join(lines[cut:], "\n"))
+
+	sigStart := signatureStart(body)
+	signature := strings.TrimSpace(strings.Join(body[sigStart:], "\n"))
+	replyText := strings.TrimSpace(strings.Join(body[:sigStart], "\n"))
+	if replyText == "" {
+		return reply
+	}
+	reply.Text = replyText
+	reply.Quoted = quoted
+	reply.Signature = signature
+	return reply
+}
+
+// quoteStart returns the line the quoted history starts at, otherwise the count of lines.
+func quoteStart(lines []string) int {
+	for i, line := range lines {
+		// Reply header wrapped over the lines, e.g. Gmail wraps the long "On ... wrote:".
+		for n := 1; n <= 3 && i+n <= len(lines); n++ {
+			header := strings.Join(trimLines(lines[i:i+n]), " ")
+			if quoteHeaderRe.MatchString(header) &&
+				(isTrailingQuote(lines[i+n:]) || hasHeaderBlock(lines[i+n:])) {
+				return i
+			}
+		}
+		if originalMessageRe.MatchString(line) {
+			return i
+		}
+		if underscoreRuleRe.MatchString(line) && hasHeaderBlock(lines[i+1:]) {
+			return i
+		}
+		if headerFromRe.MatchString(line) && i > 0 && hasSentHeader(lines[i+1:]) {
+			return i
+		}
+		if isQuoteLine(line) && isTrailingQuote(lines[i:]) {
+			return i
+		}
+	}
+	return len(lines)
+}
+
+// isTrailingQuote checks the lines are quoted with ">" till the end, the reply is not interleaved
+// or posted below the quote. Blank lines are skipped, nothing quoted is not the quote.
+func isTrailingQuote(lines []string) bool {
+	var seenQuote bool
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !isQuoteLine(line) {
+			return false
+		}
+		seenQuote = true
+	}
+	return seenQuote
+}
+
+func isQuoteLine(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), ">")
+}
+
+// hasHeaderBlock checks the Outlook reply header block follows the rule.
+func hasHeaderBlock(lines []string) bool {
+	for i, line := range lines {
+		if i > 2 {
+			return false
+		}
+		if headerFromRe.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSentHeader checks the sent or date header follows within the reply header block.
+func hasSentHeader(lines []string) bool {
+	for i, line := range lines {
+		if i > 3 {
+			return false
+		}
+		if headerSentRe.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// signatureStart returns the line the signature or the mobile footer starts at, otherwise the count of lines.
+// The mobile footer must be the last of the reply, the signature delimiter within the last lines.
+func signatureStart(lines []string) int {
+	last := len(lines) - 1
+	for last >= 0 && strings.TrimSpace(lines[last]) == "" {
+		last--
+	}
+	if last < 0 {
+		return len(lines)
+	}
+
+	start := len(lines)
+	if mobileFooterRe.MatchString(lines[last]) {
+		start = last
+	}
+	for i := max(0, last-12); i < start; i++ {
+		if signatureDelimiterRe.MatchString(lines[i]) {
+			return i
+		}
+	}
+	return start
+}
+
+func trimLines(lines []string) []string {
+	trimmed := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed = append(trimmed, strings.TrimSpace(line))
+	}
+	return trimmed
+}
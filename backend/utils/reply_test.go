@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readFixture returns the reply corpus fixture, the golden fixture without the trailing newline.
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "reply", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+	return string(b)
+}
+
+func TestParseTextReply(t *testing.T) {
+	tests := []struct {
+		name      string
+		quoted    bool
+		signature bool
+	}{
+		{name: "gmail", quoted: true},
+		{name: "outlook", quoted: true},
+		{name: "outlook_original_message", quoted: true},
+		{name: "outlook_mac", quoted: true},
+		{name: "apple_mail", quoted: true, signature: true},
+		{name: "thunderbird", quoted: true, signature: true},
+		{name: "localized_de", quoted: true},
+		{name: "localized_es", quoted: true},
+		{name: "localized_fr", quoted: true, signature: true},
+		{name: "localized_sv", quoted: true},
+		{name: "mobile_footer", signature: true},
+		// Reply header lookalikes not followed by the quote are kept as the reply.
+		{name: "header_without_quote"},
+		{name: "localized_header_without_quote"},
+		// Reply interleaved with the quote is kept as is.
+		{name: "interleaved"},
+		// Nothing left of the reply, the text is kept as is.
+		{name: "only_quote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := readFixture(t, tt.name+".txt")
+			want := strings.TrimSuffix(readFixture(t, tt.name+".golden"), "\n")
+
+			reply := ParseTextReply(text)
+			if reply.Text != want {
+				t.Errorf("ParseTextReply() text = %q, want %q", reply.Text, want)
+			}
+			if got := reply.Quoted != ""; got != tt.quoted {
+				t.Errorf("ParseTextReply() quoted = %q, want quoted %v", reply.Quoted, tt.quoted)
+			}
+			if got := reply.Signature != ""; got != tt.signature {
+				t.Errorf("ParseTextReply() signature = %q, want signature %v", reply.Signature, tt.signature)
+			}
+		})
+	}
+}
+
+func TestParseTextReplyCRLF(t *testing.T) {
+	text := strings.ReplaceAll(readFixture(t, "gmail.txt"), "\n", "\r\n")
+	reply := ParseTextReply(text)
+	if want := "Thanks, that fixed it!"; reply.Text != want {
+		t.Errorf("ParseTextReply() text = %q, want %q", reply.Text, want)
+	}
+}
+
+func TestCleanHTMLReply(t *testing.T) {
+	tests := []string{
+		"gmail",
+		"apple_mail",
+		"thunderbird",
+		"outlook",
+		"yahoo",
+	}
+	for _, name := range tests {
+		t.Run(name, func(t *testing.T) {
+			content := readFixture(t, name+".html")
+			want := strings.TrimSuffix(readFixture(t, name+".html.golden"), "\n")
+
+			cleaned, err := CleanHTML(content, DefaultHTMLMatchers())
+			if err != nil {
+				t.Fatalf("CleanHTML() err = %v", err)
+			}
+			text, err := ExtractTextFromHTML(cleaned)
+			if err != nil {
+				t.Fatalf("ExtractTextFromHTML() err = %v", err)
+			}
+			if text != want {
+				t.Errorf("CleanHTML() text = %q, want %q", text, want)
+			}
+		})
+	}
+}
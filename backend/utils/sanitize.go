@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLSanitizeOptions specifies how the image sources are rewritten by the sanitizer.
+// InlineImageURL resolves the `cid:` inline image to the attachment URL, unresolved inline images are removed.
+// If not specified, the inline images are kept as is to be resolved when served.
+// ImageProxyURL rewrites the remote image to be loaded through the proxy, not to leak the reader's address
+// and the read receipt to the sender. If not specified, the remote images are kept as is.
+type HTMLSanitizeOptions struct {
+	InlineImageURL func(contentId string) (string, bool)
+	ImageProxyURL  func(src string) string
+}
+
+// sanitizeDropTags are removed along with the content, forms are unwrapped with the controls removed.
+var sanitizeDropTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Iframe: true, atom.Frame: true, atom.Frameset: true, atom.Object: true, atom.Embed: true,
+	atom.Applet: true, atom.Svg: true, atom.Math: true, atom.Link: true, atom.Meta: true,
+	atom.Base: true, atom.Title: true, atom.Head: true, atom.Input: true,
+	atom.Button: true, atom.Select: true, atom.Option: true, atom.Textarea: true,
+	atom.Audio: true, atom.Video: true, atom.Source: true, atom.Track: true, atom.Canvas: true,
+}
+
+// sanitizeAllowTags are kept, other tags are unwrapped with the content kept.
+var sanitizeAllowTags = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.Address: true, atom.B: true, atom.Bdi: true, atom.Bdo: true,
+	atom.Blockquote: true, atom.Br: true, atom.Caption: true, atom.Center: true, atom.Cite: true,
+	atom.Code: true, atom.Col: true, atom.Colgroup: true, atom.Dd: true, atom.Del: true, atom.Dfn: true,
+	atom.Div: true, atom.Dl: true, atom.Dt: true, atom.Em: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Font: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Hr: true, atom.I: true, atom.Img: true, atom.Ins: true, atom.Kbd: true, atom.Li: true,
+	atom.Mark: true, atom.Ol: true, atom.P: true, atom.Pre: true, atom.Q: true, atom.S: true,
+	atom.Samp: true, atom.Small: true, atom.Span: true, atom.Strike: true, atom.Strong: true,
+	atom.Sub: true, atom.Sup: true, atom.Table: true, atom.Tbody: true, atom.Td: true, atom.Tfoot: true,
+	atom.Th: true, atom.Thead: true, atom.Time: true, atom.Tr: true, atom.Tt: true, atom.U: true,
+	atom.Ul: true, atom.Var: true, atom.Wbr: true,
+}
+
+// sanitizeAllowAttrs are the attributes kept on any of the allowed tags, `href` and `src` are checked
+// for the tag as the URL.
+var sanitizeAllowAttrs = map[string]bool{
+	"align": true, "alt": true, "bgcolor": true, "border": true, "cellpadding": true, "cellspacing": true,
+	"class": true, "color": true, "colspan": true, "dir": true, "face": true, "height": true, "lang": true,
+	"rowspan": true, "size": true, "span": true, "start": true, "style": true, "title": true, "type": true,
+	"valign": true, "width": true, "datetime": true, "cite": true,
+}
+
+// sanitizeStyleRe matches the inline style that loads the remote content or runs the script.
+var sanitizeStyleRe = regexp.MustCompile(`(?i)(expression\s*\(|url\s*\(|javascript:|vbscript:|@import|behavior\s*:|-moz-binding)`)
+
+// sanitizeDataImageRe matches the raster image data URL, SVG is not allowed as it can carry the script.
+var sanitizeDataImageRe = regexp.MustCompile(`(?i)^data:image/(png|gif|jpe?g|webp|bmp);base64,[a-z0-9+/=\s]+$`)
+
+// SanitizeHTML returns the HTML with only the allowlisted tags and attributes. Scripts, styles, frames,
+// forms and the event handlers are stripped. Links are kept for http, https, mailto and tel, opened
+// in the new tab without the referrer, images are kept for http, https, the raster data URL and the
+// inline `cid:` attachment.
+func SanitizeHTML(content string, opts HTMLSanitizeOptions) (string, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(content), body)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	sanitizeChildren(body, opts)
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func sanitizeChildren(n *html.Node, opts HTMLSanitizeOptions) {
+	c := n.FirstChild
+	for c != nil {
+		next := c.NextSibling
+		switch c.Type {
+		case html.TextNode:
+		case html.ElementNode:
+			if sanitizeDropTags[c.DataAtom] {
+				n.RemoveChild(c)
+				break
+			}
+			sanitizeChildren(c, opts)
+			if !sanitizeAllowTags[c.DataAtom] {
+				// Unwrap the tag, the sanitized content is kept in place.
+				for gc := c.FirstChild; gc != nil; gc = c.FirstChild {
+					c.RemoveChild(gc)
+					n.InsertBefore(gc, c)
+				}
+				n.RemoveChild(c)
+				break
+			}
+			if !sanitizeAttrs(c, opts) {
+				n.RemoveChild(c)
+			}
+		default:
+			// Comments including the conditional comments of Outlook, doctype.
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// sanitizeAttrs keeps the allowed attributes of the element, returns false if the element is to be removed,
+// as the image without the allowed source.
+func sanitizeAttrs(n *html.Node, opts HTMLSanitizeOptions) bool {
+	attrs := make([]html.Attribute, 0, len(n.Attr))
+	var hasSrc bool
+	for _, attr := range n.Attr {
+		key := strings.ToLower(attr.Key)
+		if attr.Namespace != "" {
+			continue
+		}
+		switch {
+		case key == "href" && n.DataAtom == atom.A:
+			if href, ok := sanitizeLinkURL(attr.Val); ok {
+				attrs = append(attrs, html.Attribute{Key: key, Val: href})
+			}
+		case key == "src" && n.DataAtom == atom.Img:
+			if src, ok := sanitizeImageURL(attr.Val, opts); ok {
+				attrs = append(attrs, html.Attribute{Key: key, Val: src})
+				hasSrc = true
+			}
+		case key == "style":
+			if !sanitizeStyleRe.MatchString(attr.Val) {
+				attrs = append(attrs, html.Attribute{Key: key, Val: attr.Val})
+			}
+		case key == "cite":
+			if cite, ok := sanitizeLinkURL(attr.Val); ok {
+				attrs = append(attrs, html.Attribute{Key: key, Val: cite})
+			}
+		case sanitizeAllowAttrs[key]:
+			attrs = append(attrs, html.Attribute{Key: key, Val: attr.Val})
+		}
+	}
+	if n.DataAtom == atom.Img && !hasSrc {
+		return false
+	}
+	if n.DataAtom == atom.A {
+		attrs = append(attrs,
+			html.Attribute{Key: "target", Val: "_blank"},
+			html.Attribute{Key: "rel", Val: "noopener noreferrer nofollow"},
+		)
+	}
+	n.Attr = attrs
+	return true
+}
+
+// sanitizeLinkURL returns the link URL if of the allowed scheme, the in-page anchor is allowed.
+func sanitizeLinkURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if strings.HasPrefix(raw, "#") {
+		return raw, true
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+		return u.String(), true
+	case "mailto", "tel":
+		return u.String(), true
+	}
+	return "", false
+}
+
+// sanitizeImageURL returns the image URL if of the allowed scheme, rewritten with the options.
+func sanitizeImageURL(raw string, opts HTMLSanitizeOptions) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if sanitizeDataImageRe.MatchString(raw) {
+		return raw, true
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "cid":
+		if opts.InlineImageURL == nil {
+			return "cid:" + u.Opaque, true
+		}
+		contentId, _ := url.PathUnescape(u.Opaque)
+		return opts.InlineImageURL(strings.Trim(contentId, "<>"))
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+		if opts.ImageProxyURL != nil {
+			return opts.ImageProxyURL(u.String()), true
+		}
+		return u.String(), true
+	}
+	return "", false
+}
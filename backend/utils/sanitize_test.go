@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "script tag",
+			content: `<p>Hi</p><script>alert(1)</script>`,
+			want:    `<p>Hi</p>`,
+		},
+		{
+			name:    "script tag uppercase",
+			content: `<SCRIPT src="https://evil.example/x.js"></SCRIPT><p>Hi</p>`,
+			want:    `<p>Hi</p>`,
+		},
+		{
+			name:    "style tag",
+			content: `<style>body{display:none}</style><p>Hi</p>`,
+			want:    `<p>Hi</p>`,
+		},
+		{
+			name:    "style attribute with url",
+			content: `<p style="background:url(https://evil.example/track.gif)">Hi</p>`,
+			want:    `<p>Hi</p>`,
+		},
+		{
+			name:    "style attribute kept",
+			content: `<p style="color:red">Hi</p>`,
+			want:    `<p style="color:red">Hi</p>`,
+		},
+		{
+			name:    "on attributes",
+			content: `<p onclick="alert(1)" onmouseover="alert(2)">Hi</p><img src="https://example.com/a.png" onerror="alert(3)">`,
+			want:    `<p>Hi</p><img src="https://example.com/a.png"/>`,
+		},
+		{
+			name:    "javascript link",
+			content: `<a href="javascript:alert(1)">link</a>`,
+			want:    `<a target="_blank" rel="noopener noreferrer nofollow">link</a>`,
+		},
+		{
+			name:    "javascript link obfuscated",
+			content: `<a href=" JaVaScRiPt:alert(1)">link</a>`,
+			want:    `<a target="_blank" rel="noopener noreferrer nofollow">link</a>`,
+		},
+		{
+			name:    "data link",
+			content: `<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">link</a>`,
+			want:    `<a target="_blank" rel="noopener noreferrer nofollow">link</a>`,
+		},
+		{
+			name:    "http link",
+			content: `<a href="https://example.com/docs">docs</a>`,
+			want:    `<a href="https://example.com/docs" target="_blank" rel="noopener noreferrer nofollow">docs</a>`,
+		},
+		{
+			name:    "data svg image",
+			content: `<img src="data:image/svg+xml;base64,PHN2Zz48L3N2Zz4=">`,
+			want:    ``,
+		},
+		{
+			name:    "data raster image",
+			content: `<img src="data:image/png;base64,iVBORw0KGgo=">`,
+			want:    `<img src="data:image/png;base64,iVBORw0KGgo="/>`,
+		},
+		{
+			name:    "javascript image",
+			content: `<img src="javascript:alert(1)">`,
+			want:    ``,
+		},
+		{
+			name:    "svg",
+			content: `<svg onload="alert(1)"><script>alert(2)</script></svg><p>Hi</p>`,
+			want:    `<p>Hi</p>`,
+		},
+		{
+			name:    "iframe and form",
+			content: `<iframe src="https://evil.example"></iframe><form action="https://evil.example"><input name="q"><p>Hi</p></form>`,
+			want:    `<p>Hi</p>`,
+		},
+		{
+			name:    "malformed nesting",
+			content: `<p><b>bold <i>both</b> italic</i></p>`,
+			want:    `<p><b>bold <i>both</i></b><i> italic</i></p>`,
+		},
+		{
+			name:    "unclosed script",
+			content: `<p>Hi</p><script>alert(1)`,
+			want:    `<p>Hi</p>`,
+		},
+		{
+			name:    "script in attribute breakout",
+			content: `<p title="x"><script>alert(1)</script>">Hi</p>`,
+			want:    `<p title="x">&#34;&gt;Hi</p>`,
+		},
+		{
+			name:    "comment",
+			content: `<!--[if mso]><script>alert(1)</script><![endif]--><p>Hi</p>`,
+			want:    `<p>Hi</p>`,
+		},
+		{
+			name:    "unknown tag unwrapped",
+			content: `<custom-el onclick="alert(1)"><b>Hi</b></custom-el>`,
+			want:    `<b>Hi</b>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SanitizeHTML(tt.content, HTMLSanitizeOptions{})
+			if err != nil {
+				t.Fatalf("SanitizeHTML() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SanitizeHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeHTMLInlineImage(t *testing.T) {
+	opts := HTMLSanitizeOptions{
+		InlineImageURL: func(contentId string) (string, bool) {
+			if contentId == "logo@example.com" {
+				return "https://cdn.example.com/logo.png", true
+			}
+			return "", false
+		},
+		ImageProxyURL: func(src string) string {
+			return "https://proxy.example.com/?url=" + src
+		},
+	}
+	got, err := SanitizeHTML(
+		`<img src="cid:logo@example.com"><img src="cid:missing@example.com"><img src="https://example.com/a.png">`, opts)
+	if err != nil {
+		t.Fatalf("SanitizeHTML() error = %v", err)
+	}
+	want := `<img src="https://cdn.example.com/logo.png"/><img src="https://proxy.example.com/?url=https://example.com/a.png"/>`
+	if got != want {
+		t.Errorf("SanitizeHTML() = %q, want %q", got, want)
+	}
+	if strings.Contains(got, "missing@example.com") {
+		t.Errorf("SanitizeHTML() kept the unresolved inline image")
+	}
+}